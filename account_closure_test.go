@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountCheckClose(t *testing.T) {
+	acc := &Account{ID: 1, Number: 42, Currency: "USD", Status: AccountActive}
+	assert.Nil(t, acc.CheckClose(nil), "empty accounts need nowhere to sweep to")
+
+	acc.Balance = -100
+	err := acc.CheckClose(nil)
+	assert.ErrorContains(t, err, "overdrawn by 100")
+	httpErr, ok := asHTTPError(err)
+	require.True(t, ok)
+	assert.Equal(t, ErrorCodeValidation, httpErr.Code)
+	assert.ErrorContains(t, acc.CheckClose(&Account{Number: 43, Currency: "USD", Status: AccountActive}), "overdrawn", "a sweep doesn't repay it")
+
+	acc.Balance = 100
+	assert.ErrorContains(t, acc.CheckClose(nil), "give an account to sweep it to")
+	assert.ErrorContains(t, acc.CheckClose(acc), "into itself")
+	assert.ErrorContains(t, acc.CheckClose(&Account{Number: 43, Currency: "EUR", Status: AccountActive}), "an account in USD")
+	assert.ErrorContains(t, acc.CheckClose(&Account{Number: 43, Currency: "USD", Status: AccountClosed}), "closed")
+
+	acc.PotBalance = 50
+	assert.ErrorContains(t, acc.CheckClose(&Account{Number: 43, Currency: "USD", Status: AccountActive}), "in pots")
+}
+
+func TestMemoryStoreCloseAccount(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	acc := &Account{Number: 42, Currency: "USD", Status: AccountActive}
+	acc.MinimumBalance, acc.OverdraftFee = 500, 25
+	require.Nil(t, store.CreateAccount(ctx, acc))
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 43, Currency: "USD", Status: AccountActive}))
+
+	_, err := store.Deposit(ctx, 42, 300, 0)
+	require.Nil(t, err)
+
+	start := time.Now().UTC().Add(time.Hour)
+	require.Nil(t, store.CreateStandingOrder(ctx, &StandingOrder{FromAccount: 42, ToAccount: 43, Amount: 10, Frequency: StandingOrderWeekly, Status: StandingOrderActive, StartDate: start, NextRunAt: start}))
+	require.Nil(t, store.CreateStandingOrder(ctx, &StandingOrder{FromAccount: 43, ToAccount: 42, Amount: 10, Frequency: StandingOrderWeekly, Status: StandingOrderActive, StartDate: start, NextRunAt: start}))
+	require.Nil(t, store.CreateScheduledTransfer(ctx, &ScheduledTransfer{FromAccount: 42, ToAccount: 43, Amount: 10, Status: ScheduledTransferActive, StartAt: start, NextRunAt: start}))
+
+	closure, err := store.CloseAccount(ctx, 42, 43)
+	require.Nil(t, err)
+	assert.Equal(t, AccountClosed, closure.Account.Status)
+	require.NotNil(t, closure.Sweep)
+	assert.Equal(t, int64(300), closure.Sweep.Amount, "the sweep ignores the minimum balance")
+	assert.Equal(t, 1, closure.CancelledStandingOrders, "orders paying into the account are not its own")
+	assert.Equal(t, 1, closure.CancelledScheduledTransfers)
+
+	closed, err := store.GetAccountByNumber(ctx, 42)
+	require.Nil(t, err)
+	assert.Zero(t, closed.Balance, "no overdraft fee is charged")
+
+	to, err := store.GetAccountByNumber(ctx, 43)
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), to.Balance)
+
+	_, err = store.CloseAccount(ctx, 42, 43)
+	assert.ErrorContains(t, err, "is closed")
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestAPICloseAccount(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/close"
+
+	_, err := api.store.Deposit(context.Background(), alice.Number, 1000, 0)
+	require.Nil(t, err)
+
+	rec := api.do("POST", path, token, CloseAccountRequest{})
+	assert.Equal(t, http.StatusConflict, rec.Code, "the balance must go somewhere")
+
+	rec = api.do("POST", path, api.login(bob, "bob-pw"), CloseAccountRequest{SweepTo: bob.Number})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "only the holder closes the account")
+
+	rec = api.do("POST", path, token, CloseAccountRequest{SweepTo: bob.Number})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	closure := new(AccountClosure)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(closure))
+	assert.Equal(t, AccountClosed, closure.Account.Status)
+	assert.Equal(t, int64(1000), closure.Sweep.Amount)
+	require.NotNil(t, closure.Statement)
+	assert.Zero(t, closure.Statement.ClosingBalance)
+	require.Len(t, closure.Statement.Transactions, 2)
+	assert.Equal(t, TransactionTransferOut, closure.Statement.Transactions[1].Type)
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, AuditAccountClosed, entries[0].Action)
+
+	rec = api.do("POST", path, token, CloseAccountRequest{})
+	assert.Equal(t, http.StatusConflict, rec.Code, "closing is final")
+}
+
+func TestAPICloseAccountChecksSweep(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/close"
+	ctx := context.Background()
+
+	_, err := api.store.Deposit(ctx, alice.Number, 200000, 0)
+	require.Nil(t, err)
+
+	rec := api.do("POST", path, token, CloseAccountRequest{SweepTo: bob.Number})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "unverified accounts can't sweep more than the KYC limit")
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeKYCRequired))
+
+	_, err = api.store.Withdraw(ctx, alice.Number, 120000, 0)
+	require.Nil(t, err)
+
+	require.Nil(t, api.store.UpdateAccountLimits(ctx, alice.ID, AccountLimits{DualApprovalAmount: 10000}))
+	require.Nil(t, api.store.AddAccountOwner(ctx, &AccountOwner{AccountNumber: alice.Number, OwnerNumber: bob.Number}))
+
+	rec = api.do("POST", path, token, CloseAccountRequest{SweepTo: bob.Number})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the sweep needs the other owner")
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeApprovalRequired))
+
+	acc, err := api.store.GetAccountByNumber(ctx, int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, AccountActive, acc.Status)
+	assert.Equal(t, int64(80000), acc.Balance)
+}
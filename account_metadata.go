@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxMetadataKeys        = 50
+	maxMetadataKeyLength   = 40
+	maxMetadataValueLength = 500
+)
+
+// metadataKeyPattern is a metadata key, e.g. crm_id or segment.tier.
+var metadataKeyPattern = regexp.MustCompile(fmt.Sprintf(`^[A-Za-z0-9_.-]{1,%d}$`, maxMetadataKeyLength))
+
+// mergeMetadata applies patch to a copy of metadata the way a JSON merge
+// patch would: a null value removes its key and others set theirs.
+func mergeMetadata(metadata map[string]string, patch map[string]*string) (map[string]string, error) {
+	merged := maps.Clone(metadata)
+
+	if merged == nil {
+		merged = map[string]string{}
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = *value
+		}
+	}
+
+	if len(merged) > maxMetadataKeys {
+		return nil, validationError("an account can have at most %d metadata keys", maxMetadataKeys)
+	}
+
+	return merged, nil
+}
+
+// matchesMetadata reports whether metadata has every key of filter, with
+// the same value.
+func matchesMetadata(metadata, filter map[string]string) bool {
+	for key, value := range filter {
+		if v, ok := metadata[key]; !ok || v != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getMetadataFilterFromQueryParams reads the metadata[key]=value query
+// parameters of an account listing, or nil if there are none.
+func getMetadataFilterFromQueryParams(r *http.Request) (map[string]string, error) {
+	var filter map[string]string
+
+	for param, values := range r.URL.Query() {
+		key, ok := strings.CutPrefix(param, "metadata[")
+
+		if !ok {
+			continue
+		}
+
+		key, ok = strings.CutSuffix(key, "]")
+
+		if !ok || !metadataKeyPattern.MatchString(key) || len(values) != 1 {
+			return nil, badRequestError("invalid metadata filter %s", param)
+		}
+
+		if filter == nil {
+			filter = map[string]string{}
+		}
+
+		filter[key] = values[0]
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeMetadata(t *testing.T) {
+	value := func(s string) *string { return &s }
+	stored := map[string]string{"crm_id": "42", "segment": "retail"}
+
+	merged, err := mergeMetadata(stored, map[string]*string{"segment": nil, "tier": value("gold"), "missing": nil})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"crm_id": "42", "tier": "gold"}, merged)
+	assert.Equal(t, "retail", stored["segment"], "the stored metadata is left alone")
+
+	merged, err = mergeMetadata(nil, map[string]*string{"crm_id": value("42")})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"crm_id": "42"}, merged)
+
+	full := map[string]string{}
+
+	for i := 0; i < maxMetadataKeys; i++ {
+		full["key"+strconv.Itoa(i)] = "v"
+	}
+
+	_, err = mergeMetadata(full, map[string]*string{"key0": value("w")})
+	assert.Nil(t, err, "changing a key of a full account")
+
+	_, err = mergeMetadata(full, map[string]*string{"one_more": value("v")})
+	assert.ErrorContains(t, err, "at most 50 metadata keys")
+}
+
+func TestMatchesMetadata(t *testing.T) {
+	metadata := map[string]string{"crm_id": "42", "tier": "gold"}
+
+	assert.True(t, matchesMetadata(metadata, nil))
+	assert.True(t, matchesMetadata(metadata, map[string]string{"tier": "gold"}))
+	assert.False(t, matchesMetadata(metadata, map[string]string{"tier": "silver"}))
+	assert.False(t, matchesMetadata(metadata, map[string]string{"tier": "gold", "segment": ""}), "a missing key isn't an empty value")
+	assert.False(t, matchesMetadata(nil, map[string]string{"tier": "gold"}))
+}
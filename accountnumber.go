@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+)
+
+const (
+	defaultAccountNumberLength = 10
+	minAccountNumberLength     = 6
+	// longer numbers would not survive JSON clients that parse them as
+	// doubles
+	maxAccountNumberLength = 15
+
+	maxAccountNumberAttempts = 5
+)
+
+// errAccountNumberTaken is returned by CreateAccount when the account's
+// number is already in use.
+var errAccountNumberTaken = &HTTPError{
+	Status:  http.StatusConflict,
+	Code:    ErrorCodeConflict,
+	Message: "account number already taken",
+	Err:     ErrDuplicateAccountNumber,
+}
+
+// AccountNumberGenerator issues account numbers of a fixed number of digits
+// whose last digit is a Luhn check digit, so mistyped numbers are caught
+// before they reach the store.
+type AccountNumberGenerator struct {
+	length int
+}
+
+var defaultAccountNumbers = NewAccountNumberGenerator(defaultAccountNumberLength)
+
+func NewAccountNumberGenerator(length int) *AccountNumberGenerator {
+	return &AccountNumberGenerator{length: length}
+}
+
+// Generate returns a random number that does not start with 0.
+func (g *AccountNumberGenerator) Generate() int64 {
+	return g.generate(rand.Int63n)
+}
+
+// generate is Generate drawing from int63n, e.g. that of a seeded source.
+func (g *AccountNumberGenerator) generate(int63n func(int64) int64) int64 {
+	low := pow10(g.length - 2).Int64()
+	payload := low + int63n(9*low)
+
+	return payload*10 + luhnCheckDigit(payload)
+}
+
+// Valid reports whether number could have been issued. Numbers shorter than
+// minAccountNumberLength were assigned before check digits existed and are
+// accepted as they are.
+func (g *AccountNumberGenerator) Valid(number int64) bool {
+	switch {
+	case number <= 0:
+		return false
+	case number < pow10(minAccountNumberLength-1).Int64():
+		return true
+	case number < pow10(g.length-1).Int64() || number >= pow10(g.length).Int64():
+		return false
+	}
+
+	return luhnCheckDigit(number/10) == number%10
+}
+
+// Check fails with a validation error on field when number is not Valid.
+func (g *AccountNumberGenerator) Check(field string, number int64) error {
+	errs := FieldErrors{}
+
+	if !g.Valid(number) {
+		errs.Add(field, "is not a valid account number")
+	}
+
+	return errs.Err()
+}
+
+// luhnCheckDigit returns the digit that makes payload followed by it pass
+// the Luhn check.
+func luhnCheckDigit(payload int64) int64 {
+	var sum int64
+
+	for double := true; payload > 0; double = !double {
+		digit := payload % 10
+		payload /= 10
+
+		if double {
+			digit *= 2
+
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+	}
+
+	return (10 - sum%10) % 10
+}
+
+// createAccount stores acc under a new number from numbers, drawing another
+// one if it is already taken.
+func createAccount(ctx context.Context, store AccountRepository, numbers *AccountNumberGenerator, acc *Account) error {
+	return createAccountWith(ctx, numbers, acc, store.CreateAccount)
+}
+
+// createAccountWith is createAccount storing acc with create, e.g. along
+// with its initial deposit.
+func createAccountWith(ctx context.Context, numbers *AccountNumberGenerator, acc *Account, create func(context.Context, *Account) error) error {
+	for attempt := 1; ; attempt++ {
+		acc.Number = numbers.Generate()
+		err := create(ctx, acc)
+
+		if !errors.Is(err, ErrDuplicateAccountNumber) || attempt == maxAccountNumberAttempts {
+			return err
+		}
+	}
+}
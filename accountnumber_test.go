@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLuhnCheckDigit(t *testing.T) {
+	assert.Equal(t, int64(3), luhnCheckDigit(7992739871))
+	assert.Equal(t, int64(0), luhnCheckDigit(0))
+}
+
+func TestAccountNumberGenerator(t *testing.T) {
+	numbers := NewAccountNumberGenerator(8)
+
+	for i := 0; i < 100; i++ {
+		number := numbers.Generate()
+
+		assert.GreaterOrEqual(t, number, int64(10000000))
+		assert.Less(t, number, int64(100000000))
+		assert.True(t, numbers.Valid(number), number)
+		// a single mistyped digit is always caught
+		assert.False(t, numbers.Valid(number/10*10+(number%10+1)%10), number)
+	}
+}
+
+func TestAccountNumberValid(t *testing.T) {
+	numbers := NewAccountNumberGenerator(11)
+
+	assert.True(t, numbers.Valid(79927398713))
+	assert.False(t, numbers.Valid(79927398714))
+	assert.False(t, numbers.Valid(7992739871), "too short")
+	assert.False(t, numbers.Valid(799273987130), "too long")
+	assert.True(t, numbers.Valid(42), "issued before check digits")
+	assert.False(t, numbers.Valid(0))
+	assert.False(t, numbers.Valid(-3))
+
+	err := numbers.Check("toAccount", 79927398714)
+
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, httpErr.Status)
+	assert.Equal(t, "toAccount", httpErr.Details[0].Field)
+}
+
+// takenNumbers fails CreateAccount the first taken times.
+type takenNumbers struct {
+	AccountRepository
+	taken int
+	tried []int64
+}
+
+func (s *takenNumbers) CreateAccount(ctx context.Context, acc *Account) error {
+	s.tried = append(s.tried, acc.Number)
+
+	if len(s.tried) <= s.taken {
+		return errAccountNumberTaken
+	}
+
+	return nil
+}
+
+func TestCreateAccountRetriesTakenNumbers(t *testing.T) {
+	numbers := NewAccountNumberGenerator(10)
+
+	store := &takenNumbers{taken: 2}
+	acc := new(Account)
+	require.Nil(t, createAccount(context.Background(), store, numbers, acc))
+	assert.Len(t, store.tried, 3)
+	assert.Equal(t, store.tried[2], acc.Number)
+
+	store = &takenNumbers{taken: maxAccountNumberAttempts}
+	assert.ErrorIs(t, createAccount(context.Background(), store, numbers, new(Account)), errAccountNumberTaken)
+	assert.Len(t, store.tried, maxAccountNumberAttempts)
+}
@@ -0,0 +1,60 @@
+package main
+
+import "time"
+
+const maxAdjustmentReasonLength = 500
+
+func NewAdminApproval(action AdminAction, number, proposedBy int64) *AdminApproval {
+	return &AdminApproval{
+		Action:        action,
+		AccountNumber: number,
+		ProposedBy:    proposedBy,
+		Status:        AdminApprovalPending,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// CheckDecision validates approving or rejecting the approval by decidedBy.
+// The admin who proposed the action can withdraw it by rejecting it, but not
+// approve it.
+func (a *AdminApproval) CheckDecision(status AdminApprovalStatus, decidedBy int64) error {
+	switch {
+	case a.Status != AdminApprovalPending:
+		return conflictError("admin approval %d is %s", a.ID, a.Status)
+	case status == AdminApprovalApproved && decidedBy == a.ProposedBy:
+		return forbiddenError("admin approval %d must be approved by another admin", a.ID)
+	}
+
+	return nil
+}
+
+// Increases tells whether l lets the account go further below zero than
+// before, through a higher overdraft limit or a lower minimum balance.
+func (l AccountLimits) Increases(before AccountLimits) bool {
+	return l.OverdraftLimit > before.OverdraftLimit || l.MinimumBalance < before.MinimumBalance
+}
+
+// adjustmentDescription is the statement description of the adjustment
+// booked by a, e.g. "fee_refund: overdraft fee charged twice".
+func (a *AdminApproval) adjustmentDescription() string {
+	if a.ReasonCode == "" {
+		return a.Reason
+	}
+
+	return string(a.ReasonCode) + ": " + a.Reason
+}
+
+// checkAdjustment returns an error unless acc can be adjusted by amount. A
+// debit may not exceed the available balance, but no overdraft fee is
+// charged for it.
+func checkAdjustment(acc *Account, amount int64) error {
+	if err := acc.CheckActive(); err != nil {
+		return err
+	}
+
+	if amount < 0 && !acc.CanDebit(-amount) {
+		return insufficientFundsError()
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminApprovalCheckDecision(t *testing.T) {
+	approval := &AdminApproval{ID: 1, ProposedBy: 7, Status: AdminApprovalPending}
+
+	assert.Equal(t, http.StatusForbidden, approval.CheckDecision(AdminApprovalApproved, 7).(*HTTPError).Status)
+	assert.Nil(t, approval.CheckDecision(AdminApprovalRejected, 7), "the proposer can withdraw it")
+	assert.Nil(t, approval.CheckDecision(AdminApprovalApproved, 8))
+
+	approval.Status = AdminApprovalRejected
+	assert.Equal(t, http.StatusConflict, approval.CheckDecision(AdminApprovalApproved, 8).(*HTTPError).Status)
+}
+
+func TestAccountLimitsIncreases(t *testing.T) {
+	before := AccountLimits{OverdraftLimit: 1000, MinimumBalance: 100, OverdraftFee: 50}
+
+	assert.True(t, AccountLimits{OverdraftLimit: 2000, MinimumBalance: 100}.Increases(before))
+	assert.True(t, AccountLimits{OverdraftLimit: 1000, MinimumBalance: 0}.Increases(before))
+	assert.False(t, AccountLimits{OverdraftLimit: 500, MinimumBalance: 100, OverdraftFee: 0, DualApprovalAmount: 5000}.Increases(before))
+}
+
+func TestMemoryStoreAdjustBalance(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 42, Currency: "USD", Status: AccountActive}))
+
+	credit, err := store.AdjustBalance(ctx, 42, 500, "error_correction: missed deposit")
+	require.Nil(t, err)
+	assert.Equal(t, TransactionAdjustment, credit.Type)
+	assert.Equal(t, int64(500), credit.Balance)
+
+	transactions, err := store.GetTransactions(ctx, 42, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, "error_correction: missed deposit", transactions[0].Description)
+
+	_, err = store.AdjustBalance(ctx, 42, -501, "")
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	debit, err := store.AdjustBalance(ctx, 42, -500, "")
+	require.Nil(t, err)
+	assert.Zero(t, debit.Balance)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestAPIAdminApprovals(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	maker := api.createAccountWithRole("Maker", "maker-pw", RoleAdmin)
+	checker := api.createAccountWithRole("Checker", "checker-pw", RoleAdmin)
+	makerToken := api.login(maker, "maker-pw")
+	checkerToken := api.login(checker, "checker-pw")
+	path := "/admin/account/" + strconv.Itoa(alice.ID)
+
+	decide := func(approval *AdminApproval, decision, token string) *AdminApproval {
+		rec := api.do("POST", "/admin/approvals/"+strconv.Itoa(approval.ID)+"/"+decision, token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		decided := new(AdminApproval)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(decided))
+
+		return decided
+	}
+
+	propose := func(method, path string, body any) *AdminApproval {
+		rec := api.do(method, path, makerToken, body)
+		require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+		approval := new(AdminApproval)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(approval))
+		assert.Equal(t, AdminApprovalPending, approval.Status)
+		assert.Equal(t, maker.Number, approval.ProposedBy)
+
+		return approval
+	}
+
+	balance := func() int64 {
+		acc, err := api.store.GetAccountById(context.Background(), alice.ID)
+		require.Nil(t, err)
+
+		return acc.Balance
+	}
+
+	rec := api.do("POST", path+"/adjustment", makerToken, BalanceAdjustmentRequest{Amount: 700, ReasonCode: AdjustmentErrorCorrection})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "a reason is required")
+
+	rec = api.do("POST", path+"/adjustment", makerToken, BalanceAdjustmentRequest{Amount: 700, Reason: "missed deposit"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "a reason code is required")
+
+	rec = api.do("POST", path+"/adjustment", makerToken, BalanceAdjustmentRequest{Amount: -1, ReasonCode: AdjustmentFeeRefund, Reason: "fee refund"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "the account can't cover the debit")
+
+	adjustment := propose("POST", path+"/adjustment", BalanceAdjustmentRequest{Amount: 700, ReasonCode: AdjustmentErrorCorrection, Reason: "missed deposit"})
+	assert.Zero(t, balance(), "nothing is booked before the approval")
+
+	rec = api.do("GET", "/admin/approvals?status=pending", checkerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	pending := []*AdminApproval{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&pending))
+	require.Len(t, pending, 1)
+	assert.Equal(t, int64(700), pending[0].Amount)
+
+	rec = api.do("POST", "/admin/approvals/"+strconv.Itoa(adjustment.ID)+"/approve", makerToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the maker can't approve their own action")
+
+	approved := decide(adjustment, "approve", checkerToken)
+	assert.Equal(t, AdminApprovalApproved, approved.Status)
+	assert.Equal(t, &checker.Number, approved.DecidedBy)
+	require.NotNil(t, approved.TransactionID)
+	assert.Equal(t, int64(700), balance())
+
+	transactions, err := api.store.GetTransactions(context.Background(), alice.Number, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, "error_correction: missed deposit", transactions[0].Description, "statements say why the balance was adjusted")
+
+	rec = api.do("POST", "/admin/approvals/"+strconv.Itoa(adjustment.ID)+"/approve", checkerToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "approvals are carried out once")
+
+	rec = api.do("PUT", path+"/limits", makerToken, AccountLimits{OverdraftFee: 25})
+	assert.Equal(t, http.StatusOK, rec.Code, "tightening the limits needs no approval")
+
+	limits := propose("PUT", path+"/limits", AccountLimits{OverdraftLimit: 5000, OverdraftFee: 25})
+	rejected := decide(limits, "reject", makerToken)
+	assert.Equal(t, AdminApprovalRejected, rejected.Status)
+
+	acc, err := api.store.GetAccountById(context.Background(), alice.ID)
+	require.Nil(t, err)
+	assert.Zero(t, acc.OverdraftLimit)
+
+	// the adjustment fails when the account can no longer cover it
+	debit := propose("POST", path+"/adjustment", BalanceAdjustmentRequest{Amount: -700, ReasonCode: AdjustmentErrorCorrection, Reason: "duplicate deposit"})
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/withdraw", api.tellerToken(), AmountRequest{Amount: 100})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/approvals/"+strconv.Itoa(debit.ID)+"/approve", checkerToken, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/approvals?status=failed", checkerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	failed := []*AdminApproval{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&failed))
+	require.Len(t, failed, 1)
+	assert.Equal(t, "insufficient funds", failed[0].Error)
+
+	rec = api.do("GET", "/admin/approvals", api.login(alice, "alice-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/admin/audit", checkerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	entries := []*AuditEntry{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&entries))
+
+	actions := map[AuditAction]int{}
+
+	for _, entry := range entries {
+		actions[entry.Action]++
+	}
+
+	assert.Equal(t, 3, actions[AuditAdminApprovalProposed])
+	assert.Equal(t, 2, actions[AuditAdminApprovalApproved])
+	assert.Equal(t, 1, actions[AuditAdminApprovalRejected])
+	assert.Equal(t, 1, actions[AuditBalanceAdjusted])
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	aliasCodeTTL         = 15 * time.Minute
+	maxAliasCodeAttempts = 5
+	maxAliasLength       = 254
+)
+
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	// phoneSeparators are dropped from phone numbers before they are
+	// checked, so +1 (555) 010-0000 and +15550100000 are the same alias.
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+)
+
+// parseAlias returns the type and normalized form of an email address or an
+// E.164 phone number, and false for anything else.
+func parseAlias(value string) (AliasType, string, bool) {
+	value = strings.TrimSpace(value)
+
+	if len(value) > maxAliasLength {
+		return "", "", false
+	}
+
+	if strings.Contains(value, "@") {
+		value = strings.ToLower(value)
+		return AliasEmail, value, emailPattern.MatchString(value)
+	}
+
+	value = phoneSeparators.Replace(value)
+
+	return AliasPhone, value, phonePattern.MatchString(value)
+}
+
+// NewAlias is the unverified alias value of the number account and the code
+// that verifies it. value must have passed AliasRequest.Validate.
+func NewAlias(number int64, value string, now time.Time) (*Alias, string, error) {
+	aliasType, value, _ := parseAlias(value)
+
+	code, err := newVerificationCode()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	alias := &Alias{
+		AccountNumber: number,
+		Type:          aliasType,
+		Value:         value,
+		CreatedAt:     now,
+		CodeHash:      hashToken(code),
+		CodeExpiresAt: now.Add(aliasCodeTTL),
+	}
+
+	return alias, code, nil
+}
+
+// newVerificationCode returns a random 6-digit code.
+func newVerificationCode() (string, error) {
+	n, err := crand.Int(crand.Reader, big.NewInt(1000000))
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// checkCode returns an error unless codeHash verifies the alias at now. A
+// wrong code counts as an attempt, which the caller must store.
+func (a *Alias) checkCode(codeHash string, now time.Time) error {
+	if a.VerifiedAt != nil {
+		return conflictError("alias %s is already verified", a.Value)
+	}
+
+	if a.Attempts >= maxAliasCodeAttempts || !now.Before(a.CodeExpiresAt) {
+		return conflictError("the verification code of alias %s expired, add it again for a new one", a.Value)
+	}
+
+	if codeHash != a.CodeHash {
+		a.Attempts++
+		return validationError("invalid code")
+	}
+
+	return nil
+}
+
+// resolveAlias points a validated transfer request that names an alias at
+// the account it is verified on.
+func resolveAlias(ctx context.Context, store AliasRepository, from int64, req *TransferRequest) error {
+	if req.ToAlias == "" {
+		return nil
+	}
+
+	_, value, _ := parseAlias(req.ToAlias)
+
+	alias, err := store.ResolveAlias(ctx, value)
+
+	if err != nil {
+		return err
+	}
+
+	if alias.AccountNumber == from {
+		errs := FieldErrors{}
+		errs.Add("toAlias", "must not be an alias of the sending account")
+		return errs.Err()
+	}
+
+	req.ToAccount = int(alias.AccountNumber)
+
+	return nil
+}
+
+// NewAliasResolution shows the holder of acc, which alias points to, by
+// first name and last initial.
+func NewAliasResolution(alias *Alias, acc *Account) *AliasResolution {
+	name := acc.FirstName
+
+	if acc.LastName != "" {
+		initial, _ := utf8.DecodeRuneInString(acc.LastName)
+		name += " " + string(initial) + "."
+	}
+
+	return &AliasResolution{
+		Type:          alias.Type,
+		Value:         alias.Value,
+		AccountNumber: acc.Number,
+		Name:          name,
+		Currency:      acc.Currency,
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAlias(t *testing.T) {
+	for value, want := range map[string][2]string{
+		" Alice@Example.com ": {"email", "alice@example.com"},
+		"+1 (555) 010-0000":   {"phone", "+15550100000"},
+		"+44 20 7946 0000":    {"phone", "+442079460000"},
+	} {
+		aliasType, normalized, ok := parseAlias(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, AliasType(want[0]), aliasType, value)
+		assert.Equal(t, want[1], normalized, value)
+	}
+
+	for _, value := range []string{"", "alice", "alice@example", "5550100000", "+0555010000", "+12", strings.Repeat("a", 250) + "@example.com"} {
+		_, _, ok := parseAlias(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestTransferRequestValidateAlias(t *testing.T) {
+	assert.Nil(t, (&TransferRequest{ToAlias: "bob@example.com", Amount: 1}).Validate())
+	assert.NotNil(t, (&TransferRequest{ToAlias: "bob", Amount: 1}).Validate())
+	assert.NotNil(t, (&TransferRequest{ToAlias: "bob@example.com", ToAccount: 7, Amount: 1}).Validate())
+	assert.NotNil(t, (&TransferRequest{ToAlias: "bob@example.com", BeneficiaryID: 7, Amount: 1}).Validate())
+}
+
+func TestMemoryStoreAliases(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	add := func(number int64, value string) (*Alias, string) {
+		alias, code, err := NewAlias(number, value, now)
+		require.Nil(t, err)
+		require.Len(t, code, 6)
+		require.Nil(t, store.CreateAlias(ctx, alias))
+
+		return alias, code
+	}
+
+	alias, _ := add(42, "alice@example.com")
+
+	// adding it again replaces the code
+	again, code := add(42, "Alice@example.com")
+	assert.Equal(t, alias.ID, again.ID)
+
+	_, err := store.VerifyAlias(ctx, alias.ID, 43, hashToken(code), now)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status, "aliases are only found through their own account")
+
+	_, err = store.ResolveAlias(ctx, "alice@example.com")
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status, "unverified aliases don't resolve")
+
+	other, otherCode := add(43, "alice@example.com")
+
+	verified, err := store.VerifyAlias(ctx, alias.ID, 42, hashToken(code), now)
+	require.Nil(t, err)
+	assert.NotNil(t, verified.VerifiedAt)
+
+	resolved, err := store.ResolveAlias(ctx, "alice@example.com")
+	require.Nil(t, err)
+	assert.Equal(t, int64(42), resolved.AccountNumber)
+
+	_, err = store.VerifyAlias(ctx, other.ID, 43, hashToken(otherCode), now)
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status, "a value is verified on one account at a time")
+
+	err = store.CreateAlias(ctx, &Alias{AccountNumber: 44, Type: AliasEmail, Value: "alice@example.com"})
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status)
+
+	phone, phoneCode := add(42, "+15550100000")
+
+	for i := 0; i < maxAliasCodeAttempts; i++ {
+		_, err = store.VerifyAlias(ctx, phone.ID, 42, hashToken("wrong"), now)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.(*HTTPError).Status)
+	}
+
+	_, err = store.VerifyAlias(ctx, phone.ID, 42, hashToken(phoneCode), now)
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status, "the code is used up after too many attempts")
+
+	_, phoneCode = add(42, "+15550100000")
+	_, err = store.VerifyAlias(ctx, phone.ID, 42, hashToken(phoneCode), now.Add(aliasCodeTTL))
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status, "codes expire")
+
+	require.Nil(t, store.DeleteAlias(ctx, alias.ID, 42))
+
+	_, err = store.ResolveAlias(ctx, "alice@example.com")
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+
+	aliases, err := store.GetAliases(ctx, 42)
+	require.Nil(t, err)
+	assert.Len(t, aliases, 1)
+}
+
+func TestAPIAliases(t *testing.T) {
+	api := newTestAPI(t)
+	notifier := new(recordingNotifier)
+	api.server.notifier = notifier
+
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	aliceToken := api.login(alice, "alice-pw")
+	bobToken := api.login(bob, "bob-pw")
+	path := "/account/" + strconv.Itoa(bob.ID) + "/aliases"
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path, bobToken, AliasRequest{Value: "not an alias"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path, bobToken, AliasRequest{Value: "+1 555 010 0000"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	alias := new(Alias)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(alias))
+	assert.Equal(t, AliasPhone, alias.Type)
+	assert.NotContains(t, rec.Body.String(), "code")
+
+	require.Len(t, notifier.notifications, 1)
+	n := notifier.notifications[0]
+	assert.Equal(t, "+15550100000", n.To)
+	code := n.Body[strings.LastIndex(n.Body, " ")+1:]
+
+	transfer := TransferRequest{ToAlias: "+15550100000", Amount: 300}
+
+	rec = api.do("POST", "/transfer", aliceToken, transfer)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "unverified aliases can't be paid")
+
+	rec = api.do("POST", path+"/"+strconv.Itoa(alias.ID)+"/verify", aliceToken, AliasVerifyRequest{Code: code})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", path+"/"+strconv.Itoa(alias.ID)+"/verify", bobToken, AliasVerifyRequest{Code: code})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/aliases/resolve?alias="+url.QueryEscape("+1 555 010 0000"), "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("GET", "/aliases/resolve?alias="+url.QueryEscape("+1 555 010 0000"), aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	resolution := new(AliasResolution)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(resolution))
+	assert.Equal(t, bob.Number, resolution.AccountNumber)
+	assert.Equal(t, "Bob T.", resolution.Name)
+
+	rec = api.do("POST", "/transfer", aliceToken, transfer)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", bobToken, transfer)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "an account can't pay its own alias")
+
+	acc, err := api.store.GetAccountByNumber(context.Background(), int(bob.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), acc.Balance)
+
+	rec = api.do("DELETE", path+"/"+strconv.Itoa(alias.ID), bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path, bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, "[]", rec.Body.String())
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AMLRule is the anti-money laundering rule that raised a flag.
+type AMLRule string
+
+const (
+	// AMLRuleThreshold flags single entries of at least the threshold.
+	AMLRuleThreshold AMLRule = "threshold"
+	// AMLRuleStructuring flags entries kept under the threshold that add
+	// up to it within the structuring window, as when a large deposit is
+	// split up to avoid being reported.
+	AMLRuleStructuring AMLRule = "structuring"
+)
+
+// AMLFlagStatus is where the investigation of a flag stands. Flags start
+// open; reported and dismissed are final.
+type AMLFlagStatus string
+
+const (
+	AMLFlagOpen          AMLFlagStatus = "open"
+	AMLFlagInvestigating AMLFlagStatus = "investigating"
+	AMLFlagReported      AMLFlagStatus = "reported"
+	AMLFlagDismissed     AMLFlagStatus = "dismissed"
+)
+
+// amlMonitoredTypes are the ledger entries the rules look at: money paid in
+// or out by the holder, rather than booked by the bank.
+var amlMonitoredTypes = []TransactionType{
+	TransactionDeposit,
+	TransactionWithdrawal,
+	TransactionTransferIn,
+	TransactionTransferOut,
+	TransactionExternalOut,
+}
+
+// AMLFlag is a case of suspicious activity on an account for compliance
+// officers to investigate, and to report to the authorities if need be.
+// TransactionID is the entry that raised it, and TransactionIDs all the
+// entries it covers, whose absolute amounts add up to Amount.
+type AMLFlag struct {
+	ID             int           `json:"id"`
+	AccountNumber  int64         `json:"accountNumber"`
+	Rule           AMLRule       `json:"rule"`
+	TransactionID  int           `json:"transactionId"`
+	TransactionIDs []int         `json:"transactionIds"`
+	Amount         int64         `json:"amount"`
+	Currency       string        `json:"currency"`
+	Status         AMLFlagStatus `json:"status"`
+	ReviewedBy     *int64        `json:"reviewedBy,omitempty"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+	// Notes are only loaded with a single flag.
+	Notes []*AMLNote `json:"notes,omitempty"`
+}
+
+// AMLNote is a compliance officer's annotation of a flag.
+type AMLNote struct {
+	ID        int       `json:"id"`
+	FlagID    int       `json:"flagId"`
+	Author    int64     `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AMLNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// AMLFlagFilter selects the flags to list. From and To bound CreatedAt to
+// [From, To); zero values match everything, and a Limit of 0 lists them
+// all.
+type AMLFlagFilter struct {
+	AccountNumber int64
+	Rule          AMLRule
+	Status        AMLFlagStatus
+	From          time.Time
+	To            time.Time
+	Limit         int
+	Offset        int
+}
+
+// Matches reports whether the filter selects flag.
+func (f *AMLFlagFilter) Matches(flag *AMLFlag) bool {
+	return (f.AccountNumber == 0 || flag.AccountNumber == f.AccountNumber) &&
+		(f.Rule == "" || flag.Rule == f.Rule) &&
+		(f.Status == "" || flag.Status == f.Status) &&
+		(f.From.IsZero() || !flag.CreatedAt.Before(f.From)) &&
+		(f.To.IsZero() || flag.CreatedAt.Before(f.To))
+}
+
+// CheckTransition validates moving the flag to status: open flags can be
+// investigated, and open or investigated ones reported or dismissed.
+func (f *AMLFlag) CheckTransition(status AMLFlagStatus) error {
+	if f.Status == AMLFlagReported || f.Status == AMLFlagDismissed || f.Status == status {
+		return conflictError("AML flag %d is %s", f.ID, f.Status)
+	}
+
+	if status == AMLFlagInvestigating && f.Status != AMLFlagOpen {
+		return conflictError("AML flag %d is %s", f.ID, f.Status)
+	}
+
+	return nil
+}
+
+// AMLMonitor flags the suspicious entries of an account whenever money is
+// paid in or out of it. It only flags; the money has moved already.
+type AMLMonitor struct {
+	store Storage
+	// threshold is the smallest entry flagged on its own, 0 turns the
+	// monitor off.
+	threshold         int64
+	structuringCount  int
+	structuringWindow time.Duration
+}
+
+func NewAMLMonitor(cfg *Config, store Storage) *AMLMonitor {
+	return &AMLMonitor{
+		store:             store,
+		threshold:         cfg.AMLThreshold,
+		structuringCount:  cfg.AMLStructuringCount,
+		structuringWindow: cfg.AMLStructuringWindow,
+	}
+}
+
+// Publish checks the account of every transaction.created and
+// transfer.completed event. A failure is logged, the money has moved.
+func (m *AMLMonitor) Publish(ctx context.Context, event *Event) {
+	if m.threshold == 0 || (event.Type != EventTransactionCreated && event.Type != EventTransferCompleted) {
+		return
+	}
+
+	if err := m.Check(ctx, event.AccountNumber, event.CreatedAt); err != nil {
+		slog.ErrorContext(ctx, "checking account for AML flags", "account", event.AccountNumber, "error", err)
+	}
+}
+
+// Check flags the entries of the number account in the structuring window
+// up to at: each one of at least the threshold, and the ones under it in
+// the direction of the newest entry if there are enough of them to add up
+// to the threshold. Entries are only flagged once by the threshold rule,
+// and an account at most once per window by the structuring rule.
+func (m *AMLMonitor) Check(ctx context.Context, number int64, at time.Time) error {
+	since := at.Add(-m.structuringWindow)
+	entries, err := m.store.GetTransactionsBetween(ctx, number, since, at.Add(time.Second))
+
+	if err != nil {
+		return err
+	}
+
+	entries = slices.DeleteFunc(entries, func(t *Transaction) bool { return !slices.Contains(amlMonitoredTypes, t.Type) })
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	account, err := m.store.GetAccountByNumber(ctx, int(number))
+
+	if err != nil {
+		return err
+	}
+
+	var structured []*Transaction
+	newest := entries[len(entries)-1]
+
+	for _, t := range entries {
+		if abs(t.Amount) >= m.threshold {
+			if err := m.flag(ctx, account, AMLRuleThreshold, []*Transaction{t}, at); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if (t.Amount > 0) == (newest.Amount > 0) {
+			structured = append(structured, t)
+		}
+	}
+
+	var total int64
+
+	for _, t := range structured {
+		total += abs(t.Amount)
+	}
+
+	if len(structured) < m.structuringCount || total < m.threshold || abs(newest.Amount) >= m.threshold {
+		return nil
+	}
+
+	flagged, err := m.store.GetAMLFlags(ctx, &AMLFlagFilter{AccountNumber: number, Rule: AMLRuleStructuring, From: since, Limit: 1})
+
+	if err != nil || len(flagged) > 0 {
+		return err
+	}
+
+	return m.flag(ctx, account, AMLRuleStructuring, structured, at)
+}
+
+// flag raises a flag of rule on account for entries, the last of which
+// raised it. Entries already flagged by rule are left alone.
+func (m *AMLMonitor) flag(ctx context.Context, account *Account, rule AMLRule, entries []*Transaction, at time.Time) error {
+	flag := &AMLFlag{
+		AccountNumber: account.Number,
+		Rule:          rule,
+		TransactionID: entries[len(entries)-1].ID,
+		Currency:      account.Currency,
+		Status:        AMLFlagOpen,
+		CreatedAt:     at,
+		UpdatedAt:     at,
+	}
+
+	for _, t := range entries {
+		flag.TransactionIDs = append(flag.TransactionIDs, t.ID)
+		flag.Amount += abs(t.Amount)
+	}
+
+	err := m.store.CreateAMLFlag(ctx, flag)
+
+	if httpErr, ok := asHTTPError(err); ok && httpErr.Status == http.StatusConflict {
+		return nil
+	}
+
+	if err == nil {
+		amlFlagsTotal.WithLabelValues(string(rule)).Inc()
+		slog.InfoContext(ctx, "AML flag raised", "rule", rule, "account", account.Number, "amount", flag.Amount, "transactions", flag.TransactionIDs)
+	}
+
+	return err
+}
+
+func abs(amount int64) int64 {
+	if amount < 0 {
+		return -amount
+	}
+
+	return amount
+}
+
+// writeAMLFlagsCSV writes flags as a CSV report for filing SARs, one row per
+// flag with the account holder from accounts, by number, and the notes
+// joined.
+func writeAMLFlagsCSV(w io.Writer, flags []*AMLFlag, accounts map[int64]*Account) error {
+	cw := csv.NewWriter(w)
+
+	records := [][]string{
+		{"id", "created", "account", "holder", "rule", "status", "amount", "currency", "transactions", "notes"},
+	}
+
+	for _, f := range flags {
+		holder := ""
+
+		if acc := accounts[f.AccountNumber]; acc != nil {
+			holder = acc.FirstName + " " + acc.LastName
+		}
+
+		ids := make([]string, len(f.TransactionIDs))
+
+		for i, id := range f.TransactionIDs {
+			ids[i] = strconv.Itoa(id)
+		}
+
+		notes := make([]string, len(f.Notes))
+
+		for i, note := range f.Notes {
+			notes[i] = note.CreatedAt.Format(time.RFC3339) + " " + strconv.FormatInt(note.Author, 10) + ": " + note.Body
+		}
+
+		records = append(records, []string{
+			strconv.Itoa(f.ID),
+			f.CreatedAt.Format(time.RFC3339),
+			strconv.FormatInt(f.AccountNumber, 10),
+			holder,
+			string(f.Rule),
+			string(f.Status),
+			formatAmount(f.Amount, f.Currency),
+			f.Currency,
+			strings.Join(ids, " "),
+			strings.Join(notes, "\n"),
+		})
+	}
+
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
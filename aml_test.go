@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAMLMonitorCheck(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	acc, err := NewAccount("Alice", "Test", "alice-pw")
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	cfg := testConfig()
+	cfg.AMLThreshold = 10000
+	monitor := NewAMLMonitor(cfg, store)
+
+	deposit := func(amount int64) *Transaction {
+		transaction, err := store.Deposit(ctx, acc.Number, amount, 0)
+		require.Nil(t, err)
+		require.Nil(t, monitor.Check(ctx, acc.Number, time.Now().UTC()))
+
+		return transaction
+	}
+
+	flags := func(rule AMLRule) []*AMLFlag {
+		flags, err := store.GetAMLFlags(ctx, &AMLFlagFilter{Rule: rule})
+		require.Nil(t, err)
+
+		return flags
+	}
+
+	large := deposit(10000)
+	require.Len(t, flags(AMLRuleThreshold), 1)
+	assert.Equal(t, large.ID, flags(AMLRuleThreshold)[0].TransactionID)
+
+	require.Nil(t, monitor.Check(ctx, acc.Number, time.Now().UTC()))
+	assert.Len(t, flags(AMLRuleThreshold), 1, "entries are only flagged once")
+
+	first := deposit(4000)
+	second := deposit(3000)
+	assert.Empty(t, flags(AMLRuleStructuring), "two entries under the threshold")
+
+	third := deposit(3500)
+	structured := flags(AMLRuleStructuring)
+	require.Len(t, structured, 1)
+	assert.Equal(t, []int{first.ID, second.ID, third.ID}, structured[0].TransactionIDs)
+	assert.Equal(t, int64(10500), structured[0].Amount)
+	assert.Equal(t, AMLFlagOpen, structured[0].Status)
+
+	deposit(3000)
+	assert.Len(t, flags(AMLRuleStructuring), 1, "an account is flagged once per window")
+}
+
+func TestAMLFlagCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to AMLFlagStatus
+		ok       bool
+	}{
+		{AMLFlagOpen, AMLFlagInvestigating, true},
+		{AMLFlagOpen, AMLFlagReported, true},
+		{AMLFlagInvestigating, AMLFlagDismissed, true},
+		{AMLFlagInvestigating, AMLFlagInvestigating, false},
+		{AMLFlagReported, AMLFlagDismissed, false},
+		{AMLFlagDismissed, AMLFlagInvestigating, false},
+	}
+
+	for _, tt := range tests {
+		flag := &AMLFlag{ID: 1, Status: tt.from}
+		err := flag.CheckTransition(tt.to)
+
+		assert.Equal(t, tt.ok, err == nil, "%s to %s", tt.from, tt.to)
+	}
+}
+
+func TestAMLFlags(t *testing.T) {
+	cfg := testConfig()
+	cfg.AMLThreshold = 10000
+	api := newTestAPIWithConfig(t, cfg)
+	alice := api.createAccount("Alice", "alice-pw")
+	officer := api.createAccountWithRole("Officer", "officer-pw", RoleCompliance)
+	token := api.login(alice, "alice-pw")
+	officerToken := api.login(officer, "officer-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 20000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/compliance/aml-flags", token, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "customers can't review flags")
+
+	rec = api.do("GET", "/compliance/aml-flags?status=open&rule=threshold", officerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	flags := []*AMLFlag{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&flags))
+	require.Len(t, flags, 1)
+	assert.Equal(t, alice.Number, flags[0].AccountNumber)
+	assert.Equal(t, int64(20000), flags[0].Amount)
+
+	path := "/compliance/aml-flags/" + strconv.Itoa(flags[0].ID)
+
+	rec = api.do("POST", path+"/investigate", officerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/investigate", officerToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", path+"/notes", officerToken, AMLNoteRequest{Body: "cash from a car sale, no papers"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/report", officerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/dismiss", officerToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "reported flags are closed")
+
+	rec = api.do("GET", path, officerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	flag := new(AMLFlag)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(flag))
+	assert.Equal(t, AMLFlagReported, flag.Status)
+	assert.Equal(t, officer.Number, *flag.ReviewedBy)
+	require.Len(t, flag.Notes, 1)
+
+	rec = api.do("GET", "/compliance/aml-flags/export?status=reported", officerToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "Alice Test")
+	assert.Contains(t, rec.Body.String(), "cash from a car sale, no papers")
+
+	rec = api.do("GET", "/compliance/aml-flags?status=closed", officerToken, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, AuditAMLReported, entries[0].Action)
+}
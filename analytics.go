@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	analyticsMonthLayout  = "2006-01"
+	uncategorizedCategory = "uncategorized"
+
+	defaultAnalyticsMonths = 6
+	maxAnalyticsMonths     = 24
+)
+
+// NewSpendingAnalytics lays the totals of the months [from, to) out per
+// category, with a zero total for every month a category has no entries.
+// Categories that moved the most money out of the account come first.
+func NewSpendingAnalytics(account *Account, from, to time.Time, totals []*CategoryTotal) *SpendingAnalytics {
+	analytics := &SpendingAnalytics{
+		AccountNumber: account.Number,
+		Currency:      account.Currency,
+		From:          from.Format(analyticsMonthLayout),
+		To:            to.AddDate(0, -1, 0).Format(analyticsMonthLayout),
+		Categories:    []*CategoryAnalytics{},
+	}
+
+	byCategory := map[string]*CategoryAnalytics{}
+
+	for _, total := range totals {
+		category, ok := byCategory[total.Category]
+
+		if !ok {
+			category = &CategoryAnalytics{Category: total.Category, Months: []*MonthlyTotal{}}
+
+			for month := from; month.Before(to); month = month.AddDate(0, 1, 0) {
+				category.Months = append(category.Months, &MonthlyTotal{Month: month.Format(analyticsMonthLayout)})
+			}
+
+			byCategory[total.Category] = category
+			analytics.Categories = append(analytics.Categories, category)
+		}
+
+		month := category.Months[monthsBetween(from, total.Month)]
+		month.Credits += total.Credits
+		month.Debits += total.Debits
+		month.Count += total.Count
+		category.TotalCredits += total.Credits
+		category.TotalDebits += total.Debits
+	}
+
+	for _, category := range analytics.Categories {
+		category.Change, category.Trend = monthlyTrend(category.Months)
+	}
+
+	sort.Slice(analytics.Categories, func(i, j int) bool {
+		a, b := analytics.Categories[i], analytics.Categories[j]
+
+		if a.TotalDebits != b.TotalDebits {
+			return a.TotalDebits > b.TotalDebits
+		}
+
+		return a.Category < b.Category
+	})
+
+	return analytics
+}
+
+// monthlyTrend compares the money moved, in and out, in the last two months.
+func monthlyTrend(months []*MonthlyTotal) (int64, Trend) {
+	if len(months) < 2 {
+		return 0, TrendFlat
+	}
+
+	last, previous := months[len(months)-1], months[len(months)-2]
+	change := (last.Credits + last.Debits) - (previous.Credits + previous.Debits)
+
+	switch {
+	case change > 0:
+		return change, TrendUp
+	case change < 0:
+		return change, TrendDown
+	}
+
+	return 0, TrendFlat
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// localize adds the totals of every category and month written for locale.
+func (a *SpendingAnalytics) localize(locale *Locale) {
+	for _, category := range a.Categories {
+		category.Display = map[string]string{
+			"totalCredits": locale.FormatAmount(category.TotalCredits, a.Currency),
+			"totalDebits":  locale.FormatAmount(category.TotalDebits, a.Currency),
+			"change":       locale.FormatAmount(category.Change, a.Currency),
+		}
+
+		for _, month := range category.Months {
+			month.Display = map[string]string{
+				"credits": locale.FormatAmount(month.Credits, a.Currency),
+				"debits":  locale.FormatAmount(month.Debits, a.Currency),
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSpendingAnalytics(t *testing.T) {
+	account := &Account{Number: 1234, Currency: "USD"}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	analytics := NewSpendingAnalytics(account, from, to, []*CategoryTotal{
+		{Category: "groceries", Month: from, Debits: 300, Count: 3},
+		{Category: "groceries", Month: from.AddDate(0, 2, 0), Debits: 100, Count: 1},
+		{Category: "rent", Month: from, Debits: 1000, Count: 1},
+		{Category: "rent", Month: from.AddDate(0, 1, 0), Debits: 1000, Count: 1},
+		{Category: "rent", Month: from.AddDate(0, 2, 0), Debits: 1000, Count: 1},
+	})
+
+	assert.Equal(t, "2026-01", analytics.From)
+	assert.Equal(t, "2026-03", analytics.To)
+	require.Len(t, analytics.Categories, 2)
+
+	rent, groceries := analytics.Categories[0], analytics.Categories[1]
+
+	assert.Equal(t, "rent", rent.Category)
+	assert.Equal(t, int64(3000), rent.TotalDebits)
+	assert.Equal(t, TrendFlat, rent.Trend)
+
+	require.Len(t, groceries.Months, 3)
+	assert.Equal(t, "2026-02", groceries.Months[1].Month)
+	assert.Equal(t, int64(0), groceries.Months[1].Debits)
+	assert.Equal(t, int64(100), groceries.Change)
+	assert.Equal(t, TrendUp, groceries.Trend)
+}
+
+func TestGetAnalyticsPeriodFromQueryParams(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	from, to, err := getAnalyticsPeriodFromQueryParams(httptest.NewRequest("GET", "/", nil), now)
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), to)
+
+	_, _, err = getAnalyticsPeriodFromQueryParams(httptest.NewRequest("GET", "/?from=2026-03&to=2026-02", nil), now)
+	assert.NotNil(t, err)
+
+	_, _, err = getAnalyticsPeriodFromQueryParams(httptest.NewRequest("GET", "/?from=2020-01", nil), now)
+	assert.NotNil(t, err)
+}
+
+func TestAPIAnalytics(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 5000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	salary := new(Transaction)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(salary))
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 1200})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	transactions, err := api.store.GetTransactions(context.Background(), alice.Number, 1, 0)
+	require.Nil(t, err)
+	rent := transactions[0]
+
+	category := func(id int) string {
+		return "/account/" + strconv.Itoa(alice.ID) + "/transactions/" + strconv.Itoa(id) + "/category"
+	}
+
+	rec = api.do("PUT", category(salary.ID), token, CategoryRequest{Category: "salary"})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("PUT", category(rent.ID), token, CategoryRequest{Category: "rent"})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"category":"rent"`)
+
+	rec = api.do("PUT", category(rent.ID), token, CategoryRequest{Category: "Rent!"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// bob's entry is not alice's to tag
+	bobTransactions, _ := api.store.GetTransactions(context.Background(), bob.Number, 1, 0)
+	rec = api.do("PUT", category(bobTransactions[0].ID), token, CategoryRequest{Category: "rent"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/analytics", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	analytics := new(SpendingAnalytics)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(analytics))
+	require.Len(t, analytics.Categories, 2)
+	assert.Equal(t, "rent", analytics.Categories[0].Category)
+	assert.Equal(t, int64(1200), analytics.Categories[0].TotalDebits)
+	assert.Len(t, analytics.Categories[0].Months, defaultAnalyticsMonths)
+	assert.Equal(t, TrendUp, analytics.Categories[0].Trend)
+	assert.Equal(t, "salary", analytics.Categories[1].Category)
+	assert.Equal(t, int64(5000), analytics.Categories[1].TotalCredits)
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/analytics", api.login(bob, "bob-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
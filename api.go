@@ -1,97 +1,630 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
-
-	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func writeJSON(w http.ResponseWriter, status int, v any) error {
-	w.WriteHeader(status)
 	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
 
 	return json.NewEncoder(w).Encode(v)
 }
 
 type APIFunc func(http.ResponseWriter, *http.Request) error
 
-type APIError struct {
-	Error string
-}
-
 func makeHttpHandleFunc(f APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			writeJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+			writeError(w, r, err)
 		}
 	}
 }
 
+func decodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return badRequestError("invalid request body: %s", err)
+	}
+
+	if v, ok := v.(Validator); ok {
+		return v.Validate()
+	}
+
+	return nil
+}
+
 type APIServer struct {
 	listenAddr string
 	store      Storage
+	rates      ExchangeRateProvider
+	events     EventPublisher
+	// bus is where /account/{id}/stream subscribes; events must publish to it.
+	bus     *EventBus
+	limiter RateLimiter
+	tokens  *TokenIssuer
+	// stepUpAmount is the transfer amount above which accounts with
+	// two-factor authentication must send a code, 0 to never ask.
+	stepUpAmount int64
+	holdTTL      time.Duration
+	// coolingOff and coolingOffAmount limit transfers to new beneficiaries.
+	coolingOff       time.Duration
+	coolingOffAmount int64
+	// kycTransferLimit is the largest transfer from unverified accounts.
+	kycTransferLimit int64
+	fraud            *FraudEngine
+	accountNumbers   *AccountNumberGenerator
+	// oidc is nil unless an OpenID Connect provider is configured.
+	oidc             *OIDCVerifier
+	notifier         Notifier
+	passwordResetTTL time.Duration
+	loginPolicy      LoginPolicy
+
+	cardProcessorKey     string
+	cardAuthorizationTTL time.Duration
+
+	// receipts is nil unless a receipt key is configured.
+	receipts *ReceiptSigner
+	// reconciler runs the reconciliations asked for by admins.
+	reconciler *Reconciler
+	// cors is nil unless browser origins are allowed.
+	cors        *CORSPolicy
+	maintenance *MaintenanceSwitch
+
+	// termDeposits are the products offered, and early withdrawals of term
+	// deposits cost termDepositPenaltyDays of interest.
+	termDeposits           []*TermDepositProduct
+	termDepositPenaltyDays int
+
+	// requestTimeout is the deadline of every request but untimedRoutes.
+	requestTimeout    time.Duration
+	transferQuoteTTL  time.Duration
+	paymentRequestTTL time.Duration
+
+	// verifiedEmailAmount is the largest transfer from accounts without a
+	// verified email.
+	verifiedEmailAmount int64
 }
 
-func NewAPIServer(listenAddr string, store Storage) *APIServer {
+// NewAPIServer creates the JSON API server. limiter may be nil to disable
+// rate limiting.
+func NewAPIServer(cfg *Config, store Storage, rates ExchangeRateProvider, events EventPublisher, bus *EventBus, limiter RateLimiter) *APIServer {
 	return &APIServer{
-		listenAddr: listenAddr,
-		store:      store,
+		listenAddr:       cfg.ListenAddr,
+		store:            store,
+		rates:            rates,
+		events:           events,
+		bus:              bus,
+		limiter:          limiter,
+		tokens:           NewTokenIssuer(cfg),
+		stepUpAmount:     cfg.TOTPStepUpAmount,
+		holdTTL:          cfg.HoldTTL,
+		coolingOff:       cfg.BeneficiaryCoolingOff,
+		coolingOffAmount: cfg.BeneficiaryCoolingOffAmount,
+		kycTransferLimit: cfg.KYCTransferLimit,
+		fraud:            NewFraudEngine(cfg, store),
+		accountNumbers:   NewAccountNumberGenerator(cfg.AccountNumberLength),
+		oidc:             NewOIDCVerifier(cfg),
+		notifier:         NewNotifier(cfg),
+		passwordResetTTL: cfg.PasswordResetTTL,
+		loginPolicy:      newLoginPolicy(cfg),
+
+		cardProcessorKey:     cfg.CardProcessorKey,
+		cardAuthorizationTTL: cfg.CardAuthorizationTTL,
+
+		receipts: newReceiptSigner(cfg.ReceiptKey),
+		cors:     newCORSPolicy(cfg),
+
+		maintenance: NewMaintenanceSwitch(store),
+		reconciler:  NewReconciler(cfg, store),
+
+		termDeposits:           cfg.TermDepositProducts(),
+		termDepositPenaltyDays: cfg.TermDepositPenaltyDays,
+
+		requestTimeout:    cfg.RequestTimeout,
+		transferQuoteTTL:  cfg.TransferQuoteTTL,
+		paymentRequestTTL: cfg.PaymentRequestTTL,
+
+		verifiedEmailAmount: cfg.VerifiedEmailAmount,
 	}
 }
 
-func (s *APIServer) Run() {
+const (
+	shutdownTimeout    = 15 * time.Second
+	healthCheckTimeout = 2 * time.Second
+)
+
+// routes builds the router serving every endpoint of the API.
+func (s *APIServer) routes() (http.Handler, error) {
+	doc, err := loadOpenAPI()
+
+	if err != nil {
+		return nil, err
+	}
+
+	validateRequests, err := withOpenAPIValidation(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
 	router := mux.NewRouter()
 
-	router.HandleFunc("/login", makeHttpHandleFunc(s.handleLogin))
-	router.HandleFunc("/account", makeHttpHandleFunc(s.handleAccount))
-	router.HandleFunc("/account/{id}", withJwtAuth(makeHttpHandleFunc(s.handleAccountById), s.store))
-	router.HandleFunc("/transfer", makeHttpHandleFunc(s.handleTransfer))
+	for _, middleware := range s.middleware(validateRequests) {
+		router.Use(middleware)
+	}
+
+	// mux only runs its middlewares on matched routes
+	router.NotFoundHandler = Chain{withAccessToken(s.tokens, s.store), withLogging}.ThenFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return notFoundError("route %s not found", r.URL.Path)
+	})
+
+	router.HandleFunc("/openapi.json", handleOpenAPI(doc))
+	router.Handle("/metrics", promhttp.Handler())
+	router.HandleFunc("/healthz", makeHttpHandleFunc(s.handleHealth))
+	router.HandleFunc("/readyz", makeHttpHandleFunc(s.handleReady))
+
+	idempotent := withIdempotency(s.store)
+
+	// every version serves the same handlers, only the shape of some
+	// responses differs between them
+	for _, versioned := range versionRouters(router) {
+		// logins stay open in every maintenance mode, so admins can get
+		// in to switch it off; admins keep working during maintenance,
+		// except for changes in read-only mode
+		sessions := routeGroup{router: versioned}
+		api := routeGroup{router: versioned, chain: Chain{withMaintenance(s.maintenance), withReadOnly(s.maintenance)}}
+		accounts := api.With(withJwtAuth(s.store))
+		holders := api.With(withHolderAuth(s.store))
+		operators := sessions.With(withAdminAuth(s.store))
+		admins := operators.With(withReadOnly(s.maintenance))
+		compliance := sessions.With(withComplianceAuth(s.store), withReadOnly(s.maintenance))
+
+		sessions.Handle("/login", s.handleLogin)
+		sessions.Handle("/login/oidc", s.handleOIDCLogin)
+		api.Handle("/password/forgot", s.handleForgotPassword)
+		api.Handle("/password/reset", s.handleResetPassword)
+		sessions.Handle("/token/refresh", s.handleRefreshToken)
+		api.Handle("/account", s.handleAccount, idempotent)
+		admins.Handle("/account/search", s.handleSearchAccounts)
+		api.Handle("/account/verify", s.handleVerifyPayee)
+		accounts.Handle("/account/{id}", s.handleAccountById)
+		accounts.Handle("/account/{id}/transactions", s.handleGetTransactions)
+		accounts.Handle("/account/{id}/transactions/export", s.handleExportTransactions)
+		accounts.Handle("/account/{id}/transfers", s.handleGetAccountTransfers)
+		accounts.Handle("/account/{id}/transactions/{transactionId}/category", s.handleCategorizeTransaction)
+		accounts.Handle("/account/{id}/analytics", s.handleGetAnalytics)
+		accounts.Handle("/account/{id}/events", s.handleGetAccountEvents)
+		accounts.Handle("/account/{id}/balance", s.handleGetBalanceAt)
+		accounts.Handle("/account/{id}/balance/history", s.handleGetBalanceHistory)
+		accounts.Handle("/account/{id}/stream", s.handleStream)
+		accounts.Handle("/account/{id}/statement", s.handleGetStatement)
+		accounts.Handle("/account/{id}/statement/link", s.handleCreateStatementLink)
+		accounts.Handle("/account/{id}/import", s.handleImport)
+		holders.Handle("/account/{id}/kyc", s.handleKYC)
+		holders.Handle("/account/{id}/identities", s.handleLinkIdentity)
+		holders.Handle("/account/{id}/totp", s.handleEnrollTOTP)
+		holders.Handle("/account/{id}/totp/verify", s.handleVerifyTOTP)
+		accounts.Handle("/account/{id}/owners", s.handleAccountOwners)
+		accounts.Handle("/account/{id}/owners/{ownerNumber}", s.handleRemoveAccountOwner)
+		holders.Handle("/account/{id}/joint-accounts", s.handleGetOwnedAccounts)
+		accounts.Handle("/account/{id}/approvals", s.handleGetTransferApprovals)
+		holders.Handle("/account/{id}/payment-requests", s.handleGetPaymentRequests)
+		holders.Handle("/account/{id}/payment-requests/{requestId}/accept", s.handleDecidePaymentRequest(PaymentRequestAccepted))
+		holders.Handle("/account/{id}/payment-requests/{requestId}/decline", s.handleDecidePaymentRequest(PaymentRequestDeclined))
+		accounts.Handle("/account/{id}/approvals/{approvalId}/approve", s.handleDecideTransferApproval(TransferApprovalApproved))
+		accounts.Handle("/account/{id}/approvals/{approvalId}/reject", s.handleDecideTransferApproval(TransferApprovalRejected))
+		accounts.Handle("/account/{id}/pots", s.handlePots)
+		accounts.Handle("/account/{id}/pots/progress", s.handleGetPotsProgress)
+		accounts.Handle("/account/{id}/pots/{potId}", s.handlePot)
+		accounts.Handle("/account/{id}/pots/{potId}/deposit", s.handleMovePotMoney(1))
+		accounts.Handle("/account/{id}/pots/{potId}/withdraw", s.handleMovePotMoney(-1))
+		accounts.Handle("/account/{id}/pots/{potId}/progress", s.handleGetPotProgress)
+		accounts.Handle("/account/{id}/term-deposits", s.handleTermDeposits)
+		accounts.Handle("/account/{id}/term-deposits/{depositId}", s.handleGetTermDeposit)
+		accounts.Handle("/account/{id}/term-deposits/{depositId}/withdraw", s.handleWithdrawTermDeposit)
+		accounts.Handle("/account/{id}/standing-orders", s.handleStandingOrders)
+		accounts.Handle("/account/{id}/standing-orders/{orderId}", s.handleCancelStandingOrder)
+		accounts.Handle("/account/{id}/disputes", s.handleDisputes)
+		accounts.Handle("/account/{id}/cards", s.handleCards)
+		accounts.Handle("/account/{id}/cards/{cardId}/freeze", s.handleUpdateCardStatus(CardFrozen))
+		accounts.Handle("/account/{id}/cards/{cardId}/unfreeze", s.handleUpdateCardStatus(CardActive))
+		accounts.Handle("/account/{id}/external-transfers", s.handleExternalTransfers)
+		accounts.Handle("/account/{id}/beneficiaries", s.handleBeneficiaries)
+		accounts.Handle("/account/{id}/beneficiaries/{beneficiaryId}", s.handleDeleteBeneficiary)
+		holders.Handle("/account/{id}/aliases", s.handleAliases)
+		holders.Handle("/account/{id}/aliases/{aliasId}", s.handleDeleteAlias)
+		holders.Handle("/account/{id}/aliases/{aliasId}/verify", s.handleVerifyAlias)
+		holders.Handle("/account/{id}/contact", s.handleContact)
+		holders.Handle("/account/{id}/contact/{kind}", s.handleDeleteContact)
+		holders.Handle("/account/{id}/contact/{kind}/verify", s.handleVerifyContact)
+		holders.Handle("/account/{id}/sessions", s.handleSessions)
+		holders.Handle("/account/{id}/close", s.handleCloseAccount)
+		holders.Handle("/account/{id}/sessions/{sessionId}", s.handleRevokeSession)
+		holders.Handle("/account/{id}/api-keys", s.handleAPIKeys)
+		holders.Handle("/account/{id}/api-keys/{keyId}", s.handleRevokeAPIKey)
+		holders.Handle("/account/{id}/users", s.handleBusinessUsers)
+		holders.Handle("/account/{id}/users/{userId}", s.handleBusinessUser)
+		holders.Handle("/account/{id}/notifications", s.handleGetNotifications)
+		holders.Handle("/account/{id}/notifications/preferences", s.handleNotificationPreferences)
+		accounts.Handle("/account/{id}/loans", s.handleGetLoans)
+		accounts.Handle("/account/{id}/webhooks", s.handleWebhooks)
+		accounts.Handle("/account/{id}/webhooks/{webhookId}", s.handleDeleteWebhook)
+		accounts.Handle("/account/{id}/webhooks/{webhookId}/deliveries", s.handleGetWebhookDeliveries)
+		admins.Handle("/admin/webhooks", s.handleWebhooks)
+		admins.Handle("/admin/webhooks/{webhookId}", s.handleDeleteWebhook)
+		admins.Handle("/admin/webhooks/{webhookId}/deliveries", s.handleGetWebhookDeliveries)
+		admins.Handle("/admin/account/{id}/limits", s.handleUpdateAccountLimits)
+		admins.Handle("/admin/account/{id}/unlock", s.handleUnlockAccount)
+		admins.Handle("/admin/account/{id}/freeze", s.handleUpdateAccountStatus(AccountFrozen))
+		admins.Handle("/admin/account/{id}/unfreeze", s.handleUpdateAccountStatus(AccountActive))
+		admins.Handle("/admin/account/{id}/close", s.handleUpdateAccountStatus(AccountClosed))
+		admins.Handle("/admin/account/{id}/adjustment", s.handleAdjustBalance)
+		admins.Handle("/admin/account/{id}/restore", s.handleRestoreAccount)
+		admins.Handle("/admin/account/{id}/deposit", s.handleDeposit)
+		admins.Handle("/admin/account/{id}/withdraw", s.handleWithdraw)
+		admins.Handle("/admin/account/{id}/kyc/approve", s.handleReviewKYC(KYCVerified))
+		admins.Handle("/admin/account/{id}/kyc/reject", s.handleReviewKYC(KYCRejected))
+		admins.Handle("/admin/approvals", s.handleGetAdminApprovals)
+		admins.Handle("/admin/approvals/{id}/approve", s.handleDecideAdminApproval(AdminApprovalApproved))
+		admins.Handle("/admin/approvals/{id}/reject", s.handleDecideAdminApproval(AdminApprovalRejected))
+		admins.Handle("/admin/loans", s.handleCreateLoan)
+		admins.Handle("/admin/kyc", s.handleGetPendingKYC)
+		admins.Handle("/admin/fraud/reviews", s.handleGetFraudReviews)
+		admins.Handle("/admin/fraud/reviews/{id}/clear", s.handleDecideFraudReview(FraudReviewCleared))
+		admins.Handle("/admin/fraud/reviews/{id}/confirm", s.handleDecideFraudReview(FraudReviewConfirmed))
+		admins.Handle("/admin/disputes", s.handleGetDisputes)
+		admins.Handle("/admin/disputes/{id}/reverse", s.handleDecideDispute(DisputeReversed))
+		admins.Handle("/admin/disputes/{id}/deny", s.handleDecideDispute(DisputeDenied))
+		admins.Handle("/admin/external-transfers/{id}/return", s.handleReturnExternalTransfer)
+		admins.Handle("/admin/transfers", s.handleGetAdminTransfers)
+		admins.Handle("/admin/transfers/{id}/reverse", s.handleReverseTransfer)
+		admins.Handle("/admin/audit", s.handleGetAuditLog)
+		admins.Handle("/admin/events/replay", s.handleCheckProjections)
+		admins.Handle("/admin/ledger/integrity", s.handleLedgerIntegrity)
+		admins.Handle("/admin/reconciliation/reports", s.handleReconciliationReports)
+		admins.Handle("/admin/reconciliation/reports/{id}", s.handleGetReconciliationReport)
+		operators.Handle("/admin/maintenance", s.handleMaintenance)
+		admins.Handle("/admin/export", s.handleExportDataset)
+		admins.Handle("/admin/import", s.handleImportDataset)
+		admins.Handle("/admin/holidays", s.handleHolidays)
+		admins.Handle("/admin/holidays/{date}", s.handleDeleteHoliday)
+		admins.Handle("/admin/stats", s.handleGetStats)
+		admins.Handle("/admin/stats/transfers", s.handleGetTransferStats)
+		admins.Handle("/admin/stats/failed-logins", s.handleGetFailedLoginStats)
+		admins.Handle("/admin/stats/largest-accounts", s.handleGetLargestAccounts)
+		compliance.Handle("/compliance/aml-flags", s.handleGetAMLFlags)
+		compliance.Handle("/compliance/aml-flags/export", s.handleExportAMLFlags)
+		compliance.Handle("/compliance/aml-flags/{id}", s.handleGetAMLFlag)
+		compliance.Handle("/compliance/aml-flags/{id}/investigate", s.handleUpdateAMLFlag(AMLFlagInvestigating))
+		compliance.Handle("/compliance/aml-flags/{id}/report", s.handleUpdateAMLFlag(AMLFlagReported))
+		compliance.Handle("/compliance/aml-flags/{id}/dismiss", s.handleUpdateAMLFlag(AMLFlagDismissed))
+		compliance.Handle("/compliance/aml-flags/{id}/notes", s.handleAddAMLNote)
+		api.Handle("/transfer", s.handleTransfer, idempotent)
+		api.Handle("/transfer/batch", s.handleTransferBatch, idempotent)
+		api.Handle("/transfer/batch/{id}", s.handleGetTransferBatch)
+		api.Handle("/transfer/quote", s.handleTransferQuote)
+		api.Handle("/transfer/receipt/verify", s.handleVerifyTransferReceipt)
+		api.Handle("/transfer/{id}/receipt", s.handleGetTransferReceipt)
+		api.Handle("/payment-request", s.handlePaymentRequest, idempotent)
+		api.Handle("/transfer/authorize", s.handleAuthorizeTransfer, idempotent)
+		api.Handle("/transfer/{id}/capture", s.handleCaptureHold, idempotent)
+		api.Handle("/term-deposits/products", s.handleGetTermDepositProducts)
+		api.Handle("/statement/download", s.handleDownloadStatement)
+		api.Handle("/loan/{id}", s.handleGetLoan)
+		api.Handle("/loan/{id}/schedule", s.handleGetLoanSchedule)
+		api.Handle("/aliases/resolve", s.handleResolveAlias)
+		api.Handle("/cards/authorize", s.handleAuthorizeCard, withCardProcessorAuth(s.cardProcessorKey))
+		api.Handle("/transfer/schedule", s.handleScheduledTransfers)
+		api.Handle("/transfer/schedule/{id}", s.handleCancelScheduledTransfer)
+		// after the fixed /transfer paths, which it would otherwise shadow
+		api.Handle("/transfer/{id}", s.handleGetTransfer)
+	}
+
+	// outside the router so preflights of any path are answered, and
+	// unmatched routes get the headers too
+	if s.cors != nil {
+		return withCORS(s.cors)(router), nil
+	}
+
+	return router, nil
+}
+
+// middleware is the chain every matched route runs through, outermost
+// first: the caller is identified by access token or API key before the
+// request is logged, panics are recovered inside the logging so they are
+// logged as 500s, and the rate limit and request validation come last,
+// inside the metrics.
+func (s *APIServer) middleware(validateRequests Middleware) Chain {
+	chain := Chain{withAccessToken(s.tokens, s.store), withAPIKey(s.store), withBusinessUser(s.store), withLogging, withRecovery, withTimeout(s.requestTimeout), withMetrics}
+
+	if s.limiter != nil {
+		chain = chain.Use(withRateLimit(s.limiter))
+	}
+
+	return chain.Use(validateRequests)
+}
+
+// Run serves the API until ctx is cancelled, then stops accepting new
+// connections and waits up to shutdownTimeout for in-flight requests.
+func (s *APIServer) Run(ctx context.Context) error {
+	router, err := s.routes()
+
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:    s.listenAddr,
+		Handler: router,
+	}
+
+	// streams never finish by themselves
+	server.RegisterOnShutdown(s.bus.Close)
+
+	go s.tokens.Run(ctx)
+
+	// a failure leaves the API on, rather than not starting it at all
+	if err := s.maintenance.Load(ctx); err != nil {
+		slog.Error("loading the maintenance mode", "error", err)
+	}
+
+	go s.maintenance.Run(ctx)
+
+	errc := make(chan error, 1)
+
+	go func() {
+		slog.Info("JSON API server running", "addr", s.listenAddr)
+		errc <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down JSON API server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type ReadinessCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                    `json:"status"`
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+// handleHealth is the liveness probe: it answers as long as the process can
+// serve HTTP, so an unreachable database doesn't get the server restarted.
+func (s *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	return writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// handleReady is the readiness probe. It needs no token and answers 503 with
+// the failed checks until the store is reachable, its schema is up to date and
+// access tokens can be signed.
+func (s *APIServer) handleReady(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	resp := ReadinessResponse{Status: "ok", Checks: map[string]ReadinessCheck{}}
+
+	check := func(name string, err error) {
+		if err == nil {
+			resp.Checks[name] = ReadinessCheck{Status: "ok"}
+			return
+		}
+
+		slog.WarnContext(ctx, "readiness check failed", "check", name, "error", err)
+
+		resp.Status = "unavailable"
+		resp.Checks[name] = ReadinessCheck{Status: "fail", Error: err.Error()}
+	}
+
+	check("database", s.store.Ping(ctx))
+
+	pending, err := s.store.PendingMigrations(ctx)
+
+	if err == nil && len(pending) > 0 {
+		err = fmt.Errorf("%d migrations pending, the oldest is %d", len(pending), pending[0])
+	}
+
+	check("migrations", err)
+
+	check("jwtSecret", s.tokens.Ready())
 
-	log.Println("JSON API server running on port: ", s.listenAddr)
+	if resp.Status != "ok" {
+		return writeJSON(w, http.StatusServiceUnavailable, resp)
+	}
 
-	http.ListenAndServe(s.listenAddr, router)
+	return writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *APIServer) handleLogin(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != "POST" {
-		return fmt.Errorf("method not allowed")
+		return methodNotAllowedError(r.Method)
 	}
 
 	var req LoginRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	now, ip := time.Now().UTC(), clientIP(r.RemoteAddr)
+
+	if err := checkLoginThrottle(r.Context(), s.store, s.loginPolicy, req.Number, req.Username, ip, now); err != nil {
 		return err
 	}
 
-	acc, err := s.store.GetAccountByNumber(int(req.Number))
+	var resp *LoginResponse
+	var err error
+
+	if req.Username != "" {
+		resp, err = loginBusinessUser(r.Context(), s.store, s.tokens, req.Number, req.Username, req.Password, requestDevice(r))
+	} else {
+		resp, err = login(r.Context(), s.store, s.tokens, req.Number, req.Password, req.TOTPCode, requestDevice(r))
+	}
+
+	if isLoginFailure(err) {
+		recordAudit(r.Context(), s.store, newAuditEntry(r, AuditLoginFailed, req.Number, nil, nil))
+		recordLoginFailure(r.Context(), s.store, s.events, s.loginPolicy, req.Number, req.Username, ip, now, err)
+	}
 
 	if err != nil {
 		return err
 	}
 
-	if !acc.ValidPassword(req.Password) {
-		return fmt.Errorf("invalid credentials")
+	clearLoginFailures(r.Context(), s.store, s.loginPolicy, resp.Number, req.Username)
+	recordLoginNetwork(r.Context(), s.store, s.events, resp.Number, clientIP(r.RemoteAddr))
+
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// login checks the credentials, and the two-factor code if the account has
+// it enabled, and issues an access token and a refresh token for the account.
+func login(ctx context.Context, store Storage, tokens *TokenIssuer, number int64, password, totpCode string, device Device) (*LoginResponse, error) {
+	acc, err := store.GetAccountByNumber(ctx, int(number))
+
+	if isNotFound(err) {
+		return nil, unauthorizedError("invalid credentials")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !acc.ValidPassword(password) {
+		return nil, unauthorizedError("invalid credentials")
+	}
+
+	if err := checkSecondFactor(ctx, store, acc.Number, totpCode); err != nil {
+		return nil, err
+	}
+
+	return startSession(ctx, store, tokens, acc, nil, device)
+}
+
+// startSession records a session on device for an account whose holder, or
+// business user if user isn't nil, has been authenticated, and issues an
+// access token and a refresh token for it.
+func startSession(ctx context.Context, store Storage, tokens *TokenIssuer, acc *Account, user *BusinessUser, device Device) (*LoginResponse, error) {
+	session := NewSession(acc.Number, device, time.Now().UTC())
+
+	if user != nil {
+		session.UserID = user.ID
+	}
+
+	if err := store.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	refreshToken, plainRefreshToken, err := tokens.NewRefreshToken(acc.Number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken.SessionID = session.ID
+
+	if err := store.CreateRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
 	}
 
-	token, err := createJwt(acc)
+	token, err := tokens.createAccessToken(acc, user, session.ID)
 
 	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: plainRefreshToken,
+		ExpiresIn:    int64(tokens.accessTokenTTL.Seconds()),
+		Number:       acc.Number,
+		UserID:       session.UserID,
+	}, nil
+}
+
+func (s *APIServer) handleRefreshToken(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	var req RefreshTokenRequest
+
+	if err := decodeJSON(r, &req); err != nil {
 		return err
 	}
 
-	resp := LoginResponse{
-		Token:  token,
-		Number: acc.Number,
+	next, plainRefreshToken, err := s.tokens.NewRefreshToken(0)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.RotateRefreshToken(r.Context(), hashToken(req.RefreshToken), next, requestDevice(r)); err != nil {
+		return err
+	}
+
+	acc, err := s.store.GetAccountByNumber(r.Context(), int(next.AccountNumber))
+
+	if err != nil {
+		return err
+	}
+
+	var user *BusinessUser
+
+	if next.UserID != 0 {
+		if user, err = checkBusinessUser(r.Context(), s.store, acc.Number, next.UserID); err != nil {
+			return err
+		}
+	}
+
+	token, err := s.tokens.createAccessToken(acc, user, next.SessionID)
+
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("%+v \n", resp)
+	resp := LoginResponse{
+		Token:        token,
+		RefreshToken: plainRefreshToken,
+		ExpiresIn:    int64(s.tokens.accessTokenTTL.Seconds()),
+		Number:       acc.Number,
+		UserID:       next.UserID,
+	}
 
 	return writeJSON(w, http.StatusOK, resp)
 }
@@ -105,11 +638,67 @@ func (s *APIServer) handleAccount(w http.ResponseWriter, r *http.Request) error
 		return s.handleCreateAccount(w, r)
 	}
 
-	return fmt.Errorf("method not allowed %s", r.Method)
+	return methodNotAllowedError(r.Method)
 }
 
 func (s *APIServer) handleGetAccount(w http.ResponseWriter, r *http.Request) error {
-	accounts, err := s.store.GetAccounts()
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	filter := AccountFilter{
+		Limit:    limit,
+		Offset:   offset,
+		Sort:     query.Get("sort"),
+		LastName: query.Get("lastName"),
+	}
+
+	if v := query.Get("minBalance"); v != "" {
+		minBalance, err := strconv.ParseInt(v, 10, 64)
+
+		if err != nil {
+			return badRequestError("invalid minBalance %s", v)
+		}
+
+		filter.MinBalance = &minBalance
+	}
+
+	if filter.Metadata, err = getMetadataFilterFromQueryParams(r); err != nil {
+		return err
+	}
+
+	accounts, err := s.store.GetAccounts(r.Context(), filter)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, accounts)
+}
+
+// handleSearchAccounts looks accounts up by name or number for the back
+// office, best match first.
+func (s *APIServer) handleSearchAccounts(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	q, err := checkSearchQuery(r.URL.Query().Get("q"))
+
+	if err != nil {
+		return err
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	accounts, err := s.store.SearchAccounts(r.Context(), q, limit, offset)
 
 	if err != nil {
 		return err
@@ -127,185 +716,804 @@ func (s *APIServer) handleAccountById(w http.ResponseWriter, r *http.Request) er
 		return s.handleUpdateAccount(w, r)
 	}
 
+	if r.Method == "PATCH" {
+		return s.handlePatchAccount(w, r)
+	}
+
 	if r.Method == "DELETE" {
 		return s.handleDeleteAccount(w, r)
 	}
 
-	return fmt.Errorf("method not allowed %s", r.Method)
+	return methodNotAllowedError(r.Method)
 }
 
 func (s *APIServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) error {
 	createAccountRequest := new(AccountRequest)
 
-	if err := json.NewDecoder(r.Body).Decode(createAccountRequest); err != nil {
+	if err := decodeJSON(r, createAccountRequest); err != nil {
 		return err
 	}
 
-	account, err := NewAccount(createAccountRequest.FirstName, createAccountRequest.LastName, createAccountRequest.Password)
+	account, err := newAccountFromRequest(createAccountRequest)
 
 	if err != nil {
 		return err
 	}
 
-	if err := s.store.CreateAccount(account); err != nil {
-		return err
-	}
+	deposit := createAccountRequest.InitialDeposit
 
-	tokenString, err := createJwt(account)
+	switch {
+	case deposit == nil:
+		err = createAccount(r.Context(), s.store, s.accountNumbers, account)
+	default:
+		err = s.createAccountWithTransfer(r, account, deposit)
+	}
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Json Web Token: ", tokenString)
+	s.events.Publish(r.Context(), &Event{Type: EventAccountCreated, AccountNumber: account.Number, Data: account})
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAccountCreated, account.Number, nil, account))
 
 	return writeJSON(w, http.StatusOK, account)
 }
 
-func createJwt(account *Account) (string, error) {
-	claims := &jwt.MapClaims{
-		"expiresAt":     15000,
-		"accountNumber": account.Number,
-	}
+// createAccountWithTransfer creates account funded by a transfer from an
+// account the requester owns, checked like any other transfer from it. A
+// transfer that would need a second owner's approval is refused, since the
+// account can't wait for it.
+func (s *APIServer) createAccountWithTransfer(r *http.Request, account *Account, deposit *InitialDeposit) error {
+	requester, err := getAccountNumberFromToken(r)
 
-	secret := os.Getenv("JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte((secret)))
-}
+	if err != nil {
+		return err
+	}
 
-func (s *APIServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request) error {
-	id, err := getIdFromQueryParams(r)
+	fromAccount, err := s.transferSource(r.Context(), requester, &TransferRequest{FromAccount: int(deposit.FromAccount)})
 
 	if err != nil {
-		return fmt.Errorf("invalid id given %d", id)
+		return err
 	}
 
-	account, err := s.store.GetAccountById(id)
+	source, err := s.store.GetAccountByNumber(r.Context(), int(fromAccount))
 
 	if err != nil {
 		return err
 	}
 
-	accountRequest := new(AccountRequest)
+	if source.Currency != account.Currency {
+		return validationError("initial deposits can only be transferred from an account in %s", account.Currency)
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(accountRequest); err != nil {
+	if err := checkKYCTransferLimit(r.Context(), s.store, s.kycTransferLimit, fromAccount, deposit.Amount); err != nil {
 		return err
 	}
 
-	account.FirstName = accountRequest.FirstName
-	account.LastName = accountRequest.LastName
+	if err := checkVerifiedEmail(r.Context(), s.store, s.verifiedEmailAmount, fromAccount, deposit.Amount); err != nil {
+		return err
+	}
 
-	if err := s.store.UpdateAccount(account); err != nil {
+	if err := checkTransferStepUp(r.Context(), s.store, s.stepUpAmount, requester, deposit.Amount, deposit.TOTPCode); err != nil {
 		return err
 	}
 
-	return writeJSON(w, http.StatusOK, account)
-}
+	if needed, err := needsSecondOwner(r.Context(), s.store, source, nil, deposit.Amount); err != nil {
+		return err
+	} else if needed {
+		return forbiddenError("a transfer of %d from account %d needs a second owner's approval", deposit.Amount, fromAccount)
+	}
 
-func (s *APIServer) handleGetAccountById(w http.ResponseWriter, r *http.Request) error {
-	id, err := getIdFromQueryParams(r)
+	transfer := &Transfer{
+		FromAccount: fromAccount,
+		Amount:      deposit.Amount,
+		Currency:    source.Currency,
+		ToAmount:    deposit.Amount,
+		ToCurrency:  account.Currency,
+	}
+
+	review, err := s.fraud.Screen(r.Context(), &FraudCheck{Transfer: transfer, IP: clientIP(r.RemoteAddr), At: time.Now().UTC()})
 
 	if err != nil {
-		return fmt.Errorf("invalid id given %d", id)
+		return err
 	}
 
-	account, err := s.store.GetAccountById(id)
+	err = createAccountWith(r.Context(), s.accountNumbers, account, func(ctx context.Context, acc *Account) error {
+		return s.store.CreateAccountWithTransfer(ctx, acc, transfer)
+	})
 
 	if err != nil {
 		return err
 	}
 
-	return writeJSON(w, http.StatusOK, account)
+	recordFraudReview(r.Context(), s.store, review, transfer)
+	publishTransferEvents(r.Context(), s.events, s.store, transfer)
+
+	return nil
 }
 
-func (s *APIServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request) error {
-	id, err := getIdFromQueryParams(r)
+// newAccountFromRequest builds a new account, not yet stored, from a create
+// request.
+func newAccountFromRequest(req *AccountRequest) (*Account, error) {
+	account, err := NewAccount(req.FirstName, req.LastName, req.Password)
 
 	if err != nil {
-		return fmt.Errorf("invalid id given %d", id)
+		return nil, err
 	}
 
-	if err := s.store.DeleteAccount(id); err != nil {
-		return err
+	if req.Currency != "" {
+		if !validCurrency(req.Currency) {
+			return nil, validationError("unsupported currency %s", req.Currency)
+		}
+
+		account.Currency = req.Currency
 	}
 
-	return writeJSON(w, http.StatusOK, id)
+	if req.Type != "" {
+		if req.Type != AccountChecking && req.Type != AccountSavings && req.Type != AccountBusiness {
+			return nil, validationError("unsupported account type %s", req.Type)
+		}
+
+		account.Type = req.Type
+	}
+
+	return account, nil
 }
 
-func (s *APIServer) handleTransfer(w http.ResponseWriter, r *http.Request) error {
+func (s *APIServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
 
-	transferRequest := new(TransferRequest)
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(transferRequest); err != nil {
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
 		return err
 	}
 
-	defer r.Body.Close()
-
-	return writeJSON(w, http.StatusOK, transferRequest)
-}
+	accountRequest := new(AccountRequest)
 
-func validateJwt(tokenString string) (*jwt.Token, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
+	if err := decodeJSON(r, accountRequest); err != nil {
+		return err
+	}
 
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
+	version, err := getExpectedVersion(r, accountRequest.Version)
 
-		// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return []byte(jwtSecret), nil
-	})
-}
+	if err != nil {
+		return err
+	}
 
-func withJwtAuth(handleFunc http.HandlerFunc, s Storage) http.HandlerFunc {
+	account.FirstName = accountRequest.FirstName
+	account.LastName = accountRequest.LastName
+	account.Version = version
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("Calling method with jwt authentication")
+	if err := s.store.UpdateAccount(r.Context(), account); err != nil {
+		return err
+	}
 
-		tokenString := r.Header.Get("x-jwt-token")
+	w.Header().Set("ETag", accountETag(account.Version))
 
-		token, err := validateJwt(tokenString)
+	return writeJSON(w, http.StatusOK, account)
+}
 
-		if err != nil || !token.Valid {
-			writeJSON(w, http.StatusForbidden, APIError{Error: "Permission denied"})
-			return
-		}
+// handlePatchAccount merges the request's metadata into the account's.
+func (s *APIServer) handlePatchAccount(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
 
-		if !token.Valid {
-			writeJSON(w, http.StatusForbidden, APIError{Error: "Invalid token"})
-			return
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(AccountPatchRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	version, err := getExpectedVersion(r, req.Version)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.UpdateAccountMetadata(r.Context(), id, req.Metadata, version)
+
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", accountETag(account.Version))
+
+	return writeJSON(w, http.StatusOK, account)
+}
+
+func (s *APIServer) handleGetAccountById(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", accountETag(account.Version))
+
+	return writeJSON(w, http.StatusOK, account)
+}
+
+// handleDeleteAccount soft-deletes the account, so its ledger history is
+// kept and an admin can restore it.
+func (s *APIServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	before, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	after, err := s.store.DeleteAccount(r.Context(), id, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAccountDeleted, after.Number, before, after))
+
+	return writeJSON(w, http.StatusOK, id)
+}
+
+// handleUpdateAccountStatus serves the admin freeze, unfreeze and close
+// endpoints, which all move the account to status. Unfreezing only proposes
+// the change, which a second admin must approve.
+func (s *APIServer) handleUpdateAccountStatus(status AccountStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
 		}
 
-		userId, err := getIdFromQueryParams(r)
+		id, err := getIdFromQueryParams(r)
 
 		if err != nil {
-			writeJSON(w, http.StatusForbidden, APIError{Error: "Invalid token"})
-			return
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
 		}
 
-		account, err := s.GetAccountById(userId)
+		before, err := s.store.GetAccountById(r.Context(), id)
 
 		if err != nil {
-			writeJSON(w, http.StatusForbidden, APIError{Error: "Invalid token"})
-			return
+			return err
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
-		if account.Number != int64(claims["accountNumber"].(float64)) {
-			writeJSON(w, http.StatusForbidden, APIError{Error: "Invalid token"})
-			return
+		if status == AccountActive {
+			if err := before.CheckStatusChange(status); err != nil {
+				return err
+			}
+
+			approval, err := s.proposeAdminApproval(r, AdminUnfreeze, before.Number)
+
+			if err != nil {
+				return err
+			}
+
+			return s.createAdminApproval(w, r, approval)
 		}
 
+		account, err := s.store.UpdateAccountStatus(r.Context(), id, status)
+
 		if err != nil {
-			writeJSON(w, http.StatusForbidden, APIError{Error: "Invalid token"})
-			return
+			return err
+		}
+
+		recordAudit(r.Context(), s.store, newAuditEntry(r, statusAuditActions[status], account.Number, before, account))
+
+		return writeJSON(w, http.StatusOK, account)
+	}
+}
+
+func (s *APIServer) handleRestoreAccount(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.RestoreAccount(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAccountRestored, account.Number, nil, account))
+
+	return writeJSON(w, http.StatusOK, account)
+}
+
+// handleUpdateAccountLimits changes the limits of the {id} account. Changes
+// that let it go further below zero, see AccountLimits.Increases, are only
+// proposed and answered with 202 until a second admin approves them.
+func (s *APIServer) handleUpdateAccountLimits(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "PUT" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	limits := new(AccountLimits)
+
+	if err := decodeJSON(r, limits); err != nil {
+		return err
+	}
+
+	if limits.OverdraftLimit < 0 || limits.OverdraftFee < 0 || limits.DualApprovalAmount < 0 {
+		return validationError("overdraftLimit, overdraftFee and dualApprovalAmount must not be negative")
+	}
+
+	before, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if limits.Increases(before.AccountLimits) {
+		approval, err := s.proposeAdminApproval(r, AdminUpdateLimits, before.Number)
+
+		if err != nil {
+			return err
+		}
+
+		approval.Limits = limits
+
+		return s.createAdminApproval(w, r, approval)
+	}
+
+	if err := s.store.UpdateAccountLimits(r.Context(), id, *limits); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAccountLimitsChanged, account.Number, before, account))
+
+	return writeJSON(w, http.StatusOK, account)
+}
+
+// handleTransfer executes a transfer from the token's account, or from a
+// joint account it co-owns. Transfers that need a second owner's approval
+// are answered with 202 and the pending approval instead.
+func (s *APIServer) handleTransfer(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	transferRequest := new(TransferRequest)
+
+	if err := decodeJSON(r, transferRequest); err != nil {
+		return err
+	}
+
+	defer r.Body.Close()
+
+	fromAccount, err := s.transferSource(r.Context(), requester, transferRequest)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkAPIKeySource(r, requester, fromAccount); err != nil {
+		return err
+	}
+
+	if err := checkBusinessUserSource(r, requester, fromAccount); err != nil {
+		return err
+	}
+
+	if err := s.checkTransferRequest(r.Context(), requester, fromAccount, transferRequest); err != nil {
+		return err
+	}
+
+	transfer, err := s.requestedTransfer(r.Context(), fromAccount, transferRequest)
+
+	if err != nil {
+		return err
+	}
+
+	transfer.TransferReference = transferRequest.TransferReference
+
+	review, err := s.fraud.Screen(r.Context(), &FraudCheck{Transfer: transfer, IP: clientIP(r.RemoteAddr), At: time.Now().UTC()})
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByNumber(r.Context(), int(fromAccount))
+
+	if err != nil {
+		return err
+	}
+
+	user := getBusinessUserFromToken(r)
+
+	if needed, err := needsSecondOwner(r.Context(), s.store, account, user, transfer.Amount); err != nil {
+		return err
+	} else if needed {
+		approval := NewTransferApproval(transfer, requester)
+
+		if user != nil {
+			approval.RequestedByUser = user.ID
+		}
+
+		if err := s.store.CreateTransferApproval(r.Context(), approval); err != nil {
+			return err
+		}
+
+		recordFraudReview(r.Context(), s.store, review, nil)
+
+		return writeJSON(w, http.StatusAccepted, approval)
+	}
+
+	if err := s.store.Transfer(r.Context(), transfer); err != nil {
+		return err
+	}
+
+	recordFraudReview(r.Context(), s.store, review, transfer)
+	publishTransferEvents(r.Context(), s.events, s.store, transfer)
+
+	return writeJSON(w, http.StatusOK, transfer)
+}
+
+// transferSource returns the account a transfer request debits: the
+// requester's own, or the joint account named by fromAccount.
+func (s *APIServer) transferSource(ctx context.Context, requester int64, req *TransferRequest) (int64, error) {
+	if req.FromAccount == 0 || int64(req.FromAccount) == requester {
+		return requester, nil
+	}
+
+	owner, err := isAccountOwner(ctx, s.store, int64(req.FromAccount), requester)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !owner {
+		return 0, forbiddenError("permission denied")
+	}
+
+	return int64(req.FromAccount), nil
+}
+
+// requestedTransfer prices the transfer of req from fromAccount, on the
+// terms of its quote if it names one. The quote is used up either way.
+func (s *APIServer) requestedTransfer(ctx context.Context, fromAccount int64, req *TransferRequest) (*Transfer, error) {
+	if req.QuoteID == 0 {
+		return newTransfer(ctx, s.store, s.rates, fromAccount, int64(req.ToAccount), req.Amount)
+	}
+
+	quote, err := s.store.UseTransferQuote(ctx, req.QuoteID, fromAccount, time.Now().UTC())
+
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.store.GetAccountByNumber(ctx, int(fromAccount))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := quote.CheckTerms(int64(req.ToAccount), req.Amount, account); err != nil {
+		return nil, err
+	}
+
+	return quote.Transfer(), nil
+}
+
+// resolveTransferRequest validates a transfer request from fromAccount and
+// resolves its destination into ToAccount.
+func (s *APIServer) resolveTransferRequest(ctx context.Context, fromAccount int64, req *TransferRequest) error {
+	if err := req.ValidateFrom(fromAccount); err != nil {
+		return err
+	}
+
+	if req.ToAccount != 0 {
+		if err := s.accountNumbers.Check("toAccount", int64(req.ToAccount)); err != nil {
+			return err
 		}
+	}
 
-		handleFunc(w, r)
+	if err := resolveBeneficiary(ctx, s.store, fromAccount, req); err != nil {
+		return err
 	}
 
+	return resolveAlias(ctx, s.store, fromAccount, req)
+}
+
+// checkTransferRequest resolves the destination of a transfer request from
+// fromAccount and runs the checks every new transfer goes through. The
+// two-factor step-up applies to the requester, who may be a co-owner of
+// fromAccount.
+func (s *APIServer) checkTransferRequest(ctx context.Context, requester, fromAccount int64, req *TransferRequest) error {
+	if err := s.resolveTransferRequest(ctx, fromAccount, req); err != nil {
+		return err
+	}
+
+	if err := checkBeneficiaryCoolingOff(ctx, s.store, s.coolingOffAmount, fromAccount, int64(req.ToAccount), req.Amount, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	if err := checkKYCTransferLimit(ctx, s.store, s.kycTransferLimit, fromAccount, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkVerifiedEmail(ctx, s.store, s.verifiedEmailAmount, fromAccount, req.Amount); err != nil {
+		return err
+	}
+
+	return checkTransferStepUp(ctx, s.store, s.stepUpAmount, requester, req.Amount, req.TOTPCode)
+}
+
+func (s *APIServer) handleGetTransactions(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	if utf8.RuneCountInString(q) > maxMemoLength {
+		return badRequestError("q must be at most %d characters", maxMemoLength)
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if requestAPIVersion(r) != APIVersion1 {
+		if offset != 0 {
+			return badRequestError("offset is not supported, use cursor")
+		}
+
+		return s.writeTransactionPage(w, r, account.Number, q, limit)
+	}
+
+	var transactions []*Transaction
+
+	if q != "" {
+		transactions, err = s.store.SearchTransactions(r.Context(), account.Number, &TransactionSearch{Query: q, Limit: limit, Offset: offset})
+	} else {
+		transactions, err = s.store.GetTransactions(r.Context(), account.Number, limit, offset)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, transactions)
+}
+
+// writeTransactionPage writes the cursor paginated feed of /v2, only the
+// entries matching q if it is set. It fetches one entry more than asked to
+// tell whether another page follows.
+func (s *APIServer) writeTransactionPage(w http.ResponseWriter, r *http.Request, number int64, q string, limit int) error {
+	cursor, err := getTransactionCursorFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	var transactions []*Transaction
+
+	if q != "" {
+		transactions, err = s.store.SearchTransactions(r.Context(), number, &TransactionSearch{Query: q, Before: cursor, Limit: limit + 1})
+	} else {
+		transactions, err = s.store.GetTransactionsBefore(r.Context(), number, cursor, limit+1)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	page := &TransactionPage{Transactions: transactions}
+
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = newTransactionCursor(page.Transactions[limit-1]).Encode()
+		page.HasMore = true
+	}
+
+	return writeJSON(w, http.StatusOK, page)
+}
+
+// handleDeposit books cash an operator paid in at a counter. Holders can't
+// credit their own accounts, so it is for admins only, and audited.
+func (s *APIServer) handleDeposit(w http.ResponseWriter, r *http.Request) error {
+	return s.handleBalanceChange(w, r, s.store.Deposit, AuditCashDeposited)
+}
+
+func (s *APIServer) handleWithdraw(w http.ResponseWriter, r *http.Request) error {
+	return s.handleBalanceChange(w, r, s.store.Withdraw, AuditCashWithdrawn)
+}
+
+func (s *APIServer) handleBalanceChange(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, number, amount int64, version int) (*Transaction, error), action AuditAction) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	amountRequest := new(AmountRequest)
+
+	if err := decodeJSON(r, amountRequest); err != nil {
+		return err
+	}
+
+	defer r.Body.Close()
+
+	version, err := getExpectedVersion(r, amountRequest.Version)
+
+	if err != nil {
+		return err
+	}
+
+	transaction, err := apply(r.Context(), account.Number, amountRequest.Amount, version)
+
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", accountETag(transaction.accountVersion))
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, action, account.Number, nil, transaction))
+	s.events.Publish(r.Context(), &Event{Type: EventTransactionCreated, AccountNumber: account.Number, Data: transaction})
+
+	if transaction.Amount < 0 {
+		publishBalanceEvent(r.Context(), s.events, account.Number, transaction.Balance, account.Currency)
+	}
+
+	return writeJSON(w, http.StatusOK, transaction)
+}
+
+// withJwtAuth only lets the request through when the token belongs to the
+// holder or a co-owner of the account addressed by the {id} path parameter.
+func withJwtAuth(s Storage) Middleware {
+	return withAccountAuth(s, true)
+}
+
+// withHolderAuth is withJwtAuth without co-owners, for the routes managing
+// the holder's own login and identity.
+func withHolderAuth(s Storage) Middleware {
+	return withAccountAuth(s, false)
+}
+
+func withAccountAuth(s Storage, coOwners bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			number, err := getAccountNumberFromToken(r)
+
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			userId, err := getIdFromQueryParams(r)
+
+			if err != nil {
+				writeError(w, r, badRequestError("invalid id given %s", mux.Vars(r)["id"]))
+				return
+			}
+
+			account, err := s.GetAccountById(r.Context(), userId)
+
+			if err != nil {
+				writeError(w, r, forbiddenError("permission denied"))
+				return
+			}
+
+			allowed := account.Number == number
+
+			if !allowed && coOwners {
+				allowed, err = isAccountOwner(r.Context(), s, account.Number, number)
+
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+			}
+
+			if !allowed {
+				writeError(w, r, forbiddenError("permission denied"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withAdminAuth only lets the request through for tokens of accounts that
+// currently hold the admin role.
+func withAdminAuth(s Storage) Middleware {
+	return withRoleAuth(s, RoleAdmin)
+}
+
+// withComplianceAuth only lets the request through for tokens of compliance
+// officers and admins.
+func withComplianceAuth(s Storage) Middleware {
+	return withRoleAuth(s, RoleCompliance, RoleAdmin)
+}
+
+// withRoleAuth only lets the request through for tokens of accounts that
+// currently hold one of roles.
+func withRoleAuth(s Storage, roles ...Role) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			number, err := getAccountNumberFromToken(r)
+
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			account, err := s.GetAccountByNumber(r.Context(), int(number))
+
+			if err != nil || !slices.Contains(roles, account.Role) {
+				writeError(w, r, forbiddenError("permission denied"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func getIdFromQueryParams(r *http.Request) (int, error) {
@@ -318,3 +1526,35 @@ func getIdFromQueryParams(r *http.Request) (int, error) {
 
 	return id, nil
 }
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+func getPaginationFromQueryParams(r *http.Request) (int, int, error) {
+	limit, offset := defaultPageLimit, 0
+	query := r.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return 0, 0, badRequestError("invalid limit %s", v)
+		}
+
+		limit = n
+	}
+
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+
+		if err != nil || n < 0 {
+			return 0, 0, badRequestError("invalid offset %s", v)
+		}
+
+		offset = n
+	}
+
+	return limit, offset, nil
+}
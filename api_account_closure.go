@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// handleCloseAccount closes the {id} account for good: what is left of its
+// balance is swept to sweepTo, its standing orders and scheduled transfers
+// are cancelled and the final statement is returned with the closure. The
+// account is kept, closed, rather than deleted. The sweep moves money like
+// any other transfer, so it goes through the same checks first.
+func (s *APIServer) handleCloseAccount(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	req := new(CloseAccountRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	before, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	var review *FraudReview
+
+	if before.Balance > 0 && req.SweepTo != 0 {
+		if review, err = s.checkSweep(r, before, req); err != nil {
+			return err
+		}
+	}
+
+	closure, err := s.store.CloseAccount(r.Context(), before.Number, req.SweepTo)
+
+	if err != nil {
+		return err
+	}
+
+	if review != nil && closure.Sweep != nil {
+		recordFraudReview(r.Context(), s.store, review, closure.Sweep)
+	}
+
+	if closure.Sweep != nil {
+		publishTransferEvents(r.Context(), s.events, s.store, closure.Sweep)
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAccountClosed, before.Number, before, closure.Account))
+
+	closure.Statement = s.finalStatement(r.Context(), closure.Account, time.Now().UTC())
+
+	return writeJSON(w, http.StatusOK, closure)
+}
+
+// checkSweep runs the sweep of the balance of acc to req.SweepTo through the
+// checks of a transfer made by the requester: step-up, KYC limit, verified
+// email, beneficiary cooling-off, a second owner's approval and the fraud
+// screen, whose review it returns.
+func (s *APIServer) checkSweep(r *http.Request, acc *Account, req *CloseAccountRequest) (*FraudReview, error) {
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transferRequest := &TransferRequest{ToAccount: int(req.SweepTo), Amount: acc.Balance, TOTPCode: req.TOTPCode}
+
+	if err := s.checkTransferRequest(r.Context(), requester, acc.Number, transferRequest); err != nil {
+		return nil, err
+	}
+
+	if err := checkSecondOwner(r.Context(), s.store, acc.Number, acc.Balance); err != nil {
+		return nil, err
+	}
+
+	sweep := newSweepTransfer(acc, int64(transferRequest.ToAccount))
+
+	return s.fraud.Screen(r.Context(), &FraudCheck{Transfer: sweep, IP: clientIP(r.RemoteAddr), At: sweep.CreatedAt})
+}
+
+// finalStatement is the statement of the account from the start of the
+// month until it closed at closedAt. The account is closed by then, so it is
+// left out rather than failing the closure when it can't be read; it stays
+// available from /account/{id}/statement.
+func (s *APIServer) finalStatement(ctx context.Context, account *Account, closedAt time.Time) *Statement {
+	from := startOfMonth(closedAt)
+	opening, err := openingBalance(ctx, s.store, account.Number, from)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "reading the opening balance of the final statement", "account", account.Number, "error", err)
+		return nil
+	}
+
+	transactions, err := s.store.GetTransactionsBetween(ctx, account.Number, from, closedAt)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "reading the transactions of the final statement", "account", account.Number, "error", err)
+		return nil
+	}
+
+	return NewStatement(account, from, closedAt, opening, transactions)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type adminApprovalAuditSnapshot struct {
+	Status AdminApprovalStatus `json:"status"`
+}
+
+// handleAdjustBalance proposes crediting or debiting the {id} account by
+// hand. The adjustment is only booked once a second admin approves it.
+func (s *APIServer) handleAdjustBalance(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(BalanceAdjustmentRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkAdjustment(account, req.Amount); err != nil {
+		return err
+	}
+
+	approval, err := s.proposeAdminApproval(r, AdminAdjustBalance, account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	approval.Amount = req.Amount
+	approval.ReasonCode = req.ReasonCode
+	approval.Reason = req.Reason
+
+	return s.createAdminApproval(w, r, approval)
+}
+
+// proposeAdminApproval starts an approval of action on the account by the
+// admin calling r.
+func (s *APIServer) proposeAdminApproval(r *http.Request, action AdminAction, number int64) (*AdminApproval, error) {
+	proposedBy, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAdminApproval(action, number, proposedBy), nil
+}
+
+// createAdminApproval queues approval for a second admin and answers with
+// 202 and the pending approval.
+func (s *APIServer) createAdminApproval(w http.ResponseWriter, r *http.Request, approval *AdminApproval) error {
+	if err := s.store.CreateAdminApproval(r.Context(), approval); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAdminApprovalProposed, approval.AccountNumber, nil, approval))
+
+	return writeJSON(w, http.StatusAccepted, approval)
+}
+
+// handleGetAdminApprovals is the queue of the proposed admin actions, oldest
+// first, filtered by ?status=; ?status=pending lists those waiting for a
+// second admin.
+func (s *APIServer) handleGetAdminApprovals(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	status := AdminApprovalStatus(r.URL.Query().Get("status"))
+
+	switch status {
+	case "", AdminApprovalPending, AdminApprovalApproved, AdminApprovalRejected, AdminApprovalFailed:
+	default:
+		return badRequestError("invalid status %s", status)
+	}
+
+	approvals, err := s.store.GetAdminApprovals(r.Context(), status, limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, approvals)
+}
+
+// handleDecideAdminApproval approves or rejects the {id} pending admin
+// action. An approved action is carried out right away; when that fails the
+// approval is marked failed and the error returned.
+func (s *APIServer) handleDecideAdminApproval(status AdminApprovalStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		number, err := getAccountNumberFromToken(r)
+
+		if err != nil {
+			return err
+		}
+
+		id, err := getIdFromQueryParams(r)
+
+		if err != nil {
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+		}
+
+		approval, err := s.store.DecideAdminApproval(r.Context(), id, status, number, time.Now().UTC())
+
+		if err != nil {
+			return err
+		}
+
+		before := adminApprovalAuditSnapshot{Status: AdminApprovalPending}
+
+		if status == AdminApprovalRejected {
+			recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAdminApprovalRejected, approval.AccountNumber, before, approval))
+
+			return writeJSON(w, http.StatusOK, approval)
+		}
+
+		if err := s.executeAdminApproval(r, approval); err != nil {
+			approval.Status = AdminApprovalFailed
+			approval.Error = "internal server error"
+
+			var httpErr *HTTPError
+
+			if errors.As(err, &httpErr) {
+				approval.Error = httpErr.Message
+			}
+
+			if updateErr := s.store.UpdateAdminApproval(r.Context(), approval); updateErr != nil {
+				return updateErr
+			}
+
+			recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAdminApprovalApproved, approval.AccountNumber, before, approval))
+
+			return err
+		}
+
+		if err := s.store.UpdateAdminApproval(r.Context(), approval); err != nil {
+			return err
+		}
+
+		recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAdminApprovalApproved, approval.AccountNumber, before, approval))
+
+		return writeJSON(w, http.StatusOK, approval)
+	}
+}
+
+// executeAdminApproval carries out an approved action and audits it as if
+// the approving admin had done it.
+func (s *APIServer) executeAdminApproval(r *http.Request, approval *AdminApproval) error {
+	before, err := s.store.GetAccountByNumber(r.Context(), int(approval.AccountNumber))
+
+	if err != nil {
+		return err
+	}
+
+	var action AuditAction
+
+	switch approval.Action {
+	case AdminAdjustBalance:
+		transaction, err := s.store.AdjustBalance(r.Context(), before.Number, approval.Amount, approval.adjustmentDescription())
+
+		if err != nil {
+			return err
+		}
+
+		approval.TransactionID = &transaction.ID
+		action = AuditBalanceAdjusted
+
+		s.events.Publish(r.Context(), &Event{Type: EventTransactionCreated, AccountNumber: before.Number, Data: transaction})
+
+		if transaction.Amount < 0 {
+			publishBalanceEvent(r.Context(), s.events, before.Number, transaction.Balance, before.Currency)
+		}
+	case AdminUpdateLimits:
+		if err := s.store.UpdateAccountLimits(r.Context(), before.ID, *approval.Limits); err != nil {
+			return err
+		}
+
+		action = AuditAccountLimitsChanged
+	case AdminUnfreeze:
+		if _, err := s.store.UpdateAccountStatus(r.Context(), before.ID, AccountActive); err != nil {
+			return err
+		}
+
+		action = AuditAccountUnfrozen
+	}
+
+	after, err := s.store.GetAccountByNumber(r.Context(), int(approval.AccountNumber))
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, action, after.Number, before, after))
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleAliases(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetAliases(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateAlias(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetAliases(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	aliases, err := s.store.GetAliases(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, aliases)
+}
+
+// handleCreateAlias adds an email or phone alias to the {id} account and
+// sends it the code that verifies it.
+func (s *APIServer) handleCreateAlias(w http.ResponseWriter, r *http.Request) error {
+	req := new(AliasRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	alias, code, err := NewAlias(account.Number, req.Value, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreateAlias(r.Context(), alias); err != nil {
+		return err
+	}
+
+	notification := &Notification{
+		AccountNumber: account.Number,
+		Subject:       "Verify your alias",
+		Body:          fmt.Sprintf("Use this code with POST /account/%d/aliases/%d/verify before %s: %s", account.ID, alias.ID, alias.CodeExpiresAt.Format(http.TimeFormat), code),
+		To:            alias.Value,
+	}
+
+	if err := s.notifier.Notify(r.Context(), notification); err != nil {
+		slog.ErrorContext(r.Context(), "sending alias verification failed", "accountNumber", account.Number, "error", err)
+	}
+
+	return writeJSON(w, http.StatusCreated, alias)
+}
+
+func (s *APIServer) handleVerifyAlias(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	aliasID, err := strconv.Atoi(mux.Vars(r)["aliasId"])
+
+	if err != nil {
+		return badRequestError("invalid alias id given %s", mux.Vars(r)["aliasId"])
+	}
+
+	req := new(AliasVerifyRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	alias, err := s.store.VerifyAlias(r.Context(), aliasID, account.Number, hashToken(req.Code), time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAliasVerified, account.Number, nil, alias))
+
+	return writeJSON(w, http.StatusOK, alias)
+}
+
+func (s *APIServer) handleDeleteAlias(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	aliasID, err := strconv.Atoi(mux.Vars(r)["aliasId"])
+
+	if err != nil {
+		return badRequestError("invalid alias id given %s", mux.Vars(r)["aliasId"])
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteAlias(r.Context(), aliasID, account.Number); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, aliasID)
+}
+
+// handleResolveAlias shows any logged-in customer who a verified alias
+// belongs to, so they can check before paying it with toAlias.
+func (s *APIServer) handleResolveAlias(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	if _, err := getAccountNumberFromToken(r); err != nil {
+		return err
+	}
+
+	_, value, ok := parseAlias(r.URL.Query().Get("alias"))
+
+	if !ok {
+		return badRequestError("alias must be an email address or a phone number with its country code")
+	}
+
+	alias, err := s.store.ResolveAlias(r.Context(), value)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByNumber(r.Context(), int(alias.AccountNumber))
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, NewAliasResolution(alias, account))
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type amlFlagAuditSnapshot struct {
+	Status AMLFlagStatus `json:"status"`
+}
+
+// handleGetAMLFlags lists the AML flags for compliance review, oldest first,
+// filtered by ?status=, ?rule=, ?account= and the ?from= and ?to= dates.
+func (s *APIServer) handleGetAMLFlags(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	filter, err := getAMLFlagFilterFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	if filter.Limit, filter.Offset, err = getPaginationFromQueryParams(r); err != nil {
+		return err
+	}
+
+	flags, err := s.store.GetAMLFlags(r.Context(), filter)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, flags)
+}
+
+// handleExportAMLFlags writes every flag the filters of handleGetAMLFlags
+// select, with their notes, as a CSV attachment to file SARs from.
+func (s *APIServer) handleExportAMLFlags(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	filter, err := getAMLFlagFilterFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	flags, err := s.store.GetAMLFlags(r.Context(), filter)
+
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, len(flags))
+	byID := map[int]*AMLFlag{}
+
+	for i, flag := range flags {
+		ids[i] = flag.ID
+		byID[flag.ID] = flag
+	}
+
+	notes, err := s.store.GetAMLNotes(r.Context(), ids)
+
+	if err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		byID[note.FlagID].Notes = append(byID[note.FlagID].Notes, note)
+	}
+
+	accounts := map[int64]*Account{}
+
+	for _, flag := range flags {
+		if _, ok := accounts[flag.AccountNumber]; ok {
+			continue
+		}
+
+		account, err := s.store.GetAccountByNumber(r.Context(), int(flag.AccountNumber))
+
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+
+		accounts[flag.AccountNumber] = account
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := writeAMLFlagsCSV(buf, flags, accounts); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="aml-flags-`+time.Now().UTC().Format("20060102T150405Z")+`.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	_, err = buf.WriteTo(w)
+
+	return err
+}
+
+func (s *APIServer) handleGetAMLFlag(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	flag, err := s.store.GetAMLFlag(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, flag)
+}
+
+// handleUpdateAMLFlag moves the {id} flag to status. Reporting only records
+// that a SAR was filed; the report itself goes to the authorities outside
+// the bank.
+func (s *APIServer) handleUpdateAMLFlag(status AMLFlagStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		officer, err := getAccountNumberFromToken(r)
+
+		if err != nil {
+			return err
+		}
+
+		id, err := getIdFromQueryParams(r)
+
+		if err != nil {
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+		}
+
+		before, err := s.store.GetAMLFlag(r.Context(), id)
+
+		if err != nil {
+			return err
+		}
+
+		flag, err := s.store.UpdateAMLFlagStatus(r.Context(), id, status, officer, time.Now().UTC())
+
+		if err != nil {
+			return err
+		}
+
+		action := map[AMLFlagStatus]AuditAction{
+			AMLFlagInvestigating: AuditAMLInvestigating,
+			AMLFlagReported:      AuditAMLReported,
+			AMLFlagDismissed:     AuditAMLDismissed,
+		}[status]
+
+		recordAudit(r.Context(), s.store, newAuditEntry(r, action, flag.AccountNumber, amlFlagAuditSnapshot{Status: before.Status}, amlFlagAuditSnapshot{Status: flag.Status}))
+
+		return writeJSON(w, http.StatusOK, flag)
+	}
+}
+
+// handleAddAMLNote annotates the {id} flag, whatever its status, with the
+// officer's findings.
+func (s *APIServer) handleAddAMLNote(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	req := new(AMLNoteRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	body := strings.TrimSpace(req.Body)
+
+	if body == "" || len(body) > 2000 {
+		return validationError("body must be 1 to 2000 characters")
+	}
+
+	officer, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	note := &AMLNote{FlagID: id, Author: officer, Body: body, CreatedAt: time.Now().UTC()}
+
+	if err := s.store.AddAMLNote(r.Context(), note); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, note)
+}
+
+// getAMLFlagFilterFromQueryParams reads the filters of the AML flag listing;
+// the dates are inclusive.
+func getAMLFlagFilterFromQueryParams(r *http.Request) (*AMLFlagFilter, error) {
+	query := r.URL.Query()
+	filter := &AMLFlagFilter{
+		Rule:   AMLRule(query.Get("rule")),
+		Status: AMLFlagStatus(query.Get("status")),
+	}
+
+	switch filter.Rule {
+	case "", AMLRuleThreshold, AMLRuleStructuring:
+	default:
+		return nil, badRequestError("invalid rule %s", filter.Rule)
+	}
+
+	switch filter.Status {
+	case "", AMLFlagOpen, AMLFlagInvestigating, AMLFlagReported, AMLFlagDismissed:
+	default:
+		return nil, badRequestError("invalid status %s", filter.Status)
+	}
+
+	if v := query.Get("account"); v != "" {
+		number, err := strconv.ParseInt(v, 10, 64)
+
+		if err != nil || number <= 0 {
+			return nil, badRequestError("invalid account %s", v)
+		}
+
+		filter.AccountNumber = number
+	}
+
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(statementDateLayout, v)
+
+		if err != nil {
+			return nil, badRequestError("invalid from date %s", v)
+		}
+
+		filter.From = t
+	}
+
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(statementDateLayout, v)
+
+		if err != nil {
+			return nil, badRequestError("invalid to date %s", v)
+		}
+
+		filter.To = t.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleCategorizeTransaction(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "PUT" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	transactionID, err := strconv.Atoi(mux.Vars(r)["transactionId"])
+
+	if err != nil {
+		return badRequestError("invalid transaction id given %s", mux.Vars(r)["transactionId"])
+	}
+
+	req := new(CategoryRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	transaction, err := s.store.CategorizeTransaction(r.Context(), account.Number, transactionID, req.Category)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, transaction)
+}
+
+// handleGetAnalytics totals the account's entries per category and month.
+func (s *APIServer) handleGetAnalytics(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	from, to, err := getAnalyticsPeriodFromQueryParams(r, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	locale, err := requestLocale(r)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	totals, err := s.store.GetCategoryTotals(r.Context(), account.Number, from, to)
+
+	if err != nil {
+		return err
+	}
+
+	analytics := NewSpendingAnalytics(account, from, to, totals)
+
+	if locale != nil {
+		analytics.localize(locale)
+	}
+
+	setLocaleHeaders(w, locale)
+
+	return writeJSON(w, http.StatusOK, analytics)
+}
+
+// getAnalyticsPeriodFromQueryParams reads the inclusive from and to months
+// (YYYY-MM) and returns the period as [from, to+1 month). It defaults to the
+// last defaultAnalyticsMonths months, the current one included.
+func getAnalyticsPeriodFromQueryParams(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	to := startOfMonth(now)
+	from := to.AddDate(0, 1-defaultAnalyticsMonths, 0)
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(analyticsMonthLayout, v)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid from month %s", v)
+		}
+
+		from = t
+	}
+
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(analyticsMonthLayout, v)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid to month %s", v)
+		}
+
+		to = t
+	}
+
+	to = to.AddDate(0, 1, 0)
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, validationError("from must not be after to")
+	}
+
+	if monthsBetween(from, to) > maxAnalyticsMonths {
+		return time.Time{}, time.Time{}, validationError("analytics period must not exceed %d months", maxAnalyticsMonths)
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetAPIKeys(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateAPIKey(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.store.GetAPIKeys(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, keys)
+}
+
+// handleCreateAPIKey creates an API key for the {id} account and responds
+// with the key, the only time it is shown.
+func (s *APIServer) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) error {
+	req := new(APIKeyRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	key, secret, err := NewAPIKey(account.Number, req, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreateAPIKey(r.Context(), key); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAPIKeyCreated, account.Number, nil, key))
+
+	return writeJSON(w, http.StatusCreated, CreatedAPIKey{APIKey: key, Key: secret})
+}
+
+func (s *APIServer) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	keyID, err := strconv.Atoi(mux.Vars(r)["keyId"])
+
+	if err != nil || keyID <= 0 {
+		return badRequestError("invalid API key id given %s", mux.Vars(r)["keyId"])
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	key, err := s.store.RevokeAPIKey(r.Context(), keyID, account.Number, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditAPIKeyRevoked, account.Number, nil, key))
+
+	return writeJSON(w, http.StatusOK, key)
+}
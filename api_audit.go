@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handleGetAuditLog lists the audit log newest first.
+func (s *APIServer) handleGetAuditLog(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	entries, err := s.store.GetAuditLog(r.Context(), limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleBeneficiaries(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetBeneficiaries(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateBeneficiary(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleCreateBeneficiary(w http.ResponseWriter, r *http.Request) error {
+	owner, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(BeneficiaryRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if req.AccountNumber == owner {
+		return validationError("cannot save the account itself as a beneficiary")
+	}
+
+	if err := s.accountNumbers.Check("accountNumber", req.AccountNumber); err != nil {
+		return err
+	}
+
+	if _, err := s.store.GetAccountByNumber(r.Context(), int(req.AccountNumber)); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	beneficiary := &Beneficiary{
+		Owner:           owner,
+		Name:            strings.TrimSpace(req.Name),
+		AccountNumber:   req.AccountNumber,
+		Nickname:        strings.TrimSpace(req.Nickname),
+		CoolingOffUntil: now.Add(s.coolingOff),
+		CreatedAt:       now,
+	}
+
+	if err := s.store.CreateBeneficiary(r.Context(), beneficiary); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, beneficiary)
+}
+
+func (s *APIServer) handleGetBeneficiaries(w http.ResponseWriter, r *http.Request) error {
+	owner, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	beneficiaries, err := s.store.GetBeneficiaries(r.Context(), owner)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, beneficiaries)
+}
+
+func (s *APIServer) handleDeleteBeneficiary(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	owner, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	beneficiaryID, err := strconv.Atoi(mux.Vars(r)["beneficiaryId"])
+
+	if err != nil {
+		return badRequestError("invalid beneficiary id given %s", mux.Vars(r)["beneficiaryId"])
+	}
+
+	if err := s.store.DeleteBeneficiary(r.Context(), beneficiaryID, owner); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, beneficiaryID)
+}
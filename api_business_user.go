@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleBusinessUsers(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetBusinessUsers(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateBusinessUser(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleBusinessUser(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "PUT" {
+		return s.handleChangeBusinessUserRole(w, r)
+	}
+
+	if r.Method == "DELETE" {
+		return s.handleRemoveBusinessUser(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+// businessAccountFromPath loads the {id} account, which must be a business
+// account for it to have users.
+func (s *APIServer) businessAccountFromPath(r *http.Request) (*Account, error) {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Type != AccountBusiness {
+		return nil, validationError("only business accounts have users")
+	}
+
+	return account, nil
+}
+
+func (s *APIServer) handleGetBusinessUsers(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.businessAccountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	users, err := s.store.GetBusinessUsers(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, users)
+}
+
+// handleCreateBusinessUser gives a business account a user, who logs in
+// with the account's number, their username and their password.
+func (s *APIServer) handleCreateBusinessUser(w http.ResponseWriter, r *http.Request) error {
+	req := new(BusinessUserRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.businessAccountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	user, err := NewBusinessUser(account.Number, req, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreateBusinessUser(r.Context(), user); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditUserAdded, account.Number, nil, user))
+
+	return writeJSON(w, http.StatusCreated, user)
+}
+
+func (s *APIServer) handleChangeBusinessUserRole(w http.ResponseWriter, r *http.Request) error {
+	userID, err := businessUserIDFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(BusinessUserRoleRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.businessAccountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	before, after, err := s.store.SetBusinessUserRole(r.Context(), userID, account.Number, req.Role)
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditUserRoleChanged, account.Number, before, after))
+
+	return writeJSON(w, http.StatusOK, after)
+}
+
+// handleRemoveBusinessUser removes a user and logs them out of every
+// session.
+func (s *APIServer) handleRemoveBusinessUser(w http.ResponseWriter, r *http.Request) error {
+	userID, err := businessUserIDFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.businessAccountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	user, err := s.store.RemoveBusinessUser(r.Context(), userID, account.Number, now, now.Add(s.tokens.accessTokenTTL))
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditUserRemoved, account.Number, user, nil))
+
+	return writeJSON(w, http.StatusOK, user)
+}
+
+func businessUserIDFromPath(r *http.Request) (int, error) {
+	userID, err := strconv.Atoi(mux.Vars(r)["userId"])
+
+	if err != nil || userID <= 0 {
+		return 0, badRequestError("invalid user id given %s", mux.Vars(r)["userId"])
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleCards(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetCards(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleIssueCard(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetCards(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	cards, err := s.store.GetCards(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, cards)
+}
+
+// handleIssueCard issues a card on the {id} account. The response is the
+// only one to carry the card's full number.
+func (s *APIServer) handleIssueCard(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	if err := account.CheckActive(); err != nil {
+		return err
+	}
+
+	card, pan, err := NewCard(account.Number, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreateCard(r.Context(), card); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, &IssuedCard{Card: card, PAN: pan})
+}
+
+// handleUpdateCardStatus freezes or unfreezes the {cardId} card of the {id}
+// account; frozen cards are declined.
+func (s *APIServer) handleUpdateCardStatus(status CardStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		cardID, err := strconv.Atoi(mux.Vars(r)["cardId"])
+
+		if err != nil {
+			return badRequestError("invalid card id given %s", mux.Vars(r)["cardId"])
+		}
+
+		account, err := s.accountFromPath(r)
+
+		if err != nil {
+			return err
+		}
+
+		card, err := s.store.UpdateCardStatus(r.Context(), cardID, account.Number, status)
+
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, http.StatusOK, card)
+	}
+}
+
+// handleAuthorizeCard answers a card processor: approved authorizations hold
+// the amount on the card's account, declined ones say why. Both are 200s, so
+// only a missing card or a malformed request is an error.
+func (s *APIServer) handleAuthorizeCard(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	req := new(CardAuthorizationRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	auth := NewCardAuthorization(req, s.cardAuthorizationTTL, time.Now().UTC())
+
+	if err := s.store.AuthorizeCard(r.Context(), hashToken(req.PAN), auth); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, auth)
+}
+
+// withCardProcessorAuth only lets the request through when it carries the
+// configured card processor key in x-processor-key; with no key configured
+// nothing gets through.
+func withCardProcessorAuth(key string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := r.Header.Get("x-processor-key")
+
+			if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+				writeError(w, r, forbiddenError("permission denied"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
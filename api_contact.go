@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// contactKinds are the kinds of contact details in the order they are set.
+var contactKinds = []ContactKind{ContactEmail, ContactPhone, ContactAddress}
+
+func (s *APIServer) handleContact(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetContact(w, r)
+	}
+
+	if r.Method == "PUT" {
+		return s.handleSetContact(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetContact(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	details, err := s.store.GetContactDetails(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, NewContact(details))
+}
+
+// handleSetContact sets the contact details of the {id} account the request
+// has and sends each a code that verifies it. Setting a detail to the
+// verified value it has changes nothing; setting it to the unverified value
+// it has sends a new code.
+func (s *APIServer) handleSetContact(w http.ResponseWriter, r *http.Request) error {
+	req := new(ContactRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	details, err := s.store.GetContactDetails(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	contact := NewContact(details)
+	values := req.values()
+	now := time.Now().UTC()
+
+	for _, kind := range contactKinds {
+		value, ok := values[kind]
+		current := contact.detail(kind)
+
+		if !ok || (current != nil && current.Value == value && current.VerifiedAt != nil) {
+			continue
+		}
+
+		detail, code, err := NewContactDetail(account.Number, kind, value, now)
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.store.SetContactDetail(r.Context(), detail); err != nil {
+			return err
+		}
+
+		if current != nil && current.Value != value {
+			recordAudit(r.Context(), s.store, newAuditEntry(r, AuditContactChanged, account.Number, current, detail))
+		}
+
+		notification := &Notification{
+			AccountNumber: account.Number,
+			Subject:       fmt.Sprintf("Verify your %s", kind),
+			Body:          fmt.Sprintf("Use this code with POST /account/%d/contact/%s/verify before %s: %s", account.ID, kind, detail.CodeExpiresAt.Format(http.TimeFormat), code),
+			To:            value,
+		}
+
+		if err := s.notifier.Notify(r.Context(), notification); err != nil {
+			slog.ErrorContext(r.Context(), "sending contact verification failed", "accountNumber", account.Number, "kind", kind, "error", err)
+		}
+	}
+
+	return s.handleGetContact(w, r)
+}
+
+func (s *APIServer) handleVerifyContact(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	kind, err := contactKindFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(ContactVerifyRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	detail, err := s.store.VerifyContactDetail(r.Context(), account.Number, kind, hashToken(req.Code), time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditContactVerified, account.Number, nil, detail))
+
+	return writeJSON(w, http.StatusOK, detail)
+}
+
+func (s *APIServer) handleDeleteContact(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	kind, err := contactKindFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	details, err := s.store.GetContactDetails(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteContactDetail(r.Context(), account.Number, kind); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditContactChanged, account.Number, NewContact(details).detail(kind), nil))
+
+	return writeJSON(w, http.StatusOK, kind)
+}
+
+func contactKindFromPath(r *http.Request) (ContactKind, error) {
+	kind := ContactKind(mux.Vars(r)["kind"])
+
+	for _, k := range contactKinds {
+		if k == kind {
+			return kind, nil
+		}
+	}
+
+	return "", badRequestError("contact kind must be email, phone or address, got %s", kind)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// handleExportDataset streams every account, account event, journal entry,
+// transaction and transfer as an NDJSON archive, or as one JSON document
+// with ?format=json.
+func (s *APIServer) handleExportDataset(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	format := DatasetFormat(r.URL.Query().Get("format"))
+
+	if format == "" {
+		format = DatasetNDJSON
+	}
+
+	contentType, ok := datasetContentTypes[format]
+
+	if !ok {
+		return badRequestError("invalid format %s, use ndjson or json", format)
+	}
+
+	now := time.Now().UTC()
+	dataset, err := s.store.ExportDataset(r.Context(), now)
+
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(r.Context(), "dataset exported", "summary", dataset.Summary().String())
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="go-bank-`+now.Format("20060102T150405Z")+`.`+string(format)+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	return WriteDataset(w, dataset, format)
+}
+
+// handleImportDataset loads an archive made by handleExportDataset into
+// this instance, which must not have moved any money yet.
+func (s *APIServer) handleImportDataset(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format := DatasetFormat("")
+
+	for f, contentType := range datasetContentTypes {
+		if contentType == mediaType {
+			format = f
+		}
+	}
+
+	if format == "" {
+		return badRequestError("unsupported content type %q, send application/x-ndjson or application/json", mediaType)
+	}
+
+	dataset, err := ReadDataset(http.MaxBytesReader(w, r.Body, maxDatasetSize), format)
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		return badRequestError("the archive must be at most %d bytes", maxDatasetSize)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.ImportDataset(r.Context(), dataset); err != nil {
+		return err
+	}
+
+	summary := dataset.Summary()
+	slog.InfoContext(r.Context(), "dataset imported", "summary", summary.String(), "exportedAt", dataset.ExportedAt)
+
+	return writeJSON(w, http.StatusCreated, summary)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type disputeAuditSnapshot struct {
+	Status         DisputeStatus `json:"status"`
+	ReversedAmount int64         `json:"reversedAmount"`
+}
+
+func (s *APIServer) handleDisputes(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetAccountDisputes(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateDispute(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+// handleCreateDispute disputes a debit of the {id} account; nothing moves
+// until an admin reverses the dispute.
+func (s *APIServer) handleCreateDispute(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(DisputeRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	dispute := &Dispute{
+		AccountNumber: account.Number,
+		TransactionID: req.TransactionID,
+		Amount:        req.Amount,
+		Reason:        strings.TrimSpace(req.Reason),
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	if err := s.store.CreateDispute(r.Context(), dispute); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, dispute)
+}
+
+func (s *APIServer) handleGetAccountDisputes(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return s.writeDisputes(w, r, account.Number)
+}
+
+// handleGetDisputes is the queue of disputes across all accounts, oldest
+// first, filtered by ?status=.
+func (s *APIServer) handleGetDisputes(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	return s.writeDisputes(w, r, 0)
+}
+
+// writeDisputes writes the page of the number account's disputes, or of
+// every account's if 0, asked for by the query parameters.
+func (s *APIServer) writeDisputes(w http.ResponseWriter, r *http.Request, number int64) error {
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	status := DisputeStatus(r.URL.Query().Get("status"))
+
+	switch status {
+	case "", DisputeOpen, DisputeReversed, DisputeDenied:
+	default:
+		return badRequestError("invalid status %s", status)
+	}
+
+	disputes, err := s.store.GetDisputes(r.Context(), number, status, limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, disputes)
+}
+
+// handleDecideDispute reverses or denies the {id} open dispute. Reversals
+// post compensating entries and never touch the disputed one.
+func (s *APIServer) handleDecideDispute(status DisputeStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		admin, err := getAccountNumberFromToken(r)
+
+		if err != nil {
+			return err
+		}
+
+		id, err := getIdFromQueryParams(r)
+
+		if err != nil {
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+		}
+
+		req := new(DisputeDecisionRequest)
+
+		if err := decodeJSON(r, req); err != nil {
+			return err
+		}
+
+		if status == DisputeDenied {
+			errs := FieldErrors{}
+
+			if strings.TrimSpace(req.Note) == "" {
+				errs.Add("note", "is required")
+			}
+
+			if req.Amount != 0 {
+				errs.Add("amount", "must not be set when denying")
+			}
+
+			if err := errs.Err(); err != nil {
+				return err
+			}
+		}
+
+		decision := &DisputeDecision{
+			Status:    status,
+			Amount:    req.Amount,
+			Note:      strings.TrimSpace(req.Note),
+			DecidedBy: admin,
+			DecidedAt: time.Now().UTC(),
+		}
+
+		dispute, err := s.store.DecideDispute(r.Context(), id, decision)
+
+		if err != nil {
+			return err
+		}
+
+		action := AuditDisputeReversed
+
+		if status == DisputeDenied {
+			action = AuditDisputeDenied
+		}
+
+		recordAudit(r.Context(), s.store, newAuditEntry(r, action, dispute.AccountNumber, disputeAuditSnapshot{Status: DisputeOpen}, disputeAuditSnapshot{Status: dispute.Status, ReversedAmount: dispute.ReversedAmount}))
+
+		return writeJSON(w, http.StatusOK, dispute)
+	}
+}
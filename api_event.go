@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGetAccountEvents pages through the account's events by version.
+func (s *APIServer) handleGetAccountEvents(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	limit, _, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	filter := AccountEventFilter{Limit: limit}
+
+	if v := r.URL.Query().Get("afterVersion"); v != "" {
+		filter.AfterVersion, err = strconv.Atoi(v)
+
+		if err != nil || filter.AfterVersion < 0 {
+			return badRequestError("invalid afterVersion %s", v)
+		}
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	events, err := s.store.GetAccountEvents(r.Context(), account.Number, filter)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, events)
+}
+
+// handleGetBalanceAt replays the account's events up to the at query
+// parameter, now if it is not given.
+func (s *APIServer) handleGetBalanceAt(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	at := time.Now().UTC()
+
+	if v := r.URL.Query().Get("at"); v != "" {
+		at, err = time.Parse(time.RFC3339, v)
+
+		if err != nil {
+			return badRequestError("invalid at %s", v)
+		}
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	aggregate, err := ReplayAccount(r.Context(), s.store, account.Number, at)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, aggregate)
+}
+
+// handleCheckProjections replays every account against its stored balance.
+// Mismatches are still a 200, the report lists them.
+func (s *APIServer) handleCheckProjections(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	report, err := CheckProjections(r.Context(), s.store)
+
+	if err != nil {
+		return err
+	}
+
+	if !report.Consistent {
+		slog.ErrorContext(r.Context(), "event replay does not match balances", "mismatchedAccounts", len(report.Mismatches))
+	}
+
+	return writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type externalTransferAuditSnapshot struct {
+	Status     ExternalTransferStatus `json:"status"`
+	ReturnCode string                 `json:"returnCode,omitempty"`
+}
+
+func (s *APIServer) handleExternalTransfers(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetExternalTransfers(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateExternalTransfer(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+// handleCreateExternalTransfer holds the amount of a payment to another bank
+// on the {id} account; the clearing worker submits and later settles it.
+func (s *APIServer) handleCreateExternalTransfer(w http.ResponseWriter, r *http.Request) error {
+	req := new(ExternalTransferRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkKYCTransferLimit(r.Context(), s.store, s.kycTransferLimit, account.Number, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkVerifiedEmail(r.Context(), s.store, s.verifiedEmailAmount, account.Number, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkSecondOwner(r.Context(), s.store, account.Number, req.Amount); err != nil {
+		return err
+	}
+
+	transfer := NewExternalTransfer(account.Number, req, time.Now().UTC())
+
+	if err := s.store.CreateExternalTransfer(r.Context(), transfer); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, transfer)
+}
+
+func (s *APIServer) handleGetExternalTransfers(w http.ResponseWriter, r *http.Request) error {
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	transfers, err := s.store.GetExternalTransfers(r.Context(), account.Number, limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, transfers)
+}
+
+// handleReturnExternalTransfer records a return by the clearing network of
+// the {id} transfer, e.g. for a closed destination account. Unsettled
+// transfers release their hold; settled ones are credited back.
+func (s *APIServer) handleReturnExternalTransfer(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(ExternalTransferReturnRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	transfer, err := s.store.ReturnExternalTransfer(r.Context(), id, req.Code, strings.TrimSpace(req.Reason), time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditExternalReturned, transfer.AccountNumber, nil, externalTransferAuditSnapshot{Status: transfer.Status, ReturnCode: transfer.ReturnCode}))
+
+	return writeJSON(w, http.StatusOK, transfer)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type fraudReviewAuditSnapshot struct {
+	Status FraudReviewStatus `json:"status"`
+}
+
+// handleGetFraudReviews is the review queue of the transfers the fraud rules
+// flagged or blocked, oldest first, filtered by ?status=.
+func (s *APIServer) handleGetFraudReviews(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	status := FraudReviewStatus(r.URL.Query().Get("status"))
+
+	switch status {
+	case "", FraudReviewPending, FraudReviewCleared, FraudReviewConfirmed:
+	default:
+		return badRequestError("invalid status %s", status)
+	}
+
+	reviews, err := s.store.GetFraudReviews(r.Context(), status, limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, reviews)
+}
+
+// handleDecideFraudReview clears or confirms the {id} pending review.
+// Confirming doesn't act on the accounts; staff freeze them separately.
+func (s *APIServer) handleDecideFraudReview(status FraudReviewStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		reviewer, err := getAccountNumberFromToken(r)
+
+		if err != nil {
+			return err
+		}
+
+		id, err := getIdFromQueryParams(r)
+
+		if err != nil {
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+		}
+
+		review, err := s.store.DecideFraudReview(r.Context(), id, status, reviewer, time.Now().UTC())
+
+		if err != nil {
+			return err
+		}
+
+		action := AuditFraudCleared
+
+		if status == FraudReviewConfirmed {
+			action = AuditFraudConfirmed
+		}
+
+		recordAudit(r.Context(), s.store, newAuditEntry(r, action, review.FromAccount, fraudReviewAuditSnapshot{Status: FraudReviewPending}, fraudReviewAuditSnapshot{Status: review.Status}))
+
+		return writeJSON(w, http.StatusOK, review)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleAuthorizeTransfer places a hold for a transfer from the token's
+// account; the money only moves when the hold is captured.
+func (s *APIServer) handleAuthorizeTransfer(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	fromAccount, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(TransferRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if req.FromAccount != 0 && int64(req.FromAccount) != fromAccount {
+		return validationError("fromAccount is only supported by POST /transfer")
+	}
+
+	if req.QuoteID != 0 {
+		return validationError("quoteId is only supported by POST /transfer")
+	}
+
+	if err := s.checkTransferRequest(r.Context(), fromAccount, fromAccount, req); err != nil {
+		return err
+	}
+
+	if err := checkSecondOwner(r.Context(), s.store, fromAccount, req.Amount); err != nil {
+		return err
+	}
+
+	transfer, err := newTransfer(r.Context(), s.store, s.rates, fromAccount, int64(req.ToAccount), req.Amount)
+
+	if err != nil {
+		return err
+	}
+
+	hold := NewHold(transfer, s.holdTTL)
+
+	if err := s.store.AuthorizeTransfer(r.Context(), hold); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, hold)
+}
+
+func (s *APIServer) handleCaptureHold(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	fromAccount, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	transfer, err := s.store.CaptureHold(r.Context(), id, fromAccount, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	publishTransferEvents(r.Context(), s.events, s.store, transfer)
+
+	return writeJSON(w, http.StatusOK, transfer)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// importFormats maps the accepted content types to their file format.
+var importFormats = map[string]ImportFormat{
+	"text/csv":          ImportCSV,
+	"application/x-ofx": ImportOFX,
+	"application/ofx":   ImportOFX,
+}
+
+// handleImport books the external transactions of a CSV or OFX file on the
+// {id} account, skipping the ones imported before or already in its
+// ledger. With ?dryRun=true it only reports what it would import.
+func (s *APIServer) handleImport(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, ok := importFormats[mediaType]
+
+	if !ok {
+		return badRequestError("unsupported content type %q, send text/csv or application/x-ofx", mediaType)
+	}
+
+	query := r.URL.Query()
+	dryRun := false
+
+	if value := query.Get("dryRun"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return badRequestError("invalid dryRun %s", value)
+		}
+
+		dryRun = parsed
+	}
+
+	mapping, err := csvMappingFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		return badRequestError("the file must be at most %d bytes", maxImportSize)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := parseImport(format, bytes.NewReader(file), mapping, account.Currency)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := s.store.ImportTransactions(r.Context(), account.Number, rows, dryRun, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return writeJSON(w, http.StatusOK, result)
+	}
+
+	return writeJSON(w, http.StatusCreated, result)
+}
+
+// csvMappingFromQueryParams overrides the default CSV column names, date
+// format and delimiter with the ones given in the query.
+func csvMappingFromQueryParams(r *http.Request) (CSVMapping, error) {
+	query := r.URL.Query()
+	mapping := defaultCSVMapping
+
+	for param, field := range map[string]*string{
+		"dateColumn":        &mapping.Date,
+		"amountColumn":      &mapping.Amount,
+		"descriptionColumn": &mapping.Description,
+		"idColumn":          &mapping.ID,
+		"dateFormat":        &mapping.DateFormat,
+	} {
+		if value := query.Get(param); value != "" {
+			*field = value
+		}
+	}
+
+	if value := query.Get("delimiter"); value != "" {
+		delimiter, size := utf8.DecodeRuneInString(value)
+
+		if size != len(value) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+			return mapping, badRequestError("invalid delimiter %s", value)
+		}
+
+		mapping.Delimiter = delimiter
+	}
+
+	return mapping, nil
+}
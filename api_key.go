@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// APIKeyScope is what an API key may do with its account.
+type APIKeyScope string
+
+const (
+	// APIKeyScopeRead reads the account, its ledger and statements.
+	APIKeyScopeRead APIKeyScope = "read"
+	// APIKeyScopeTransfer initiates transfers from the account.
+	APIKeyScopeTransfer APIKeyScope = "transfer"
+)
+
+// apiKeyRoutes are the routes each scope opens to API keys, as a method and
+// a path template without the version prefix. Every other route, including
+// the management of API keys, logins and sessions, needs an access token.
+var apiKeyRoutes = map[APIKeyScope][]string{
+	APIKeyScopeRead: {
+		"GET /account/{id}",
+		"GET /account/{id}/transactions",
+		"GET /account/{id}/transactions/export",
+		"GET /account/{id}/transfers",
+		"GET /account/{id}/analytics",
+		"GET /account/{id}/events",
+		"GET /account/{id}/balance",
+		"GET /account/{id}/balance/history",
+		"GET /account/{id}/statement",
+		"GET /transfer/{id}",
+		"GET /transfer/{id}/receipt",
+	},
+	APIKeyScopeTransfer: {
+		"POST /transfer",
+		"POST /transfer/quote",
+		"GET /transfer/{id}",
+	},
+}
+
+const (
+	// apiKeyPrefix starts every API key, so leaked keys are easy to scan
+	// for.
+	apiKeyPrefix = "gbk_"
+
+	// apiKeyLastUsedPrecision is how stale LastUsedAt may get, so a busy
+	// key isn't written on every request.
+	apiKeyLastUsedPrecision = time.Minute
+
+	// TokenSourceAPIKey is the x-api-key header of machine-to-machine
+	// clients.
+	TokenSourceAPIKey TokenSource = "x-api-key"
+)
+
+// APIKey lets a machine act on the account it was created for, within its
+// scopes, without logging in. Only the hash of the key is stored; the key
+// itself is shown once, when it is created.
+type APIKey struct {
+	ID            int           `json:"id"`
+	AccountNumber int64         `json:"accountNumber"`
+	Name          string        `json:"name"`
+	Scopes        []APIKeyScope `json:"scopes"`
+	// Prefix is the start of the key, to tell keys apart in listings.
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+
+	KeyHash string `json:"-"`
+}
+
+// APIKeyRequest creates an API key.
+type APIKeyRequest struct {
+	Name   string        `json:"name"`
+	Scopes []APIKeyScope `json:"scopes"`
+}
+
+// CreatedAPIKey is a new API key with the key itself, which can't be
+// retrieved again.
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// NewAPIKey makes a key for the number account and returns it with the key
+// to hand to the caller.
+func NewAPIKey(number int64, req *APIKeyRequest, now time.Time) (*APIKey, string, error) {
+	name := strings.TrimSpace(req.Name)
+
+	if name == "" || len(name) > 100 {
+		return nil, "", validationError("name must be 1 to 100 characters")
+	}
+
+	if len(req.Scopes) == 0 {
+		return nil, "", validationError("at least one scope is required")
+	}
+
+	scopes := []APIKeyScope{}
+
+	for _, scope := range req.Scopes {
+		if _, ok := apiKeyRoutes[scope]; !ok {
+			return nil, "", validationError("unknown scope %s", scope)
+		}
+
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	token, err := randomToken()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := apiKeyPrefix + token
+
+	return &APIKey{
+		AccountNumber: number,
+		Name:          name,
+		Scopes:        scopes,
+		Prefix:        key[:len(apiKeyPrefix)+6],
+		CreatedAt:     now,
+		KeyHash:       hashToken(key),
+	}, key, nil
+}
+
+// Allows reports whether the scopes of k open the route of method and
+// template.
+func (k *APIKey) Allows(method, template string) bool {
+	route := method + " " + template
+
+	for _, scope := range k.Scopes {
+		if slices.Contains(apiKeyRoutes[scope], route) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unversionedTemplate strips the version prefix from a route's path
+// template.
+func unversionedTemplate(template string) string {
+	for _, v := range apiVersions {
+		if rest, ok := strings.CutPrefix(template, v.prefix()); ok {
+			return rest
+		}
+	}
+
+	return template
+}
+
+// withAPIKey identifies the caller of requests carrying an x-api-key header
+// by that key instead of an access token, which is then ignored. Like
+// withAccessToken it leaves denying requests to the routes needing a
+// caller: unknown and revoked keys are unauthorized, and keys are forbidden
+// the routes outside their scopes and those of other accounts.
+func withAPIKey(store Storage) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := r.Header.Get("x-api-key")
+
+			if given == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := accessToken{number: -1, source: TokenSourceAPIKey}
+			key, err := checkAPIKey(r.Context(), store, given, time.Now().UTC())
+
+			if err == nil {
+				err = checkCallerRoute(r, store, key.AccountNumber, key.Allows, "the API key's scopes don't allow %s %s")
+			}
+
+			if err != nil {
+				token.err = err
+			} else {
+				token.number = key.AccountNumber
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accessTokenKey, token)))
+		})
+	}
+}
+
+// checkAPIKey returns the live key given, recording that it was used at now.
+func checkAPIKey(ctx context.Context, store APIKeyRepository, given string, now time.Time) (*APIKey, error) {
+	key, err := store.GetAPIKeyByHash(ctx, hashToken(given))
+
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	if key == nil || key.RevokedAt != nil {
+		return nil, unauthorizedError("invalid API key")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedPrecision {
+		if err := store.TouchAPIKey(ctx, key.ID, now); err != nil {
+			return nil, err
+		}
+
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
+
+// checkCallerRoute denies callers acting for the number account, API keys
+// and business users, the routes allows doesn't open to them, with the
+// denied message, and the routes of other accounts than number, even those
+// it co-owns.
+func checkCallerRoute(r *http.Request, store AccountRepository, number int64, allows func(method, template string) bool, denied string) error {
+	route := mux.CurrentRoute(r)
+
+	if route == nil {
+		return notFoundError("route %s not found", r.URL.Path)
+	}
+
+	template, _ := route.GetPathTemplate()
+
+	if !allows(r.Method, unversionedTemplate(template)) {
+		return forbiddenError(denied, r.Method, r.URL.Path)
+	}
+
+	if !strings.HasPrefix(unversionedTemplate(template), "/account/{id}") {
+		return nil
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := store.GetAccountById(r.Context(), id)
+
+	if err != nil || account.Number != number {
+		return forbiddenError("permission denied")
+	}
+
+	return nil
+}
+
+// checkAPIKeySource denies API keys transfers from other accounts than their
+// own.
+func checkAPIKeySource(r *http.Request, requester, fromAccount int64) error {
+	if getTokenSource(r) == TokenSourceAPIKey && fromAccount != requester {
+		return forbiddenError("API keys only transfer from their own account")
+	}
+
+	return nil
+}
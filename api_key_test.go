@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	now := time.Now().UTC()
+
+	key, secret, err := NewAPIKey(42, &APIKeyRequest{Name: " erp ", Scopes: []APIKeyScope{APIKeyScopeRead, APIKeyScopeRead}}, now)
+	require.Nil(t, err)
+	assert.Equal(t, "erp", key.Name)
+	assert.Equal(t, []APIKeyScope{APIKeyScopeRead}, key.Scopes)
+	assert.Equal(t, hashToken(secret), key.KeyHash)
+	assert.Equal(t, secret[:10], key.Prefix)
+	assert.True(t, key.Allows("GET", "/account/{id}/statement"))
+	assert.False(t, key.Allows("POST", "/transfer"))
+
+	_, _, err = NewAPIKey(42, &APIKeyRequest{Name: "erp", Scopes: []APIKeyScope{"admin"}}, now)
+	assert.ErrorContains(t, err, "unknown scope admin")
+
+	_, _, err = NewAPIKey(42, &APIKeyRequest{Name: "erp"}, now)
+	assert.ErrorContains(t, err, "at least one scope")
+}
+
+func TestMemoryStoreAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	key, secret, err := NewAPIKey(42, &APIKeyRequest{Name: "erp", Scopes: []APIKeyScope{APIKeyScopeRead}}, now)
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAPIKey(ctx, key))
+
+	used, err := checkAPIKey(ctx, store, secret, now)
+	require.Nil(t, err)
+	assert.Equal(t, now, *used.LastUsedAt)
+
+	_, err = checkAPIKey(ctx, store, secret, now.Add(time.Second))
+	require.Nil(t, err)
+
+	keys, err := store.GetAPIKeys(ctx, 42)
+	require.Nil(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, now, *keys[0].LastUsedAt, "last use is only written once a minute")
+
+	_, err = store.RevokeAPIKey(ctx, key.ID, 43, now)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+
+	revoked, err := store.RevokeAPIKey(ctx, key.ID, 42, now)
+	require.Nil(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = store.RevokeAPIKey(ctx, key.ID, 42, now)
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status)
+
+	_, err = checkAPIKey(ctx, store, secret, now)
+	assert.Equal(t, http.StatusUnauthorized, err.(*HTTPError).Status)
+
+	_, err = checkAPIKey(ctx, store, "gbk_unknown", now)
+	assert.Equal(t, http.StatusUnauthorized, err.(*HTTPError).Status)
+}
+
+func TestAPIKeys(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	create := func(scopes ...APIKeyScope) *CreatedAPIKey {
+		rec := api.do("POST", path+"/api-keys", token, APIKeyRequest{Name: "erp", Scopes: scopes})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		created := new(CreatedAPIKey)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(created))
+
+		return created
+	}
+
+	withKey := func(method, path, key string, body any) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(body)
+		require.Nil(t, err)
+
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", key)
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	reader := create(APIKeyScopeRead)
+	transferrer := create(APIKeyScopeTransfer)
+
+	rec = withKey("GET", path+"/statement", reader.Key, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = withKey("GET", "/v2/account/"+strconv.Itoa(alice.ID), reader.Key, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = withKey("POST", "/transfer", reader.Key, TransferRequest{ToAccount: int(bob.Number), Amount: 100})
+	assert.Equal(t, http.StatusForbidden, rec.Code, "read keys can't transfer")
+
+	rec = withKey("GET", "/account/"+strconv.Itoa(bob.ID), reader.Key, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "keys only reach their own account")
+
+	rec = withKey("GET", path+"/api-keys", reader.Key, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "keys can't manage keys")
+
+	rec = withKey("POST", "/transfer", transferrer.Key, TransferRequest{ToAccount: int(bob.Number), Amount: 100})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = withKey("GET", path+"/transactions", transferrer.Key, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "transfer keys can't read the ledger")
+
+	rec = api.do("GET", path+"/api-keys", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), reader.Key)
+
+	keys := []*APIKey{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&keys))
+	require.Len(t, keys, 2)
+	assert.Equal(t, transferrer.ID, keys[0].ID, "the newest first")
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	rec = api.do("DELETE", path+"/api-keys/"+strconv.Itoa(reader.ID), token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = withKey("GET", path+"/statement", reader.Key, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "revoked keys are denied at once")
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, AuditAPIKeyRevoked, entries[0].Action)
+}
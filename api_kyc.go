@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// kycAuditSnapshot leaves the holder's personal details out of the audit log.
+type kycAuditSnapshot struct {
+	Status          KYCStatus `json:"status"`
+	RejectionReason string    `json:"rejectionReason,omitempty"`
+}
+
+func (s *APIServer) handleKYC(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetKYC(w, r)
+	}
+
+	if r.Method == "PUT" {
+		return s.handleSubmitKYC(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetKYC(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	kyc, err := s.store.GetKYC(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, kyc)
+}
+
+// handleSubmitKYC is the second step of onboarding, after POST /account:
+// the holder submits their details and waits for an admin to review them.
+// Rejected holders can submit corrected details.
+func (s *APIServer) handleSubmitKYC(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(KYCRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	kyc := &KYC{
+		AccountNumber: account.Number,
+		DateOfBirth:   req.DateOfBirth,
+		NationalID:    req.NationalID,
+		Address:       req.Address,
+		SubmittedAt:   time.Now().UTC(),
+	}
+
+	if err := s.store.SubmitKYC(r.Context(), kyc); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditKYCSubmitted, account.Number, kycAuditSnapshot{Status: account.KYCStatus}, kycAuditSnapshot{Status: kyc.Status}))
+
+	return writeJSON(w, http.StatusOK, kyc)
+}
+
+// handleGetPendingKYC lists the submissions admins have yet to review.
+func (s *APIServer) handleGetPendingKYC(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	submissions, err := s.store.GetPendingKYC(r.Context(), limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, submissions)
+}
+
+// handleReviewKYC verifies or rejects the {id} account's pending submission.
+// Rejections must give the holder a reason.
+func (s *APIServer) handleReviewKYC(status KYCStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		id, err := getIdFromQueryParams(r)
+
+		if err != nil {
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+		}
+
+		req := new(KYCRejectRequest)
+
+		if status == KYCRejected {
+			if err := decodeJSON(r, req); err != nil {
+				return err
+			}
+		}
+
+		account, err := s.store.GetAccountById(r.Context(), id)
+
+		if err != nil {
+			return err
+		}
+
+		kyc, err := s.store.ReviewKYC(r.Context(), account.Number, status, req.Reason, time.Now().UTC())
+
+		if err != nil {
+			return err
+		}
+
+		action := AuditKYCVerified
+
+		if status == KYCRejected {
+			action = AuditKYCRejected
+		}
+
+		recordAudit(r.Context(), s.store, newAuditEntry(r, action, account.Number, kycAuditSnapshot{Status: account.KYCStatus}, kycAuditSnapshot{Status: kyc.Status, RejectionReason: kyc.RejectionReason}))
+
+		return writeJSON(w, http.StatusOK, kyc)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// handleLedgerIntegrity reports whether the books balance. An unbalanced
+// ledger is still a 200, the report says what is off.
+func (s *APIServer) handleLedgerIntegrity(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	report, err := s.store.CheckLedgerIntegrity(r.Context())
+
+	if err != nil {
+		return err
+	}
+
+	if !report.Balanced {
+		slog.ErrorContext(r.Context(), "ledger integrity check failed",
+			"unbalancedEntries", len(report.UnbalancedEntries),
+			"mismatchedAccounts", len(report.MismatchedAccounts),
+		)
+	}
+
+	return writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleCreateLoan originates a loan and pays its principal into the
+// borrowing account.
+func (s *APIServer) handleCreateLoan(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	req := new(LoanRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByNumber(r.Context(), int(req.AccountNumber))
+
+	if err != nil {
+		return err
+	}
+
+	loan, installments := NewLoan(req, account, time.Now().UTC())
+	transaction, err := s.store.CreateLoan(r.Context(), loan, installments)
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditLoanOriginated, loan.AccountNumber, nil, loan))
+	s.events.Publish(r.Context(), &Event{Type: EventTransactionCreated, AccountNumber: loan.AccountNumber, Data: transaction})
+
+	return writeJSON(w, http.StatusCreated, loan)
+}
+
+func (s *APIServer) handleGetLoans(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	loans, err := s.store.GetLoans(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, loans)
+}
+
+func (s *APIServer) handleGetLoan(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	loan, err := s.loanFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, loan)
+}
+
+// handleGetLoanSchedule lists the {id} loan's installments with what is
+// paid, overdue or still scheduled.
+func (s *APIServer) handleGetLoanSchedule(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	loan, err := s.loanFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	installments, err := s.store.GetLoanSchedule(r.Context(), loan.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, installments)
+}
+
+// loanFromPath loads the {id} loan for an owner of its account or an admin.
+// Other requesters are told it doesn't exist.
+func (s *APIServer) loanFromPath(r *http.Request) (*Loan, error) {
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return nil, badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	loan, err := s.store.GetLoan(r.Context(), id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := isAccountOwner(r.Context(), s.store, loan.AccountNumber, requester)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if owner {
+		return loan, nil
+	}
+
+	if account, err := s.store.GetAccountByNumber(r.Context(), int(requester)); err == nil && account.Role == RoleAdmin {
+		return loan, nil
+	}
+
+	return nil, notFoundError("loan %d not found", id)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handleUnlockAccount lets an admin forget the failed logins of an account,
+// or with ?username= of one of its business users, ending the lockout or
+// backoff early. It answers with the failures it cleared.
+func (s *APIServer) handleUnlockAccount(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	username := r.URL.Query().Get("username")
+	throttle, err := s.store.ClearLoginThrottle(r.Context(), loginKey(account.Number, username))
+
+	if isNotFound(err) && username != "" {
+		return notFoundError("user %s of account %d has no failed logins", username, account.Number)
+	}
+
+	if isNotFound(err) {
+		return notFoundError("account %d has no failed logins", account.Number)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditLoginUnlocked, account.Number, throttle, nil))
+
+	return writeJSON(w, http.StatusOK, throttle)
+}
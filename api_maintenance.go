@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleMaintenance shows the maintenance mode on GET and switches it on
+// PUT. Switching is audited on the admin's account.
+func (s *APIServer) handleMaintenance(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return writeJSON(w, http.StatusOK, s.maintenance.State())
+	}
+
+	if r.Method != "PUT" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	admin, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(MaintenanceRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	retryAfter := time.Duration(req.RetryAfter) * time.Second
+
+	if retryAfter == 0 {
+		retryAfter = defaultMaintenanceRetry
+	}
+
+	before := s.maintenance.State()
+	state := &MaintenanceState{
+		Mode:       req.Mode,
+		Message:    req.Message,
+		RetryAfter: int(retryAfter.Seconds()),
+		UpdatedBy:  &admin,
+		UpdatedAt:  time.Now().UTC(),
+	}
+
+	if err := s.maintenance.Set(r.Context(), state); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditMaintenanceChanged, admin, before, state))
+
+	return writeJSON(w, http.StatusOK, state)
+}
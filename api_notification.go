@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+func (s *APIServer) handleNotificationPreferences(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetNotificationPreferences(w, r)
+	}
+
+	if r.Method == "PUT" {
+		return s.handleSaveNotificationPreferences(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	prefs, err := s.store.GetNotificationPreferences(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, prefs)
+}
+
+// handleSaveNotificationPreferences replaces what the holder of the {id}
+// account is notified about and where.
+func (s *APIServer) handleSaveNotificationPreferences(w http.ResponseWriter, r *http.Request) error {
+	req := new(NotificationPreferencesRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	prefs := NewNotificationPreferences(account.Number, req, time.Now().UTC())
+
+	if err := s.store.SaveNotificationPreferences(r.Context(), prefs); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, prefs)
+}
+
+// handleGetNotifications lists the notifications queued for the {id}
+// account and how their delivery went, newest first.
+func (s *APIServer) handleGetNotifications(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := s.store.GetNotificationDeliveries(r.Context(), account.Number, limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleOIDCLogin logs in the account linked to the subject of an ID token,
+// with the same two-factor check and tokens as a password login.
+func (s *APIServer) handleOIDCLogin(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	if s.oidc == nil {
+		return notFoundError("OIDC login is not configured")
+	}
+
+	req := new(OIDCLoginRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	subject, err := s.oidc.Verify(r.Context(), req.IDToken)
+
+	if err != nil {
+		return err
+	}
+
+	identity, err := s.store.GetIdentity(r.Context(), s.oidc.issuer, subject)
+
+	if isNotFound(err) {
+		return unauthorizedError("identity is not linked to an account")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	acc, err := s.store.GetAccountByNumber(r.Context(), int(identity.AccountNumber))
+
+	if isNotFound(err) {
+		return unauthorizedError("identity is not linked to an account")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = checkSecondFactor(r.Context(), s.store, acc.Number, req.TOTPCode)
+
+	if isLoginFailure(err) {
+		recordAudit(r.Context(), s.store, newAuditEntry(r, AuditLoginFailed, acc.Number, nil, nil))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := startSession(r.Context(), s.store, s.tokens, acc, nil, requestDevice(r))
+
+	if err != nil {
+		return err
+	}
+
+	recordLoginNetwork(r.Context(), s.store, s.events, acc.Number, clientIP(r.RemoteAddr))
+
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// handleLinkIdentity links the subject of an ID token to the caller's
+// account, so it can log in through the provider from then on.
+func (s *APIServer) handleLinkIdentity(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	if s.oidc == nil {
+		return notFoundError("OIDC login is not configured")
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(LinkIdentityRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	subject, err := s.oidc.Verify(r.Context(), req.IDToken)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	identity := &ExternalIdentity{
+		Issuer:        s.oidc.issuer,
+		Subject:       subject,
+		AccountNumber: account.Number,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	if err := s.store.LinkIdentity(r.Context(), identity); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditIdentityLinked, account.Number, nil, identity))
+
+	return writeJSON(w, http.StatusCreated, identity)
+}
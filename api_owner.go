@@ -0,0 +1,280 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleAccountOwners(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetAccountOwners(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleAddAccountOwner(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetAccountOwners(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	owners, err := s.store.GetAccountOwners(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, owners)
+}
+
+// handleAddAccountOwner makes the account joint. Only its holder can add
+// co-owners.
+func (s *APIServer) handleAddAccountOwner(w http.ResponseWriter, r *http.Request) error {
+	number, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(AccountOwnerRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if account.Number != number {
+		return forbiddenError("only the account holder can add owners")
+	}
+
+	if req.OwnerNumber == account.Number {
+		return validationError("the account holder already owns the account")
+	}
+
+	if _, err := s.store.GetAccountByNumber(r.Context(), int(req.OwnerNumber)); err != nil {
+		return err
+	}
+
+	owner := &AccountOwner{
+		AccountID:     account.ID,
+		AccountNumber: account.Number,
+		OwnerNumber:   req.OwnerNumber,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	if err := s.store.AddAccountOwner(r.Context(), owner); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditOwnerAdded, account.Number, nil, owner))
+
+	return writeJSON(w, http.StatusCreated, owner)
+}
+
+// handleRemoveAccountOwner lets the holder remove any co-owner, and a
+// co-owner remove themselves.
+func (s *APIServer) handleRemoveAccountOwner(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	number, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	ownerNumber, err := strconv.ParseInt(mux.Vars(r)["ownerNumber"], 10, 64)
+
+	if err != nil {
+		return badRequestError("invalid owner number given %s", mux.Vars(r)["ownerNumber"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if number != account.Number && number != ownerNumber {
+		return forbiddenError("only the account holder can remove other owners")
+	}
+
+	if err := s.store.RemoveAccountOwner(r.Context(), account.Number, ownerNumber); err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditOwnerRemoved, account.Number, &AccountOwner{AccountID: account.ID, AccountNumber: account.Number, OwnerNumber: ownerNumber}, nil))
+
+	return writeJSON(w, http.StatusOK, ownerNumber)
+}
+
+// handleGetOwnedAccounts lists the joint accounts the holder co-owns.
+func (s *APIServer) handleGetOwnedAccounts(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	owned, err := s.store.GetOwnedAccounts(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, owned)
+}
+
+func (s *APIServer) handleGetTransferApprovals(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	approvals, err := s.store.GetTransferApprovals(r.Context(), account.Number, limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, approvals)
+}
+
+// handleDecideTransferApproval approves or rejects a pending transfer of the
+// {id} account. An approved transfer is executed right away; when that fails
+// the approval is marked failed and the error returned.
+func (s *APIServer) handleDecideTransferApproval(status TransferApprovalStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		number, err := getAccountNumberFromToken(r)
+
+		if err != nil {
+			return err
+		}
+
+		id, err := getIdFromQueryParams(r)
+
+		if err != nil {
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+		}
+
+		approvalID, err := strconv.Atoi(mux.Vars(r)["approvalId"])
+
+		if err != nil {
+			return badRequestError("invalid approval id given %s", mux.Vars(r)["approvalId"])
+		}
+
+		account, err := s.store.GetAccountById(r.Context(), id)
+
+		if err != nil {
+			return err
+		}
+
+		var userID int
+
+		if user := getBusinessUserFromToken(r); user != nil {
+			userID = user.ID
+		}
+
+		approval, err := s.store.DecideTransferApproval(r.Context(), approvalID, account.Number, status, number, userID, time.Now().UTC())
+
+		if err != nil {
+			return err
+		}
+
+		if status == TransferApprovalRejected {
+			return writeJSON(w, http.StatusOK, approval)
+		}
+
+		transfer, err := newTransfer(r.Context(), s.store, s.rates, approval.FromAccount, approval.ToAccount, approval.Amount)
+
+		if err == nil {
+			transfer.TransferReference = approval.TransferReference
+			err = s.store.Transfer(r.Context(), transfer)
+		}
+
+		if err != nil {
+			approval.Status = TransferApprovalFailed
+			approval.Error = publicErrorMessage(err)
+
+			if updateErr := s.store.UpdateTransferApproval(r.Context(), approval); updateErr != nil {
+				return updateErr
+			}
+
+			return err
+		}
+
+		approval.TransferID = &transfer.ID
+
+		if err := s.store.UpdateTransferApproval(r.Context(), approval); err != nil {
+			return err
+		}
+
+		publishTransferEvents(r.Context(), s.events, s.store, transfer)
+
+		return writeJSON(w, http.StatusOK, approval)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// handleForgotPassword sends a reset token to the holder of the account. It
+// answers the same whether or not the account exists, so it can't be used to
+// find account numbers.
+func (s *APIServer) handleForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	req := new(PasswordForgotRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	acc, err := s.store.GetAccountByNumber(r.Context(), int(req.Number))
+
+	var httpErr *HTTPError
+
+	if errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound {
+		return writeJSON(w, http.StatusAccepted, req)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	reset, token, err := NewPasswordReset(acc.Number, s.passwordResetTTL)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreatePasswordReset(r.Context(), reset); err != nil {
+		return err
+	}
+
+	notification := &Notification{
+		AccountNumber: acc.Number,
+		Subject:       "Reset your password",
+		Body:          fmt.Sprintf("Use this token with POST /password/reset before %s: %s", reset.ExpiresAt.Format(http.TimeFormat), token),
+	}
+
+	if err := s.notifier.Notify(r.Context(), notification); err != nil {
+		slog.ErrorContext(r.Context(), "sending password reset failed", "accountNumber", acc.Number, "error", err)
+	}
+
+	return writeJSON(w, http.StatusAccepted, req)
+}
+
+// handleResetPassword sets a new password with a token sent by
+// handleForgotPassword. The token can be used once, and the account's
+// sessions end like with the reset-password admin command.
+func (s *APIServer) handleResetPassword(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	req := new(PasswordResetRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	acc := new(Account)
+
+	if err := acc.SetPassword(req.Password); err != nil {
+		return err
+	}
+
+	number, err := s.store.RedeemPasswordReset(r.Context(), hashToken(req.Token), acc.EncryptedPassword)
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditPasswordReset, number, nil, nil))
+
+	return writeJSON(w, http.StatusOK, number)
+}
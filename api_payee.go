@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// handleVerifyPayee tells any logged-in customer whether ?name= is the
+// holder of the ?number= account, so they can be warned before paying the
+// wrong one.
+func (s *APIServer) handleVerifyPayee(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	if _, err := getAccountNumberFromToken(r); err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	number, err := strconv.ParseInt(query.Get("number"), 10, 64)
+
+	if err != nil {
+		return badRequestError("invalid account number given %s", query.Get("number"))
+	}
+
+	name := strings.TrimSpace(query.Get("name"))
+
+	if name == "" || utf8.RuneCountInString(name) > maxPayeeNameLength {
+		return badRequestError("name must be between 1 and %d characters", maxPayeeNameLength)
+	}
+
+	if err := s.accountNumbers.Check("number", number); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByNumber(r.Context(), int(number))
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, VerifyPayee(name, account))
+}
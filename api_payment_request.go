@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handlePaymentRequest asks another account to pay the token's account. The
+// payer finds the request under its incoming payment requests.
+func (s *APIServer) handlePaymentRequest(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(PaymentRequestRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if req.FromAccount == requester {
+		errs := FieldErrors{}
+		errs.Add("fromAccount", "must not be the requesting account")
+		return errs.Err()
+	}
+
+	if err := s.accountNumbers.Check("fromAccount", req.FromAccount); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByNumber(r.Context(), int(requester))
+
+	if err != nil {
+		return err
+	}
+
+	if err := account.CheckActive(); err != nil {
+		return err
+	}
+
+	payer, err := s.store.GetAccountByNumber(r.Context(), int(req.FromAccount))
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	request := &PaymentRequest{
+		FromAccount:       payer.Number,
+		ToAccount:         requester,
+		Amount:            req.Amount,
+		Currency:          payer.Currency,
+		Status:            PaymentRequestPending,
+		ExpiresAt:         now.Add(s.paymentRequestTTL),
+		CreatedAt:         now,
+		TransferReference: req.TransferReference,
+	}
+
+	if err := s.store.CreatePaymentRequest(r.Context(), request); err != nil {
+		return err
+	}
+
+	s.events.Publish(r.Context(), &Event{Type: EventPaymentRequestCreated, AccountNumber: payer.Number, Data: request})
+
+	return writeJSON(w, http.StatusCreated, request)
+}
+
+// handleGetPaymentRequests lists the requests the {id} account was asked to
+// pay, or with ?direction=outgoing the ones it made, optionally only those
+// with ?status=.
+func (s *APIServer) handleGetPaymentRequests(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	direction := query.Get("direction")
+
+	if direction != "" && direction != "incoming" && direction != "outgoing" {
+		return badRequestError("direction must be incoming or outgoing")
+	}
+
+	status := PaymentRequestStatus(query.Get("status"))
+
+	switch status {
+	case "", PaymentRequestPending, PaymentRequestAccepted, PaymentRequestDeclined, PaymentRequestExpired:
+	default:
+		return badRequestError("status must be pending, accepted, declined or expired")
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	requests, err := s.store.GetPaymentRequests(r.Context(), account.Number, direction != "outgoing", status, time.Now().UTC(), limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, requests)
+}
+
+// handleDecidePaymentRequest accepts or declines a pending request the {id}
+// account was asked to pay. Accepting makes the transfer, after the checks
+// of POST /transfer; when the transfer fails the request stays pending and
+// the error is returned.
+func (s *APIServer) handleDecidePaymentRequest(status PaymentRequestStatus) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		id, err := getIdFromQueryParams(r)
+
+		if err != nil {
+			return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+		}
+
+		requestID, err := strconv.Atoi(mux.Vars(r)["requestId"])
+
+		if err != nil {
+			return badRequestError("invalid payment request id given %s", mux.Vars(r)["requestId"])
+		}
+
+		// the two-factor code is optional, and so is the body
+		accept := new(PaymentRequestAcceptRequest)
+
+		if status == PaymentRequestAccepted && r.ContentLength != 0 {
+			if err := decodeJSON(r, accept); err != nil {
+				return err
+			}
+		}
+
+		account, err := s.store.GetAccountById(r.Context(), id)
+
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		if status == PaymentRequestDeclined {
+			request, err := s.store.DecidePaymentRequest(r.Context(), requestID, account.Number, status, now)
+
+			if err != nil {
+				return err
+			}
+
+			s.events.Publish(r.Context(), &Event{Type: EventPaymentRequestDeclined, AccountNumber: request.ToAccount, Data: request})
+
+			return writeJSON(w, http.StatusOK, request)
+		}
+
+		request, err := s.store.GetPaymentRequest(r.Context(), requestID, now)
+
+		if err != nil {
+			return err
+		}
+
+		if request.FromAccount != account.Number {
+			return notFoundError("payment request %d not found", requestID)
+		}
+
+		if err := request.CheckDecision(now); err != nil {
+			return err
+		}
+
+		if err := s.checkTransferRequest(r.Context(), account.Number, account.Number, &TransferRequest{ToAccount: int(request.ToAccount), Amount: request.Amount, TOTPCode: accept.TOTPCode}); err != nil {
+			return err
+		}
+
+		transfer, err := newTransfer(r.Context(), s.store, s.rates, account.Number, request.ToAccount, request.Amount)
+
+		if err != nil {
+			return err
+		}
+
+		transfer.TransferReference = request.TransferReference
+
+		review, err := s.fraud.Screen(r.Context(), &FraudCheck{Transfer: transfer, IP: clientIP(r.RemoteAddr), At: now})
+
+		if err != nil {
+			return err
+		}
+
+		if err := checkSecondOwner(r.Context(), s.store, account.Number, transfer.Amount); err != nil {
+			return err
+		}
+
+		request, err = s.store.AcceptPaymentRequest(r.Context(), requestID, account.Number, transfer, now)
+
+		if err != nil {
+			return err
+		}
+
+		recordFraudReview(r.Context(), s.store, review, transfer)
+		publishTransferEvents(r.Context(), s.events, s.store, transfer)
+		s.events.Publish(r.Context(), &Event{Type: EventPaymentRequestAccepted, AccountNumber: request.ToAccount, Data: request})
+
+		return writeJSON(w, http.StatusOK, request)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handlePots(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetPots(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreatePot(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetPots(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	pots, err := s.store.GetPots(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, pots)
+}
+
+func (s *APIServer) handleCreatePot(w http.ResponseWriter, r *http.Request) error {
+	req := new(PotRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	pot := &Pot{AccountNumber: account.Number, CreatedAt: now}
+	pot.Update(req, now)
+
+	if err := s.store.CreatePot(r.Context(), pot); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, pot)
+}
+
+func (s *APIServer) handlePot(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return s.handleGetPot(w, r)
+	case "PUT":
+		return s.handleUpdatePot(w, r)
+	case "DELETE":
+		return s.handleDeletePot(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetPot(w http.ResponseWriter, r *http.Request) error {
+	pot, err := s.potFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, pot)
+}
+
+func (s *APIServer) handleUpdatePot(w http.ResponseWriter, r *http.Request) error {
+	req := new(PotRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	pot, err := s.potFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	pot.Update(req, time.Now().UTC())
+
+	if err := s.store.UpdatePot(r.Context(), pot); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, pot)
+}
+
+// handleDeletePot moves whatever the pot holds back to the account's
+// available balance.
+func (s *APIServer) handleDeletePot(w http.ResponseWriter, r *http.Request) error {
+	pot, err := s.potFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.store.DeletePot(r.Context(), pot.ID, pot.AccountNumber)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, deleted)
+}
+
+// handleMovePotMoney moves the requested amount into the pot when sign is 1,
+// and out of it when sign is -1.
+func (s *APIServer) handleMovePotMoney(sign int64) APIFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "POST" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		req := new(AmountRequest)
+
+		if err := decodeJSON(r, req); err != nil {
+			return err
+		}
+
+		pot, err := s.potFromPath(r)
+
+		if err != nil {
+			return err
+		}
+
+		pot, err = s.store.MovePotMoney(r.Context(), pot.ID, pot.AccountNumber, sign*req.Amount)
+
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, http.StatusOK, pot)
+	}
+}
+
+// handleGetPotsProgress reports the progress of every pot of the account.
+func (s *APIServer) handleGetPotsProgress(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	pots, err := s.store.GetPots(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	progress := make([]*PotProgress, len(pots))
+
+	for i, pot := range pots {
+		progress[i] = pot.Progress()
+	}
+
+	return writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *APIServer) handleGetPotProgress(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	pot, err := s.potFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, pot.Progress())
+}
+
+// accountFromPath loads the {id} account.
+func (s *APIServer) accountFromPath(r *http.Request) (*Account, error) {
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return nil, badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	return s.store.GetAccountById(r.Context(), id)
+}
+
+// potFromPath loads the {potId} pot of the {id} account.
+func (s *APIServer) potFromPath(r *http.Request) (*Pot, error) {
+	potID, err := strconv.Atoi(mux.Vars(r)["potId"])
+
+	if err != nil {
+		return nil, badRequestError("invalid pot id given %s", mux.Vars(r)["potId"])
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetPot(r.Context(), potID, account.Number)
+}
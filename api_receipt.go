@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGetTransferReceipt issues a signed receipt of the {id} transfer to
+// an owner of either side of it or an admin.
+func (s *APIServer) handleGetTransferReceipt(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	if s.receipts == nil {
+		return notFoundError("transfer receipts are not configured")
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	transfer, err := s.visibleTransfer(r.Context(), id, requester)
+
+	if err != nil {
+		return err
+	}
+
+	if !transfer.MovedMoney() {
+		return conflictError("transfer %d is %s, there is no receipt for it", id, transfer.Status)
+	}
+
+	return writeJSON(w, http.StatusOK, s.receipts.Issue(transfer, time.Now()))
+}
+
+// handleVerifyTransferReceipt checks that the bank signed a receipt as it
+// is, and that the transfer it records was not changed since. Only those who
+// may get the receipt may verify it.
+func (s *APIServer) handleVerifyTransferReceipt(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	if s.receipts == nil {
+		return notFoundError("transfer receipts are not configured")
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	receipt := new(TransferReceipt)
+
+	if err := decodeJSON(r, receipt); err != nil {
+		return err
+	}
+
+	transfer, err := s.visibleTransfer(r.Context(), receipt.TransferID, requester)
+
+	if err != nil {
+		return err
+	}
+
+	verification := &TransferReceiptVerification{SignatureValid: s.receipts.Verify(receipt)}
+	verification.RecordMatches = verification.SignatureValid && receipt.Matches(transfer)
+
+	return writeJSON(w, http.StatusOK, verification)
+}
+
+// visibleTransfer loads the id transfer for an owner of either of its
+// accounts or an admin. Other requesters are told it doesn't exist.
+func (s *APIServer) visibleTransfer(ctx context.Context, id int, requester int64) (*Transfer, error) {
+	transfer, err := s.store.GetTransfer(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, number := range []int64{transfer.FromAccount, transfer.ToAccount} {
+		owner, err := isAccountOwner(ctx, s.store, number, requester)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if owner {
+			return transfer, nil
+		}
+	}
+
+	if account, err := s.store.GetAccountByNumber(ctx, int(requester)); err == nil && account.Role == RoleAdmin {
+		return transfer, nil
+	}
+
+	return nil, notFoundError("transfer %d not found", id)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handleReconciliationReports lists the stored reconciliation reports,
+// newest first, on GET and reconciles the ledger right away on POST. Like
+// the nightly run, a POST that finds discrepancies raises an alert.
+func (s *APIServer) handleReconciliationReports(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		report, err := s.reconciler.Reconcile(r.Context())
+
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, http.StatusCreated, report)
+	}
+
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	reports, err := s.store.GetReconciliationReports(r.Context(), limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, reports)
+}
+
+func (s *APIServer) handleGetReconciliationReport(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	report, err := s.store.GetReconciliationReport(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, report)
+}
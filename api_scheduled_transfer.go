@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleScheduledTransfers(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetScheduledTransfers(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleScheduleTransfer(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleScheduleTransfer(w http.ResponseWriter, r *http.Request) error {
+	fromAccount, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(ScheduleTransferRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if req.Recurrence == "" {
+		req.Recurrence = RecurrenceNone
+	}
+
+	switch req.Recurrence {
+	case RecurrenceNone, RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly:
+	default:
+		return validationError("invalid recurrence %s", req.Recurrence)
+	}
+
+	if req.Amount <= 0 {
+		return validationError("invalid amount %d", req.Amount)
+	}
+
+	if req.BeneficiaryID != 0 {
+		if req.ToAccount != 0 {
+			return validationError("set either toAccount or beneficiaryId")
+		}
+
+		beneficiary, err := s.store.GetBeneficiary(r.Context(), req.BeneficiaryID, fromAccount)
+
+		if err != nil {
+			return err
+		}
+
+		req.ToAccount = int(beneficiary.AccountNumber)
+	} else if err := s.accountNumbers.Check("toAccount", int64(req.ToAccount)); err != nil {
+		return err
+	}
+
+	if int64(req.ToAccount) == fromAccount {
+		return validationError("cannot transfer to the same account")
+	}
+
+	now := time.Now().UTC()
+
+	if !req.ExecuteAt.After(now) {
+		return validationError("executeAt must be in the future")
+	}
+
+	if _, err := s.store.GetAccountByNumber(r.Context(), req.ToAccount); err != nil {
+		return err
+	}
+
+	// the cooling-off only matters when the transfer runs
+	if err := checkBeneficiaryCoolingOff(r.Context(), s.store, s.coolingOffAmount, fromAccount, int64(req.ToAccount), req.Amount, req.ExecuteAt); err != nil {
+		return err
+	}
+
+	if err := checkKYCTransferLimit(r.Context(), s.store, s.kycTransferLimit, fromAccount, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkVerifiedEmail(r.Context(), s.store, s.verifiedEmailAmount, fromAccount, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkSecondOwner(r.Context(), s.store, fromAccount, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkTransferStepUp(r.Context(), s.store, s.stepUpAmount, fromAccount, req.Amount, req.TOTPCode); err != nil {
+		return err
+	}
+
+	st := &ScheduledTransfer{
+		FromAccount: fromAccount,
+		ToAccount:   int64(req.ToAccount),
+		Amount:      req.Amount,
+		Recurrence:  req.Recurrence,
+		Status:      ScheduledTransferActive,
+		StartAt:     req.ExecuteAt.UTC(),
+		NextRunAt:   req.ExecuteAt.UTC(),
+		CreatedAt:   now,
+	}
+
+	if err := s.store.CreateScheduledTransfer(r.Context(), st); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, st)
+}
+
+func (s *APIServer) handleGetScheduledTransfers(w http.ResponseWriter, r *http.Request) error {
+	fromAccount, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	transfers, err := s.store.GetScheduledTransfers(r.Context(), fromAccount)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, transfers)
+}
+
+func (s *APIServer) handleCancelScheduledTransfer(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	fromAccount, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	if err := s.store.CancelScheduledTransfer(r.Context(), id, fromAccount); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, id)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleSessions(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetSessions(w, r)
+	}
+
+	if r.Method == "DELETE" {
+		return s.revokeSessions(w, r, 0)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+// handleGetSessions lists the live sessions of the {id} account, marking the
+// one the caller's token was issued to.
+func (s *APIServer) handleGetSessions(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	sessions, err := s.store.GetSessions(r.Context(), account.Number, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	current := getSessionIDFromToken(r)
+
+	for _, session := range sessions {
+		session.Current = current != 0 && session.ID == current
+	}
+
+	return writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *APIServer) handleRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	sessionID, err := strconv.Atoi(mux.Vars(r)["sessionId"])
+
+	if err != nil || sessionID <= 0 {
+		return badRequestError("invalid session id given %s", mux.Vars(r)["sessionId"])
+	}
+
+	return s.revokeSessions(w, r, sessionID)
+}
+
+// revokeSessions revokes session id of the {id} account, or all of them if
+// id is 0, and responds with the sessions it revoked. Their access tokens
+// are denied until the last of them expires.
+func (s *APIServer) revokeSessions(w http.ResponseWriter, r *http.Request, id int) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	revoked, err := s.store.RevokeSessions(r.Context(), account.Number, id, now, now.Add(s.tokens.accessTokenTTL))
+
+	if err != nil {
+		return err
+	}
+
+	if len(revoked) > 0 {
+		recordAudit(r.Context(), s.store, newAuditEntry(r, AuditSessionsRevoked, account.Number, nil, revoked))
+	}
+
+	return writeJSON(w, http.StatusOK, revoked)
+}
+
+// requestDevice is the device a request is made from.
+func requestDevice(r *http.Request) Device {
+	return NewDevice(r.UserAgent(), clientIP(r.RemoteAddr))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *APIServer) handleStandingOrders(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetStandingOrders(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateStandingOrder(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetStandingOrders(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	orders, err := s.store.GetStandingOrders(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, orders)
+}
+
+// handleCreateStandingOrder sets up a standing order from the {id} account,
+// checked like a scheduled transfer. Its first payment is on the first
+// payment date from startDate.
+func (s *APIServer) handleCreateStandingOrder(w http.ResponseWriter, r *http.Request) error {
+	req := new(StandingOrderRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	if req.BeneficiaryID != 0 {
+		beneficiary, err := s.store.GetBeneficiary(r.Context(), req.BeneficiaryID, account.Number)
+
+		if err != nil {
+			return err
+		}
+
+		req.ToAccount = int(beneficiary.AccountNumber)
+	} else if err := s.accountNumbers.Check("toAccount", int64(req.ToAccount)); err != nil {
+		return err
+	}
+
+	if int64(req.ToAccount) == account.Number {
+		return validationError("cannot transfer to the same account")
+	}
+
+	if _, err := s.store.GetAccountByNumber(r.Context(), req.ToAccount); err != nil {
+		return err
+	}
+
+	start, _ := time.Parse(time.DateOnly, req.StartDate)
+
+	if err := checkBeneficiaryCoolingOff(r.Context(), s.store, s.coolingOffAmount, account.Number, int64(req.ToAccount), req.Amount, start); err != nil {
+		return err
+	}
+
+	if err := checkKYCTransferLimit(r.Context(), s.store, s.kycTransferLimit, account.Number, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkVerifiedEmail(r.Context(), s.store, s.verifiedEmailAmount, account.Number, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkSecondOwner(r.Context(), s.store, account.Number, req.Amount); err != nil {
+		return err
+	}
+
+	if err := checkTransferStepUp(r.Context(), s.store, s.stepUpAmount, account.Number, req.Amount, req.TOTPCode); err != nil {
+		return err
+	}
+
+	holidays, err := s.store.GetHolidays(r.Context())
+
+	if err != nil {
+		return err
+	}
+
+	order := &StandingOrder{
+		FromAccount: account.Number,
+		ToAccount:   int64(req.ToAccount),
+		Amount:      req.Amount,
+		Frequency:   req.Frequency,
+		Status:      StandingOrderActive,
+		StartDate:   start,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	order.schedule(NewBusinessCalendar(holidays), start)
+
+	if err := s.store.CreateStandingOrder(r.Context(), order); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, order)
+}
+
+func (s *APIServer) handleCancelStandingOrder(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["orderId"])
+
+	if err != nil {
+		return badRequestError("invalid standing order id given %s", mux.Vars(r)["orderId"])
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CancelStandingOrder(r.Context(), orderID, account.Number); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, orderID)
+}
+
+func (s *APIServer) handleHolidays(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		holidays, err := s.store.GetHolidays(r.Context())
+
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, http.StatusOK, holidays)
+	}
+
+	if r.Method == "POST" {
+		holiday := new(Holiday)
+
+		if err := decodeJSON(r, holiday); err != nil {
+			return err
+		}
+
+		if err := s.store.AddHoliday(r.Context(), holiday); err != nil {
+			return err
+		}
+
+		return writeJSON(w, http.StatusCreated, holiday)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleDeleteHoliday(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	date := mux.Vars(r)["date"]
+
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return badRequestError("invalid date given %s", date)
+	}
+
+	if err := s.store.DeleteHoliday(r.Context(), date); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, date)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const maxStatementPeriod = 366 * 24 * time.Hour
+
+func (s *APIServer) handleGetStatement(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	from, to, err := getStatementPeriodFromQueryParams(r, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	format, err := checkStatementFormat(r.URL.Query().Get("format"))
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return s.writeStatement(w, r, account, from, to, format)
+}
+
+// handleCreateStatementLink signs a short-lived link that downloads the
+// requested statement of the {id} account without an access token.
+func (s *APIServer) handleCreateStatementLink(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	req := new(StatementLinkRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	from, to, err := parseStatementPeriod(req.From, req.To, now)
+
+	if err != nil {
+		return err
+	}
+
+	format, err := checkStatementFormat(req.Format)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	link, err := s.tokens.CreateStatementLink(&statementScope{AccountNumber: account.Number, From: from, To: to, Format: format}, now)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, link)
+}
+
+// handleDownloadStatement serves the statement a link was signed for. The
+// token in the query is all it checks, as the link may be opened from an
+// email client.
+func (s *APIServer) handleDownloadStatement(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	scope, err := s.tokens.VerifyStatementLink(r.URL.Query().Get("token"))
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByNumber(r.Context(), int(scope.AccountNumber))
+
+	if err != nil {
+		return err
+	}
+
+	// keeps the token out of caches and of the Referer of links in the
+	// statement
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	return s.writeStatement(w, r, account, scope.From, scope.To, scope.Format)
+}
+
+// checkStatementFormat defaults an empty statement format to json.
+func checkStatementFormat(format string) (string, error) {
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "csv" && format != "pdf" {
+		return "", badRequestError("invalid format %s", format)
+	}
+
+	return format, nil
+}
+
+// writeStatement writes the statement of account for the period [from, to)
+// as JSON, with display amounts in the requested locale, or as a CSV or PDF
+// attachment.
+func (s *APIServer) writeStatement(w http.ResponseWriter, r *http.Request, account *Account, from, to time.Time, format string) error {
+	locale, err := requestLocale(r)
+
+	if err != nil {
+		return err
+	}
+
+	opening, err := openingBalance(r.Context(), s.store, account.Number, from)
+
+	if err != nil {
+		return err
+	}
+
+	transactions, err := s.store.GetTransactionsBetween(r.Context(), account.Number, from, to)
+
+	if err != nil {
+		return err
+	}
+
+	statement := NewStatement(account, from, to, opening, transactions)
+
+	if format == "json" {
+		if locale != nil {
+			statement.localize(locale)
+		}
+
+		setLocaleHeaders(w, locale)
+
+		return writeJSON(w, http.StatusOK, statement)
+	}
+
+	buf := new(bytes.Buffer)
+	contentType := "text/csv"
+
+	if format == "csv" {
+		err = writeStatementCSV(buf, statement)
+	} else {
+		contentType = "application/pdf"
+		err = writeStatementPDF(buf, statement)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	first, last := statementPeriod(statement)
+	filename := fmt.Sprintf("statement-%d-%s-%s.%s", account.Number, first, last, format)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	_, err = buf.WriteTo(w)
+
+	return err
+}
+
+// handleGetBalanceHistory returns the account's closing balance of each day
+// of the period, given like a statement's, for balance over time charts.
+// Days are only listed once the materializer has stored them, so today
+// never is.
+func (s *APIServer) handleGetBalanceHistory(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	from, to, err := getStatementPeriodFromQueryParams(r, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	balances, err := s.store.GetClosingBalances(r.Context(), account.Number, from, to)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, balances)
+}
+
+// getStatementPeriodFromQueryParams reads the inclusive from and to dates
+// (YYYY-MM-DD) and returns the period as [from, to+1 day). It defaults to the
+// current month up to and including today.
+func getStatementPeriodFromQueryParams(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	return parseStatementPeriod(r.URL.Query().Get("from"), r.URL.Query().Get("to"), now)
+}
+
+// parseStatementPeriod is getStatementPeriodFromQueryParams of the dates
+// fromDate and toDate, either of which may be empty.
+func parseStatementPeriod(fromDate, toDate string, now time.Time) (time.Time, time.Time, error) {
+	today := now.Truncate(24 * time.Hour)
+	from := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := today
+
+	if fromDate != "" {
+		t, err := time.Parse(statementDateLayout, fromDate)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid from date %s", fromDate)
+		}
+
+		from = t
+	}
+
+	if toDate != "" {
+		t, err := time.Parse(statementDateLayout, toDate)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid to date %s", toDate)
+		}
+
+		to = t
+	}
+
+	to = to.AddDate(0, 0, 1)
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, validationError("from must not be after to")
+	}
+
+	if to.Sub(from) > maxStatementPeriod {
+		return time.Time{}, time.Time{}, validationError("statement period must not exceed one year")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handleGetStats summarises the open accounts and the balances they hold.
+func (s *APIServer) handleGetStats(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	stats, err := s.store.GetAccountStats(r.Context())
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, stats)
+}
+
+// handleGetTransferStats totals the transfers made per day and source
+// currency.
+func (s *APIServer) handleGetTransferStats(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	from, to, err := getStatsPeriodFromQueryParams(r, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	volume, err := s.store.GetTransferVolume(r.Context(), from, to)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, volume)
+}
+
+func (s *APIServer) handleGetFailedLoginStats(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	from, to, err := getStatsPeriodFromQueryParams(r, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	counts, err := s.store.GetFailedLogins(r.Context(), from, to)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, counts)
+}
+
+func (s *APIServer) handleGetLargestAccounts(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	limit := defaultLargestAccounts
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return badRequestError("invalid limit %s", v)
+		}
+
+		limit = n
+	}
+
+	accounts, err := s.store.GetLargestAccounts(r.Context(), r.URL.Query().Get("currency"), limit)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, accounts)
+}
+
+// getStatsPeriodFromQueryParams reads the from and to days of a stats
+// period, both included, and returns it as [from, to). It defaults to the
+// last defaultStatsDays days, today included.
+func getStatsPeriodFromQueryParams(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	to := now.Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, 1-defaultStatsDays)
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid from date %s", v)
+		}
+
+		from = t
+	}
+
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid to date %s", v)
+		}
+
+		to = t
+	}
+
+	to = to.AddDate(0, 0, 1)
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, validationError("from must not be after to")
+	}
+
+	if to.Sub(from) > maxStatsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, validationError("stats period must not exceed %d days", maxStatsDays)
+	}
+
+	return from, to, nil
+}
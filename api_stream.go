@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleStream sends the account's events as Server-Sent Events until the
+// client goes away. It starts with the current balance and sends the balance
+// again after every event that changed it.
+func (s *APIServer) handleStream(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	events, unsubscribe := s.bus.Subscribe(account.Number)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keeps nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	rc := http.NewResponseController(w)
+
+	if err := rc.Flush(); err != nil {
+		return fmt.Errorf("streaming not supported: %w", err)
+	}
+
+	balance := account.Balance
+
+	if err := writeStreamEvent(rc, w, "", "balance", BalanceData{Balance: balance, Currency: account.Currency}); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+
+			if err := rc.Flush(); err != nil {
+				return nil
+			}
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			// the balance below is what streams care about, low or not
+			if event.Type != EventBalanceLow {
+				if err := writeStreamEvent(rc, w, event.ID, string(event.Type), event); err != nil {
+					return nil
+				}
+			}
+
+			account, err := s.store.GetAccountByNumber(r.Context(), int(account.Number))
+
+			if err != nil {
+				slog.ErrorContext(r.Context(), "loading balance for stream", "error", err)
+				return nil
+			}
+
+			if account.Balance == balance {
+				continue
+			}
+
+			balance = account.Balance
+
+			if err := writeStreamEvent(rc, w, "", "balance", BalanceData{Balance: balance, Currency: account.Currency}); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// writeStreamEvent writes one Server-Sent Event with data as its JSON
+// payload and flushes it to the client.
+func writeStreamEvent(rc *http.ResponseController, w http.ResponseWriter, id, event string, data any) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+
+	return rc.Flush()
+}
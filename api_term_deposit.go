@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGetTermDepositProducts lists the terms deposits can be opened for
+// and their rates.
+func (s *APIServer) handleGetTermDepositProducts(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	return writeJSON(w, http.StatusOK, s.termDeposits)
+}
+
+func (s *APIServer) handleTermDeposits(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetTermDeposits(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateTermDeposit(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleGetTermDeposits(w http.ResponseWriter, r *http.Request) error {
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	deposits, err := s.store.GetTermDeposits(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, deposits)
+}
+
+// handleCreateTermDeposit takes the amount out of the account for one of
+// the offered terms.
+func (s *APIServer) handleCreateTermDeposit(w http.ResponseWriter, r *http.Request) error {
+	req := new(TermDepositRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	product := s.termDepositProduct(req.TermMonths)
+
+	if product == nil {
+		return validationError("no term deposit is offered for %d months", req.TermMonths)
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	deposit := NewTermDeposit(req, product, account, time.Now().UTC())
+	transaction, err := s.store.CreateTermDeposit(r.Context(), deposit)
+
+	if err != nil {
+		return err
+	}
+
+	s.events.Publish(r.Context(), &Event{Type: EventTransactionCreated, AccountNumber: deposit.AccountNumber, Data: transaction})
+	publishBalanceEvent(r.Context(), s.events, deposit.AccountNumber, transaction.Balance, deposit.Currency)
+
+	return writeJSON(w, http.StatusCreated, deposit)
+}
+
+func (s *APIServer) handleGetTermDeposit(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	deposit, err := s.termDepositFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, deposit)
+}
+
+// handleWithdrawTermDeposit pays the deposit back before it matures, less
+// the early withdrawal penalty.
+func (s *APIServer) handleWithdrawTermDeposit(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	deposit, err := s.termDepositFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	deposit, transaction, err := s.store.CloseTermDeposit(r.Context(), deposit.ID, deposit.AccountNumber, time.Now().UTC(), s.termDepositPenaltyDays)
+
+	if err != nil {
+		return err
+	}
+
+	s.events.Publish(r.Context(), &Event{Type: EventTransactionCreated, AccountNumber: deposit.AccountNumber, Data: transaction})
+	publishBalanceEvent(r.Context(), s.events, deposit.AccountNumber, transaction.Balance, deposit.Currency)
+
+	return writeJSON(w, http.StatusOK, deposit)
+}
+
+func (s *APIServer) termDepositProduct(months int) *TermDepositProduct {
+	for _, product := range s.termDeposits {
+		if product.TermMonths == months {
+			return product
+		}
+	}
+
+	return nil
+}
+
+// termDepositFromPath loads the {depositId} term deposit of the {id}
+// account.
+func (s *APIServer) termDepositFromPath(r *http.Request) (*TermDeposit, error) {
+	depositID, err := strconv.Atoi(mux.Vars(r)["depositId"])
+
+	if err != nil {
+		return nil, badRequestError("invalid term deposit id given %s", mux.Vars(r)["depositId"])
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetTermDeposit(r.Context(), depositID, account.Number)
+}
@@ -0,0 +1,634 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAPI struct {
+	t       *testing.T
+	store   *MemoryStore
+	server  *APIServer
+	handler http.Handler
+	teller  string
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	return newTestAPIWithStepUp(t, 0)
+}
+
+func newTestAPIWithStepUp(t *testing.T, stepUpAmount int64) *testAPI {
+	cfg := testConfig()
+	cfg.TOTPStepUpAmount = stepUpAmount
+
+	return newTestAPIWithConfig(t, cfg)
+}
+
+func newTestAPIWithConfig(t *testing.T, cfg *Config) *testAPI {
+	store := NewMemoryStore()
+	rates, err := NewStaticRateProvider(defaultExchangeRates)
+	require.Nil(t, err)
+
+	bus := NewEventBus()
+
+	server := NewAPIServer(cfg, store, rates, publishers{NewWebhookDispatcher(store), bus, NewPotSweeper(store), NewNotificationDispatcher(store, newChannelNotifiers(cfg)), NewAMLMonitor(cfg, store)}, bus, nil)
+	handler, err := server.routes()
+	require.Nil(t, err)
+
+	return &testAPI{t: t, store: store, server: server, handler: handler}
+}
+
+func (a *testAPI) createAccount(firstName, password string) *Account {
+	return a.createAccountWithRole(firstName, password, RoleCustomer)
+}
+
+func (a *testAPI) createAccountWithRole(firstName, password string, role Role) *Account {
+	acc, err := NewAccount(firstName, "Test", password)
+	require.Nil(a.t, err)
+	acc.Role = role
+	require.Nil(a.t, a.store.CreateAccount(context.Background(), acc))
+
+	return acc
+}
+
+// tellerToken logs in the admin that books cash deposits and withdrawals,
+// created on first use.
+func (a *testAPI) tellerToken() string {
+	if a.teller == "" {
+		teller := a.createAccountWithRole("Teller", "teller-pw", RoleAdmin)
+		a.teller = a.login(teller, "teller-pw")
+	}
+
+	return a.teller
+}
+
+func (a *testAPI) login(acc *Account, password string) string {
+	rec := a.do("POST", "/login", "", LoginRequest{Number: acc.Number, Password: password})
+	require.Equal(a.t, http.StatusOK, rec.Code, rec.Body.String())
+
+	resp := new(LoginResponse)
+	require.Nil(a.t, json.NewDecoder(rec.Body).Decode(resp))
+
+	return resp.Token
+}
+
+func (a *testAPI) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	var payload []byte
+
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		require.Nil(a.t, err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+
+	if token != "" {
+		req.Header.Set("x-jwt-token", token)
+	}
+
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAPIDepositAndTransfer(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+
+	// holders can't credit their own accounts
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", token, AmountRequest{Amount: 1000})
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/account/"+strconv.Itoa(alice.ID)+"/deposit", token, AmountRequest{Amount: 1000})
+	assert.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, AuditCashDeposited, entries[0].Action)
+	assert.Equal(t, alice.Number, entries[0].AccountNumber)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 400})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 1000})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	mistyped := bob.Number/10*10 + (bob.Number%10+1)%10
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(mistyped), Amount: 100})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "is not a valid account number")
+
+	from, _ := api.store.GetAccountById(context.Background(), alice.ID)
+	to, _ := api.store.GetAccountById(context.Background(), bob.ID)
+
+	assert.Equal(t, int64(600), from.Balance)
+	assert.Equal(t, int64(400), to.Balance)
+}
+
+func TestAPIHealth(t *testing.T) {
+	api := newTestAPI(t)
+
+	rec := api.do("GET", "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+// unreadyStore is a store with an unreachable database.
+type unreadyStore struct {
+	*MemoryStore
+}
+
+func (s unreadyStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func (s unreadyStore) PendingMigrations(ctx context.Context) ([]int, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestAPIReady(t *testing.T) {
+	api := newTestAPI(t)
+
+	rec := api.do("GET", "/readyz", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok","checks":{"database":{"status":"ok"},"migrations":{"status":"ok"},"jwtSecret":{"status":"ok"}}}`, rec.Body.String())
+
+	store := unreadyStore{NewMemoryStore()}
+	handler, err := NewAPIServer(testConfig(), store, nil, nil, NewEventBus(), nil).routes()
+	require.Nil(t, err)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"unavailable","checks":{"database":{"status":"fail","error":"connection refused"},"migrations":{"status":"fail","error":"connection refused"},"jwtSecret":{"status":"ok"}}}`, rec.Body.String())
+
+	// liveness doesn't depend on the database
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAPIRequiresOwnToken(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+
+	rec := api.do("GET", "/account/"+strconv.Itoa(bob.ID), api.login(alice, "alice-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(bob.ID), "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAPIAccountStatus(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/freeze", token, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/freeze", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 400})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeAccountInactive))
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/withdraw", api.tellerToken(), AmountRequest{Amount: 100})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/close", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/unfreeze", adminToken, nil)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	approval := new(AdminApproval)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(approval))
+
+	checker := api.createAccountWithRole("Checker", "checker-pw", RoleAdmin)
+	rec = api.do("POST", "/admin/approvals/"+strconv.Itoa(approval.ID)+"/approve", api.login(checker, "checker-pw"), nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("DELETE", "/account/"+strconv.Itoa(alice.ID), token, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/withdraw", api.tellerToken(), AmountRequest{Amount: 1000})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/close", adminToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", api.login(bob, "bob-pw"), TransferRequest{ToAccount: int(alice.Number), Amount: 1})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestAPIDeleteAndRestoreAccount(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	bobToken := api.login(bob, "bob-pw")
+	adminToken := api.login(admin, "admin-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/withdraw", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("DELETE", "/account/"+strconv.Itoa(alice.ID), token, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	_, err := api.store.GetAccountById(context.Background(), alice.ID)
+	assert.True(t, isNotFound(err))
+
+	rec = api.do("GET", "/account", adminToken, nil)
+	assert.NotContains(t, rec.Body.String(), `"firstName":"Alice"`)
+
+	rec = api.do("POST", "/transfer", bobToken, TransferRequest{ToAccount: int(alice.Number), Amount: 1})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/restore", bobToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/restore", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "deletedAt")
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/restore", adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// the ledger history survives the round trip
+	transactions, _ := api.store.GetTransactions(context.Background(), alice.Number, 10, 0)
+	assert.Len(t, transactions, 2)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(bob.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", bobToken, TransferRequest{ToAccount: int(alice.Number), Amount: 1})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestAPIAuthorizeAndCapture(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer/authorize", token, TransferRequest{ToAccount: int(bob.Number), Amount: 700})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	hold := new(Hold)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(hold))
+	assert.Equal(t, HoldAuthorized, hold.Status)
+
+	// the held amount can't be spent twice
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 400})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = api.do("POST", "/transfer/"+strconv.Itoa(hold.ID)+"/capture", api.login(bob, "bob-pw"), nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("POST", "/transfer/"+strconv.Itoa(hold.ID)+"/capture", token, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer/"+strconv.Itoa(hold.ID)+"/capture", token, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	from, _ := api.store.GetAccountById(context.Background(), alice.ID)
+	to, _ := api.store.GetAccountById(context.Background(), bob.ID)
+
+	assert.Equal(t, int64(300), from.Balance)
+	assert.Equal(t, int64(0), from.HeldBalance)
+	assert.Equal(t, int64(700), to.Balance)
+}
+
+func TestAPIBeneficiaries(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	beneficiaries := "/account/" + strconv.Itoa(alice.ID) + "/beneficiaries"
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 500000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", beneficiaries, token, BeneficiaryRequest{Name: "Bob Test", AccountNumber: bob.Number, Nickname: "landlord"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	beneficiary := new(Beneficiary)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(beneficiary))
+	assert.True(t, beneficiary.CoolingOffUntil.After(beneficiary.CreatedAt))
+
+	rec = api.do("POST", beneficiaries, token, BeneficiaryRequest{Name: "Bob again", AccountNumber: bob.Number})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("GET", beneficiaries, token, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"nickname":"landlord"`)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{BeneficiaryID: beneficiary.ID, Amount: 1000})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// large transfers wait for the cooling-off, however the payee is named
+	rec = api.do("POST", "/transfer", token, TransferRequest{BeneficiaryID: beneficiary.ID, Amount: 200000})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeCoolingOff))
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 200000})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", "/transfer", api.login(bob, "bob-pw"), TransferRequest{BeneficiaryID: beneficiary.ID, Amount: 1})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("DELETE", beneficiaries+"/"+strconv.Itoa(beneficiary.ID), token, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{BeneficiaryID: beneficiary.ID, Amount: 1000})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	to, _ := api.store.GetAccountById(context.Background(), bob.ID)
+	assert.Equal(t, int64(1000), to.Balance)
+}
+
+func TestAPIBearerToken(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(admin, "admin-pw")
+
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header = header
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := serve("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/freeze", http.Header{"Authorization": {"Bearer " + token}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, AuditAccountFrozen, entries[0].Action)
+	assert.Equal(t, TokenSourceBearer, entries[0].TokenSource)
+
+	path := "/account/" + strconv.Itoa(admin.ID)
+
+	rec = serve("GET", path, http.Header{"Authorization": {"bearer " + token}, "X-Jwt-Token": {"stale"}})
+	assert.Equal(t, http.StatusOK, rec.Code, "the Bearer token wins")
+
+	rec = serve("GET", path, http.Header{"Authorization": {"Bearer stale"}, "X-Jwt-Token": {token}})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "even when it is invalid")
+
+	rec = serve("GET", path, http.Header{"Authorization": {"Basic YWRtaW46YWRtaW4="}, "X-Jwt-Token": {token}})
+	assert.Equal(t, http.StatusOK, rec.Code, "other schemes fall back to x-jwt-token")
+}
+
+func TestAPIAuditLog(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	adminToken := api.login(admin, "admin-pw")
+
+	rec := api.do("POST", "/account", "", AccountRequest{FirstName: "Alice", LastName: "Test", Password: "alice-pw", Currency: "USD", Type: AccountChecking})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	alice := new(Account)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(alice))
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/freeze", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/audit", api.login(alice, "alice-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/admin/audit", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	entries := []*AuditEntry{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&entries))
+	require.Len(t, entries, 3)
+
+	frozen, failed, created := entries[0], entries[1], entries[2]
+
+	assert.Equal(t, AuditAccountFrozen, frozen.Action)
+	assert.Equal(t, &admin.Number, frozen.Actor)
+	assert.Equal(t, "192.0.2.1", frozen.IP)
+	assert.Equal(t, TokenSourceHeader, frozen.TokenSource)
+	assert.Contains(t, string(frozen.Before), `"status":"active"`)
+	assert.Contains(t, string(frozen.After), `"status":"frozen"`)
+
+	assert.Equal(t, AuditLoginFailed, failed.Action)
+	assert.Equal(t, alice.Number, failed.AccountNumber)
+	assert.Nil(t, failed.Actor)
+	assert.Empty(t, failed.TokenSource)
+
+	assert.Equal(t, AuditAccountCreated, created.Action)
+	assert.Nil(t, created.Before)
+	assert.NotContains(t, string(created.After), "alice-pw")
+}
+
+func TestAPIAccountMetadata(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+	value := func(s string) *string { return &s }
+
+	rec := api.do("PATCH", path, token, AccountPatchRequest{Metadata: map[string]*string{"crm_id": value("42"), "segment": value("retail")}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	account := new(Account)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(account))
+	assert.Equal(t, map[string]string{"crm_id": "42", "segment": "retail"}, account.Metadata)
+
+	rec = api.do("PATCH", path, token, AccountPatchRequest{Metadata: map[string]*string{"segment": nil, "tier": value("gold")}, Version: account.Version})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	account = new(Account)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(account))
+	assert.Equal(t, map[string]string{"crm_id": "42", "tier": "gold"}, account.Metadata, "null removes a key, the rest are kept")
+
+	rec = api.do("PATCH", path, token, AccountPatchRequest{Metadata: map[string]*string{"tier": value("silver")}, Version: account.Version - 1})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("PATCH", path, token, AccountPatchRequest{Metadata: map[string]*string{"crm id": value("42")}})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = api.do("PATCH", "/account/"+strconv.Itoa(bob.ID), token, AccountPatchRequest{Metadata: map[string]*string{"crm_id": value("43")}})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/account?metadata[crm_id]=42&metadata[tier]=gold", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var accounts []*Account
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&accounts))
+	require.Len(t, accounts, 1)
+	assert.Equal(t, alice.Number, accounts[0].Number)
+
+	rec = api.do("GET", "/account?metadata[crm_id]=43", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "[]\n", rec.Body.String())
+
+	rec = api.do("GET", "/account?metadata[crm%20id]=42", "", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAPICreateAccountWithInitialDeposit(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+
+	_, err := api.store.Deposit(context.Background(), alice.Number, 10000, 0)
+	require.Nil(t, err)
+
+	request := func(deposit *InitialDeposit) AccountRequest {
+		return AccountRequest{FirstName: "Alice", LastName: "Test", Password: "alice-pw", Currency: "USD", Type: AccountSavings, InitialDeposit: deposit}
+	}
+
+	rec := api.do("POST", "/account", "", request(&InitialDeposit{Source: "cash", Amount: 2500}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "nobody opens an account with money of nowhere")
+
+	rec = api.do("POST", "/account", "", request(&InitialDeposit{Source: InitialDepositTransfer, Amount: 4000, FromAccount: alice.Number}))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "transfers need the owner's token")
+
+	rec = api.do("POST", "/account", token, request(&InitialDeposit{Source: InitialDepositTransfer, Amount: 4000, FromAccount: bob.Number}))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/account", token, request(&InitialDeposit{Source: "cheque", Amount: 4000}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "the source is checked against the OpenAPI document")
+
+	accounts := len(api.store.accounts)
+
+	rec = api.do("POST", "/account", token, request(&InitialDeposit{Source: InitialDepositTransfer, Amount: 20000, FromAccount: alice.Number}))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeInsufficientFunds))
+	assert.Len(t, api.store.accounts, accounts, "no account is left behind without its deposit")
+
+	rec = api.do("POST", "/account", token, request(&InitialDeposit{Source: InitialDepositTransfer, Amount: 4000, FromAccount: alice.Number}))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	funded := new(Account)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(funded))
+	assert.Equal(t, int64(4000), funded.Balance)
+
+	source, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(6000), source.Balance)
+
+	report, err := api.store.CheckLedgerIntegrity(context.Background())
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+type recordingNotifier struct {
+	notifications []*Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification *Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func TestAPIPasswordReset(t *testing.T) {
+	api := newTestAPI(t)
+	notifier := new(recordingNotifier)
+	api.server.notifier = notifier
+	alice := api.createAccount("Alice", "alice-pw")
+
+	rec := api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "alice-pw"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	login := new(LoginResponse)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(login))
+
+	// unknown accounts get the same answer
+	rec = api.do("POST", "/password/forgot", "", PasswordForgotRequest{Number: alice.Number + 1})
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Empty(t, notifier.notifications)
+
+	token := func() string {
+		rec := api.do("POST", "/password/forgot", "", PasswordForgotRequest{Number: alice.Number})
+		require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+		require.NotEmpty(t, notifier.notifications)
+
+		n := notifier.notifications[len(notifier.notifications)-1]
+		assert.Equal(t, alice.Number, n.AccountNumber)
+
+		return n.Body[strings.LastIndex(n.Body, " ")+1:]
+	}
+
+	// a new token replaces the previous one
+	first := token()
+	second := token()
+
+	rec = api.do("POST", "/password/reset", "", PasswordResetRequest{Token: first, Password: "new-pw"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/password/reset", "", PasswordResetRequest{Token: "made-up", Password: "new-pw"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/password/reset", "", PasswordResetRequest{Token: second, Password: "new-pw"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/password/reset", "", PasswordResetRequest{Token: second, Password: "other-pw"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "alice-pw"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	api.login(alice, "new-pw")
+
+	rec = api.do("POST", "/token/refresh", "", RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// expired tokens are refused
+	api.server.passwordResetTTL = -time.Second
+
+	rec = api.do("POST", "/password/reset", "", PasswordResetRequest{Token: token(), Password: "newer-pw"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "expired")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleEnrollTOTP starts two-factor enrollment. The secret only protects the
+// account once a code generated from it is confirmed on /totp/verify.
+func (s *APIServer) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	t, enrollment, err := newTOTP(account)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CreateTOTP(r.Context(), t); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, enrollment)
+}
+
+// handleVerifyTOTP enables two-factor authentication once the client proves
+// it set up the secret, and returns the backup codes.
+func (s *APIServer) handleVerifyTOTP(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	req := new(TOTPVerifyRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	t, err := s.store.GetTOTP(r.Context(), account.Number)
+
+	if err != nil {
+		return err
+	}
+
+	if t.EnabledAt != nil {
+		return conflictError("two-factor authentication is already enabled")
+	}
+
+	if !validTOTPCode(t.Secret, req.Code, time.Now()) {
+		return validationError("invalid code")
+	}
+
+	codes, hashes, err := newBackupCodes()
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.EnableTOTP(r.Context(), account.Number, hashes); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, TOTPBackupCodes{BackupCodes: codes})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleExportTransactions streams the {id} account's ledger, oldest first
+// by created_at then id, as NDJSON or, with ?format=csv, as CSV, optionally limited to the ?from=
+// and ?to= days. Entries are written as they are read from the store, so
+// the export takes as long as the client takes to read it.
+func (s *APIServer) handleExportTransactions(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	format := TransactionExportFormat(r.URL.Query().Get("format"))
+
+	if format == "" {
+		format = TransactionExportNDJSON
+	}
+
+	contentType, ok := transactionExportContentTypes[format]
+
+	if !ok {
+		return badRequestError("invalid format %s, use ndjson or csv", format)
+	}
+
+	now := time.Now().UTC()
+	from, to, err := getTransactionExportPeriodFromQueryParams(r, now)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions-%d-%s.%s"`, account.Number, now.Format("20060102T150405Z"), format))
+	w.WriteHeader(http.StatusOK)
+
+	ew, err := newTransactionExportWriter(w, format, account.Currency)
+
+	if err == nil {
+		err = s.store.StreamTransactions(r.Context(), account.Number, from, to, ew.Write)
+	}
+
+	if err == nil {
+		err = ew.Flush()
+	}
+
+	if err != nil {
+		// the status is sent; breaking off the response is the only way
+		// left to tell the client the export is incomplete
+		slog.ErrorContext(r.Context(), "exporting transactions", "account", account.Number, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+// getTransactionExportPeriodFromQueryParams returns the period [from, to)
+// of the ?from= and ?to= days, both inclusive, from the first entry up to
+// now if left out.
+func getTransactionExportPeriodFromQueryParams(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	from, to := time.Time{}, now
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(statementDateLayout, v)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid from date %s", v)
+		}
+
+		from = t
+	}
+
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(statementDateLayout, v)
+
+		if err != nil {
+			return time.Time{}, time.Time{}, badRequestError("invalid to date %s", v)
+		}
+
+		to = t.AddDate(0, 0, 1)
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, validationError("from must not be after to")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type transferAuditSnapshot struct {
+	Status TransferStatus `json:"status"`
+}
+
+// handleGetTransfer shows the {id} transfer, whatever its status, to an
+// owner of either side of it or an admin.
+func (s *APIServer) handleGetTransfer(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	transfer, err := s.visibleTransfer(r.Context(), id, requester)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, transfer)
+}
+
+// handleGetAccountTransfers lists the transfers into and out of the {id}
+// account.
+func (s *APIServer) handleGetAccountTransfers(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	account, err := s.accountFromPath(r)
+
+	if err != nil {
+		return err
+	}
+
+	return s.writeTransfers(w, r, account.Number)
+}
+
+// handleGetAdminTransfers lists every account's transfers, or those of the
+// account query parameter.
+func (s *APIServer) handleGetAdminTransfers(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	var number int64
+
+	if v := r.URL.Query().Get("account"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+
+		if err != nil || n <= 0 {
+			return badRequestError("invalid account %s", v)
+		}
+
+		number = n
+	}
+
+	return s.writeTransfers(w, r, number)
+}
+
+// writeTransfers writes the page of the number account's transfers, or of
+// every account's if 0, filtered by the status, from and to query
+// parameters.
+func (s *APIServer) writeTransfers(w http.ResponseWriter, r *http.Request, number int64) error {
+	filter, err := getTransferFilterFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	filter.AccountNumber = number
+	transfers, err := s.store.GetTransfers(r.Context(), filter)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, transfers)
+}
+
+// getTransferFilterFromQueryParams reads the page, the status and the from
+// and to days (YYYY-MM-DD, both included) of a transfer listing. Either day
+// may be left out to leave that end open.
+func getTransferFilterFromQueryParams(r *http.Request) (*TransferFilter, error) {
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	filter := &TransferFilter{Status: TransferStatus(query.Get("status")), Limit: limit, Offset: offset}
+
+	switch filter.Status {
+	case "", TransferPending, TransferCompleted, TransferFailed, TransferReversed:
+	default:
+		return nil, badRequestError("invalid status %s", filter.Status)
+	}
+
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.DateOnly, v); err != nil {
+			return nil, badRequestError("invalid from date %s", v)
+		}
+	}
+
+	if v := query.Get("to"); v != "" {
+		to, err := time.Parse(time.DateOnly, v)
+
+		if err != nil {
+			return nil, badRequestError("invalid to date %s", v)
+		}
+
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, validationError("from must not be after to")
+	}
+
+	return filter, nil
+}
+
+// handleReverseTransfer moves the money of the {id} completed transfer
+// back, at the amounts it was made for.
+func (s *APIServer) handleReverseTransfer(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	transfer, err := s.store.ReverseTransfer(r.Context(), id, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	recordAudit(r.Context(), s.store, newAuditEntry(r, AuditTransferReversed, transfer.FromAccount, transferAuditSnapshot{Status: TransferCompleted}, transferAuditSnapshot{Status: transfer.Status}))
+	publishTransferReversedEvents(r.Context(), s.events, s.store, transfer)
+
+	return writeJSON(w, http.StatusOK, transfer)
+}
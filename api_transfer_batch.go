@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handleTransferBatch executes up to maxTransferBatchSize transfers from one
+// account, e.g. a payroll run. Every transfer is checked before any is
+// executed; see TransferBatchRepository for how atomic and partial batches
+// run.
+func (s *APIServer) handleTransferBatch(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(TransferBatchRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	fromAccount, err := s.transferSource(r.Context(), requester, &TransferRequest{FromAccount: req.FromAccount})
+
+	if err != nil {
+		return err
+	}
+
+	if err := req.ValidateFrom(fromAccount); err != nil {
+		return err
+	}
+
+	if err := checkSecondOwner(r.Context(), s.store, fromAccount, req.total()); err != nil {
+		return err
+	}
+
+	if err := checkTransferStepUp(r.Context(), s.store, s.stepUpAmount, requester, req.total(), req.TOTPCode); err != nil {
+		return err
+	}
+
+	batch, err := s.newTransferBatch(r.Context(), fromAccount, req, clientIP(r.RemoteAddr))
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.TransferBatch(r.Context(), batch); err != nil {
+		return err
+	}
+
+	for _, item := range batch.Items {
+		if item.Status == TransferBatchItemCompleted {
+			recordFraudReview(r.Context(), s.store, item.review, item.transfer)
+			publishTransferEvents(r.Context(), s.events, s.store, item.transfer)
+		}
+	}
+
+	return writeJSON(w, http.StatusCreated, batch)
+}
+
+// handleGetTransferBatch returns a batch to the owners of the account it was
+// paid from.
+func (s *APIServer) handleGetTransferBatch(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	batch, err := s.store.GetTransferBatch(r.Context(), id)
+
+	if err != nil {
+		return err
+	}
+
+	owner, err := isAccountOwner(r.Context(), s.store, batch.FromAccount, requester)
+
+	if err != nil {
+		return err
+	}
+
+	if !owner {
+		return notFoundError("transfer batch %d not found", id)
+	}
+
+	return writeJSON(w, http.StatusOK, batch)
+}
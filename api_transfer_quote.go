@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleTransferQuote prices a transfer from the token's account, or from a
+// joint account it co-owns, without making it. POST /transfer with the
+// quote's id makes it on the quoted terms until the quote expires.
+func (s *APIServer) handleTransferQuote(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	requester, err := getAccountNumberFromToken(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(TransferRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if req.QuoteID != 0 {
+		return validationError("quoteId is only supported by POST /transfer")
+	}
+
+	fromAccount, err := s.transferSource(r.Context(), requester, req)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkAPIKeySource(r, requester, fromAccount); err != nil {
+		return err
+	}
+
+	if err := checkBusinessUserSource(r, requester, fromAccount); err != nil {
+		return err
+	}
+
+	if err := s.resolveTransferRequest(r.Context(), fromAccount, req); err != nil {
+		return err
+	}
+
+	transfer, err := newTransfer(r.Context(), s.store, s.rates, fromAccount, int64(req.ToAccount), req.Amount)
+
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByNumber(r.Context(), int(fromAccount))
+
+	if err != nil {
+		return err
+	}
+
+	if err := account.CheckActive(); err != nil {
+		return err
+	}
+
+	if !account.CanDebit(transfer.Amount) {
+		return insufficientFundsError()
+	}
+
+	quote := NewTransferQuote(transfer, account, s.transferQuoteTTL, time.Now().UTC())
+
+	if err := s.store.CreateTransferQuote(r.Context(), quote); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, quote)
+}
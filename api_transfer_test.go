@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITransferStatus(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	carol := api.createAccount("Carol", "carol-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	aliceToken := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+
+	_, err := api.store.Deposit(context.Background(), alice.Number, 1000, 0)
+	require.Nil(t, err)
+
+	rec := api.do("POST", "/transfer", aliceToken, TransferRequest{ToAccount: int(bob.Number), Amount: 300})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	completed := new(Transfer)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(completed))
+	assert.Equal(t, TransferCompleted, completed.Status)
+	transferPath := "/transfer/" + strconv.Itoa(completed.ID)
+
+	rec = api.do("POST", "/transfer", aliceToken, TransferRequest{ToAccount: int(bob.Number), Amount: 5000})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	transfersPath := "/account/" + strconv.Itoa(alice.ID) + "/transfers"
+	list := func(path, token string) []*Transfer {
+		rec := api.do("GET", path, token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		transfers := []*Transfer{}
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(&transfers))
+
+		return transfers
+	}
+
+	failed := list(transfersPath+"?status=failed", aliceToken)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "insufficient funds", failed[0].FailureReason)
+	assert.Equal(t, int64(5000), failed[0].Amount)
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	assert.Len(t, list(transfersPath+"?from="+today+"&to="+today, aliceToken), 2)
+	assert.Empty(t, list(transfersPath+"?from="+tomorrow, aliceToken))
+
+	rec = api.do("GET", transfersPath+"?status=lost", aliceToken, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = api.do("GET", transferPath, aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"status":"completed"`)
+
+	rec = api.do("GET", transferPath, api.login(carol, "carol-pw"), nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "only the owners of either side see it")
+
+	rec = api.do("POST", "/admin/transfers/"+strconv.Itoa(completed.ID)+"/reverse", aliceToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/admin/transfers/"+strconv.Itoa(failed[0].ID)+"/reverse", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "failed transfers moved nothing")
+
+	rec = api.do("POST", "/admin/transfers/"+strconv.Itoa(completed.ID)+"/reverse", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	reversed := new(Transfer)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(reversed))
+	assert.Equal(t, TransferReversed, reversed.Status)
+	assert.NotNil(t, reversed.ReversedAt)
+
+	rec = api.do("POST", "/admin/transfers/"+strconv.Itoa(completed.ID)+"/reverse", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	for number, balance := range map[int64]int64{alice.Number: 1000, bob.Number: 0} {
+		acc, err := api.store.GetAccountByNumber(context.Background(), int(number))
+		require.Nil(t, err)
+		assert.Equal(t, balance, acc.Balance)
+	}
+
+	bobs := list("/admin/transfers?status=reversed&account="+strconv.FormatInt(bob.Number, 10), adminToken)
+	require.Len(t, bobs, 1)
+	assert.Equal(t, completed.ID, bobs[0].ID)
+
+	report, err := api.store.CheckLedgerIntegrity(context.Background())
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var webhookEventTypes = map[EventType]bool{
+	EventAccountCreated:     true,
+	EventTransferCompleted:  true,
+	EventTransferReversed:   true,
+	EventBalanceLow:         true,
+	EventTransactionCreated: true,
+	EventLoginNewDevice:     true,
+	EventLoginLocked:        true,
+
+	EventPaymentRequestCreated:  true,
+	EventPaymentRequestAccepted: true,
+	EventPaymentRequestDeclined: true,
+}
+
+// webhookOwner returns the account number the webhook routes act on: the
+// {id} account for /account/{id}/webhooks, nil for the admin routes.
+func (s *APIServer) webhookOwner(r *http.Request) (*int64, error) {
+	if _, ok := mux.Vars(r)["id"]; !ok {
+		return nil, nil
+	}
+
+	id, err := getIdFromQueryParams(r)
+
+	if err != nil {
+		return nil, badRequestError("invalid id given %s", mux.Vars(r)["id"])
+	}
+
+	account, err := s.store.GetAccountById(r.Context(), id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &account.Number, nil
+}
+
+func (s *APIServer) handleWebhooks(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "GET" {
+		return s.handleGetWebhooks(w, r)
+	}
+
+	if r.Method == "POST" {
+		return s.handleCreateWebhook(w, r)
+	}
+
+	return methodNotAllowedError(r.Method)
+}
+
+func (s *APIServer) handleCreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	owner, err := s.webhookOwner(r)
+
+	if err != nil {
+		return err
+	}
+
+	req := new(WebhookRequest)
+
+	if err := decodeJSON(r, req); err != nil {
+		return err
+	}
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return validationError("url must be an absolute http(s) URL")
+	}
+
+	if len(req.Events) == 0 {
+		return validationError("at least one event is required")
+	}
+
+	for _, event := range req.Events {
+		if !webhookEventTypes[event] {
+			return validationError("unknown event %s", event)
+		}
+	}
+
+	if req.LowBalanceThreshold == 0 {
+		req.LowBalanceThreshold = defaultLowBalanceThreshold
+	}
+
+	secret, err := newWebhookSecret()
+
+	if err != nil {
+		return err
+	}
+
+	webhook := &Webhook{
+		AccountNumber:       owner,
+		URL:                 req.URL,
+		Secret:              secret,
+		Events:              req.Events,
+		LowBalanceThreshold: req.LowBalanceThreshold,
+		CreatedAt:           time.Now().UTC(),
+	}
+
+	if err := s.store.CreateWebhook(r.Context(), webhook); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, webhook)
+}
+
+func (s *APIServer) handleGetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	owner, err := s.webhookOwner(r)
+
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := s.store.GetWebhooks(r.Context(), owner)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, webhooks)
+}
+
+func (s *APIServer) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	owner, err := s.webhookOwner(r)
+
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := strconv.Atoi(mux.Vars(r)["webhookId"])
+
+	if err != nil {
+		return badRequestError("invalid webhook id given %s", mux.Vars(r)["webhookId"])
+	}
+
+	if err := s.store.DeleteWebhook(r.Context(), webhookID, owner); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, webhookID)
+}
+
+func (s *APIServer) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowedError(r.Method)
+	}
+
+	owner, err := s.webhookOwner(r)
+
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := strconv.Atoi(mux.Vars(r)["webhookId"])
+
+	if err != nil {
+		return badRequestError("invalid webhook id given %s", mux.Vars(r)["webhookId"])
+	}
+
+	limit, offset, err := getPaginationFromQueryParams(r)
+
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := s.store.GetWebhookDeliveries(r.Context(), webhookID, owner, limit, offset)
+
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// newAuditEntry describes action on account by the caller of r.
+func newAuditEntry(r *http.Request, action AuditAction, account int64, before, after any) *AuditEntry {
+	var actor *int64
+
+	if number, err := getAccountNumberFromToken(r); err == nil {
+		actor = &number
+	}
+
+	entry := auditEntry(action, account, actor, clientIP(r.RemoteAddr), before, after)
+	entry.TokenSource = getTokenSource(r)
+
+	return entry
+}
+
+// auditEntry snapshots before and after as JSON, nil leaves them out.
+func auditEntry(action AuditAction, account int64, actor *int64, ip string, before, after any) *AuditEntry {
+	return &AuditEntry{
+		Action:        action,
+		Actor:         actor,
+		AccountNumber: account,
+		IP:            ip,
+		Before:        auditSnapshot(before),
+		After:         auditSnapshot(after),
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+func auditSnapshot(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		slog.Error("snapshotting audit entry", "error", err)
+		return nil
+	}
+
+	return data
+}
+
+// recordAudit stores entry after the action succeeded. A failure is logged
+// rather than returned, since the action can no longer be undone.
+func recordAudit(ctx context.Context, store Storage, entry *AuditEntry) {
+	if entry.RequestID == "" {
+		entry.RequestID = requestIDFromContext(ctx)
+	}
+
+	if err := store.RecordAudit(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "recording audit entry", "action", entry.Action, "account", entry.AccountNumber, "error", err)
+	}
+}
+
+// isLoginFailure tells the errors of login that should be audited from
+// internal errors.
+func isLoginFailure(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.Status == http.StatusUnauthorized
+}
+
+// statusAuditActions maps the status an admin moves an account to onto the
+// action recorded for it.
+var statusAuditActions = map[AccountStatus]AuditAction{
+	AccountFrozen: AuditAccountFrozen,
+	AccountActive: AuditAccountUnfrozen,
+	AccountClosed: AuditAccountClosed,
+}
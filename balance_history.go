@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	closingBalanceInterval = time.Hour
+	// closingBalanceDelay leaves entries dated just before midnight time to
+	// commit before their day is materialized.
+	closingBalanceDelay = 15 * time.Minute
+)
+
+// ClosingBalanceMaterializer stores every account's closing balance once
+// its day is over, catching up on the days it missed while not running.
+type ClosingBalanceMaterializer struct {
+	store Storage
+}
+
+func NewClosingBalanceMaterializer(store Storage) *ClosingBalanceMaterializer {
+	return &ClosingBalanceMaterializer{store: store}
+}
+
+func (m *ClosingBalanceMaterializer) Run(ctx context.Context) {
+	ticker := time.NewTicker(closingBalanceInterval)
+	defer ticker.Stop()
+
+	for {
+		m.materializeDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *ClosingBalanceMaterializer) materializeDue(ctx context.Context, now time.Time) {
+	today := now.Add(-closingBalanceDelay).Truncate(24 * time.Hour)
+
+	day, err := m.store.NextClosingBalanceDay(ctx)
+
+	if err != nil {
+		slog.Error("finding the next day of closing balances", "error", err)
+		return
+	}
+
+	for ; !day.IsZero() && day.Before(today); day = day.AddDate(0, 0, 1) {
+		n, err := m.store.MaterializeClosingBalances(ctx, day)
+
+		if err != nil {
+			slog.Error("materializing closing balances", "error", err, "day", day.Format(statementDateLayout))
+			return
+		}
+
+		slog.Info("closing balances materialized", "day", day.Format(statementDateLayout), "accounts", n)
+	}
+}
+
+// openingBalance is the account's balance at the start of day from, read
+// from the closing balance of the day before when it has been materialized.
+func openingBalance(ctx context.Context, store Storage, number int64, from time.Time) (int64, error) {
+	balances, err := store.GetClosingBalances(ctx, number, from.AddDate(0, 0, -1), from)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(balances) == 1 {
+		return balances[0].Balance, nil
+	}
+
+	return store.GetBalanceAt(ctx, number, from)
+}
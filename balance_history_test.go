@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClosingBalanceMaterializer(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	next, err := store.NextClosingBalanceDay(ctx)
+	require.Nil(t, err)
+	assert.True(t, next.IsZero())
+
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 1, Status: AccountActive, CreatedAt: day(1).Add(9 * time.Hour)}))
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 2, Status: AccountActive, CreatedAt: day(3).Add(9 * time.Hour)}))
+
+	for _, d := range []int{1, 2, 4} {
+		_, err := store.Deposit(ctx, 1, int64(100*d), 0)
+		require.Nil(t, err)
+		store.transactions[len(store.transactions)-1].CreatedAt = day(d).Add(12 * time.Hour)
+	}
+
+	materializer := NewClosingBalanceMaterializer(store)
+
+	// March 4 is not over until the delay has passed
+	materializer.materializeDue(ctx, day(5).Add(closingBalanceDelay-time.Second))
+
+	balances, err := store.GetClosingBalances(ctx, 1, day(1), day(10))
+	require.Nil(t, err)
+	assert.Equal(t, []*ClosingBalance{
+		{AccountNumber: 1, Date: "2024-03-01", Balance: 100},
+		{AccountNumber: 1, Date: "2024-03-02", Balance: 300},
+		{AccountNumber: 1, Date: "2024-03-03", Balance: 300},
+	}, balances)
+
+	balances, err = store.GetClosingBalances(ctx, 2, day(1), day(10))
+	require.Nil(t, err)
+	assert.Len(t, balances, 1, "accounts start the day they are opened")
+
+	materializer.materializeDue(ctx, day(5).Add(closingBalanceDelay))
+
+	balances, err = store.GetClosingBalances(ctx, 1, day(4), day(5))
+	require.Nil(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, int64(700), balances[0].Balance)
+
+	// days are only materialized once
+	n, err := store.MaterializeClosingBalances(ctx, day(4))
+	require.Nil(t, err)
+	assert.Zero(t, n)
+
+	next, err = store.NextClosingBalanceDay(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, day(5), next)
+
+	opening, err := openingBalance(ctx, store, 1, day(3))
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), opening)
+
+	// days not materialized yet are read from the ledger
+	opening, err = openingBalance(ctx, store, 1, day(7))
+	require.Nil(t, err)
+	assert.Equal(t, int64(700), opening)
+}
+
+func TestAPIBalanceHistory(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 500})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+
+	for _, transaction := range api.store.transactions {
+		transaction.CreatedAt = yesterday
+	}
+
+	api.store.accounts[alice.ID].CreatedAt = yesterday
+	NewClosingBalanceMaterializer(api.store).materializeDue(context.Background(), time.Now().UTC().Add(closingBalanceDelay))
+
+	rec = api.do("GET", path+"/balance/history?from="+yesterday.Format(statementDateLayout), token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var balances []*ClosingBalance
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&balances))
+	require.Len(t, balances, 1)
+	assert.Equal(t, yesterday.Format(statementDateLayout), balances[0].Date)
+	assert.Equal(t, int64(500), balances[0].Balance)
+
+	rec = api.do("GET", path+"/balance/history?from=yesterday", token, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
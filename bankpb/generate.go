@@ -0,0 +1,5 @@
+// Package bankpb holds the protobuf definitions of the gRPC API and the code
+// generated from them.
+package bankpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative bank.proto
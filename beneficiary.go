@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// resolveBeneficiary points a validated transfer request that names a
+// beneficiary of from at the beneficiary's account.
+func resolveBeneficiary(ctx context.Context, store BeneficiaryRepository, from int64, req *TransferRequest) error {
+	if req.BeneficiaryID == 0 {
+		return nil
+	}
+
+	beneficiary, err := store.GetBeneficiary(ctx, req.BeneficiaryID, from)
+
+	if err != nil {
+		return err
+	}
+
+	req.ToAccount = int(beneficiary.AccountNumber)
+
+	return nil
+}
+
+// checkBeneficiaryCoolingOff refuses transfers above threshold to an account
+// that from saved as a beneficiary less than the cooling-off period ago,
+// whether or not the transfer names the beneficiary. A threshold of 0
+// disables the check.
+func checkBeneficiaryCoolingOff(ctx context.Context, store BeneficiaryRepository, threshold, from, to, amount int64, now time.Time) error {
+	if threshold <= 0 || amount <= threshold {
+		return nil
+	}
+
+	beneficiaries, err := store.GetBeneficiaries(ctx, from)
+
+	if err != nil {
+		return err
+	}
+
+	for _, beneficiary := range beneficiaries {
+		if beneficiary.AccountNumber == to && now.Before(beneficiary.CoolingOffUntil) {
+			return coolingOffError(beneficiary)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BusinessUserRole is what a user of a business account may do with it.
+// Users split the account's work between them: some only look, some
+// initiate transfers and others approve them.
+type BusinessUserRole string
+
+const (
+	// BusinessUserViewer users only read the account.
+	BusinessUserViewer BusinessUserRole = "viewer"
+	// BusinessUserInitiator users read the account and initiate transfers
+	// from it, which always wait for an approval.
+	BusinessUserInitiator BusinessUserRole = "initiator"
+	// BusinessUserApprover users read the account and approve or reject the
+	// transfers waiting for approval.
+	BusinessUserApprover BusinessUserRole = "approver"
+)
+
+// businessUserReadRoutes are the routes every business user may call.
+var businessUserReadRoutes = []string{
+	"GET /account/{id}",
+	"GET /account/{id}/transactions",
+	"GET /account/{id}/transactions/export",
+	"GET /account/{id}/transfers",
+	"GET /account/{id}/analytics",
+	"GET /account/{id}/events",
+	"GET /account/{id}/balance",
+	"GET /account/{id}/balance/history",
+	"GET /account/{id}/statement",
+	"GET /account/{id}/approvals",
+	"GET /transfer/{id}",
+	"GET /transfer/{id}/receipt",
+}
+
+// businessUserRoutes are the routes each role opens to business users, as a
+// method and a path template without the version prefix, like
+// apiKeyRoutes. Managing the account, its users, logins and keys is left to
+// its holder.
+var businessUserRoutes = map[BusinessUserRole][]string{
+	BusinessUserViewer: businessUserReadRoutes,
+	BusinessUserInitiator: append(slices.Clone(businessUserReadRoutes),
+		"POST /transfer",
+		"POST /transfer/quote",
+	),
+	BusinessUserApprover: append(slices.Clone(businessUserReadRoutes),
+		"POST /account/{id}/approvals/{approvalId}/approve",
+		"POST /account/{id}/approvals/{approvalId}/reject",
+	),
+}
+
+// BusinessUser is a person working on a business account with a login of
+// their own, within their role. Users have no bank account of their own: they
+// log in with the account's number and their username, and their tokens act
+// on the business account.
+type BusinessUser struct {
+	ID            int              `json:"id"`
+	AccountNumber int64            `json:"accountNumber"`
+	Username      string           `json:"username"`
+	Role          BusinessUserRole `json:"role"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	RemovedAt     *time.Time       `json:"removedAt,omitempty"`
+
+	EncryptedPassword string `json:"-"`
+}
+
+// BusinessUserRequest adds a user to a business account.
+type BusinessUserRequest struct {
+	Username string           `json:"username"`
+	Password string           `json:"password"`
+	Role     BusinessUserRole `json:"role"`
+}
+
+// BusinessUserRoleRequest changes the role of a business user.
+type BusinessUserRoleRequest struct {
+	Role BusinessUserRole `json:"role"`
+}
+
+func NewBusinessUser(number int64, req *BusinessUserRequest, now time.Time) (*BusinessUser, error) {
+	encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &BusinessUser{
+		AccountNumber:     number,
+		Username:          req.Username,
+		Role:              req.Role,
+		CreatedAt:         now,
+		EncryptedPassword: string(encryptedPassword),
+	}, nil
+}
+
+func (u *BusinessUser) ValidPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.EncryptedPassword), []byte(password)) == nil
+}
+
+// Allows reports whether the role of u opens the route of method and
+// template.
+func (u *BusinessUser) Allows(method, template string) bool {
+	return slices.Contains(businessUserRoutes[u.Role], method+" "+template)
+}
+
+// loginBusinessUser checks the credentials of a user of the number account,
+// and issues an access token and a refresh token for them. Users don't have
+// two-factor authentication of their own.
+func loginBusinessUser(ctx context.Context, store Storage, tokens *TokenIssuer, number int64, username, password string, device Device) (*LoginResponse, error) {
+	user, err := store.GetBusinessUserByUsername(ctx, number, username)
+
+	if isNotFound(err) {
+		return nil, unauthorizedError("invalid credentials")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.ValidPassword(password) {
+		return nil, unauthorizedError("invalid credentials")
+	}
+
+	acc, err := store.GetAccountByNumber(ctx, int(user.AccountNumber))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return startSession(ctx, store, tokens, acc, user, device)
+}
+
+// checkBusinessUser returns the user the token of number was issued to,
+// unless they have been removed since.
+func checkBusinessUser(ctx context.Context, store BusinessUserRepository, number int64, id int) (*BusinessUser, error) {
+	user, err := store.GetBusinessUser(ctx, id)
+
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	if user == nil || user.AccountNumber != number || user.RemovedAt != nil {
+		return nil, unauthorizedError("business user removed")
+	}
+
+	return user, nil
+}
+
+// withBusinessUser loads the business user of access tokens issued to one,
+// so their role is checked as it is now rather than as it was at login.
+// Like withAPIKey it leaves denying requests to the routes needing a
+// caller: removed users are unauthorized, and users are forbidden the
+// routes outside their role and those of other accounts.
+func withBusinessUser(store Storage) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _ := r.Context().Value(accessTokenKey).(accessToken)
+
+			if token.err != nil || token.userID == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := checkBusinessUser(r.Context(), store, token.number, token.userID)
+
+			if err == nil {
+				err = checkCallerRoute(r, store, user.AccountNumber, user.Allows, "the business user's role doesn't allow %s %s")
+			}
+
+			if err != nil {
+				token.number, token.err = -1, err
+			} else {
+				token.user = user
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accessTokenKey, token)))
+		})
+	}
+}
+
+// getBusinessUserFromToken returns the business user of the request's
+// access token, nil when the account's holder made it.
+func getBusinessUserFromToken(r *http.Request) *BusinessUser {
+	token, _ := r.Context().Value(accessTokenKey).(accessToken)
+
+	if token.err != nil {
+		return nil
+	}
+
+	return token.user
+}
+
+// checkBusinessUserSource denies business users transfers from other
+// accounts than their business account, even those it co-owns.
+func checkBusinessUserSource(r *http.Request, requester, fromAccount int64) error {
+	if getBusinessUserFromToken(r) != nil && fromAccount != requester {
+		return forbiddenError("business users only transfer from their business account")
+	}
+
+	return nil
+}
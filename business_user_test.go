@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessUserAllows(t *testing.T) {
+	viewer := &BusinessUser{Role: BusinessUserViewer}
+	initiator := &BusinessUser{Role: BusinessUserInitiator}
+	approver := &BusinessUser{Role: BusinessUserApprover}
+
+	for _, user := range []*BusinessUser{viewer, initiator, approver} {
+		assert.True(t, user.Allows("GET", "/account/{id}/transactions"), user.Role)
+		assert.False(t, user.Allows("POST", "/account/{id}/close"), user.Role)
+		assert.False(t, user.Allows("POST", "/account/{id}/users"), user.Role)
+	}
+
+	assert.False(t, viewer.Allows("POST", "/transfer"))
+	assert.True(t, initiator.Allows("POST", "/transfer"))
+	assert.False(t, approver.Allows("POST", "/transfer"))
+
+	assert.False(t, initiator.Allows("POST", "/account/{id}/approvals/{approvalId}/approve"))
+	assert.True(t, approver.Allows("POST", "/account/{id}/approvals/{approvalId}/approve"))
+}
+
+func TestMemoryStoreBusinessUsers(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	user, err := NewBusinessUser(42, &BusinessUserRequest{Username: "ian", Password: "ian-pw", Role: BusinessUserInitiator}, now)
+	require.Nil(t, err)
+	require.Nil(t, store.CreateBusinessUser(ctx, user))
+	assert.True(t, user.ValidPassword("ian-pw"))
+
+	err = store.CreateBusinessUser(ctx, &BusinessUser{AccountNumber: 42, Username: "ian", Role: BusinessUserViewer})
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status)
+
+	require.Nil(t, store.CreateBusinessUser(ctx, &BusinessUser{AccountNumber: 43, Username: "ian", Role: BusinessUserViewer}), "usernames are per account")
+
+	before, after, err := store.SetBusinessUserRole(ctx, user.ID, 42, BusinessUserApprover)
+	require.Nil(t, err)
+	assert.Equal(t, BusinessUserInitiator, before.Role)
+	assert.Equal(t, BusinessUserApprover, after.Role)
+
+	_, _, err = store.SetBusinessUserRole(ctx, user.ID, 43, BusinessUserViewer)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+
+	removed, err := store.RemoveBusinessUser(ctx, user.ID, 42, now, now.Add(time.Minute))
+	require.Nil(t, err)
+	assert.NotNil(t, removed.RemovedAt)
+
+	_, err = store.GetBusinessUserByUsername(ctx, 42, "ian")
+	assert.True(t, isNotFound(err))
+
+	users, err := store.GetBusinessUsers(ctx, 42)
+	require.Nil(t, err)
+	assert.Empty(t, users)
+
+	require.Nil(t, store.CreateBusinessUser(ctx, &BusinessUser{AccountNumber: 42, Username: "ian", Role: BusinessUserViewer}), "removed users' usernames can be reused")
+}
+
+func TestAPIBusinessUsers(t *testing.T) {
+	api := newTestAPI(t)
+
+	business, err := NewAccount("Acme", "Ltd", "acme-pw")
+	require.Nil(t, err)
+	business.Type = AccountBusiness
+	require.Nil(t, api.store.CreateAccount(context.Background(), business))
+
+	_, err = api.store.Deposit(context.Background(), business.Number, 100000, 0)
+	require.Nil(t, err)
+
+	alice := api.createAccount("Alice", "alice-pw")
+	payee := api.createAccount("Pat", "pat-pw")
+	holderToken := api.login(business, "acme-pw")
+	path := "/account/" + strconv.Itoa(business.ID)
+
+	rec := api.do("POST", "/account/"+strconv.Itoa(alice.ID)+"/users", api.login(alice, "alice-pw"), BusinessUserRequest{Username: "vic", Password: "vic-pw", Role: BusinessUserViewer})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "only business accounts have users")
+
+	users := map[BusinessUserRole]*BusinessUser{}
+
+	for username, role := range map[string]BusinessUserRole{"vic": BusinessUserViewer, "ian": BusinessUserInitiator, "amy": BusinessUserApprover} {
+		rec = api.do("POST", path+"/users", holderToken, BusinessUserRequest{Username: username, Password: username + "-pw", Role: role})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.NotContains(t, rec.Body.String(), "password")
+
+		user := new(BusinessUser)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(user))
+		users[role] = user
+	}
+
+	rec = api.do("POST", path+"/users", holderToken, BusinessUserRequest{Username: "vic", Password: "pw", Role: BusinessUserViewer})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	loginUser := func(username, password string) (*LoginResponse, int) {
+		rec := api.do("POST", "/login", "", LoginRequest{Number: business.Number, Username: username, Password: password})
+
+		if rec.Code != http.StatusOK {
+			return nil, rec.Code
+		}
+
+		resp := new(LoginResponse)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(resp))
+
+		return resp, rec.Code
+	}
+
+	_, code := loginUser("vic", "acme-pw")
+	assert.Equal(t, http.StatusUnauthorized, code, "users have their own passwords")
+
+	viewer, _ := loginUser("vic", "vic-pw")
+	initiator, _ := loginUser("ian", "ian-pw")
+	approver, _ := loginUser("amy", "amy-pw")
+	require.NotNil(t, viewer)
+	assert.Equal(t, business.Number, viewer.Number)
+	assert.Equal(t, users[BusinessUserViewer].ID, viewer.UserID)
+
+	transfer := func(token string, amount int64) *httptest.ResponseRecorder {
+		return api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(payee.Number), Amount: amount})
+	}
+
+	// every user reads the account, only the holder changes it
+	for _, resp := range []*LoginResponse{viewer, initiator, approver} {
+		rec = api.do("GET", path, resp.Token, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = api.do("POST", path+"/close", resp.Token, CloseAccountRequest{})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = api.do("GET", path+"/users", resp.Token, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "users can't manage users")
+
+		rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID), resp.Token, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	for _, resp := range []*LoginResponse{viewer, approver} {
+		rec = transfer(resp.Token, 1000)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "only initiators initiate")
+	}
+
+	rec = api.do("POST", "/transfer", initiator.Token, TransferRequest{FromAccount: int(alice.Number), ToAccount: int(payee.Number), Amount: 1000})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// initiated transfers always wait for an approval
+	rec = transfer(initiator.Token, 1000)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	approval := new(TransferApproval)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(approval))
+	assert.Equal(t, business.Number, approval.RequestedBy)
+	assert.Equal(t, users[BusinessUserInitiator].ID, approval.RequestedByUser)
+	approvalPath := path + "/approvals/" + strconv.Itoa(approval.ID)
+
+	for _, resp := range []*LoginResponse{initiator, viewer} {
+		rec = api.do("POST", approvalPath+"/approve", resp.Token, nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	rec = api.do("POST", approvalPath+"/approve", approver.Token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(approval))
+	assert.Equal(t, TransferApprovalApproved, approval.Status)
+	assert.Equal(t, users[BusinessUserApprover].ID, approval.DecidedByUser)
+
+	userPath := path + "/users/" + strconv.Itoa(users[BusinessUserViewer].ID)
+
+	rec = api.do("PUT", userPath, holderToken, BusinessUserRoleRequest{Role: BusinessUserInitiator})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = transfer(viewer.Token, 1000)
+	assert.Equal(t, http.StatusAccepted, rec.Code, "roles change at once, without logging in again")
+
+	entries, err := api.store.GetAuditLog(context.Background(), 1, 0)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditUserRoleChanged, entries[0].Action)
+
+	rec = api.do("POST", "/token/refresh", "", RefreshTokenRequest{RefreshToken: viewer.RefreshToken})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	refreshed := new(LoginResponse)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(refreshed))
+	assert.Equal(t, viewer.UserID, refreshed.UserID, "refreshed tokens stay the user's")
+
+	rec = api.do("POST", "/account/"+strconv.Itoa(payee.ID)+"/users", refreshed.Token, BusinessUserRequest{Username: "x", Password: "x", Role: BusinessUserApprover})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("DELETE", userPath, holderToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path, refreshed.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "removed users are logged out")
+
+	_, code = loginUser("vic", "vic-pw")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	rec = api.do("GET", path+"/users", holderToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	listed := []*BusinessUser{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&listed))
+	assert.Len(t, listed, 2)
+}
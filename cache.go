@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/gob"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AccountCache keeps accounts read from the store by number, and which
+// number each account id has, for up to a TTL.
+type AccountCache interface {
+	// Get returns the cached account with number, or nil.
+	Get(ctx context.Context, number int64) (*Account, error)
+	// Number returns the number of the cached account with id, or 0.
+	Number(ctx context.Context, id int) (int64, error)
+	Set(ctx context.Context, acc *Account) error
+	// Invalidate forgets the accounts with numbers, or every account when
+	// none are given.
+	Invalidate(ctx context.Context, numbers ...int64) error
+}
+
+type cacheEntry struct {
+	account *Account
+	expires time.Time
+}
+
+// MemoryAccountCache keeps up to size accounts in process memory, evicting
+// the least recently used. Every instance of the server has its own, so it
+// only sees the writes made through that instance.
+type MemoryAccountCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[int64]*list.Element
+	ids     map[int]int64
+	// lru holds the cacheEntry values, most recently used first.
+	lru *list.List
+}
+
+func NewMemoryAccountCache(size int, ttl time.Duration) *MemoryAccountCache {
+	return &MemoryAccountCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[int64]*list.Element{},
+		ids:     map[int]int64{},
+		lru:     list.New(),
+	}
+}
+
+func (c *MemoryAccountCache) Get(ctx context.Context, number int64) (*Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[number]
+
+	if !ok {
+		return nil, nil
+	}
+
+	entry := e.Value.(*cacheEntry)
+
+	if !c.now().Before(entry.expires) {
+		c.remove(e)
+		return nil, nil
+	}
+
+	c.lru.MoveToFront(e)
+	copied := *entry.account
+
+	return &copied, nil
+}
+
+func (c *MemoryAccountCache) Number(ctx context.Context, id int) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ids[id], nil
+}
+
+func (c *MemoryAccountCache) Set(ctx context.Context, acc *Account) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[acc.Number]; ok {
+		c.remove(e)
+	}
+
+	copied := *acc
+	c.entries[acc.Number] = c.lru.PushFront(&cacheEntry{account: &copied, expires: c.now().Add(c.ttl)})
+	c.ids[acc.ID] = acc.Number
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+
+	return nil
+}
+
+func (c *MemoryAccountCache) Invalidate(ctx context.Context, numbers ...int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(numbers) == 0 {
+		c.entries = map[int64]*list.Element{}
+		c.ids = map[int]int64{}
+		c.lru.Init()
+
+		return nil
+	}
+
+	for _, number := range numbers {
+		if e, ok := c.entries[number]; ok {
+			c.remove(e)
+		}
+	}
+
+	return nil
+}
+
+func (c *MemoryAccountCache) remove(e *list.Element) {
+	acc := c.lru.Remove(e).(*cacheEntry).account
+	delete(c.entries, acc.Number)
+	delete(c.ids, acc.ID)
+}
+
+// RedisAccountCache shares the cache between all instances of the server,
+// so writes made through one invalidate it for all of them. Accounts are
+// stored with their password hash, for logins, so Redis must be as
+// protected as the database.
+type RedisAccountCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisAccountCache(client *redis.Client, ttl time.Duration) *RedisAccountCache {
+	return &RedisAccountCache{client: client, ttl: ttl}
+}
+
+func accountCacheKey(number int64) string {
+	return "account:" + strconv.FormatInt(number, 10)
+}
+
+func accountIDCacheKey(id int) string {
+	return "account-id:" + strconv.Itoa(id)
+}
+
+func (c *RedisAccountCache) Get(ctx context.Context, number int64) (*Account, error) {
+	data, err := c.client.Get(ctx, accountCacheKey(number)).Bytes()
+
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := new(Account)
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(acc); err != nil {
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+func (c *RedisAccountCache) Number(ctx context.Context, id int) (int64, error) {
+	number, err := c.client.Get(ctx, accountIDCacheKey(id)).Int64()
+
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return number, err
+}
+
+// Set encodes with gob, as JSON leaves the password hash out.
+func (c *RedisAccountCache) Set(ctx context.Context, acc *Account) error {
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(acc); err != nil {
+		return err
+	}
+
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, accountCacheKey(acc.Number), buf.Bytes(), c.ttl)
+		pipe.Set(ctx, accountIDCacheKey(acc.ID), acc.Number, c.ttl)
+
+		return nil
+	})
+
+	return err
+}
+
+// Invalidate leaves the ids' numbers, which never change.
+func (c *RedisAccountCache) Invalidate(ctx context.Context, numbers ...int64) error {
+	if len(numbers) > 0 {
+		keys := make([]string, len(numbers))
+
+		for i, number := range numbers {
+			keys[i] = accountCacheKey(number)
+		}
+
+		return c.client.Del(ctx, keys...).Err()
+	}
+
+	iter := c.client.Scan(ctx, 0, "account:*", 1000).Iterator()
+
+	for iter.Next(ctx) {
+		if err := c.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+// cachedStore serves GetAccountById and GetAccountByNumber, which every
+// authenticated request and balance read makes, from an AccountCache.
+// Balances change only by journal entries, so it invalidates the accounts
+// of each one the store commits, whichever write committed it. The writes
+// that change other fields of accounts, such as their status or held
+// balance, are overridden to invalidate those. A read racing a write can
+// still cache the account as it was before the write; the TTL bounds how
+// long. The store's own checks never rely on the cache, so a stale account
+// can only be shown, not spent from.
+type cachedStore struct {
+	Storage
+	cache AccountCache
+}
+
+func cacheStore(store Storage, cache AccountCache) Storage {
+	s := &cachedStore{Storage: store, cache: cache}
+	store.OnJournalCommitted(s.journalCommitted)
+
+	return s
+}
+
+// journalCommitted invalidates the accounts the entry books to. Those of
+// the bank's own books aren't customer accounts, so an entry only booking
+// to them invalidates none rather than all.
+func (s *cachedStore) journalCommitted(ctx context.Context, entry *JournalEntry) {
+	if numbers := entry.accountNumbers(); len(numbers) > 0 {
+		s.invalidate(ctx, numbers...)
+	}
+}
+
+func (s *cachedStore) GetAccountById(ctx context.Context, id int) (*Account, error) {
+	number, err := s.cache.Number(ctx, id)
+
+	if err != nil {
+		slog.WarnContext(ctx, "reading the account cache", "error", err)
+	}
+
+	if number != 0 {
+		if acc := s.cached(ctx, number); acc != nil {
+			return acc, nil
+		}
+	}
+
+	accountCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+	acc, err := s.Storage.GetAccountById(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.set(ctx, acc)
+
+	return acc, nil
+}
+
+func (s *cachedStore) GetAccountByNumber(ctx context.Context, number int) (*Account, error) {
+	if acc := s.cached(ctx, int64(number)); acc != nil {
+		return acc, nil
+	}
+
+	accountCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+	acc, err := s.Storage.GetAccountByNumber(ctx, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.set(ctx, acc)
+
+	return acc, nil
+}
+
+// cached returns the cached account with number, or nil. A failing cache is
+// logged and read through rather than failing the request.
+func (s *cachedStore) cached(ctx context.Context, number int64) *Account {
+	acc, err := s.cache.Get(ctx, number)
+
+	if err != nil {
+		slog.WarnContext(ctx, "reading the account cache", "error", err)
+		return nil
+	}
+
+	if acc != nil {
+		accountCacheRequestsTotal.WithLabelValues("hit").Inc()
+	}
+
+	return acc
+}
+
+func (s *cachedStore) set(ctx context.Context, acc *Account) {
+	if err := s.cache.Set(ctx, acc); err != nil {
+		slog.WarnContext(ctx, "writing the account cache", "error", err)
+	}
+}
+
+// invalidate is called after every write, whether it failed or not, as
+// some fail after changing accounts. Without numbers it forgets every
+// account.
+func (s *cachedStore) invalidate(ctx context.Context, numbers ...int64) {
+	if err := s.cache.Invalidate(ctx, numbers...); err != nil {
+		slog.ErrorContext(ctx, "invalidating the account cache, accounts may be stale until their TTL", "numbers", numbers, "error", err)
+	}
+}
+
+// invalidateID invalidates the account with id, if it is cached.
+func (s *cachedStore) invalidateID(ctx context.Context, id int) {
+	number, err := s.cache.Number(ctx, id)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "invalidating the account cache, accounts may be stale until their TTL", "id", id, "error", err)
+		return
+	}
+
+	if number != 0 {
+		s.invalidate(ctx, number)
+	}
+}
+
+func (s *cachedStore) UpdateAccountStatus(ctx context.Context, id int, status AccountStatus) (*Account, error) {
+	defer s.invalidateID(ctx, id)
+	return s.Storage.UpdateAccountStatus(ctx, id, status)
+}
+
+func (s *cachedStore) DeleteAccount(ctx context.Context, id int, now time.Time) (*Account, error) {
+	defer s.invalidateID(ctx, id)
+	return s.Storage.DeleteAccount(ctx, id, now)
+}
+
+func (s *cachedStore) CloseAccount(ctx context.Context, number, sweepTo int64) (*AccountClosure, error) {
+	defer s.invalidate(ctx, number)
+	return s.Storage.CloseAccount(ctx, number, sweepTo)
+}
+
+func (s *cachedStore) RestoreAccount(ctx context.Context, id int) (*Account, error) {
+	defer s.invalidateID(ctx, id)
+	return s.Storage.RestoreAccount(ctx, id)
+}
+
+func (s *cachedStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	defer s.invalidateID(ctx, acc.ID)
+	return s.Storage.UpdateAccount(ctx, acc)
+}
+
+func (s *cachedStore) UpdateAccountMetadata(ctx context.Context, id int, patch map[string]*string, version int) (*Account, error) {
+	defer s.invalidateID(ctx, id)
+	return s.Storage.UpdateAccountMetadata(ctx, id, patch, version)
+}
+
+func (s *cachedStore) UpdateAccountLimits(ctx context.Context, id int, limits AccountLimits) error {
+	defer s.invalidateID(ctx, id)
+	return s.Storage.UpdateAccountLimits(ctx, id, limits)
+}
+
+func (s *cachedStore) ResetPassword(ctx context.Context, number int64, encryptedPassword string) error {
+	defer s.invalidate(ctx, number)
+	return s.Storage.ResetPassword(ctx, number, encryptedPassword)
+}
+
+func (s *cachedStore) RedeemPasswordReset(ctx context.Context, tokenHash, encryptedPassword string) (int64, error) {
+	number, err := s.Storage.RedeemPasswordReset(ctx, tokenHash, encryptedPassword)
+
+	if err == nil {
+		s.invalidate(ctx, number)
+	}
+
+	return number, err
+}
+
+func (s *cachedStore) AccrueInterest(ctx context.Context, number int64, day time.Time, micros int64, post bool) error {
+	defer s.invalidate(ctx, number)
+	return s.Storage.AccrueInterest(ctx, number, day, micros, post)
+}
+
+// ImportDataset forgets every cached account, since a cache shared with the
+// instance the archive came from may hold them.
+func (s *cachedStore) ImportDataset(ctx context.Context, d *Dataset) error {
+	defer s.invalidate(ctx)
+	return s.Storage.ImportDataset(ctx, d)
+}
+
+func (s *cachedStore) AuthorizeTransfer(ctx context.Context, hold *Hold) error {
+	defer s.invalidate(ctx, hold.FromAccount)
+	return s.Storage.AuthorizeTransfer(ctx, hold)
+}
+
+func (s *cachedStore) CaptureHold(ctx context.Context, id int, fromAccount int64, now time.Time) (*Transfer, error) {
+	defer s.invalidate(ctx, fromAccount)
+	return s.Storage.CaptureHold(ctx, id, fromAccount, now)
+}
+
+func (s *cachedStore) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]*Hold, error) {
+	holds, err := s.Storage.ExpireHolds(ctx, now, limit)
+
+	if len(holds) > 0 {
+		numbers := make([]int64, len(holds))
+
+		for i, hold := range holds {
+			numbers[i] = hold.FromAccount
+		}
+
+		s.invalidate(ctx, numbers...)
+	}
+
+	return holds, err
+}
+
+func (s *cachedStore) AuthorizeCard(ctx context.Context, panHash string, auth *CardAuthorization) error {
+	err := s.Storage.AuthorizeCard(ctx, panHash, auth)
+
+	if auth.Status == CardAuthorizationApproved {
+		s.invalidate(ctx, auth.AccountNumber)
+	}
+
+	return err
+}
+
+func (s *cachedStore) ExpireCardAuthorizations(ctx context.Context, now time.Time, limit int) ([]*CardAuthorization, error) {
+	auths, err := s.Storage.ExpireCardAuthorizations(ctx, now, limit)
+
+	if len(auths) > 0 {
+		numbers := make([]int64, len(auths))
+
+		for i, auth := range auths {
+			numbers[i] = auth.AccountNumber
+		}
+
+		s.invalidate(ctx, numbers...)
+	}
+
+	return auths, err
+}
+
+func (s *cachedStore) CreateExternalTransfer(ctx context.Context, transfer *ExternalTransfer) error {
+	defer s.invalidate(ctx, transfer.AccountNumber)
+	return s.Storage.CreateExternalTransfer(ctx, transfer)
+}
+
+func (s *cachedStore) SettleExternalTransfers(ctx context.Context, submittedBefore, now time.Time, limit int) ([]*ExternalTransfer, error) {
+	transfers, err := s.Storage.SettleExternalTransfers(ctx, submittedBefore, now, limit)
+
+	if len(transfers) > 0 {
+		numbers := make([]int64, len(transfers))
+
+		for i, transfer := range transfers {
+			numbers[i] = transfer.AccountNumber
+		}
+
+		s.invalidate(ctx, numbers...)
+	}
+
+	return transfers, err
+}
+
+func (s *cachedStore) ReturnExternalTransfer(ctx context.Context, id int, code, reason string, now time.Time) (*ExternalTransfer, error) {
+	transfer, err := s.Storage.ReturnExternalTransfer(ctx, id, code, reason, now)
+
+	if err == nil {
+		s.invalidate(ctx, transfer.AccountNumber)
+	}
+
+	return transfer, err
+}
+
+func (s *cachedStore) SubmitKYC(ctx context.Context, kyc *KYC) error {
+	defer s.invalidate(ctx, kyc.AccountNumber)
+	return s.Storage.SubmitKYC(ctx, kyc)
+}
+
+func (s *cachedStore) ReviewKYC(ctx context.Context, number int64, status KYCStatus, reason string, reviewedAt time.Time) (*KYC, error) {
+	defer s.invalidate(ctx, number)
+	return s.Storage.ReviewKYC(ctx, number, status, reason, reviewedAt)
+}
+
+func (s *cachedStore) DeletePot(ctx context.Context, id int, number int64) (*Pot, error) {
+	defer s.invalidate(ctx, number)
+	return s.Storage.DeletePot(ctx, id, number)
+}
+
+func (s *cachedStore) MovePotMoney(ctx context.Context, id int, number int64, amount int64) (*Pot, error) {
+	defer s.invalidate(ctx, number)
+	return s.Storage.MovePotMoney(ctx, id, number, amount)
+}
+
+func (s *cachedStore) SweepPots(ctx context.Context, now time.Time, limit int) ([]*PotSweep, error) {
+	sweeps, err := s.Storage.SweepPots(ctx, now, limit)
+
+	if len(sweeps) > 0 {
+		numbers := make([]int64, len(sweeps))
+
+		for i, sweep := range sweeps {
+			numbers[i] = sweep.AccountNumber
+		}
+
+		s.invalidate(ctx, numbers...)
+	}
+
+	return sweeps, err
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryAccountCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cache := NewMemoryAccountCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	for i := 1; i <= 2; i++ {
+		require.Nil(t, cache.Set(ctx, &Account{ID: i, Number: int64(100 + i)}))
+	}
+
+	acc, _ := cache.Get(ctx, 101)
+	require.NotNil(t, acc)
+
+	// callers can't change the cached account
+	acc.Balance = 500
+	acc, _ = cache.Get(ctx, 101)
+	assert.Zero(t, acc.Balance)
+
+	// 102 is the least recently used
+	require.Nil(t, cache.Set(ctx, &Account{ID: 3, Number: 103}))
+
+	acc, _ = cache.Get(ctx, 102)
+	assert.Nil(t, acc)
+
+	number, _ := cache.Number(ctx, 2)
+	assert.Zero(t, number)
+
+	number, _ = cache.Number(ctx, 3)
+	assert.Equal(t, int64(103), number)
+
+	require.Nil(t, cache.Invalidate(ctx, 103))
+
+	acc, _ = cache.Get(ctx, 103)
+	assert.Nil(t, acc)
+
+	now = now.Add(time.Minute)
+
+	acc, _ = cache.Get(ctx, 101)
+	assert.Nil(t, acc)
+
+	require.Nil(t, cache.Set(ctx, &Account{ID: 1, Number: 101}))
+	require.Nil(t, cache.Invalidate(ctx))
+
+	acc, _ = cache.Get(ctx, 101)
+	assert.Nil(t, acc)
+}
+
+func TestRedisAccountCache(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cache := NewRedisAccountCache(redis.NewClient(&redis.Options{Addr: server.Addr()}), time.Minute)
+
+	acc, err := cache.Get(ctx, 101)
+	require.Nil(t, err)
+	assert.Nil(t, acc)
+
+	require.Nil(t, cache.Set(ctx, &Account{ID: 1, Number: 101, EncryptedPassword: "hash", Balance: 500}))
+	require.Nil(t, cache.Set(ctx, &Account{ID: 2, Number: 102}))
+
+	acc, err = cache.Get(ctx, 101)
+	require.Nil(t, err)
+	assert.Equal(t, "hash", acc.EncryptedPassword)
+	assert.Equal(t, int64(500), acc.Balance)
+
+	number, err := cache.Number(ctx, 1)
+	require.Nil(t, err)
+	assert.Equal(t, int64(101), number)
+
+	require.Nil(t, cache.Invalidate(ctx, 101))
+
+	acc, _ = cache.Get(ctx, 101)
+	assert.Nil(t, acc)
+
+	acc, _ = cache.Get(ctx, 102)
+	assert.NotNil(t, acc)
+
+	require.Nil(t, cache.Invalidate(ctx))
+
+	acc, _ = cache.Get(ctx, 102)
+	assert.Nil(t, acc)
+
+	server.FastForward(time.Minute)
+
+	number, _ = cache.Number(ctx, 1)
+	assert.Zero(t, number)
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStore()
+	store := cacheStore(memory, NewMemoryAccountCache(10, time.Minute))
+
+	from, err := NewAccount("Alice", "Test", "pw")
+	require.Nil(t, err)
+	to, err := NewAccount("Bob", "Test", "pw")
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAccount(ctx, from))
+	require.Nil(t, store.CreateAccount(ctx, to))
+
+	hits := testutil.ToFloat64(accountCacheRequestsTotal.WithLabelValues("hit"))
+
+	_, err = store.GetAccountById(ctx, from.ID)
+	require.Nil(t, err)
+	_, err = store.GetAccountByNumber(ctx, int(from.Number))
+	require.Nil(t, err)
+
+	assert.Equal(t, hits+1, testutil.ToFloat64(accountCacheRequestsTotal.WithLabelValues("hit")))
+
+	// a change behind the cache's back is not seen
+	memory.accounts[from.ID].FirstName = "Alicia"
+
+	acc, err := store.GetAccountById(ctx, from.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Alice", acc.FirstName)
+
+	// writes through it are
+	_, err = store.Deposit(ctx, from.Number, 500, 0)
+	require.Nil(t, err)
+
+	acc, err = store.GetAccountById(ctx, from.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Alicia", acc.FirstName)
+	assert.Equal(t, int64(500), acc.Balance)
+
+	_, err = store.GetAccountByNumber(ctx, int(to.Number))
+	require.Nil(t, err)
+
+	transfer, err := newTransfer(ctx, store, nil, from.Number, to.Number, 300)
+	require.Nil(t, err)
+	require.Nil(t, store.Transfer(ctx, transfer))
+
+	acc, err = store.GetAccountByNumber(ctx, int(to.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), acc.Balance)
+
+	_, err = store.UpdateAccountStatus(ctx, from.ID, AccountFrozen)
+	require.Nil(t, err)
+
+	acc, err = store.GetAccountByNumber(ctx, int(from.Number))
+	require.Nil(t, err)
+	assert.Equal(t, AccountFrozen, acc.Status)
+	assert.Equal(t, int64(200), acc.Balance)
+
+	// deleted accounts are not served from the cache
+	_, err = store.UpdateAccountStatus(ctx, from.ID, AccountActive)
+	require.Nil(t, err)
+	_, err = store.Withdraw(ctx, from.Number, 200, 0)
+	require.Nil(t, err)
+	_, err = store.DeleteAccount(ctx, from.ID, time.Now().UTC())
+	require.Nil(t, err)
+
+	_, err = store.GetAccountById(ctx, from.ID)
+	assert.NotNil(t, err)
+}
+
+// TestCachedStoreJournalCommitted checks the balances a journal entry books
+// are invalidated whichever write commits it, including writes the cache
+// doesn't override.
+func TestCachedStoreJournalCommitted(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStore()
+	store := cacheStore(memory, NewMemoryAccountCache(10, time.Minute))
+
+	acc, err := NewAccount("Alice", "Test", "pw")
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	_, err = store.GetAccountByNumber(ctx, int(acc.Number))
+	require.Nil(t, err)
+
+	// the write is made on the store behind the cache, as a writer the
+	// cache has no override for would
+	_, err = memory.Deposit(ctx, acc.Number, 500, 0)
+	require.Nil(t, err)
+
+	cached, err := store.GetAccountByNumber(ctx, int(acc.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(500), cached.Balance)
+
+	_, err = memory.Withdraw(ctx, acc.Number, 200, 0)
+	require.Nil(t, err)
+
+	cached, err = store.GetAccountById(ctx, acc.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), cached.Balance)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// cardIIN starts the numbers of the cards the bank issues; the rest is
+	// random but for a Luhn check digit, 16 digits in all.
+	cardIIN          = 400000
+	cardIINLength    = 6
+	cardNumberLength = 16
+	cardValidity     = 4
+
+	maxMerchantLength = 100
+)
+
+// NewCard issues a card on the number account, valid until the end of the
+// month cardValidity years from now, and returns it with its full number.
+func NewCard(number int64, now time.Time) (*Card, string, error) {
+	digits := cardNumberLength - cardIINLength - 1
+	random, err := crand.Int(crand.Reader, pow10(digits))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	payload := cardIIN*pow10(digits).Int64() + random.Int64()
+	pan := fmt.Sprintf("%d%d", payload, luhnCheckDigit(payload))
+	expiry := now.AddDate(cardValidity, 0, 0)
+
+	return &Card{
+		AccountNumber: number,
+		MaskedPAN:     maskPAN(pan),
+		PANHash:       hashToken(pan),
+		ExpiryMonth:   int(expiry.Month()),
+		ExpiryYear:    expiry.Year(),
+		Status:        CardActive,
+		CreatedAt:     now,
+	}, pan, nil
+}
+
+func maskPAN(pan string) string {
+	return strings.Repeat("*", len(pan)-4) + pan[len(pan)-4:]
+}
+
+// Expired reports whether the card can no longer be used at now; cards are
+// valid through the last day of their expiry month.
+func (c *Card) Expired(now time.Time) bool {
+	return !now.Before(time.Date(c.ExpiryYear, time.Month(c.ExpiryMonth)+1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// NewCardAuthorization is req waiting to be decided; approved, it holds the
+// amount for ttl.
+func NewCardAuthorization(req *CardAuthorizationRequest, ttl time.Duration, now time.Time) *CardAuthorization {
+	return &CardAuthorization{
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Merchant:  strings.TrimSpace(req.Merchant),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+// decide approves auth against card and its locked account acc, or declines
+// it with the first reason that applies.
+func (auth *CardAuthorization) decide(card *Card, acc *Account, now time.Time) {
+	auth.CardID = card.ID
+	auth.AccountNumber = card.AccountNumber
+	auth.Status = CardAuthorizationDeclined
+
+	switch {
+	case card.Status == CardFrozen:
+		auth.DeclineReason = CardDeclineFrozen
+	case card.Expired(now):
+		auth.DeclineReason = CardDeclineExpired
+	case acc.CheckActive() != nil:
+		auth.DeclineReason = CardDeclineAccountInactive
+	case acc.Currency != auth.Currency:
+		auth.DeclineReason = CardDeclineCurrency
+	case !acc.CanDebit(auth.Amount):
+		auth.DeclineReason = CardDeclineInsufficientFunds
+	default:
+		auth.Status = CardAuthorizationApproved
+	}
+}
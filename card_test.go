@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCardProcessorKey = "processor-key-of-at-least-32-chars"
+
+func TestNewCard(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	card, pan, err := NewCard(42, now)
+	require.Nil(t, err)
+
+	require.Len(t, pan, cardNumberLength)
+	assert.Equal(t, strconv.Itoa(cardIIN), pan[:cardIINLength])
+
+	number, err := strconv.ParseInt(pan, 10, 64)
+	require.Nil(t, err)
+	assert.Equal(t, luhnCheckDigit(number/10), number%10)
+
+	assert.Equal(t, "************"+pan[12:], card.MaskedPAN)
+	assert.Equal(t, hashToken(pan), card.PANHash)
+	assert.Equal(t, CardActive, card.Status)
+	assert.Equal(t, 2028, card.ExpiryYear)
+	assert.Equal(t, 1, card.ExpiryMonth)
+
+	assert.False(t, card.Expired(time.Date(2028, 1, 31, 23, 59, 0, 0, time.UTC)))
+	assert.True(t, card.Expired(time.Date(2028, 2, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestCardAuthorizationDecide(t *testing.T) {
+	now := time.Now().UTC()
+	card := &Card{ID: 3, AccountNumber: 42, Status: CardActive, ExpiryMonth: 12, ExpiryYear: now.Year() + 1}
+	acc := &Account{Number: 42, Currency: "USD", Status: AccountActive, Balance: 1000, HeldBalance: 300}
+
+	decide := func(card *Card, acc *Account, amount int64, currency string) *CardAuthorization {
+		auth := NewCardAuthorization(&CardAuthorizationRequest{Amount: amount, Currency: currency, Merchant: " Coffee "}, time.Hour, now)
+		auth.decide(card, acc, now)
+
+		return auth
+	}
+
+	auth := decide(card, acc, 700, "USD")
+	assert.Equal(t, CardAuthorizationApproved, auth.Status)
+	assert.Empty(t, auth.DeclineReason)
+	assert.Equal(t, 3, auth.CardID)
+	assert.Equal(t, "Coffee", auth.Merchant)
+	assert.Equal(t, now.Add(time.Hour), auth.ExpiresAt)
+
+	assert.Equal(t, CardDeclineInsufficientFunds, decide(card, acc, 701, "USD").DeclineReason)
+	assert.Equal(t, CardDeclineCurrency, decide(card, acc, 1, "EUR").DeclineReason)
+
+	frozen := *acc
+	frozen.Status = AccountFrozen
+	assert.Equal(t, CardDeclineAccountInactive, decide(card, &frozen, 1, "USD").DeclineReason)
+
+	expired := *card
+	expired.ExpiryYear = now.Year() - 1
+	assert.Equal(t, CardDeclineExpired, decide(&expired, acc, 1, "USD").DeclineReason)
+
+	expired.Status = CardFrozen
+	auth = decide(&expired, acc, 1, "USD")
+	assert.Equal(t, CardAuthorizationDeclined, auth.Status)
+	assert.Equal(t, CardDeclineFrozen, auth.DeclineReason)
+}
+
+func TestMemoryStoreCardAuthorizations(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	acc := &Account{Number: 42, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	_, err := store.Deposit(ctx, 42, 1000, 0)
+	require.Nil(t, err)
+
+	card, pan, err := NewCard(42, now)
+	require.Nil(t, err)
+	require.Nil(t, store.CreateCard(ctx, card))
+
+	authorize := func(amount int64) *CardAuthorization {
+		auth := NewCardAuthorization(&CardAuthorizationRequest{Amount: amount, Currency: "USD"}, time.Hour, now)
+		require.Nil(t, store.AuthorizeCard(ctx, hashToken(pan), auth))
+
+		return auth
+	}
+
+	assert.Equal(t, CardAuthorizationApproved, authorize(600).Status)
+	assert.Equal(t, CardDeclineInsufficientFunds, authorize(600).DeclineReason)
+
+	held, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Equal(t, int64(600), held.HeldBalance)
+
+	_, err = store.Withdraw(ctx, 42, 500, 0)
+	assert.NotNil(t, err, "held funds can't be withdrawn")
+
+	err = store.AuthorizeCard(ctx, hashToken("4000000000000000"), NewCardAuthorization(&CardAuthorizationRequest{Amount: 1, Currency: "USD"}, time.Hour, now))
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+
+	_, err = store.UpdateCardStatus(ctx, card.ID, 43, CardFrozen)
+	assert.NotNil(t, err, "cards are only found through their own account")
+
+	frozen, err := store.UpdateCardStatus(ctx, card.ID, 42, CardFrozen)
+	require.Nil(t, err)
+	assert.Equal(t, CardFrozen, frozen.Status)
+	assert.Equal(t, CardDeclineFrozen, authorize(1).DeclineReason)
+
+	NewHoldReaper(store).expireDue(ctx, now.Add(time.Hour))
+
+	released, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Zero(t, released.HeldBalance)
+
+	auths, err := store.ExpireCardAuthorizations(ctx, now.Add(2*time.Hour), 10)
+	require.Nil(t, err)
+	assert.Empty(t, auths, "declined and expired authorizations are left alone")
+}
+
+func TestAPICards(t *testing.T) {
+	cfg := testConfig()
+	cfg.CardProcessorKey = testCardProcessorKey
+
+	api := newTestAPIWithConfig(t, cfg)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/cards", token, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	issued := new(IssuedCard)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(issued))
+	require.Len(t, issued.PAN, cardNumberLength)
+
+	rec = api.do("GET", path+"/cards", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), issued.PAN)
+
+	authorize := func(key string, req CardAuthorizationRequest) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(req)
+		require.Nil(t, err)
+
+		r := httptest.NewRequest("POST", "/cards/authorize", bytes.NewReader(payload))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("x-processor-key", key)
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, r)
+
+		return rec
+	}
+
+	req := CardAuthorizationRequest{PAN: issued.PAN, Amount: 400, Currency: alice.Currency, Merchant: "Coffee"}
+
+	rec = authorize("wrong", req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = authorize(testCardProcessorKey, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	auth := new(CardAuthorization)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(auth))
+	assert.Equal(t, CardAuthorizationApproved, auth.Status)
+
+	acc, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(400), acc.HeldBalance)
+
+	rec = api.do("POST", path+"/cards/"+strconv.Itoa(issued.ID)+"/freeze", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = authorize(testCardProcessorKey, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(auth))
+	assert.Equal(t, CardAuthorizationDeclined, auth.Status)
+	assert.Equal(t, CardDeclineFrozen, auth.DeclineReason)
+
+	rec = api.do("POST", path+"/cards/"+strconv.Itoa(issued.ID)+"/unfreeze", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	req.PAN = "1234"
+	rec = authorize(testCardProcessorKey, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	// without a key configured the endpoint is closed
+	closed := newTestAPI(t)
+
+	payload, err := json.Marshal(CardAuthorizationRequest{PAN: issued.PAN, Amount: 1, Currency: "USD"})
+	require.Nil(t, err)
+
+	r := httptest.NewRequest("POST", "/cards/authorize", bytes.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+
+	rec = httptest.NewRecorder()
+	closed.handler.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
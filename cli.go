@@ -0,0 +1,545 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// cli builds the go-bank command. Every command takes the configuration
+// flags of LoadConfig; the admin commands work directly on the configured
+// store, so they need no server and no JWT secret. Tests replace the store
+// and the standard streams.
+type cli struct {
+	getenv    func(string) string
+	stdin     io.Reader
+	stdout    io.Writer
+	openStore func(context.Context, *Config) (Storage, func() error, error)
+
+	// cfg is loaded before any command runs.
+	cfg *Config
+}
+
+func newCLI() *cli {
+	return &cli{
+		getenv:    os.Getenv,
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		openStore: openStore,
+	}
+}
+
+func (c *cli) rootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:               "go-bank",
+		Short:             "Bank accounts and transfers over a JSON and a gRPC API",
+		Long:              "Bank accounts and transfers over a JSON and a gRPC API.\nWithout a command, go-bank runs the server like `go-bank serve`.",
+		SilenceUsage:      true,
+		PersistentPreRunE: c.loadConfig,
+		RunE:              c.serve,
+	}
+
+	root.PersistentFlags().AddGoFlagSet(newFlagSet(DefaultConfig(), new(string)))
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Run the JSON and gRPC APIs and the background workers",
+			Args:  cobra.NoArgs,
+			RunE:  c.serve,
+		},
+		&cobra.Command{
+			Use:   "migrate up|down [steps]",
+			Short: "Apply or roll back the Postgres or SQLite migrations",
+			Args:  cobra.RangeArgs(1, 2),
+			RunE:  c.migrate,
+		},
+		&cobra.Command{
+			Use:   "seed [profile|fixtures]",
+			Short: "Create the accounts of a seeding profile or a YAML or JSON fixture file, or the demo accounts",
+			Args:  cobra.MaximumNArgs(1),
+			RunE:  c.seed,
+		},
+		c.createAccountCommand(),
+		c.listAccountsCommand(),
+		c.transferCommand(),
+		c.resetPasswordCommand(),
+		c.exportCommand(),
+		c.importCommand(),
+	)
+
+	return root
+}
+
+// loadConfig passes the configuration flags given on the command line to
+// LoadConfig, so they keep their precedence over the file and environment.
+func (c *cli) loadConfig(cmd *cobra.Command, args []string) error {
+	configFlags := newFlagSet(DefaultConfig(), new(string))
+	var flags []string
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if configFlags.Lookup(f.Name) != nil {
+			flags = append(flags, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	cfg, _, err := LoadConfig(flags, c.getenv)
+
+	if err != nil {
+		return err
+	}
+
+	c.cfg = cfg
+
+	return nil
+}
+
+// withStore opens the configured store for an admin command.
+func (c *cli) withStore(ctx context.Context, f func(Storage) error) error {
+	if err := c.cfg.validateStore(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	store, closeStore, err := c.openStore(ctx, c.cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer closeStore()
+
+	return f(store)
+}
+
+func (c *cli) serve(cmd *cobra.Command, args []string) error {
+	if err := c.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	slog.SetDefault(slog.New(requestIDHandler{slog.NewJSONHandler(os.Stdout, nil)}))
+
+	store, closeStore, err := c.openStore(cmd.Context(), c.cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := closeStore(); err != nil {
+			slog.Error("closing store", "error", err)
+		}
+	}()
+
+	store = instrumentStore(store, c.cfg.DBSlowQuery)
+
+	// outside instrumentStore, so query metrics only count reads that miss
+	if cache := newAccountCache(c.cfg); cache != nil {
+		store = cacheStore(store, cache)
+	}
+
+	if c.cfg.Seed != "" && c.cfg.Seed != "false" {
+		slog.Info("seeding the database", "fixtures", c.cfg.Seed)
+
+		if err := c.seedFixtures(cmd.Context(), store, c.cfg.Seed); err != nil {
+			return err
+		}
+	}
+
+	return runServer(c.cfg, store)
+}
+
+func (c *cli) migrate(cmd *cobra.Command, args []string) error {
+	if c.cfg.Store == "sqlite" {
+		return c.migrateSQLite(cmd, args)
+	}
+
+	if c.cfg.DatabaseURL == "" {
+		return errors.New("databaseUrl: must be set to run migrations (DATABASE_URL)")
+	}
+
+	if err := c.cfg.validatePool(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	store, err := NewPostgresStore(cmd.Context(), c.cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	return runMigrate(cmd.Context(), store, args)
+}
+
+func (c *cli) migrateSQLite(cmd *cobra.Command, args []string) error {
+	if c.cfg.SQLitePath == "" {
+		return errors.New("sqlitePath: must be set to run migrations (BANK_SQLITE_PATH)")
+	}
+
+	store, err := NewSQLiteStore(cmd.Context(), c.cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer store.Close()
+
+	return runMigrate(cmd.Context(), store.PostgresStore, args)
+}
+
+func (c *cli) seed(cmd *cobra.Command, args []string) error {
+	seed := "true"
+
+	if len(args) > 0 {
+		seed = args[0]
+	}
+
+	return c.withStore(cmd.Context(), func(store Storage) error {
+		return c.seedFixtures(cmd.Context(), store, seed)
+	})
+}
+
+// seedFixtures creates the accounts of the fixtures seed names, see
+// loadFixtures.
+func (c *cli) seedFixtures(ctx context.Context, store Storage, seed string) error {
+	numbers := NewAccountNumberGenerator(c.cfg.AccountNumberLength)
+	fixtures, err := loadFixtures(seed, NewFixtureGenerator(numbers, c.cfg.SeedRandom, c.cfg.SeedAccounts))
+
+	if err != nil {
+		return err
+	}
+
+	created, err := seedFixtures(ctx, store, numbers, fixtures)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("seeded the database", "created", created, "accounts", len(fixtures.Accounts))
+
+	return nil
+}
+
+func (c *cli) createAccountCommand() *cobra.Command {
+	req := new(AccountRequest)
+	var role string
+
+	cmd := &cobra.Command{
+		Use:   "create-account",
+		Short: "Open an account, reading its password from standard input",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password, err := readPassword(c.stdin)
+
+			if err != nil {
+				return err
+			}
+
+			req.Password = password
+
+			if err := req.Validate(); err != nil {
+				return err
+			}
+
+			if role != string(RoleCustomer) && role != string(RoleAdmin) && role != string(RoleCompliance) {
+				return fmt.Errorf("role must be %s, %s or %s", RoleCustomer, RoleAdmin, RoleCompliance)
+			}
+
+			account, err := newAccountFromRequest(req)
+
+			if err != nil {
+				return err
+			}
+
+			account.Role = Role(role)
+
+			return c.withStore(cmd.Context(), func(store Storage) error {
+				if err := createAccount(cmd.Context(), store, NewAccountNumberGenerator(c.cfg.AccountNumberLength), account); err != nil {
+					return err
+				}
+
+				NewWebhookDispatcher(store).Publish(cmd.Context(), &Event{Type: EventAccountCreated, AccountNumber: account.Number, Data: account})
+				recordAudit(cmd.Context(), store, auditEntry(AuditAccountCreated, account.Number, nil, "", nil, account))
+
+				return c.printJSON(account)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&req.FirstName, "first-name", "", "first name of the holder")
+	cmd.Flags().StringVar(&req.LastName, "last-name", "", "last name of the holder")
+	cmd.Flags().StringVar(&req.Currency, "currency", "", "account currency, USD if empty")
+	cmd.Flags().StringVar((*string)(&req.Type), "type", "", "checking or savings, checking if empty")
+	cmd.Flags().StringVar(&role, "role", string(RoleCustomer), "customer, admin or compliance")
+
+	return cmd
+}
+
+func (c *cli) listAccountsCommand() *cobra.Command {
+	filter := AccountFilter{}
+
+	cmd := &cobra.Command{
+		Use:   "list-accounts",
+		Short: "List accounts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if filter.Limit < 1 || filter.Limit > maxPageLimit || filter.Offset < 0 {
+				return fmt.Errorf("limit must be between 1 and %d and offset not negative", maxPageLimit)
+			}
+
+			return c.withStore(cmd.Context(), func(store Storage) error {
+				accounts, err := store.GetAccounts(cmd.Context(), filter)
+
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tNUMBER\tNAME\tTYPE\tROLE\tSTATUS\tBALANCE")
+
+				for _, acc := range accounts {
+					fmt.Fprintf(w, "%d\t%d\t%s %s\t%s\t%s\t%s\t%d %s\n", acc.ID, acc.Number, acc.FirstName, acc.LastName, acc.Type, acc.Role, acc.Status, acc.Balance, acc.Currency)
+				}
+
+				return w.Flush()
+			})
+		},
+	}
+
+	cmd.Flags().IntVar(&filter.Limit, "limit", defaultPageLimit, "accounts to list")
+	cmd.Flags().IntVar(&filter.Offset, "offset", 0, "accounts to skip")
+	cmd.Flags().StringVar(&filter.Sort, "sort", "", "created_at or last_name, prefixed with - for descending order")
+	cmd.Flags().StringVar(&filter.LastName, "last-name", "", "only list holders with this last name")
+
+	return cmd
+}
+
+// transferCommand moves money as an operator: the two-factor step-up and
+// beneficiary cooling-off checks of the API do not apply.
+func (c *cli) transferCommand() *cobra.Command {
+	var from, to, amount int64
+
+	cmd := &cobra.Command{
+		Use:   "transfer",
+		Short: "Transfer money between two accounts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &TransferRequest{ToAccount: int(to), Amount: amount}
+
+			if err := req.ValidateFrom(from); err != nil {
+				return err
+			}
+
+			rates, err := NewStaticRateProvider(defaultExchangeRates)
+
+			if err != nil {
+				return err
+			}
+
+			return c.withStore(cmd.Context(), func(store Storage) error {
+				transfer, err := newTransfer(cmd.Context(), store, rates, from, to, amount)
+
+				if err != nil {
+					return err
+				}
+
+				if err := store.Transfer(cmd.Context(), transfer); err != nil {
+					return err
+				}
+
+				publishTransferEvents(cmd.Context(), NewWebhookDispatcher(store), store, transfer)
+
+				return c.printJSON(transfer)
+			})
+		},
+	}
+
+	cmd.Flags().Int64Var(&from, "from", 0, "number of the account to debit")
+	cmd.Flags().Int64Var(&to, "to", 0, "number of the account to credit")
+	cmd.Flags().Int64Var(&amount, "amount", 0, "amount in minor units of the debited account's currency")
+
+	return cmd
+}
+
+func (c *cli) resetPasswordCommand() *cobra.Command {
+	var number int64
+
+	cmd := &cobra.Command{
+		Use:   "reset-password",
+		Short: "Set an account's password, read from standard input, and end its sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password, err := readPassword(c.stdin)
+
+			if err != nil {
+				return err
+			}
+
+			if len(password) > maxPasswordLength {
+				return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+			}
+
+			return c.withStore(cmd.Context(), func(store Storage) error {
+				account, err := store.GetAccountByNumber(cmd.Context(), int(number))
+
+				if err != nil {
+					return err
+				}
+
+				if err := account.SetPassword(password); err != nil {
+					return err
+				}
+
+				if err := store.ResetPassword(cmd.Context(), account.Number, account.EncryptedPassword); err != nil {
+					return err
+				}
+
+				recordAudit(cmd.Context(), store, auditEntry(AuditPasswordReset, account.Number, nil, "", nil, nil))
+				fmt.Fprintf(c.stdout, "password of account %d reset\n", account.Number)
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().Int64Var(&number, "account", 0, "account number")
+
+	return cmd
+}
+
+// exportCommand writes the dataset archive to standard output, or to the
+// file given with --output.
+func (c *cli) exportCommand() *cobra.Command {
+	var format, output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export every account, ledger entry and transfer to an archive",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := datasetContentTypes[DatasetFormat(format)]; !ok {
+				return fmt.Errorf("format must be %s or %s", DatasetNDJSON, DatasetJSON)
+			}
+
+			return c.withStore(cmd.Context(), func(store Storage) error {
+				dataset, err := store.ExportDataset(cmd.Context(), time.Now().UTC())
+
+				if err != nil {
+					return err
+				}
+
+				if output == "" {
+					return WriteDataset(c.stdout, dataset, DatasetFormat(format))
+				}
+
+				// the archive holds password hashes, so only the owner can read it
+				f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+
+				if err != nil {
+					return err
+				}
+
+				if err := WriteDataset(f, dataset, DatasetFormat(format)); err != nil {
+					f.Close()
+					return err
+				}
+
+				return f.Close()
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", string(DatasetNDJSON), "ndjson or json")
+	cmd.Flags().StringVar(&output, "output", "", "file to create instead of writing to standard output")
+
+	return cmd
+}
+
+// importCommand loads an archive made by export, from the file given as
+// argument or standard input, into a store that has not moved any money
+// yet.
+func (c *cli) importCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import an archive made by export into a fresh store",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := datasetContentTypes[DatasetFormat(format)]; !ok {
+				return fmt.Errorf("format must be %s or %s", DatasetNDJSON, DatasetJSON)
+			}
+
+			r := c.stdin
+
+			if len(args) == 1 {
+				f, err := os.Open(args[0])
+
+				if err != nil {
+					return err
+				}
+
+				defer f.Close()
+
+				r = f
+			}
+
+			dataset, err := ReadDataset(r, DatasetFormat(format))
+
+			if err != nil {
+				return err
+			}
+
+			return c.withStore(cmd.Context(), func(store Storage) error {
+				if err := store.ImportDataset(cmd.Context(), dataset); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(c.stdout, "imported %s\n", dataset.Summary())
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", string(DatasetNDJSON), "ndjson or json")
+
+	return cmd
+}
+
+// readPassword reads the first line of r, so passwords stay out of the
+// process list and shell history.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+
+	if password == "" {
+		return "", errors.New("password must be given on standard input")
+	}
+
+	return password, nil
+}
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
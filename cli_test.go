@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCLI struct {
+	t      *testing.T
+	store  *MemoryStore
+	stdout *bytes.Buffer
+	// cli is the last one run
+	cli *cli
+}
+
+func newTestCLI(t *testing.T) *testCLI {
+	return &testCLI{t: t, store: NewMemoryStore(), stdout: new(bytes.Buffer)}
+}
+
+// run executes the command line args with stdin as standard input, against
+// the same in-memory store on every call.
+func (c *testCLI) run(stdin string, args ...string) error {
+	c.stdout.Reset()
+
+	cmd := &cli{
+		getenv: testEnv(map[string]string{"BANK_STORE": "memory"}),
+		stdin:  strings.NewReader(stdin),
+		stdout: c.stdout,
+		openStore: func(context.Context, *Config) (Storage, func() error, error) {
+			return c.store, func() error { return nil }, nil
+		},
+	}
+
+	c.cli = cmd
+	root := cmd.rootCommand()
+	root.SetArgs(args)
+	root.SetOut(new(bytes.Buffer))
+	root.SetErr(new(bytes.Buffer))
+
+	return root.Execute()
+}
+
+func TestCLIAccounts(t *testing.T) {
+	c := newTestCLI(t)
+
+	require.Nil(t, c.run("alice-pw\n", "create-account", "--first-name", "Alice", "--last-name", "Smith"))
+
+	alice := new(Account)
+	require.Nil(t, json.Unmarshal(c.stdout.Bytes(), alice))
+	assert.Equal(t, RoleCustomer, alice.Role)
+
+	require.Nil(t, c.run("bob-pw", "create-account", "--first-name", "Bob", "--last-name", "Jones", "--role", "admin"))
+
+	bob := new(Account)
+	require.Nil(t, json.Unmarshal(c.stdout.Bytes(), bob))
+	assert.Equal(t, RoleAdmin, bob.Role)
+
+	require.Nil(t, c.run("", "list-accounts", "--sort", "last_name"))
+
+	lines := strings.Split(strings.TrimSpace(c.stdout.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "Bob Jones")
+	assert.Contains(t, lines[2], "Alice Smith")
+
+	assert.ErrorContains(t, c.run("", "create-account", "--first-name", "Carol", "--last-name", "Test"), "standard input")
+	assert.ErrorContains(t, c.run("pw", "create-account", "--first-name", "Carol", "--last-name", "Test", "--role", "root"), "role must be")
+
+	entries, err := c.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestCLITransferAndResetPassword(t *testing.T) {
+	c := newTestCLI(t)
+	ctx := context.Background()
+
+	alice, err := NewAccount("Alice", "Smith", "alice-pw")
+	require.Nil(t, err)
+	bob, err := NewAccount("Bob", "Jones", "bob-pw")
+	require.Nil(t, err)
+	require.Nil(t, c.store.CreateAccount(ctx, alice))
+	require.Nil(t, c.store.CreateAccount(ctx, bob))
+	_, err = c.store.Deposit(ctx, alice.Number, 1000, 0)
+	require.Nil(t, err)
+
+	from, to := strconv.FormatInt(alice.Number, 10), strconv.FormatInt(bob.Number, 10)
+
+	require.Nil(t, c.run("", "transfer", "--from", from, "--to", to, "--amount", "300"))
+	assert.ErrorContains(t, c.run("", "transfer", "--from", from, "--to", to, "--amount", "3000"), "insufficient funds")
+
+	received, err := c.store.GetAccountByNumber(ctx, int(bob.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), received.Balance)
+
+	require.Nil(t, c.store.CreateRefreshToken(ctx, &RefreshToken{AccountNumber: alice.Number, TokenHash: "hash"}))
+	require.Nil(t, c.run("new-password\n", "reset-password", "--account", from))
+
+	reset, err := c.store.GetAccountByNumber(ctx, int(alice.Number))
+	require.Nil(t, err)
+	assert.True(t, reset.ValidPassword("new-password"))
+	assert.False(t, reset.ValidPassword("alice-pw"))
+	assert.ErrorContains(t, c.store.RotateRefreshToken(ctx, "hash", &RefreshToken{}, Device{}), "invalid refresh token")
+
+	assert.ErrorContains(t, c.run("pw", "reset-password", "--account", "1"), "not found")
+}
+
+func TestCLIConfigFlags(t *testing.T) {
+	c := newTestCLI(t)
+
+	require.Nil(t, c.run("", "seed", "--db-max-conns", "5"))
+	assert.Equal(t, 5, c.cli.cfg.DBMaxConns)
+	assert.Equal(t, "memory", c.cli.cfg.Store)
+
+	root := &cli{getenv: testEnv(nil), stdin: strings.NewReader(""), stdout: new(bytes.Buffer)}
+	cmd := root.rootCommand()
+	cmd.SetArgs([]string{"list-accounts", "--store", "mysql"})
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+
+	assert.ErrorContains(t, cmd.Execute(), `store: must be postgres, sqlite or memory, got "mysql"`)
+
+	accounts, err := c.store.GetAccounts(context.Background(), AccountFilter{Limit: 10})
+	require.Nil(t, err)
+	assert.Len(t, accounts, 3, "the demo fixtures")
+}
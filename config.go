@@ -0,0 +1,927 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	minJWTSecretLength        = 16
+	minCardProcessorKeyLength = 32
+	minReceiptKeyLength       = 32
+)
+
+// Config is everything the server can be configured with. It is loaded once
+// at startup by LoadConfig; later sources override earlier ones: defaults,
+// the YAML file given with -config (or BANK_CONFIG), environment variables,
+// then flags.
+type Config struct {
+	ListenAddr string `yaml:"listenAddr"`
+	// GRPCAddr is empty to disable the gRPC API.
+	GRPCAddr string `yaml:"grpcAddr"`
+
+	// RequestTimeout is the deadline of every API request but streams,
+	// exports and imports; queries still running then are cancelled.
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+
+	// CORSAllowedOrigins is a comma separated list of the origins browser
+	// frontends may call the API from, or * for any; empty disables CORS.
+	// The methods and headers are comma separated too, and CORSMaxAge is
+	// how long browsers may cache a preflight.
+	CORSAllowedOrigins string        `yaml:"corsAllowedOrigins"`
+	CORSAllowedMethods string        `yaml:"corsAllowedMethods"`
+	CORSAllowedHeaders string        `yaml:"corsAllowedHeaders"`
+	CORSMaxAge         time.Duration `yaml:"corsMaxAge"`
+
+	// Store is postgres, sqlite or memory. SQLitePath is the database file
+	// of the sqlite store, created if missing.
+	Store       string `yaml:"store"`
+	DatabaseURL string `yaml:"databaseUrl"`
+	SQLitePath  string `yaml:"sqlitePath"`
+	// Seed is true or a seeding profile, minimal, demo or load-test, to
+	// seed on start, or the path of a YAML or JSON fixture file; see
+	// Fixtures and seedProfiles.
+	Seed string `yaml:"seed"`
+	// SeedAccounts overrides how many accounts the demo and load-test
+	// profiles generate; SeedRandom seeds the generator, so the same value
+	// generates the same accounts and histories.
+	SeedAccounts int   `yaml:"seedAccounts"`
+	SeedRandom   int64 `yaml:"seedRandom"`
+
+	// DBMaxConns caps the Postgres pool; requests wait for a free
+	// connection instead of opening more.
+	DBMaxConns          int           `yaml:"dbMaxConns"`
+	DBMinConns          int           `yaml:"dbMinConns"`
+	DBMaxConnIdleTime   time.Duration `yaml:"dbMaxConnIdleTime"`
+	DBMaxConnLifetime   time.Duration `yaml:"dbMaxConnLifetime"`
+	DBHealthCheckPeriod time.Duration `yaml:"dbHealthCheckPeriod"`
+	// DBSlowQuery logs storage operations and SQL statements that take
+	// longer, with the request ID they ran for; zero logs none.
+	DBSlowQuery time.Duration `yaml:"dbSlowQuery"`
+	// DBQueryTimeout is the statement_timeout Postgres cancels statements
+	// at; zero leaves them unbounded.
+	DBQueryTimeout time.Duration `yaml:"dbQueryTimeout"`
+
+	// DatabaseReplicaURLs is a comma separated list of read replicas of
+	// DatabaseURL that serve account and transaction listings.
+	DatabaseReplicaURLs string `yaml:"databaseReplicaUrls"`
+	// ReplicaMaxLag stops reading from a replica that falls further behind
+	// the primary; zero reads from replicas however far behind they are.
+	ReplicaMaxLag time.Duration `yaml:"replicaMaxLag"`
+
+	JWTSecret       string        `yaml:"jwtSecret"`
+	AccessTokenTTL  time.Duration `yaml:"accessTokenTtl"`
+	RefreshTokenTTL time.Duration `yaml:"refreshTokenTtl"`
+	// StatementLinkTTL is how long a statement download link works, see
+	// StatementLink.
+	StatementLinkTTL time.Duration `yaml:"statementLinkTtl"`
+
+	// JWTKeySource is config, file or kms. The config source signs with
+	// JWTSecret, as key legacyKeyID, and JWTKeys, a comma separated list of
+	// kid:secret pairs oldest first; the file source reads JWTKeysFile.
+	JWTKeySource string `yaml:"jwtKeySource"`
+	JWTKeys      string `yaml:"jwtKeys"`
+	JWTKeysFile  string `yaml:"jwtKeysFile"`
+
+	// PasswordResetTTL is how long a password reset token can be used.
+	PasswordResetTTL time.Duration `yaml:"passwordResetTtl"`
+
+	// LoginMaxFailures is how many failed logins in a row lock an account,
+	// or an IP, out for LoginLockout; 0 disables the lockout. Until then
+	// every failure doubles the wait before the next attempt, starting at
+	// LoginBackoff.
+	LoginMaxFailures int           `yaml:"loginMaxFailures"`
+	LoginBackoff     time.Duration `yaml:"loginBackoff"`
+	LoginLockout     time.Duration `yaml:"loginLockout"`
+
+	// Notifier is log, email or sms; it delivers password reset tokens.
+	// Holders' notifications go to the channels they chose, or to the log
+	// when it is log.
+	Notifier string `yaml:"notifier"`
+	// Broker is log, kafka or nats; the outbox relay publishes completed
+	// transfers to it.
+	Broker string `yaml:"broker"`
+
+	// OIDCIssuer enables logging in with ID tokens of this OpenID Connect
+	// provider, issued to OIDCClientID. OIDCJWKSURL is discovered from the
+	// issuer if empty.
+	OIDCIssuer   string `yaml:"oidcIssuer"`
+	OIDCClientID string `yaml:"oidcClientId"`
+	OIDCJWKSURL  string `yaml:"oidcJwksUrl"`
+
+	// RateLimit is in requests per second, 0 disables rate limiting.
+	RateLimit float64 `yaml:"rateLimit"`
+	RateBurst int     `yaml:"rateBurst"`
+	// RedisAddr shares rate limits between instances; in-memory if empty.
+	RedisAddr string `yaml:"redisAddr"`
+
+	// AccountCache is off, memory or redis; it serves account reads for up
+	// to AccountCacheTTL. The memory cache holds up to AccountCacheSize
+	// accounts per instance, the redis one is shared through RedisAddr.
+	AccountCache     string        `yaml:"accountCache"`
+	AccountCacheTTL  time.Duration `yaml:"accountCacheTtl"`
+	AccountCacheSize int           `yaml:"accountCacheSize"`
+
+	// AccountNumberLength is the number of digits of new account numbers,
+	// including the check digit.
+	AccountNumberLength int `yaml:"accountNumberLength"`
+
+	SavingsAPR string `yaml:"savingsApr"`
+	// TermDepositRates are the terms term deposits can be opened for, as a
+	// comma separated list of termMonths=rate. Withdrawing a deposit before
+	// it matures costs TermDepositPenaltyDays of its interest.
+	TermDepositRates       string `yaml:"termDepositRates"`
+	TermDepositPenaltyDays int    `yaml:"termDepositPenaltyDays"`
+	TOTPStepUpAmount       int64  `yaml:"totpStepUpAmount"`
+	// HoldTTL is how long an authorized transfer can be captured.
+	HoldTTL time.Duration `yaml:"holdTtl"`
+	// TransferQuoteTTL is how long a transfer quote can be used.
+	TransferQuoteTTL time.Duration `yaml:"transferQuoteTtl"`
+	// PaymentRequestTTL is how long a payment request can be accepted.
+	PaymentRequestTTL time.Duration `yaml:"paymentRequestTtl"`
+	// Transfers above BeneficiaryCoolingOffAmount to a beneficiary added
+	// less than BeneficiaryCoolingOff ago are refused, 0 disables the check.
+	BeneficiaryCoolingOff       time.Duration `yaml:"beneficiaryCoolingOff"`
+	BeneficiaryCoolingOffAmount int64         `yaml:"beneficiaryCoolingOffAmount"`
+	// KYCTransferLimit is the largest transfer accounts can make before an
+	// admin has verified their holder, 0 disables the check.
+	KYCTransferLimit int64 `yaml:"kycTransferLimit"`
+	// VerifiedEmailAmount is the largest transfer accounts can make before
+	// their holder has verified an email, 0 disables the check.
+	VerifiedEmailAmount int64 `yaml:"verifiedEmailAmount"`
+	// CardProcessorKey is the shared secret card processors authorize card
+	// payments with, empty to disable card authorization. Approved
+	// authorizations hold the amount for CardAuthorizationTTL.
+	CardProcessorKey     string        `yaml:"cardProcessorKey"`
+	CardAuthorizationTTL time.Duration `yaml:"cardAuthorizationTtl"`
+	// ReceiptKey signs transfer receipts, empty to disable them. Receipts
+	// signed with a key no longer verify once it is changed.
+	ReceiptKey string `yaml:"receiptKey"`
+	// ExternalSettlementDelay is how long external transfers stay submitted
+	// to the clearing network before they settle.
+	ExternalSettlementDelay time.Duration `yaml:"externalSettlementDelay"`
+	// StandingOrderMaxAttempts is how often a standing order payment is
+	// tried for lack of funds before it is skipped and the holder notified.
+	StandingOrderMaxAttempts int `yaml:"standingOrderMaxAttempts"`
+	// ReconciliationHour is the hour of the day, in UTC, the ledger is
+	// reconciled against the account balances.
+	ReconciliationHour int `yaml:"reconciliationHour"`
+	// ReconciliationAlertURL is posted the reports of reconciliations that
+	// found discrepancies, empty to only log them.
+	ReconciliationAlertURL string `yaml:"reconciliationAlertUrl"`
+
+	// FraudRules screens transfers, as a comma separated list of
+	// rule=action; rules left out are not evaluated. The settings below
+	// tune the rules.
+	FraudRules          string        `yaml:"fraudRules"`
+	FraudVelocityLimit  int           `yaml:"fraudVelocityLimit"`
+	FraudVelocityWindow time.Duration `yaml:"fraudVelocityWindow"`
+	// FraudLargeAmount is the smallest transfer to a new payee the
+	// new-beneficiary rule matches.
+	FraudLargeAmount int64 `yaml:"fraudLargeAmount"`
+	// FraudUnusualHours is the range of hours in UTC, e.g. 0-6, the
+	// unusual-hour rule matches.
+	FraudUnusualHours string `yaml:"fraudUnusualHours"`
+
+	// AMLThreshold is the smallest ledger entry flagged for compliance
+	// review on its own, 0 turns AML flagging off. Entries under it are
+	// flagged when at least AMLStructuringCount of them add up to it
+	// within AMLStructuringWindow.
+	AMLThreshold         int64         `yaml:"amlThreshold"`
+	AMLStructuringCount  int           `yaml:"amlStructuringCount"`
+	AMLStructuringWindow time.Duration `yaml:"amlStructuringWindow"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		ListenAddr:                  ":3000",
+		GRPCAddr:                    ":50051",
+		RequestTimeout:              30 * time.Second,
+		CORSAllowedMethods:          "GET,POST,PUT,PATCH,DELETE",
+		CORSAllowedHeaders:          "Authorization,Content-Type,x-jwt-token,Idempotency-Key,If-Match,X-Request-ID",
+		CORSMaxAge:                  10 * time.Minute,
+		Store:                       "postgres",
+		SeedRandom:                  1,
+		DBMaxConns:                  20,
+		DBMaxConnIdleTime:           5 * time.Minute,
+		DBMaxConnLifetime:           time.Hour,
+		DBHealthCheckPeriod:         30 * time.Second,
+		DBSlowQuery:                 500 * time.Millisecond,
+		DBQueryTimeout:              10 * time.Second,
+		ReplicaMaxLag:               10 * time.Second,
+		JWTKeySource:                "config",
+		AccessTokenTTL:              15 * time.Minute,
+		RefreshTokenTTL:             30 * 24 * time.Hour,
+		StatementLinkTTL:            15 * time.Minute,
+		PasswordResetTTL:            30 * time.Minute,
+		LoginMaxFailures:            5,
+		LoginBackoff:                time.Second,
+		LoginLockout:                15 * time.Minute,
+		Notifier:                    "log",
+		Broker:                      "log",
+		RateLimit:                   10,
+		RateBurst:                   20,
+		AccountCache:                "off",
+		AccountCacheTTL:             30 * time.Second,
+		AccountCacheSize:            10000,
+		AccountNumberLength:         defaultAccountNumberLength,
+		SavingsAPR:                  defaultSavingsAPR,
+		TermDepositRates:            defaultTermDepositRates,
+		TermDepositPenaltyDays:      defaultTermDepositPenaltyDays,
+		HoldTTL:                     7 * 24 * time.Hour,
+		TransferQuoteTTL:            2 * time.Minute,
+		PaymentRequestTTL:           7 * 24 * time.Hour,
+		CardAuthorizationTTL:        7 * 24 * time.Hour,
+		ExternalSettlementDelay:     24 * time.Hour,
+		BeneficiaryCoolingOff:       24 * time.Hour,
+		BeneficiaryCoolingOffAmount: 100000,
+		KYCTransferLimit:            100000,
+		VerifiedEmailAmount:         100000,
+		StandingOrderMaxAttempts:    3,
+		ReconciliationHour:          2,
+		FraudRules:                  "velocity=flag,new-beneficiary=flag,unusual-hour=flag,ip-mismatch=flag",
+		FraudVelocityLimit:          10,
+		FraudVelocityWindow:         time.Hour,
+		FraudLargeAmount:            100000,
+		FraudUnusualHours:           "0-6",
+		AMLThreshold:                1000000,
+		AMLStructuringCount:         3,
+		AMLStructuringWindow:        24 * time.Hour,
+	}
+}
+
+// LoadConfig builds the configuration from args (without the program name)
+// and the environment, and returns it with the arguments left after the
+// flags. The result is not validated.
+func LoadConfig(args []string, getenv func(string) string) (*Config, []string, error) {
+	// a first pass only finds the config file; flags are parsed again below
+	// so they override the file and the environment
+	var path string
+
+	if err := newFlagSet(DefaultConfig(), &path).Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if path == "" {
+		path = getenv("BANK_CONFIG")
+	}
+
+	cfg := DefaultConfig()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(getenv); err != nil {
+		return nil, nil, err
+	}
+
+	fs := newFlagSet(cfg, &path)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// newFlagSet binds the flags to cfg, using its current values as defaults.
+// Secrets have no flags so they don't end up in process listings.
+func newFlagSet(cfg *Config, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("go-bank", flag.ContinueOnError)
+
+	fs.StringVar(configPath, "config", *configPath, "YAML config file")
+	fs.StringVar(&cfg.ListenAddr, "listen-addr", cfg.ListenAddr, "listen address of the JSON API")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", cfg.GRPCAddr, "listen address of the gRPC API, empty to disable it")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", cfg.RequestTimeout, "how long an API request can take before it is cancelled with a 504")
+	fs.StringVar(&cfg.CORSAllowedOrigins, "cors-allowed-origins", cfg.CORSAllowedOrigins, "comma separated origins browsers may call the API from, * for any, empty disables CORS")
+	fs.StringVar(&cfg.CORSAllowedMethods, "cors-allowed-methods", cfg.CORSAllowedMethods, "comma separated methods allowed for cross-origin requests")
+	fs.StringVar(&cfg.CORSAllowedHeaders, "cors-allowed-headers", cfg.CORSAllowedHeaders, "comma separated request headers allowed for cross-origin requests")
+	fs.DurationVar(&cfg.CORSMaxAge, "cors-max-age", cfg.CORSMaxAge, "how long browsers may cache a preflight response")
+	fs.StringVar(&cfg.Store, "store", cfg.Store, "storage backend: postgres, sqlite or memory")
+	fs.StringVar(&cfg.DatabaseURL, "database-url", cfg.DatabaseURL, "Postgres connection string")
+	fs.StringVar(&cfg.SQLitePath, "sqlite-path", cfg.SQLitePath, "database file of the sqlite store")
+	fs.Var(seedFlag{&cfg.Seed}, "seed", "seed the db with the demo fixtures, with -seed=<profile> a minimal, demo or load-test profile, or with -seed=<file> those of a YAML or JSON file")
+	fs.IntVar(&cfg.SeedAccounts, "seed-accounts", cfg.SeedAccounts, "number of accounts the demo and load-test seeding profiles generate, 0 for the profile's own")
+	fs.Int64Var(&cfg.SeedRandom, "seed-random", cfg.SeedRandom, "random seed of the accounts and histories the seeding profiles generate")
+	fs.IntVar(&cfg.DBMaxConns, "db-max-conns", cfg.DBMaxConns, "maximum number of Postgres connections")
+	fs.IntVar(&cfg.DBMinConns, "db-min-conns", cfg.DBMinConns, "number of Postgres connections kept open when idle")
+	fs.DurationVar(&cfg.DBMaxConnIdleTime, "db-max-conn-idle-time", cfg.DBMaxConnIdleTime, "how long an idle Postgres connection is kept")
+	fs.DurationVar(&cfg.DBMaxConnLifetime, "db-max-conn-lifetime", cfg.DBMaxConnLifetime, "how long a Postgres connection is used before it is replaced")
+	fs.DurationVar(&cfg.DBHealthCheckPeriod, "db-health-check-period", cfg.DBHealthCheckPeriod, "how often idle Postgres connections are checked")
+	fs.DurationVar(&cfg.DBSlowQuery, "db-slow-query", cfg.DBSlowQuery, "log storage operations and SQL statements slower than this, 0 to log none")
+	fs.DurationVar(&cfg.DBQueryTimeout, "db-query-timeout", cfg.DBQueryTimeout, "cancel SQL statements running longer than this, 0 for no limit")
+	fs.StringVar(&cfg.DatabaseReplicaURLs, "database-replica-urls", cfg.DatabaseReplicaURLs, "comma separated Postgres read replica connection strings")
+	fs.DurationVar(&cfg.ReplicaMaxLag, "replica-max-lag", cfg.ReplicaMaxLag, "replication lag past which a replica stops serving reads, 0 for no limit")
+	fs.StringVar(&cfg.JWTKeySource, "jwt-key-source", cfg.JWTKeySource, "where access token signing keys are loaded from: config, file or kms")
+	fs.StringVar(&cfg.JWTKeysFile, "jwt-keys-file", cfg.JWTKeysFile, "JSON file of access token signing keys, for the file key source")
+	fs.DurationVar(&cfg.AccessTokenTTL, "access-token-ttl", cfg.AccessTokenTTL, "lifetime of access tokens")
+	fs.DurationVar(&cfg.RefreshTokenTTL, "refresh-token-ttl", cfg.RefreshTokenTTL, "lifetime of refresh tokens")
+	fs.DurationVar(&cfg.StatementLinkTTL, "statement-link-ttl", cfg.StatementLinkTTL, "lifetime of statement download links")
+	fs.DurationVar(&cfg.PasswordResetTTL, "password-reset-ttl", cfg.PasswordResetTTL, "lifetime of password reset tokens")
+	fs.IntVar(&cfg.LoginMaxFailures, "login-max-failures", cfg.LoginMaxFailures, "failed logins in a row that lock an account or IP out, 0 to never lock")
+	fs.DurationVar(&cfg.LoginBackoff, "login-backoff", cfg.LoginBackoff, "wait after the first failed login, doubled by every further failure")
+	fs.DurationVar(&cfg.LoginLockout, "login-lockout", cfg.LoginLockout, "how long too many failed logins lock an account or IP out")
+	fs.StringVar(&cfg.Notifier, "notifier", cfg.Notifier, "how password reset tokens are delivered: log, email or sms")
+	fs.StringVar(&cfg.Broker, "broker", cfg.Broker, "message broker completed transfers are published to: log, kafka or nats")
+	fs.StringVar(&cfg.OIDCIssuer, "oidc-issuer", cfg.OIDCIssuer, "issuer URL of the OpenID Connect provider, empty to disable OIDC login")
+	fs.StringVar(&cfg.OIDCClientID, "oidc-client-id", cfg.OIDCClientID, "client ID the provider's ID tokens must be issued to")
+	fs.StringVar(&cfg.OIDCJWKSURL, "oidc-jwks-url", cfg.OIDCJWKSURL, "URL of the provider's signing keys, discovered from the issuer if empty")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "requests per second allowed per account or IP, 0 disables rate limiting")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", cfg.RateBurst, "requests allowed in a burst above the rate limit")
+	fs.StringVar(&cfg.RedisAddr, "redis-addr", cfg.RedisAddr, "Redis address to share rate limits between instances, in-memory if empty")
+	fs.StringVar(&cfg.AccountCache, "account-cache", cfg.AccountCache, "cache for account reads: off, memory or redis")
+	fs.DurationVar(&cfg.AccountCacheTTL, "account-cache-ttl", cfg.AccountCacheTTL, "how long an account read is cached")
+	fs.IntVar(&cfg.AccountCacheSize, "account-cache-size", cfg.AccountCacheSize, "accounts the memory cache holds per instance")
+	fs.IntVar(&cfg.AccountNumberLength, "account-number-length", cfg.AccountNumberLength, "digits of new account numbers, including the check digit")
+	fs.StringVar(&cfg.SavingsAPR, "savings-apr", cfg.SavingsAPR, "annual interest rate paid on savings accounts, e.g. 0.02")
+	fs.StringVar(&cfg.TermDepositRates, "term-deposit-rates", cfg.TermDepositRates, "terms offered for term deposits and their annual rates, as termMonths=rate pairs, e.g. 12=0.04")
+	fs.IntVar(&cfg.TermDepositPenaltyDays, "term-deposit-penalty-days", cfg.TermDepositPenaltyDays, "days of interest withdrawing a term deposit before it matures costs")
+	fs.Int64Var(&cfg.TOTPStepUpAmount, "totp-step-up-amount", cfg.TOTPStepUpAmount, "transfers above this amount need a two-factor code on accounts that enabled it, 0 disables step-up")
+	fs.DurationVar(&cfg.HoldTTL, "hold-ttl", cfg.HoldTTL, "how long an authorized transfer can be captured before its hold expires")
+	fs.DurationVar(&cfg.TransferQuoteTTL, "transfer-quote-ttl", cfg.TransferQuoteTTL, "how long a transfer quote can be used")
+	fs.DurationVar(&cfg.PaymentRequestTTL, "payment-request-ttl", cfg.PaymentRequestTTL, "how long a payment request can be accepted")
+	fs.DurationVar(&cfg.CardAuthorizationTTL, "card-authorization-ttl", cfg.CardAuthorizationTTL, "how long an approved card authorization holds its amount")
+	fs.DurationVar(&cfg.ExternalSettlementDelay, "external-settlement-delay", cfg.ExternalSettlementDelay, "how long submitted external transfers take to settle")
+	fs.DurationVar(&cfg.BeneficiaryCoolingOff, "beneficiary-cooling-off", cfg.BeneficiaryCoolingOff, "how long a new beneficiary can't receive large transfers")
+	fs.Int64Var(&cfg.BeneficiaryCoolingOffAmount, "beneficiary-cooling-off-amount", cfg.BeneficiaryCoolingOffAmount, "transfers above this amount to a new beneficiary wait for the cooling-off period, 0 disables it")
+	fs.Int64Var(&cfg.KYCTransferLimit, "kyc-transfer-limit", cfg.KYCTransferLimit, "transfers above this amount need a verified account holder, 0 disables the check")
+	fs.Int64Var(&cfg.VerifiedEmailAmount, "verified-email-amount", cfg.VerifiedEmailAmount, "transfers above this amount need a verified email, 0 disables the check")
+	fs.IntVar(&cfg.StandingOrderMaxAttempts, "standing-order-max-attempts", cfg.StandingOrderMaxAttempts, "attempts at a standing order payment refused for lack of funds before it is skipped")
+	fs.IntVar(&cfg.ReconciliationHour, "reconciliation-hour", cfg.ReconciliationHour, "hour of the day in UTC the ledger is reconciled against the account balances")
+	fs.StringVar(&cfg.ReconciliationAlertURL, "reconciliation-alert-url", cfg.ReconciliationAlertURL, "URL posted the reports of reconciliations that found discrepancies, empty to log them")
+	fs.StringVar(&cfg.FraudRules, "fraud-rules", cfg.FraudRules, "fraud rules screening transfers as rule=action pairs, actions are allow, flag or block")
+	fs.IntVar(&cfg.FraudVelocityLimit, "fraud-velocity-limit", cfg.FraudVelocityLimit, "transfers an account can make within the velocity window before the velocity rule matches")
+	fs.DurationVar(&cfg.FraudVelocityWindow, "fraud-velocity-window", cfg.FraudVelocityWindow, "window the velocity rule counts transfers in")
+	fs.Int64Var(&cfg.FraudLargeAmount, "fraud-large-amount", cfg.FraudLargeAmount, "smallest transfer to a new payee the new-beneficiary rule matches")
+	fs.StringVar(&cfg.FraudUnusualHours, "fraud-unusual-hours", cfg.FraudUnusualHours, "hours in UTC the unusual-hour rule matches, e.g. 0-6")
+	fs.Int64Var(&cfg.AMLThreshold, "aml-threshold", cfg.AMLThreshold, "smallest ledger entry flagged for compliance review, 0 to turn AML flagging off")
+	fs.IntVar(&cfg.AMLStructuringCount, "aml-structuring-count", cfg.AMLStructuringCount, "entries under the AML threshold adding up to it that are flagged as structuring")
+	fs.DurationVar(&cfg.AMLStructuringWindow, "aml-structuring-window", cfg.AMLStructuringWindow, "window the structuring rule adds up entries in")
+
+	return fs
+}
+
+// seedFlag lets -seed be given alone, as the boolean it used to be, or with
+// a seeding profile or the path of a fixture file.
+type seedFlag struct {
+	value *string
+}
+
+func (f seedFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+
+	return *f.value
+}
+
+func (f seedFlag) Set(value string) error {
+	*f.value = value
+
+	return nil
+}
+
+func (f seedFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv(getenv func(string) string) error {
+	// kept from before the config file existed
+	if user, password := getenv("POSTGRES_USERNAME"), getenv("POSTGRES_PASSWORD"); user != "" {
+		c.DatabaseURL = "user=postgres dbname=" + user + " password=" + password + " sslmode=disable"
+	}
+
+	vars := []struct {
+		name  string
+		apply func(string) error
+	}{
+		{"BANK_LISTEN_ADDR", setString(&c.ListenAddr)},
+		{"BANK_GRPC_ADDR", setString(&c.GRPCAddr)},
+		{"BANK_REQUEST_TIMEOUT", setDuration(&c.RequestTimeout)},
+		{"BANK_CORS_ALLOWED_ORIGINS", setString(&c.CORSAllowedOrigins)},
+		{"BANK_CORS_ALLOWED_METHODS", setString(&c.CORSAllowedMethods)},
+		{"BANK_CORS_ALLOWED_HEADERS", setString(&c.CORSAllowedHeaders)},
+		{"BANK_CORS_MAX_AGE", setDuration(&c.CORSMaxAge)},
+		{"BANK_STORE", setString(&c.Store)},
+		{"DATABASE_URL", setString(&c.DatabaseURL)},
+		{"BANK_SQLITE_PATH", setString(&c.SQLitePath)},
+		{"BANK_SEED_ACCOUNTS", setInt(&c.SeedAccounts)},
+		{"BANK_SEED_RANDOM", setInt64(&c.SeedRandom)},
+		{"BANK_DB_MAX_CONNS", setInt(&c.DBMaxConns)},
+		{"BANK_DB_MIN_CONNS", setInt(&c.DBMinConns)},
+		{"BANK_DB_MAX_CONN_IDLE_TIME", setDuration(&c.DBMaxConnIdleTime)},
+		{"BANK_DB_MAX_CONN_LIFETIME", setDuration(&c.DBMaxConnLifetime)},
+		{"BANK_DB_HEALTH_CHECK_PERIOD", setDuration(&c.DBHealthCheckPeriod)},
+		{"BANK_DB_SLOW_QUERY", setDuration(&c.DBSlowQuery)},
+		{"BANK_DB_QUERY_TIMEOUT", setDuration(&c.DBQueryTimeout)},
+		{"BANK_DATABASE_REPLICA_URLS", setString(&c.DatabaseReplicaURLs)},
+		{"BANK_REPLICA_MAX_LAG", setDuration(&c.ReplicaMaxLag)},
+		{"JWT_SECRET", setString(&c.JWTSecret)},
+		{"BANK_JWT_KEYS", setString(&c.JWTKeys)},
+		{"BANK_JWT_KEY_SOURCE", setString(&c.JWTKeySource)},
+		{"BANK_JWT_KEYS_FILE", setString(&c.JWTKeysFile)},
+		{"BANK_ACCESS_TOKEN_TTL", setDuration(&c.AccessTokenTTL)},
+		{"BANK_REFRESH_TOKEN_TTL", setDuration(&c.RefreshTokenTTL)},
+		{"BANK_STATEMENT_LINK_TTL", setDuration(&c.StatementLinkTTL)},
+		{"BANK_PASSWORD_RESET_TTL", setDuration(&c.PasswordResetTTL)},
+		{"BANK_LOGIN_MAX_FAILURES", setInt(&c.LoginMaxFailures)},
+		{"BANK_LOGIN_BACKOFF", setDuration(&c.LoginBackoff)},
+		{"BANK_LOGIN_LOCKOUT", setDuration(&c.LoginLockout)},
+		{"BANK_NOTIFIER", setString(&c.Notifier)},
+		{"BANK_BROKER", setString(&c.Broker)},
+		{"BANK_OIDC_ISSUER", setString(&c.OIDCIssuer)},
+		{"BANK_OIDC_CLIENT_ID", setString(&c.OIDCClientID)},
+		{"BANK_OIDC_JWKS_URL", setString(&c.OIDCJWKSURL)},
+		{"BANK_RATE_LIMIT", setFloat(&c.RateLimit)},
+		{"BANK_RATE_BURST", setInt(&c.RateBurst)},
+		{"BANK_REDIS_ADDR", setString(&c.RedisAddr)},
+		{"BANK_ACCOUNT_CACHE", setString(&c.AccountCache)},
+		{"BANK_ACCOUNT_CACHE_TTL", setDuration(&c.AccountCacheTTL)},
+		{"BANK_ACCOUNT_CACHE_SIZE", setInt(&c.AccountCacheSize)},
+		{"BANK_ACCOUNT_NUMBER_LENGTH", setInt(&c.AccountNumberLength)},
+		{"BANK_SAVINGS_APR", setString(&c.SavingsAPR)},
+		{"BANK_TERM_DEPOSIT_RATES", setString(&c.TermDepositRates)},
+		{"BANK_TERM_DEPOSIT_PENALTY_DAYS", setInt(&c.TermDepositPenaltyDays)},
+		{"BANK_TOTP_STEP_UP_AMOUNT", setInt64(&c.TOTPStepUpAmount)},
+		{"BANK_HOLD_TTL", setDuration(&c.HoldTTL)},
+		{"BANK_TRANSFER_QUOTE_TTL", setDuration(&c.TransferQuoteTTL)},
+		{"BANK_PAYMENT_REQUEST_TTL", setDuration(&c.PaymentRequestTTL)},
+		{"BANK_CARD_PROCESSOR_KEY", setString(&c.CardProcessorKey)},
+		{"BANK_RECEIPT_KEY", setString(&c.ReceiptKey)},
+		{"BANK_CARD_AUTHORIZATION_TTL", setDuration(&c.CardAuthorizationTTL)},
+		{"BANK_EXTERNAL_SETTLEMENT_DELAY", setDuration(&c.ExternalSettlementDelay)},
+		{"BANK_BENEFICIARY_COOLING_OFF", setDuration(&c.BeneficiaryCoolingOff)},
+		{"BANK_BENEFICIARY_COOLING_OFF_AMOUNT", setInt64(&c.BeneficiaryCoolingOffAmount)},
+		{"BANK_KYC_TRANSFER_LIMIT", setInt64(&c.KYCTransferLimit)},
+		{"BANK_VERIFIED_EMAIL_AMOUNT", setInt64(&c.VerifiedEmailAmount)},
+		{"BANK_STANDING_ORDER_MAX_ATTEMPTS", setInt(&c.StandingOrderMaxAttempts)},
+		{"BANK_RECONCILIATION_HOUR", setInt(&c.ReconciliationHour)},
+		{"BANK_RECONCILIATION_ALERT_URL", setString(&c.ReconciliationAlertURL)},
+		{"BANK_FRAUD_RULES", setString(&c.FraudRules)},
+		{"BANK_FRAUD_VELOCITY_LIMIT", setInt(&c.FraudVelocityLimit)},
+		{"BANK_FRAUD_VELOCITY_WINDOW", setDuration(&c.FraudVelocityWindow)},
+		{"BANK_FRAUD_LARGE_AMOUNT", setInt64(&c.FraudLargeAmount)},
+		{"BANK_FRAUD_UNUSUAL_HOURS", setString(&c.FraudUnusualHours)},
+		{"BANK_AML_THRESHOLD", setInt64(&c.AMLThreshold)},
+		{"BANK_AML_STRUCTURING_COUNT", setInt(&c.AMLStructuringCount)},
+		{"BANK_AML_STRUCTURING_WINDOW", setDuration(&c.AMLStructuringWindow)},
+	}
+
+	var errs []error
+
+	for _, v := range vars {
+		if value := getenv(v.name); value != "" {
+			if err := v.apply(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", v.name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func setString(dst *string) func(string) error {
+	return func(s string) error {
+		*dst = s
+		return nil
+	}
+}
+
+func setDuration(dst *time.Duration) func(string) error {
+	return func(s string) (err error) {
+		*dst, err = time.ParseDuration(s)
+		return err
+	}
+}
+
+func setFloat(dst *float64) func(string) error {
+	return func(s string) (err error) {
+		*dst, err = strconv.ParseFloat(s, 64)
+		return err
+	}
+}
+
+func setInt(dst *int) func(string) error {
+	return func(s string) (err error) {
+		*dst, err = strconv.Atoi(s)
+		return err
+	}
+}
+
+func setInt64(dst *int64) func(string) error {
+	return func(s string) (err error) {
+		*dst, err = strconv.ParseInt(s, 10, 64)
+		return err
+	}
+}
+
+// Validate reports every invalid setting at once, named as in the YAML file.
+func (c *Config) Validate() error {
+	var errs []error
+
+	invalid := func(field, format string, a ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{field}, a...)...))
+	}
+
+	if c.ListenAddr == "" {
+		invalid("listenAddr", "must be set")
+	}
+
+	if c.RequestTimeout <= 0 {
+		invalid("requestTimeout", "must be positive")
+	}
+
+	for _, origin := range splitList(c.CORSAllowedOrigins) {
+		// an origin is a scheme and host, without a path
+		if origin != "*" && (!isAbsoluteURL(origin) || strings.Count(origin, "/") != 2) {
+			invalid("corsAllowedOrigins", "must be origins such as https://app.example.com or *, got %q", origin)
+		}
+	}
+
+	if c.CORSAllowedOrigins != "" && len(splitList(c.CORSAllowedMethods)) == 0 {
+		invalid("corsAllowedMethods", "must be set with corsAllowedOrigins")
+	}
+
+	if c.CORSMaxAge < 0 {
+		invalid("corsMaxAge", "must not be negative")
+	}
+
+	if err := c.validateStore(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.validateJWTKeys(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.AccessTokenTTL <= 0 {
+		invalid("accessTokenTtl", "must be positive")
+	}
+
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		invalid("refreshTokenTtl", "must be longer than accessTokenTtl")
+	}
+
+	if c.StatementLinkTTL <= 0 || c.StatementLinkTTL > maxStatementLinkTTL {
+		invalid("statementLinkTtl", "must be positive and at most %s", maxStatementLinkTTL)
+	}
+
+	if c.PasswordResetTTL <= 0 {
+		invalid("passwordResetTtl", "must be positive")
+	}
+
+	if c.LoginMaxFailures < 0 {
+		invalid("loginMaxFailures", "must not be negative")
+	}
+
+	if c.LoginMaxFailures > 0 && c.LoginBackoff < 0 {
+		invalid("loginBackoff", "must not be negative")
+	}
+
+	if c.LoginMaxFailures > 0 && c.LoginLockout <= 0 {
+		invalid("loginLockout", "must be positive")
+	}
+
+	switch c.Notifier {
+	case "log", "email", "sms":
+	default:
+		invalid("notifier", "must be log, email or sms, got %q", c.Notifier)
+	}
+
+	switch c.Broker {
+	case "log", "kafka", "nats":
+	default:
+		invalid("broker", "must be log, kafka or nats, got %q", c.Broker)
+	}
+
+	if c.OIDCIssuer != "" {
+		if !isAbsoluteURL(c.OIDCIssuer) {
+			invalid("oidcIssuer", "must be an absolute URL, got %q", c.OIDCIssuer)
+		}
+
+		if c.OIDCClientID == "" {
+			invalid("oidcClientId", "must be set with oidcIssuer")
+		}
+	}
+
+	if c.OIDCJWKSURL != "" && !isAbsoluteURL(c.OIDCJWKSURL) {
+		invalid("oidcJwksUrl", "must be an absolute URL, got %q", c.OIDCJWKSURL)
+	}
+
+	if c.RateLimit < 0 {
+		invalid("rateLimit", "must not be negative")
+	}
+
+	if c.RateLimit > 0 && c.RateBurst < 1 {
+		invalid("rateBurst", "must be at least 1")
+	}
+
+	switch c.AccountCache {
+	case "off":
+	case "memory":
+		if c.AccountCacheSize < 1 {
+			invalid("accountCacheSize", "must be at least 1")
+		}
+	case "redis":
+		if c.RedisAddr == "" {
+			invalid("redisAddr", "must be set for the redis account cache")
+		}
+	default:
+		invalid("accountCache", "must be off, memory or redis, got %q", c.AccountCache)
+	}
+
+	if c.AccountCache != "off" && c.AccountCacheTTL <= 0 {
+		invalid("accountCacheTtl", "must be positive")
+	}
+
+	if c.AccountNumberLength < minAccountNumberLength || c.AccountNumberLength > maxAccountNumberLength {
+		invalid("accountNumberLength", "must be between %d and %d", minAccountNumberLength, maxAccountNumberLength)
+	}
+
+	if apr, ok := new(big.Rat).SetString(c.SavingsAPR); !ok || apr.Sign() < 0 {
+		invalid("savingsApr", "must be a non-negative rate, got %q", c.SavingsAPR)
+	}
+
+	if _, err := parseTermDepositProducts(c.TermDepositRates); err != nil {
+		invalid("termDepositRates", "%s", err)
+	}
+
+	if c.TermDepositPenaltyDays < 0 {
+		invalid("termDepositPenaltyDays", "must not be negative")
+	}
+
+	if c.TOTPStepUpAmount < 0 {
+		invalid("totpStepUpAmount", "must not be negative")
+	}
+
+	if c.HoldTTL <= 0 {
+		invalid("holdTtl", "must be positive")
+	}
+
+	if c.TransferQuoteTTL <= 0 {
+		invalid("transferQuoteTtl", "must be positive")
+	}
+
+	if c.PaymentRequestTTL <= 0 {
+		invalid("paymentRequestTtl", "must be positive")
+	}
+
+	if c.CardProcessorKey != "" && len(c.CardProcessorKey) < minCardProcessorKeyLength {
+		invalid("cardProcessorKey", "must be at least %d characters (BANK_CARD_PROCESSOR_KEY)", minCardProcessorKeyLength)
+	}
+
+	if c.ReceiptKey != "" && len(c.ReceiptKey) < minReceiptKeyLength {
+		invalid("receiptKey", "must be at least %d characters (BANK_RECEIPT_KEY)", minReceiptKeyLength)
+	}
+
+	if c.CardAuthorizationTTL <= 0 {
+		invalid("cardAuthorizationTtl", "must be positive")
+	}
+
+	if c.ExternalSettlementDelay < 0 {
+		invalid("externalSettlementDelay", "must not be negative")
+	}
+
+	if c.BeneficiaryCoolingOff < 0 {
+		invalid("beneficiaryCoolingOff", "must not be negative")
+	}
+
+	if c.BeneficiaryCoolingOffAmount < 0 {
+		invalid("beneficiaryCoolingOffAmount", "must not be negative")
+	}
+
+	if c.KYCTransferLimit < 0 {
+		invalid("kycTransferLimit", "must not be negative")
+	}
+
+	if c.VerifiedEmailAmount < 0 {
+		invalid("verifiedEmailAmount", "must not be negative")
+	}
+
+	if c.StandingOrderMaxAttempts < 1 {
+		invalid("standingOrderMaxAttempts", "must be at least 1")
+	}
+
+	if c.ReconciliationHour < 0 || c.ReconciliationHour > 23 {
+		invalid("reconciliationHour", "must be between 0 and 23")
+	}
+
+	if c.ReconciliationAlertURL != "" && !isAbsoluteURL(c.ReconciliationAlertURL) {
+		invalid("reconciliationAlertUrl", "must be an absolute URL, got %q", c.ReconciliationAlertURL)
+	}
+
+	if _, err := parseFraudRules(c.FraudRules); err != nil {
+		invalid("fraudRules", "%s", err)
+	}
+
+	if c.FraudVelocityLimit < 1 {
+		invalid("fraudVelocityLimit", "must be at least 1")
+	}
+
+	if c.FraudVelocityWindow <= 0 {
+		invalid("fraudVelocityWindow", "must be positive")
+	}
+
+	if c.FraudLargeAmount < 0 {
+		invalid("fraudLargeAmount", "must not be negative")
+	}
+
+	if _, _, err := parseHourRange(c.FraudUnusualHours); err != nil {
+		invalid("fraudUnusualHours", "%s", err)
+	}
+
+	if c.AMLThreshold < 0 {
+		invalid("amlThreshold", "must not be negative")
+	}
+
+	if c.AMLStructuringCount < 2 {
+		invalid("amlStructuringCount", "must be at least 2")
+	}
+
+	if c.AMLStructuringWindow <= 0 {
+		invalid("amlStructuringWindow", "must be positive")
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateStore checks the settings needed to open the store, which is all
+// the admin commands need.
+func (c *Config) validateStore() error {
+	var errs []error
+
+	invalid := func(field, format string, a ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{field}, a...)...))
+	}
+
+	switch c.Store {
+	case "postgres":
+		if c.DatabaseURL == "" {
+			invalid("databaseUrl", "must be set for the postgres store (DATABASE_URL)")
+		}
+	case "sqlite":
+		if c.SQLitePath == "" {
+			invalid("sqlitePath", "must be set for the sqlite store (BANK_SQLITE_PATH)")
+		}
+	case "memory":
+	default:
+		invalid("store", "must be postgres, sqlite or memory, got %q", c.Store)
+	}
+
+	if c.SeedAccounts < 0 {
+		invalid("seedAccounts", "must not be negative")
+	}
+
+	if err := c.validatePool(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateJWTKeys checks the signing keys of the configured key source; the
+// file and KMS ones are checked when they are loaded.
+func (c *Config) validateJWTKeys() error {
+	switch c.JWTKeySource {
+	case "config":
+	case "file":
+		if c.JWTKeysFile == "" {
+			return errors.New("jwtKeysFile: must be set with the file key source (BANK_JWT_KEYS_FILE)")
+		}
+
+		return nil
+	case "kms":
+		return nil
+	default:
+		return fmt.Errorf("jwtKeySource: must be config, file or kms, got %q", c.JWTKeySource)
+	}
+
+	if (c.JWTSecret != "" || c.JWTKeys == "") && len(c.JWTSecret) < minJWTSecretLength {
+		return fmt.Errorf("jwtSecret: must be at least %d characters (JWT_SECRET)", minJWTSecretLength)
+	}
+
+	keys, err := c.JWTKeyList()
+
+	if err == nil {
+		_, err = newKeyring(keys)
+	}
+
+	if err != nil {
+		return fmt.Errorf("jwtKeys: %w (BANK_JWT_KEYS)", err)
+	}
+
+	return nil
+}
+
+// JWTKeyList returns the signing keys of the config key source: JWTSecret,
+// if set, then JWTKeys.
+func (c *Config) JWTKeyList() ([]*SigningKey, error) {
+	keys, err := parseSigningKeys(c.JWTKeys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.JWTSecret != "" {
+		keys = append([]*SigningKey{{ID: legacyKeyID, Secret: c.JWTSecret}}, keys...)
+	}
+
+	return keys, nil
+}
+
+// validatePool checks the Postgres pool settings, which migrate needs even
+// though it skips the rest of Validate.
+func (c *Config) validatePool() error {
+	var errs []error
+
+	invalid := func(field, format string, a ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{field}, a...)...))
+	}
+
+	if c.DBMaxConns < 1 {
+		invalid("dbMaxConns", "must be at least 1")
+	}
+
+	if c.DBMinConns < 0 || c.DBMinConns > c.DBMaxConns {
+		invalid("dbMinConns", "must be between 0 and dbMaxConns")
+	}
+
+	if c.DBMaxConnIdleTime <= 0 {
+		invalid("dbMaxConnIdleTime", "must be positive")
+	}
+
+	if c.DBMaxConnLifetime <= 0 {
+		invalid("dbMaxConnLifetime", "must be positive")
+	}
+
+	if c.DBHealthCheckPeriod <= 0 {
+		invalid("dbHealthCheckPeriod", "must be positive")
+	}
+
+	if c.DBSlowQuery < 0 {
+		invalid("dbSlowQuery", "must not be negative")
+	}
+
+	if c.DBQueryTimeout < 0 {
+		invalid("dbQueryTimeout", "must not be negative")
+	}
+
+	if c.ReplicaMaxLag < 0 {
+		invalid("replicaMaxLag", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// ReplicaURLs returns the connection strings of DatabaseReplicaURLs.
+func (c *Config) ReplicaURLs() []string {
+	return splitList(c.DatabaseReplicaURLs)
+}
+
+// TermDepositProducts returns TermDepositRates parsed; the config must be
+// valid.
+func (c *Config) TermDepositProducts() []*TermDepositProduct {
+	products, _ := parseTermDepositProducts(c.TermDepositRates)
+	return products
+}
+
+// SavingsRate returns SavingsAPR parsed; the config must be valid.
+func (c *Config) SavingsRate() *big.Rat {
+	apr, _ := new(big.Rat).SetString(c.SavingsAPR)
+	return apr
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.Store = "memory"
+	cfg.JWTSecret = "test-secret-of-some-length"
+	// every test request comes from the same address; the lockout tests
+	// turn it back on
+	cfg.LoginMaxFailures = 0
+
+	return cfg
+}
+
+func testEnv(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, args, err := LoadConfig(nil, testEnv(nil))
+
+	require.Nil(t, err)
+	assert.Equal(t, DefaultConfig(), cfg)
+	assert.Empty(t, args)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bank.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(`
+listenAddr: ":4000"
+grpcAddr: ":4001"
+store: memory
+accessTokenTtl: 5m
+rateLimit: 3
+`), 0o600))
+
+	env := testEnv(map[string]string{
+		"BANK_CONFIG":       path,
+		"BANK_GRPC_ADDR":    ":5001",
+		"BANK_RATE_LIMIT":   "4",
+		"JWT_SECRET":        "from-the-environment",
+		"DATABASE_URL":      "postgres://localhost/bank",
+		"BANK_RATE_BURST":   "7",
+		"BANK_SAVINGS_APR":  "0.03",
+		"BANK_DB_MAX_CONNS": "50",
+
+		"BANK_DATABASE_REPLICA_URLS": "postgres://replica-1/bank, ,postgres://replica-2/bank",
+		"BANK_REQUEST_TIMEOUT":       "5s",
+		"BANK_DB_SLOW_QUERY":         "2s",
+		"BANK_LOGIN_MAX_FAILURES":    "3",
+	})
+
+	cfg, args, err := LoadConfig([]string{"-rate-limit", "5", "migrate", "up"}, env)
+
+	require.Nil(t, err)
+	assert.Equal(t, []string{"migrate", "up"}, args)
+	// file over defaults
+	assert.Equal(t, ":4000", cfg.ListenAddr)
+	assert.Equal(t, "memory", cfg.Store)
+	assert.Equal(t, 5*time.Minute, cfg.AccessTokenTTL)
+	// env over file
+	assert.Equal(t, ":5001", cfg.GRPCAddr)
+	assert.Equal(t, 7, cfg.RateBurst)
+	assert.Equal(t, "from-the-environment", cfg.JWTSecret)
+	assert.Equal(t, "postgres://localhost/bank", cfg.DatabaseURL)
+	assert.Equal(t, "0.03", cfg.SavingsAPR)
+	assert.Equal(t, 50, cfg.DBMaxConns)
+	assert.Equal(t, []string{"postgres://replica-1/bank", "postgres://replica-2/bank"}, cfg.ReplicaURLs())
+	assert.Equal(t, 5*time.Second, cfg.RequestTimeout)
+	assert.Equal(t, 2*time.Second, cfg.DBSlowQuery)
+	assert.Equal(t, 3, cfg.LoginMaxFailures)
+	// flags over env
+	assert.Equal(t, 5.0, cfg.RateLimit)
+}
+
+func TestLoadConfigSeedFlag(t *testing.T) {
+	cfg, args, err := LoadConfig([]string{"-seed", "serve"}, testEnv(nil))
+	require.Nil(t, err)
+	assert.Equal(t, "true", cfg.Seed, "-seed alone seeds the demo fixtures")
+	assert.Equal(t, []string{"serve"}, args)
+
+	cfg, _, err = LoadConfig([]string{"-seed=fixtures.yaml"}, testEnv(nil))
+	require.Nil(t, err)
+	assert.Equal(t, "fixtures.yaml", cfg.Seed)
+
+	cfg, _, err = LoadConfig([]string{"-seed=load-test", "-seed-random", "7"}, testEnv(map[string]string{"BANK_SEED_ACCOUNTS": "50"}))
+	require.Nil(t, err)
+	assert.Equal(t, "load-test", cfg.Seed)
+	assert.Equal(t, int64(7), cfg.SeedRandom)
+	assert.Equal(t, 50, cfg.SeedAccounts)
+}
+
+func TestLoadConfigFileFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bank.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("listenAddr: \":4000\"\n"), 0o600))
+
+	cfg, _, err := LoadConfig([]string{"-config", path}, testEnv(nil))
+
+	require.Nil(t, err)
+	assert.Equal(t, ":4000", cfg.ListenAddr)
+}
+
+func TestLoadConfigLegacyPostgresEnv(t *testing.T) {
+	cfg, _, err := LoadConfig(nil, testEnv(map[string]string{
+		"POSTGRES_USERNAME": "bank",
+		"POSTGRES_PASSWORD": "secret",
+	}))
+
+	require.Nil(t, err)
+	assert.Equal(t, "user=postgres dbname=bank password=secret sslmode=disable", cfg.DatabaseURL)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bank.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("listenAdr: \":4000\"\n"), 0o600))
+
+	_, _, err := LoadConfig([]string{"-config", path}, testEnv(nil))
+	assert.ErrorContains(t, err, "field listenAdr not found")
+
+	_, _, err = LoadConfig(nil, testEnv(map[string]string{"BANK_ACCESS_TOKEN_TTL": "soon"}))
+	assert.ErrorContains(t, err, "BANK_ACCESS_TOKEN_TTL")
+
+	_, _, err = LoadConfig([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, testEnv(nil))
+	assert.ErrorContains(t, err, "reading config")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.Nil(t, testConfig().Validate())
+
+	cfg := DefaultConfig()
+	cfg.JWTSecret = "short"
+	cfg.AccessTokenTTL = time.Hour
+	cfg.RefreshTokenTTL = time.Minute
+	cfg.RateBurst = 0
+	cfg.SavingsAPR = "-1"
+	cfg.TermDepositRates = "6=0.03,6=0.04"
+	cfg.TermDepositPenaltyDays = -1
+	cfg.TOTPStepUpAmount = -1
+	cfg.DBMinConns = cfg.DBMaxConns + 1
+	cfg.DBHealthCheckPeriod = 0
+	cfg.DBSlowQuery = -time.Second
+	cfg.DBQueryTimeout = -time.Second
+	cfg.AccountNumberLength = 20
+	cfg.OIDCIssuer = "accounts.example.com"
+	cfg.KYCTransferLimit = -1
+	cfg.VerifiedEmailAmount = -1
+	cfg.StandingOrderMaxAttempts = 0
+	cfg.ReconciliationHour = 24
+	cfg.ReconciliationAlertURL = "alerts.example.com"
+	cfg.PasswordResetTTL = 0
+	cfg.LoginLockout = 0
+	cfg.Notifier = "pigeon"
+	cfg.Broker = "carrier"
+	cfg.FraudRules = "velocity=panic"
+	cfg.FraudUnusualHours = "6-6"
+	cfg.AccountCache = "redis"
+	cfg.AccountCacheTTL = 0
+	cfg.CardProcessorKey = "short"
+	cfg.ReceiptKey = "short"
+	cfg.CardAuthorizationTTL = 0
+	cfg.ExternalSettlementDelay = -time.Hour
+	cfg.ReplicaMaxLag = -time.Second
+	cfg.RequestTimeout = 0
+	cfg.CORSAllowedOrigins = "https://app.example.com/login"
+	cfg.CORSAllowedMethods = " , "
+	cfg.CORSMaxAge = -time.Minute
+	cfg.TransferQuoteTTL = 0
+	cfg.PaymentRequestTTL = 0
+	cfg.StatementLinkTTL = 48 * time.Hour
+	cfg.AMLThreshold = -1
+	cfg.AMLStructuringCount = 1
+	cfg.SeedAccounts = -1
+	cfg.AMLStructuringWindow = 0
+
+	err := cfg.Validate()
+
+	require.NotNil(t, err)
+
+	for _, field := range []string{"databaseUrl", "jwtSecret", "refreshTokenTtl", "rateBurst", "savingsApr", "termDepositRates", "termDepositPenaltyDays", "totpStepUpAmount", "dbMinConns", "dbHealthCheckPeriod", "dbSlowQuery", "dbQueryTimeout", "accountNumberLength", "oidcIssuer", "oidcClientId", "kycTransferLimit", "verifiedEmailAmount", "passwordResetTtl", "loginLockout", "notifier", "broker", "standingOrderMaxAttempts", "reconciliationHour", "reconciliationAlertUrl", "fraudRules", "fraudUnusualHours", "redisAddr", "accountCacheTtl", "cardProcessorKey", "receiptKey", "cardAuthorizationTtl", "externalSettlementDelay", "replicaMaxLag", "requestTimeout", "corsAllowedOrigins", "corsAllowedMethods", "corsMaxAge", "transferQuoteTtl", "paymentRequestTtl", "statementLinkTtl", "amlThreshold", "amlStructuringCount", "amlStructuringWindow", "seedAccounts"} {
+		assert.ErrorContains(t, err, field+":")
+	}
+
+	cfg = testConfig()
+	cfg.JWTSecret = ""
+	cfg.JWTKeys = "2024-01:first-secret-of-some-length"
+	assert.Nil(t, cfg.Validate(), "the secret is optional with other keys")
+
+	cfg.JWTKeys = "2024-01:first-secret-of-some-length,2024-01:second-secret-of-some-length"
+	assert.ErrorContains(t, cfg.Validate(), "jwtKeys: signing key 2024-01 is listed twice")
+
+	cfg.JWTKeys = "2024-01:short"
+	assert.ErrorContains(t, cfg.Validate(), "jwtKeys: signing key 2024-01 must be at least")
+
+	cfg.JWTKeySource = "file"
+	assert.ErrorContains(t, cfg.Validate(), "jwtKeysFile:")
+
+	cfg.JWTKeySource = "vault"
+	assert.ErrorContains(t, cfg.Validate(), "jwtKeySource:")
+
+	cfg = testConfig()
+	cfg.Store = "sqlite"
+	assert.ErrorContains(t, cfg.Validate(), "sqlitePath: must be set for the sqlite store")
+
+	cfg.SQLitePath = "bank.db"
+	assert.Nil(t, cfg.Validate())
+
+	cfg.Store = "mysql"
+	assert.ErrorContains(t, cfg.Validate(), `store: must be postgres, sqlite or memory, got "mysql"`)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	contactCodeTTL = 15 * time.Minute
+	// addressCodeTTL leaves time for the letter with the code to arrive.
+	addressCodeTTL = 30 * 24 * time.Hour
+)
+
+// values returns the normalized contact details req sets, by kind. req must
+// have passed Validate.
+func (req *ContactRequest) values() map[ContactKind]string {
+	values := map[ContactKind]string{}
+
+	if req.Email != "" {
+		_, values[ContactEmail], _ = parseAlias(req.Email)
+	}
+
+	if req.Phone != "" {
+		_, values[ContactPhone], _ = parseAlias(req.Phone)
+	}
+
+	if address := strings.TrimSpace(req.Address); address != "" {
+		values[ContactAddress] = address
+	}
+
+	return values
+}
+
+// NewContactDetail is the unverified contact detail of the number account
+// and the code that verifies it.
+func NewContactDetail(number int64, kind ContactKind, value string, now time.Time) (*ContactDetail, string, error) {
+	code, err := newVerificationCode()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	ttl := contactCodeTTL
+
+	if kind == ContactAddress {
+		ttl = addressCodeTTL
+	}
+
+	detail := &ContactDetail{
+		AccountNumber: number,
+		Kind:          kind,
+		Value:         value,
+		UpdatedAt:     now,
+		CodeHash:      hashToken(code),
+		CodeExpiresAt: now.Add(ttl),
+	}
+
+	return detail, code, nil
+}
+
+// checkCode returns an error unless codeHash verifies the detail at now. A
+// wrong code counts as an attempt, which the caller must store.
+func (d *ContactDetail) checkCode(codeHash string, now time.Time) error {
+	if d.VerifiedAt != nil {
+		return conflictError("%s %s is already verified", d.Kind, d.Value)
+	}
+
+	if d.Attempts >= maxAliasCodeAttempts || !now.Before(d.CodeExpiresAt) {
+		return conflictError("the verification code of %s %s expired, set it again for a new one", d.Kind, d.Value)
+	}
+
+	if codeHash != d.CodeHash {
+		d.Attempts++
+		return validationError("invalid code")
+	}
+
+	return nil
+}
+
+// NewContact sorts the contact details of an account by kind.
+func NewContact(details []*ContactDetail) *Contact {
+	contact := new(Contact)
+
+	for _, d := range details {
+		switch d.Kind {
+		case ContactEmail:
+			contact.Email = d
+		case ContactPhone:
+			contact.Phone = d
+		case ContactAddress:
+			contact.Address = d
+		}
+	}
+
+	return contact
+}
+
+func (c *Contact) detail(kind ContactKind) *ContactDetail {
+	switch kind {
+	case ContactEmail:
+		return c.Email
+	case ContactPhone:
+		return c.Phone
+	case ContactAddress:
+		return c.Address
+	}
+
+	return nil
+}
+
+// checkVerifiedEmail refuses transfers above threshold from accounts without
+// a verified email, so their holder can be reached about them. A threshold
+// of 0 disables the check.
+func checkVerifiedEmail(ctx context.Context, store ContactRepository, threshold, from, amount int64) error {
+	if threshold <= 0 || amount <= threshold {
+		return nil
+	}
+
+	details, err := store.GetContactDetails(ctx, from)
+
+	if err != nil {
+		return err
+	}
+
+	if email := NewContact(details).Email; email == nil || email.VerifiedAt == nil {
+		return emailUnverifiedError(from, threshold)
+	}
+
+	return nil
+}
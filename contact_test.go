@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verifyEmail gives acc a verified email, so it can make large transfers.
+func (a *testAPI) verifyEmail(acc *Account) {
+	now := time.Now().UTC()
+	detail := &ContactDetail{AccountNumber: acc.Number, Kind: ContactEmail, Value: "holder@example.com", VerifiedAt: &now, UpdatedAt: now}
+	require.Nil(a.t, a.store.SetContactDetail(context.Background(), detail))
+}
+
+func TestContactRequestValidate(t *testing.T) {
+	assert.Nil(t, (&ContactRequest{Email: "alice@example.com"}).Validate())
+	assert.Nil(t, (&ContactRequest{Phone: "+1 555 010 0000", Address: "1 Main St"}).Validate())
+
+	for _, req := range []*ContactRequest{
+		{},
+		{Address: " "},
+		{Email: "+15550100000"},
+		{Phone: "alice@example.com"},
+		{Address: strings.Repeat("a", maxAddressLength+1)},
+	} {
+		assert.NotNil(t, req.Validate(), req)
+	}
+}
+
+func TestMemoryStoreContactDetails(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 42, Currency: "USD"}))
+
+	email, code, err := NewContactDetail(42, ContactEmail, "alice@example.com", now)
+	require.Nil(t, err)
+	require.Nil(t, store.SetContactDetail(ctx, email))
+
+	address, _, err := NewContactDetail(42, ContactAddress, "1 Main St", now)
+	require.Nil(t, err)
+	assert.Equal(t, now.Add(addressCodeTTL), address.CodeExpiresAt, "letters take longer to arrive")
+
+	assert.Equal(t, http.StatusNotFound, store.SetContactDetail(ctx, &ContactDetail{AccountNumber: 43, Kind: ContactEmail}).(*HTTPError).Status)
+
+	_, err = store.VerifyContactDetail(ctx, 42, ContactPhone, hashToken(code), now)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+
+	_, err = store.VerifyContactDetail(ctx, 42, ContactEmail, hashToken("000000"+code), now)
+	assert.ErrorContains(t, err, "invalid code")
+
+	_, err = store.VerifyContactDetail(ctx, 42, ContactEmail, hashToken(code), email.CodeExpiresAt)
+	assert.ErrorContains(t, err, "expired")
+
+	verified, err := store.VerifyContactDetail(ctx, 42, ContactEmail, hashToken(code), now)
+	require.Nil(t, err)
+	assert.NotNil(t, verified.VerifiedAt)
+
+	details, err := store.GetContactDetails(ctx, 42)
+	require.Nil(t, err)
+	assert.Equal(t, "alice@example.com", NewContact(details).Email.Value)
+
+	// a new value starts over unverified
+	changed, _, err := NewContactDetail(42, ContactEmail, "alice@example.org", now)
+	require.Nil(t, err)
+	require.Nil(t, store.SetContactDetail(ctx, changed))
+
+	details, err = store.GetContactDetails(ctx, 42)
+	require.Nil(t, err)
+	assert.Nil(t, NewContact(details).Email.VerifiedAt)
+
+	require.Nil(t, store.DeleteContactDetail(ctx, 42, ContactEmail))
+	assert.NotNil(t, store.DeleteContactDetail(ctx, 42, ContactEmail))
+}
+
+func TestAPIContact(t *testing.T) {
+	cfg := testConfig()
+	cfg.KYCTransferLimit = 0
+	api := newTestAPIWithConfig(t, cfg)
+	notifier := new(recordingNotifier)
+	api.server.notifier = notifier
+
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/contact"
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 500000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	large := TransferRequest{ToAccount: int(bob.Number), Amount: 100001}
+
+	rec = api.do("POST", "/transfer", token, large)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeEmailUnverified))
+
+	rec = api.do("PUT", path, api.login(bob, "bob-pw"), ContactRequest{Email: "alice@example.com"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("PUT", path, token, ContactRequest{Email: "Alice@Example.com", Address: "1 Main St"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	contact := new(Contact)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(contact))
+	assert.Equal(t, "alice@example.com", contact.Email.Value)
+	assert.Nil(t, contact.Email.VerifiedAt)
+	assert.Nil(t, contact.Phone)
+	assert.NotContains(t, rec.Body.String(), "code")
+
+	require.Len(t, notifier.notifications, 2)
+	n := notifier.notifications[0]
+	assert.Equal(t, "alice@example.com", n.To)
+	code := n.Body[strings.LastIndex(n.Body, " ")+1:]
+
+	rec = api.do("POST", path+"/fax/verify", token, ContactVerifyRequest{Code: code})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = api.do("POST", path+"/email/verify", token, ContactVerifyRequest{Code: code})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, large)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// setting the verified value again changes nothing
+	rec = api.do("PUT", path, token, ContactRequest{Email: "alice@example.com"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Len(t, notifier.notifications, 2)
+
+	rec = api.do("PUT", path, token, ContactRequest{Email: "alice@example.org"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Len(t, notifier.notifications, 3)
+
+	rec = api.do("POST", "/transfer", token, large)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the new email isn't verified yet")
+
+	rec = api.do("DELETE", path+"/address", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path, token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), `"address"`)
+}
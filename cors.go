@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsExposedHeaders are the response headers browsers let scripts read.
+var corsExposedHeaders = []string{"X-Request-ID", "Retry-After", "ETag", "Idempotent-Replayed", "Deprecation", "Content-Disposition"}
+
+// CORSPolicy lets browser frontends served from other origins call the API.
+type CORSPolicy struct {
+	// Origins are the allowed origins, e.g. https://app.example.com, or *
+	// for any.
+	Origins []string
+	Methods []string
+	// Headers are the request headers allowed, compared case insensitively.
+	Headers []string
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// newCORSPolicy returns nil when no origin is allowed, cross-origin
+// requests are left to the browser's same-origin policy then.
+func newCORSPolicy(cfg *Config) *CORSPolicy {
+	origins := splitList(cfg.CORSAllowedOrigins)
+
+	if len(origins) == 0 {
+		return nil
+	}
+
+	return &CORSPolicy{
+		Origins: origins,
+		Methods: splitList(strings.ToUpper(cfg.CORSAllowedMethods)),
+		Headers: splitList(strings.ToLower(cfg.CORSAllowedHeaders)),
+		MaxAge:  cfg.CORSMaxAge,
+	}
+}
+
+func (p *CORSPolicy) allowsOrigin(origin string) bool {
+	return slices.Contains(p.Origins, "*") || slices.Contains(p.Origins, origin)
+}
+
+// allowsPreflight reports whether the method and headers a preflight asks
+// for are all allowed.
+func (p *CORSPolicy) allowsPreflight(method, headers string) bool {
+	if !slices.Contains(p.Methods, method) {
+		return false
+	}
+
+	for _, header := range splitList(strings.ToLower(headers)) {
+		if !slices.Contains(p.Headers, header) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// withCORS answers preflight requests itself, before they reach the
+// router, and adds the CORS headers to the responses of allowed origins.
+// Requests without an Origin, and those of other origins, are served as
+// usual: without the headers the browser won't let the page read them.
+// Preflights of other origins, or asking for a method or header that isn't
+// allowed, are refused with a 403.
+func withCORS(policy *CORSPolicy) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !policy.allowsOrigin(origin) {
+				if preflight {
+					writeError(w, r, forbiddenError("origin %s is not allowed", origin))
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowOrigin := origin
+
+			if slices.Contains(policy.Origins, "*") {
+				allowOrigin = "*"
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+
+			if !preflight {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !policy.allowsPreflight(r.Header.Get("Access-Control-Request-Method"), r.Header.Get("Access-Control-Request-Headers")) {
+				writeError(w, r, forbiddenError("method or headers not allowed for cross-origin requests"))
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.Methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+
+			if policy.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// splitList returns the non-empty items of a comma separated list.
+func splitList(s string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPICORS(t *testing.T) {
+	cfg := testConfig()
+	cfg.CORSAllowedOrigins = "https://app.example.com"
+	cfg.CORSMaxAge = time.Minute
+	api := newTestAPIWithConfig(t, cfg)
+
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+
+	serve := func(method, origin string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/account", nil)
+		req.Header = header
+		req.Header.Set("Origin", origin)
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		return serve("OPTIONS", origin, http.Header{
+			"Access-Control-Request-Method":  {method},
+			"Access-Control-Request-Headers": {headers},
+		})
+	}
+
+	rec := preflight("https://app.example.com", "POST", "Content-Type, X-JWT-Token")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), "POST")
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "x-jwt-token")
+	assert.Equal(t, "60", rec.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+
+	rec = preflight("https://evil.example.com", "POST", "x-jwt-token")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	rec = preflight("https://app.example.com", "TRACE", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the method isn't allowed")
+
+	rec = preflight("https://app.example.com", "GET", "x-custom")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the header isn't allowed")
+
+	rec = serve("GET", "https://app.example.com", http.Header{"X-Jwt-Token": {token}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+
+	rec = serve("GET", "https://evil.example.com", http.Header{"X-Jwt-Token": {token}})
+	assert.Equal(t, http.StatusOK, rec.Code, "the browser, not the API, keeps other origins out")
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestAPICORSDisabled(t *testing.T) {
+	api := newTestAPI(t)
+
+	req := httptest.NewRequest("OPTIONS", "/account", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+
+	rec := httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+
+	assert.NotEqual(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransactionCursor is the position of a transaction in the feed, which is
+// ordered by created_at then id, newest first. Clients only see it encoded.
+type TransactionCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+func newTransactionCursor(t *Transaction) *TransactionCursor {
+	return &TransactionCursor{CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+// precedes reports whether the cursor comes before t in the feed, that is
+// whether t is older.
+func (c *TransactionCursor) precedes(t *Transaction) bool {
+	if t.CreatedAt.Equal(c.CreatedAt) {
+		return t.ID < c.ID
+	}
+
+	return t.CreatedAt.Before(c.CreatedAt)
+}
+
+func (c *TransactionCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTransactionCursor(s string) (*TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return nil, badRequestError("invalid cursor %s", s)
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), ",")
+
+	if !ok {
+		return nil, badRequestError("invalid cursor %s", s)
+	}
+
+	cursor := new(TransactionCursor)
+
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, badRequestError("invalid cursor %s", s)
+	}
+
+	if cursor.ID, err = strconv.Atoi(id); err != nil {
+		return nil, badRequestError("invalid cursor %s", s)
+	}
+
+	return cursor, nil
+}
+
+// getTransactionCursorFromQueryParams returns the cursor query parameter,
+// nil for the first page.
+func getTransactionCursorFromQueryParams(r *http.Request) (*TransactionCursor, error) {
+	v := r.URL.Query().Get("cursor")
+
+	if v == "" {
+		return nil, nil
+	}
+
+	return decodeTransactionCursor(v)
+}
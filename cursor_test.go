@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionCursorEncoding(t *testing.T) {
+	cursor := &TransactionCursor{CreatedAt: time.Date(2024, 3, 15, 13, 0, 0, 123456789, time.UTC), ID: 42}
+
+	decoded, err := decodeTransactionCursor(cursor.Encode())
+	require.Nil(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	for _, invalid := range []string{"!!", "bm9jb21tYQ", "eCw0Mg", "MjAyNC0wMy0xNVQxMzowMDowMFoseA"} {
+		_, err := decodeTransactionCursor(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestMemoryStoreGetTransactionsBefore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 1, Currency: "USD", Status: AccountActive}))
+
+	for i := 1; i <= 5; i++ {
+		_, err := store.Deposit(ctx, 1, int64(i), 0)
+		require.Nil(t, err)
+	}
+
+	// entries created in the same instant are ordered by id
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, transaction := range store.transactions {
+		transaction.CreatedAt = at
+	}
+
+	first, err := store.GetTransactionsBefore(ctx, 1, nil, 2)
+	require.Nil(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, []int64{5, 4}, []int64{first[0].Amount, first[1].Amount})
+
+	rest, err := store.GetTransactionsBefore(ctx, 1, newTransactionCursor(first[1]), 10)
+	require.Nil(t, err)
+	require.Len(t, rest, 3)
+	assert.Equal(t, []int64{3, 2, 1}, []int64{rest[0].Amount, rest[1].Amount, rest[2].Amount})
+}
+
+func TestAPITransactionsCursor(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/transactions"
+
+	for i := 1; i <= 5; i++ {
+		rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: int64(i)})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	amounts := []int64{}
+	query := "?limit=2"
+
+	for {
+		rec := api.do("GET", "/v2"+path+query, token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		page := new(TransactionPage)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(page))
+
+		for _, transaction := range page.Transactions {
+			amounts = append(amounts, transaction.Amount)
+		}
+
+		if !page.HasMore {
+			assert.Empty(t, page.NextCursor)
+			break
+		}
+
+		// new entries do not shift the pages after the cursor
+		rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 100})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		query = "?limit=2&cursor=" + page.NextCursor
+	}
+
+	assert.Equal(t, []int64{5, 4, 3, 2, 1}, amounts)
+
+	rec := api.do("GET", "/v2"+path+"?offset=2", token, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = api.do("GET", "/v2"+path+"?cursor=!!", token, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// /v1 keeps offset pagination
+	rec = api.do("GET", "/v1"+path+"?limit=2&offset=1", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var transactions []*Transaction
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&transactions))
+	assert.Len(t, transactions, 2)
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	datasetVersion = 1
+	maxDatasetSize = 256 << 20
+)
+
+type DatasetFormat string
+
+const (
+	// DatasetNDJSON archives hold one JSON record per line, a header then
+	// every account, event, journal entry, transaction and transfer.
+	DatasetNDJSON DatasetFormat = "ndjson"
+	// DatasetJSON archives are a single JSON document.
+	DatasetJSON DatasetFormat = "json"
+)
+
+// datasetContentTypes are the media types archives are served and accepted
+// as.
+var datasetContentTypes = map[DatasetFormat]string{
+	DatasetNDJSON: "application/x-ndjson",
+	DatasetJSON:   "application/json",
+}
+
+// Dataset is a portable copy of the bank: every account, deleted ones
+// included, with its event history, the journal with the account
+// transactions posted to it, and the transfers. Ids and account numbers are
+// kept, so an archive imported into a fresh instance reads the same as the
+// one it was exported from. Holds, pots, cards, loans and the rest of the
+// per-account records are not part of it.
+type Dataset struct {
+	Version        int                `json:"version"`
+	ExportedAt     time.Time          `json:"exportedAt"`
+	Accounts       []*ArchivedAccount `json:"accounts"`
+	AccountEvents  []*AccountEvent    `json:"accountEvents"`
+	JournalEntries []*JournalEntry    `json:"journalEntries"`
+	Transactions   []*Transaction     `json:"transactions"`
+	Transfers      []*Transfer        `json:"transfers"`
+}
+
+// ArchivedAccount is an account with the password hash and interest state
+// the API never shows, so holders can log in to the imported instance and
+// interest carries on accruing where it stopped.
+type ArchivedAccount struct {
+	*Account
+	EncryptedPassword      string     `json:"encryptedPassword"`
+	InterestRemainder      int64      `json:"interestRemainder"`
+	InterestAccruedThrough *time.Time `json:"interestAccruedThrough,omitempty"`
+}
+
+// MarshalJSON keeps the archived fields, which the promoted MarshalJSON of
+// Account would leave out.
+func (a ArchivedAccount) MarshalJSON() ([]byte, error) {
+	type account Account
+
+	return json.Marshal(struct {
+		*account
+		EncryptedPassword      string     `json:"encryptedPassword"`
+		InterestRemainder      int64      `json:"interestRemainder"`
+		InterestAccruedThrough *time.Time `json:"interestAccruedThrough,omitempty"`
+	}{(*account)(a.Account), a.EncryptedPassword, a.InterestRemainder, a.InterestAccruedThrough})
+}
+
+func NewArchivedAccount(acc *Account) *ArchivedAccount {
+	return &ArchivedAccount{
+		Account:                acc,
+		EncryptedPassword:      acc.EncryptedPassword,
+		InterestRemainder:      acc.InterestRemainder,
+		InterestAccruedThrough: acc.InterestAccruedThrough,
+	}
+}
+
+// DatasetSummary counts what an archive holds.
+type DatasetSummary struct {
+	Accounts       int `json:"accounts"`
+	AccountEvents  int `json:"accountEvents"`
+	JournalEntries int `json:"journalEntries"`
+	Transactions   int `json:"transactions"`
+	Transfers      int `json:"transfers"`
+}
+
+func (d *Dataset) Summary() *DatasetSummary {
+	return &DatasetSummary{
+		Accounts:       len(d.Accounts),
+		AccountEvents:  len(d.AccountEvents),
+		JournalEntries: len(d.JournalEntries),
+		Transactions:   len(d.Transactions),
+		Transfers:      len(d.Transfers),
+	}
+}
+
+// accountKeys returns the ids and numbers of the archived accounts.
+func (d *Dataset) accountKeys() ([]int, []int64) {
+	ids := make([]int, len(d.Accounts))
+	numbers := make([]int64, len(d.Accounts))
+
+	for i, a := range d.Accounts {
+		ids[i], numbers[i] = a.ID, a.Number
+	}
+
+	return ids, numbers
+}
+
+// datasetRecord is a line of an NDJSON archive.
+type datasetRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// datasetHeader opens an NDJSON archive.
+type datasetHeader struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// WriteDataset writes d to w as an archive in format.
+func WriteDataset(w io.Writer, d *Dataset, format DatasetFormat) error {
+	if format == DatasetJSON {
+		return json.NewEncoder(w).Encode(d)
+	}
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+
+	write := func(kind string, v any) error {
+		data, err := json.Marshal(v)
+
+		if err != nil {
+			return err
+		}
+
+		return encoder.Encode(datasetRecord{Type: kind, Data: data})
+	}
+
+	if err := write("header", datasetHeader{Version: d.Version, ExportedAt: d.ExportedAt}); err != nil {
+		return err
+	}
+
+	for _, a := range d.Accounts {
+		if err := write("account", a); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range d.AccountEvents {
+		if err := write("account_event", e); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range d.JournalEntries {
+		if err := write("journal_entry", e); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range d.Transactions {
+		if err := write("transaction", t); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range d.Transfers {
+		if err := write("transfer", t); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadDataset reads an archive in format and checks it with Validate.
+// Holds and pots are not archived, so the balances they held or set aside
+// are spendable again.
+func ReadDataset(r io.Reader, format DatasetFormat) (*Dataset, error) {
+	d := new(Dataset)
+
+	if format == DatasetJSON {
+		if err := json.NewDecoder(r).Decode(d); err != nil {
+			return nil, archiveError(err, "invalid archive")
+		}
+	} else if err := readNDJSONDataset(r, d); err != nil {
+		return nil, err
+	}
+
+	for _, a := range d.Accounts {
+		if a.Account == nil {
+			return nil, badRequestError("invalid archive: empty account")
+		}
+
+		a.Account.EncryptedPassword = a.EncryptedPassword
+		a.Account.InterestRemainder = a.InterestRemainder
+		a.Account.InterestAccruedThrough = a.InterestAccruedThrough
+		a.HeldBalance, a.PotBalance = 0, 0
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func readNDJSONDataset(r io.Reader, d *Dataset) error {
+	decoder := json.NewDecoder(r)
+	header := false
+
+	for line := 1; decoder.More(); line++ {
+		var record datasetRecord
+
+		if err := decoder.Decode(&record); err != nil {
+			return archiveError(err, fmt.Sprintf("invalid archive at record %d", line))
+		}
+
+		var target any
+
+		switch {
+		case !header && record.Type != "header":
+			return badRequestError("invalid archive: it must start with a header")
+		case record.Type == "header":
+			if header {
+				return badRequestError("invalid archive: second header at record %d", line)
+			}
+
+			header = true
+			target = &datasetHeader{}
+		case record.Type == "account":
+			target = &ArchivedAccount{}
+		case record.Type == "account_event":
+			target = &AccountEvent{}
+		case record.Type == "journal_entry":
+			target = &JournalEntry{}
+		case record.Type == "transaction":
+			target = &Transaction{}
+		case record.Type == "transfer":
+			target = &Transfer{}
+		default:
+			return badRequestError("invalid archive: unknown record type %q at record %d", record.Type, line)
+		}
+
+		if err := json.Unmarshal(record.Data, target); err != nil {
+			return badRequestError("invalid archive at record %d: %s", line, err)
+		}
+
+		switch v := target.(type) {
+		case *datasetHeader:
+			d.Version, d.ExportedAt = v.Version, v.ExportedAt
+		case *ArchivedAccount:
+			d.Accounts = append(d.Accounts, v)
+		case *AccountEvent:
+			d.AccountEvents = append(d.AccountEvents, v)
+		case *JournalEntry:
+			d.JournalEntries = append(d.JournalEntries, v)
+		case *Transaction:
+			d.Transactions = append(d.Transactions, v)
+		case *Transfer:
+			d.Transfers = append(d.Transfers, v)
+		}
+	}
+
+	if !header {
+		return badRequestError("invalid archive: it must start with a header")
+	}
+
+	return nil
+}
+
+// archiveError reports a malformed archive as a bad request, and passes on
+// the errors reading it, like the body being too large.
+func archiveError(err error, prefix string) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return badRequestError("%s: %s", prefix, err)
+	}
+
+	return err
+}
+
+// Validate checks that the archive can be imported: ids and account
+// numbers are unique, every journal entry balances and transactions point at
+// archived entries and accounts. Whether balances match the ledger is left
+// to the integrity check, so a restore brings back the books as they were.
+func (d *Dataset) Validate() error {
+	errs := FieldErrors{}
+
+	if d.Version != datasetVersion {
+		errs.Add("version", "must be %d, got %d", datasetVersion, d.Version)
+		return errs.Err()
+	}
+
+	ids := map[int]bool{}
+	numbers := map[int64]bool{}
+
+	for _, a := range d.Accounts {
+		if ids[a.ID] || numbers[a.Number] {
+			errs.Add("accounts", "account %d (id %d) is archived twice", a.Number, a.ID)
+		}
+
+		ids[a.ID], numbers[a.Number] = true, true
+	}
+
+	versions := map[[2]int64]bool{}
+
+	for _, e := range d.AccountEvents {
+		key := [2]int64{e.AccountNumber, int64(e.Version)}
+
+		if versions[key] {
+			errs.Add("accountEvents", "version %d of account %d is archived twice", e.Version, e.AccountNumber)
+		}
+
+		versions[key] = true
+	}
+
+	entries := map[int]bool{}
+	lines := map[int]bool{}
+
+	for _, entry := range d.JournalEntries {
+		if entries[entry.ID] {
+			errs.Add("journalEntries", "entry %d is archived twice", entry.ID)
+		}
+
+		entries[entry.ID] = true
+
+		if err := entry.Validate(); err != nil {
+			errs.Add("journalEntries", "%s", err)
+		}
+
+		for _, line := range entry.Lines {
+			if lines[line.ID] {
+				errs.Add("journalEntries", "line %d is archived twice", line.ID)
+			}
+
+			lines[line.ID] = true
+		}
+	}
+
+	transactions := map[int]bool{}
+
+	for _, t := range d.Transactions {
+		if transactions[t.ID] {
+			errs.Add("transactions", "transaction %d is archived twice", t.ID)
+		}
+
+		transactions[t.ID] = true
+
+		if !entries[t.JournalID] {
+			errs.Add("transactions", "transaction %d is posted to entry %d, which is not archived", t.ID, t.JournalID)
+		}
+
+		if !numbers[t.AccountNumber] {
+			errs.Add("transactions", "transaction %d is on account %d, which is not archived", t.ID, t.AccountNumber)
+		}
+	}
+
+	transfers := map[int]bool{}
+
+	for _, t := range d.Transfers {
+		if transfers[t.ID] {
+			errs.Add("transfers", "transfer %d is archived twice", t.ID)
+		}
+
+		transfers[t.ID] = true
+	}
+
+	return errs.Err()
+}
+
+func (s *DatasetSummary) String() string {
+	return fmt.Sprintf("%d accounts, %d account events, %d journal entries, %d transactions, %d transfers",
+		s.Accounts, s.AccountEvents, s.JournalEntries, s.Transactions, s.Transfers)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDatasetStore returns a store where money has moved between two
+// accounts, one of them deleted since.
+func newDatasetStore(t *testing.T) (*MemoryStore, *Account, *Account) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	alice, err := NewAccount("Alice", "Smith", "alice-pw")
+	require.Nil(t, err)
+	bob, err := NewAccount("Bob", "Jones", "bob-pw")
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAccount(ctx, alice))
+	require.Nil(t, store.CreateAccount(ctx, bob))
+
+	_, err = store.Deposit(ctx, alice.Number, 1000, 0)
+	require.Nil(t, err)
+	require.Nil(t, store.Transfer(ctx, &Transfer{FromAccount: alice.Number, ToAccount: bob.Number, Amount: 300, Currency: "USD", ToAmount: 300, ToCurrency: "USD", CreatedAt: now}))
+	_, err = store.Withdraw(ctx, bob.Number, 300, 0)
+	require.Nil(t, err)
+	_, err = store.DeleteAccount(ctx, bob.ID, now)
+	require.Nil(t, err)
+
+	return store, alice, bob
+}
+
+func TestDatasetRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, alice, bob := newDatasetStore(t)
+
+	dataset, err := store.ExportDataset(ctx, time.Now().UTC())
+	require.Nil(t, err)
+	assert.Equal(t, &DatasetSummary{Accounts: 2, AccountEvents: 6, JournalEntries: 3, Transactions: 4, Transfers: 1}, dataset.Summary())
+
+	for _, format := range []DatasetFormat{DatasetNDJSON, DatasetJSON} {
+		var archive bytes.Buffer
+		require.Nil(t, WriteDataset(&archive, dataset, format))
+
+		read, err := ReadDataset(&archive, format)
+		require.Nil(t, err, format)
+
+		imported := NewMemoryStore()
+		require.Nil(t, imported.ImportDataset(ctx, read))
+
+		acc, err := imported.GetAccountByNumber(ctx, int(alice.Number))
+		require.Nil(t, err)
+		assert.Equal(t, alice.ID, acc.ID)
+		assert.Equal(t, int64(700), acc.Balance)
+		assert.True(t, acc.ValidPassword("alice-pw"), "password hashes are archived")
+
+		_, err = imported.GetAccountByNumber(ctx, int(bob.Number))
+		assert.NotNil(t, err, "deleted accounts stay deleted")
+
+		transactions, err := imported.GetTransactions(ctx, alice.Number, 10, 0)
+		require.Nil(t, err)
+		assert.Len(t, transactions, 2)
+
+		report, err := imported.CheckLedgerIntegrity(ctx)
+		require.Nil(t, err)
+		assert.True(t, report.Balanced)
+
+		projections, err := CheckProjections(ctx, imported)
+		require.Nil(t, err)
+		assert.Empty(t, projections.Mismatches)
+
+		// new records carry on after the imported ids
+		deposit, err := imported.Deposit(ctx, alice.Number, 50, 0)
+		require.Nil(t, err)
+		assert.Equal(t, dataset.Transactions[len(dataset.Transactions)-1].ID+1, deposit.ID)
+
+		err = imported.ImportDataset(ctx, read)
+		assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status, "money has moved since")
+	}
+
+	fresh := NewMemoryStore()
+	require.Nil(t, fresh.CreateAccount(ctx, &Account{Number: alice.Number, Currency: "USD"}))
+
+	err = fresh.ImportDataset(ctx, dataset)
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status, "account numbers can't be taken twice")
+}
+
+func TestReadDatasetErrors(t *testing.T) {
+	header := `{"type":"header","data":{"version":1,"exportedAt":"2024-01-01T00:00:00Z"}}` + "\n"
+
+	for _, c := range [][2]string{
+		{"", "must start with a header"},
+		{`{"type":"account","data":{}}`, "must start with a header"},
+		{header + `{"type":"loan","data":{}}`, "unknown record type"},
+		{header + `{"type":"account"`, "invalid archive at record 2"},
+		{strings.Replace(header, `"version":1`, `"version":2`, 1), "version must be 1"},
+		{header + `{"type":"transaction","data":{"id":1,"journalId":7,"accountNumber":42}}`, "entry 7, which is not archived"},
+		{header + `{"type":"journal_entry","data":{"id":1,"lines":[{"id":1,"ledger":"cash","currency":"USD","amount":5}]}}`, "is off by 5 USD"},
+	} {
+		_, err := ReadDataset(strings.NewReader(c[0]), DatasetNDJSON)
+		assert.ErrorContains(t, err, c[1], c[0])
+	}
+
+	_, err := ReadDataset(strings.NewReader("[]"), DatasetJSON)
+	assert.Equal(t, http.StatusBadRequest, err.(*HTTPError).Status)
+}
+
+func TestAPIDataset(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	alice := api.createAccount("Alice", "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+	aliceToken := api.login(alice, "alice-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", adminToken, AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/export", aliceToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/admin/export?format=xml", adminToken, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = api.do("GET", "/admin/export", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), ".ndjson")
+	archive := rec.Body.String()
+
+	upload := func(api *testAPI, token, contentType, archive string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/admin/import", strings.NewReader(archive))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("x-jwt-token", token)
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, r)
+
+		return rec
+	}
+
+	rec = upload(api, adminToken, "application/x-ndjson", archive)
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	// the new instance's own admin must not collide with the archive
+	clone := newTestAPI(t)
+	clone.store.ids["account"] = 100
+	cloneAdmin := clone.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	cloneToken := clone.login(cloneAdmin, "admin-pw")
+
+	rec = upload(clone, cloneToken, "text/csv", archive)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = upload(clone, cloneToken, "application/x-ndjson", archive)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	summary := new(DatasetSummary)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(summary))
+	assert.Equal(t, 2, summary.Accounts)
+	assert.Equal(t, 1, summary.Transactions)
+
+	token := clone.login(alice, "alice-pw")
+	rec = clone.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/transactions", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"amount":1000`)
+}
+
+func TestCLIExportImport(t *testing.T) {
+	source := newTestCLI(t)
+	store, alice, _ := newDatasetStore(t)
+	source.store = store
+
+	require.Nil(t, source.run("", "export"))
+	archive := source.stdout.String()
+
+	assert.ErrorContains(t, source.run("", "export", "--format", "gob"), "format must be")
+
+	target := newTestCLI(t)
+	require.Nil(t, target.run(archive, "import"))
+	assert.Contains(t, target.stdout.String(), "imported 2 accounts")
+
+	acc, err := target.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(700), acc.Balance)
+
+	assert.ErrorContains(t, target.run(archive, "import"), "fresh instance")
+}
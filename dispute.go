@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math/big"
+	"slices"
+)
+
+const maxDisputeTextLength = 500
+
+// disputableTransactions are the debits a holder can dispute.
+var disputableTransactions = []TransactionType{TransactionWithdrawal, TransactionTransferOut, TransactionFee}
+
+// open checks that d can be raised on t given the disputes already raised on
+// it: one at a time, and never for more than is left unreversed. A zero
+// Amount disputes all that is left.
+func (d *Dispute) open(t *Transaction, previous []*Dispute) error {
+	if !slices.Contains(disputableTransactions, t.Type) {
+		return validationError("%s entries can't be disputed", t.Type)
+	}
+
+	left := -t.Amount
+
+	for _, p := range previous {
+		switch p.Status {
+		case DisputeOpen:
+			return conflictError("transaction %d already has an open dispute", t.ID)
+		case DisputeReversed:
+			left -= p.ReversedAmount
+		}
+	}
+
+	if left <= 0 {
+		return conflictError("transaction %d has already been reversed in full", t.ID)
+	}
+
+	if d.Amount == 0 {
+		d.Amount = left
+	}
+
+	if d.Amount > left {
+		errs := FieldErrors{}
+		errs.Add("amount", "must be at most %d, what is left to dispute", left)
+
+		return errs.Err()
+	}
+
+	d.Status = DisputeOpen
+
+	return nil
+}
+
+// reversal checks decision against d and returns the amount to give back,
+// 0 for denials.
+func (d *Dispute) reversal(decision *DisputeDecision) (int64, error) {
+	if d.Status != DisputeOpen {
+		return 0, conflictError("dispute %d is %s", d.ID, d.Status)
+	}
+
+	if decision.Status == DisputeDenied {
+		return 0, nil
+	}
+
+	if decision.Amount == 0 {
+		return d.Amount, nil
+	}
+
+	if decision.Amount > d.Amount {
+		errs := FieldErrors{}
+		errs.Add("amount", "must be at most the disputed %d", d.Amount)
+
+		return 0, errs.Err()
+	}
+
+	return decision.Amount, nil
+}
+
+// decide records decision on d; journalID is the reversal's entry, nil for
+// denials.
+func (d *Dispute) decide(decision *DisputeDecision, amount int64, journalID *int) {
+	d.Status = decision.Status
+	d.ReversedAmount = amount
+	d.ReversalJournalID = journalID
+	d.Note = decision.Note
+	d.DecidedBy = &decision.DecidedBy
+	d.DecidedAt = &decision.DecidedAt
+}
+
+// counterpartyShare is what reversing amount of a debit of debited takes
+// back from the counterparty that was credited for it, so cross-currency
+// transfers are reversed at their original rate. It rounds half up.
+func counterpartyShare(amount, debited, credited int64) int64 {
+	if amount == debited {
+		return credited
+	}
+
+	value := new(big.Int).Mul(big.NewInt(amount), big.NewInt(credited))
+	value.Add(value, big.NewInt(debited/2))
+
+	return value.Quo(value, big.NewInt(debited)).Int64()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisputeOpen(t *testing.T) {
+	withdrawal := &Transaction{ID: 7, Type: TransactionWithdrawal, Amount: -500}
+
+	dispute := &Dispute{}
+	require.Nil(t, dispute.open(withdrawal, nil))
+	assert.Equal(t, int64(500), dispute.Amount)
+	assert.Equal(t, DisputeOpen, dispute.Status)
+
+	previous := []*Dispute{{Status: DisputeReversed, ReversedAmount: 200}, {Status: DisputeDenied}}
+
+	dispute = &Dispute{}
+	require.Nil(t, dispute.open(withdrawal, previous))
+	assert.Equal(t, int64(300), dispute.Amount)
+
+	err := (&Dispute{Amount: 301}).open(withdrawal, previous)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.(*HTTPError).Status)
+
+	err = (&Dispute{}).open(withdrawal, append(previous, &Dispute{Status: DisputeOpen}))
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status)
+
+	err = (&Dispute{}).open(withdrawal, []*Dispute{{Status: DisputeReversed, ReversedAmount: 500}})
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status)
+
+	err = (&Dispute{}).open(&Transaction{Type: TransactionDeposit, Amount: 500}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestDisputeReversal(t *testing.T) {
+	dispute := &Dispute{Amount: 300, Status: DisputeOpen}
+
+	amount, err := dispute.reversal(&DisputeDecision{Status: DisputeReversed})
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), amount)
+
+	amount, err = dispute.reversal(&DisputeDecision{Status: DisputeReversed, Amount: 120})
+	require.Nil(t, err)
+	assert.Equal(t, int64(120), amount)
+
+	_, err = dispute.reversal(&DisputeDecision{Status: DisputeReversed, Amount: 301})
+	assert.NotNil(t, err)
+
+	amount, err = dispute.reversal(&DisputeDecision{Status: DisputeDenied})
+	require.Nil(t, err)
+	assert.Zero(t, amount)
+
+	dispute.decide(&DisputeDecision{Status: DisputeDenied, Note: "signed receipt", DecidedBy: 9, DecidedAt: time.Now()}, 0, nil)
+
+	_, err = dispute.reversal(&DisputeDecision{Status: DisputeReversed})
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status)
+}
+
+func TestCounterpartyShare(t *testing.T) {
+	assert.Equal(t, int64(461), counterpartyShare(500, 500, 461))
+	assert.Equal(t, int64(92), counterpartyShare(100, 500, 461))
+	assert.Equal(t, int64(231), counterpartyShare(250, 500, 461))
+	assert.Equal(t, int64(1), counterpartyShare(1, 3, 2))
+}
+
+func TestMemoryStoreReverseTransfer(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rates, err := NewStaticRateProvider(defaultExchangeRates)
+	require.Nil(t, err)
+
+	usd := &Account{Number: 1, Currency: "USD", Status: AccountActive}
+	eur := &Account{Number: 2, Currency: "EUR", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, usd))
+	require.Nil(t, store.CreateAccount(ctx, eur))
+
+	_, err = store.Deposit(ctx, 1, 1000, 0)
+	require.Nil(t, err)
+
+	transfer, err := newTransfer(ctx, store, rates, 1, 2, 500)
+	require.Nil(t, err)
+	require.Nil(t, store.Transfer(ctx, transfer))
+
+	transactions, err := store.GetTransactions(ctx, 1, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, TransactionTransferOut, transactions[0].Type)
+
+	dispute := &Dispute{AccountNumber: 1, TransactionID: transactions[0].ID, Reason: "never arrived", CreatedAt: time.Now().UTC()}
+	require.Nil(t, store.CreateDispute(ctx, dispute))
+	assert.Equal(t, int64(500), dispute.Amount)
+
+	// the recipient is debited even once frozen
+	_, err = store.UpdateAccountStatus(ctx, eur.ID, AccountFrozen)
+	require.Nil(t, err)
+
+	decided, err := store.DecideDispute(ctx, dispute.ID, &DisputeDecision{Status: DisputeReversed, Amount: 100, DecidedBy: 9, DecidedAt: time.Now().UTC()})
+	require.Nil(t, err)
+	assert.Equal(t, DisputeReversed, decided.Status)
+	assert.Equal(t, int64(100), decided.ReversedAmount)
+	require.NotNil(t, decided.ReversalJournalID)
+
+	from, _ := store.GetAccountByNumber(ctx, 1)
+	to, _ := store.GetAccountByNumber(ctx, 2)
+	assert.Equal(t, int64(600), from.Balance)
+	assert.Equal(t, transfer.ToAmount-counterpartyShare(100, 500, transfer.ToAmount), to.Balance)
+
+	received, err := store.GetTransactions(ctx, 2, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionReversal, received[0].Type)
+	assert.Equal(t, *decided.ReversalJournalID, received[0].JournalID)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced, "%+v", report)
+
+	// the disputed entry is untouched and the rest can still be disputed
+	transactions, err = store.GetTransactions(ctx, 1, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionReversal, transactions[0].Type)
+	assert.Equal(t, int64(-500), transactions[1].Amount)
+
+	rest := &Dispute{AccountNumber: 1, TransactionID: transactions[1].ID, Reason: "never arrived", CreatedAt: time.Now().UTC()}
+	require.Nil(t, store.CreateDispute(ctx, rest))
+	assert.Equal(t, int64(400), rest.Amount)
+}
+
+func TestAPIDisputes(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin"+path+"/withdraw", api.tellerToken(), AmountRequest{Amount: 400})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	transactions, err := api.store.GetTransactions(context.Background(), alice.Number, 10, 0)
+	require.Nil(t, err)
+
+	withdrawal, deposit := transactions[0], transactions[1]
+
+	rec = api.do("POST", path+"/disputes", token, DisputeRequest{TransactionID: deposit.ID, Reason: "not mine"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/disputes", token, DisputeRequest{TransactionID: withdrawal.ID, Amount: 300, Reason: "ATM short-changed me"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	dispute := new(Dispute)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(dispute))
+	assert.Equal(t, DisputeOpen, dispute.Status)
+	assert.Equal(t, int64(300), dispute.Amount)
+
+	rec = api.do("POST", path+"/disputes", token, DisputeRequest{TransactionID: withdrawal.ID, Reason: "again"})
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/disputes", token, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/admin/disputes?status=open", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var disputes []*Dispute
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&disputes))
+	require.Len(t, disputes, 1)
+	assert.Equal(t, dispute.ID, disputes[0].ID)
+
+	decide := "/admin/disputes/" + strconv.Itoa(dispute.ID)
+
+	rec = api.do("POST", decide+"/deny", adminToken, DisputeDecisionRequest{})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", decide+"/reverse", adminToken, DisputeDecisionRequest{Amount: 250})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(dispute))
+	assert.Equal(t, DisputeReversed, dispute.Status)
+	assert.Equal(t, int64(250), dispute.ReversedAmount)
+	assert.Equal(t, &admin.Number, dispute.DecidedBy)
+
+	rec = api.do("POST", decide+"/deny", adminToken, DisputeDecisionRequest{Note: "too late"})
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	acc, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(850), acc.Balance)
+
+	// the rest of the withdrawal is disputed and denied
+	rec = api.do("POST", path+"/disputes", token, DisputeRequest{TransactionID: withdrawal.ID, Reason: "the rest too"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(dispute))
+	assert.Equal(t, int64(150), dispute.Amount)
+
+	rec = api.do("POST", "/admin/disputes/"+strconv.Itoa(dispute.ID)+"/deny", adminToken, DisputeDecisionRequest{Note: "the ATM balanced"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path+"/disputes?status=denied", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&disputes))
+	require.Len(t, disputes, 1)
+	assert.Equal(t, "the ATM balanced", disputes[0].Note)
+	assert.Zero(t, disputes[0].ReversedAmount)
+
+	report, err := api.store.CheckLedgerIntegrity(context.Background())
+	require.Nil(t, err)
+	assert.True(t, report.Balanced, "%+v", report)
+}
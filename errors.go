@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusClientClosedRequest is logged for requests the client gave up on,
+// as nginx does.
+const statusClientClosedRequest = 499
+
+type ErrorCode string
+
+const (
+	ErrorCodeBadRequest        ErrorCode = "bad_request"
+	ErrorCodeValidation        ErrorCode = "validation_error"
+	ErrorCodeUnauthorized      ErrorCode = "unauthorized"
+	ErrorCodeTOTPRequired      ErrorCode = "totp_required"
+	ErrorCodeForbidden         ErrorCode = "forbidden"
+	ErrorCodeNotFound          ErrorCode = "not_found"
+	ErrorCodeMethodNotAllowed  ErrorCode = "method_not_allowed"
+	ErrorCodeConflict          ErrorCode = "conflict"
+	ErrorCodeInsufficientFunds ErrorCode = "insufficient_funds"
+	ErrorCodeAccountInactive   ErrorCode = "account_inactive"
+	ErrorCodeCoolingOff        ErrorCode = "beneficiary_cooling_off"
+	ErrorCodeKYCRequired       ErrorCode = "kyc_required"
+	ErrorCodeEmailUnverified   ErrorCode = "email_unverified"
+	ErrorCodeApprovalRequired  ErrorCode = "approval_required"
+	ErrorCodeTransferBlocked   ErrorCode = "transfer_blocked"
+	ErrorCodeVersionConflict   ErrorCode = "version_conflict"
+	ErrorCodePrecondition      ErrorCode = "precondition_required"
+	ErrorCodeRateLimited       ErrorCode = "rate_limited"
+	ErrorCodeLoginLocked       ErrorCode = "login_locked"
+	ErrorCodeTimeout           ErrorCode = "timeout"
+	ErrorCodeMaintenance       ErrorCode = "maintenance"
+	ErrorCodeInternal          ErrorCode = "internal_error"
+)
+
+// Domain errors of the stores. The errors they return wrap them, so callers
+// can tell them apart with errors.Is whichever store they use, and the API
+// reports them with the right status code even when they come bare.
+var (
+	ErrAccountNotFound        = errors.New("account not found")
+	ErrDuplicateAccountNumber = errors.New("account number already taken")
+	ErrInsufficientFunds      = errors.New("insufficient funds")
+)
+
+// APIError is the JSON envelope of every /v1 error response.
+type APIError struct {
+	Code      ErrorCode `json:"code"`
+	Error     string    `json:"error"`
+	RequestID string    `json:"requestId,omitempty"`
+	// Details lists the offending fields of validation errors.
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// HTTPError is returned by handlers (and the layers below them) to choose the
+// status code and error code of the response. Any other error is reported as
+// an opaque 500 so internal details never reach the client.
+type HTTPError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Details []FieldError
+
+	// Err is the domain error the response reports, if any.
+	Err error
+	// RetryAfter is sent as the Retry-After header when positive.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func newHTTPError(status int, code ErrorCode, format string, a ...any) *HTTPError {
+	return &HTTPError{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func badRequestError(format string, a ...any) error {
+	return newHTTPError(http.StatusBadRequest, ErrorCodeBadRequest, format, a...)
+}
+
+func validationError(format string, a ...any) error {
+	return newHTTPError(http.StatusUnprocessableEntity, ErrorCodeValidation, format, a...)
+}
+
+func unauthorizedError(format string, a ...any) error {
+	return newHTTPError(http.StatusUnauthorized, ErrorCodeUnauthorized, format, a...)
+}
+
+func totpRequiredError() error {
+	return newHTTPError(http.StatusUnauthorized, ErrorCodeTOTPRequired, "two-factor code required")
+}
+
+func forbiddenError(format string, a ...any) error {
+	return newHTTPError(http.StatusForbidden, ErrorCodeForbidden, format, a...)
+}
+
+func notFoundError(format string, a ...any) error {
+	return newHTTPError(http.StatusNotFound, ErrorCodeNotFound, format, a...)
+}
+
+// accountNotFoundError is a notFoundError wrapping ErrAccountNotFound.
+func accountNotFoundError(format string, a ...any) error {
+	err := newHTTPError(http.StatusNotFound, ErrorCodeNotFound, format, a...)
+	err.Err = ErrAccountNotFound
+
+	return err
+}
+
+func conflictError(format string, a ...any) error {
+	return newHTTPError(http.StatusConflict, ErrorCodeConflict, format, a...)
+}
+
+func versionConflictError(number int64, version, current int) error {
+	return newHTTPError(http.StatusConflict, ErrorCodeVersionConflict, "account with number %d is at version %d, not %d", number, current, version)
+}
+
+func preconditionRequiredError() error {
+	return newHTTPError(http.StatusPreconditionRequired, ErrorCodePrecondition, "the account version is required, send it with If-Match")
+}
+
+func insufficientFundsError() error {
+	err := newHTTPError(http.StatusUnprocessableEntity, ErrorCodeInsufficientFunds, "insufficient funds")
+	err.Err = ErrInsufficientFunds
+
+	return err
+}
+
+func accountInactiveError(number int64, status AccountStatus) error {
+	return newHTTPError(http.StatusConflict, ErrorCodeAccountInactive, "account with number %d is %s", number, status)
+}
+
+func coolingOffError(beneficiary *Beneficiary) error {
+	return newHTTPError(http.StatusConflict, ErrorCodeCoolingOff, "beneficiary %d can receive large transfers from %s", beneficiary.ID, beneficiary.CoolingOffUntil.Format(time.RFC3339))
+}
+
+func kycRequiredError(number, threshold int64) error {
+	return newHTTPError(http.StatusForbidden, ErrorCodeKYCRequired, "account with number %d must be verified to transfer more than %d", number, threshold)
+}
+
+func emailUnverifiedError(number, threshold int64) error {
+	return newHTTPError(http.StatusForbidden, ErrorCodeEmailUnverified, "account with number %d needs a verified email to transfer more than %d", number, threshold)
+}
+
+func approvalRequiredError(number, threshold int64) error {
+	return newHTTPError(http.StatusForbidden, ErrorCodeApprovalRequired, "transfers above %d from account with number %d need a second owner's approval, send them with POST /transfer", threshold, number)
+}
+
+// transferBlockedError doesn't tell which fraud rules matched, so they
+// can't be probed.
+func transferBlockedError() error {
+	return newHTTPError(http.StatusForbidden, ErrorCodeTransferBlocked, "transfer blocked, it has been sent for review")
+}
+
+func tooManyRequestsError() error {
+	return newHTTPError(http.StatusTooManyRequests, ErrorCodeRateLimited, "too many requests")
+}
+
+// gatewayTimeoutError reports a request that ran past its deadline.
+func gatewayTimeoutError() *HTTPError {
+	return newHTTPError(http.StatusGatewayTimeout, ErrorCodeTimeout, "request timed out")
+}
+
+func methodNotAllowedError(method string) error {
+	return newHTTPError(http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, "method not allowed %s", method)
+}
+
+// asHTTPError finds the *HTTPError in err's chain, or else reports a bare
+// domain error. It returns false for any other error.
+func asHTTPError(err error) (*HTTPError, bool) {
+	var httpErr *HTTPError
+
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr, true
+	case errors.Is(err, ErrAccountNotFound):
+		httpErr = newHTTPError(http.StatusNotFound, ErrorCodeNotFound, "account not found")
+	case errors.Is(err, ErrDuplicateAccountNumber):
+		httpErr = newHTTPError(http.StatusConflict, ErrorCodeConflict, "account number already taken")
+	case errors.Is(err, ErrInsufficientFunds):
+		httpErr = newHTTPError(http.StatusUnprocessableEntity, ErrorCodeInsufficientFunds, "insufficient funds")
+	case errors.Is(err, context.DeadlineExceeded), isStatementTimeout(err):
+		httpErr = gatewayTimeoutError()
+	default:
+		return nil, false
+	}
+
+	httpErr.Err = err
+
+	return httpErr, true
+}
+
+// publicErrorMessage is what clients are told of err: the message of a
+// domain error, or a generic one for errors whose details only go to the
+// logs.
+func publicErrorMessage(err error) string {
+	if httpErr, ok := asHTTPError(err); ok {
+		return httpErr.Message
+	}
+
+	return "internal server error"
+}
+
+func isNotFound(err error) bool {
+	httpErr, ok := asHTTPError(err)
+	return ok && httpErr.Status == http.StatusNotFound
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	// nobody reads the response, but the logs should not show a failure of
+	// ours
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		slog.InfoContext(r.Context(), "client closed request", "error", err)
+		w.WriteHeader(statusClientClosedRequest)
+
+		return
+	}
+
+	httpErr, ok := asHTTPError(err)
+
+	// whatever the store made of the cancelled query, the deadline is what
+	// happened
+	if !ok && errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		httpErr, ok = gatewayTimeoutError(), true
+	}
+
+	if !ok {
+		slog.ErrorContext(r.Context(), "internal error", "error", err)
+		httpErr = newHTTPError(http.StatusInternalServerError, ErrorCodeInternal, "internal server error")
+	}
+
+	if httpErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(httpErr.RetryAfter.Seconds()))))
+	}
+
+	writeJSON(w, httpErr.Status, errorResponse(r, httpErr, requestIDFromContext(r.Context())))
+}
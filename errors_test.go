@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	r := httptest.NewRequest("GET", "/account/7", nil)
+
+	w := httptest.NewRecorder()
+	writeError(w, r, notFoundError("account %d not found", 7))
+
+	var resp APIError
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, ErrorCodeNotFound, resp.Code)
+	assert.Equal(t, "account 7 not found", resp.Error)
+
+	w = httptest.NewRecorder()
+	writeError(w, r, errors.New("pq: connection refused"))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, ErrorCodeInternal, resp.Code)
+	assert.NotContains(t, resp.Error, "pq")
+}
+
+func TestWriteErrorDomainErrors(t *testing.T) {
+	r := httptest.NewRequest("GET", "/account/7", nil)
+
+	for err, status := range map[error]int{
+		fmt.Errorf("lookup: %w", ErrAccountNotFound):    http.StatusNotFound,
+		ErrDuplicateAccountNumber:                       http.StatusConflict,
+		ErrInsufficientFunds:                            http.StatusUnprocessableEntity,
+		accountNotFoundError("account %d not found", 7): http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		writeError(w, r, err)
+
+		assert.Equal(t, status, w.Code, err.Error())
+		assert.NotContains(t, w.Body.String(), "lookup")
+	}
+
+	assert.ErrorIs(t, insufficientFundsError(), ErrInsufficientFunds)
+	assert.ErrorIs(t, errAccountNumberTaken, ErrDuplicateAccountNumber)
+	assert.True(t, isNotFound(ErrAccountNotFound))
+
+	_, err := NewMemoryStore().GetAccountByNumber(context.Background(), 7)
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+func TestWriteErrorCancelledRequest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/account/7", nil).WithContext(ctx), errors.New("conn closed"))
+
+	var resp APIError
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, ErrorCodeTimeout, resp.Code)
+
+	w = httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/account/7", nil).WithContext(ctx), notFoundError("account %d not found", 7))
+	assert.Equal(t, http.StatusNotFound, w.Code, "errors the handler chose are kept")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	w = httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/account/7", nil).WithContext(ctx), errors.New("conn closed"))
+	assert.Equal(t, statusClientClosedRequest, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestWithTimeout(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(withTimeout(10 * time.Millisecond))
+
+	wait := makeHttpHandleFunc(func(w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	})
+	router.HandleFunc("/v1/account/{id}", wait)
+	router.HandleFunc("/v1/account/{id}/stream", func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.False(t, ok, "streams have no deadline")
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/account/7", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"timeout"`)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/account/7/stream", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
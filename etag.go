@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// accountETag is the entity tag of an account version, e.g. "3".
+func accountETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// getExpectedVersion returns the account version a change is based on, from
+// the If-Match header or else the version of the request body, and 0 when
+// the request gives none, which /v1 accepts for compatibility. "*" matches
+// any version.
+func getExpectedVersion(r *http.Request, bodyVersion int) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	if header == "" {
+		if bodyVersion == 0 && requestAPIVersion(r) != APIVersion1 {
+			return 0, preconditionRequiredError()
+		}
+
+		return bodyVersion, nil
+	}
+
+	if header == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+
+	if err != nil || version <= 0 {
+		return 0, badRequestError("invalid If-Match %s", header)
+	}
+
+	if bodyVersion != 0 && bodyVersion != version {
+		return 0, badRequestError("If-Match %s does not match version %d", header, bodyVersion)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetExpectedVersion(t *testing.T) {
+	request := func(path, ifMatch string) *http.Request {
+		r := httptest.NewRequest("PUT", path, nil)
+
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+
+		return r
+	}
+
+	for _, tc := range []struct {
+		path, ifMatch string
+		body, version int
+	}{
+		{"/v1/account/1", "", 0, 0},
+		{"/account/1", "", 3, 3},
+		{"/v2/account/1", "", 3, 3},
+		{"/v2/account/1", `"3"`, 0, 3},
+		{"/v2/account/1", `W/"3"`, 3, 3},
+		{"/v2/account/1", "*", 0, 0},
+	} {
+		version, err := getExpectedVersion(request(tc.path, tc.ifMatch), tc.body)
+		require.Nil(t, err, tc)
+		assert.Equal(t, tc.version, version, tc)
+	}
+
+	_, err := getExpectedVersion(request("/v2/account/1", ""), 0)
+	assert.Equal(t, ErrorCodePrecondition, err.(*HTTPError).Code)
+
+	for _, ifMatch := range []string{`"x"`, `"0"`, `"4"`} {
+		_, err := getExpectedVersion(request("/v1/account/1", ifMatch), 3)
+		assert.Equal(t, ErrorCodeBadRequest, err.(*HTTPError).Code, ifMatch)
+	}
+}
+
+func TestAPIAccountVersion(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("GET", "/v2"+path, token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	rec = api.do("POST", "/v2/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 500})
+	require.Equal(t, http.StatusPreconditionRequired, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/v2/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 500, Version: 1})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	// a change based on the old version is refused
+	rec = api.do("POST", "/v2/admin"+path+"/withdraw", api.tellerToken(), AmountRequest{Amount: 100, Version: 1})
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"code":"version_conflict"`)
+
+	rec = api.do("PUT", "/v2"+path, token, map[string]any{"firstName": "Alicia", "lastName": "Smith", "version": 1})
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	rec = api.do("PUT", "/v2"+path, token, map[string]any{"firstName": "Alicia", "lastName": "Smith", "version": 2})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	// /v1 still accepts unconditional changes
+	rec = api.do("POST", "/v1/admin"+path+"/withdraw", api.tellerToken(), AmountRequest{Amount: 100})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/v1"+path, token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	acc := new(Account)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(acc))
+	assert.Equal(t, 4, acc.Version)
+	assert.Equal(t, int64(400), acc.Balance)
+	assert.Equal(t, "Alicia", acc.FirstName)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// transactionEvents names the event recorded for each kind of ledger entry.
+var transactionEvents = map[TransactionType]AccountEventType{
+	TransactionDeposit:     MoneyDeposited,
+	TransactionWithdrawal:  MoneyWithdrawn,
+	TransactionTransferOut: TransferSent,
+	TransactionTransferIn:  TransferReceived,
+	TransactionFee:         FeeCharged,
+	TransactionInterest:    InterestPaid,
+	TransactionReversal:    MoneyReversed,
+
+	TransactionExternalOut:    ExternalPaymentSent,
+	TransactionExternalReturn: ExternalPaymentReturned,
+	TransactionImport:         TransactionImported,
+	TransactionAdjustment:     BalanceAdjusted,
+
+	TransactionLoanDisbursement: LoanDisbursed,
+	TransactionLoanRepayment:    LoanRepaid,
+
+	TransactionTermDeposit:       TermDepositOpened,
+	TransactionTermDepositPayout: TermDepositPaidOut,
+}
+
+func newAccountOpenedEvent(acc *Account) *AccountEvent {
+	return &AccountEvent{
+		AccountNumber: acc.Number,
+		Version:       1,
+		Type:          AccountOpened,
+		Amount:        acc.Balance,
+		Currency:      acc.Currency,
+		CreatedAt:     acc.CreatedAt,
+	}
+}
+
+func newTransactionEvent(t *Transaction, version int) *AccountEvent {
+	return &AccountEvent{
+		AccountNumber: t.AccountNumber,
+		Version:       version,
+		Type:          transactionEvents[t.Type],
+		Amount:        t.Amount,
+		Counterparty:  t.Counterparty,
+		CreatedAt:     t.CreatedAt,
+	}
+}
+
+// Apply folds the next event of the account into the aggregate.
+func (a *AccountAggregate) Apply(e *AccountEvent) error {
+	if e.Version != a.Version+1 {
+		return fmt.Errorf("event %d of account %d follows version %d", e.Version, e.AccountNumber, a.Version)
+	}
+
+	if e.Type == AccountOpened {
+		a.AccountNumber = e.AccountNumber
+		a.Currency = e.Currency
+		a.Balance = e.Amount
+		a.OpenedAt = e.CreatedAt
+	} else {
+		a.Balance += e.Amount
+	}
+
+	a.Version = e.Version
+	a.UpdatedAt = e.CreatedAt
+
+	return nil
+}
+
+// ReplayAccount rebuilds the account as of at from its events. It fails
+// with not found if the account had not been opened by then.
+func ReplayAccount(ctx context.Context, store EventRepository, number int64, at time.Time) (*AccountAggregate, error) {
+	events, err := store.GetAccountEvents(ctx, number, AccountEventFilter{Until: at})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		return nil, notFoundError("account with number %d had no history at %s", number, at.Format(time.RFC3339))
+	}
+
+	return replayEvents(events)
+}
+
+func replayEvents(events []*AccountEvent) (*AccountAggregate, error) {
+	aggregate := new(AccountAggregate)
+
+	for _, e := range events {
+		if err := aggregate.Apply(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return aggregate, nil
+}
+
+// CheckProjections replays every account and reports those whose stored
+// balance differs from the replayed one. The accounts are not locked, so an
+// account changed during the check may be reported too.
+func CheckProjections(ctx context.Context, store Storage) (*ProjectionReport, error) {
+	report := &ProjectionReport{Mismatches: []*ProjectionMismatch{}}
+
+	for offset := 0; ; offset += maxPageLimit {
+		accounts, err := store.GetAccounts(ctx, AccountFilter{Limit: maxPageLimit, Offset: offset})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, acc := range accounts {
+			events, err := store.GetAccountEvents(ctx, acc.Number, AccountEventFilter{})
+
+			if err != nil {
+				return nil, err
+			}
+
+			aggregate, err := replayEvents(events)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if aggregate.Balance != acc.Balance {
+				report.Mismatches = append(report.Mismatches, &ProjectionMismatch{AccountNumber: acc.Number, Balance: acc.Balance, Replayed: aggregate.Balance})
+			}
+		}
+
+		report.Accounts += len(accounts)
+
+		if len(accounts) < maxPageLimit {
+			break
+		}
+	}
+
+	report.Consistent = len(report.Mismatches) == 0
+
+	return report, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountAggregateApply(t *testing.T) {
+	opened := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregate := new(AccountAggregate)
+
+	require.Nil(t, aggregate.Apply(&AccountEvent{AccountNumber: 42, Version: 1, Type: AccountOpened, Amount: 100, Currency: "EUR", CreatedAt: opened}))
+	require.Nil(t, aggregate.Apply(&AccountEvent{AccountNumber: 42, Version: 2, Type: MoneyWithdrawn, Amount: -30, CreatedAt: opened.Add(time.Hour)}))
+
+	assert.Equal(t, &AccountAggregate{AccountNumber: 42, Currency: "EUR", Balance: 70, Version: 2, OpenedAt: opened, UpdatedAt: opened.Add(time.Hour)}, aggregate)
+
+	// a gap in the history must not be replayed over
+	assert.NotNil(t, aggregate.Apply(&AccountEvent{AccountNumber: 42, Version: 4, Type: MoneyDeposited, Amount: 5}))
+}
+
+func TestAPIAccountEvents(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 400})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/events", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	events := []*AccountEvent{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&events))
+	require.Len(t, events, 3)
+	assert.Equal(t, []AccountEventType{AccountOpened, MoneyDeposited, TransferSent}, []AccountEventType{events[0].Type, events[1].Type, events[2].Type})
+	assert.Equal(t, bob.Number, *events[2].Counterparty)
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/events?afterVersion=2", token, nil)
+	assert.Contains(t, rec.Body.String(), string(TransferSent))
+	assert.NotContains(t, rec.Body.String(), string(MoneyDeposited))
+
+	balanceAt := func(at time.Time) *http.Response {
+		return api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/balance?at="+at.Format(time.RFC3339Nano), token, nil).Result()
+	}
+
+	// just before the transfer only the deposit had happened
+	resp := balanceAt(events[2].CreatedAt)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	aggregate := new(AccountAggregate)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(aggregate))
+	assert.Equal(t, int64(1000), aggregate.Balance)
+	assert.Equal(t, 2, aggregate.Version)
+
+	assert.Equal(t, http.StatusNotFound, balanceAt(alice.CreatedAt.Add(-time.Hour)).StatusCode)
+
+	rec = api.do("GET", "/admin/events/replay", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"consistent":true`)
+
+	api.store.mu.Lock()
+	api.store.accounts[bob.ID].Balance++
+	api.store.mu.Unlock()
+
+	rec = api.do("GET", "/admin/events/replay", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	report := new(ProjectionReport)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(report))
+	assert.False(t, report.Consistent)
+	require.Len(t, report.Mismatches, 1)
+	assert.Equal(t, &ProjectionMismatch{AccountNumber: bob.Number, Balance: 401, Replayed: 400}, report.Mismatches[0])
+}
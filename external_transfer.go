@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	clearingInterval  = time.Minute
+	clearingBatchSize = 100
+
+	maxReferenceLength = 140
+)
+
+// schemeCurrencies is the only currency each clearing network moves.
+var schemeCurrencies = map[ExternalTransferScheme]string{
+	SchemeACH:  "USD",
+	SchemeSEPA: "EUR",
+}
+
+var (
+	achDestinationPattern = regexp.MustCompile(`^[0-9]{9}/[0-9]{4,17}$`)
+	ibanPattern           = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	returnCodePattern     = regexp.MustCompile(`^[A-Z]{1,2}[0-9]{2}$`)
+)
+
+// validIBAN reports whether iban, without spaces, passes the ISO 13616
+// mod-97 check.
+func validIBAN(iban string) bool {
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+
+	var digits strings.Builder
+
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			digits.WriteString(big.NewInt(int64(c - 'A' + 10)).String())
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+
+	return n.Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// NewExternalTransfer is req from the number account, not yet held.
+func NewExternalTransfer(number int64, req *ExternalTransferRequest, now time.Time) *ExternalTransfer {
+	return &ExternalTransfer{
+		AccountNumber:   number,
+		Scheme:          req.Scheme,
+		BeneficiaryName: strings.TrimSpace(req.BeneficiaryName),
+		Destination:     normalizeDestination(req.Destination),
+		Amount:          req.Amount,
+		Currency:        schemeCurrencies[req.Scheme],
+		Reference:       strings.TrimSpace(req.Reference),
+		Status:          ExternalTransferPending,
+		CreatedAt:       now,
+	}
+}
+
+// normalizeDestination drops the spaces IBANs are usually written with.
+func normalizeDestination(destination string) string {
+	return strings.ToUpper(strings.ReplaceAll(destination, " ", ""))
+}
+
+// checkExternalTransfer validates a new transfer against its locked account.
+func checkExternalTransfer(acc *Account, transfer *ExternalTransfer) error {
+	if acc.Currency != transfer.Currency {
+		return conflictError("%s transfers are in %s, the account is in %s", transfer.Scheme, transfer.Currency, acc.Currency)
+	}
+
+	if err := acc.CheckActive(); err != nil {
+		return err
+	}
+
+	if !acc.CanDebit(transfer.Amount) {
+		return insufficientFundsError()
+	}
+
+	return nil
+}
+
+// CheckReturn returns an error unless the transfer can still be returned.
+func (t *ExternalTransfer) CheckReturn() error {
+	if t.Status == ExternalTransferReturned {
+		return conflictError("external transfer %d is already returned", t.ID)
+	}
+
+	return nil
+}
+
+// settle records that the transfer was booked by journal entry journalID.
+func (t *ExternalTransfer) settle(journalID int, now time.Time) {
+	t.Status = ExternalTransferSettled
+	t.JournalID = &journalID
+	t.SettledAt = &now
+}
+
+// markReturned records the network's return; journalID is the entry that
+// gave a settled transfer back, nil for unsettled ones.
+func (t *ExternalTransfer) markReturned(code, reason string, journalID *int, now time.Time) {
+	t.Status = ExternalTransferReturned
+	t.ReturnCode = code
+	t.ReturnReason = reason
+	t.ReturnJournalID = journalID
+	t.ReturnedAt = &now
+}
+
+// ClearingWorker simulates the clearing networks: it submits pending
+// external transfers, then settles them once they have been submitted for
+// settlementDelay. Several workers may run against the same database.
+type ClearingWorker struct {
+	store           Storage
+	settlementDelay time.Duration
+}
+
+func NewClearingWorker(store Storage, settlementDelay time.Duration) *ClearingWorker {
+	return &ClearingWorker{store: store, settlementDelay: settlementDelay}
+}
+
+func (c *ClearingWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(clearingInterval)
+	defer ticker.Stop()
+
+	for {
+		c.clearDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// clearDue settles the transfers submitted long enough before now, then
+// submits the pending ones, in batches until none are left.
+func (c *ClearingWorker) clearDue(ctx context.Context, now time.Time) {
+	for {
+		transfers, err := c.store.SettleExternalTransfers(ctx, now.Add(-c.settlementDelay), now, clearingBatchSize)
+
+		if err != nil {
+			slog.Error("settling external transfers", "error", err)
+			break
+		}
+
+		for _, t := range transfers {
+			slog.Info("external transfer settled", "transfer", t.ID, "account", t.AccountNumber, "amount", t.Amount, "scheme", t.Scheme)
+		}
+
+		if len(transfers) < clearingBatchSize {
+			break
+		}
+	}
+
+	for {
+		transfers, err := c.store.SubmitExternalTransfers(ctx, now, clearingBatchSize)
+
+		if err != nil {
+			slog.Error("submitting external transfers", "error", err)
+			return
+		}
+
+		if len(transfers) > 0 {
+			slog.Info("external transfers submitted", "count", len(transfers))
+		}
+
+		if len(transfers) < clearingBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidIBAN(t *testing.T) {
+	assert.True(t, validIBAN("DE89370400440532013000"))
+	assert.True(t, validIBAN("GB82WEST12345698765432"))
+	assert.False(t, validIBAN("DE89370400440532013001"))
+	assert.False(t, validIBAN("DE8937040044"))
+	assert.False(t, validIBAN("de89370400440532013000"))
+}
+
+func TestExternalTransferRequestValidate(t *testing.T) {
+	valid := func() *ExternalTransferRequest {
+		return &ExternalTransferRequest{Scheme: SchemeSEPA, BeneficiaryName: "Bob", Destination: "DE89 3704 0044 0532 0130 00", Amount: 100}
+	}
+
+	assert.Nil(t, valid().Validate())
+
+	ach := &ExternalTransferRequest{Scheme: SchemeACH, BeneficiaryName: "Bob", Destination: "021000021/123456789", Amount: 100}
+	assert.Nil(t, ach.Validate())
+
+	ach.Destination = "DE89370400440532013000"
+	assert.NotNil(t, ach.Validate(), "ACH needs a routing and account number")
+
+	req := valid()
+	req.Scheme = "swift"
+	assert.NotNil(t, req.Validate())
+
+	req = valid()
+	req.Destination = "DE89370400440532013001"
+	assert.NotNil(t, req.Validate(), "the IBAN check digits must match")
+
+	req = valid()
+	req.Amount = 0
+	assert.NotNil(t, req.Validate())
+
+	assert.Nil(t, (&ExternalTransferReturnRequest{Code: "R03"}).Validate())
+	assert.Nil(t, (&ExternalTransferReturnRequest{Code: "AC04"}).Validate())
+	assert.NotNil(t, (&ExternalTransferReturnRequest{Code: "closed"}).Validate())
+}
+
+func TestMemoryStoreExternalTransfers(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+	clearing := NewClearingWorker(store, time.Hour)
+
+	acc := &Account{Number: 42, Currency: "EUR", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	_, err := store.Deposit(ctx, 42, 1000, 0)
+	require.Nil(t, err)
+
+	send := func(amount int64) *ExternalTransfer {
+		req := &ExternalTransferRequest{Scheme: SchemeSEPA, BeneficiaryName: "Bob", Destination: "DE89370400440532013000", Amount: amount}
+		transfer := NewExternalTransfer(42, req, now)
+		require.Nil(t, store.CreateExternalTransfer(ctx, transfer))
+
+		return transfer
+	}
+
+	settled := send(600)
+
+	held, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Equal(t, int64(1000), held.Balance, "pending transfers aren't booked")
+	assert.Equal(t, int64(600), held.HeldBalance)
+
+	err = store.CreateExternalTransfer(ctx, NewExternalTransfer(42, &ExternalTransferRequest{Scheme: SchemeSEPA, Amount: 500}, now))
+	assert.Equal(t, http.StatusUnprocessableEntity, err.(*HTTPError).Status, "held funds can't be sent twice")
+
+	err = store.CreateExternalTransfer(ctx, NewExternalTransfer(42, &ExternalTransferRequest{Scheme: SchemeACH, Amount: 1}, now))
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status, "ACH only moves USD")
+
+	clearing.clearDue(ctx, now)
+
+	transfers, err := store.GetExternalTransfers(ctx, 42, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, transfers, 1)
+	assert.Equal(t, ExternalTransferSubmitted, transfers[0].Status)
+
+	clearing.clearDue(ctx, now.Add(time.Hour))
+
+	transfers, err = store.GetExternalTransfers(ctx, 42, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, ExternalTransferSettled, transfers[0].Status)
+	assert.NotNil(t, transfers[0].JournalID)
+
+	booked, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Equal(t, int64(400), booked.Balance)
+	assert.Zero(t, booked.HeldBalance)
+
+	pending := send(300)
+
+	returned, err := store.ReturnExternalTransfer(ctx, pending.ID, "AC04", "closed account", now)
+	require.Nil(t, err)
+	assert.Equal(t, ExternalTransferReturned, returned.Status)
+	assert.Nil(t, returned.ReturnJournalID, "unsettled transfers only release their hold")
+
+	released, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Equal(t, int64(400), released.Balance)
+	assert.Zero(t, released.HeldBalance)
+
+	returned, err = store.ReturnExternalTransfer(ctx, settled.ID, "AC04", "", now)
+	require.Nil(t, err)
+	assert.NotNil(t, returned.ReturnJournalID)
+
+	credited, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Equal(t, int64(1000), credited.Balance)
+
+	_, err = store.ReturnExternalTransfer(ctx, settled.ID, "AC04", "", now)
+	assert.Equal(t, http.StatusConflict, err.(*HTTPError).Status)
+
+	_, err = store.ReturnExternalTransfer(ctx, 99, "AC04", "", now)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestAPIExternalTransfers(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	req := ExternalTransferRequest{Scheme: SchemeACH, BeneficiaryName: "Bob", Destination: "021000021/123456789", Amount: 250, Reference: "rent"}
+
+	rec = api.do("POST", path+"/external-transfers", token, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	transfer := new(ExternalTransfer)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(transfer))
+	assert.Equal(t, ExternalTransferPending, transfer.Status)
+	assert.Equal(t, "USD", transfer.Currency)
+
+	req.Destination = "bogus"
+	rec = api.do("POST", path+"/external-transfers", token, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path+"/external-transfers", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	transfers := []*ExternalTransfer{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&transfers))
+	assert.Len(t, transfers, 1)
+
+	returnPath := "/admin/external-transfers/" + strconv.Itoa(transfer.ID) + "/return"
+
+	rec = api.do("POST", returnPath, token, ExternalTransferReturnRequest{Code: "R03"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", returnPath, adminToken, ExternalTransferReturnRequest{Code: "R03", Reason: "no account"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(transfer))
+	assert.Equal(t, ExternalTransferReturned, transfer.Status)
+	assert.Equal(t, "R03", transfer.ReturnCode)
+
+	acc, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(1000), acc.Balance)
+	assert.Zero(t, acc.HeldBalance)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// generatedFixturePassword is the password of every generated account.
+const generatedFixturePassword = "seeded"
+
+// seedHistoryEnd is when generated histories end. It is fixed rather than
+// now so the same seed generates the same fixtures whenever it runs.
+var seedHistoryEnd = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// seedProfile is how many accounts a seeding profile generates on top of the
+// demo fixtures, and how many months of history each one gets.
+type seedProfile struct {
+	accounts int
+	months   int
+}
+
+// seedProfiles are the profiles -seed takes besides a fixture file.
+var seedProfiles = map[string]seedProfile{
+	"minimal":   {},
+	"demo":      {accounts: 25, months: 6},
+	"load-test": {accounts: 1000, months: 12},
+}
+
+var (
+	seedFirstNames = []string{"Ada", "Alan", "Barbara", "Claude", "Dennis", "Edsger", "Frances", "Grace", "Hedy", "John", "Katherine", "Ken", "Linus", "Margaret", "Niklaus", "Radia", "Rob", "Shafi", "Tim", "Whitfield"}
+	seedLastNames  = []string{"Allen", "Backus", "Cerf", "Diffie", "Goldwasser", "Hamilton", "Hopper", "Johnson", "Kay", "Knuth", "Lamarr", "Liskov", "McCarthy", "Perlman", "Pike", "Ritchie", "Shannon", "Thompson", "Turing", "Wirth"}
+)
+
+// FixtureGenerator makes up accounts with realistic histories for the
+// seeding profiles. The same seed generates the same accounts, so load tests
+// and benchmarks run against the same data, and raising the number of
+// accounts keeps those generated before.
+type FixtureGenerator struct {
+	numbers  *AccountNumberGenerator
+	seed     int64
+	accounts int
+}
+
+// NewFixtureGenerator generates account numbers with numbers. A positive
+// accounts overrides how many accounts a profile generates.
+func NewFixtureGenerator(numbers *AccountNumberGenerator, seed int64, accounts int) *FixtureGenerator {
+	return &FixtureGenerator{numbers: numbers, seed: seed, accounts: accounts}
+}
+
+// Generate returns the accounts of profile, without the demo fixtures.
+func (g *FixtureGenerator) Generate(profile seedProfile) []*AccountFixture {
+	if profile.months == 0 {
+		return nil
+	}
+
+	count := profile.accounts
+
+	if g.accounts > 0 {
+		count = g.accounts
+	}
+
+	r := rand.New(rand.NewSource(g.seed))
+	seen := map[int64]bool{}
+	fixtures := make([]*AccountFixture, 0, count)
+
+	for len(fixtures) < count {
+		number := g.numbers.generate(r.Int63n)
+
+		if seen[number] {
+			continue
+		}
+
+		seen[number] = true
+		fixtures = append(fixtures, g.account(r, number, profile.months))
+	}
+
+	return fixtures
+}
+
+// account makes up a customer who opened the account months before
+// seedHistoryEnd: checking accounts get a salary, rent, card payments and
+// the odd cash withdrawal each month, savings accounts a monthly deposit and
+// the odd withdrawal.
+func (g *FixtureGenerator) account(r *rand.Rand, number int64, months int) *AccountFixture {
+	opened := seedHistoryEnd.AddDate(0, -months, 0)
+	fixture := &AccountFixture{
+		Number:    number,
+		FirstName: seedFirstNames[r.Intn(len(seedFirstNames))],
+		LastName:  seedLastNames[r.Intn(len(seedLastNames))],
+		Password:  generatedFixturePassword,
+		Currency:  defaultCurrency,
+		Type:      AccountChecking,
+		OpenedAt:  opened,
+		Balance:   between(r, 1000, 500000),
+	}
+
+	switch n := r.Intn(10); {
+	case n == 0:
+		fixture.Currency = "EUR"
+	case n == 1:
+		fixture.Currency = "GBP"
+	}
+
+	if r.Intn(10) < 3 {
+		fixture.Type = AccountSavings
+	}
+
+	salary := between(r, 150000, 800000)
+	rent := salary * between(r, 25, 40) / 100
+	var history []*TransactionFixture
+
+	// anywhere in the first four weeks of the month
+	at := func(month time.Time) time.Time {
+		return month.Add(time.Duration(r.Int63n(int64(28 * 24 * time.Hour)))).Truncate(time.Minute)
+	}
+
+	for m := 0; m < months; m++ {
+		month := opened.AddDate(0, m, 0)
+
+		if fixture.Type == AccountSavings {
+			history = append(history, &TransactionFixture{Amount: salary / 10, At: month.AddDate(0, 0, 25).Add(8 * time.Hour)})
+
+			if r.Intn(4) == 0 {
+				history = append(history, &TransactionFixture{Amount: -between(r, 10, 100) * 1000, At: at(month)})
+			}
+
+			continue
+		}
+
+		history = append(history,
+			&TransactionFixture{Amount: salary, At: month.AddDate(0, 0, 25).Add(8 * time.Hour)},
+			&TransactionFixture{Amount: -rent, At: month.AddDate(0, 0, 1).Add(10*time.Hour + 30*time.Minute)},
+		)
+
+		for i := between(r, 5, 25); i > 0; i-- {
+			history = append(history, &TransactionFixture{Amount: -between(r, 300, 15000), At: at(month)})
+		}
+
+		for i := r.Intn(3); i > 0; i-- {
+			history = append(history, &TransactionFixture{Amount: -between(r, 2, 20) * 1000, At: at(month)})
+		}
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].At.Before(history[j].At)
+	})
+
+	// customers don't spend what they don't have
+	balance := fixture.Balance
+
+	for _, t := range history {
+		if balance+t.Amount < 0 {
+			continue
+		}
+
+		balance += t.Amount
+		fixture.Transactions = append(fixture.Transactions, t)
+	}
+
+	return fixture
+}
+
+// between returns a random amount in [lo, hi].
+func between(r *rand.Rand, lo, hi int64) int64 {
+	return lo + r.Int63n(hi-lo+1)
+}
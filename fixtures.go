@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// demoFixtures are seeded by -seed given without a file, and by every
+// seeding profile.
+//
+//go:embed fixtures/demo.yaml
+var demoFixtures []byte
+
+// Fixtures are the accounts a demo or test environment is seeded with, read
+// from a YAML or JSON file. Accounts are keyed by their number, so seeding
+// the same fixtures again, or from several servers at once, creates each
+// account and its history once.
+type Fixtures struct {
+	Accounts []*AccountFixture `yaml:"accounts"`
+}
+
+type AccountFixture struct {
+	Number    int64       `yaml:"number"`
+	FirstName string      `yaml:"firstName"`
+	LastName  string      `yaml:"lastName"`
+	Password  string      `yaml:"password"`
+	Role      Role        `yaml:"role"`
+	Currency  string      `yaml:"currency"`
+	Type      AccountType `yaml:"type"`
+	// OpenedAt defaults to now, and to the first transaction if earlier.
+	OpenedAt time.Time `yaml:"openedAt"`
+	// Balance is deposited when the account opens, before Transactions.
+	Balance      int64                 `yaml:"balance"`
+	Transactions []*TransactionFixture `yaml:"transactions"`
+}
+
+// TransactionFixture is a deposit, or with a negative Amount a withdrawal,
+// booked at At. An account's transactions are listed oldest first.
+type TransactionFixture struct {
+	Amount int64     `yaml:"amount"`
+	At     time.Time `yaml:"at"`
+}
+
+// seedJournalKinds are the journal entries fixture transactions are booked
+// as.
+var seedJournalKinds = map[TransactionType]JournalKind{
+	TransactionDeposit:    JournalDeposit,
+	TransactionWithdrawal: JournalWithdrawal,
+}
+
+// loadFixtures reads the fixtures seed names: none for false or empty, the
+// demo fixtures for true or the minimal profile, the demo fixtures and the
+// accounts generator makes up for the other profiles, or else those of the
+// file at that path.
+func loadFixtures(seed string, generator *FixtureGenerator) (*Fixtures, error) {
+	data := demoFixtures
+	profile, isProfile := seedProfiles[seed]
+
+	switch {
+	case seed == "" || seed == "false":
+		return &Fixtures{}, nil
+	case seed == "true" || isProfile:
+	default:
+		file, err := os.ReadFile(seed)
+
+		if err != nil {
+			return nil, fmt.Errorf("reading fixtures: %w", err)
+		}
+
+		data = file
+	}
+
+	fixtures := new(Fixtures)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(fixtures); err != nil {
+		return nil, fmt.Errorf("reading fixtures from %s: %w", seed, err)
+	}
+
+	fixtures.Accounts = append(fixtures.Accounts, generator.Generate(profile)...)
+
+	return fixtures, nil
+}
+
+// Validate checks the fixtures before anything is seeded, so a mistake in
+// the file seeds nothing rather than part of it.
+func (f *Fixtures) Validate(numbers *AccountNumberGenerator) error {
+	seen := map[int64]bool{}
+	var errs []error
+
+	for i, account := range f.Accounts {
+		if err := account.validate(numbers); err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", i+1, err))
+		} else if seen[account.Number] {
+			errs = append(errs, fmt.Errorf("account %d: number %d is listed twice", i+1, account.Number))
+		}
+
+		seen[account.Number] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *AccountFixture) validate(numbers *AccountNumberGenerator) error {
+	switch {
+	case !numbers.Valid(f.Number):
+		return fmt.Errorf("number %d is not a valid account number", f.Number)
+	case f.FirstName == "" || f.LastName == "":
+		return errors.New("firstName and lastName are required")
+	case f.Password == "":
+		return errors.New("password is required")
+	case f.Role != "" && f.Role != RoleCustomer && f.Role != RoleAdmin && f.Role != RoleCompliance:
+		return fmt.Errorf("role must be %s, %s or %s", RoleCustomer, RoleAdmin, RoleCompliance)
+	case f.Currency != "" && !validCurrency(f.Currency):
+		return fmt.Errorf("unsupported currency %s", f.Currency)
+	case f.Type != "" && f.Type != AccountChecking && f.Type != AccountSavings && f.Type != AccountBusiness:
+		return fmt.Errorf("type must be %s, %s or %s", AccountChecking, AccountSavings, AccountBusiness)
+	case f.Balance < 0:
+		return errors.New("balance must not be negative")
+	}
+
+	balance := f.Balance
+
+	for i, t := range f.Transactions {
+		if t.Amount == 0 || t.At.IsZero() {
+			return fmt.Errorf("transaction %d: amount and at are required", i+1)
+		}
+
+		if i > 0 && t.At.Before(f.Transactions[i-1].At) {
+			return fmt.Errorf("transaction %d is before transaction %d, list them oldest first", i+1, i)
+		}
+
+		var ok bool
+
+		if balance, ok = addMinorUnits(balance, t.Amount); !ok {
+			return fmt.Errorf("transaction %d: amount out of range", i+1)
+		}
+
+		if balance < 0 {
+			return fmt.Errorf("transaction %d overdraws the account", i+1)
+		}
+	}
+
+	return nil
+}
+
+// account returns the account of f, without its password, and its history:
+// the opening balance, then the transactions.
+func (f *AccountFixture) account(now time.Time) (*Account, []*Transaction) {
+	acc := newAccount(f.FirstName, f.LastName)
+	acc.Number = f.Number
+
+	if f.Role != "" {
+		acc.Role = f.Role
+	}
+
+	if f.Currency != "" {
+		acc.Currency = f.Currency
+	}
+
+	if f.Type != "" {
+		acc.Type = f.Type
+	}
+
+	history := make([]*Transaction, 0, len(f.Transactions)+1)
+
+	for _, t := range f.Transactions {
+		kind := TransactionDeposit
+
+		if t.Amount < 0 {
+			kind = TransactionWithdrawal
+		}
+
+		history = append(history, &Transaction{Type: kind, Amount: t.Amount, CreatedAt: t.At.UTC()})
+	}
+
+	acc.CreatedAt = now
+
+	if !f.OpenedAt.IsZero() {
+		acc.CreatedAt = f.OpenedAt.UTC()
+	}
+
+	if len(history) > 0 && history[0].CreatedAt.Before(acc.CreatedAt) {
+		acc.CreatedAt = history[0].CreatedAt
+	}
+
+	if f.Balance > 0 {
+		opening := &Transaction{Type: TransactionDeposit, Amount: f.Balance, CreatedAt: acc.CreatedAt}
+		history = append([]*Transaction{opening}, history...)
+	}
+
+	return acc, history
+}
+
+// seedFixtures creates the fixtures' accounts that don't exist yet and
+// returns how many it created.
+func seedFixtures(ctx context.Context, store AccountRepository, numbers *AccountNumberGenerator, fixtures *Fixtures) (int, error) {
+	if err := fixtures.Validate(numbers); err != nil {
+		return 0, fmt.Errorf("invalid fixtures:\n%w", err)
+	}
+
+	now := time.Now().UTC()
+	created := 0
+	// bcrypt is slow on purpose; the generated accounts share a password,
+	// so hash each password once rather than once per account
+	hashes := map[string]string{}
+
+	for _, fixture := range fixtures.Accounts {
+		acc, history := fixture.account(now)
+
+		if hash, ok := hashes[fixture.Password]; ok {
+			acc.EncryptedPassword = hash
+		} else if err := acc.SetPassword(fixture.Password); err != nil {
+			return created, err
+		}
+
+		hashes[fixture.Password] = acc.EncryptedPassword
+		err := store.SeedAccount(ctx, acc, history)
+
+		if errors.Is(err, ErrDuplicateAccountNumber) {
+			slog.InfoContext(ctx, "account already seeded", "number", acc.Number)
+			continue
+		}
+
+		if err != nil {
+			return created, fmt.Errorf("seeding account %d: %w", acc.Number, err)
+		}
+
+		created++
+	}
+
+	return created, nil
+}
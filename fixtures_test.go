@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	generator := NewFixtureGenerator(NewAccountNumberGenerator(defaultAccountNumberLength), 1, 0)
+
+	demo, err := loadFixtures("true", generator)
+	require.Nil(t, err)
+	assert.NotEmpty(t, demo.Accounts)
+
+	for _, length := range []int{minAccountNumberLength, defaultAccountNumberLength, maxAccountNumberLength} {
+		assert.Nil(t, demo.Validate(NewAccountNumberGenerator(length)), "the demo numbers are valid at any length")
+	}
+
+	none, err := loadFixtures("false", generator)
+	require.Nil(t, err)
+	assert.Empty(t, none.Accounts)
+
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	require.Nil(t, os.WriteFile(path, []byte(`{"accounts": [{"number": 42, "firstName": "Ada", "lastName": "Lovelace", "password": "pw", "balance": 100}]}`), 0o600))
+
+	fixtures, err := loadFixtures(path, generator)
+	require.Nil(t, err)
+	require.Len(t, fixtures.Accounts, 1)
+	assert.Equal(t, int64(100), fixtures.Accounts[0].Balance)
+
+	require.Nil(t, os.WriteFile(path, []byte(`{"accounts": [{"numbr": 42}]}`), 0o600))
+	_, err = loadFixtures(path, generator)
+	assert.ErrorContains(t, err, "numbr", "misspelled fields are caught")
+
+	minimal, err := loadFixtures("minimal", generator)
+	require.Nil(t, err)
+	assert.Equal(t, demo, minimal)
+
+	profile, err := loadFixtures("demo", generator)
+	require.Nil(t, err)
+	assert.Len(t, profile.Accounts, len(demo.Accounts)+seedProfiles["demo"].accounts, "the demo fixtures and the generated accounts")
+}
+
+func TestFixtureGeneratorGenerate(t *testing.T) {
+	numbers := NewAccountNumberGenerator(defaultAccountNumberLength)
+	profile := seedProfile{accounts: 20, months: 3}
+
+	fixtures := NewFixtureGenerator(numbers, 42, 0).Generate(profile)
+	require.Len(t, fixtures, 20)
+	assert.Nil(t, (&Fixtures{Accounts: fixtures}).Validate(numbers))
+	assert.Equal(t, fixtures, NewFixtureGenerator(numbers, 42, 0).Generate(profile), "the same seed generates the same fixtures")
+	assert.NotEqual(t, fixtures, NewFixtureGenerator(numbers, 43, 0).Generate(profile))
+
+	more := NewFixtureGenerator(numbers, 42, 30).Generate(profile)
+	require.Len(t, more, 30, "the number of accounts overrides the profile's")
+	assert.Equal(t, fixtures, more[:20], "more accounts keep those generated before")
+
+	for _, fixture := range fixtures {
+		assert.NotEmpty(t, fixture.Transactions)
+
+		for _, transaction := range fixture.Transactions {
+			assert.False(t, transaction.At.Before(fixture.OpenedAt))
+			assert.True(t, transaction.At.Before(seedHistoryEnd))
+		}
+	}
+
+	assert.Empty(t, NewFixtureGenerator(numbers, 42, 30).Generate(seedProfiles["minimal"]))
+}
+
+func TestFixturesValidate(t *testing.T) {
+	numbers := NewAccountNumberGenerator(defaultAccountNumberLength)
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := func() *AccountFixture {
+		return &AccountFixture{Number: 42, FirstName: "Ada", LastName: "Lovelace", Password: "pw", Balance: 100}
+	}
+
+	overdrawn := valid()
+	overdrawn.Transactions = []*TransactionFixture{{Amount: -50, At: at}, {Amount: -60, At: at.Add(time.Hour)}}
+
+	unordered := valid()
+	unordered.Transactions = []*TransactionFixture{{Amount: 50, At: at.Add(time.Hour)}, {Amount: 60, At: at}}
+
+	badNumber := valid()
+	badNumber.Number = 1234567890
+
+	err := (&Fixtures{Accounts: []*AccountFixture{valid(), valid(), overdrawn, unordered, badNumber}}).Validate(numbers)
+	assert.ErrorContains(t, err, "account 2: number 42 is listed twice")
+	assert.ErrorContains(t, err, "account 3: transaction 2 overdraws the account")
+	assert.ErrorContains(t, err, "account 4: transaction 2 is before transaction 1")
+	assert.ErrorContains(t, err, "account 5: number 1234567890 is not a valid account number")
+}
+
+func TestSeedFixtures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	numbers := NewAccountNumberGenerator(defaultAccountNumberLength)
+	opened := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	fixtures := &Fixtures{Accounts: []*AccountFixture{{
+		Number: 42, FirstName: "Ada", LastName: "Lovelace", Password: "pw", Currency: "EUR", OpenedAt: opened, Balance: 1000,
+		Transactions: []*TransactionFixture{{Amount: 500, At: opened.AddDate(0, 0, 1)}, {Amount: -300, At: opened.AddDate(0, 0, 2)}},
+	}}}
+
+	created, err := seedFixtures(ctx, store, numbers, fixtures)
+	require.Nil(t, err)
+	assert.Equal(t, 1, created)
+
+	created, err = seedFixtures(ctx, store, numbers, fixtures)
+	require.Nil(t, err)
+	assert.Zero(t, created, "seeded accounts are skipped")
+
+	acc, err := store.GetAccountByNumber(ctx, 42)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1200), acc.Balance)
+	assert.Equal(t, opened, acc.CreatedAt)
+	assert.True(t, acc.ValidPassword("pw"))
+
+	transactions, err := store.GetTransactionsBetween(ctx, 42, opened, opened.AddDate(0, 1, 0))
+	require.Nil(t, err)
+	require.Len(t, transactions, 3, "the opening balance and the history")
+	assert.Equal(t, opened, transactions[0].CreatedAt)
+	assert.Equal(t, TransactionWithdrawal, transactions[2].Type)
+	assert.Equal(t, opened.AddDate(0, 0, 2), transactions[2].CreatedAt)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestSeedFixturesProfile(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	numbers := NewAccountNumberGenerator(defaultAccountNumberLength)
+
+	fixtures, err := loadFixtures("demo", NewFixtureGenerator(numbers, 1, 5))
+	require.Nil(t, err)
+
+	created, err := seedFixtures(ctx, store, numbers, fixtures)
+	require.Nil(t, err)
+	assert.Equal(t, len(fixtures.Accounts), created)
+
+	generated := fixtures.Accounts[len(fixtures.Accounts)-1]
+	acc, err := store.GetAccountByNumber(ctx, int(generated.Number))
+	require.Nil(t, err)
+	assert.True(t, acc.ValidPassword(generatedFixturePassword))
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fraudRuleNames are the built-in rules, in the order they are evaluated.
+var fraudRuleNames = []string{"velocity", "new-beneficiary", "unusual-hour", "ip-mismatch"}
+
+// FraudCheck is a transfer about to be executed. IP is empty when the
+// transfer wasn't requested by a client, e.g. a standing order.
+type FraudCheck struct {
+	Transfer *Transfer
+	IP       string
+	At       time.Time
+}
+
+// FraudRule tells transfers that look fraudulent. Rules only look; the action
+// taken when one matches is configured on the FraudEngine.
+type FraudRule interface {
+	Name() string
+	Match(ctx context.Context, store FraudRepository, check *FraudCheck) (bool, error)
+}
+
+// velocityRule matches once an account has made limit transfers within
+// window.
+type velocityRule struct {
+	limit  int
+	window time.Duration
+}
+
+func (velocityRule) Name() string { return "velocity" }
+
+func (r velocityRule) Match(ctx context.Context, store FraudRepository, check *FraudCheck) (bool, error) {
+	count, err := store.CountTransfersFrom(ctx, check.Transfer.FromAccount, check.At.Add(-r.window))
+
+	return count >= r.limit, err
+}
+
+// newBeneficiaryRule matches transfers of at least amount to an account the
+// source has never paid before.
+type newBeneficiaryRule struct {
+	amount int64
+}
+
+func (newBeneficiaryRule) Name() string { return "new-beneficiary" }
+
+func (r newBeneficiaryRule) Match(ctx context.Context, store FraudRepository, check *FraudCheck) (bool, error) {
+	if check.Transfer.Amount < r.amount {
+		return false, nil
+	}
+
+	paid, err := store.HasTransferredTo(ctx, check.Transfer.FromAccount, check.Transfer.ToAccount)
+
+	return !paid, err
+}
+
+// unusualHourRule matches transfers made from start to end (exclusive), in
+// hours of the day in UTC. The range may wrap around midnight.
+type unusualHourRule struct {
+	start, end int
+}
+
+func (unusualHourRule) Name() string { return "unusual-hour" }
+
+func (r unusualHourRule) Match(ctx context.Context, store FraudRepository, check *FraudCheck) (bool, error) {
+	hour := check.At.UTC().Hour()
+
+	if r.start <= r.end {
+		return hour >= r.start && hour < r.end, nil
+	}
+
+	return hour >= r.start || hour < r.end, nil
+}
+
+// ipMismatchRule matches transfers requested from a network the holder has
+// never logged in from. Without a GeoIP database networks stand in for
+// locations. Accounts with no recorded logins never match.
+type ipMismatchRule struct{}
+
+func (ipMismatchRule) Name() string { return "ip-mismatch" }
+
+func (ipMismatchRule) Match(ctx context.Context, store FraudRepository, check *FraudCheck) (bool, error) {
+	if check.IP == "" {
+		return false, nil
+	}
+
+	networks, err := store.GetLoginNetworks(ctx, check.Transfer.FromAccount)
+
+	if err != nil || len(networks) == 0 {
+		return false, err
+	}
+
+	return !slices.Contains(networks, loginNetwork(check.IP)), nil
+}
+
+// loginNetwork returns the /24 of an IPv4 address or the /48 of an IPv6 one.
+func loginNetwork(ip string) string {
+	addr := net.ParseIP(ip)
+
+	if addr == nil {
+		return ip
+	}
+
+	if v4 := addr.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+
+	return (&net.IPNet{IP: addr.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// LoginData is the payload of login.new_device events.
+type LoginData struct {
+	IP      string `json:"ip"`
+	Network string `json:"network"`
+}
+
+// recordLoginNetwork remembers the network a holder logged in from for the
+// ip-mismatch rule, and publishes login.new_device when an account that has
+// logged in before does so from a network it hasn't used. A failure is
+// logged, the login has already succeeded.
+func recordLoginNetwork(ctx context.Context, store FraudRepository, events EventPublisher, number int64, ip string) {
+	if ip == "" {
+		return
+	}
+
+	network := loginNetwork(ip)
+	known, err := store.GetLoginNetworks(ctx, number)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "loading login networks", "account", number, "error", err)
+		return
+	}
+
+	if err := store.RecordLoginNetwork(ctx, number, network, time.Now().UTC()); err != nil {
+		slog.ErrorContext(ctx, "recording login network", "account", number, "error", err)
+		return
+	}
+
+	if len(known) > 0 && !slices.Contains(known, network) {
+		events.Publish(ctx, &Event{Type: EventLoginNewDevice, AccountNumber: number, Data: LoginData{IP: ip, Network: network}})
+	}
+}
+
+// parseFraudRules parses the fraudRules setting, a comma separated list of
+// rule=action. Rules left out are not evaluated.
+func parseFraudRules(s string) (map[string]FraudAction, error) {
+	actions := map[string]FraudAction{}
+
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+
+		name, action, ok := strings.Cut(field, "=")
+
+		if !ok {
+			return nil, fmt.Errorf("%q is not rule=action", field)
+		}
+
+		if !slices.Contains(fraudRuleNames, name) {
+			return nil, fmt.Errorf("unknown rule %q, must be one of %s", name, strings.Join(fraudRuleNames, ", "))
+		}
+
+		switch FraudAction(action) {
+		case FraudAllow, FraudFlag, FraudBlock:
+		default:
+			return nil, fmt.Errorf("action of %s must be allow, flag or block, got %q", name, action)
+		}
+
+		actions[name] = FraudAction(action)
+	}
+
+	return actions, nil
+}
+
+// parseHourRange parses a range of hours of the day such as "0-6".
+func parseHourRange(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+
+	if ok {
+		start, err = strconv.Atoi(from)
+	}
+
+	if ok && err == nil {
+		end, err = strconv.Atoi(to)
+	}
+
+	if !ok || err != nil || start < 0 || start > 23 || end < 0 || end > 24 || start == end {
+		return 0, 0, fmt.Errorf("must be a range of hours such as 0-6, got %q", s)
+	}
+
+	return start, end, nil
+}
+
+type fraudRuleAction struct {
+	rule   FraudRule
+	action FraudAction
+}
+
+// FraudEngine screens transfers with its rules before they are executed.
+type FraudEngine struct {
+	store FraudRepository
+	rules []fraudRuleAction
+}
+
+// NewFraudEngine builds an engine running the built-in rules enabled in
+// cfg, which must be valid.
+func NewFraudEngine(cfg *Config, store FraudRepository) *FraudEngine {
+	engine := &FraudEngine{store: store}
+	actions, _ := parseFraudRules(cfg.FraudRules)
+	start, end, _ := parseHourRange(cfg.FraudUnusualHours)
+
+	rules := map[string]FraudRule{
+		"velocity":        velocityRule{limit: cfg.FraudVelocityLimit, window: cfg.FraudVelocityWindow},
+		"new-beneficiary": newBeneficiaryRule{amount: cfg.FraudLargeAmount},
+		"unusual-hour":    unusualHourRule{start: start, end: end},
+		"ip-mismatch":     ipMismatchRule{},
+	}
+
+	for _, name := range fraudRuleNames {
+		if action, ok := actions[name]; ok {
+			engine.Add(rules[name], action)
+		}
+	}
+
+	return engine
+}
+
+// Add evaluates rule on every transfer, taking action when it matches.
+func (e *FraudEngine) Add(rule FraudRule, action FraudAction) {
+	e.rules = append(e.rules, fraudRuleAction{rule: rule, action: action})
+}
+
+// Screen runs every rule against a transfer about to be executed and takes
+// the strictest action of those that match. A blocked transfer is queued
+// for review and refused. A flagged one is returned as a review for the
+// caller to record with recordFraudReview once the transfer has run, and
+// nil is returned otherwise.
+func (e *FraudEngine) Screen(ctx context.Context, check *FraudCheck) (*FraudReview, error) {
+	review := &FraudReview{
+		FromAccount: check.Transfer.FromAccount,
+		ToAccount:   check.Transfer.ToAccount,
+		Amount:      check.Transfer.Amount,
+		Currency:    check.Transfer.Currency,
+		IP:          check.IP,
+		Action:      FraudAllow,
+		Status:      FraudReviewPending,
+		CreatedAt:   check.At,
+	}
+
+	for _, r := range e.rules {
+		matched, err := r.rule.Match(ctx, e.store, check)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !matched {
+			continue
+		}
+
+		slog.InfoContext(ctx, "fraud rule matched", "rule", r.rule.Name(), "action", r.action, "from", review.FromAccount, "to", review.ToAccount, "amount", review.Amount)
+
+		if r.action == FraudAllow {
+			continue
+		}
+
+		review.Rules = append(review.Rules, r.rule.Name())
+
+		if r.action == FraudBlock || review.Action == FraudAllow {
+			review.Action = r.action
+		}
+	}
+
+	switch review.Action {
+	case FraudBlock:
+		if err := e.store.CreateFraudReview(ctx, review); err != nil {
+			return nil, err
+		}
+
+		return nil, transferBlockedError()
+	case FraudFlag:
+		return review, nil
+	}
+
+	return nil, nil
+}
+
+// recordFraudReview queues a flagged transfer for review after it ran;
+// transfer is nil if it is waiting for a second owner. A failure is logged
+// rather than returned, since the transfer can no longer be undone.
+func recordFraudReview(ctx context.Context, store FraudRepository, review *FraudReview, transfer *Transfer) {
+	if review == nil {
+		return
+	}
+
+	if transfer != nil {
+		review.TransferID = &transfer.ID
+	}
+
+	if err := store.CreateFraudReview(ctx, review); err != nil {
+		slog.ErrorContext(ctx, "recording fraud review", "from", review.FromAccount, "rules", review.Rules, "error", err)
+	}
+}
+
+// CheckDecision validates clearing or confirming the review.
+func (r *FraudReview) CheckDecision() error {
+	if r.Status != FraudReviewPending {
+		return conflictError("fraud review %d is %s", r.ID, r.Status)
+	}
+
+	return nil
+}
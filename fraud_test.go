@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFraudRules(t *testing.T) {
+	actions, err := parseFraudRules("velocity=block, ip-mismatch=allow,")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]FraudAction{"velocity": FraudBlock, "ip-mismatch": FraudAllow}, actions)
+
+	for _, s := range []string{"velocity", "speed=flag", "velocity=panic"} {
+		_, err := parseFraudRules(s)
+		assert.NotNil(t, err, s)
+	}
+
+	start, end, err := parseHourRange("22-6")
+	require.Nil(t, err)
+	assert.Equal(t, []int{22, 6}, []int{start, end})
+
+	for _, s := range []string{"", "6", "6-6", "0-25", "night-6"} {
+		_, _, err := parseHourRange(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestLoginNetwork(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", loginNetwork("203.0.113.57"))
+	assert.Equal(t, "2001:db8:1::/48", loginNetwork("2001:db8:1:2::1"))
+	assert.Equal(t, "bufconn", loginNetwork("bufconn"))
+}
+
+func TestFraudRules(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, 3, 15, 23, 30, 0, 0, time.UTC)
+
+	for _, number := range []int64{1, 2, 3} {
+		require.Nil(t, store.CreateAccount(ctx, &Account{Number: number, Currency: "USD", Status: AccountActive}))
+	}
+
+	_, err := store.Deposit(ctx, 1, 1000, 0)
+	require.Nil(t, err)
+	require.Nil(t, store.Transfer(ctx, &Transfer{FromAccount: 1, ToAccount: 2, Amount: 100, Currency: "USD", ToAmount: 100, ToCurrency: "USD"}))
+
+	check := &FraudCheck{Transfer: &Transfer{FromAccount: 1, ToAccount: 2, Amount: 500}, IP: "198.51.100.7", At: now}
+
+	match := func(rule FraudRule, check *FraudCheck) bool {
+		matched, err := rule.Match(ctx, store, check)
+		require.Nil(t, err)
+
+		return matched
+	}
+
+	assert.True(t, match(velocityRule{limit: 1, window: time.Hour}, &FraudCheck{Transfer: check.Transfer, At: time.Now()}))
+	assert.False(t, match(velocityRule{limit: 2, window: time.Hour}, &FraudCheck{Transfer: check.Transfer, At: time.Now()}))
+
+	assert.False(t, match(newBeneficiaryRule{amount: 500}, check))
+	assert.True(t, match(newBeneficiaryRule{amount: 500}, &FraudCheck{Transfer: &Transfer{FromAccount: 1, ToAccount: 3, Amount: 500}}))
+	assert.False(t, match(newBeneficiaryRule{amount: 501}, &FraudCheck{Transfer: &Transfer{FromAccount: 1, ToAccount: 3, Amount: 500}}))
+
+	assert.True(t, match(unusualHourRule{start: 22, end: 6}, check))
+	assert.False(t, match(unusualHourRule{start: 0, end: 6}, check))
+
+	// holders who never logged in are not matched
+	assert.False(t, match(ipMismatchRule{}, check))
+
+	recordLoginNetwork(ctx, store, publishers{}, 1, "198.51.100.200")
+	assert.False(t, match(ipMismatchRule{}, check))
+	assert.True(t, match(ipMismatchRule{}, &FraudCheck{Transfer: check.Transfer, IP: "192.0.2.1"}))
+}
+
+type matchingRule struct{ name string }
+
+func (r matchingRule) Name() string { return r.name }
+
+func (matchingRule) Match(context.Context, FraudRepository, *FraudCheck) (bool, error) {
+	return true, nil
+}
+
+func TestFraudEngineScreen(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	check := &FraudCheck{Transfer: &Transfer{FromAccount: 1, ToAccount: 2, Amount: 500, Currency: "USD"}, At: time.Now().UTC()}
+
+	engine := &FraudEngine{store: store}
+	engine.Add(matchingRule{"logged"}, FraudAllow)
+
+	review, err := engine.Screen(ctx, check)
+	require.Nil(t, err)
+	assert.Nil(t, review)
+
+	engine.Add(matchingRule{"flagged"}, FraudFlag)
+
+	review, err = engine.Screen(ctx, check)
+	require.Nil(t, err)
+	require.NotNil(t, review)
+	assert.Equal(t, FraudFlag, review.Action)
+	assert.Equal(t, []string{"flagged"}, review.Rules)
+
+	// flagged transfers are only queued once executed
+	reviews, err := store.GetFraudReviews(ctx, "", 10, 0)
+	require.Nil(t, err)
+	assert.Empty(t, reviews)
+
+	engine.Add(matchingRule{"blocked"}, FraudBlock)
+
+	_, err = engine.Screen(ctx, check)
+	assert.Equal(t, ErrorCodeTransferBlocked, err.(*HTTPError).Code)
+
+	reviews, err = store.GetFraudReviews(ctx, FraudReviewPending, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, FraudBlock, reviews[0].Action)
+	assert.Equal(t, []string{"flagged", "blocked"}, reviews[0].Rules)
+	assert.Nil(t, reviews[0].TransferID)
+}
+
+func TestAPIFraudReviews(t *testing.T) {
+	cfg := testConfig()
+	cfg.FraudRules = "new-beneficiary=flag,velocity=block"
+	cfg.FraudVelocityLimit = 2
+	cfg.FraudLargeAmount = 1000
+
+	api := newTestAPIWithConfig(t, cfg)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 5000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// a large first payment to bob is flagged but goes through
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 1500})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	transfer := new(Transfer)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(transfer))
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 1500})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// the third transfer within the hour is blocked
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100})
+	require.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"code":"transfer_blocked"`)
+
+	rec = api.do("GET", "/admin/fraud/reviews", token, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/admin/fraud/reviews?status=pending", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var reviews []*FraudReview
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&reviews))
+	require.Len(t, reviews, 2)
+	assert.Equal(t, FraudFlag, reviews[0].Action)
+	assert.Equal(t, []string{"new-beneficiary"}, reviews[0].Rules)
+	assert.Equal(t, &transfer.ID, reviews[0].TransferID)
+	assert.Equal(t, FraudBlock, reviews[1].Action)
+	assert.Equal(t, []string{"velocity"}, reviews[1].Rules)
+
+	path := "/admin/fraud/reviews/" + strconv.Itoa(reviews[0].ID)
+
+	rec = api.do("POST", path+"/clear", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	review := new(FraudReview)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(review))
+	assert.Equal(t, FraudReviewCleared, review.Status)
+	assert.Equal(t, &admin.Number, review.ReviewedBy)
+
+	rec = api.do("POST", path+"/confirm", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("GET", "/admin/fraud/reviews?status=pending", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&reviews))
+	assert.Len(t, reviews, 1)
+
+	rec = api.do("GET", "/admin/fraud/reviews?status=open", adminToken, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
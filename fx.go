@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const defaultCurrency = "USD"
+
+// CurrencyInfo describes a supported currency. All amounts in the API and
+// the database are integers in minor units.
+type CurrencyInfo struct {
+	// Decimals is the number of minor units in one major unit, e.g. 2 for
+	// the cents of USD.
+	Decimals int
+	// Symbol is what display amounts are written with.
+	Symbol string
+}
+
+// currencies maps the supported ISO 4217 codes to their metadata.
+var currencies = map[string]CurrencyInfo{
+	"USD": {Decimals: 2, Symbol: "$"},
+	"EUR": {Decimals: 2, Symbol: "€"},
+	"GBP": {Decimals: 2, Symbol: "£"},
+	"CHF": {Decimals: 2, Symbol: "CHF"},
+	"JPY": {Decimals: 0, Symbol: "¥"},
+}
+
+func validCurrency(currency string) bool {
+	_, ok := currencies[currency]
+	return ok
+}
+
+// ExchangeRateProvider returns how many units of the to currency one unit of
+// the from currency buys, expressed in major units.
+type ExchangeRateProvider interface {
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// defaultExchangeRates are quoted against USD.
+var defaultExchangeRates = map[string]string{
+	"USD/EUR": "0.92",
+	"USD/GBP": "0.79",
+	"USD/CHF": "0.88",
+	"USD/JPY": "149.50",
+}
+
+// StaticRateProvider serves a fixed table of "FROM/TO" rates. Inverse rates
+// and crosses through a common currency are derived from the table.
+type StaticRateProvider struct {
+	rates map[string]*big.Rat
+}
+
+func NewStaticRateProvider(rates map[string]string) (*StaticRateProvider, error) {
+	p := &StaticRateProvider{rates: map[string]*big.Rat{}}
+
+	for pair, value := range rates {
+		rate, ok := new(big.Rat).SetString(value)
+
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %s for %s", value, pair)
+		}
+
+		p.rates[pair] = rate
+	}
+
+	return p, nil
+}
+
+func (p *StaticRateProvider) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	if rate := p.direct(from, to); rate != nil {
+		return rate, nil
+	}
+
+	for pair := range p.rates {
+		base := strings.SplitN(pair, "/", 2)[0]
+		left, right := p.direct(from, base), p.direct(base, to)
+
+		if left != nil && right != nil {
+			return new(big.Rat).Mul(left, right), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no exchange rate from %s to %s", from, to)
+}
+
+func (p *StaticRateProvider) direct(from, to string) *big.Rat {
+	if from == to {
+		return big.NewRat(1, 1)
+	}
+
+	if rate, ok := p.rates[from+"/"+to]; ok {
+		return rate
+	}
+
+	if rate, ok := p.rates[to+"/"+from]; ok {
+		return new(big.Rat).Inv(rate)
+	}
+
+	return nil
+}
+
+// convertAmount converts a positive amount of from minor units into to minor
+// units at rate, rounding half up.
+func convertAmount(amount int64, from, to string, rate *big.Rat) (int64, error) {
+	fromInfo, ok := currencies[from]
+
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %s", from)
+	}
+
+	toInfo, ok := currencies[to]
+
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %s", to)
+	}
+
+	value := new(big.Rat).Mul(big.NewRat(amount, 1), rate)
+	value.Mul(value, new(big.Rat).SetFrac(pow10(toInfo.Decimals), pow10(fromInfo.Decimals)))
+
+	quo, rem := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("converted amount out of range")
+	}
+
+	return quo.Int64(), nil
+}
+
+func pow10(exp int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
+
+// newTransfer prices a transfer of amount, in the source account's currency,
+// converting it into the destination account's currency when they differ.
+func newTransfer(ctx context.Context, accounts AccountRepository, rates ExchangeRateProvider, from, to, amount int64) (*Transfer, error) {
+	fromAcc, err := accounts.GetAccountByNumber(ctx, int(from))
+
+	if err != nil {
+		return nil, err
+	}
+
+	toAcc, err := accounts.GetAccountByNumber(ctx, int(to))
+
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &Transfer{
+		FromAccount: from,
+		ToAccount:   to,
+		Amount:      amount,
+		Currency:    fromAcc.Currency,
+		ToAmount:    amount,
+		ToCurrency:  toAcc.Currency,
+	}
+
+	if amount <= 0 || fromAcc.Currency == toAcc.Currency {
+		return transfer, nil
+	}
+
+	rate, err := rates.Rate(ctx, fromAcc.Currency, toAcc.Currency)
+
+	if err != nil {
+		return nil, validationError("transfers from %s to %s are not supported", fromAcc.Currency, toAcc.Currency)
+	}
+
+	toAmount, err := convertAmount(amount, fromAcc.Currency, toAcc.Currency, rate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transfer.ToAmount = toAmount
+	transfer.Rate = rate.FloatString(6)
+
+	return transfer, nil
+}
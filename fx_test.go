@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticRateProvider(t *testing.T) {
+	p, err := NewStaticRateProvider(defaultExchangeRates)
+	assert.Nil(t, err)
+
+	rate, err := p.Rate(context.Background(), "EUR", "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, "25/23", rate.String())
+
+	rate, err = p.Rate(context.Background(), "EUR", "GBP")
+	assert.Nil(t, err)
+	assert.Equal(t, "79/92", rate.String())
+
+	_, err = p.Rate(context.Background(), "USD", "XXX")
+	assert.NotNil(t, err)
+}
+
+func TestConvertAmount(t *testing.T) {
+	p, _ := NewStaticRateProvider(defaultExchangeRates)
+
+	rate, _ := p.Rate(context.Background(), "USD", "JPY")
+	amount, err := convertAmount(1000, "USD", "JPY", rate)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1495), amount)
+
+	rate, _ = p.Rate(context.Background(), "JPY", "USD")
+	amount, err = convertAmount(1495, "JPY", "USD", rate)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), amount)
+
+	rate, _ = p.Rate(context.Background(), "USD", "EUR")
+	amount, err = convertAmount(1, "USD", "EUR", rate)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), amount)
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/hmuir28/go-bank/bankpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcPublicMethods can be called without an access token.
+var grpcPublicMethods = map[string]bool{
+	bankpb.Bank_Login_FullMethodName:         true,
+	bankpb.Bank_CreateAccount_FullMethodName: true,
+}
+
+type grpcAccountKey struct{}
+
+type grpcTokenSourceKey struct{}
+
+// GRPCServer serves the account and transfer operations of the JSON API over
+// gRPC, on top of the same storage. Calls authenticate with the access token
+// from /login in the authorization metadata key, as Bearer <token>, or in
+// x-jwt-token.
+type GRPCServer struct {
+	bankpb.UnimplementedBankServer
+
+	listenAddr string
+	store      Storage
+	rates      ExchangeRateProvider
+	events     EventPublisher
+	tokens     *TokenIssuer
+	// stepUpAmount, coolingOffAmount, kycTransferLimit,
+	// verifiedEmailAmount and fraud work as on APIServer.
+	stepUpAmount     int64
+	coolingOffAmount int64
+	kycTransferLimit int64
+	fraud            *FraudEngine
+	accountNumbers   *AccountNumberGenerator
+	loginPolicy      LoginPolicy
+	maintenance      *MaintenanceSwitch
+
+	verifiedEmailAmount int64
+}
+
+func NewGRPCServer(cfg *Config, store Storage, rates ExchangeRateProvider, events EventPublisher) *GRPCServer {
+	return &GRPCServer{
+		listenAddr:       cfg.GRPCAddr,
+		store:            store,
+		rates:            rates,
+		events:           events,
+		tokens:           NewTokenIssuer(cfg),
+		stepUpAmount:     cfg.TOTPStepUpAmount,
+		coolingOffAmount: cfg.BeneficiaryCoolingOffAmount,
+		kycTransferLimit: cfg.KYCTransferLimit,
+		fraud:            NewFraudEngine(cfg, store),
+		accountNumbers:   NewAccountNumberGenerator(cfg.AccountNumberLength),
+		loginPolicy:      newLoginPolicy(cfg),
+		maintenance:      NewMaintenanceSwitch(store),
+
+		verifiedEmailAmount: cfg.VerifiedEmailAmount,
+	}
+}
+
+func (s *GRPCServer) server() *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcLogging, grpcRecovery, grpcErrors, grpcMaintenance(s.maintenance), grpcAuth(s.tokens, s.store)))
+	bankpb.RegisterBankServer(server, s)
+
+	return server
+}
+
+// Run serves gRPC until ctx is cancelled, then waits up to shutdownTimeout
+// for in-flight calls before closing the remaining connections.
+func (s *GRPCServer) Run(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.listenAddr)
+
+	if err != nil {
+		return err
+	}
+
+	server := s.server()
+	errc := make(chan error, 1)
+
+	go s.tokens.Run(ctx)
+
+	if err := s.maintenance.Load(ctx); err != nil {
+		slog.Error("loading the maintenance mode", "error", err)
+	}
+
+	go s.maintenance.Run(ctx)
+
+	go func() {
+		slog.Info("gRPC server running", "addr", s.listenAddr)
+		errc <- server.Serve(lis)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down gRPC server")
+
+	stopped := make(chan struct{})
+
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		server.Stop()
+	}
+
+	return nil
+}
+
+func grpcLogging(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := ""
+
+	if values := md.Get("x-request-id"); len(values) > 0 {
+		requestID = values[0]
+	}
+
+	requestID = acceptRequestID(requestID)
+	ctx = contextWithRequestID(ctx, requestID)
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID))
+
+	resp, err := handler(ctx, req)
+
+	slog.InfoContext(ctx, "grpc",
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"latencyMs", time.Since(start).Milliseconds(),
+	)
+
+	return resp, err
+}
+
+// grpcRecovery is withRecovery for gRPC: a panicking call is logged with its
+// stack and fails with an opaque internal error.
+func grpcRecovery(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.ErrorContext(ctx, "handler panicked", "panic", recovered, "method", info.FullMethod, "stack", string(debug.Stack()))
+			resp, err = nil, status.Error(codes.Internal, "internal server error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// grpcStatusCodes maps the HTTP status of an *HTTPError to a gRPC code.
+var grpcStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.Aborted,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// grpcErrors turns the errors returned by the shared code into gRPC statuses.
+// Like writeError, anything that is neither an *HTTPError nor a domain error
+// is reported as an opaque internal error.
+func grpcErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+
+	if err == nil {
+		return resp, nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return nil, err
+	}
+
+	httpErr, ok := asHTTPError(err)
+
+	if !ok {
+		slog.ErrorContext(ctx, "internal error", "error", err, "method", info.FullMethod)
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+
+	code, ok := grpcStatusCodes[httpErr.Status]
+
+	if !ok {
+		code = codes.Unknown
+	}
+
+	if httpErr.Code == ErrorCodeInsufficientFunds || httpErr.Code == ErrorCodeAccountInactive || httpErr.Code == ErrorCodeCoolingOff {
+		code = codes.FailedPrecondition
+	}
+
+	return nil, status.Error(code, httpErr.Message)
+}
+
+// grpcAuth checks the access token of every call except the public ones,
+// denying those of revoked sessions.
+func grpcAuth(tokens *TokenIssuer, sessions SessionRepository) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if grpcPublicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		token, source := grpcAccessToken(md)
+
+		if token == "" {
+			return nil, unauthorizedError("permission denied")
+		}
+
+		checked, err := checkAccessToken(ctx, tokens, sessions, token)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// business users' roles are only checked by the JSON API
+		if checked.userID != 0 {
+			return nil, forbiddenError("business users can't use the gRPC API")
+		}
+
+		ctx = context.WithValue(ctx, grpcAccountKey{}, checked.number)
+
+		return handler(context.WithValue(ctx, grpcTokenSourceKey{}, source), req)
+	}
+}
+
+// grpcAccessToken reads the access token from the authorization metadata
+// key with the Bearer scheme, falling back to x-jwt-token like the JSON API.
+func grpcAccessToken(md metadata.MD) (string, TokenSource) {
+	for _, value := range md.Get("authorization") {
+		if token, ok := bearerToken(value); ok {
+			return token, TokenSourceBearer
+		}
+	}
+
+	if values := md.Get("x-jwt-token"); len(values) > 0 {
+		return values[0], TokenSourceHeader
+	}
+
+	return "", ""
+}
+
+func grpcAccountNumber(ctx context.Context) int64 {
+	number, _ := ctx.Value(grpcAccountKey{}).(int64)
+	return number
+}
+
+// newGRPCAuditEntry is newAuditEntry for the caller of a gRPC call.
+func newGRPCAuditEntry(ctx context.Context, action AuditAction, account int64, before, after any) *AuditEntry {
+	var actor *int64
+
+	if number := grpcAccountNumber(ctx); number != 0 {
+		actor = &number
+	}
+
+	entry := auditEntry(action, account, actor, grpcClientIP(ctx), before, after)
+	entry.TokenSource, _ = ctx.Value(grpcTokenSourceKey{}).(TokenSource)
+
+	return entry
+}
+
+func grpcClientIP(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return clientIP(p.Addr.String())
+	}
+
+	return ""
+}
+
+// grpcDevice is the device a gRPC call is made from.
+func grpcDevice(ctx context.Context) Device {
+	md, _ := metadata.FromIncomingContext(ctx)
+	userAgent := ""
+
+	if values := md.Get("user-agent"); len(values) > 0 {
+		userAgent = values[0]
+	}
+
+	return NewDevice(userAgent, grpcClientIP(ctx))
+}
+
+func (s *GRPCServer) Login(ctx context.Context, req *bankpb.LoginRequest) (*bankpb.LoginResponse, error) {
+	now, ip := time.Now().UTC(), grpcClientIP(ctx)
+
+	if err := checkLoginThrottle(ctx, s.store, s.loginPolicy, req.Number, "", ip, now); err != nil {
+		return nil, err
+	}
+
+	resp, err := login(ctx, s.store, s.tokens, req.Number, req.Password, req.TotpCode, grpcDevice(ctx))
+
+	if isLoginFailure(err) {
+		recordAudit(ctx, s.store, newGRPCAuditEntry(ctx, AuditLoginFailed, req.Number, nil, nil))
+		recordLoginFailure(ctx, s.store, s.events, s.loginPolicy, req.Number, "", ip, now, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	clearLoginFailures(ctx, s.store, s.loginPolicy, resp.Number, "")
+
+	recordLoginNetwork(ctx, s.store, s.events, resp.Number, grpcClientIP(ctx))
+
+	return &bankpb.LoginResponse{
+		Number:       resp.Number,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    resp.ExpiresIn,
+	}, nil
+}
+
+func (s *GRPCServer) CreateAccount(ctx context.Context, req *bankpb.CreateAccountRequest) (*bankpb.Account, error) {
+	accountRequest := &AccountRequest{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Password:  req.Password,
+		Currency:  req.Currency,
+		Type:      AccountType(req.Type),
+	}
+
+	if err := accountRequest.Validate(); err != nil {
+		return nil, err
+	}
+
+	account, err := newAccountFromRequest(accountRequest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createAccount(ctx, s.store, s.accountNumbers, account); err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, &Event{Type: EventAccountCreated, AccountNumber: account.Number, Data: account})
+	recordAudit(ctx, s.store, newGRPCAuditEntry(ctx, AuditAccountCreated, account.Number, nil, account))
+
+	return accountToProto(account), nil
+}
+
+func (s *GRPCServer) GetAccount(ctx context.Context, req *bankpb.GetAccountRequest) (*bankpb.Account, error) {
+	account, err := s.store.GetAccountByNumber(ctx, int(grpcAccountNumber(ctx)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return accountToProto(account), nil
+}
+
+func (s *GRPCServer) ListTransactions(ctx context.Context, req *bankpb.ListTransactionsRequest) (*bankpb.ListTransactionsResponse, error) {
+	limit, offset := int(req.Limit), int(req.Offset)
+
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	if limit < 0 || limit > maxPageLimit {
+		return nil, badRequestError("invalid limit %d", req.Limit)
+	}
+
+	if offset < 0 {
+		return nil, badRequestError("invalid offset %d", req.Offset)
+	}
+
+	transactions, err := s.store.GetTransactions(ctx, grpcAccountNumber(ctx), limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &bankpb.ListTransactionsResponse{}
+
+	for _, t := range transactions {
+		resp.Transactions = append(resp.Transactions, transactionToProto(t))
+	}
+
+	return resp, nil
+}
+
+func (s *GRPCServer) Deposit(ctx context.Context, req *bankpb.AmountRequest) (*bankpb.Transaction, error) {
+	transaction, err := s.store.Deposit(ctx, grpcAccountNumber(ctx), req.Amount, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, &Event{Type: EventTransactionCreated, AccountNumber: transaction.AccountNumber, Data: transaction})
+
+	return transactionToProto(transaction), nil
+}
+
+func (s *GRPCServer) Withdraw(ctx context.Context, req *bankpb.AmountRequest) (*bankpb.Transaction, error) {
+	account, err := s.store.GetAccountByNumber(ctx, int(grpcAccountNumber(ctx)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.store.Withdraw(ctx, account.Number, req.Amount, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, &Event{Type: EventTransactionCreated, AccountNumber: account.Number, Data: transaction})
+	publishBalanceEvent(ctx, s.events, account.Number, transaction.Balance, account.Currency)
+
+	return transactionToProto(transaction), nil
+}
+
+func (s *GRPCServer) CreateTransfer(ctx context.Context, req *bankpb.TransferRequest) (*bankpb.Transfer, error) {
+	transferRequest := &TransferRequest{ToAccount: int(req.ToAccount), Amount: req.Amount}
+
+	if err := transferRequest.ValidateFrom(grpcAccountNumber(ctx)); err != nil {
+		return nil, err
+	}
+
+	if err := s.accountNumbers.Check("toAccount", req.ToAccount); err != nil {
+		return nil, err
+	}
+
+	if err := checkBeneficiaryCoolingOff(ctx, s.store, s.coolingOffAmount, grpcAccountNumber(ctx), req.ToAccount, req.Amount, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	if err := checkKYCTransferLimit(ctx, s.store, s.kycTransferLimit, grpcAccountNumber(ctx), req.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := checkVerifiedEmail(ctx, s.store, s.verifiedEmailAmount, grpcAccountNumber(ctx), req.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := checkSecondOwner(ctx, s.store, grpcAccountNumber(ctx), req.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := checkTransferStepUp(ctx, s.store, s.stepUpAmount, grpcAccountNumber(ctx), req.Amount, req.TotpCode); err != nil {
+		return nil, err
+	}
+
+	transfer, err := newTransfer(ctx, s.store, s.rates, grpcAccountNumber(ctx), req.ToAccount, req.Amount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	review, err := s.fraud.Screen(ctx, &FraudCheck{Transfer: transfer, IP: grpcClientIP(ctx), At: time.Now().UTC()})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Transfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+
+	recordFraudReview(ctx, s.store, review, transfer)
+	publishTransferEvents(ctx, s.events, s.store, transfer)
+
+	return &bankpb.Transfer{
+		Id:          int64(transfer.ID),
+		FromAccount: transfer.FromAccount,
+		ToAccount:   transfer.ToAccount,
+		Amount:      transfer.Amount,
+		Currency:    transfer.Currency,
+		ToAmount:    transfer.ToAmount,
+		ToCurrency:  transfer.ToCurrency,
+		Rate:        transfer.Rate,
+		CreatedAt:   timestamppb.New(transfer.CreatedAt),
+	}, nil
+}
+
+func accountToProto(account *Account) *bankpb.Account {
+	return &bankpb.Account{
+		Id:              int64(account.ID),
+		FirstName:       account.FirstName,
+		LastName:        account.LastName,
+		Number:          account.Number,
+		Balance:         account.Balance,
+		Currency:        account.Currency,
+		Role:            string(account.Role),
+		Type:            string(account.Type),
+		AccruedInterest: account.AccruedInterest,
+		CreatedAt:       timestamppb.New(account.CreatedAt),
+	}
+}
+
+func transactionToProto(t *Transaction) *bankpb.Transaction {
+	return &bankpb.Transaction{
+		Id:            int64(t.ID),
+		AccountNumber: t.AccountNumber,
+		Type:          string(t.Type),
+		Amount:        t.Amount,
+		Balance:       t.Balance,
+		Counterparty:  t.Counterparty,
+		CreatedAt:     timestamppb.New(t.CreatedAt),
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/hmuir28/go-bank/bankpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestGRPCClient(t *testing.T, store Storage) bankpb.BankClient {
+	rates, err := NewStaticRateProvider(defaultExchangeRates)
+	require.Nil(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(testConfig(), store, rates, NewWebhookDispatcher(store)).server()
+
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return bankpb.NewBankClient(conn)
+}
+
+func TestGRPCTransfer(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	client := newTestGRPCClient(t, store)
+
+	alice, err := client.CreateAccount(ctx, &bankpb.CreateAccountRequest{FirstName: "Alice", LastName: "Test", Password: "alice-pw"})
+	require.Nil(t, err)
+	bob, err := client.CreateAccount(ctx, &bankpb.CreateAccountRequest{FirstName: "Bob", LastName: "Test", Password: "bob-pw"})
+	require.Nil(t, err)
+
+	login, err := client.Login(ctx, &bankpb.LoginRequest{Number: alice.Number, Password: "alice-pw"})
+	require.Nil(t, err)
+
+	authed := metadata.AppendToOutgoingContext(ctx, "x-jwt-token", login.Token)
+
+	_, err = client.Deposit(authed, &bankpb.AmountRequest{Amount: 1000})
+	require.Nil(t, err)
+
+	transfer, err := client.CreateTransfer(authed, &bankpb.TransferRequest{ToAccount: bob.Number, Amount: 400})
+	require.Nil(t, err)
+	assert.Equal(t, int64(400), transfer.ToAmount)
+
+	_, err = client.CreateTransfer(authed, &bankpb.TransferRequest{ToAccount: bob.Number, Amount: 1000})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	account, err := client.GetAccount(authed, &bankpb.GetAccountRequest{})
+	require.Nil(t, err)
+	assert.Equal(t, int64(600), account.Balance)
+
+	list, err := client.ListTransactions(authed, &bankpb.ListTransactionsRequest{})
+	require.Nil(t, err)
+	assert.Len(t, list.Transactions, 2)
+}
+
+func TestGRPCRequiresToken(t *testing.T) {
+	client := newTestGRPCClient(t, NewMemoryStore())
+
+	_, err := client.GetAccount(context.Background(), &bankpb.GetAccountRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Login(context.Background(), &bankpb.LoginRequest{Number: 1, Password: "x"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGRPCBearerToken(t *testing.T) {
+	ctx := context.Background()
+	client := newTestGRPCClient(t, NewMemoryStore())
+
+	alice, err := client.CreateAccount(ctx, &bankpb.CreateAccountRequest{FirstName: "Alice", LastName: "Test", Password: "alice-pw"})
+	require.Nil(t, err)
+
+	login, err := client.Login(ctx, &bankpb.LoginRequest{Number: alice.Number, Password: "alice-pw"})
+	require.Nil(t, err)
+
+	account, err := client.GetAccount(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+login.Token), &bankpb.GetAccountRequest{})
+	require.Nil(t, err)
+	assert.Equal(t, alice.Number, account.Number)
+
+	_, err = client.GetAccount(metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+login.Token), &bankpb.GetAccountRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGRPCRecovery(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: bankpb.Bank_GetAccount_FullMethodName}
+
+	_, err := grpcRecovery(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotContains(t, err.Error(), "boom")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	holdReaperInterval  = time.Minute
+	holdReaperBatchSize = 100
+)
+
+// HoldReaper releases the funds of authorized transfers that were not
+// captured before they expired, and of approved card authorizations. Several
+// reapers may run against the same database.
+type HoldReaper struct {
+	store Storage
+}
+
+func NewHoldReaper(store Storage) *HoldReaper {
+	return &HoldReaper{store: store}
+}
+
+func (h *HoldReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(holdReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		h.expireDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireDue expires holds and card authorizations in batches until none are
+// left due at now.
+func (h *HoldReaper) expireDue(ctx context.Context, now time.Time) {
+	h.expireHolds(ctx, now)
+	h.expireCardAuthorizations(ctx, now)
+}
+
+func (h *HoldReaper) expireHolds(ctx context.Context, now time.Time) {
+	for {
+		holds, err := h.store.ExpireHolds(ctx, now, holdReaperBatchSize)
+
+		if err != nil {
+			slog.Error("expiring holds", "error", err)
+			return
+		}
+
+		for _, hold := range holds {
+			slog.Info("hold expired", "hold", hold.ID, "account", hold.FromAccount, "amount", hold.Amount)
+		}
+
+		if len(holds) < holdReaperBatchSize {
+			return
+		}
+	}
+}
+
+func (h *HoldReaper) expireCardAuthorizations(ctx context.Context, now time.Time) {
+	for {
+		auths, err := h.store.ExpireCardAuthorizations(ctx, now, holdReaperBatchSize)
+
+		if err != nil {
+			slog.Error("expiring card authorizations", "error", err)
+			return
+		}
+
+		for _, auth := range auths {
+			slog.Info("card authorization expired", "authorization", auth.ID, "account", auth.AccountNumber, "amount", auth.Amount)
+		}
+
+		if len(auths) < holdReaperBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreHolds(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	alice := &Account{Number: 1, Currency: "USD", Status: AccountActive}
+	bob := &Account{Number: 2, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, alice))
+	require.Nil(t, store.CreateAccount(ctx, bob))
+
+	_, err := store.Deposit(ctx, alice.Number, 1000, 0)
+	require.Nil(t, err)
+
+	transfer := &Transfer{FromAccount: 1, ToAccount: 2, Amount: 600, Currency: "USD", ToAmount: 600, ToCurrency: "USD"}
+	hold := NewHold(transfer, time.Hour)
+	require.Nil(t, store.AuthorizeTransfer(ctx, hold))
+
+	// only 400 is available while the hold is authorized
+	assert.ErrorContains(t, store.AuthorizeTransfer(ctx, NewHold(transfer, time.Hour)), "insufficient funds")
+	_, err = store.Withdraw(ctx, alice.Number, 500, 0)
+	assert.ErrorContains(t, err, "insufficient funds")
+
+	acc, _ := store.GetAccountByNumber(ctx, 1)
+	assert.Equal(t, int64(1000), acc.Balance)
+	assert.Equal(t, int64(600), acc.HeldBalance)
+
+	// captures are refused once the hold expired, even before it is reaped
+	_, err = store.CaptureHold(ctx, hold.ID, alice.Number, hold.ExpiresAt)
+	assert.ErrorContains(t, err, "is expired")
+
+	captured, err := store.CaptureHold(ctx, hold.ID, alice.Number, time.Now().UTC())
+	require.Nil(t, err)
+	assert.Equal(t, int64(600), captured.Amount)
+
+	acc, _ = store.GetAccountByNumber(ctx, 1)
+	assert.Equal(t, int64(400), acc.Balance)
+	assert.Equal(t, int64(0), acc.HeldBalance)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestCannotCloseAccountWithHolds(t *testing.T) {
+	acc := &Account{Status: AccountActive, HeldBalance: 100}
+
+	assert.ErrorContains(t, acc.CheckStatusChange(AccountClosed), "on hold")
+	assert.Nil(t, acc.CheckStatusChange(AccountFrozen))
+}
+
+func TestHoldReaperExpiresHolds(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 1, Currency: "USD", Status: AccountActive}))
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 2, Currency: "USD", Status: AccountActive}))
+
+	_, err := store.Deposit(ctx, 1, 1000, 0)
+	require.Nil(t, err)
+
+	transfer := &Transfer{FromAccount: 1, ToAccount: 2, Amount: 300, Currency: "USD", ToAmount: 300, ToCurrency: "USD"}
+	short, long := NewHold(transfer, time.Minute), NewHold(transfer, time.Hour)
+	require.Nil(t, store.AuthorizeTransfer(ctx, short))
+	require.Nil(t, store.AuthorizeTransfer(ctx, long))
+
+	NewHoldReaper(store).expireDue(ctx, short.ExpiresAt)
+
+	acc, _ := store.GetAccountByNumber(ctx, 1)
+	assert.Equal(t, int64(300), acc.HeldBalance)
+
+	_, err = store.CaptureHold(ctx, short.ID, 1, time.Now().UTC())
+	assert.ErrorContains(t, err, "is expired")
+
+	_, err = store.CaptureHold(ctx, long.ID, 1, time.Now().UTC())
+	assert.Nil(t, err)
+}
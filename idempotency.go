@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	rec.body.Write(b)
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// withIdempotency makes POST handlers safe to retry. When the request carries
+// an Idempotency-Key header the first successful response is stored and
+// replayed for every later request with the same key, instead of running the
+// handler again. Failed responses, and handlers that panic, release the key
+// so the client can retry.
+func withIdempotency(s Storage) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+
+			if key == "" || r.Method != "POST" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				writeError(w, r, badRequestError("Idempotency-Key is too long"))
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+
+			if err != nil {
+				writeError(w, r, badRequestError("invalid request body"))
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.Sum256(body)
+			rec := &IdempotencyRecord{
+				Key:         key,
+				Scope:       idempotencyScope(r),
+				RequestHash: hex.EncodeToString(sum[:]),
+				CreatedAt:   time.Now().UTC(),
+			}
+
+			stored, reserved, err := s.ReserveIdempotencyKey(r.Context(), rec)
+
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			if !reserved {
+				replayIdempotentResponse(w, r, rec, stored)
+				return
+			}
+
+			recorder := &idempotencyRecorder{ResponseWriter: w}
+			returned := false
+
+			// a handler that panics is answered by withRecovery, but its key
+			// must be released here or every retry would find it in progress
+			defer func() {
+				if returned {
+					return
+				}
+
+				if err := s.ReleaseIdempotencyKey(context.Background(), rec.Key, rec.Scope); err != nil {
+					slog.Error("failed to release idempotency key", "error", err)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+			returned = true
+
+			// the handler has already run, so the outcome must be recorded even if
+			// the client went away in the meantime
+			ctx := context.Background()
+
+			if recorder.status >= 200 && recorder.status < 300 {
+				rec.StatusCode = recorder.status
+				rec.ResponseBody = recorder.body.Bytes()
+
+				if err := s.CompleteIdempotencyKey(ctx, rec); err != nil {
+					slog.Error("failed to store idempotent response", "error", err)
+				}
+
+				return
+			}
+
+			if err := s.ReleaseIdempotencyKey(ctx, rec.Key, rec.Scope); err != nil {
+				slog.Error("failed to release idempotency key", "error", err)
+			}
+		})
+	}
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, rec, stored *IdempotencyRecord) {
+	if stored.RequestHash != rec.RequestHash {
+		writeError(w, r, validationError("Idempotency-Key was already used with a different request"))
+		return
+	}
+
+	if stored.Status != IdempotencyCompleted {
+		writeError(w, r, conflictError("a request with this Idempotency-Key is still in progress"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.ResponseBody)
+}
+
+// idempotencyScope keeps keys of different endpoints, and of different
+// authenticated accounts, from colliding with each other.
+func idempotencyScope(r *http.Request) string {
+	scope := r.Method + " " + r.URL.Path
+
+	if number, err := getAccountNumberFromToken(r); err == nil {
+		scope += " " + strconv.FormatInt(number, 10)
+	}
+
+	return scope
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithIdempotencyPanic(t *testing.T) {
+	calls := 0
+	handler := withRecovery(withIdempotency(NewMemoryStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		if calls == 1 {
+			panic("boom")
+		}
+
+		w.Write([]byte(`{"ok":true}`))
+	})))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/deposit", strings.NewReader(`{"amount":100}`))
+		req.Header.Set("Idempotency-Key", "retry-me")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := send()
+	require.Equal(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+
+	rec = send()
+	require.Equal(t, http.StatusOK, rec.Code, "the key of the request that panicked is released: %s", rec.Body.String())
+	assert.Equal(t, 2, calls)
+
+	rec = send()
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls, "and the response of the retry is replayed")
+}
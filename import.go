@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxImportSize              = 1 << 20
+	maxImportRows              = 1000
+	maxImportDescriptionLength = 140
+	maxImportIDLength          = 255
+
+	ofxDateLayout = "20060102"
+)
+
+var defaultCSVMapping = CSVMapping{
+	Date:        "date",
+	Amount:      "amount",
+	Description: "description",
+	ID:          "id",
+	DateFormat:  time.DateOnly,
+	Delimiter:   ',',
+}
+
+// ofxTag matches an OFX element and its value. OFX 1.x is SGML and leaves
+// leaf elements unclosed, so values end at the next tag or line break.
+var ofxTag = regexp.MustCompile(`<(/?[A-Za-z0-9.]+)>([^<\r\n]*)`)
+
+// importRecord is an external transaction as it is written in the file.
+type importRecord struct {
+	id, date, amount, description string
+}
+
+// parseImport reads the external transactions of an imported file in the
+// account's currency. Problems with a transaction are reported as
+// rows[n].field.
+func parseImport(format ImportFormat, r io.Reader, mapping CSVMapping, currency string) ([]*ImportRow, error) {
+	var records []importRecord
+	var err error
+
+	layout := mapping.DateFormat
+
+	if format == ImportOFX {
+		records, err = parseOFX(r, currency)
+		layout = ofxDateLayout
+	} else {
+		records, err = parseCSV(r, mapping)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	errs := FieldErrors{}
+
+	switch {
+	case len(records) == 0:
+		errs.Add("file", "has no transactions")
+	case len(records) > maxImportRows:
+		errs.Add("file", "must have at most %d transactions", maxImportRows)
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	rows := make([]*ImportRow, len(records))
+
+	for n, record := range records {
+		row := &ImportRow{ExternalID: record.id, Description: record.description}
+
+		if at, err := time.Parse(layout, record.date); err != nil {
+			errs.Add(fmt.Sprintf("rows[%d].date", n), "must be a date as %s", layout)
+		} else {
+			row.Date = at.Format(time.DateOnly)
+		}
+
+		if amount, ok := parseAmount(record.amount, currency); !ok {
+			errs.Add(fmt.Sprintf("rows[%d].amount", n), "must be a non-zero amount in %s", currency)
+		} else {
+			row.Amount = amount
+		}
+
+		if len(row.ExternalID) > maxImportIDLength {
+			errs.Add(fmt.Sprintf("rows[%d].id", n), "must be at most %d characters", maxImportIDLength)
+		}
+
+		if utf8.RuneCountInString(row.Description) > maxImportDescriptionLength {
+			errs.Add(fmt.Sprintf("rows[%d].description", n), "must be at most %d characters", maxImportDescriptionLength)
+		}
+
+		rows[n] = row
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	fingerprintRows(rows)
+
+	return rows, nil
+}
+
+func parseCSV(r io.Reader, mapping CSVMapping) ([]importRecord, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = mapping.Delimiter
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	lines, err := cr.ReadAll()
+
+	if err != nil {
+		return nil, badRequestError("invalid CSV file: %s", err)
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+
+	for i, name := range lines[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	column := func(name string) int {
+		if i, ok := columns[strings.ToLower(name)]; ok {
+			return i
+		}
+
+		return -1
+	}
+
+	date, amount, description, id := column(mapping.Date), column(mapping.Amount), column(mapping.Description), column(mapping.ID)
+
+	errs := FieldErrors{}
+
+	if date < 0 {
+		errs.Add("dateColumn", "no %q column in the header", mapping.Date)
+	}
+
+	if amount < 0 {
+		errs.Add("amountColumn", "no %q column in the header", mapping.Amount)
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	field := func(line []string, i int) string {
+		if i < 0 || i >= len(line) {
+			return ""
+		}
+
+		return strings.TrimSpace(line[i])
+	}
+
+	records := make([]importRecord, 0, len(lines)-1)
+
+	for _, line := range lines[1:] {
+		records = append(records, importRecord{
+			id:          field(line, id),
+			date:        field(line, date),
+			amount:      field(line, amount),
+			description: field(line, description),
+		})
+	}
+
+	return records, nil
+}
+
+// parseOFX reads the STMTTRN elements of an OFX bank statement, in SGML or
+// XML form. Transactions without a NAME are described by their MEMO.
+func parseOFX(r io.Reader, currency string) ([]importRecord, error) {
+	records := []importRecord{}
+
+	var record *importRecord
+	var memo string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportSize)
+
+	for scanner.Scan() {
+		for _, match := range ofxTag.FindAllStringSubmatch(scanner.Text(), -1) {
+			tag, value := strings.ToUpper(match[1]), strings.TrimSpace(match[2])
+
+			switch {
+			case tag == "CURDEF" && value != currency:
+				return nil, validationError("the file is in %s, the account is in %s", value, currency)
+			case tag == "STMTTRN":
+				record, memo = new(importRecord), ""
+			case record == nil:
+			case tag == "/STMTTRN":
+				if record.description == "" {
+					record.description = memo
+				}
+
+				records = append(records, *record)
+				record = nil
+			case tag == "FITID":
+				record.id = value
+			case tag == "NAME":
+				record.description = value
+			case tag == "MEMO":
+				memo = value
+			case tag == "DTPOSTED":
+				// the time and time zone after the date are ignored
+				record.date = value[:min(len(value), len(ofxDateLayout))]
+			case tag == "TRNAMT":
+				record.amount = value
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, badRequestError("invalid OFX file: %s", err)
+	}
+
+	return records, nil
+}
+
+// parseAmount parses a signed decimal amount in major units, like 12.34 or
+// -5,00, into minor units of currency. Zero is refused.
+func parseAmount(value, currency string) (int64, bool) {
+	value = strings.ReplaceAll(value, " ", "")
+
+	if !strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	exp := currencies[currency].Decimals
+
+	if len(fraction) > exp || strings.ContainsAny(fraction, "+-") {
+		return 0, false
+	}
+
+	units, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", exp-len(fraction)), 10, 64)
+
+	if err != nil || units == 0 {
+		return 0, false
+	}
+
+	return units, true
+}
+
+// fingerprintRows identifies each row by its external ID, or else by its
+// date, amount, description and how many identical rows came before it, so
+// importing the same file again finds the same fingerprints.
+func fingerprintRows(rows []*ImportRow) {
+	seen := map[string]int{}
+
+	for _, row := range rows {
+		if row.ExternalID != "" {
+			row.fingerprint = hashToken("id|" + row.ExternalID)
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%d|%s", row.Date, row.Amount, row.Description)
+		row.fingerprint = hashToken(fmt.Sprintf("row|%s|%d", key, seen[key]))
+		seen[key]++
+	}
+}
+
+// importPeriod returns the first day of the rows and the day after their
+// last, for looking up the ledger entries they may duplicate.
+func importPeriod(rows []*ImportRow) (time.Time, time.Time) {
+	first, last := rows[0].Date, rows[0].Date
+
+	for _, row := range rows {
+		first, last = min(first, row.Date), max(last, row.Date)
+	}
+
+	from, _ := time.Parse(time.DateOnly, first)
+	to, _ := time.Parse(time.DateOnly, last)
+
+	return from, to.AddDate(0, 0, 1)
+}
+
+// markDuplicates marks the rows imported before, by fingerprint, and the
+// rows matching a ledger entry of the same amount on the same day. Each
+// ledger entry matches at most one row. imported maps fingerprints to the
+// transactions they were booked as; ledger holds the account's entries of
+// the import period, imported ones excluded.
+func markDuplicates(rows []*ImportRow, imported map[string]int, ledger []*Transaction) {
+	unmatched := map[string][]int{}
+
+	for _, t := range ledger {
+		key := fmt.Sprintf("%s|%d", t.CreatedAt.UTC().Format(time.DateOnly), t.Amount)
+		unmatched[key] = append(unmatched[key], t.ID)
+	}
+
+	seen := map[string]bool{}
+
+	for _, row := range rows {
+		row.Status = ImportRowNew
+		key := fmt.Sprintf("%s|%d", row.Date, row.Amount)
+
+		if id, ok := imported[row.fingerprint]; ok {
+			row.Status = ImportRowDuplicate
+			row.TransactionID = &id
+		} else if seen[row.fingerprint] {
+			// the file repeats an external ID
+			row.Status = ImportRowDuplicate
+		} else if ids := unmatched[key]; len(ids) > 0 {
+			row.Status = ImportRowDuplicate
+			row.TransactionID = &ids[0]
+			unmatched[key] = ids[1:]
+		}
+
+		seen[row.fingerprint] = true
+	}
+}
+
+// checkImport returns an error unless acc can take the new rows in order
+// without any debit exceeding its available balance.
+func checkImport(acc *Account, rows []*ImportRow) error {
+	if err := acc.CheckActive(); err != nil {
+		return err
+	}
+
+	running := *acc
+
+	for _, row := range rows {
+		if row.Status != ImportRowNew {
+			continue
+		}
+
+		if row.Amount < 0 && !running.CanDebit(-row.Amount) {
+			return insufficientFundsError()
+		}
+
+		running.Balance += row.Amount
+	}
+
+	return nil
+}
+
+// NewImportResult counts the outcome of the rows.
+func NewImportResult(rows []*ImportRow, dryRun bool) *ImportResult {
+	result := &ImportResult{DryRun: dryRun, Rows: rows}
+
+	for _, row := range rows {
+		if row.Status == ImportRowDuplicate {
+			result.Duplicates++
+		} else {
+			result.Imported++
+		}
+	}
+
+	return result
+}
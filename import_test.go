@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOFX = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240105120000[-5:EST]
+<TRNAMT>1500.00
+<FITID>2024010501
+<NAME>Payroll
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240107
+<TRNAMT>-42.50
+<FITID>2024010702
+<MEMO>Groceries
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+func TestParseAmount(t *testing.T) {
+	for value, want := range map[string]int64{"12.34": 1234, "-5,5": -550, "+3": 300, "1 000.00": 100000, "-.01": -1} {
+		amount, ok := parseAmount(value, "USD")
+		assert.True(t, ok, value)
+		assert.Equal(t, want, amount, value)
+	}
+
+	amount, ok := parseAmount("1500", "JPY")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1500), amount)
+
+	for _, value := range []string{"", "0", "0.00", "1.234", "1,234.5", "abc", "1.-2"} {
+		_, ok := parseAmount(value, "USD")
+		assert.False(t, ok, value)
+	}
+}
+
+func TestParseImportCSV(t *testing.T) {
+	file := "Booked;Value;Text\n31/01/2024;-12,50;Coffee\n31/01/2024;-12,50;Coffee\n01/02/2024;100;\n"
+	mapping := CSVMapping{Date: "booked", Amount: "value", Description: "text", ID: "id", DateFormat: "02/01/2006", Delimiter: ';'}
+
+	rows, err := parseImport(ImportCSV, strings.NewReader(file), mapping, "EUR")
+	require.Nil(t, err)
+	require.Len(t, rows, 3)
+
+	assert.Equal(t, "2024-01-31", rows[0].Date)
+	assert.Equal(t, int64(-1250), rows[0].Amount)
+	assert.Equal(t, "Coffee", rows[0].Description)
+	assert.NotEqual(t, rows[0].fingerprint, rows[1].fingerprint, "identical rows are told apart by their position")
+
+	again, err := parseImport(ImportCSV, strings.NewReader(file), mapping, "EUR")
+	require.Nil(t, err)
+	assert.Equal(t, rows[1].fingerprint, again[1].fingerprint)
+
+	_, err = parseImport(ImportCSV, strings.NewReader(file), defaultCSVMapping, "EUR")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "dateColumn")
+
+	_, err = parseImport(ImportCSV, strings.NewReader("date,amount\n2024-13-01,x\n"), defaultCSVMapping, "EUR")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "rows[0].date")
+	assert.Contains(t, err.Error(), "rows[0].amount")
+
+	_, err = parseImport(ImportCSV, strings.NewReader("date,amount\n"), defaultCSVMapping, "EUR")
+	assert.NotNil(t, err)
+}
+
+func TestParseImportOFX(t *testing.T) {
+	rows, err := parseImport(ImportOFX, strings.NewReader(testOFX), defaultCSVMapping, "USD")
+	require.Nil(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, &ImportRow{ExternalID: "2024010501", Date: "2024-01-05", Amount: 150000, Description: "Payroll", fingerprint: hashToken("id|2024010501")}, rows[0])
+	assert.Equal(t, "2024-01-07", rows[1].Date)
+	assert.Equal(t, int64(-4250), rows[1].Amount)
+	assert.Equal(t, "Groceries", rows[1].Description)
+
+	_, err = parseImport(ImportOFX, strings.NewReader(testOFX), defaultCSVMapping, "EUR")
+	assert.NotNil(t, err, "the file must be in the account's currency")
+}
+
+func TestMemoryStoreImportTransactions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+	today := now.Format(time.DateOnly)
+
+	acc := &Account{Number: 42, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	deposit, err := store.Deposit(ctx, 42, 5000, 0)
+	require.Nil(t, err)
+
+	file := "date,amount,description\n" + today + ",50.00,cash\n" + today + ",-3.00,coffee\n" + today + ",-3.00,coffee\n"
+
+	parse := func() []*ImportRow {
+		rows, err := parseImport(ImportCSV, strings.NewReader(file), defaultCSVMapping, "USD")
+		require.Nil(t, err)
+
+		return rows
+	}
+
+	result, err := store.ImportTransactions(ctx, 42, parse(), true, now)
+	require.Nil(t, err)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 1, result.Duplicates)
+	assert.Equal(t, ImportRowDuplicate, result.Rows[0].Status, "the deposit is already in the ledger")
+	assert.Equal(t, &deposit.ID, result.Rows[0].TransactionID)
+	assert.Equal(t, ImportRowNew, result.Rows[1].Status)
+
+	unchanged, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Equal(t, int64(5000), unchanged.Balance, "dry runs don't book anything")
+
+	result, err = store.ImportTransactions(ctx, 42, parse(), false, now)
+	require.Nil(t, err)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, ImportRowImported, result.Rows[2].Status)
+	require.NotNil(t, result.Rows[2].TransactionID)
+
+	booked, _ := store.GetAccountByNumber(ctx, 42)
+	assert.Equal(t, int64(4400), booked.Balance)
+
+	result, err = store.ImportTransactions(ctx, 42, parse(), false, now)
+	require.Nil(t, err)
+	assert.Zero(t, result.Imported, "importing the same file again imports nothing")
+	assert.Equal(t, 3, result.Duplicates)
+
+	rows, err := parseImport(ImportCSV, strings.NewReader("date,amount\n"+today+",-44.01\n"), defaultCSVMapping, "USD")
+	require.Nil(t, err)
+
+	_, err = store.ImportTransactions(ctx, 42, rows, true, now)
+	assert.Equal(t, http.StatusUnprocessableEntity, err.(*HTTPError).Status, "imports can't overdraw the account")
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestAPIImport(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/import"
+
+	upload := func(query, contentType, file string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", path+query, strings.NewReader(file))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("x-jwt-token", token)
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, r)
+
+		return rec
+	}
+
+	rec := upload("?dryRun=true", "application/x-ofx", testOFX)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	result := new(ImportResult)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 2, result.Imported)
+
+	rec = upload("", "application/x-ofx", testOFX)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	acc, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(145750), acc.Balance)
+
+	rec = upload("?idColumn=fitid", "text/csv", "date,amount,fitid\n2024-01-05,1500.00,2024010501\n")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(result))
+	assert.Equal(t, 1, result.Duplicates, "the CSV repeats a transaction of the OFX file")
+
+	rec = upload("?delimiter=ab", "text/csv", "date,amount\n")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = upload("", "text/plain", "date,amount\n")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,129 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// The integration tests run the API against Postgres, in a container
+// unless BANK_TEST_DATABASE_URL names a server to use instead:
+//
+//	go test -tags integration -run Integration ./...
+//
+// Each test gets a database of its own, migrated from scratch.
+
+const integrationPostgresImage = "postgres:16-alpine"
+
+// integrationDSN is the server the tests create their databases on.
+var integrationDSN string
+
+var integrationDatabases atomic.Int64
+
+func TestMain(m *testing.M) {
+	integrationDSN = os.Getenv("BANK_TEST_DATABASE_URL")
+
+	if integrationDSN != "" {
+		os.Exit(m.Run())
+	}
+
+	ctx := context.Background()
+	container, err := postgres.Run(ctx, integrationPostgresImage,
+		postgres.WithDatabase("bank"),
+		postgres.WithUsername("bank"),
+		postgres.WithPassword("bank"),
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").WithOccurrence(2).WithStartupTimeout(time.Minute)),
+	)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "starting postgres:", err)
+		os.Exit(1)
+	}
+
+	integrationDSN, err = container.ConnectionString(ctx, "sslmode=disable")
+
+	code := 1
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "postgres connection string:", err)
+	} else {
+		code = m.Run()
+	}
+
+	if err := container.Terminate(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "stopping postgres:", err)
+	}
+
+	os.Exit(code)
+}
+
+// newIntegrationDatabase creates an empty database for the test and
+// returns its URL.
+func newIntegrationDatabase(t *testing.T) string {
+	ctx := context.Background()
+	name := fmt.Sprintf("bank_test_%d_%d", os.Getpid(), integrationDatabases.Add(1))
+
+	conn, err := pgx.Connect(ctx, integrationDSN)
+	require.Nil(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "create database "+name)
+	require.Nil(t, err)
+
+	dsn, err := url.Parse(integrationDSN)
+	require.Nil(t, err)
+	dsn.Path = "/" + name
+
+	return dsn.String()
+}
+
+// newIntegrationStore opens a Postgres store on a database of the test's
+// own, migrated from scratch.
+func newIntegrationStore(t *testing.T) Storage {
+	ctx := context.Background()
+
+	cfg := testConfig()
+	cfg.Store = "postgres"
+	cfg.DatabaseURL = newIntegrationDatabase(t)
+
+	store, err := NewPostgresStore(ctx, cfg)
+	require.Nil(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	require.Nil(t, store.Init(ctx))
+
+	return store
+}
+
+func TestIntegrationMigrations(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.DatabaseURL = newIntegrationDatabase(t)
+
+	store, err := NewPostgresStore(ctx, cfg)
+	require.Nil(t, err)
+	defer store.Close()
+
+	migrations, err := loadMigrations(migrationFiles)
+	require.Nil(t, err)
+
+	require.Nil(t, store.MigrateUp(ctx))
+	require.Nil(t, store.MigrateDown(ctx, len(migrations)), "every down migration reverts its up")
+	require.Nil(t, store.MigrateUp(ctx), "and the schema can be built again after")
+}
+
+func TestIntegrationStore(t *testing.T) {
+	runStoreCases(t, newIntegrationStore)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"time"
+)
+
+const (
+	interestInterval = time.Hour
+
+	// interestScale is the number of InterestRemainder units in a minor unit.
+	interestScale = 1_000_000
+
+	defaultSavingsAPR = "0.02"
+)
+
+// Accrue adds micros millionths of a minor unit of interest.
+func (i *AccountInterest) Accrue(micros int64) {
+	total := i.InterestRemainder + micros
+	i.AccruedInterest += total / interestScale
+	i.InterestRemainder = total % interestScale
+}
+
+// dailyInterestMicros returns one day's interest on balance at apr, in
+// millionths of a minor unit, rounded down. Overdrawn balances earn nothing.
+func dailyInterestMicros(balance int64, apr *big.Rat) int64 {
+	if balance <= 0 {
+		return 0
+	}
+
+	v := new(big.Rat).Mul(big.NewRat(balance, 1), apr)
+	v.Mul(v, big.NewRat(interestScale, 365))
+
+	return new(big.Int).Quo(v.Num(), v.Denom()).Int64()
+}
+
+// InterestAccruer accrues a day of interest on every savings account once the
+// day is over, using the balance at the end of the day, and posts the
+// interest earned in a month as an interest ledger entry on the first of the
+// next month.
+type InterestAccruer struct {
+	store Storage
+	apr   *big.Rat
+}
+
+func NewInterestAccruer(store Storage, apr *big.Rat) *InterestAccruer {
+	return &InterestAccruer{
+		store: store,
+		apr:   apr,
+	}
+}
+
+func (a *InterestAccruer) Run(ctx context.Context) {
+	ticker := time.NewTicker(interestInterval)
+	defer ticker.Stop()
+
+	for {
+		a.accrueDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *InterestAccruer) accrueDue(ctx context.Context, now time.Time) {
+	today := now.Truncate(24 * time.Hour)
+
+	accounts, err := a.store.GetAccountsDueForInterest(ctx, today)
+
+	if err != nil {
+		slog.Error("loading accounts due for interest", "error", err)
+		return
+	}
+
+	for _, acc := range accounts {
+		if err := a.accrueAccount(ctx, acc, today); err != nil {
+			slog.Error("accruing interest", "error", err, "account", acc.Number)
+		}
+	}
+}
+
+// accrueAccount catches the account up on every full day before today that
+// has not been accrued yet.
+func (a *InterestAccruer) accrueAccount(ctx context.Context, acc *Account, today time.Time) error {
+	day := acc.CreatedAt.Truncate(24 * time.Hour)
+
+	if acc.InterestAccruedThrough != nil {
+		day = acc.InterestAccruedThrough.AddDate(0, 0, 1)
+	}
+
+	for ; day.Before(today); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+
+		balance, err := a.store.GetBalanceAt(ctx, acc.Number, next)
+
+		if err != nil {
+			return err
+		}
+
+		if err := a.store.AccrueInterest(ctx, acc.Number, day, dailyInterestMicros(balance, a.apr), next.Day() == 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDailyInterestMicros(t *testing.T) {
+	apr := big.NewRat(365, 1000)
+
+	assert.Equal(t, int64(1_000_000), dailyInterestMicros(1000, apr))
+	assert.Equal(t, int64(1_000), dailyInterestMicros(1, apr))
+	assert.Equal(t, int64(0), dailyInterestMicros(-500, apr))
+}
+
+func TestAccountInterestAccrue(t *testing.T) {
+	interest := AccountInterest{}
+
+	interest.Accrue(600_000)
+	assert.Equal(t, AccountInterest{AccruedInterest: 0, InterestRemainder: 600_000}, interest)
+
+	interest.Accrue(600_000)
+	assert.Equal(t, AccountInterest{AccruedInterest: 1, InterestRemainder: 200_000}, interest)
+}
+
+func TestInterestAccruerPostsMonthly(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	created := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 1, Type: AccountSavings, Status: AccountActive, CreatedAt: created}))
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 2, Type: AccountChecking, Status: AccountActive, CreatedAt: created}))
+	_, err := store.Deposit(ctx, 1, 100_000, 0)
+	require.Nil(t, err)
+	_, err = store.Deposit(ctx, 2, 100_000, 0)
+	require.Nil(t, err)
+
+	// backdate the deposits so they count from the day the accounts opened
+	for _, t := range store.transactions {
+		t.CreatedAt = created
+	}
+
+	accruer := NewInterestAccruer(store, big.NewRat(365, 1000))
+	accruer.accrueDue(ctx, time.Date(2024, 2, 2, 6, 0, 0, 0, time.UTC))
+
+	// Jan 30 and 31 are posted on Feb 1, Feb 1 is accrued
+	savings, _ := store.GetAccountByNumber(ctx, 1)
+	assert.Equal(t, int64(100_200), savings.Balance)
+	assert.Equal(t, int64(100), savings.AccruedInterest)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), *savings.InterestAccruedThrough)
+
+	transactions, _ := store.GetTransactions(ctx, 1, 10, 0)
+	assert.Equal(t, TransactionInterest, transactions[0].Type)
+	assert.Equal(t, int64(200), transactions[0].Amount)
+
+	// running again the same day accrues nothing new
+	accruer.accrueDue(ctx, time.Date(2024, 2, 2, 7, 0, 0, 0, time.UTC))
+	savings, _ = store.GetAccountByNumber(ctx, 1)
+	assert.Equal(t, int64(100), savings.AccruedInterest)
+
+	checking, _ := store.GetAccountByNumber(ctx, 2)
+	assert.Equal(t, int64(100_000), checking.Balance)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+const (
+	minKYCAge        = 18
+	maxAddressLength = 200
+)
+
+var nationalIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]{5,30}$`)
+
+// checkKYCTransferLimit refuses transfers above threshold from accounts whose
+// holder has not been verified. A threshold of 0 disables the check.
+func checkKYCTransferLimit(ctx context.Context, store AccountRepository, threshold, from, amount int64) error {
+	if threshold <= 0 || amount <= threshold {
+		return nil
+	}
+
+	account, err := store.GetAccountByNumber(ctx, int(from))
+
+	if err != nil {
+		return err
+	}
+
+	if account.KYCStatus != KYCVerified {
+		return kycRequiredError(from, threshold)
+	}
+
+	return nil
+}
+
+// ageOn returns how many full years old someone born on birth is on day.
+func ageOn(birth, day time.Time) int {
+	age := day.Year() - birth.Year()
+
+	if day.Month() < birth.Month() || (day.Month() == birth.Month() && day.Day() < birth.Day()) {
+		age--
+	}
+
+	return age
+}
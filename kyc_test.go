@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgeOn(t *testing.T) {
+	birth := time.Date(2000, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 17, ageOn(birth, time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 18, ageOn(birth, time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 18, ageOn(birth, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestKYCRequestValidate(t *testing.T) {
+	assert.Nil(t, (&KYCRequest{DateOfBirth: "1990-05-01", NationalID: "AB-123456", Address: "1 Main St"}).Validate())
+
+	minor := time.Now().UTC().AddDate(-17, 0, 0).Format(time.DateOnly)
+
+	for _, req := range []*KYCRequest{
+		{DateOfBirth: "01/05/1990", NationalID: "AB-123456", Address: "1 Main St"},
+		{DateOfBirth: minor, NationalID: "AB-123456", Address: "1 Main St"},
+		{DateOfBirth: "1990-05-01", NationalID: "AB 1", Address: "1 Main St"},
+		{DateOfBirth: "1990-05-01", NationalID: "AB-123456", Address: " "},
+	} {
+		assert.NotNil(t, req.Validate(), req)
+	}
+}
+
+func TestAPIKYCOnboarding(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/kyc"
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 500000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// small transfers don't wait for verification, large ones do
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100000})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100001})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeKYCRequired))
+
+	rec = api.do("GET", path, token, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/kyc/approve", adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("PUT", path, token, KYCRequest{DateOfBirth: "1990-05-01", NationalID: "AB-123456", Address: "1 Main St"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/kyc", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var pending []*KYC
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&pending))
+	require.Len(t, pending, 1)
+	assert.Equal(t, alice.Number, pending[0].AccountNumber)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/kyc/reject", token, KYCRejectRequest{Reason: "blurry"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/kyc/reject", adminToken, KYCRejectRequest{})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/kyc/reject", adminToken, KYCRejectRequest{Reason: "address does not match the ID"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	kyc := new(KYC)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(kyc))
+	assert.Equal(t, KYCRejected, kyc.Status)
+	assert.Equal(t, "address does not match the ID", kyc.RejectionReason)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/kyc/approve", adminToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// resubmitting puts the account back in the queue
+	rec = api.do("PUT", path, token, KYCRequest{DateOfBirth: "1990-05-01", NationalID: "AB-123456", Address: "2 Main St"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "rejectionReason")
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/kyc/approve", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID), token, nil)
+	assert.Contains(t, rec.Body.String(), `"kycStatus":"verified"`)
+
+	api.verifyEmail(alice)
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100001})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("PUT", path, token, KYCRequest{DateOfBirth: "1990-05-01", NationalID: "AB-123456", Address: "3 Main St"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("GET", "/admin/kyc", adminToken, nil)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
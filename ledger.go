@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Ledger names a book of the double-entry ledger. Customer lines belong to
+// one account; the other books are the bank's own, one per currency.
+type Ledger string
+
+const (
+	LedgerCustomer Ledger = "customer"
+	// LedgerCash is money entering or leaving the bank through deposits
+	// and withdrawals.
+	LedgerCash     Ledger = "cash"
+	LedgerFees     Ledger = "fees"
+	LedgerInterest Ledger = "interest"
+	// LedgerFX balances the two currencies of a cross-currency transfer.
+	LedgerFX Ledger = "fx"
+	// LedgerClearing is money sent to or returned by external payment
+	// networks.
+	LedgerClearing Ledger = "clearing"
+	// LedgerImport is the other side of external transactions imported
+	// from files.
+	LedgerImport Ledger = "import"
+	// LedgerAdjustments is the other side of balances corrected by hand.
+	LedgerAdjustments Ledger = "adjustments"
+	// LedgerLoans is the principal lent to customers: disbursements debit
+	// it and repayments credit it, their interest going to LedgerInterest.
+	LedgerLoans Ledger = "loans"
+	// LedgerTermDeposits is the principal customers locked in term
+	// deposits: opening one credits it and paying it back debits it, the
+	// interest coming from LedgerInterest and penalties going to LedgerFees.
+	LedgerTermDeposits Ledger = "term_deposits"
+	// LedgerOpening balances the entries recorded before the ledger was
+	// double-entry.
+	LedgerOpening Ledger = "opening"
+)
+
+type JournalKind string
+
+const (
+	JournalDeposit    JournalKind = "deposit"
+	JournalWithdrawal JournalKind = "withdrawal"
+	JournalTransfer   JournalKind = "transfer"
+	JournalInterest   JournalKind = "interest"
+	JournalReversal   JournalKind = "reversal"
+	// JournalExternalTransfer entries book settled external transfers and
+	// JournalExternalReturn entries the ones returned after settling.
+	JournalExternalTransfer  JournalKind = "external_transfer"
+	JournalExternalReturn    JournalKind = "external_return"
+	JournalImport            JournalKind = "import"
+	JournalAdjustment        JournalKind = "adjustment"
+	JournalLoanDisbursement  JournalKind = "loan_disbursement"
+	JournalLoanRepayment     JournalKind = "loan_repayment"
+	JournalTermDeposit       JournalKind = "term_deposit"
+	JournalTermDepositPayout JournalKind = "term_deposit_payout"
+	// JournalOpening entries were recorded before the ledger was
+	// double-entry.
+	JournalOpening JournalKind = "opening"
+)
+
+// JournalLine is one side of a journal entry. Amount is signed like
+// Transaction.Amount: credits are positive and debits negative, so the lines
+// of a balanced entry sum to zero in every currency.
+type JournalLine struct {
+	ID            int    `json:"id"`
+	EntryID       int    `json:"entryId"`
+	Ledger        Ledger `json:"ledger"`
+	AccountNumber *int64 `json:"accountNumber,omitempty"`
+	Currency      string `json:"currency"`
+	Amount        int64  `json:"amount"`
+}
+
+// JournalEntry groups the lines of one money movement, e.g. a transfer and
+// the overdraft fee it triggered. The customer lines are mirrored by the
+// account's Transactions, which reference the entry.
+type JournalEntry struct {
+	ID        int            `json:"id"`
+	Kind      JournalKind    `json:"kind"`
+	Lines     []*JournalLine `json:"lines"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+func newJournalEntry(kind JournalKind, createdAt time.Time) *JournalEntry {
+	return &JournalEntry{Kind: kind, CreatedAt: createdAt}
+}
+
+// post adds a line to the entry. number is only set for customer lines.
+func (e *JournalEntry) post(ledger Ledger, number *int64, currency string, amount int64) {
+	e.Lines = append(e.Lines, &JournalLine{
+		EntryID:       e.ID,
+		Ledger:        ledger,
+		AccountNumber: number,
+		Currency:      currency,
+		Amount:        amount,
+	})
+}
+
+// accountNumbers returns the customer accounts the entry books to.
+func (e *JournalEntry) accountNumbers() []int64 {
+	numbers := []int64{}
+
+	for _, line := range e.Lines {
+		if line.AccountNumber != nil && !slices.Contains(numbers, *line.AccountNumber) {
+			numbers = append(numbers, *line.AccountNumber)
+		}
+	}
+
+	return numbers
+}
+
+func (e *JournalEntry) postCustomer(acc *Account, amount int64) {
+	number := acc.Number
+	e.post(LedgerCustomer, &number, acc.Currency, amount)
+}
+
+// postFXLines balances a cross-currency transfer: the fx book takes the
+// debited amount in the source currency and pays out the credited amount in
+// the destination currency.
+func postFXLines(entry *JournalEntry, transfer *Transfer) {
+	if transfer.Currency == transfer.ToCurrency {
+		return
+	}
+
+	entry.post(LedgerFX, nil, transfer.Currency, transfer.Amount)
+	entry.post(LedgerFX, nil, transfer.ToCurrency, -transfer.ToAmount)
+}
+
+// postFXReversalLines undoes the FX lines of a transfer moved back at its
+// original rate.
+func postFXReversalLines(entry *JournalEntry, transfer *Transfer) {
+	if transfer.Currency == transfer.ToCurrency {
+		return
+	}
+
+	entry.post(LedgerFX, nil, transfer.Currency, -transfer.Amount)
+	entry.post(LedgerFX, nil, transfer.ToCurrency, transfer.ToAmount)
+}
+
+// Validate checks that the lines sum to zero in every currency. An
+// unbalanced entry is a bug, so it is reported as an internal error.
+func (e *JournalEntry) Validate() error {
+	totals, err := sumLines(e.Lines)
+
+	if err != nil {
+		return fmt.Errorf("journal entry %d (%s): %w", e.ID, e.Kind, err)
+	}
+
+	for currency, total := range totals {
+		if total != 0 {
+			return fmt.Errorf("journal entry %d (%s) is off by %d %s", e.ID, e.Kind, total, currency)
+		}
+	}
+
+	return nil
+}
+
+func sumLines(lines []*JournalLine) (map[string]int64, error) {
+	totals := map[string]int64{}
+
+	for _, line := range lines {
+		total, ok := addMinorUnits(totals[line.Currency], line.Amount)
+
+		if !ok {
+			return nil, fmt.Errorf("the %s lines add up out of range", line.Currency)
+		}
+
+		totals[line.Currency] = total
+	}
+
+	return totals, nil
+}
+
+// LedgerMismatch is an account whose stored balance differs from the sum of
+// its journal lines.
+type LedgerMismatch struct {
+	AccountNumber int64 `json:"accountNumber"`
+	Balance       int64 `json:"balance"`
+	LedgerBalance int64 `json:"ledgerBalance"`
+}
+
+// LedgerIntegrityReport is the outcome of checking the books: every entry
+// must balance, so the totals per currency are zero, and every account
+// balance must match its journal lines.
+type LedgerIntegrityReport struct {
+	Balanced           bool             `json:"balanced"`
+	Entries            int              `json:"entries"`
+	Totals             map[string]int64 `json:"totals"`
+	UnbalancedEntries  []int            `json:"unbalancedEntries"`
+	MismatchedAccounts []LedgerMismatch `json:"mismatchedAccounts"`
+	CheckedAt          time.Time        `json:"checkedAt"`
+}
+
+// finish sorts the findings and sets Balanced.
+func (r *LedgerIntegrityReport) finish() *LedgerIntegrityReport {
+	if r.UnbalancedEntries == nil {
+		r.UnbalancedEntries = []int{}
+	}
+
+	if r.MismatchedAccounts == nil {
+		r.MismatchedAccounts = []LedgerMismatch{}
+	}
+
+	slices.Sort(r.UnbalancedEntries)
+	sort.Slice(r.MismatchedAccounts, func(i, j int) bool {
+		return r.MismatchedAccounts[i].AccountNumber < r.MismatchedAccounts[j].AccountNumber
+	})
+
+	r.Balanced = len(r.UnbalancedEntries) == 0 && len(r.MismatchedAccounts) == 0
+
+	for _, total := range r.Totals {
+		if total != 0 {
+			r.Balanced = false
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalEntryValidate(t *testing.T) {
+	number := int64(1)
+
+	entry := newJournalEntry(JournalDeposit, time.Now())
+	entry.post(LedgerCustomer, &number, "USD", 100)
+	entry.post(LedgerCash, nil, "USD", -100)
+	assert.Nil(t, entry.Validate())
+
+	entry.post(LedgerFX, nil, "EUR", 5)
+	assert.NotNil(t, entry.Validate())
+
+	entry = newJournalEntry(JournalDeposit, time.Now())
+	entry.post(LedgerCustomer, &number, "USD", math.MaxInt64)
+	entry.post(LedgerCash, nil, "USD", 1)
+	assert.ErrorContains(t, entry.Validate(), "out of range")
+}
+
+func TestMemoryStoreLedgerBalances(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rates, err := NewStaticRateProvider(defaultExchangeRates)
+	require.Nil(t, err)
+
+	usd := &Account{Number: 1, Currency: "USD", Status: AccountActive, AccountLimits: AccountLimits{OverdraftLimit: 500, OverdraftFee: 25}}
+	eur := &Account{Number: 2, Currency: "EUR", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, usd))
+	require.Nil(t, store.CreateAccount(ctx, eur))
+
+	_, err = store.Deposit(ctx, 1, 1000, 0)
+	require.Nil(t, err)
+
+	_, err = store.Withdraw(ctx, 1, 1100, 0)
+	require.Nil(t, err)
+
+	_, err = store.Deposit(ctx, 2, 1000, 0)
+	require.Nil(t, err)
+
+	transfer, err := newTransfer(ctx, store, rates, 2, 1, 500)
+	require.Nil(t, err)
+	require.Nil(t, store.Transfer(ctx, transfer))
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced, "%+v", report)
+	assert.Equal(t, 4, report.Entries)
+	assert.Equal(t, map[string]int64{"USD": 0, "EUR": 0}, report.Totals)
+
+	transactions, _ := store.GetTransactions(ctx, 1, 10, 0)
+	require.Len(t, transactions, 4)
+	assert.Equal(t, TransactionFee, transactions[1].Type)
+	assert.Equal(t, transactions[1].JournalID, transactions[2].JournalID, "the fee is part of the withdrawal's entry")
+
+	// a balance changed behind the ledger's back is reported
+	store.accounts[usd.ID].Balance += 1
+
+	report, err = store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.False(t, report.Balanced)
+	require.Len(t, report.MismatchedAccounts, 1)
+	assert.Equal(t, int64(1), report.MismatchedAccounts[0].AccountNumber)
+}
+
+func TestAPILedgerIntegrity(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/ledger/integrity", token, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/admin/ledger/integrity", api.login(admin, "admin-pw"), nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	report := new(LedgerIntegrityReport)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(report))
+	assert.True(t, report.Balanced)
+	assert.Equal(t, 1, report.Entries)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"time"
+)
+
+const (
+	loanInterval   = time.Hour
+	loanBatchSize  = 50
+	loanRetryDelay = 24 * time.Hour
+
+	maxLoanTermMonths = 360
+)
+
+// NewLoan is the loan req originates for acc on now and its amortization
+// schedule, the first installment due a month later. req must have passed
+// LoanRequest.Validate.
+func NewLoan(req *LoanRequest, acc *Account, now time.Time) (*Loan, []*LoanInstallment) {
+	rate, _ := new(big.Rat).SetString(req.AnnualRate)
+	payment, installments := amortize(req.Principal, rate, req.TermMonths, now.Truncate(24*time.Hour))
+	firstDue := installments[0].DueDate
+
+	loan := &Loan{
+		AccountNumber:  acc.Number,
+		Principal:      req.Principal,
+		Currency:       acc.Currency,
+		AnnualRate:     req.AnnualRate,
+		TermMonths:     req.TermMonths,
+		MonthlyPayment: payment,
+		Outstanding:    req.Principal,
+		Status:         LoanActive,
+		NextPaymentAt:  &firstDue,
+		CreatedAt:      now,
+	}
+
+	return loan, installments
+}
+
+// amortize splits a loan of principal at the annual rate into term equal
+// monthly payments, rounded up to the minor unit, each paying the month's
+// interest on the balance left and the rest off the principal. The last
+// payment only clears what is left, so it may be smaller.
+func amortize(principal int64, rate *big.Rat, term int, start time.Time) (int64, []*LoanInstallment) {
+	monthly := new(big.Rat).Quo(rate, big.NewRat(12, 1))
+	payment := ceilRat(new(big.Rat).Quo(big.NewRat(principal, 1), big.NewRat(int64(term), 1)))
+
+	if monthly.Sign() > 0 {
+		// principal * r / (1 - (1 + r)^-term)
+		growth := new(big.Rat).Add(big.NewRat(1, 1), monthly)
+		compound := big.NewRat(1, 1)
+
+		for i := 0; i < term; i++ {
+			compound.Mul(compound, growth)
+		}
+
+		v := new(big.Rat).Mul(big.NewRat(principal, 1), monthly)
+		v.Mul(v, compound)
+		payment = ceilRat(v.Quo(v, new(big.Rat).Sub(compound, big.NewRat(1, 1))))
+	}
+
+	installments := make([]*LoanInstallment, term)
+	balance := principal
+
+	for n := range installments {
+		interest := roundRat(new(big.Rat).Mul(big.NewRat(balance, 1), monthly))
+		principalPart := min(payment-interest, balance)
+
+		if n == term-1 {
+			principalPart = balance
+		}
+
+		balance -= principalPart
+
+		installments[n] = &LoanInstallment{
+			Number:    n + 1,
+			DueDate:   nextOccurrence(start, RecurrenceMonthly, n+1),
+			Payment:   principalPart + interest,
+			Principal: principalPart,
+			Interest:  interest,
+			Balance:   balance,
+			Status:    LoanInstallmentScheduled,
+		}
+	}
+
+	return payment, installments
+}
+
+// ceilRat rounds a non-negative v up to an integer.
+func ceilRat(v *big.Rat) int64 {
+	quo, rem := new(big.Int).QuoRem(v.Num(), v.Denom(), new(big.Int))
+
+	if rem.Sign() > 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+
+	return quo.Int64()
+}
+
+// roundRat rounds a non-negative v half up to an integer.
+func roundRat(v *big.Rat) int64 {
+	quo, rem := new(big.Int).QuoRem(v.Num(), v.Denom(), new(big.Int))
+
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+
+	return quo.Int64()
+}
+
+// canCollect reports whether installment can be debited from acc now.
+func canCollect(acc *Account, installment *LoanInstallment) bool {
+	return acc.CheckActive() == nil && acc.CanDebit(installment.Payment)
+}
+
+// settleLoan brings the loan's outstanding principal, arrears, status and
+// next collection up to date with its installments once the ones due by now
+// were collected or found overdue.
+func settleLoan(loan *Loan, installments []*LoanInstallment, now time.Time) {
+	loan.Outstanding = loan.Principal
+	loan.ArrearsAmount = 0
+	loan.NextPaymentAt = nil
+
+	for _, installment := range installments {
+		switch installment.Status {
+		case LoanInstallmentPaid:
+			loan.Outstanding = installment.Balance
+		case LoanInstallmentOverdue:
+			loan.ArrearsAmount += installment.Payment
+		case LoanInstallmentScheduled:
+			if loan.NextPaymentAt == nil {
+				due := installment.DueDate
+				loan.NextPaymentAt = &due
+			}
+		}
+	}
+
+	switch {
+	case loan.ArrearsAmount > 0:
+		retryAt := now.Add(loanRetryDelay)
+		loan.Status = LoanInArrears
+		loan.NextPaymentAt = &retryAt
+	case loan.NextPaymentAt == nil:
+		loan.Status = LoanPaidOff
+		loan.PaidOffAt = &now
+	default:
+		loan.Status = LoanActive
+	}
+}
+
+// LoanCollector debits the installments of loans from their accounts when
+// they fall due. Installments the account can't pay put the loan in arrears
+// and are retried every loanRetryDelay.
+type LoanCollector struct {
+	store  Storage
+	events EventPublisher
+}
+
+func NewLoanCollector(store Storage, events EventPublisher) *LoanCollector {
+	return &LoanCollector{store: store, events: events}
+}
+
+func (c *LoanCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(loanInterval)
+	defer ticker.Stop()
+
+	for {
+		c.collectDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *LoanCollector) collectDue(ctx context.Context, now time.Time) {
+	loans, err := c.store.GetLoansDue(ctx, now, loanBatchSize)
+
+	if err != nil {
+		slog.Error("loading loans due", "error", err)
+		return
+	}
+
+	for _, due := range loans {
+		loan, transactions, err := c.store.CollectLoanRepayments(ctx, due.ID, now)
+
+		if err != nil {
+			slog.Error("collecting loan repayments", "error", err, "loanId", due.ID)
+			continue
+		}
+
+		if loan.Status == LoanInArrears {
+			slog.Warn("loan in arrears", "loanId", loan.ID, "account", loan.AccountNumber, "arrears", loan.ArrearsAmount)
+		}
+
+		for _, transaction := range transactions {
+			c.events.Publish(ctx, &Event{Type: EventTransactionCreated, AccountNumber: loan.AccountNumber, Data: transaction})
+		}
+
+		if len(transactions) > 0 {
+			last := transactions[len(transactions)-1]
+			publishBalanceEvent(ctx, c.events, loan.AccountNumber, last.Balance, loan.Currency)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmortize(t *testing.T) {
+	start := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	payment, installments := amortize(1000000, big.NewRat(6, 100), 12, start)
+	require.Len(t, installments, 12)
+	assert.Equal(t, int64(86067), payment)
+	assert.Equal(t, int64(5000), installments[0].Interest, "a month of interest at 0.5% on the whole principal")
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), installments[0].DueDate)
+	assert.Equal(t, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), installments[1].DueDate)
+
+	var principal int64
+
+	for i, installment := range installments {
+		assert.Equal(t, i+1, installment.Number)
+		assert.Equal(t, installment.Principal+installment.Interest, installment.Payment)
+
+		if i < len(installments)-1 {
+			assert.Equal(t, payment, installment.Payment)
+		}
+
+		principal += installment.Principal
+	}
+
+	assert.Equal(t, int64(1000000), principal)
+	assert.Zero(t, installments[11].Balance)
+	assert.LessOrEqual(t, installments[11].Payment, payment)
+
+	payment, installments = amortize(1000, new(big.Rat), 3, start)
+	assert.Equal(t, int64(334), payment)
+	assert.Equal(t, []int64{334, 334, 332}, []int64{installments[0].Payment, installments[1].Payment, installments[2].Payment})
+	assert.Zero(t, installments[2].Interest)
+}
+
+func TestLoanRequestValidate(t *testing.T) {
+	assert.Nil(t, (&LoanRequest{AccountNumber: 42, Principal: 1000, AnnualRate: "0.0599", TermMonths: 12}).Validate())
+	assert.Nil(t, (&LoanRequest{AccountNumber: 42, Principal: 1000, AnnualRate: "0", TermMonths: maxLoanTermMonths}).Validate())
+
+	for _, req := range []*LoanRequest{
+		{Principal: 1000, AnnualRate: "0.05", TermMonths: 12},
+		{AccountNumber: 42, AnnualRate: "0.05", TermMonths: 12},
+		{AccountNumber: 42, Principal: 1000, AnnualRate: "5%", TermMonths: 12},
+		{AccountNumber: 42, Principal: 1000, AnnualRate: "1/20", TermMonths: 12},
+		{AccountNumber: 42, Principal: 1000, AnnualRate: "1.5", TermMonths: 12},
+		{AccountNumber: 42, Principal: 1000, AnnualRate: "0.05", TermMonths: 0},
+		{AccountNumber: 42, Principal: 1000, AnnualRate: "0.05", TermMonths: maxLoanTermMonths + 1},
+	} {
+		assert.NotNil(t, req.Validate(), req)
+	}
+}
+
+func TestMemoryStoreLoans(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+
+	acc := &Account{Number: 42, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	loan, installments := NewLoan(&LoanRequest{AccountNumber: 42, Principal: 120000, AnnualRate: "0", TermMonths: 12}, acc, now)
+	assert.Equal(t, int64(10000), loan.MonthlyPayment)
+
+	disbursement, err := store.CreateLoan(ctx, loan, installments)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionLoanDisbursement, disbursement.Type)
+	assert.Equal(t, int64(120000), disbursement.Balance)
+
+	due, err := store.GetLoansDue(ctx, now, 10)
+	require.Nil(t, err)
+	assert.Empty(t, due, "the first installment is due a month after origination")
+
+	firstDue := now.AddDate(0, 1, 0)
+
+	due, err = store.GetLoansDue(ctx, firstDue, 10)
+	require.Nil(t, err)
+	require.Len(t, due, 1)
+
+	collected, transactions, err := store.CollectLoanRepayments(ctx, loan.ID, firstDue)
+	require.Nil(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, int64(-10000), transactions[0].Amount)
+	assert.Equal(t, LoanActive, collected.Status)
+	assert.Equal(t, int64(110000), collected.Outstanding)
+	assert.Equal(t, now.Truncate(24*time.Hour).AddDate(0, 2, 0), *collected.NextPaymentAt)
+
+	_, err = store.Withdraw(ctx, 42, 105000, 0)
+	require.Nil(t, err)
+
+	// two installments are due, the account covers neither
+	collected, transactions, err = store.CollectLoanRepayments(ctx, loan.ID, now.AddDate(0, 3, 0))
+	require.Nil(t, err)
+	assert.Empty(t, transactions)
+	assert.Equal(t, LoanInArrears, collected.Status)
+	assert.Equal(t, int64(20000), collected.ArrearsAmount)
+	assert.Equal(t, now.AddDate(0, 3, 0).Add(loanRetryDelay), *collected.NextPaymentAt)
+
+	_, err = store.Deposit(ctx, 42, 10000, 0)
+	require.Nil(t, err)
+
+	collected, transactions, err = store.CollectLoanRepayments(ctx, loan.ID, now.AddDate(0, 3, 1))
+	require.Nil(t, err)
+	assert.Len(t, transactions, 1, "the oldest overdue installment is paid first")
+	assert.Equal(t, int64(10000), collected.ArrearsAmount)
+
+	schedule, err := store.GetLoanSchedule(ctx, loan.ID)
+	require.Nil(t, err)
+	require.Len(t, schedule, 12)
+	assert.Equal(t, LoanInstallmentPaid, schedule[1].Status)
+	assert.NotNil(t, schedule[1].TransactionID)
+	assert.Equal(t, LoanInstallmentOverdue, schedule[2].Status)
+	assert.Equal(t, LoanInstallmentScheduled, schedule[3].Status)
+
+	_, err = store.Deposit(ctx, 42, 200000, 0)
+	require.Nil(t, err)
+
+	collected, transactions, err = store.CollectLoanRepayments(ctx, loan.ID, now.AddDate(1, 0, 0))
+	require.Nil(t, err)
+	assert.Len(t, transactions, 10)
+	assert.Equal(t, LoanPaidOff, collected.Status)
+	assert.Zero(t, collected.Outstanding)
+	assert.Nil(t, collected.NextPaymentAt)
+
+	account, err := store.GetAccountByNumber(ctx, 42)
+	require.Nil(t, err)
+	assert.Equal(t, int64(120000-105000+10000+200000-120000), account.Balance)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestAPILoans(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	adminToken := api.login(admin, "admin-pw")
+	aliceToken := api.login(alice, "alice-pw")
+	bobToken := api.login(bob, "bob-pw")
+
+	req := LoanRequest{AccountNumber: alice.Number, Principal: 500000, AnnualRate: "0.0599", TermMonths: 24}
+
+	rec := api.do("POST", "/admin/loans", aliceToken, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/admin/loans", adminToken, LoanRequest{AccountNumber: alice.Number, Principal: 500000, AnnualRate: "6%", TermMonths: 24})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/loans", adminToken, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	loan := new(Loan)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(loan))
+	assert.Equal(t, LoanActive, loan.Status)
+	assert.Equal(t, "USD", loan.Currency)
+	path := "/loan/" + strconv.Itoa(loan.ID)
+
+	acc, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(500000), acc.Balance)
+
+	rec = api.do("GET", path+"/schedule", aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	schedule := []*LoanInstallment{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&schedule))
+	require.Len(t, schedule, 24)
+	assert.Equal(t, loan.MonthlyPayment, schedule[0].Payment)
+
+	rec = api.do("GET", path, bobToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "other holders can't see the loan")
+
+	rec = api.do("GET", path, adminToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/loans", aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	loans := []*Loan{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&loans))
+	assert.Len(t, loans, 1)
+}
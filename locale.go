@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Locale is how display amounts are written in a language and region.
+type Locale struct {
+	Tag     string
+	Decimal string
+	Group   string
+	// SymbolAfter writes the currency symbol after the amount, as in
+	// 12,34 €, rather than before it, as in €12.34.
+	SymbolAfter bool
+}
+
+// locales are the locales display amounts can be written for, by tag.
+var locales = map[string]*Locale{
+	"en-US": {Tag: "en-US", Decimal: ".", Group: ","},
+	"en-GB": {Tag: "en-GB", Decimal: ".", Group: ","},
+	"de-DE": {Tag: "de-DE", Decimal: ",", Group: ".", SymbolAfter: true},
+	"de-CH": {Tag: "de-CH", Decimal: ".", Group: "’"},
+	"fr-FR": {Tag: "fr-FR", Decimal: ",", Group: "\u202f", SymbolAfter: true},
+	"es-ES": {Tag: "es-ES", Decimal: ",", Group: ".", SymbolAfter: true},
+	"it-IT": {Tag: "it-IT", Decimal: ",", Group: ".", SymbolAfter: true},
+	"ja-JP": {Tag: "ja-JP", Decimal: ".", Group: ","},
+}
+
+// languageLocales is the locale of a language asked for without a region.
+var languageLocales = map[string]string{
+	"en": "en-US",
+	"de": "de-DE",
+	"fr": "fr-FR",
+	"es": "es-ES",
+	"it": "it-IT",
+	"ja": "ja-JP",
+}
+
+// findLocale returns the locale of a BCP 47 tag such as de-DE, ignoring
+// case, or that of its language when the region isn't supported. It
+// returns nil when the language isn't either.
+func findLocale(tag string) *Locale {
+	language, region, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	language = strings.ToLower(language)
+
+	if locale, ok := locales[language+"-"+strings.ToUpper(region)]; ok {
+		return locale
+	}
+
+	return locales[languageLocales[language]]
+}
+
+// requestLocale returns the locale display amounts are written in: that of
+// the locale query parameter, or else the first supported language of the
+// Accept-Language header. It is nil when neither asks for one, and no
+// display amounts are written then.
+func requestLocale(r *http.Request) (*Locale, error) {
+	if tag := r.URL.Query().Get("locale"); tag != "" {
+		locale := findLocale(tag)
+
+		if locale == nil {
+			return nil, badRequestError("unsupported locale %s", tag)
+		}
+
+		return locale, nil
+	}
+
+	for _, tag := range acceptedLanguages(r.Header.Get("Accept-Language")) {
+		if locale := findLocale(tag); locale != nil {
+			return locale, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// acceptedLanguages returns the language tags of an Accept-Language header,
+// most preferred first. Tags with a q of 0, and the * wildcard, are left
+// out.
+func acceptedLanguages(header string) []string {
+	type accepted struct {
+		tag string
+		q   float64
+	}
+
+	var languages []accepted
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		q := 1.0
+
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+
+			if err != nil {
+				continue
+			}
+
+			q = parsed
+		}
+
+		if tag != "" && tag != "*" && q > 0 {
+			languages = append(languages, accepted{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].q > languages[j].q })
+
+	tags := make([]string, len(languages))
+
+	for i, language := range languages {
+		tags[i] = language.tag
+	}
+
+	return tags
+}
+
+// setLocaleHeaders tells caches the response depends on Accept-Language
+// and names the locale it was written in, if any.
+func setLocaleHeaders(w http.ResponseWriter, locale *Locale) {
+	w.Header().Add("Vary", "Accept-Language")
+
+	if locale != nil {
+		w.Header().Set("Content-Language", locale.Tag)
+	}
+}
+
+// FormatAmount writes minor units of currency as the locale does, with the
+// currency's symbol, e.g. -1234567 EUR as -12.345,67 € in de-DE. Symbols
+// are set apart from the digits by a no-break space, so the two are never
+// wrapped onto different lines.
+func (l *Locale) FormatAmount(amount int64, currency string) string {
+	info, ok := currencies[currency]
+
+	if !ok {
+		info = CurrencyInfo{Symbol: currency}
+	}
+
+	sign := ""
+	magnitude := uint64(amount)
+
+	if amount < 0 {
+		sign = "-"
+		magnitude = uint64(-amount)
+	}
+
+	digits := strconv.FormatUint(magnitude, 10)
+
+	if len(digits) <= info.Decimals {
+		digits = strings.Repeat("0", info.Decimals-len(digits)+1) + digits
+	}
+
+	whole, fraction := digits[:len(digits)-info.Decimals], digits[len(digits)-info.Decimals:]
+	number := groupDigits(whole, l.Group)
+
+	if fraction != "" {
+		number += l.Decimal + fraction
+	}
+
+	if l.SymbolAfter {
+		return sign + number + "\u00a0" + info.Symbol
+	}
+
+	// a code used as the symbol, such as CHF, is set apart from the digits
+	if last, _ := utf8.DecodeLastRuneInString(info.Symbol); unicode.IsLetter(last) {
+		return sign + info.Symbol + "\u00a0" + number
+	}
+
+	return sign + info.Symbol + number
+}
+
+// groupDigits separates every three digits of whole, from the right, with
+// separator.
+func groupDigits(whole, separator string) string {
+	var b strings.Builder
+
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(separator)
+		}
+
+		b.WriteRune(digit)
+	}
+
+	return b.String()
+}
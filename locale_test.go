@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocaleFormatAmount(t *testing.T) {
+	assert.Equal(t, "$1,234,567.89", locales["en-US"].FormatAmount(123456789, "USD"))
+	assert.Equal(t, "$0.05", locales["en-US"].FormatAmount(5, "USD"))
+	assert.Equal(t, "-£1.50", locales["en-GB"].FormatAmount(-150, "GBP"))
+	assert.Equal(t, "-12.345,67\u00a0€", locales["de-DE"].FormatAmount(-1234567, "EUR"))
+	assert.Equal(t, "1\u202f234,00\u00a0€", locales["fr-FR"].FormatAmount(123400, "EUR"))
+	assert.Equal(t, "CHF\u00a01’000.00", locales["de-CH"].FormatAmount(100000, "CHF"))
+	assert.Equal(t, "¥1,500", locales["ja-JP"].FormatAmount(1500, "JPY"), "yen have no minor units")
+	assert.Equal(t, "0\u00a0¥", locales["de-DE"].FormatAmount(0, "JPY"))
+}
+
+func TestFindLocale(t *testing.T) {
+	assert.Equal(t, "de-CH", findLocale("de-ch").Tag)
+	assert.Equal(t, "de-DE", findLocale("de").Tag)
+	assert.Equal(t, "fr-FR", findLocale("fr-CA").Tag, "an unsupported region falls back to the language")
+	assert.Nil(t, findLocale("pt-BR"))
+	assert.Nil(t, findLocale(""))
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	assert.Equal(t, []string{"fr-CH", "de", "en"}, acceptedLanguages("en;q=0.5, fr-CH, de;q=0.9, *;q=0.1"))
+	assert.Equal(t, []string{"de"}, acceptedLanguages("en;q=0, de"))
+	assert.Empty(t, acceptedLanguages(""))
+}
+
+func TestAPILocale(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 123456})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path+"/statement", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), `"display"`, "amounts are only written for a locale")
+	assert.Empty(t, rec.Header().Get("Content-Language"))
+
+	rec = api.do("GET", path+"/statement?locale=de-de", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "de-DE", rec.Header().Get("Content-Language"))
+
+	statement := new(Statement)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(statement))
+	assert.Equal(t, "1.234,56\u00a0$", statement.Display["closingBalance"])
+	require.Len(t, statement.Transactions, 1)
+	assert.Equal(t, "1.234,56\u00a0$", statement.Transactions[0].Display["amount"])
+
+	rec = api.do("GET", path+"/statement?locale=xx", token, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// without a locale parameter, the first supported language is used
+	req := httptest.NewRequest("GET", path+"/analytics", nil)
+	req.Header.Set("x-jwt-token", token)
+	req.Header.Set("Accept-Language", "pt-BR, fr;q=0.8, en;q=0.5")
+	rec = httptest.NewRecorder()
+	api.handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "fr-FR", rec.Header().Get("Content-Language"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Accept-Language")
+
+	analytics := new(SpendingAnalytics)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(analytics))
+	require.Len(t, analytics.Categories, 1)
+	assert.Equal(t, "1\u202f234,56\u00a0$", analytics.Categories[0].Display["totalCredits"])
+	assert.Equal(t, "0,00\u00a0$", analytics.Categories[0].Months[0].Display["debits"])
+}
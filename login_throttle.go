@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LoginPolicy slows down password guessing: every failed login of an
+// account, or from an IP, doubles the wait before the next attempt starting
+// at Backoff, and MaxFailures failures in a row lock it out for Lockout.
+// Failures are forgotten Lockout after the last one.
+type LoginPolicy struct {
+	MaxFailures int
+	Backoff     time.Duration
+	Lockout     time.Duration
+}
+
+func newLoginPolicy(cfg *Config) LoginPolicy {
+	return LoginPolicy{MaxFailures: cfg.LoginMaxFailures, Backoff: cfg.LoginBackoff, Lockout: cfg.LoginLockout}
+}
+
+// Enabled reports whether failed logins are counted at all.
+func (p LoginPolicy) Enabled() bool {
+	return p.MaxFailures > 0
+}
+
+// LoginThrottle counts the failed logins in a row of an account, a business
+// user or an IP, told apart by the prefix of Key.
+type LoginThrottle struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil"`
+}
+
+func accountLoginKey(number int64) string {
+	return "account:" + strconv.FormatInt(number, 10)
+}
+
+// userLoginKey is the key of the business user of account number who logs
+// in as username. It is keyed by the login name rather than the user's id,
+// so names that don't exist are throttled too.
+func userLoginKey(number int64, username string) string {
+	return "user:" + strconv.FormatInt(number, 10) + ":" + username
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginKey is the key of the login of number's holder, or of its business
+// user username if it isn't empty. The failures of one don't lock the other
+// out.
+func loginKey(number int64, username string) string {
+	if username != "" {
+		return userLoginKey(number, username)
+	}
+
+	return accountLoginKey(number)
+}
+
+// loginThrottleKeys are the keys a login of number, as username if it isn't
+// empty, from ip is counted against.
+func loginThrottleKeys(number int64, username, ip string) []string {
+	keys := []string{loginKey(number, username)}
+
+	if ip != "" {
+		keys = append(keys, ipLoginKey(ip))
+	}
+
+	return keys
+}
+
+// Locked reports whether t is locked out at now.
+func (t *LoginThrottle) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+// RetryAt is the earliest time another login may be attempted, which may
+// be in the past.
+func (t *LoginThrottle) RetryAt(policy LoginPolicy) time.Time {
+	if t.LockedUntil != nil {
+		return *t.LockedUntil
+	}
+
+	if t.Failures == 0 {
+		return time.Time{}
+	}
+
+	backoff := policy.Lockout
+
+	// past 2^30 times the backoff the wait is longer than any lockout
+	if shift := t.Failures - 1; shift < 31 && policy.Backoff <= policy.Lockout>>shift {
+		backoff = policy.Backoff << shift
+	}
+
+	return t.LastFailureAt.Add(backoff)
+}
+
+// Fail counts a failed login at now, starting over if the last lockout has
+// expired or the last failure is older than the lockout, and locks t out
+// once it reaches the policy's maximum.
+func (t *LoginThrottle) Fail(policy LoginPolicy, now time.Time) {
+	expired := t.LockedUntil != nil && !t.Locked(now)
+
+	if expired || now.Sub(t.LastFailureAt) >= policy.Lockout {
+		t.Failures = 0
+		t.LockedUntil = nil
+	}
+
+	t.Failures++
+	t.LastFailureAt = now
+
+	if t.Failures >= policy.MaxFailures && t.LockedUntil == nil {
+		lockedUntil := now.Add(policy.Lockout)
+		t.LockedUntil = &lockedUntil
+	}
+}
+
+// LoginLockedData is the payload of login.locked events.
+type LoginLockedData struct {
+	IP          string    `json:"ip"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+// checkLoginThrottle refuses a login of number, as username if it isn't
+// empty, from ip while the login or the IP is locked out or still waiting
+// out the backoff of its last failure.
+func checkLoginThrottle(ctx context.Context, store LoginThrottleRepository, policy LoginPolicy, number int64, username, ip string, now time.Time) error {
+	if !policy.Enabled() {
+		return nil
+	}
+
+	throttles, err := store.GetLoginThrottles(ctx, loginThrottleKeys(number, username, ip))
+
+	if err != nil {
+		return err
+	}
+
+	var retryAt time.Time
+	locked := false
+
+	for _, throttle := range throttles {
+		if at := throttle.RetryAt(policy); at.After(now) && at.After(retryAt) {
+			retryAt = at
+		}
+
+		locked = locked || throttle.Locked(now)
+	}
+
+	if retryAt.IsZero() {
+		return nil
+	}
+
+	code := ErrorCodeRateLimited
+
+	if locked {
+		code = ErrorCodeLoginLocked
+	}
+
+	wait := retryAt.Sub(now).Round(time.Second)
+	httpErr := newHTTPError(http.StatusTooManyRequests, code, "too many failed logins, try again in %s", max(wait, time.Second))
+	httpErr.RetryAfter = retryAt.Sub(now)
+
+	return httpErr
+}
+
+// recordLoginFailure counts a failed login of number, as username if it
+// isn't empty, from ip, unless err only asks for the two-factor code. Locking the account or the IP out is
+// audited, and an account lockout published as login.locked so the holder
+// hears about it. A failure is logged, the login has failed anyway.
+func recordLoginFailure(ctx context.Context, store Storage, events EventPublisher, policy LoginPolicy, number int64, username, ip string, now time.Time, err error) {
+	if httpErr, ok := asHTTPError(err); !policy.Enabled() || (ok && httpErr.Code == ErrorCodeTOTPRequired) {
+		return
+	}
+
+	for _, key := range loginThrottleKeys(number, username, ip) {
+		throttle, err := store.RecordLoginFailure(ctx, key, policy, now)
+
+		if err != nil {
+			slog.ErrorContext(ctx, "recording login failure", "key", key, "error", err)
+			continue
+		}
+
+		// later failures of a racing login land on an existing lockout
+		if throttle.LockedUntil == nil || throttle.Failures != policy.MaxFailures {
+			continue
+		}
+
+		recordAudit(ctx, store, auditEntry(AuditLoginLocked, number, nil, ip, nil, throttle))
+
+		if key == accountLoginKey(number) {
+			events.Publish(ctx, &Event{Type: EventLoginLocked, AccountNumber: number, Data: LoginLockedData{IP: ip, Failures: throttle.Failures, LockedUntil: *throttle.LockedUntil}})
+		}
+	}
+}
+
+// clearLoginFailures forgets the failed logins of number's holder, or of its
+// business user username, once they have logged in. The failures of the IP
+// are kept, it may be guessing the passwords of other accounts.
+func clearLoginFailures(ctx context.Context, store LoginThrottleRepository, policy LoginPolicy, number int64, username string) {
+	if !policy.Enabled() {
+		return
+	}
+
+	if _, err := store.ClearLoginThrottle(ctx, loginKey(number, username)); err != nil && !isNotFound(err) {
+		slog.ErrorContext(ctx, "clearing login failures", "key", loginKey(number, username), "error", err)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	policy := LoginPolicy{MaxFailures: 3, Backoff: time.Second, Lockout: time.Minute}
+	now := time.Now().UTC()
+	throttle := &LoginThrottle{Key: accountLoginKey(42)}
+
+	throttle.Fail(policy, now)
+	assert.Equal(t, now.Add(time.Second), throttle.RetryAt(policy))
+
+	throttle.Fail(policy, now.Add(time.Second))
+	assert.Equal(t, now.Add(3*time.Second), throttle.RetryAt(policy), "the backoff doubles")
+	assert.False(t, throttle.Locked(now.Add(time.Second)))
+
+	throttle.Fail(policy, now.Add(3*time.Second))
+	require.NotNil(t, throttle.LockedUntil)
+	assert.True(t, throttle.Locked(now.Add(time.Minute)))
+	assert.Equal(t, now.Add(3*time.Second+time.Minute), throttle.RetryAt(policy))
+
+	throttle.Fail(policy, now.Add(2*time.Minute))
+	assert.Equal(t, 1, throttle.Failures, "an expired lockout starts over")
+	assert.Nil(t, throttle.LockedUntil)
+
+	throttle.Fail(policy, now.Add(4*time.Minute))
+	assert.Equal(t, 1, throttle.Failures, "old failures are forgotten")
+
+	throttle.Failures = 40
+	assert.Equal(t, now.Add(5*time.Minute), throttle.RetryAt(policy), "the backoff never exceeds the lockout")
+}
+
+func TestMemoryStoreLoginThrottles(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	policy := LoginPolicy{MaxFailures: 2, Lockout: time.Minute}
+	now := time.Now().UTC()
+
+	for i := 1; i <= 2; i++ {
+		throttle, err := store.RecordLoginFailure(ctx, accountLoginKey(42), policy, now)
+		require.Nil(t, err)
+		assert.Equal(t, i, throttle.Failures)
+	}
+
+	throttles, err := store.GetLoginThrottles(ctx, loginThrottleKeys(42, "", "10.0.0.1"))
+	require.Nil(t, err)
+	require.Len(t, throttles, 1)
+	assert.True(t, throttles[0].Locked(now))
+
+	cleared, err := store.ClearLoginThrottle(ctx, accountLoginKey(42))
+	require.Nil(t, err)
+	assert.Equal(t, 2, cleared.Failures)
+
+	_, err = store.ClearLoginThrottle(ctx, accountLoginKey(42))
+	assert.True(t, isNotFound(err))
+}
+
+func TestAPILoginLockout(t *testing.T) {
+	cfg := testConfig()
+	cfg.LoginMaxFailures = 3
+	cfg.LoginBackoff = 0
+	api := newTestAPIWithConfig(t, cfg)
+
+	alice := api.createAccount("Alice", "alice-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	adminToken := api.login(admin, "admin-pw")
+
+	loginFrom := func(ip, password string) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(LoginRequest{Number: alice.Number, Password: password})
+		require.Nil(t, err)
+
+		req := httptest.NewRequest("POST", "/login", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := loginFrom("10.0.0.1", "guess")
+		require.Equal(t, http.StatusUnauthorized, rec.Code, rec.Body.String())
+	}
+
+	rec := loginFrom("10.0.0.2", "alice-pw")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code, "the account is locked from everywhere")
+	assert.Contains(t, rec.Body.String(), `"code":"login_locked"`)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+
+	locked := 0
+
+	for _, entry := range entries {
+		if entry.Action == AuditLoginLocked {
+			assert.Equal(t, alice.Number, entry.AccountNumber)
+			assert.Equal(t, "10.0.0.1", entry.IP)
+			locked++
+		}
+	}
+
+	assert.Equal(t, 2, locked, "both the account and the IP are locked")
+
+	rec = api.do("POST", fmt.Sprintf("/admin/account/%d/unlock", alice.ID), adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", fmt.Sprintf("/admin/account/%d/unlock", alice.ID), adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = loginFrom("10.0.0.1", "alice-pw")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "the IP stays locked")
+
+	rec = loginFrom("10.0.0.2", "alice-pw")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestAPILoginBackoff(t *testing.T) {
+	cfg := testConfig()
+	cfg.LoginMaxFailures = 5
+	cfg.LoginBackoff = time.Minute
+	api := newTestAPIWithConfig(t, cfg)
+
+	alice := api.createAccount("Alice", "alice-pw")
+
+	rec := api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "guess"})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "alice-pw"})
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"rate_limited"`)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+}
+
+func TestAPIBusinessUserLoginLockout(t *testing.T) {
+	cfg := testConfig()
+	cfg.LoginMaxFailures = 3
+	cfg.LoginBackoff = 0
+	api := newTestAPIWithConfig(t, cfg)
+
+	acme := api.createAccount("Acme", "acme-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	adminToken := api.login(admin, "admin-pw")
+
+	for _, username := range []string{"ian", "ann"} {
+		user, err := NewBusinessUser(acme.Number, &BusinessUserRequest{Username: username, Password: username + "-pw", Role: BusinessUserViewer}, time.Now().UTC())
+		require.Nil(t, err)
+		require.Nil(t, api.store.CreateBusinessUser(context.Background(), user))
+	}
+
+	loginFrom := func(ip string, req LoginRequest) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(req)
+		require.Nil(t, err)
+
+		httpReq := httptest.NewRequest("POST", "/login", bytes.NewReader(payload))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.RemoteAddr = ip + ":1234"
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, httpReq)
+
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := loginFrom("10.0.0.1", LoginRequest{Number: acme.Number, Username: "ian", Password: "guess"})
+		require.Equal(t, http.StatusUnauthorized, rec.Code, rec.Body.String())
+	}
+
+	rec := loginFrom("10.0.0.2", LoginRequest{Number: acme.Number, Username: "ian", Password: "ian-pw"})
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "the user is locked out")
+
+	rec = loginFrom("10.0.0.2", LoginRequest{Number: acme.Number, Password: "acme-pw"})
+	assert.Equal(t, http.StatusOK, rec.Code, "the holder is not: %s", rec.Body.String())
+
+	rec = loginFrom("10.0.0.2", LoginRequest{Number: acme.Number, Username: "ann", Password: "ann-pw"})
+	assert.Equal(t, http.StatusOK, rec.Code, "nor are the other users: %s", rec.Body.String())
+
+	rec = api.do("POST", fmt.Sprintf("/admin/account/%d/unlock?username=ian", acme.ID), adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = loginFrom("10.0.0.2", LoginRequest{Number: acme.Number, Username: "ian", Password: "ian-pw"})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
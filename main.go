@@ -1,48 +1,221 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
 )
 
-func seedAccount(store Storage, firstName, lastName, password string) *Account {
+// runMigrate implements `go-bank migrate up` and `go-bank migrate down [steps]`.
+func runMigrate(ctx context.Context, store *PostgresStore, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: go-bank migrate up|down [steps]")
+	}
 
-	acc, err := NewAccount(firstName, lastName, password)
+	switch args[0] {
+	case "up":
+		return store.MigrateUp(ctx)
+	case "down":
+		steps := 1
 
-	if err != nil {
-		log.Fatal(err)
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %s", args[1])
+			}
+
+			steps = n
+		}
+
+		return store.MigrateDown(ctx, steps)
 	}
 
-	if err := store.CreateAccount(acc); err != nil {
-		log.Fatal(err)
+	return fmt.Errorf("unknown migrate command %s", args[0])
+}
+
+// openStore returns the configured storage backend, ready for use, and a
+// function that releases it.
+func openStore(ctx context.Context, cfg *Config) (Storage, func() error, error) {
+	switch cfg.Store {
+	case "memory":
+		slog.Warn("using the in-memory store, data is lost on exit")
+		return NewMemoryStore(), func() error { return nil }, nil
+	case "postgres":
+		store, err := NewPostgresStore(ctx, cfg)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := store.Init(ctx); err != nil {
+			store.Close()
+			return nil, nil, err
+		}
+
+		return store, store.Close, nil
+	case "sqlite":
+		store, err := NewSQLiteStore(ctx, cfg)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := store.Init(ctx); err != nil {
+			store.Close()
+			return nil, nil, err
+		}
+
+		return store, store.Close, nil
 	}
 
-	return acc
+	return nil, nil, fmt.Errorf("unknown store %s", cfg.Store)
 }
 
-func seedAccounts(s Storage) {
-	seedAccount(s, "Papu", "Papu 2", "lerion")
+// newRateLimiter returns the configured limiter, or nil when rate limiting is
+// disabled.
+func newRateLimiter(cfg *Config) RateLimiter {
+	if cfg.RateLimit <= 0 {
+		return nil
+	}
+
+	if cfg.RedisAddr != "" {
+		return NewRedisRateLimiter(redis.NewClient(&redis.Options{Addr: cfg.RedisAddr}), cfg.RateLimit, cfg.RateBurst)
+	}
+
+	return NewMemoryRateLimiter(cfg.RateLimit, cfg.RateBurst)
 }
 
-func main() {
-	seed := flag.Bool("seed", false, "seed the db")
-	store, err := NewPostgresStore()
+// newAccountCache returns the configured account cache, or nil when account
+// reads are not cached.
+func newAccountCache(cfg *Config) AccountCache {
+	switch cfg.AccountCache {
+	case "memory":
+		return NewMemoryAccountCache(cfg.AccountCacheSize, cfg.AccountCacheTTL)
+	case "redis":
+		return NewRedisAccountCache(redis.NewClient(&redis.Options{Addr: cfg.RedisAddr}), cfg.AccountCacheTTL)
+	}
+
+	return nil
+}
+
+// runServer serves the JSON and gRPC APIs and runs the background workers
+// on store until SIGINT or SIGTERM.
+func runServer(cfg *Config, store Storage) error {
+	rates, err := NewStaticRateProvider(defaultExchangeRates)
 
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	if err := store.Init(); err != nil {
-		log.Fatal(err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	webhooks := NewWebhookDispatcher(store)
+	bus := NewEventBus()
+	pots := NewPotSweeper(store)
+	notifications := NewNotificationDispatcher(store, newChannelNotifiers(cfg))
+	aml := NewAMLMonitor(cfg, store)
+	events := publishers{webhooks, bus, pots, notifications, aml}
+
+	var workers sync.WaitGroup
+	workers.Add(13)
+
+	go func() {
+		defer workers.Done()
+		NewScheduler(store, rates, events).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewStandingOrderRunner(cfg, store, rates, events).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		webhooks.Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewInterestAccruer(store, cfg.SavingsRate()).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewHoldReaper(store).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewClearingWorker(store, cfg.ExternalSettlementDelay).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewClosingBalanceMaterializer(store).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewReconciler(cfg, store).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		pots.Run(ctx)
+	}()
 
-	if *seed {
-		fmt.Println("seeding the database")
-		seedAccounts(store)
+	go func() {
+		defer workers.Done()
+		notifications.Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewLoanCollector(store, events).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewTermDepositMaturer(store, events).Run(ctx)
+	}()
+
+	go func() {
+		defer workers.Done()
+		NewOutboxRelay(store, NewMessageBroker(cfg)).Run(ctx)
+	}()
+
+	if cfg.GRPCAddr != "" {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+
+			if err := NewGRPCServer(cfg, store, rates, events).Run(ctx); err != nil {
+				slog.Error("gRPC server stopped", "error", err)
+			}
+		}()
 	}
 
-	server := NewAPIServer(":3000", store)
-	server.Run()
+	server := NewAPIServer(cfg, store, rates, events, bus, newRateLimiter(cfg))
+	err = server.Run(ctx)
 
+	stop()
+	workers.Wait()
+
+	return err
+}
+
+func main() {
+	if err := newCLI().rootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/hmuir28/go-bank/bankpb"
+	"google.golang.org/grpc"
+)
+
+const (
+	// maintenanceRefreshInterval is how soon the other instances follow an
+	// admin switching the mode.
+	maintenanceRefreshInterval = 5 * time.Second
+	defaultMaintenanceRetry    = 5 * time.Minute
+	maxMaintenanceRetry        = 24 * time.Hour
+
+	maxMaintenanceMessageLength = 500
+)
+
+// MaintenanceMode is how much of the API is served while the bank is being
+// worked on. Health checks and logins are served in every mode.
+type MaintenanceMode string
+
+const (
+	MaintenanceOff MaintenanceMode = "off"
+	// MaintenanceReadOnly refuses every change, admins' included, but
+	// serves reads.
+	MaintenanceReadOnly MaintenanceMode = "read_only"
+	// MaintenanceOn refuses every request but the admins'.
+	MaintenanceOn MaintenanceMode = "maintenance"
+)
+
+// MaintenanceState is the mode the API is in, as last set by an admin.
+type MaintenanceState struct {
+	Mode MaintenanceMode `json:"mode"`
+	// Message is shown to the clients that are refused.
+	Message string `json:"message,omitempty"`
+	// RetryAfter is the seconds refused clients are told to wait.
+	RetryAfter int       `json:"retryAfter"`
+	UpdatedBy  *int64    `json:"updatedBy,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// MaintenanceRequest switches the maintenance mode; RetryAfter defaults to
+// five minutes.
+type MaintenanceRequest struct {
+	Mode       MaintenanceMode `json:"mode"`
+	Message    string          `json:"message"`
+	RetryAfter int             `json:"retryAfter"`
+}
+
+// refusal is the 503 returned to the requests the state refuses.
+func (s *MaintenanceState) refusal() error {
+	message := s.Message
+
+	if message == "" && s.Mode == MaintenanceReadOnly {
+		message = "the bank is read-only for maintenance"
+	} else if message == "" {
+		message = "the bank is under maintenance"
+	}
+
+	err := newHTTPError(http.StatusServiceUnavailable, ErrorCodeMaintenance, "%s", message)
+	err.RetryAfter = time.Duration(s.RetryAfter) * time.Second
+
+	return err
+}
+
+// MaintenanceSwitch keeps the stored maintenance state at hand, so checking
+// it costs no query. Every instance reloads it every few seconds.
+type MaintenanceSwitch struct {
+	store Storage
+	state atomic.Pointer[MaintenanceState]
+}
+
+func NewMaintenanceSwitch(store Storage) *MaintenanceSwitch {
+	return &MaintenanceSwitch{store: store}
+}
+
+// State returns the current state, off until one was loaded or set.
+func (m *MaintenanceSwitch) State() *MaintenanceState {
+	if state := m.state.Load(); state != nil {
+		return state
+	}
+
+	return &MaintenanceState{Mode: MaintenanceOff}
+}
+
+// Set stores state and switches to it.
+func (m *MaintenanceSwitch) Set(ctx context.Context, state *MaintenanceState) error {
+	if err := m.store.SetMaintenanceState(ctx, state); err != nil {
+		return err
+	}
+
+	m.state.Store(state)
+
+	return nil
+}
+
+// Load switches to the stored state. A failure keeps the current one.
+func (m *MaintenanceSwitch) Load(ctx context.Context) error {
+	state, err := m.store.GetMaintenanceState(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	m.state.Store(state)
+
+	return nil
+}
+
+func (m *MaintenanceSwitch) Run(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.Load(ctx); err != nil {
+			slog.Error("loading the maintenance mode", "error", err)
+		}
+	}
+}
+
+// isReadMethod tells the requests that change nothing.
+func isReadMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// withMaintenance refuses every request in maintenance mode.
+func withMaintenance(m *MaintenanceSwitch) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if state := m.State(); state.Mode == MaintenanceOn {
+				writeError(w, r, state.refusal())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withReadOnly refuses the requests that change anything while the API is
+// read-only.
+func withReadOnly(m *MaintenanceSwitch) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if state := m.State(); state.Mode == MaintenanceReadOnly && !isReadMethod(r.Method) {
+				writeError(w, r, state.refusal())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// grpcReadMethods change nothing, so they are served in read-only mode.
+var grpcReadMethods = map[string]bool{
+	bankpb.Bank_GetAccount_FullMethodName:       true,
+	bankpb.Bank_ListTransactions_FullMethodName: true,
+}
+
+// grpcMaintenance refuses calls like the JSON API refuses customer
+// requests: all but logins in maintenance mode, and those that change
+// anything in read-only mode.
+func grpcMaintenance(m *MaintenanceSwitch) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		state := m.State()
+
+		if info.FullMethod == bankpb.Bank_Login_FullMethodName || state.Mode == MaintenanceOff {
+			return handler(ctx, req)
+		}
+
+		if state.Mode == MaintenanceOn || !grpcReadMethods[info.FullMethod] {
+			return nil, state.refusal()
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/hmuir28/go-bank/bankpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestAPIMaintenanceMode(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	aliceToken := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+
+	aliceAccount := "/account/" + strconv.Itoa(alice.ID)
+	deposit := AmountRequest{Amount: 100}
+
+	switchTo := func(mode MaintenanceMode) {
+		rec := api.do("PUT", "/admin/maintenance", adminToken, MaintenanceRequest{Mode: mode, Message: "Upgrading", RetryAfter: 60})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	rec := api.do("PUT", "/admin/maintenance", aliceToken, MaintenanceRequest{Mode: MaintenanceOn})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("PUT", "/admin/maintenance", adminToken, MaintenanceRequest{Mode: "closed"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "the mode is checked against the OpenAPI document")
+
+	switchTo(MaintenanceReadOnly)
+
+	rec = api.do("POST", "/admin"+aliceAccount+"/deposit", api.tellerToken(), deposit)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"code":"maintenance"`)
+	assert.Contains(t, rec.Body.String(), "Upgrading")
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	rec = api.do("GET", aliceAccount, aliceToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, "reads keep working")
+
+	api.login(alice, "alice-pw")
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/freeze", adminToken, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "admins can't change anything either")
+
+	switchTo(MaintenanceOn)
+
+	rec = api.do("GET", aliceAccount, aliceToken, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = api.do("GET", "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = api.do("GET", "/admin/account/"+strconv.Itoa(alice.ID)+"/limits", adminToken, nil)
+	assert.NotEqual(t, http.StatusServiceUnavailable, rec.Code, "admins keep working")
+
+	rec = api.do("GET", "/admin/maintenance", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	state := new(MaintenanceState)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(state))
+	assert.Equal(t, MaintenanceOn, state.Mode)
+	assert.Equal(t, &admin.Number, state.UpdatedBy)
+
+	restarted := NewMaintenanceSwitch(api.store)
+	require.Nil(t, restarted.Load(context.Background()))
+	assert.Equal(t, MaintenanceOn, restarted.State().Mode, "the mode survives restarts")
+
+	switchTo(MaintenanceOff)
+
+	rec = api.do("POST", "/admin"+aliceAccount+"/deposit", api.tellerToken(), deposit)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+
+	switches := 0
+
+	for _, entry := range entries {
+		if entry.Action == AuditMaintenanceChanged {
+			switches++
+		}
+	}
+
+	assert.Equal(t, 3, switches)
+}
+
+func TestGRPCMaintenance(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	maintenance := NewMaintenanceSwitch(store)
+	intercept := grpcMaintenance(maintenance)
+
+	call := func(method string) error {
+		_, err := intercept(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+
+		return err
+	}
+
+	require.Nil(t, maintenance.Set(ctx, &MaintenanceState{Mode: MaintenanceReadOnly, RetryAfter: 60}))
+	assert.Nil(t, call(bankpb.Bank_GetAccount_FullMethodName))
+	assert.Nil(t, call(bankpb.Bank_Login_FullMethodName))
+	assert.ErrorContains(t, call(bankpb.Bank_Deposit_FullMethodName), "read-only")
+
+	require.Nil(t, maintenance.Set(ctx, &MaintenanceState{Mode: MaintenanceOn, RetryAfter: 60}))
+	assert.Nil(t, call(bankpb.Bank_Login_FullMethodName))
+	assert.ErrorContains(t, call(bankpb.Bank_GetAccount_FullMethodName), "under maintenance")
+}
@@ -0,0 +1,1143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bank_http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	httpPanicsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_http_panics_total",
+		Help: "HTTP handlers that panicked, by method and route.",
+	}, []string{"method", "route"})
+
+	storeQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bank_store_query_duration_seconds",
+		Help:    "Time taken by storage operations, by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	transfersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_transfers_total",
+		Help: "Completed transfers, by source currency.",
+	}, []string{"currency"})
+
+	transferAmountTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_transfer_amount_total",
+		Help: "Amount moved by completed transfers in minor units, by source currency.",
+	}, []string{"currency"})
+
+	transferFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_transfer_failures_total",
+		Help: "Transfers rejected by the store, by error code.",
+	}, []string{"reason"})
+
+	outboxPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_outbox_published_total",
+		Help: "Outbox messages published to the message broker, by topic.",
+	}, []string{"topic"})
+
+	outboxPublishFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_outbox_publish_failures_total",
+		Help: "Failed attempts to publish outbox messages, by topic.",
+	}, []string{"topic"})
+
+	storeSlowStatementsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bank_store_slow_statements_total",
+		Help: "SQL statements that took longer than the slow query threshold.",
+	})
+
+	accountCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_account_cache_requests_total",
+		Help: "Account reads served by the account cache, by result: hit or miss.",
+	}, []string{"result"})
+
+	amlFlagsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bank_aml_flags_total",
+		Help: "AML flags raised for compliance review, by rule.",
+	}, []string{"rule"})
+)
+
+// withMetrics counts requests and records their latency under the route
+// template, e.g. /account/{id}, so account numbers don't explode the number
+// of series.
+func withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		route := "unmatched"
+
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		httpRequestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// instrumentedStore times every storage operation and counts transfers.
+type instrumentedStore struct {
+	Storage
+	// slowQuery is how long an operation takes before it is logged, zero to
+	// log none.
+	slowQuery time.Duration
+}
+
+func instrumentStore(store Storage, slowQuery time.Duration) Storage {
+	return &instrumentedStore{Storage: store, slowQuery: slowQuery}
+}
+
+// observe records how long operation took since start, and logs it with the
+// request ID of ctx when it was slow.
+func (s *instrumentedStore) observe(ctx context.Context, operation string, start time.Time) {
+	took := time.Since(start)
+	storeQueryDuration.WithLabelValues(operation).Observe(took.Seconds())
+
+	if s.slowQuery > 0 && took >= s.slowQuery {
+		slog.WarnContext(ctx, "slow query", "operation", operation, "latency", took)
+	}
+}
+
+func (s *instrumentedStore) CreateAccount(ctx context.Context, account *Account) error {
+	defer s.observe(ctx, "CreateAccount", time.Now())
+	return s.Storage.CreateAccount(ctx, account)
+}
+
+func (s *instrumentedStore) CreateAccountWithTransfer(ctx context.Context, acc *Account, transfer *Transfer) error {
+	defer s.observe(ctx, "CreateAccountWithTransfer", time.Now())
+	return s.Storage.CreateAccountWithTransfer(ctx, acc, transfer)
+}
+
+func (s *instrumentedStore) SeedAccount(ctx context.Context, acc *Account, history []*Transaction) error {
+	defer s.observe(ctx, "SeedAccount", time.Now())
+	return s.Storage.SeedAccount(ctx, acc, history)
+}
+
+func (s *instrumentedStore) UpdateAccountStatus(ctx context.Context, id int, status AccountStatus) (*Account, error) {
+	defer s.observe(ctx, "UpdateAccountStatus", time.Now())
+	return s.Storage.UpdateAccountStatus(ctx, id, status)
+}
+
+func (s *instrumentedStore) DeleteAccount(ctx context.Context, id int, now time.Time) (*Account, error) {
+	defer s.observe(ctx, "DeleteAccount", time.Now())
+	return s.Storage.DeleteAccount(ctx, id, now)
+}
+
+func (s *instrumentedStore) CloseAccount(ctx context.Context, number, sweepTo int64) (*AccountClosure, error) {
+	defer s.observe(ctx, "CloseAccount", time.Now())
+	return s.Storage.CloseAccount(ctx, number, sweepTo)
+}
+
+func (s *instrumentedStore) RestoreAccount(ctx context.Context, id int) (*Account, error) {
+	defer s.observe(ctx, "RestoreAccount", time.Now())
+	return s.Storage.RestoreAccount(ctx, id)
+}
+
+func (s *instrumentedStore) UpdateAccount(ctx context.Context, account *Account) error {
+	defer s.observe(ctx, "UpdateAccount", time.Now())
+	return s.Storage.UpdateAccount(ctx, account)
+}
+
+func (s *instrumentedStore) GetAccountById(ctx context.Context, id int) (*Account, error) {
+	defer s.observe(ctx, "GetAccountById", time.Now())
+	return s.Storage.GetAccountById(ctx, id)
+}
+
+func (s *instrumentedStore) GetAccountByNumber(ctx context.Context, number int) (*Account, error) {
+	defer s.observe(ctx, "GetAccountByNumber", time.Now())
+	return s.Storage.GetAccountByNumber(ctx, number)
+}
+
+func (s *instrumentedStore) SearchAccounts(ctx context.Context, q string, limit, offset int) ([]*Account, error) {
+	defer s.observe(ctx, "SearchAccounts", time.Now())
+	return s.Storage.SearchAccounts(ctx, q, limit, offset)
+}
+
+func (s *instrumentedStore) GetAccounts(ctx context.Context, filter AccountFilter) ([]*Account, error) {
+	defer s.observe(ctx, "GetAccounts", time.Now())
+	return s.Storage.GetAccounts(ctx, filter)
+}
+
+func (s *instrumentedStore) ResetPassword(ctx context.Context, number int64, encryptedPassword string) error {
+	defer s.observe(ctx, "ResetPassword", time.Now())
+	return s.Storage.ResetPassword(ctx, number, encryptedPassword)
+}
+
+func (s *instrumentedStore) UpdateAccountMetadata(ctx context.Context, id int, patch map[string]*string, version int) (*Account, error) {
+	defer s.observe(ctx, "UpdateAccountMetadata", time.Now())
+	return s.Storage.UpdateAccountMetadata(ctx, id, patch, version)
+}
+
+func (s *instrumentedStore) UpdateAccountLimits(ctx context.Context, id int, limits AccountLimits) error {
+	defer s.observe(ctx, "UpdateAccountLimits", time.Now())
+	return s.Storage.UpdateAccountLimits(ctx, id, limits)
+}
+
+func (s *instrumentedStore) Deposit(ctx context.Context, number, amount int64, version int) (*Transaction, error) {
+	defer s.observe(ctx, "Deposit", time.Now())
+	return s.Storage.Deposit(ctx, number, amount, version)
+}
+
+func (s *instrumentedStore) Withdraw(ctx context.Context, number, amount int64, version int) (*Transaction, error) {
+	defer s.observe(ctx, "Withdraw", time.Now())
+	return s.Storage.Withdraw(ctx, number, amount, version)
+}
+
+func (s *instrumentedStore) AdjustBalance(ctx context.Context, number, amount int64, description string) (*Transaction, error) {
+	defer s.observe(ctx, "AdjustBalance", time.Now())
+	return s.Storage.AdjustBalance(ctx, number, amount, description)
+}
+
+func (s *instrumentedStore) GetTransactions(ctx context.Context, number int64, limit, offset int) ([]*Transaction, error) {
+	defer s.observe(ctx, "GetTransactions", time.Now())
+	return s.Storage.GetTransactions(ctx, number, limit, offset)
+}
+
+func (s *instrumentedStore) SearchTransactions(ctx context.Context, number int64, search *TransactionSearch) ([]*Transaction, error) {
+	defer s.observe(ctx, "SearchTransactions", time.Now())
+	return s.Storage.SearchTransactions(ctx, number, search)
+}
+
+func (s *instrumentedStore) GetTransactionsBefore(ctx context.Context, number int64, before *TransactionCursor, limit int) ([]*Transaction, error) {
+	defer s.observe(ctx, "GetTransactionsBefore", time.Now())
+	return s.Storage.GetTransactionsBefore(ctx, number, before, limit)
+}
+
+func (s *instrumentedStore) GetTransactionsBetween(ctx context.Context, number int64, from, to time.Time) ([]*Transaction, error) {
+	defer s.observe(ctx, "GetTransactionsBetween", time.Now())
+	return s.Storage.GetTransactionsBetween(ctx, number, from, to)
+}
+
+func (s *instrumentedStore) StreamTransactions(ctx context.Context, number int64, from, to time.Time, fn func(*Transaction) error) error {
+	defer s.observe(ctx, "StreamTransactions", time.Now())
+	return s.Storage.StreamTransactions(ctx, number, from, to, fn)
+}
+
+func (s *instrumentedStore) CategorizeTransaction(ctx context.Context, number int64, id int, category string) (*Transaction, error) {
+	defer s.observe(ctx, "CategorizeTransaction", time.Now())
+	return s.Storage.CategorizeTransaction(ctx, number, id, category)
+}
+
+func (s *instrumentedStore) GetCategoryTotals(ctx context.Context, number int64, from, to time.Time) ([]*CategoryTotal, error) {
+	defer s.observe(ctx, "GetCategoryTotals", time.Now())
+	return s.Storage.GetCategoryTotals(ctx, number, from, to)
+}
+
+func (s *instrumentedStore) GetAccountEvents(ctx context.Context, number int64, filter AccountEventFilter) ([]*AccountEvent, error) {
+	defer s.observe(ctx, "GetAccountEvents", time.Now())
+	return s.Storage.GetAccountEvents(ctx, number, filter)
+}
+
+func (s *instrumentedStore) NextClosingBalanceDay(ctx context.Context) (time.Time, error) {
+	defer s.observe(ctx, "NextClosingBalanceDay", time.Now())
+	return s.Storage.NextClosingBalanceDay(ctx)
+}
+
+func (s *instrumentedStore) MaterializeClosingBalances(ctx context.Context, day time.Time) (int, error) {
+	defer s.observe(ctx, "MaterializeClosingBalances", time.Now())
+	return s.Storage.MaterializeClosingBalances(ctx, day)
+}
+
+func (s *instrumentedStore) GetClosingBalances(ctx context.Context, number int64, from, to time.Time) ([]*ClosingBalance, error) {
+	defer s.observe(ctx, "GetClosingBalances", time.Now())
+	return s.Storage.GetClosingBalances(ctx, number, from, to)
+}
+
+func (s *instrumentedStore) GetBalanceAt(ctx context.Context, number int64, at time.Time) (int64, error) {
+	defer s.observe(ctx, "GetBalanceAt", time.Now())
+	return s.Storage.GetBalanceAt(ctx, number, at)
+}
+
+func (s *instrumentedStore) GetAccountsDueForInterest(ctx context.Context, today time.Time) ([]*Account, error) {
+	defer s.observe(ctx, "GetAccountsDueForInterest", time.Now())
+	return s.Storage.GetAccountsDueForInterest(ctx, today)
+}
+
+func (s *instrumentedStore) AccrueInterest(ctx context.Context, number int64, day time.Time, micros int64, post bool) error {
+	defer s.observe(ctx, "AccrueInterest", time.Now())
+	return s.Storage.AccrueInterest(ctx, number, day, micros, post)
+}
+
+func (s *instrumentedStore) CheckLedgerIntegrity(ctx context.Context) (*LedgerIntegrityReport, error) {
+	defer s.observe(ctx, "CheckLedgerIntegrity", time.Now())
+	return s.Storage.CheckLedgerIntegrity(ctx)
+}
+
+func (s *instrumentedStore) GetMaintenanceState(ctx context.Context) (*MaintenanceState, error) {
+	defer s.observe(ctx, "GetMaintenanceState", time.Now())
+	return s.Storage.GetMaintenanceState(ctx)
+}
+
+func (s *instrumentedStore) SetMaintenanceState(ctx context.Context, state *MaintenanceState) error {
+	defer s.observe(ctx, "SetMaintenanceState", time.Now())
+	return s.Storage.SetMaintenanceState(ctx, state)
+}
+
+func (s *instrumentedStore) CreateReconciliationReport(ctx context.Context, report *ReconciliationReport) error {
+	defer s.observe(ctx, "CreateReconciliationReport", time.Now())
+	return s.Storage.CreateReconciliationReport(ctx, report)
+}
+
+func (s *instrumentedStore) GetReconciliationReports(ctx context.Context, limit, offset int) ([]*ReconciliationReport, error) {
+	defer s.observe(ctx, "GetReconciliationReports", time.Now())
+	return s.Storage.GetReconciliationReports(ctx, limit, offset)
+}
+
+func (s *instrumentedStore) GetReconciliationReport(ctx context.Context, id int) (*ReconciliationReport, error) {
+	defer s.observe(ctx, "GetReconciliationReport", time.Now())
+	return s.Storage.GetReconciliationReport(ctx, id)
+}
+
+func (s *instrumentedStore) ExportDataset(ctx context.Context, now time.Time) (*Dataset, error) {
+	defer s.observe(ctx, "ExportDataset", time.Now())
+	return s.Storage.ExportDataset(ctx, now)
+}
+
+func (s *instrumentedStore) ImportDataset(ctx context.Context, d *Dataset) error {
+	defer s.observe(ctx, "ImportDataset", time.Now())
+	return s.Storage.ImportDataset(ctx, d)
+}
+
+func (s *instrumentedStore) Transfer(ctx context.Context, transfer *Transfer) error {
+	defer s.observe(ctx, "Transfer", time.Now())
+
+	err := s.Storage.Transfer(ctx, transfer)
+	observeTransfer(transfer, err)
+
+	return err
+}
+
+func (s *instrumentedStore) GetTransfer(ctx context.Context, id int) (*Transfer, error) {
+	defer s.observe(ctx, "GetTransfer", time.Now())
+	return s.Storage.GetTransfer(ctx, id)
+}
+
+func (s *instrumentedStore) GetTransfers(ctx context.Context, filter *TransferFilter) ([]*Transfer, error) {
+	defer s.observe(ctx, "GetTransfers", time.Now())
+	return s.Storage.GetTransfers(ctx, filter)
+}
+
+func (s *instrumentedStore) ReverseTransfer(ctx context.Context, id int, now time.Time) (*Transfer, error) {
+	defer s.observe(ctx, "ReverseTransfer", time.Now())
+	return s.Storage.ReverseTransfer(ctx, id, now)
+}
+
+// observeTransfer counts a transfer executed by the store, or why it failed.
+func observeTransfer(transfer *Transfer, err error) {
+	if err != nil {
+		reason := ErrorCodeInternal
+
+		var httpErr *HTTPError
+
+		if errors.As(err, &httpErr) {
+			reason = httpErr.Code
+		}
+
+		transferFailuresTotal.WithLabelValues(string(reason)).Inc()
+
+		return
+	}
+
+	transfersTotal.WithLabelValues(transfer.Currency).Inc()
+	transferAmountTotal.WithLabelValues(transfer.Currency).Add(float64(transfer.Amount))
+}
+
+func (s *instrumentedStore) CreateTransferQuote(ctx context.Context, quote *TransferQuote) error {
+	defer s.observe(ctx, "CreateTransferQuote", time.Now())
+	return s.Storage.CreateTransferQuote(ctx, quote)
+}
+
+func (s *instrumentedStore) UseTransferQuote(ctx context.Context, id int, fromAccount int64, now time.Time) (*TransferQuote, error) {
+	defer s.observe(ctx, "UseTransferQuote", time.Now())
+	return s.Storage.UseTransferQuote(ctx, id, fromAccount, now)
+}
+
+func (s *instrumentedStore) AuthorizeTransfer(ctx context.Context, hold *Hold) error {
+	defer s.observe(ctx, "AuthorizeTransfer", time.Now())
+	return s.Storage.AuthorizeTransfer(ctx, hold)
+}
+
+func (s *instrumentedStore) TransferBatch(ctx context.Context, batch *TransferBatch) error {
+	defer s.observe(ctx, "TransferBatch", time.Now())
+
+	err := s.Storage.TransferBatch(ctx, batch)
+
+	if err != nil {
+		observeTransfer(nil, err)
+		return err
+	}
+
+	for _, item := range batch.Items {
+		if item.Status == TransferBatchItemCompleted {
+			observeTransfer(item.transfer, nil)
+		} else {
+			observeTransfer(nil, &HTTPError{Code: item.ErrorCode})
+		}
+	}
+
+	return nil
+}
+
+func (s *instrumentedStore) GetTransferBatch(ctx context.Context, id int) (*TransferBatch, error) {
+	defer s.observe(ctx, "GetTransferBatch", time.Now())
+	return s.Storage.GetTransferBatch(ctx, id)
+}
+
+func (s *instrumentedStore) CaptureHold(ctx context.Context, id int, fromAccount int64, now time.Time) (*Transfer, error) {
+	defer s.observe(ctx, "CaptureHold", time.Now())
+
+	transfer, err := s.Storage.CaptureHold(ctx, id, fromAccount, now)
+	observeTransfer(transfer, err)
+
+	return transfer, err
+}
+
+func (s *instrumentedStore) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]*Hold, error) {
+	defer s.observe(ctx, "ExpireHolds", time.Now())
+	return s.Storage.ExpireHolds(ctx, now, limit)
+}
+
+func (s *instrumentedStore) CreateCard(ctx context.Context, card *Card) error {
+	defer s.observe(ctx, "CreateCard", time.Now())
+	return s.Storage.CreateCard(ctx, card)
+}
+
+func (s *instrumentedStore) GetCards(ctx context.Context, number int64) ([]*Card, error) {
+	defer s.observe(ctx, "GetCards", time.Now())
+	return s.Storage.GetCards(ctx, number)
+}
+
+func (s *instrumentedStore) UpdateCardStatus(ctx context.Context, id int, number int64, status CardStatus) (*Card, error) {
+	defer s.observe(ctx, "UpdateCardStatus", time.Now())
+	return s.Storage.UpdateCardStatus(ctx, id, number, status)
+}
+
+func (s *instrumentedStore) AuthorizeCard(ctx context.Context, panHash string, auth *CardAuthorization) error {
+	defer s.observe(ctx, "AuthorizeCard", time.Now())
+	return s.Storage.AuthorizeCard(ctx, panHash, auth)
+}
+
+func (s *instrumentedStore) ExpireCardAuthorizations(ctx context.Context, now time.Time, limit int) ([]*CardAuthorization, error) {
+	defer s.observe(ctx, "ExpireCardAuthorizations", time.Now())
+	return s.Storage.ExpireCardAuthorizations(ctx, now, limit)
+}
+
+func (s *instrumentedStore) CreateExternalTransfer(ctx context.Context, transfer *ExternalTransfer) error {
+	defer s.observe(ctx, "CreateExternalTransfer", time.Now())
+	return s.Storage.CreateExternalTransfer(ctx, transfer)
+}
+
+func (s *instrumentedStore) GetExternalTransfers(ctx context.Context, number int64, limit, offset int) ([]*ExternalTransfer, error) {
+	defer s.observe(ctx, "GetExternalTransfers", time.Now())
+	return s.Storage.GetExternalTransfers(ctx, number, limit, offset)
+}
+
+func (s *instrumentedStore) SubmitExternalTransfers(ctx context.Context, now time.Time, limit int) ([]*ExternalTransfer, error) {
+	defer s.observe(ctx, "SubmitExternalTransfers", time.Now())
+	return s.Storage.SubmitExternalTransfers(ctx, now, limit)
+}
+
+func (s *instrumentedStore) SettleExternalTransfers(ctx context.Context, submittedBefore, now time.Time, limit int) ([]*ExternalTransfer, error) {
+	defer s.observe(ctx, "SettleExternalTransfers", time.Now())
+	return s.Storage.SettleExternalTransfers(ctx, submittedBefore, now, limit)
+}
+
+func (s *instrumentedStore) ReturnExternalTransfer(ctx context.Context, id int, code, reason string, now time.Time) (*ExternalTransfer, error) {
+	defer s.observe(ctx, "ReturnExternalTransfer", time.Now())
+	return s.Storage.ReturnExternalTransfer(ctx, id, code, reason, now)
+}
+
+func (s *instrumentedStore) ImportTransactions(ctx context.Context, number int64, rows []*ImportRow, dryRun bool, now time.Time) (*ImportResult, error) {
+	defer s.observe(ctx, "ImportTransactions", time.Now())
+	return s.Storage.ImportTransactions(ctx, number, rows, dryRun, now)
+}
+
+func (s *instrumentedStore) CreateBeneficiary(ctx context.Context, b *Beneficiary) error {
+	defer s.observe(ctx, "CreateBeneficiary", time.Now())
+	return s.Storage.CreateBeneficiary(ctx, b)
+}
+
+func (s *instrumentedStore) GetBeneficiaries(ctx context.Context, owner int64) ([]*Beneficiary, error) {
+	defer s.observe(ctx, "GetBeneficiaries", time.Now())
+	return s.Storage.GetBeneficiaries(ctx, owner)
+}
+
+func (s *instrumentedStore) GetBeneficiary(ctx context.Context, id int, owner int64) (*Beneficiary, error) {
+	defer s.observe(ctx, "GetBeneficiary", time.Now())
+	return s.Storage.GetBeneficiary(ctx, id, owner)
+}
+
+func (s *instrumentedStore) DeleteBeneficiary(ctx context.Context, id int, owner int64) error {
+	defer s.observe(ctx, "DeleteBeneficiary", time.Now())
+	return s.Storage.DeleteBeneficiary(ctx, id, owner)
+}
+
+func (s *instrumentedStore) SetContactDetail(ctx context.Context, detail *ContactDetail) error {
+	defer s.observe(ctx, "SetContactDetail", time.Now())
+	return s.Storage.SetContactDetail(ctx, detail)
+}
+
+func (s *instrumentedStore) GetContactDetails(ctx context.Context, number int64) ([]*ContactDetail, error) {
+	defer s.observe(ctx, "GetContactDetails", time.Now())
+	return s.Storage.GetContactDetails(ctx, number)
+}
+
+func (s *instrumentedStore) VerifyContactDetail(ctx context.Context, number int64, kind ContactKind, codeHash string, now time.Time) (*ContactDetail, error) {
+	defer s.observe(ctx, "VerifyContactDetail", time.Now())
+	return s.Storage.VerifyContactDetail(ctx, number, kind, codeHash, now)
+}
+
+func (s *instrumentedStore) DeleteContactDetail(ctx context.Context, number int64, kind ContactKind) error {
+	defer s.observe(ctx, "DeleteContactDetail", time.Now())
+	return s.Storage.DeleteContactDetail(ctx, number, kind)
+}
+
+func (s *instrumentedStore) CreateAlias(ctx context.Context, alias *Alias) error {
+	defer s.observe(ctx, "CreateAlias", time.Now())
+	return s.Storage.CreateAlias(ctx, alias)
+}
+
+func (s *instrumentedStore) GetAliases(ctx context.Context, number int64) ([]*Alias, error) {
+	defer s.observe(ctx, "GetAliases", time.Now())
+	return s.Storage.GetAliases(ctx, number)
+}
+
+func (s *instrumentedStore) VerifyAlias(ctx context.Context, id int, number int64, codeHash string, now time.Time) (*Alias, error) {
+	defer s.observe(ctx, "VerifyAlias", time.Now())
+	return s.Storage.VerifyAlias(ctx, id, number, codeHash, now)
+}
+
+func (s *instrumentedStore) DeleteAlias(ctx context.Context, id int, number int64) error {
+	defer s.observe(ctx, "DeleteAlias", time.Now())
+	return s.Storage.DeleteAlias(ctx, id, number)
+}
+
+func (s *instrumentedStore) ResolveAlias(ctx context.Context, value string) (*Alias, error) {
+	defer s.observe(ctx, "ResolveAlias", time.Now())
+	return s.Storage.ResolveAlias(ctx, value)
+}
+
+func (s *instrumentedStore) RecordAudit(ctx context.Context, entry *AuditEntry) error {
+	defer s.observe(ctx, "RecordAudit", time.Now())
+	return s.Storage.RecordAudit(ctx, entry)
+}
+
+func (s *instrumentedStore) GetAuditLog(ctx context.Context, limit, offset int) ([]*AuditEntry, error) {
+	defer s.observe(ctx, "GetAuditLog", time.Now())
+	return s.Storage.GetAuditLog(ctx, limit, offset)
+}
+
+func (s *instrumentedStore) LinkIdentity(ctx context.Context, identity *ExternalIdentity) error {
+	defer s.observe(ctx, "LinkIdentity", time.Now())
+	return s.Storage.LinkIdentity(ctx, identity)
+}
+
+func (s *instrumentedStore) GetIdentity(ctx context.Context, issuer, subject string) (*ExternalIdentity, error) {
+	defer s.observe(ctx, "GetIdentity", time.Now())
+	return s.Storage.GetIdentity(ctx, issuer, subject)
+}
+
+func (s *instrumentedStore) CreateScheduledTransfer(ctx context.Context, st *ScheduledTransfer) error {
+	defer s.observe(ctx, "CreateScheduledTransfer", time.Now())
+	return s.Storage.CreateScheduledTransfer(ctx, st)
+}
+
+func (s *instrumentedStore) GetScheduledTransfers(ctx context.Context, accountNumber int64) ([]*ScheduledTransfer, error) {
+	defer s.observe(ctx, "GetScheduledTransfers", time.Now())
+	return s.Storage.GetScheduledTransfers(ctx, accountNumber)
+}
+
+func (s *instrumentedStore) CancelScheduledTransfer(ctx context.Context, id int, accountNumber int64) error {
+	defer s.observe(ctx, "CancelScheduledTransfer", time.Now())
+	return s.Storage.CancelScheduledTransfer(ctx, id, accountNumber)
+}
+
+func (s *instrumentedStore) ClaimDueScheduledTransfers(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*ScheduledTransfer, error) {
+	defer s.observe(ctx, "ClaimDueScheduledTransfers", time.Now())
+	return s.Storage.ClaimDueScheduledTransfers(ctx, now, leaseUntil, limit)
+}
+
+func (s *instrumentedStore) RecordScheduledTransferRun(ctx context.Context, st *ScheduledTransfer, run *ScheduledTransferRun) error {
+	defer s.observe(ctx, "RecordScheduledTransferRun", time.Now())
+	return s.Storage.RecordScheduledTransferRun(ctx, st, run)
+}
+
+func (s *instrumentedStore) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	defer s.observe(ctx, "CreateWebhook", time.Now())
+	return s.Storage.CreateWebhook(ctx, webhook)
+}
+
+func (s *instrumentedStore) GetWebhooks(ctx context.Context, accountNumber *int64) ([]*Webhook, error) {
+	defer s.observe(ctx, "GetWebhooks", time.Now())
+	return s.Storage.GetWebhooks(ctx, accountNumber)
+}
+
+func (s *instrumentedStore) DeleteWebhook(ctx context.Context, id int, accountNumber *int64) error {
+	defer s.observe(ctx, "DeleteWebhook", time.Now())
+	return s.Storage.DeleteWebhook(ctx, id, accountNumber)
+}
+
+func (s *instrumentedStore) CreateLoan(ctx context.Context, loan *Loan, installments []*LoanInstallment) (*Transaction, error) {
+	defer s.observe(ctx, "CreateLoan", time.Now())
+	return s.Storage.CreateLoan(ctx, loan, installments)
+}
+
+func (s *instrumentedStore) GetLoan(ctx context.Context, id int) (*Loan, error) {
+	defer s.observe(ctx, "GetLoan", time.Now())
+	return s.Storage.GetLoan(ctx, id)
+}
+
+func (s *instrumentedStore) GetLoans(ctx context.Context, number int64) ([]*Loan, error) {
+	defer s.observe(ctx, "GetLoans", time.Now())
+	return s.Storage.GetLoans(ctx, number)
+}
+
+func (s *instrumentedStore) GetLoanSchedule(ctx context.Context, id int) ([]*LoanInstallment, error) {
+	defer s.observe(ctx, "GetLoanSchedule", time.Now())
+	return s.Storage.GetLoanSchedule(ctx, id)
+}
+
+func (s *instrumentedStore) GetLoansDue(ctx context.Context, now time.Time, limit int) ([]*Loan, error) {
+	defer s.observe(ctx, "GetLoansDue", time.Now())
+	return s.Storage.GetLoansDue(ctx, now, limit)
+}
+
+func (s *instrumentedStore) CollectLoanRepayments(ctx context.Context, id int, now time.Time) (*Loan, []*Transaction, error) {
+	defer s.observe(ctx, "CollectLoanRepayments", time.Now())
+	return s.Storage.CollectLoanRepayments(ctx, id, now)
+}
+
+func (s *instrumentedStore) GetNotificationPreferences(ctx context.Context, number int64) (*NotificationPreferences, error) {
+	defer s.observe(ctx, "GetNotificationPreferences", time.Now())
+	return s.Storage.GetNotificationPreferences(ctx, number)
+}
+
+func (s *instrumentedStore) SaveNotificationPreferences(ctx context.Context, prefs *NotificationPreferences) error {
+	defer s.observe(ctx, "SaveNotificationPreferences", time.Now())
+	return s.Storage.SaveNotificationPreferences(ctx, prefs)
+}
+
+func (s *instrumentedStore) EnqueueNotificationDeliveries(ctx context.Context, deliveries []*NotificationDelivery) error {
+	defer s.observe(ctx, "EnqueueNotificationDeliveries", time.Now())
+	return s.Storage.EnqueueNotificationDeliveries(ctx, deliveries)
+}
+
+func (s *instrumentedStore) ClaimDueNotificationDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*NotificationDelivery, error) {
+	defer s.observe(ctx, "ClaimDueNotificationDeliveries", time.Now())
+	return s.Storage.ClaimDueNotificationDeliveries(ctx, now, leaseUntil, limit)
+}
+
+func (s *instrumentedStore) UpdateNotificationDelivery(ctx context.Context, delivery *NotificationDelivery) error {
+	defer s.observe(ctx, "UpdateNotificationDelivery", time.Now())
+	return s.Storage.UpdateNotificationDelivery(ctx, delivery)
+}
+
+func (s *instrumentedStore) GetNotificationDeliveries(ctx context.Context, number int64, limit, offset int) ([]*NotificationDelivery, error) {
+	defer s.observe(ctx, "GetNotificationDeliveries", time.Now())
+	return s.Storage.GetNotificationDeliveries(ctx, number, limit, offset)
+}
+
+func (s *instrumentedStore) GetWebhookDeliveries(ctx context.Context, webhookID int, accountNumber *int64, limit, offset int) ([]*WebhookDelivery, error) {
+	defer s.observe(ctx, "GetWebhookDeliveries", time.Now())
+	return s.Storage.GetWebhookDeliveries(ctx, webhookID, accountNumber, limit, offset)
+}
+
+func (s *instrumentedStore) EnqueueWebhookDeliveries(ctx context.Context, event *Event, payload []byte, balance *int64) error {
+	defer s.observe(ctx, "EnqueueWebhookDeliveries", time.Now())
+	return s.Storage.EnqueueWebhookDeliveries(ctx, event, payload, balance)
+}
+
+func (s *instrumentedStore) ClaimDueWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error) {
+	defer s.observe(ctx, "ClaimDueWebhookDeliveries", time.Now())
+	return s.Storage.ClaimDueWebhookDeliveries(ctx, now, leaseUntil, limit)
+}
+
+func (s *instrumentedStore) UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	defer s.observe(ctx, "UpdateWebhookDelivery", time.Now())
+	return s.Storage.UpdateWebhookDelivery(ctx, delivery)
+}
+
+func (s *instrumentedStore) SubmitKYC(ctx context.Context, kyc *KYC) error {
+	defer s.observe(ctx, "SubmitKYC", time.Now())
+	return s.Storage.SubmitKYC(ctx, kyc)
+}
+
+func (s *instrumentedStore) GetKYC(ctx context.Context, number int64) (*KYC, error) {
+	defer s.observe(ctx, "GetKYC", time.Now())
+	return s.Storage.GetKYC(ctx, number)
+}
+
+func (s *instrumentedStore) GetPendingKYC(ctx context.Context, limit, offset int) ([]*KYC, error) {
+	defer s.observe(ctx, "GetPendingKYC", time.Now())
+	return s.Storage.GetPendingKYC(ctx, limit, offset)
+}
+
+func (s *instrumentedStore) ReviewKYC(ctx context.Context, number int64, status KYCStatus, reason string, reviewedAt time.Time) (*KYC, error) {
+	defer s.observe(ctx, "ReviewKYC", time.Now())
+	return s.Storage.ReviewKYC(ctx, number, status, reason, reviewedAt)
+}
+
+func (s *instrumentedStore) AddAccountOwner(ctx context.Context, owner *AccountOwner) error {
+	defer s.observe(ctx, "AddAccountOwner", time.Now())
+	return s.Storage.AddAccountOwner(ctx, owner)
+}
+
+func (s *instrumentedStore) RemoveAccountOwner(ctx context.Context, number, owner int64) error {
+	defer s.observe(ctx, "RemoveAccountOwner", time.Now())
+	return s.Storage.RemoveAccountOwner(ctx, number, owner)
+}
+
+func (s *instrumentedStore) GetAccountOwners(ctx context.Context, number int64) ([]*AccountOwner, error) {
+	defer s.observe(ctx, "GetAccountOwners", time.Now())
+	return s.Storage.GetAccountOwners(ctx, number)
+}
+
+func (s *instrumentedStore) GetOwnedAccounts(ctx context.Context, owner int64) ([]*AccountOwner, error) {
+	defer s.observe(ctx, "GetOwnedAccounts", time.Now())
+	return s.Storage.GetOwnedAccounts(ctx, owner)
+}
+
+func (s *instrumentedStore) CreateTransferApproval(ctx context.Context, approval *TransferApproval) error {
+	defer s.observe(ctx, "CreateTransferApproval", time.Now())
+	return s.Storage.CreateTransferApproval(ctx, approval)
+}
+
+func (s *instrumentedStore) GetTransferApprovals(ctx context.Context, number int64, limit, offset int) ([]*TransferApproval, error) {
+	defer s.observe(ctx, "GetTransferApprovals", time.Now())
+	return s.Storage.GetTransferApprovals(ctx, number, limit, offset)
+}
+
+func (s *instrumentedStore) DecideTransferApproval(ctx context.Context, id int, number int64, status TransferApprovalStatus, decidedBy int64, decidedByUser int, decidedAt time.Time) (*TransferApproval, error) {
+	defer s.observe(ctx, "DecideTransferApproval", time.Now())
+	return s.Storage.DecideTransferApproval(ctx, id, number, status, decidedBy, decidedByUser, decidedAt)
+}
+
+func (s *instrumentedStore) UpdateTransferApproval(ctx context.Context, approval *TransferApproval) error {
+	defer s.observe(ctx, "UpdateTransferApproval", time.Now())
+	return s.Storage.UpdateTransferApproval(ctx, approval)
+}
+
+func (s *instrumentedStore) CreateAdminApproval(ctx context.Context, approval *AdminApproval) error {
+	defer s.observe(ctx, "CreateAdminApproval", time.Now())
+	return s.Storage.CreateAdminApproval(ctx, approval)
+}
+
+func (s *instrumentedStore) GetAdminApprovals(ctx context.Context, status AdminApprovalStatus, limit, offset int) ([]*AdminApproval, error) {
+	defer s.observe(ctx, "GetAdminApprovals", time.Now())
+	return s.Storage.GetAdminApprovals(ctx, status, limit, offset)
+}
+
+func (s *instrumentedStore) DecideAdminApproval(ctx context.Context, id int, status AdminApprovalStatus, decidedBy int64, decidedAt time.Time) (*AdminApproval, error) {
+	defer s.observe(ctx, "DecideAdminApproval", time.Now())
+	return s.Storage.DecideAdminApproval(ctx, id, status, decidedBy, decidedAt)
+}
+
+func (s *instrumentedStore) UpdateAdminApproval(ctx context.Context, approval *AdminApproval) error {
+	defer s.observe(ctx, "UpdateAdminApproval", time.Now())
+	return s.Storage.UpdateAdminApproval(ctx, approval)
+}
+
+func (s *instrumentedStore) CreateTermDeposit(ctx context.Context, deposit *TermDeposit) (*Transaction, error) {
+	defer s.observe(ctx, "CreateTermDeposit", time.Now())
+	return s.Storage.CreateTermDeposit(ctx, deposit)
+}
+
+func (s *instrumentedStore) GetTermDeposits(ctx context.Context, number int64) ([]*TermDeposit, error) {
+	defer s.observe(ctx, "GetTermDeposits", time.Now())
+	return s.Storage.GetTermDeposits(ctx, number)
+}
+
+func (s *instrumentedStore) GetTermDeposit(ctx context.Context, id int, number int64) (*TermDeposit, error) {
+	defer s.observe(ctx, "GetTermDeposit", time.Now())
+	return s.Storage.GetTermDeposit(ctx, id, number)
+}
+
+func (s *instrumentedStore) GetTermDepositsDue(ctx context.Context, now time.Time, limit int) ([]*TermDeposit, error) {
+	defer s.observe(ctx, "GetTermDepositsDue", time.Now())
+	return s.Storage.GetTermDepositsDue(ctx, now, limit)
+}
+
+func (s *instrumentedStore) CloseTermDeposit(ctx context.Context, id int, number int64, now time.Time, penaltyDays int) (*TermDeposit, *Transaction, error) {
+	defer s.observe(ctx, "CloseTermDeposit", time.Now())
+	return s.Storage.CloseTermDeposit(ctx, id, number, now, penaltyDays)
+}
+
+func (s *instrumentedStore) CreateFraudReview(ctx context.Context, review *FraudReview) error {
+	defer s.observe(ctx, "CreateFraudReview", time.Now())
+	return s.Storage.CreateFraudReview(ctx, review)
+}
+
+func (s *instrumentedStore) GetFraudReviews(ctx context.Context, status FraudReviewStatus, limit, offset int) ([]*FraudReview, error) {
+	defer s.observe(ctx, "GetFraudReviews", time.Now())
+	return s.Storage.GetFraudReviews(ctx, status, limit, offset)
+}
+
+func (s *instrumentedStore) DecideFraudReview(ctx context.Context, id int, status FraudReviewStatus, reviewedBy int64, reviewedAt time.Time) (*FraudReview, error) {
+	defer s.observe(ctx, "DecideFraudReview", time.Now())
+	return s.Storage.DecideFraudReview(ctx, id, status, reviewedBy, reviewedAt)
+}
+
+func (s *instrumentedStore) CreateAMLFlag(ctx context.Context, flag *AMLFlag) error {
+	defer s.observe(ctx, "CreateAMLFlag", time.Now())
+	return s.Storage.CreateAMLFlag(ctx, flag)
+}
+
+func (s *instrumentedStore) GetAMLFlags(ctx context.Context, filter *AMLFlagFilter) ([]*AMLFlag, error) {
+	defer s.observe(ctx, "GetAMLFlags", time.Now())
+	return s.Storage.GetAMLFlags(ctx, filter)
+}
+
+func (s *instrumentedStore) GetAMLFlag(ctx context.Context, id int) (*AMLFlag, error) {
+	defer s.observe(ctx, "GetAMLFlag", time.Now())
+	return s.Storage.GetAMLFlag(ctx, id)
+}
+
+func (s *instrumentedStore) UpdateAMLFlagStatus(ctx context.Context, id int, status AMLFlagStatus, reviewedBy int64, at time.Time) (*AMLFlag, error) {
+	defer s.observe(ctx, "UpdateAMLFlagStatus", time.Now())
+	return s.Storage.UpdateAMLFlagStatus(ctx, id, status, reviewedBy, at)
+}
+
+func (s *instrumentedStore) AddAMLNote(ctx context.Context, note *AMLNote) error {
+	defer s.observe(ctx, "AddAMLNote", time.Now())
+	return s.Storage.AddAMLNote(ctx, note)
+}
+
+func (s *instrumentedStore) GetAMLNotes(ctx context.Context, flagIDs []int) ([]*AMLNote, error) {
+	defer s.observe(ctx, "GetAMLNotes", time.Now())
+	return s.Storage.GetAMLNotes(ctx, flagIDs)
+}
+
+func (s *instrumentedStore) RecordLoginNetwork(ctx context.Context, number int64, network string, at time.Time) error {
+	defer s.observe(ctx, "RecordLoginNetwork", time.Now())
+	return s.Storage.RecordLoginNetwork(ctx, number, network, at)
+}
+
+func (s *instrumentedStore) GetLoginNetworks(ctx context.Context, number int64) ([]string, error) {
+	defer s.observe(ctx, "GetLoginNetworks", time.Now())
+	return s.Storage.GetLoginNetworks(ctx, number)
+}
+
+func (s *instrumentedStore) CountTransfersFrom(ctx context.Context, number int64, since time.Time) (int, error) {
+	defer s.observe(ctx, "CountTransfersFrom", time.Now())
+	return s.Storage.CountTransfersFrom(ctx, number, since)
+}
+
+func (s *instrumentedStore) HasTransferredTo(ctx context.Context, from, to int64) (bool, error) {
+	defer s.observe(ctx, "HasTransferredTo", time.Now())
+	return s.Storage.HasTransferredTo(ctx, from, to)
+}
+
+func (s *instrumentedStore) CreateDispute(ctx context.Context, dispute *Dispute) error {
+	defer s.observe(ctx, "CreateDispute", time.Now())
+	return s.Storage.CreateDispute(ctx, dispute)
+}
+
+func (s *instrumentedStore) GetDisputes(ctx context.Context, number int64, status DisputeStatus, limit, offset int) ([]*Dispute, error) {
+	defer s.observe(ctx, "GetDisputes", time.Now())
+	return s.Storage.GetDisputes(ctx, number, status, limit, offset)
+}
+
+func (s *instrumentedStore) DecideDispute(ctx context.Context, id int, decision *DisputeDecision) (*Dispute, error) {
+	defer s.observe(ctx, "DecideDispute", time.Now())
+	return s.Storage.DecideDispute(ctx, id, decision)
+}
+
+func (s *instrumentedStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	defer s.observe(ctx, "CreateRefreshToken", time.Now())
+	return s.Storage.CreateRefreshToken(ctx, token)
+}
+
+func (s *instrumentedStore) RotateRefreshToken(ctx context.Context, tokenHash string, next *RefreshToken, device Device) error {
+	defer s.observe(ctx, "RotateRefreshToken", time.Now())
+	return s.Storage.RotateRefreshToken(ctx, tokenHash, next, device)
+}
+
+func (s *instrumentedStore) CreateSession(ctx context.Context, session *Session) error {
+	defer s.observe(ctx, "CreateSession", time.Now())
+	return s.Storage.CreateSession(ctx, session)
+}
+
+func (s *instrumentedStore) GetSessions(ctx context.Context, number int64, now time.Time) ([]*Session, error) {
+	defer s.observe(ctx, "GetSessions", time.Now())
+	return s.Storage.GetSessions(ctx, number, now)
+}
+
+func (s *instrumentedStore) RevokeSessions(ctx context.Context, number int64, id int, now, deniedUntil time.Time) ([]*Session, error) {
+	defer s.observe(ctx, "RevokeSessions", time.Now())
+	return s.Storage.RevokeSessions(ctx, number, id, now, deniedUntil)
+}
+
+func (s *instrumentedStore) SessionDenied(ctx context.Context, id int, now time.Time) (bool, error) {
+	defer s.observe(ctx, "SessionDenied", time.Now())
+	return s.Storage.SessionDenied(ctx, id, now)
+}
+
+func (s *instrumentedStore) CreateBusinessUser(ctx context.Context, user *BusinessUser) error {
+	defer s.observe(ctx, "CreateBusinessUser", time.Now())
+	return s.Storage.CreateBusinessUser(ctx, user)
+}
+
+func (s *instrumentedStore) GetBusinessUser(ctx context.Context, id int) (*BusinessUser, error) {
+	defer s.observe(ctx, "GetBusinessUser", time.Now())
+	return s.Storage.GetBusinessUser(ctx, id)
+}
+
+func (s *instrumentedStore) GetBusinessUserByUsername(ctx context.Context, number int64, username string) (*BusinessUser, error) {
+	defer s.observe(ctx, "GetBusinessUserByUsername", time.Now())
+	return s.Storage.GetBusinessUserByUsername(ctx, number, username)
+}
+
+func (s *instrumentedStore) GetBusinessUsers(ctx context.Context, number int64) ([]*BusinessUser, error) {
+	defer s.observe(ctx, "GetBusinessUsers", time.Now())
+	return s.Storage.GetBusinessUsers(ctx, number)
+}
+
+func (s *instrumentedStore) SetBusinessUserRole(ctx context.Context, id int, number int64, role BusinessUserRole) (*BusinessUser, *BusinessUser, error) {
+	defer s.observe(ctx, "SetBusinessUserRole", time.Now())
+	return s.Storage.SetBusinessUserRole(ctx, id, number, role)
+}
+
+func (s *instrumentedStore) RemoveBusinessUser(ctx context.Context, id int, number int64, now, deniedUntil time.Time) (*BusinessUser, error) {
+	defer s.observe(ctx, "RemoveBusinessUser", time.Now())
+	return s.Storage.RemoveBusinessUser(ctx, id, number, now, deniedUntil)
+}
+
+func (s *instrumentedStore) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	defer s.observe(ctx, "CreateAPIKey", time.Now())
+	return s.Storage.CreateAPIKey(ctx, key)
+}
+
+func (s *instrumentedStore) GetAPIKeys(ctx context.Context, number int64) ([]*APIKey, error) {
+	defer s.observe(ctx, "GetAPIKeys", time.Now())
+	return s.Storage.GetAPIKeys(ctx, number)
+}
+
+func (s *instrumentedStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	defer s.observe(ctx, "GetAPIKeyByHash", time.Now())
+	return s.Storage.GetAPIKeyByHash(ctx, keyHash)
+}
+
+func (s *instrumentedStore) TouchAPIKey(ctx context.Context, id int, now time.Time) error {
+	defer s.observe(ctx, "TouchAPIKey", time.Now())
+	return s.Storage.TouchAPIKey(ctx, id, now)
+}
+
+func (s *instrumentedStore) RevokeAPIKey(ctx context.Context, id int, number int64, now time.Time) (*APIKey, error) {
+	defer s.observe(ctx, "RevokeAPIKey", time.Now())
+	return s.Storage.RevokeAPIKey(ctx, id, number, now)
+}
+
+func (s *instrumentedStore) CreatePaymentRequest(ctx context.Context, request *PaymentRequest) error {
+	defer s.observe(ctx, "CreatePaymentRequest", time.Now())
+	return s.Storage.CreatePaymentRequest(ctx, request)
+}
+
+func (s *instrumentedStore) GetPaymentRequest(ctx context.Context, id int, now time.Time) (*PaymentRequest, error) {
+	defer s.observe(ctx, "GetPaymentRequest", time.Now())
+	return s.Storage.GetPaymentRequest(ctx, id, now)
+}
+
+func (s *instrumentedStore) GetPaymentRequests(ctx context.Context, number int64, incoming bool, status PaymentRequestStatus, now time.Time, limit, offset int) ([]*PaymentRequest, error) {
+	defer s.observe(ctx, "GetPaymentRequests", time.Now())
+	return s.Storage.GetPaymentRequests(ctx, number, incoming, status, now, limit, offset)
+}
+
+func (s *instrumentedStore) DecidePaymentRequest(ctx context.Context, id int, fromAccount int64, status PaymentRequestStatus, now time.Time) (*PaymentRequest, error) {
+	defer s.observe(ctx, "DecidePaymentRequest", time.Now())
+	return s.Storage.DecidePaymentRequest(ctx, id, fromAccount, status, now)
+}
+
+func (s *instrumentedStore) AcceptPaymentRequest(ctx context.Context, id int, fromAccount int64, transfer *Transfer, now time.Time) (*PaymentRequest, error) {
+	defer s.observe(ctx, "AcceptPaymentRequest", time.Now())
+	return s.Storage.AcceptPaymentRequest(ctx, id, fromAccount, transfer, now)
+}
+
+func (s *instrumentedStore) GetLoginThrottles(ctx context.Context, keys []string) ([]*LoginThrottle, error) {
+	defer s.observe(ctx, "GetLoginThrottles", time.Now())
+	return s.Storage.GetLoginThrottles(ctx, keys)
+}
+
+func (s *instrumentedStore) RecordLoginFailure(ctx context.Context, key string, policy LoginPolicy, now time.Time) (*LoginThrottle, error) {
+	defer s.observe(ctx, "RecordLoginFailure", time.Now())
+	return s.Storage.RecordLoginFailure(ctx, key, policy, now)
+}
+
+func (s *instrumentedStore) ClearLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error) {
+	defer s.observe(ctx, "ClearLoginThrottle", time.Now())
+	return s.Storage.ClearLoginThrottle(ctx, key)
+}
+
+func (s *instrumentedStore) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	defer s.observe(ctx, "CreatePasswordReset", time.Now())
+	return s.Storage.CreatePasswordReset(ctx, reset)
+}
+
+func (s *instrumentedStore) RedeemPasswordReset(ctx context.Context, tokenHash, encryptedPassword string) (int64, error) {
+	defer s.observe(ctx, "RedeemPasswordReset", time.Now())
+	return s.Storage.RedeemPasswordReset(ctx, tokenHash, encryptedPassword)
+}
+
+func (s *instrumentedStore) CreateTOTP(ctx context.Context, t *TOTP) error {
+	defer s.observe(ctx, "CreateTOTP", time.Now())
+	return s.Storage.CreateTOTP(ctx, t)
+}
+
+func (s *instrumentedStore) GetTOTP(ctx context.Context, number int64) (*TOTP, error) {
+	defer s.observe(ctx, "GetTOTP", time.Now())
+	return s.Storage.GetTOTP(ctx, number)
+}
+
+func (s *instrumentedStore) EnableTOTP(ctx context.Context, number int64, backupCodeHashes []string) error {
+	defer s.observe(ctx, "EnableTOTP", time.Now())
+	return s.Storage.EnableTOTP(ctx, number, backupCodeHashes)
+}
+
+func (s *instrumentedStore) UseTOTPBackupCode(ctx context.Context, number int64, codeHash string) error {
+	defer s.observe(ctx, "UseTOTPBackupCode", time.Now())
+	return s.Storage.UseTOTPBackupCode(ctx, number, codeHash)
+}
+
+func (s *instrumentedStore) ReserveIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, bool, error) {
+	defer s.observe(ctx, "ReserveIdempotencyKey", time.Now())
+	return s.Storage.ReserveIdempotencyKey(ctx, rec)
+}
+
+func (s *instrumentedStore) CompleteIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) error {
+	defer s.observe(ctx, "CompleteIdempotencyKey", time.Now())
+	return s.Storage.CompleteIdempotencyKey(ctx, rec)
+}
+
+func (s *instrumentedStore) ReleaseIdempotencyKey(ctx context.Context, key, scope string) error {
+	defer s.observe(ctx, "ReleaseIdempotencyKey", time.Now())
+	return s.Storage.ReleaseIdempotencyKey(ctx, key, scope)
+}
+
+func (s *instrumentedStore) CreatePot(ctx context.Context, pot *Pot) error {
+	defer s.observe(ctx, "CreatePot", time.Now())
+	return s.Storage.CreatePot(ctx, pot)
+}
+
+func (s *instrumentedStore) GetPots(ctx context.Context, number int64) ([]*Pot, error) {
+	defer s.observe(ctx, "GetPots", time.Now())
+	return s.Storage.GetPots(ctx, number)
+}
+
+func (s *instrumentedStore) GetPot(ctx context.Context, id int, number int64) (*Pot, error) {
+	defer s.observe(ctx, "GetPot", time.Now())
+	return s.Storage.GetPot(ctx, id, number)
+}
+
+func (s *instrumentedStore) UpdatePot(ctx context.Context, pot *Pot) error {
+	defer s.observe(ctx, "UpdatePot", time.Now())
+	return s.Storage.UpdatePot(ctx, pot)
+}
+
+func (s *instrumentedStore) DeletePot(ctx context.Context, id int, number int64) (*Pot, error) {
+	defer s.observe(ctx, "DeletePot", time.Now())
+	return s.Storage.DeletePot(ctx, id, number)
+}
+
+func (s *instrumentedStore) MovePotMoney(ctx context.Context, id int, number int64, amount int64) (*Pot, error) {
+	defer s.observe(ctx, "MovePotMoney", time.Now())
+	return s.Storage.MovePotMoney(ctx, id, number, amount)
+}
+
+func (s *instrumentedStore) SweepPots(ctx context.Context, now time.Time, limit int) ([]*PotSweep, error) {
+	defer s.observe(ctx, "SweepPots", time.Now())
+	return s.Storage.SweepPots(ctx, now, limit)
+}
+
+func (s *instrumentedStore) CreateStandingOrder(ctx context.Context, order *StandingOrder) error {
+	defer s.observe(ctx, "CreateStandingOrder", time.Now())
+	return s.Storage.CreateStandingOrder(ctx, order)
+}
+
+func (s *instrumentedStore) GetStandingOrders(ctx context.Context, accountNumber int64) ([]*StandingOrder, error) {
+	defer s.observe(ctx, "GetStandingOrders", time.Now())
+	return s.Storage.GetStandingOrders(ctx, accountNumber)
+}
+
+func (s *instrumentedStore) CancelStandingOrder(ctx context.Context, id int, accountNumber int64) error {
+	defer s.observe(ctx, "CancelStandingOrder", time.Now())
+	return s.Storage.CancelStandingOrder(ctx, id, accountNumber)
+}
+
+func (s *instrumentedStore) ClaimDueStandingOrders(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*StandingOrder, error) {
+	defer s.observe(ctx, "ClaimDueStandingOrders", time.Now())
+	return s.Storage.ClaimDueStandingOrders(ctx, now, leaseUntil, limit)
+}
+
+func (s *instrumentedStore) UpdateStandingOrder(ctx context.Context, order *StandingOrder) error {
+	defer s.observe(ctx, "UpdateStandingOrder", time.Now())
+	return s.Storage.UpdateStandingOrder(ctx, order)
+}
+
+func (s *instrumentedStore) AddHoliday(ctx context.Context, holiday *Holiday) error {
+	defer s.observe(ctx, "AddHoliday", time.Now())
+	return s.Storage.AddHoliday(ctx, holiday)
+}
+
+func (s *instrumentedStore) GetHolidays(ctx context.Context) ([]*Holiday, error) {
+	defer s.observe(ctx, "GetHolidays", time.Now())
+	return s.Storage.GetHolidays(ctx)
+}
+
+func (s *instrumentedStore) DeleteHoliday(ctx context.Context, date string) error {
+	defer s.observe(ctx, "DeleteHoliday", time.Now())
+	return s.Storage.DeleteHoliday(ctx, date)
+}
+
+func (s *instrumentedStore) GetAccountStats(ctx context.Context) (*AccountStats, error) {
+	defer s.observe(ctx, "GetAccountStats", time.Now())
+	return s.Storage.GetAccountStats(ctx)
+}
+
+func (s *instrumentedStore) GetTransferVolume(ctx context.Context, from, to time.Time) ([]*DailyTransferVolume, error) {
+	defer s.observe(ctx, "GetTransferVolume", time.Now())
+	return s.Storage.GetTransferVolume(ctx, from, to)
+}
+
+func (s *instrumentedStore) GetFailedLogins(ctx context.Context, from, to time.Time) ([]*DailyCount, error) {
+	defer s.observe(ctx, "GetFailedLogins", time.Now())
+	return s.Storage.GetFailedLogins(ctx, from, to)
+}
+
+func (s *instrumentedStore) GetLargestAccounts(ctx context.Context, currency string, limit int) ([]*Account, error) {
+	defer s.observe(ctx, "GetLargestAccounts", time.Now())
+	return s.Storage.GetLargestAccounts(ctx, currency, limit)
+}
+
+func (s *instrumentedStore) ClaimOutboxMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*OutboxMessage, error) {
+	defer s.observe(ctx, "ClaimOutboxMessages", time.Now())
+	return s.Storage.ClaimOutboxMessages(ctx, now, leaseUntil, limit)
+}
+
+func (s *instrumentedStore) UpdateOutboxMessage(ctx context.Context, msg *OutboxMessage) error {
+	defer s.observe(ctx, "UpdateOutboxMessage", time.Now())
+	return s.Storage.UpdateOutboxMessage(ctx, msg)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+
+	rec := api.do("GET", "/account/"+strconv.Itoa(alice.ID), api.login(alice, "alice-pw"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = api.do("GET", "/metrics", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `bank_http_requests_total{method="GET",route="/account/{id}",status="200"}`)
+	assert.Contains(t, body, `bank_http_requests_total{method="POST",route="/login",status="200"}`)
+	assert.Contains(t, body, `bank_http_request_duration_seconds_bucket{method="GET",route="/account/{id}"`)
+}
+
+func TestInstrumentedStoreTransfer(t *testing.T) {
+	ctx := context.Background()
+	store := instrumentStore(NewMemoryStore(), 0)
+
+	from, err := NewAccount("Alice", "Test", "pw")
+	require.Nil(t, err)
+	to, err := NewAccount("Bob", "Test", "pw")
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAccount(ctx, from))
+	require.Nil(t, store.CreateAccount(ctx, to))
+
+	_, err = store.Deposit(ctx, from.Number, 500, 0)
+	require.Nil(t, err)
+
+	transfers := testutil.ToFloat64(transfersTotal.WithLabelValues(from.Currency))
+	amount := testutil.ToFloat64(transferAmountTotal.WithLabelValues(from.Currency))
+	failures := testutil.ToFloat64(transferFailuresTotal.WithLabelValues(string(ErrorCodeInsufficientFunds)))
+
+	transfer, err := newTransfer(ctx, store, nil, from.Number, to.Number, 300)
+	require.Nil(t, err)
+	require.Nil(t, store.Transfer(ctx, transfer))
+
+	transfer, err = newTransfer(ctx, store, nil, from.Number, to.Number, 300)
+	require.Nil(t, err)
+	require.NotNil(t, store.Transfer(ctx, transfer))
+
+	assert.Equal(t, transfers+1, testutil.ToFloat64(transfersTotal.WithLabelValues(from.Currency)))
+	assert.Equal(t, amount+300, testutil.ToFloat64(transferAmountTotal.WithLabelValues(from.Currency)))
+	assert.Equal(t, failures+1, testutil.ToFloat64(transferFailuresTotal.WithLabelValues(string(ErrorCodeInsufficientFunds))))
+	assert.Greater(t, testutil.CollectAndCount(storeQueryDuration), 0)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware wraps a handler with behaviour shared by many routes. It is an
+// alias so that mux.Router.Use takes it as is.
+type Middleware = func(http.Handler) http.Handler
+
+// Chain is a list of middlewares applied in order: the first one sees the
+// request first and the response last.
+type Chain []Middleware
+
+// Use returns a new chain running m after the middlewares of c. c itself is
+// left untouched, so chains can be extended from a shared base.
+func (c Chain) Use(m ...Middleware) Chain {
+	return append(c[:len(c):len(c)], m...)
+}
+
+// Then wraps h in the middlewares of c.
+func (c Chain) Then(h http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+
+	return h
+}
+
+// ThenFunc wraps the API handler f in the middlewares of c.
+func (c Chain) ThenFunc(f APIFunc) http.Handler {
+	return c.Then(makeHttpHandleFunc(f))
+}
+
+// routeGroup registers routes that share a chain of middlewares, such as
+// the ones only an account's holder may call. The middlewares of the router
+// still run first.
+type routeGroup struct {
+	router *mux.Router
+	chain  Chain
+}
+
+// With returns a group that runs m after the middlewares of g.
+func (g routeGroup) With(m ...Middleware) routeGroup {
+	return routeGroup{router: g.router, chain: g.chain.Use(m...)}
+}
+
+// Handle serves path with f, wrapped in the middlewares of g and then m.
+func (g routeGroup) Handle(path string, f APIFunc, m ...Middleware) {
+	g.router.Handle(path, g.chain.Use(m...).ThenFunc(f))
+}
+
+type contextKey string
+
+const requestIDKey contextKey = "requestID"
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// withLogging writes one structured log line per request once it completes.
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := acceptRequestID(r.Header.Get("X-Request-ID"))
+
+		w.Header().Set("X-Request-ID", requestID)
+		r = r.WithContext(contextWithRequestID(r.Context(), requestID))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		attrs := []any{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", time.Since(start)),
+		}
+
+		if number, err := getAccountNumberFromToken(r); err == nil {
+			attrs = append(attrs, slog.Int64("accountNumber", number))
+		}
+
+		slog.InfoContext(r.Context(), "request", attrs...)
+	})
+}
+
+// responseStartedRecorder notes whether the handler has started its response.
+type responseStartedRecorder struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (rec *responseStartedRecorder) WriteHeader(status int) {
+	rec.started = true
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseStartedRecorder) Write(b []byte) (int, error) {
+	rec.started = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (rec *responseStartedRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// withRecovery turns a panicking handler into a 500 internal_error instead
+// of a dropped connection, logging the panic and its stack with the request
+// ID. Nothing of the panic reaches the client. A handler that panics after
+// starting its response can't be answered anymore, so that connection is
+// aborted. http.ErrAbortHandler is left alone, it is how a handler aborts a
+// response on purpose.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseStartedRecorder{ResponseWriter: w}
+
+		defer func() {
+			recovered := recover()
+
+			if recovered == nil {
+				return
+			}
+
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			route := "unmatched"
+
+			if current := mux.CurrentRoute(r); current != nil {
+				if tmpl, err := current.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+
+			httpPanicsTotal.WithLabelValues(r.Method, route).Inc()
+			slog.ErrorContext(r.Context(), "handler panicked", "panic", recovered, "method", r.Method, "path", r.URL.Path, "stack", string(debug.Stack()))
+
+			if rec.started {
+				panic(http.ErrAbortHandler)
+			}
+
+			httpErr := newHTTPError(http.StatusInternalServerError, ErrorCodeInternal, "internal server error")
+			writeJSON(w, httpErr.Status, errorResponse(r, httpErr, requestIDFromContext(r.Context())))
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// untimedRoutes are the path templates that may outlive the request
+// timeout: event streams stay open until the client leaves, and archives of
+// the whole bank and ledger exports take as long as they take.
+var untimedRoutes = []string{"/account/{id}/stream", "/account/{id}/transactions/export", "/admin/export", "/admin/import"}
+
+// withTimeout cancels the request context once timeout has passed, which
+// cancels the queries the handler is running; writeError then reports the
+// request as timed out. Requests are cancelled just the same when the client
+// disconnects.
+func withTimeout(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := mux.CurrentRoute(r); route != nil {
+				template, _ := route.GetPathTemplate()
+
+				for _, untimed := range untimedRoutes {
+					if strings.HasSuffix(template, untimed) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// maxRequestIDLength bounds the request IDs clients may send.
+const maxRequestIDLength = 64
+
+// acceptRequestID returns the request ID a client sent, so its logs and ours
+// can be correlated, or a new one when it sent none or one that is too long
+// or has characters other than letters, digits, '.', '_' and '-'.
+func acceptRequestID(requestID string) string {
+	if requestID == "" || len(requestID) > maxRequestIDLength || strings.IndexFunc(requestID, invalidRequestIDRune) >= 0 {
+		return newRequestID()
+	}
+
+	return requestID
+}
+
+func invalidRequestIDRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-')
+}
+
+// contextWithRequestID carries requestID down to the logs, audit entries,
+// events and queries of the request.
+func contextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// requestIDHandler adds the request ID of the context to every record
+// logged with one, e.g. with slog.InfoContext.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := requestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("requestId", requestID))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name+" in")
+				next.ServeHTTP(w, r)
+				order = append(order, name+" out")
+			})
+		}
+	}
+
+	base := Chain{trace("a")}
+	chain := base.Use(trace("b"))
+	other := base.Use(trace("c"))
+
+	rec := httptest.NewRecorder()
+	chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) error {
+		order = append(order, "handler")
+		return nil
+	}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, []string{"a in", "b in", "handler", "b out", "a out"}, order)
+	assert.Len(t, base, 1, "Use leaves the base chain alone")
+	assert.Len(t, other, 2)
+}
+
+func TestWithRecovery(t *testing.T) {
+	handler := withRecovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"internal_error"`)
+	assert.NotContains(t, rec.Body.String(), "boom")
+
+	for _, handler := range []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) },
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{"))
+			panic("boom")
+		},
+	} {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			withRecovery(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}, "a started response is aborted")
+	}
+}
+
+func TestAcceptRequestID(t *testing.T) {
+	assert.Equal(t, "req-42_a.b", acceptRequestID("req-42_a.b"))
+
+	for _, requestID := range []string{"", strings.Repeat("a", maxRequestIDLength+1), "bad id", "bad\nid"} {
+		accepted := acceptRequestID(requestID)
+		assert.NotEqual(t, requestID, accepted)
+		assert.Len(t, accepted, 16, "%q is replaced with a new id", requestID)
+	}
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+
+	send := func(method, path, requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("x-jwt-token", token)
+		req.Header.Set("X-Request-ID", requestID)
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := send("GET", "/account/9999999999", "trace-1")
+	assert.Equal(t, "trace-1", rec.Header().Get("X-Request-ID"))
+
+	var body struct {
+		RequestID string `json:"requestId"`
+	}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "trace-1", body.RequestID)
+
+	rec = send("DELETE", fmt.Sprintf("/account/%d/sessions", alice.ID), "trace-2")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	entries, err := api.store.GetAuditLog(context.Background(), 1, 0)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditSessionsRevoked, entries[0].Action)
+	assert.Equal(t, "trace-2", entries[0].RequestID)
+}
+
+func TestRequestIDHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(requestIDHandler{slog.NewJSONHandler(&buf, nil)})
+
+	logger.InfoContext(contextWithRequestID(context.Background(), "trace-1"), "slow query")
+	logger.InfoContext(context.Background(), "background job")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"requestId":"trace-1"`)
+	assert.NotContains(t, lines[1], "requestId")
+}
+
+func TestEventRequestID(t *testing.T) {
+	event := &Event{Type: EventBalanceLow, AccountNumber: 42}
+	publishers{}.Publish(contextWithRequestID(context.Background(), "trace-1"), event)
+
+	payload, err := json.Marshal(event)
+	require.Nil(t, err)
+	assert.Contains(t, string(payload), `"requestId":"trace-1"`)
+}
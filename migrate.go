@@ -0,0 +1,92 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change, read from a pair of
+// migrations/<version>_<name>.up.sql and .down.sql files.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// loadMigrations reads every migration in fsys, sorted by version. Versions
+// must be unique and every migration needs an up file.
+func loadMigrations(fsys fs.FS) ([]*Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+
+		prefix, name, ok := strings.Cut(base, "_")
+
+		if !ok || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("invalid migration file name %s", file)
+		}
+
+		version, err := strconv.Atoi(prefix)
+
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", file)
+		}
+
+		body, err := fs.ReadFile(fsys, file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, name)
+		}
+
+		if direction == ".up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_b.up.sql":   {Data: []byte("create table b ();")},
+		"migrations/0001_add_a.up.sql":   {Data: []byte("create table a ();")},
+		"migrations/0001_add_a.down.sql": {Data: []byte("drop table a;")},
+	}
+
+	migrations, err := loadMigrations(fsys)
+
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, &Migration{Version: 1, Name: "add_a", Up: "create table a ();", Down: "drop table a;"}, migrations[0])
+	assert.Equal(t, 2, migrations[1].Version)
+	assert.Empty(t, migrations[1].Down)
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	for name, file := range map[string]string{
+		"no version":   "migrations/add_a.up.sql",
+		"no direction": "migrations/0001_add_a.sql",
+		"no up":        "migrations/0001_add_a.down.sql",
+	} {
+		_, err := loadMigrations(fstest.MapFS{file: {Data: []byte("select 1;")}})
+
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+
+	assert.Nil(t, err)
+
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Down, m.Name)
+	}
+}
@@ -0,0 +1,22 @@
+create table if not exists account (
+	id serial primary key,
+	first_name varchar(50),
+	last_name varchar(50),
+	number serial,
+	encrypted_password varchar(100),
+	balance bigint not null default 0,
+	currency varchar(3) not null default 'USD',
+	role varchar(20) not null default 'customer',
+	overdraft_limit bigint not null default 0,
+	minimum_balance bigint not null default 0,
+	overdraft_fee bigint not null default 0,
+	created_at timestamp
+);
+
+-- databases created before migrations were introduced may lack these
+alter table account alter column balance type bigint;
+alter table account add column if not exists currency varchar(3) not null default 'USD';
+alter table account add column if not exists role varchar(20) not null default 'customer';
+alter table account add column if not exists overdraft_limit bigint not null default 0;
+alter table account add column if not exists minimum_balance bigint not null default 0;
+alter table account add column if not exists overdraft_fee bigint not null default 0;
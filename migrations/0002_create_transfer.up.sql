@@ -0,0 +1,17 @@
+create table if not exists transfer (
+	id serial primary key,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null default 'USD',
+	to_amount bigint,
+	to_currency varchar(3) not null default 'USD',
+	rate varchar(32),
+	created_at timestamp
+);
+
+-- databases created before migrations were introduced may lack these
+alter table transfer add column if not exists currency varchar(3) not null default 'USD';
+alter table transfer add column if not exists to_amount bigint;
+alter table transfer add column if not exists to_currency varchar(3) not null default 'USD';
+alter table transfer add column if not exists rate varchar(32);
@@ -0,0 +1,11 @@
+create table if not exists transactions (
+	id serial primary key,
+	account_number bigint not null,
+	type varchar(20) not null,
+	amount bigint not null,
+	balance bigint not null,
+	counterparty bigint,
+	created_at timestamp
+);
+
+create index if not exists transactions_account_number_idx on transactions (account_number, id);
@@ -0,0 +1,8 @@
+create table if not exists refresh_token (
+	id serial primary key,
+	account_number bigint not null,
+	token_hash varchar(64) not null unique,
+	expires_at timestamp not null,
+	revoked_at timestamp,
+	created_at timestamp
+);
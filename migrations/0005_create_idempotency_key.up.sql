@@ -0,0 +1,10 @@
+create table if not exists idempotency_key (
+	key varchar(255) not null,
+	scope varchar(255) not null,
+	request_hash varchar(64) not null,
+	status varchar(20) not null,
+	status_code int,
+	response_body bytea,
+	created_at timestamp,
+	primary key (key, scope)
+);
@@ -0,0 +1,25 @@
+create table if not exists scheduled_transfer (
+	id serial primary key,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	recurrence varchar(20) not null,
+	status varchar(20) not null,
+	start_at timestamp not null,
+	next_run_at timestamp not null,
+	occurrence int not null default 0,
+	attempts int not null default 0,
+	last_error text not null default '',
+	locked_until timestamp,
+	created_at timestamp
+);
+
+create index if not exists scheduled_transfer_due_idx on scheduled_transfer (status, next_run_at);
+
+create table if not exists scheduled_transfer_run (
+	id serial primary key,
+	scheduled_transfer_id int not null references scheduled_transfer (id),
+	transfer_id int,
+	error text not null default '',
+	ran_at timestamp not null
+);
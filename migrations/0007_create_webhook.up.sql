@@ -0,0 +1,28 @@
+create table if not exists webhook (
+	id serial primary key,
+	account_number bigint,
+	url text not null,
+	secret varchar(100) not null,
+	events text[] not null,
+	low_balance_threshold bigint not null default 0,
+	active boolean not null default true,
+	created_at timestamp
+);
+
+create table if not exists webhook_delivery (
+	id serial primary key,
+	webhook_id int not null references webhook (id),
+	event_id varchar(50) not null,
+	event_type varchar(50) not null,
+	payload bytea not null,
+	status varchar(20) not null,
+	attempts int not null default 0,
+	next_attempt_at timestamp not null,
+	response_code int,
+	last_error text not null default '',
+	locked_until timestamp,
+	delivered_at timestamp,
+	created_at timestamp
+);
+
+create index if not exists webhook_delivery_due_idx on webhook_delivery (status, next_attempt_at);
@@ -0,0 +1,4 @@
+alter table account drop column interest_accrued_through;
+alter table account drop column interest_remainder;
+alter table account drop column accrued_interest;
+alter table account drop column type;
@@ -0,0 +1,4 @@
+alter table account add column type varchar(20) not null default 'checking';
+alter table account add column accrued_interest bigint not null default 0;
+alter table account add column interest_remainder bigint not null default 0;
+alter table account add column interest_accrued_through date;
@@ -0,0 +1 @@
+alter table account add column status varchar(20) not null default 'active';
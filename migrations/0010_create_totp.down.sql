@@ -0,0 +1,2 @@
+drop table if exists totp_backup_code;
+drop table if exists account_totp;
@@ -0,0 +1,15 @@
+create table if not exists account_totp (
+	account_number bigint primary key,
+	secret varchar(64) not null,
+	enabled_at timestamp,
+	created_at timestamp not null
+);
+
+create table if not exists totp_backup_code (
+	id serial primary key,
+	account_number bigint not null,
+	code_hash varchar(64) not null,
+	used_at timestamp
+);
+
+create index if not exists totp_backup_code_account_number_idx on totp_backup_code (account_number);
@@ -0,0 +1,3 @@
+alter table transactions drop column journal_id;
+drop table if exists journal_line;
+drop table if exists journal_entry;
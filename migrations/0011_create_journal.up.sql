@@ -0,0 +1,40 @@
+create table if not exists journal_entry (
+	id serial primary key,
+	kind varchar(20) not null,
+	created_at timestamp not null
+);
+
+create table if not exists journal_line (
+	id serial primary key,
+	entry_id integer not null references journal_entry (id),
+	ledger varchar(20) not null,
+	account_number bigint,
+	currency varchar(3) not null,
+	amount bigint not null
+);
+
+create index if not exists journal_line_entry_id_idx on journal_line (entry_id);
+create index if not exists journal_line_account_number_idx on journal_line (account_number) where ledger = 'customer';
+
+alter table transactions add column journal_id integer references journal_entry (id);
+
+-- every entry recorded before the ledger was double-entry becomes a journal
+-- entry balanced against the opening book
+insert into journal_entry (id, kind, created_at)
+select id, 'opening', coalesce(created_at, now() at time zone 'utc') from transactions;
+
+select setval(pg_get_serial_sequence('journal_entry', 'id'), coalesce(max(id), 0) + 1, false) from journal_entry;
+
+insert into journal_line (entry_id, ledger, account_number, currency, amount)
+select t.id, 'customer', t.account_number, c.currency, t.amount
+from transactions t
+cross join lateral (
+	select coalesce((select currency from account where number = t.account_number limit 1), 'USD') as currency
+) c;
+
+insert into journal_line (entry_id, ledger, currency, amount)
+select entry_id, 'opening', currency, -amount from journal_line where ledger = 'customer';
+
+update transactions set journal_id = id;
+
+alter table transactions alter column journal_id set not null;
@@ -0,0 +1,3 @@
+drop table if exists hold;
+
+alter table account drop column held_balance;
@@ -0,0 +1,18 @@
+alter table account add column held_balance bigint not null default 0;
+
+create table if not exists hold (
+	id serial primary key,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	to_amount bigint not null,
+	to_currency varchar(3) not null,
+	rate varchar(32) not null default '',
+	status varchar(20) not null,
+	transfer_id int references transfer (id),
+	expires_at timestamp not null,
+	created_at timestamp not null
+);
+
+create index if not exists hold_expiry_idx on hold (status, expires_at);
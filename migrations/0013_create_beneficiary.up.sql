@@ -0,0 +1,10 @@
+create table if not exists beneficiary (
+	id serial primary key,
+	owner bigint not null,
+	name varchar(50) not null,
+	account_number bigint not null,
+	nickname varchar(50) not null default '',
+	cooling_off_until timestamp not null,
+	created_at timestamp not null,
+	unique (owner, account_number)
+);
@@ -0,0 +1,2 @@
+drop table if exists audit_log;
+drop function if exists audit_log_immutable();
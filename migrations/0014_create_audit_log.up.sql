@@ -0,0 +1,20 @@
+create table if not exists audit_log (
+	id bigserial primary key,
+	action varchar(50) not null,
+	actor bigint,
+	account_number bigint not null,
+	ip varchar(45) not null,
+	before jsonb,
+	after jsonb,
+	created_at timestamp not null
+);
+
+create or replace function audit_log_immutable() returns trigger as $$
+begin
+	raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+create trigger audit_log_immutable
+before update or delete on audit_log
+for each row execute function audit_log_immutable();
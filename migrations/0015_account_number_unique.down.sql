@@ -0,0 +1,2 @@
+alter table account drop constraint if exists account_number_key;
+alter table account alter column number drop not null;
@@ -0,0 +1,6 @@
+-- numbers were a serial, now they are issued with a check digit
+alter table account alter column number drop default;
+alter table account alter column number type bigint;
+alter table account alter column number set not null;
+alter table account add constraint account_number_key unique (number);
+drop sequence if exists account_number_seq;
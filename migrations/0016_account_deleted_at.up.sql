@@ -0,0 +1 @@
+alter table account add column if not exists deleted_at timestamp;
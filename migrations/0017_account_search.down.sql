@@ -0,0 +1,4 @@
+drop index if exists account_number_trgm;
+drop index if exists account_last_name_trgm;
+drop index if exists account_first_name_trgm;
+drop index if exists account_full_name_trgm;
@@ -0,0 +1,7 @@
+create extension if not exists pg_trgm;
+
+-- back the ilike and % filters of GET /account/search
+create index if not exists account_full_name_trgm on account using gin ((first_name || ' ' || last_name) gin_trgm_ops);
+create index if not exists account_first_name_trgm on account using gin (first_name gin_trgm_ops);
+create index if not exists account_last_name_trgm on account using gin (last_name gin_trgm_ops);
+create index if not exists account_number_trgm on account using gin ((number::text) gin_trgm_ops);
@@ -0,0 +1,2 @@
+drop index if exists transactions_account_number_created_at_idx;
+alter table transactions drop column if exists category;
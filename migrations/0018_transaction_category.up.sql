@@ -0,0 +1,4 @@
+alter table transactions add column if not exists category varchar(30);
+
+-- backs the per-month aggregation of GET /account/{id}/analytics
+create index if not exists transactions_account_number_created_at_idx on transactions (account_number, created_at);
@@ -0,0 +1,8 @@
+create table if not exists external_identity (
+	issuer varchar(255) not null,
+	subject varchar(255) not null,
+	account_number bigint not null,
+	created_at timestamp not null,
+	primary key (issuer, subject),
+	unique (issuer, account_number)
+);
@@ -0,0 +1,2 @@
+drop table if exists account_event;
+drop function if exists account_event_immutable();
@@ -0,0 +1,43 @@
+create table if not exists account_event (
+	id bigserial primary key,
+	account_number bigint not null,
+	version integer not null,
+	type varchar(30) not null,
+	data jsonb not null,
+	created_at timestamp not null,
+	unique (account_number, version)
+);
+
+create or replace function account_event_immutable() returns trigger as $$
+begin
+	raise exception 'account_event is append-only';
+end;
+$$ language plpgsql;
+
+create trigger account_event_immutable
+before update or delete on account_event
+for each row execute function account_event_immutable();
+
+-- the history so far becomes events: the part of each balance that predates
+-- the ledger is the opening balance, then one event per ledger entry
+insert into account_event (account_number, version, type, data, created_at)
+select a.number, 1, 'AccountOpened',
+	jsonb_build_object('amount', a.balance - coalesce((select sum(t.amount) from transactions t where t.account_number = a.number), 0), 'currency', a.currency),
+	coalesce(a.created_at, now() at time zone 'utc')
+from account a;
+
+insert into account_event (account_number, version, type, data, created_at)
+select t.account_number,
+	1 + row_number() over (partition by t.account_number order by t.id),
+	case t.type
+		when 'deposit' then 'MoneyDeposited'
+		when 'withdrawal' then 'MoneyWithdrawn'
+		when 'transfer_out' then 'TransferSent'
+		when 'transfer_in' then 'TransferReceived'
+		when 'fee' then 'FeeCharged'
+		when 'interest' then 'InterestPaid'
+	end,
+	jsonb_strip_nulls(jsonb_build_object('amount', t.amount, 'counterparty', t.counterparty)),
+	coalesce(t.created_at, now() at time zone 'utc')
+from transactions t
+where exists (select 1 from account a where a.number = t.account_number);
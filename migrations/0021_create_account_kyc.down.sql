@@ -0,0 +1,2 @@
+drop table if exists account_kyc;
+alter table account drop column if exists kyc_status;
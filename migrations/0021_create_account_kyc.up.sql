@@ -0,0 +1,13 @@
+-- accounts opened before KYC onboarding keep transferring as before
+alter table account add column if not exists kyc_status varchar(10) not null default 'verified';
+alter table account alter column kyc_status set default 'pending';
+
+create table if not exists account_kyc (
+	account_number bigint primary key,
+	date_of_birth date not null,
+	national_id varchar(30) not null,
+	address text not null,
+	rejection_reason text,
+	submitted_at timestamp not null,
+	reviewed_at timestamp
+);
@@ -0,0 +1,3 @@
+drop table if exists transfer_approval;
+drop table if exists account_owner;
+alter table account drop column if exists dual_approval_amount;
@@ -0,0 +1,26 @@
+alter table account add column if not exists dual_approval_amount bigint not null default 0;
+
+create table if not exists account_owner (
+	account_number bigint not null references account (number),
+	owner_number bigint not null references account (number),
+	created_at timestamp not null,
+	primary key (account_number, owner_number)
+);
+
+create index if not exists account_owner_owner_number_idx on account_owner (owner_number);
+
+create table if not exists transfer_approval (
+	id serial primary key,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	requested_by bigint not null,
+	decided_by bigint,
+	status varchar(10) not null,
+	transfer_id integer references transfer (id),
+	error text,
+	created_at timestamp not null,
+	decided_at timestamp
+);
+
+create index if not exists transfer_approval_from_account_idx on transfer_approval (from_account, id);
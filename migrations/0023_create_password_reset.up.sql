@@ -0,0 +1,10 @@
+create table if not exists password_reset (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	token_hash varchar(64) not null unique,
+	expires_at timestamp not null,
+	used_at timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists password_reset_account_number_idx on password_reset (account_number);
@@ -0,0 +1,2 @@
+drop table if exists pot;
+alter table account drop column if exists pot_balance;
@@ -0,0 +1,16 @@
+alter table account add column if not exists pot_balance bigint not null default 0;
+
+create table if not exists pot (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	name varchar(255) not null,
+	target_amount bigint not null,
+	balance bigint not null default 0,
+	round_up boolean not null default false,
+	weekly_amount bigint not null default 0,
+	next_sweep_at timestamp,
+	created_at timestamp not null,
+	unique (account_number, name)
+);
+
+create index if not exists pot_next_sweep_at_idx on pot (next_sweep_at) where next_sweep_at is not null;
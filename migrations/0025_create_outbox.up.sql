@@ -0,0 +1,15 @@
+create table if not exists outbox (
+	id bigserial primary key,
+	message_id varchar(50) not null unique,
+	topic varchar(50) not null,
+	message_key varchar(50) not null,
+	payload bytea not null,
+	attempts int not null default 0,
+	next_attempt_at timestamp not null,
+	last_error text not null default '',
+	locked_until timestamp,
+	published_at timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists outbox_unpublished_idx on outbox (id) where published_at is null;
@@ -0,0 +1,3 @@
+drop index if exists account_balance_idx;
+drop index if exists audit_log_action_created_at_idx;
+drop index if exists transfer_created_at_idx;
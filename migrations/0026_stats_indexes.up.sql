@@ -0,0 +1,3 @@
+create index if not exists transfer_created_at_idx on transfer (created_at);
+create index if not exists audit_log_action_created_at_idx on audit_log (action, created_at);
+create index if not exists account_balance_idx on account (balance desc, id) where deleted_at is null;
@@ -0,0 +1,2 @@
+drop table if exists standing_order;
+drop table if exists holiday;
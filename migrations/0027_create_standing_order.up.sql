@@ -0,0 +1,23 @@
+create table if not exists holiday (
+	date date primary key,
+	name varchar(100) not null
+);
+
+create table if not exists standing_order (
+	id serial primary key,
+	from_account bigint not null references account (number),
+	to_account bigint not null,
+	amount bigint not null,
+	frequency varchar(20) not null,
+	status varchar(20) not null,
+	start_date timestamp not null,
+	next_run_at timestamp not null,
+	occurrence int not null default 0,
+	failures int not null default 0,
+	last_error text not null default '',
+	locked_until timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists standing_order_due_idx on standing_order (status, next_run_at);
+create index if not exists standing_order_from_account_idx on standing_order (from_account);
@@ -0,0 +1 @@
+drop index if exists transactions_account_number_created_at_id_idx;
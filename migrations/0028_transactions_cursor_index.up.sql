@@ -0,0 +1 @@
+create index if not exists transactions_account_number_created_at_id_idx on transactions (account_number, created_at desc, id desc);
@@ -0,0 +1,2 @@
+drop table if exists transfer_batch_item;
+drop table if exists transfer_batch;
@@ -0,0 +1,20 @@
+create table if not exists transfer_batch (
+	id serial primary key,
+	from_account bigint not null references account (number),
+	mode varchar(20) not null,
+	created_at timestamp not null
+);
+
+create table if not exists transfer_batch_item (
+	batch_id int not null references transfer_batch (id),
+	position int not null,
+	to_account bigint not null,
+	amount bigint not null,
+	status varchar(20) not null,
+	transfer_id int references transfer (id),
+	error_code varchar(50) not null default '',
+	error text not null default '',
+	primary key (batch_id, position)
+);
+
+create index if not exists transfer_batch_from_account_idx on transfer_batch (from_account);
@@ -0,0 +1,3 @@
+drop trigger if exists account_version_bump on account;
+drop function if exists account_version_bump();
+alter table account drop column if exists version;
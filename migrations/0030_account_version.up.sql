@@ -0,0 +1,13 @@
+alter table account add column if not exists version integer not null default 1;
+
+-- every change to an account makes a new version, whichever query makes it
+create or replace function account_version_bump() returns trigger as $$
+begin
+	new.version := old.version + 1;
+	return new;
+end;
+$$ language plpgsql;
+
+create trigger account_version_bump
+before update on account
+for each row execute function account_version_bump();
@@ -0,0 +1,3 @@
+drop index if exists transfer_from_account_idx;
+drop table if exists login_network;
+drop table if exists fraud_review;
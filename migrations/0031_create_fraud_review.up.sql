@@ -0,0 +1,27 @@
+create table if not exists fraud_review (
+	id serial primary key,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	ip varchar(45) not null default '',
+	rules text not null,
+	action varchar(10) not null,
+	transfer_id integer references transfer (id),
+	status varchar(10) not null,
+	reviewed_by bigint,
+	created_at timestamp not null,
+	reviewed_at timestamp
+);
+
+create index if not exists fraud_review_status_idx on fraud_review (status, id);
+
+create table if not exists login_network (
+	account_number bigint not null references account (number),
+	network varchar(50) not null,
+	first_seen_at timestamp not null,
+	last_seen_at timestamp not null,
+	primary key (account_number, network)
+);
+
+create index if not exists transfer_from_account_idx on transfer (from_account, created_at);
@@ -0,0 +1,20 @@
+create table if not exists dispute (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	transaction_id integer not null references transactions (id),
+	amount bigint not null,
+	reason text not null,
+	status varchar(10) not null,
+	reversed_amount bigint not null default 0,
+	reversal_journal_id integer references journal_entry (id),
+	decided_by bigint,
+	note text not null default '',
+	created_at timestamp not null,
+	decided_at timestamp
+);
+
+-- a transaction has at most one open dispute at a time
+create unique index if not exists dispute_open_idx on dispute (transaction_id) where status = 'open';
+create index if not exists dispute_transaction_id_idx on dispute (transaction_id);
+create index if not exists dispute_account_number_idx on dispute (account_number, id);
+create index if not exists dispute_status_idx on dispute (status, id);
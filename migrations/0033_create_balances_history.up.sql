@@ -0,0 +1,8 @@
+create table if not exists balances_history (
+	account_number bigint not null references account (number),
+	day date not null,
+	balance bigint not null,
+	primary key (account_number, day)
+);
+
+create index if not exists balances_history_day_idx on balances_history (day);
@@ -0,0 +1,2 @@
+drop table if exists card_authorization;
+drop table if exists card;
@@ -0,0 +1,27 @@
+create table if not exists card (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	masked_pan varchar(19) not null,
+	pan_hash varchar(64) not null unique,
+	expiry_month integer not null,
+	expiry_year integer not null,
+	status varchar(10) not null,
+	created_at timestamp not null
+);
+
+create index if not exists card_account_number_idx on card (account_number, id);
+
+create table if not exists card_authorization (
+	id serial primary key,
+	card_id integer not null references card (id),
+	account_number bigint not null references account (number),
+	amount bigint not null,
+	currency varchar(3) not null,
+	merchant varchar(100) not null default '',
+	status varchar(10) not null,
+	decline_reason varchar(20) not null default '',
+	expires_at timestamp not null,
+	created_at timestamp not null
+);
+
+create index if not exists card_authorization_expiry_idx on card_authorization (status, expires_at);
@@ -0,0 +1,22 @@
+create table if not exists external_transfer (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	scheme varchar(10) not null,
+	beneficiary_name varchar(50) not null,
+	destination varchar(40) not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	reference varchar(140) not null default '',
+	status varchar(10) not null,
+	return_code varchar(4) not null default '',
+	return_reason text not null default '',
+	journal_id integer references journal_entry (id),
+	return_journal_id integer references journal_entry (id),
+	created_at timestamp not null,
+	submitted_at timestamp,
+	settled_at timestamp,
+	returned_at timestamp
+);
+
+create index if not exists external_transfer_account_number_idx on external_transfer (account_number, id);
+create index if not exists external_transfer_status_idx on external_transfer (status, created_at);
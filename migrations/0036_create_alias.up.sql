@@ -0,0 +1,14 @@
+create table if not exists alias (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	type varchar(10) not null,
+	value varchar(254) not null,
+	code_hash varchar(64) not null,
+	code_expires_at timestamp not null,
+	attempts integer not null default 0,
+	verified_at timestamp,
+	created_at timestamp not null,
+	unique (account_number, value)
+);
+
+create unique index if not exists alias_verified_value_idx on alias (value) where verified_at is not null;
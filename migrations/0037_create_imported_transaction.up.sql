@@ -0,0 +1,12 @@
+create table if not exists imported_transaction (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	transaction_id integer not null references transactions (id),
+	fingerprint varchar(64) not null,
+	external_id varchar(255) not null default '',
+	posted_on date not null,
+	amount bigint not null,
+	description varchar(140) not null default '',
+	created_at timestamp not null,
+	unique (account_number, fingerprint)
+);
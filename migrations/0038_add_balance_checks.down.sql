@@ -0,0 +1,2 @@
+alter table account drop constraint if exists account_pot_balance_nonnegative;
+alter table pot drop constraint if exists pot_balance_nonnegative;
@@ -0,0 +1,3 @@
+-- a backstop for the application's checks, reported as insufficient funds
+alter table pot add constraint pot_balance_nonnegative check (balance >= 0);
+alter table account add constraint account_pot_balance_nonnegative check (pot_balance >= 0);
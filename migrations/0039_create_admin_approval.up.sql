@@ -0,0 +1,17 @@
+create table if not exists admin_approval (
+	id serial primary key,
+	action varchar(20) not null,
+	account_number bigint not null references account (number),
+	amount bigint not null default 0,
+	limits jsonb,
+	reason text not null default '',
+	proposed_by bigint not null,
+	decided_by bigint,
+	status varchar(10) not null,
+	transaction_id integer references transactions (id),
+	error text,
+	created_at timestamp not null,
+	decided_at timestamp
+);
+
+create index if not exists admin_approval_status_idx on admin_approval (status, id);
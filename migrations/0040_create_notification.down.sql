@@ -0,0 +1,2 @@
+drop table if exists notification_delivery;
+drop table if exists notification_preferences;
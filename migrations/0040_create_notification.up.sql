@@ -0,0 +1,31 @@
+create table if not exists notification_preferences (
+	account_number bigint primary key references account (number),
+	channels text[] not null,
+	email varchar(254) not null default '',
+	phone varchar(20) not null default '',
+	push_token varchar(4096) not null default '',
+	low_balance_threshold bigint not null default 0,
+	incoming_transfer boolean not null default false,
+	new_device_login boolean not null default false,
+	updated_at timestamp not null
+);
+
+create table if not exists notification_delivery (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	channel varchar(10) not null,
+	recipient varchar(4096) not null,
+	kind varchar(30) not null,
+	subject text not null,
+	body text not null,
+	status varchar(20) not null,
+	attempts int not null default 0,
+	next_attempt_at timestamp not null,
+	last_error text not null default '',
+	locked_until timestamp,
+	delivered_at timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists notification_delivery_due_idx on notification_delivery (status, next_attempt_at);
+create index if not exists notification_delivery_account_idx on notification_delivery (account_number, id);
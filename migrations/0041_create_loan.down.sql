@@ -0,0 +1,2 @@
+drop table if exists loan_installment;
+drop table if exists loan;
@@ -0,0 +1,32 @@
+create table if not exists loan (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	principal bigint not null check (principal > 0),
+	currency varchar(3) not null,
+	annual_rate varchar(10) not null,
+	term_months int not null,
+	monthly_payment bigint not null,
+	outstanding bigint not null,
+	arrears_amount bigint not null default 0,
+	status varchar(20) not null,
+	next_payment_at timestamp,
+	created_at timestamp not null,
+	paid_off_at timestamp
+);
+
+create index if not exists loan_account_number_idx on loan (account_number);
+create index if not exists loan_due_idx on loan (next_payment_at) where status <> 'paid_off';
+
+create table if not exists loan_installment (
+	loan_id int not null references loan (id),
+	number int not null,
+	due_date date not null,
+	payment bigint not null,
+	principal bigint not null,
+	interest bigint not null,
+	balance bigint not null,
+	status varchar(20) not null,
+	transaction_id integer references transactions (id),
+	paid_at timestamp,
+	primary key (loan_id, number)
+);
@@ -0,0 +1,14 @@
+create table if not exists transfer_quote (
+	id serial primary key,
+	from_account bigint not null references account (number),
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	to_amount bigint not null,
+	to_currency varchar(3) not null,
+	rate varchar(32) not null default '',
+	fee bigint not null,
+	expires_at timestamp not null,
+	created_at timestamp not null,
+	used_at timestamp
+);
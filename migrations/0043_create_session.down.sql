@@ -0,0 +1,5 @@
+drop table if exists token_denylist;
+
+alter table refresh_token drop column session_id;
+
+drop table if exists session;
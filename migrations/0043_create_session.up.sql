@@ -0,0 +1,21 @@
+create table if not exists session (
+	id serial primary key,
+	account_number bigint not null,
+	user_agent varchar(256) not null default '',
+	ip varchar(45) not null default '',
+	created_at timestamp not null,
+	last_seen_at timestamp not null,
+	revoked_at timestamp
+);
+
+create index if not exists session_account_number_idx on session (account_number);
+
+alter table refresh_token add column session_id int references session (id);
+
+create index if not exists refresh_token_session_id_idx on refresh_token (session_id);
+
+-- the access tokens of revoked sessions, until they expire
+create table if not exists token_denylist (
+	session_id int primary key references session (id),
+	expires_at timestamp not null
+);
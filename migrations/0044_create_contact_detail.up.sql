@@ -0,0 +1,11 @@
+create table if not exists contact_detail (
+	account_number bigint not null references account (number),
+	kind varchar(10) not null,
+	value varchar(254) not null,
+	code_hash varchar(64) not null,
+	code_expires_at timestamp not null,
+	attempts integer not null default 0,
+	verified_at timestamp,
+	updated_at timestamp not null,
+	primary key (account_number, kind)
+);
@@ -0,0 +1,2 @@
+alter table transactions drop column if exists description;
+alter table admin_approval drop column if exists reason_code;
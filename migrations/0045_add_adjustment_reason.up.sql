@@ -0,0 +1,2 @@
+alter table admin_approval add column if not exists reason_code varchar(30) not null default '';
+alter table transactions add column if not exists description text;
@@ -0,0 +1 @@
+alter table audit_log add column if not exists request_id varchar(64) not null default '';
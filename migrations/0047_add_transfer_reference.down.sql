@@ -0,0 +1,9 @@
+alter table transactions drop column if exists end_to_end_id;
+alter table transactions drop column if exists memo;
+alter table transactions drop column if exists reference;
+alter table transfer_approval drop column if exists end_to_end_id;
+alter table transfer_approval drop column if exists memo;
+alter table transfer_approval drop column if exists reference;
+alter table transfer drop column if exists end_to_end_id;
+alter table transfer drop column if exists memo;
+alter table transfer drop column if exists reference;
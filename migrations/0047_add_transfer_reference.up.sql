@@ -0,0 +1,9 @@
+alter table transfer add column if not exists reference varchar(35) not null default '';
+alter table transfer add column if not exists memo varchar(140) not null default '';
+alter table transfer add column if not exists end_to_end_id varchar(35) not null default '';
+alter table transfer_approval add column if not exists reference varchar(35) not null default '';
+alter table transfer_approval add column if not exists memo varchar(140) not null default '';
+alter table transfer_approval add column if not exists end_to_end_id varchar(35) not null default '';
+alter table transactions add column if not exists reference varchar(35) not null default '';
+alter table transactions add column if not exists memo varchar(140) not null default '';
+alter table transactions add column if not exists end_to_end_id varchar(35) not null default '';
@@ -0,0 +1,6 @@
+create table if not exists login_throttle (
+	key varchar(80) primary key,
+	failures integer not null,
+	last_failure_at timestamp not null,
+	locked_until timestamp
+);
@@ -0,0 +1,18 @@
+create table if not exists payment_request (
+	id serial primary key,
+	from_account bigint not null references account (number),
+	to_account bigint not null references account (number),
+	amount bigint not null,
+	currency varchar(3) not null,
+	status varchar(10) not null,
+	transfer_id integer references transfer (id),
+	reference varchar(35) not null default '',
+	memo varchar(140) not null default '',
+	end_to_end_id varchar(35) not null default '',
+	expires_at timestamp not null,
+	created_at timestamp not null,
+	decided_at timestamp
+);
+
+create index if not exists payment_request_from_account_idx on payment_request (from_account, id);
+create index if not exists payment_request_to_account_idx on payment_request (to_account, id);
@@ -0,0 +1,6 @@
+alter table transfer_approval drop column if exists decided_by_user;
+alter table transfer_approval drop column if exists requested_by_user;
+
+alter table session drop column if exists user_id;
+
+drop table if exists business_user;
@@ -0,0 +1,18 @@
+-- the users of business accounts, with logins of their own
+create table if not exists business_user (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	username varchar(50) not null,
+	encrypted_password varchar(100) not null,
+	role varchar(10) not null,
+	created_at timestamp not null,
+	removed_at timestamp
+);
+
+-- usernames are unique among an account's current users
+create unique index if not exists business_user_username_idx on business_user (account_number, username) where removed_at is null;
+
+alter table session add column if not exists user_id int references business_user (id);
+
+alter table transfer_approval add column if not exists requested_by_user int references business_user (id);
+alter table transfer_approval add column if not exists decided_by_user int references business_user (id);
@@ -0,0 +1 @@
+drop table if exists reconciliation_report;
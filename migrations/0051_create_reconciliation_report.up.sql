@@ -0,0 +1,11 @@
+create table if not exists reconciliation_report (
+	id serial primary key,
+	balanced boolean not null,
+	entries integer not null,
+	totals jsonb not null,
+	unbalanced_entries jsonb not null,
+	mismatched_accounts jsonb not null,
+	checked_at timestamp not null
+);
+
+create index if not exists reconciliation_report_checked_at_idx on reconciliation_report (checked_at);
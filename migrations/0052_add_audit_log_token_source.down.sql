@@ -0,0 +1 @@
+alter table audit_log drop column if exists token_source;
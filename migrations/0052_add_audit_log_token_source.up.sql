@@ -0,0 +1 @@
+alter table audit_log add column if not exists token_source varchar(20) not null default '';
@@ -0,0 +1,8 @@
+create table if not exists maintenance_state (
+	id serial primary key,
+	mode varchar(20) not null,
+	message text not null default '',
+	retry_after integer not null,
+	updated_by bigint,
+	updated_at timestamp not null
+);
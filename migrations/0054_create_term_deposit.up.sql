@@ -0,0 +1,17 @@
+create table if not exists term_deposit (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	principal bigint not null check (principal > 0),
+	currency varchar(3) not null,
+	annual_rate varchar(10) not null,
+	term_months int not null,
+	interest bigint not null,
+	penalty bigint not null default 0,
+	status varchar(20) not null,
+	matures_at timestamp not null,
+	created_at timestamp not null,
+	closed_at timestamp
+);
+
+create index if not exists term_deposit_account_number_idx on term_deposit (account_number);
+create index if not exists term_deposit_due_idx on term_deposit (matures_at) where status = 'active';
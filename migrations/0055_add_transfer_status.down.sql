@@ -0,0 +1,4 @@
+drop index if exists transfer_to_account_idx;
+alter table transfer drop column if exists reversed_at;
+alter table transfer drop column if exists failure_reason;
+alter table transfer drop column if exists status;
@@ -0,0 +1,6 @@
+-- transfers recorded so far all moved their money
+alter table transfer add column if not exists status varchar(16) not null default 'completed';
+alter table transfer add column if not exists failure_reason varchar(255) not null default '';
+alter table transfer add column if not exists reversed_at timestamp;
+
+create index if not exists transfer_to_account_idx on transfer (to_account, created_at);
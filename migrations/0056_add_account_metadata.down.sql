@@ -0,0 +1,2 @@
+drop index if exists account_metadata_idx;
+alter table account drop column if exists metadata;
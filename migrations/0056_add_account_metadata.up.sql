@@ -0,0 +1,4 @@
+alter table account add column if not exists metadata jsonb not null default '{}';
+
+-- serves the containment (@>) filter of GET /account
+create index if not exists account_metadata_idx on account using gin (metadata jsonb_path_ops);
@@ -0,0 +1,13 @@
+create table if not exists api_key (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	name varchar(100) not null,
+	scopes text[] not null,
+	prefix varchar(20) not null,
+	key_hash varchar(64) not null unique,
+	created_at timestamp not null,
+	last_used_at timestamp,
+	revoked_at timestamp
+);
+
+create index if not exists api_key_account_number_idx on api_key (account_number);
@@ -0,0 +1,2 @@
+drop table if exists aml_note;
+drop table if exists aml_flag;
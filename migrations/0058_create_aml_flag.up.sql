@@ -0,0 +1,27 @@
+create table if not exists aml_flag (
+	id serial primary key,
+	account_number bigint not null references account (number),
+	rule varchar(20) not null,
+	transaction_id integer not null references transactions (id),
+	transaction_ids integer[] not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	status varchar(20) not null,
+	reviewed_by bigint,
+	created_at timestamp not null,
+	updated_at timestamp not null,
+	unique (rule, transaction_id)
+);
+
+create index if not exists aml_flag_status_idx on aml_flag (status, id);
+create index if not exists aml_flag_account_number_idx on aml_flag (account_number, created_at);
+
+create table if not exists aml_note (
+	id serial primary key,
+	flag_id integer not null references aml_flag (id),
+	author bigint not null,
+	body text not null,
+	created_at timestamp not null
+);
+
+create index if not exists aml_note_flag_id_idx on aml_note (flag_id, id);
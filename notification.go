@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	notificationInterval    = 10 * time.Second
+	notificationBatchSize   = 50
+	notificationLease       = 2 * time.Minute
+	maxNotificationAttempts = 5
+
+	maxPushTokenLength = 4096
+)
+
+// NewNotificationPreferences are the preferences req saves for the number
+// account, with the email address and phone number normalized. req must
+// have passed NotificationPreferencesRequest.Validate.
+func NewNotificationPreferences(number int64, req *NotificationPreferencesRequest, now time.Time) *NotificationPreferences {
+	prefs := &NotificationPreferences{
+		AccountNumber:       number,
+		Channels:            req.Channels,
+		PushToken:           req.PushToken,
+		LowBalanceThreshold: req.LowBalanceThreshold,
+		IncomingTransfer:    req.IncomingTransfer,
+		NewDeviceLogin:      req.NewDeviceLogin,
+		UpdatedAt:           &now,
+	}
+
+	if prefs.Channels == nil {
+		prefs.Channels = []NotificationChannel{}
+	}
+
+	if req.Email != "" {
+		_, prefs.Email, _ = parseAlias(req.Email)
+	}
+
+	if req.Phone != "" {
+		_, prefs.Phone, _ = parseAlias(req.Phone)
+	}
+
+	return prefs
+}
+
+// destination is where channel notifications of the account go.
+func (p *NotificationPreferences) destination(channel NotificationChannel) string {
+	switch channel {
+	case NotificationEmail:
+		return p.Email
+	case NotificationSMS:
+		return p.Phone
+	case NotificationPush:
+		return p.PushToken
+	}
+
+	return ""
+}
+
+// NotificationDispatcher turns the published events holders asked to hear
+// about into deliveries on each of their channels, queued in the store, and
+// sends them in the background, retrying failures with exponential backoff.
+type NotificationDispatcher struct {
+	store     Storage
+	notifiers map[NotificationChannel]Notifier
+}
+
+func NewNotificationDispatcher(store Storage, notifiers map[NotificationChannel]Notifier) *NotificationDispatcher {
+	return &NotificationDispatcher{store: store, notifiers: notifiers}
+}
+
+func (d *NotificationDispatcher) Publish(ctx context.Context, event *Event) {
+	switch event.Type {
+	case EventBalanceLow, EventTransferCompleted, EventLoginNewDevice, EventLoginLocked, EventPaymentRequestCreated, EventPaymentRequestDeclined:
+	default:
+		return
+	}
+
+	prefs, err := d.store.GetNotificationPreferences(ctx, event.AccountNumber)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "loading notification preferences", "error", err, "account", event.AccountNumber)
+		return
+	}
+
+	kind, subject, body, ok := notificationFor(prefs, event)
+
+	if !ok || len(prefs.Channels) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	deliveries := make([]*NotificationDelivery, 0, len(prefs.Channels))
+
+	for _, channel := range prefs.Channels {
+		deliveries = append(deliveries, &NotificationDelivery{
+			AccountNumber: event.AccountNumber,
+			Channel:       channel,
+			To:            prefs.destination(channel),
+			Kind:          kind,
+			Subject:       subject,
+			Body:          body,
+			Status:        NotificationDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+
+	if err := d.store.EnqueueNotificationDeliveries(ctx, deliveries); err != nil {
+		slog.ErrorContext(ctx, "queueing notifications", "error", err, "account", event.AccountNumber, "event", event.Type)
+	}
+}
+
+// notificationFor returns the notification event warrants under prefs, if
+// any: a debit leaving the balance below the threshold, money received from
+// a transfer, or a login from a new device. Lockouts and payment requests
+// are always notified.
+func notificationFor(prefs *NotificationPreferences, event *Event) (NotificationKind, string, string, bool) {
+	switch data := event.Data.(type) {
+	case BalanceData:
+		if event.Type != EventBalanceLow || prefs.LowBalanceThreshold == 0 || data.Balance >= prefs.LowBalanceThreshold {
+			return "", "", "", false
+		}
+
+		body := fmt.Sprintf("Your balance is %s %s, below your alert threshold of %s %s.", formatAmount(data.Balance, data.Currency), data.Currency, formatAmount(prefs.LowBalanceThreshold, data.Currency), data.Currency)
+
+		return NotificationLowBalance, "Low balance", body, true
+	case *Transfer:
+		if event.Type != EventTransferCompleted || !prefs.IncomingTransfer || event.AccountNumber != data.ToAccount {
+			return "", "", "", false
+		}
+
+		body := fmt.Sprintf("You received %s %s from account %d.", formatAmount(data.ToAmount, data.ToCurrency), data.ToCurrency, data.FromAccount)
+
+		return NotificationIncomingTransfer, "Money received", body, true
+	case LoginData:
+		if event.Type != EventLoginNewDevice || !prefs.NewDeviceLogin {
+			return "", "", "", false
+		}
+
+		body := fmt.Sprintf("Your account was logged into from a new device at %s. If this wasn't you, change your password.", data.IP)
+
+		return NotificationNewDeviceLogin, "New device login", body, true
+	case LoginLockedData:
+		body := fmt.Sprintf("Your account was locked until %s after %d failed logins, the last from %s. If this wasn't you, change your password.", data.LockedUntil.Format(time.RFC1123), data.Failures, data.IP)
+
+		return NotificationLoginLocked, "Account locked", body, true
+	case *PaymentRequest:
+		amount := formatAmount(data.Amount, data.Currency) + " " + data.Currency
+
+		if event.Type == EventPaymentRequestDeclined {
+			return NotificationPaymentDeclined, "Payment request declined", fmt.Sprintf("Account %d declined your request for %s.", data.FromAccount, amount), true
+		}
+
+		if event.Type != EventPaymentRequestCreated {
+			return "", "", "", false
+		}
+
+		body := fmt.Sprintf("Account %d asks you to pay %s by %s.", data.ToAccount, amount, data.ExpiresAt.Format(time.RFC1123))
+
+		return NotificationPaymentRequest, "Payment requested", body, true
+	}
+
+	return "", "", "", false
+}
+
+func (d *NotificationDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(notificationInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *NotificationDispatcher) deliverDue(ctx context.Context, now time.Time) {
+	due, err := d.store.ClaimDueNotificationDeliveries(ctx, now, now.Add(notificationLease), notificationBatchSize)
+
+	if err != nil {
+		slog.Error("claiming notifications", "error", err)
+		return
+	}
+
+	for _, delivery := range due {
+		d.deliver(ctx, delivery, now)
+
+		if err := d.store.UpdateNotificationDelivery(ctx, delivery); err != nil {
+			slog.Error("recording notification delivery", "error", err, "deliveryId", delivery.ID)
+		}
+	}
+}
+
+func (d *NotificationDispatcher) deliver(ctx context.Context, delivery *NotificationDelivery, now time.Time) {
+	delivery.Attempts++
+
+	err := fmt.Errorf("no notifier for channel %s", delivery.Channel)
+
+	if notifier, ok := d.notifiers[delivery.Channel]; ok {
+		err = notifier.Notify(ctx, &Notification{
+			AccountNumber: delivery.AccountNumber,
+			Subject:       delivery.Subject,
+			Body:          delivery.Body,
+			To:            delivery.To,
+		})
+	}
+
+	if err == nil {
+		delivery.Status = NotificationDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+
+	if delivery.Attempts >= maxNotificationAttempts {
+		delivery.Status = NotificationDeliveryFailed
+		return
+	}
+
+	delivery.NextAttemptAt = now.Add(retryBackoff(delivery.Attempts))
+}
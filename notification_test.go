@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, n *Notification) error {
+	return errors.New("provider unavailable")
+}
+
+func TestNotificationPreferencesRequestValidate(t *testing.T) {
+	assert.Nil(t, (&NotificationPreferencesRequest{}).Validate())
+	assert.Nil(t, (&NotificationPreferencesRequest{Channels: []NotificationChannel{NotificationEmail, NotificationSMS}, Email: "alice@example.com", Phone: "+1 555 010 0000"}).Validate())
+	assert.Nil(t, (&NotificationPreferencesRequest{Channels: []NotificationChannel{NotificationPush}, PushToken: "device-token"}).Validate())
+
+	for _, req := range []*NotificationPreferencesRequest{
+		{Channels: []NotificationChannel{"pigeon"}},
+		{Channels: []NotificationChannel{NotificationEmail}},
+		{Channels: []NotificationChannel{NotificationEmail, NotificationEmail}, Email: "alice@example.com"},
+		{Channels: []NotificationChannel{NotificationSMS}, Phone: "alice@example.com"},
+		{Channels: []NotificationChannel{NotificationPush}},
+		{Email: "alice"},
+		{LowBalanceThreshold: -1},
+	} {
+		assert.NotNil(t, req.Validate(), req)
+	}
+}
+
+func TestNotificationDispatcher(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.Nil(t, store.CreateAccount(ctx, &Account{Number: 42, Currency: "USD", Status: AccountActive}))
+
+	sms := new(recordingNotifier)
+	dispatcher := NewNotificationDispatcher(store, map[NotificationChannel]Notifier{
+		NotificationEmail: failingNotifier{},
+		NotificationSMS:   sms,
+	})
+
+	now := time.Now().UTC()
+	req := &NotificationPreferencesRequest{
+		Channels:            []NotificationChannel{NotificationEmail, NotificationSMS},
+		Email:               "Alice@Example.com",
+		Phone:               "+1 555 010 0000",
+		LowBalanceThreshold: 5000,
+		IncomingTransfer:    true,
+	}
+	require.Nil(t, req.Validate())
+	require.Nil(t, store.SaveNotificationPreferences(ctx, NewNotificationPreferences(42, req, now)))
+
+	// nothing is queued for accounts that haven't saved preferences
+	dispatcher.Publish(ctx, &Event{Type: EventBalanceLow, AccountNumber: 43, Data: BalanceData{Balance: 0, Currency: "USD"}})
+
+	dispatcher.Publish(ctx, &Event{Type: EventBalanceLow, AccountNumber: 42, Data: BalanceData{Balance: 6000, Currency: "USD"}})
+	dispatcher.Publish(ctx, &Event{Type: EventBalanceLow, AccountNumber: 42, Data: BalanceData{Balance: 4000, Currency: "USD"}})
+
+	transfer := &Transfer{FromAccount: 42, ToAccount: 7, Amount: 100, Currency: "USD", ToAmount: 100, ToCurrency: "USD"}
+	dispatcher.Publish(ctx, &Event{Type: EventTransferCompleted, AccountNumber: 42, Data: transfer})
+
+	received := &Transfer{FromAccount: 7, ToAccount: 42, Amount: 250, Currency: "USD", ToAmount: 250, ToCurrency: "USD"}
+	dispatcher.Publish(ctx, &Event{Type: EventTransferCompleted, AccountNumber: 42, Data: received})
+
+	recordLoginNetwork(ctx, store, dispatcher, 42, "192.0.2.1")
+	recordLoginNetwork(ctx, store, dispatcher, 42, "198.51.100.1")
+
+	deliveries, err := store.GetNotificationDeliveries(ctx, 42, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, deliveries, 4, "one low balance and one incoming transfer notification per channel")
+	assert.Equal(t, NotificationIncomingTransfer, deliveries[0].Kind)
+	assert.Equal(t, "You received 2.50 USD from account 7.", deliveries[0].Body)
+	assert.Equal(t, NotificationLowBalance, deliveries[3].Kind)
+	assert.Equal(t, "alice@example.com", deliveries[3].To)
+	assert.Equal(t, "+15550100000", deliveries[2].To)
+
+	now = time.Now().UTC()
+	dispatcher.deliverDue(ctx, now)
+
+	require.Len(t, sms.notifications, 2)
+	assert.Equal(t, "+15550100000", sms.notifications[0].To)
+	assert.Equal(t, "Your balance is 40.00 USD, below your alert threshold of 50.00 USD.", sms.notifications[0].Body)
+
+	deliveries, err = store.GetNotificationDeliveries(ctx, 42, 10, 0)
+	require.Nil(t, err)
+
+	for _, d := range deliveries {
+		if d.Channel == NotificationSMS {
+			assert.Equal(t, NotificationDeliveryDelivered, d.Status)
+			continue
+		}
+
+		assert.Equal(t, NotificationDeliveryPending, d.Status, "failed deliveries are retried")
+		assert.Equal(t, 1, d.Attempts)
+		assert.Equal(t, "provider unavailable", d.LastError)
+		assert.True(t, d.NextAttemptAt.After(now))
+	}
+
+	for i := 1; i < maxNotificationAttempts; i++ {
+		now = now.Add(retryBackoff(i))
+		dispatcher.deliverDue(ctx, now)
+	}
+
+	deliveries, err = store.GetNotificationDeliveries(ctx, 42, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, NotificationDeliveryFailed, deliveries[1].Status)
+	assert.Equal(t, maxNotificationAttempts, deliveries[1].Attempts)
+	assert.Len(t, sms.notifications, 2, "delivered notifications are not sent again")
+
+	// the new device login is only notified once the holder asks for it
+	req.NewDeviceLogin = true
+	require.Nil(t, store.SaveNotificationPreferences(ctx, NewNotificationPreferences(42, req, now)))
+
+	recordLoginNetwork(ctx, store, dispatcher, 42, "198.51.100.2")
+	recordLoginNetwork(ctx, store, dispatcher, 42, "203.0.113.1")
+
+	deliveries, err = store.GetNotificationDeliveries(ctx, 42, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, deliveries, 6)
+	assert.Equal(t, NotificationNewDeviceLogin, deliveries[0].Kind)
+	assert.Contains(t, deliveries[0].Body, "203.0.113.1")
+}
+
+func TestAPINotifications(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	aliceToken := api.login(alice, "alice-pw")
+	bobToken := api.login(bob, "bob-pw")
+	path := "/account/" + strconv.Itoa(bob.ID) + "/notifications"
+
+	rec := api.do("GET", path+"/preferences", bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	prefs := new(NotificationPreferences)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(prefs))
+	assert.Empty(t, prefs.Channels)
+	assert.False(t, prefs.IncomingTransfer)
+
+	rec = api.do("PUT", path+"/preferences", bobToken, NotificationPreferencesRequest{Channels: []NotificationChannel{NotificationSMS}})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	rec = api.do("PUT", path+"/preferences", aliceToken, NotificationPreferencesRequest{})
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+	rec = api.do("PUT", path+"/preferences", bobToken, NotificationPreferencesRequest{Channels: []NotificationChannel{NotificationPush}, PushToken: "device-token", IncomingTransfer: true})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", aliceToken, TransferRequest{ToAccount: int(bob.Number), Amount: 300})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path, bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	deliveries := []*NotificationDelivery{}
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, NotificationPush, deliveries[0].Channel)
+	assert.Equal(t, NotificationIncomingTransfer, deliveries[0].Kind)
+	assert.Equal(t, NotificationDeliveryPending, deliveries[0].Status)
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/notifications", aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, "[]", rec.Body.String(), "the sender only hears about money received")
+}
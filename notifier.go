@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Notification is a message for the holder of an account, delivered out of
+// band.
+type Notification struct {
+	AccountNumber int64
+	Subject       string
+	Body          string
+
+	// To is an email address, phone number or postal address to deliver to
+	// instead of the holder, such as an alias that is being verified.
+	To string
+}
+
+// Notifier delivers notifications to account holders. No provider is
+// integrated yet, so the email, SMS and push notifiers are stubs.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// NewNotifier returns the configured notifier, logging notifications unless
+// email or sms is configured.
+func NewNotifier(cfg *Config) Notifier {
+	switch cfg.Notifier {
+	case "email":
+		return EmailNotifier{}
+	case "sms":
+		return SMSNotifier{}
+	}
+
+	return LogNotifier{}
+}
+
+// newChannelNotifiers returns the notifier of every notification channel,
+// all logging when the log notifier is configured.
+func newChannelNotifiers(cfg *Config) map[NotificationChannel]Notifier {
+	if cfg.Notifier == "log" {
+		return map[NotificationChannel]Notifier{
+			NotificationEmail: LogNotifier{},
+			NotificationSMS:   LogNotifier{},
+			NotificationPush:  LogNotifier{},
+		}
+	}
+
+	return map[NotificationChannel]Notifier{
+		NotificationEmail: EmailNotifier{},
+		NotificationSMS:   SMSNotifier{},
+		NotificationPush:  PushNotifier{},
+	}
+}
+
+// LogNotifier writes notifications, secrets included, to the server log. It
+// is meant for development.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, n *Notification) error {
+	slog.InfoContext(ctx, "notification", "accountNumber", n.AccountNumber, "to", n.To, "subject", n.Subject, "body", n.Body)
+	return nil
+}
+
+// EmailNotifier stands in for delivery by email.
+type EmailNotifier struct{}
+
+func (EmailNotifier) Notify(ctx context.Context, n *Notification) error {
+	slog.InfoContext(ctx, "email notification not sent, no email provider configured", "accountNumber", n.AccountNumber, "subject", n.Subject)
+	return nil
+}
+
+// SMSNotifier stands in for delivery by SMS.
+type SMSNotifier struct{}
+
+func (SMSNotifier) Notify(ctx context.Context, n *Notification) error {
+	slog.InfoContext(ctx, "SMS notification not sent, no SMS provider configured", "accountNumber", n.AccountNumber, "subject", n.Subject)
+	return nil
+}
+
+// PushNotifier stands in for delivery by push notification.
+type PushNotifier struct{}
+
+func (PushNotifier) Notify(ctx context.Context, n *Notification) error {
+	slog.InfoContext(ctx, "push notification not sent, no push provider configured", "accountNumber", n.AccountNumber, "subject", n.Subject)
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// jwksRefreshInterval keeps tokens signed with unknown keys from making
+	// us fetch the provider's keys on every request.
+	jwksRefreshInterval = time.Minute
+	oidcFetchTimeout    = 5 * time.Second
+)
+
+var errUnknownOIDCKey = errors.New("ID token signed with an unknown key")
+
+// OIDCVerifier checks RS256 ID tokens of an external OpenID Connect provider
+// against the keys it publishes. The keys are fetched on first use and again
+// when a token names a key we don't know, so the provider can rotate them.
+type OIDCVerifier struct {
+	issuer   string
+	clientID string
+	client   *http.Client
+
+	mu sync.Mutex
+	// jwksURL is discovered from the issuer unless configured.
+	jwksURL   string
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewOIDCVerifier returns nil when no provider is configured.
+func NewOIDCVerifier(cfg *Config) *OIDCVerifier {
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+
+	return &OIDCVerifier{
+		issuer:   cfg.OIDCIssuer,
+		clientID: cfg.OIDCClientID,
+		jwksURL:  cfg.OIDCJWKSURL,
+		client:   &http.Client{Timeout: oidcFetchTimeout},
+	}
+}
+
+// Verify checks the signature, issuer, audience and expiry of an ID token
+// and returns its subject.
+func (v *OIDCVerifier) Verify(ctx context.Context, idToken string) (string, error) {
+	var keyErr error
+
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
+
+	token, err := parser.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := v.key(ctx, kid)
+		keyErr = err
+
+		return key, err
+	})
+
+	// failing to reach the provider is our problem, not the caller's
+	if keyErr != nil && !errors.Is(keyErr, errUnknownOIDCKey) {
+		return "", keyErr
+	}
+
+	if err != nil || !token.Valid {
+		return "", unauthorizedError("invalid ID token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	subject, _ := claims["sub"].(string)
+
+	if !claims.VerifyIssuer(v.issuer, true) || !claims.VerifyAudience(v.clientID, true) || !claims.VerifyExpiresAt(time.Now().Unix(), true) || subject == "" {
+		return "", unauthorizedError("invalid ID token")
+	}
+
+	return subject, nil
+}
+
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(v.fetchedAt) < jwksRefreshInterval {
+		return nil, errUnknownOIDCKey
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		return nil, fmt.Errorf("fetching OIDC keys: %w", err)
+	}
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, errUnknownOIDCKey
+}
+
+// refresh replaces the keys with the provider's current JSON Web Key Set.
+// Keys other than RSA signing keys are skipped.
+func (v *OIDCVerifier) refresh(ctx context.Context) error {
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+
+		if err := v.getJSON(ctx, strings.TrimSuffix(v.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return err
+		}
+
+		if discovery.JWKSURI == "" {
+			return errors.New("discovery document has no jwks_uri")
+		}
+
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+
+	if err := v.getJSON(ctx, v.jwksURL, &jwks); err != nil {
+		return err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+
+		if errN != nil || errE != nil || len(e) > 4 {
+			return fmt.Errorf("malformed key %q", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	return nil
+}
+
+func (v *OIDCVerifier) getJSON(ctx context.Context, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
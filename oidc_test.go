@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOIDCClientID = "go-bank"
+
+// testOIDCProvider serves a discovery document and the JWKS of one RSA key.
+type testOIDCProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	p := &testOIDCProvider{t: t, key: key}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/keys"})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *testOIDCProvider) config() *Config {
+	cfg := testConfig()
+	cfg.OIDCIssuer = p.server.URL
+	cfg.OIDCClientID = testOIDCClientID
+
+	return cfg
+}
+
+// idToken signs claims over valid defaults for subject.
+func (p *testOIDCProvider) idToken(subject string, claims jwt.MapClaims) string {
+	defaults := jwt.MapClaims{
+		"iss": p.server.URL,
+		"aud": testOIDCClientID,
+		"sub": subject,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	for k, v := range claims {
+		defaults[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, defaults)
+	token.Header["kid"] = "test-key"
+
+	signed, err := token.SignedString(p.key)
+	require.Nil(p.t, err)
+
+	return signed
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	verifier := NewOIDCVerifier(provider.config())
+	ctx := context.Background()
+
+	subject, err := verifier.Verify(ctx, provider.idToken("user-1", nil))
+	require.Nil(t, err)
+	assert.Equal(t, "user-1", subject)
+
+	invalid := []string{
+		provider.idToken("user-1", jwt.MapClaims{"aud": "someone-else"}),
+		provider.idToken("user-1", jwt.MapClaims{"iss": "https://evil.example.com"}),
+		provider.idToken("user-1", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}),
+		provider.idToken("", nil),
+	}
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": provider.server.URL, "aud": testOIDCClientID, "sub": "user-1"}).SignedString([]byte("secret"))
+	require.Nil(t, err)
+	invalid = append(invalid, hs256)
+
+	for _, token := range invalid {
+		_, err := verifier.Verify(ctx, token)
+		assert.True(t, isLoginFailure(err), "%v", err)
+	}
+
+	assert.Nil(t, NewOIDCVerifier(testConfig()))
+}
+
+func TestAPIOIDCLogin(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	api := newTestAPIWithConfig(t, provider.config())
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	identities := "/account/" + strconv.Itoa(alice.ID) + "/identities"
+
+	rec := api.do("POST", "/login/oidc", "", OIDCLoginRequest{IDToken: provider.idToken("alice", nil)})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", identities, api.login(alice, "alice-pw"), LinkIdentityRequest{IDToken: provider.idToken("alice", nil)})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/account/"+strconv.Itoa(bob.ID)+"/identities", api.login(bob, "bob-pw"), LinkIdentityRequest{IDToken: provider.idToken("alice", nil)})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", "/login/oidc", "", OIDCLoginRequest{IDToken: provider.idToken("alice", nil)})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	resp := new(LoginResponse)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(resp))
+	assert.Equal(t, alice.Number, resp.Number)
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID), resp.Token, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = api.do("POST", "/login/oidc", "", OIDCLoginRequest{IDToken: provider.idToken("alice", jwt.MapClaims{"aud": "someone-else"})})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, AuditIdentityLinked, entries[0].Action)
+}
+
+func TestAPIOIDCLoginNotConfigured(t *testing.T) {
+	api := newTestAPI(t)
+
+	rec := api.do("POST", "/login/oidc", "", OIDCLoginRequest{IDToken: "token"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
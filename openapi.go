@@ -0,0 +1,85 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+func loadOpenAPI() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(openAPISpec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func handleOpenAPI(doc *openapi3.T) http.HandlerFunc {
+	return makeHttpHandleFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != "GET" {
+			return methodNotAllowedError(r.Method)
+		}
+
+		return writeJSON(w, http.StatusOK, doc)
+	})
+}
+
+// withOpenAPIValidation rejects requests whose parameters or body do not match
+// the OpenAPI document. Authentication is left to the handlers, and routes
+// missing from the document are passed through untouched.
+func withOpenAPIValidation(doc *openapi3.T) (Middleware, error) {
+	router, err := gorillamux.NewRouter(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := router.FindRoute(r)
+
+			if err == routers.ErrPathNotFound || err == routers.ErrMethodNotAllowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			}
+
+			if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				writeError(w, r, badRequestError("%s", err))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIValidation(t *testing.T) {
+	doc, err := loadOpenAPI()
+	assert.Nil(t, err)
+
+	validate, err := withOpenAPIValidation(doc)
+	assert.Nil(t, err)
+
+	handler := validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/transfer", strings.NewReader(`{"toAccount": 1, "amount": 10}`))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/transfer", strings.NewReader(`{"toAccount": "one"}`))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/not-in-the-spec", nil)
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
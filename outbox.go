@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	// outboxTopicTransfers is the topic completed transfers are published
+	// to, keyed by the account they were sent from.
+	outboxTopicTransfers = "bank.transfers"
+
+	outboxInterval   = time.Second
+	outboxBatchSize  = 100
+	outboxLease      = time.Minute
+	maxOutboxBackoff = time.Hour
+)
+
+// MessageBroker publishes outbox messages downstream. Publishing the same
+// MessageID twice must deliver it once: Kafka producers get this from
+// idempotent writes keyed by it, NATS JetStream from its Nats-Msg-Id
+// header. Consumers should still ignore message IDs they have seen, as a
+// broker only deduplicates within its window.
+type MessageBroker interface {
+	Publish(ctx context.Context, msg *OutboxMessage) error
+}
+
+// NewMessageBroker returns the configured broker, logging messages unless
+// kafka or nats is configured.
+func NewMessageBroker(cfg *Config) MessageBroker {
+	switch cfg.Broker {
+	case "kafka":
+		return KafkaBroker{}
+	case "nats":
+		return NATSBroker{}
+	}
+
+	return LogBroker{}
+}
+
+// LogBroker writes messages to the server log. It is meant for development.
+type LogBroker struct{}
+
+func (LogBroker) Publish(ctx context.Context, msg *OutboxMessage) error {
+	slog.InfoContext(ctx, "outbox message", "messageId", msg.MessageID, "topic", msg.Topic, "key", msg.Key, "payload", string(msg.Payload))
+	return nil
+}
+
+// KafkaBroker stands in for a Kafka producer. It fails every publish, so
+// messages stay in the outbox until a client is wired in.
+type KafkaBroker struct{}
+
+func (KafkaBroker) Publish(ctx context.Context, msg *OutboxMessage) error {
+	return errors.New("no Kafka client configured")
+}
+
+// NATSBroker stands in for a NATS JetStream publisher. It fails every
+// publish, so messages stay in the outbox until a client is wired in.
+type NATSBroker struct{}
+
+func (NATSBroker) Publish(ctx context.Context, msg *OutboxMessage) error {
+	return errors.New("no NATS client configured")
+}
+
+// newTransferOutboxMessage builds the transfer.completed message the store
+// writes with a transfer. Its ID is derived from the transfer's, so however
+// often it is published consumers can tell it is the same transfer.
+func newTransferOutboxMessage(transfer *Transfer) (*OutboxMessage, error) {
+	event := &Event{
+		ID:            "transfer_" + strconv.Itoa(transfer.ID),
+		Type:          EventTransferCompleted,
+		AccountNumber: transfer.FromAccount,
+		Data:          transfer,
+		CreatedAt:     transfer.CreatedAt,
+	}
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessage{
+		MessageID:     event.ID,
+		Topic:         outboxTopicTransfers,
+		Key:           strconv.FormatInt(transfer.FromAccount, 10),
+		Payload:       payload,
+		NextAttemptAt: transfer.CreatedAt,
+		CreatedAt:     transfer.CreatedAt,
+	}, nil
+}
+
+// OutboxRelay publishes outbox messages to the broker oldest first, retrying
+// failures with backoff until they are published. A relay that stops between
+// publishing a message and recording it publishes it again, which the broker
+// deduplicates by message ID.
+type OutboxRelay struct {
+	store  Storage
+	broker MessageBroker
+}
+
+func NewOutboxRelay(store Storage, broker MessageBroker) *OutboxRelay {
+	return &OutboxRelay{store: store, broker: broker}
+}
+
+func (o *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	for {
+		o.relayDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayDue publishes due messages batch by batch. It stops at the first
+// failure, releasing the rest of the batch, rather than sending the whole
+// batch to a broker that is down.
+func (o *OutboxRelay) relayDue(ctx context.Context, now time.Time) {
+	for {
+		due, err := o.store.ClaimOutboxMessages(ctx, now, now.Add(outboxLease), outboxBatchSize)
+
+		if err != nil {
+			slog.Error("claiming outbox messages", "error", err)
+			return
+		}
+
+		for i, msg := range due {
+			published := o.publish(ctx, msg, now)
+
+			if err := o.store.UpdateOutboxMessage(ctx, msg); err != nil {
+				slog.Error("recording outbox message", "error", err, "messageId", msg.MessageID)
+				return
+			}
+
+			if !published {
+				for _, rest := range due[i+1:] {
+					if err := o.store.UpdateOutboxMessage(ctx, rest); err != nil {
+						slog.Error("releasing outbox message", "error", err, "messageId", rest.MessageID)
+					}
+				}
+
+				return
+			}
+		}
+
+		if len(due) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (o *OutboxRelay) publish(ctx context.Context, msg *OutboxMessage, now time.Time) bool {
+	msg.Attempts++
+
+	if err := o.broker.Publish(ctx, msg); err != nil {
+		outboxPublishFailuresTotal.WithLabelValues(msg.Topic).Inc()
+		slog.Warn("publishing outbox message", "error", err, "messageId", msg.MessageID, "attempts", msg.Attempts)
+
+		msg.LastError = err.Error()
+		msg.NextAttemptAt = now.Add(outboxBackoff(msg.Attempts))
+
+		return false
+	}
+
+	outboxPublishedTotal.WithLabelValues(msg.Topic).Inc()
+
+	msg.LastError = ""
+	msg.PublishedAt = &now
+
+	return true
+}
+
+// outboxBackoff is retryBackoff capped at maxOutboxBackoff: messages are
+// retried forever rather than dropped.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 7 {
+		return maxOutboxBackoff
+	}
+
+	return min(retryBackoff(attempts), maxOutboxBackoff)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyBroker fails its first publishes, as many as failures, then records
+// the rest.
+type flakyBroker struct {
+	failures  int
+	published []*OutboxMessage
+}
+
+func (b *flakyBroker) Publish(ctx context.Context, msg *OutboxMessage) error {
+	if b.failures > 0 {
+		b.failures--
+		return errors.New("broker unavailable")
+	}
+
+	b.published = append(b.published, msg)
+
+	return nil
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, outboxBackoff(1))
+	assert.Equal(t, 32*time.Minute, outboxBackoff(6))
+	assert.Equal(t, time.Hour, outboxBackoff(7))
+	assert.Equal(t, time.Hour, outboxBackoff(100))
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for _, number := range []int64{1, 2} {
+		require.Nil(t, store.CreateAccount(ctx, &Account{Number: number, Currency: "USD", Status: AccountActive}))
+	}
+
+	_, err := store.Deposit(ctx, 1, 1000, 0)
+	require.Nil(t, err)
+
+	transfers := []*Transfer{}
+
+	for _, amount := range []int64{100, 200, 300} {
+		transfer := &Transfer{FromAccount: 1, ToAccount: 2, Amount: amount, Currency: "USD", ToAmount: amount, ToCurrency: "USD"}
+		require.Nil(t, store.Transfer(ctx, transfer))
+		transfers = append(transfers, transfer)
+	}
+
+	// a failed transfer writes no message
+	assert.NotNil(t, store.Transfer(ctx, &Transfer{FromAccount: 1, ToAccount: 2, Amount: 5000, Currency: "USD", ToAmount: 5000, ToCurrency: "USD"}))
+
+	broker := &flakyBroker{failures: 1}
+	relay := NewOutboxRelay(store, broker)
+	now := time.Now().UTC()
+
+	// the first attempt fails and the rest of the batch waits for the next
+	// run, which skips the failed message until its backoff has passed
+	relay.relayDue(ctx, now)
+	assert.Empty(t, broker.published)
+
+	relay.relayDue(ctx, now)
+	require.Len(t, broker.published, 2)
+
+	relay.relayDue(ctx, now.Add(outboxBackoff(1)))
+	require.Len(t, broker.published, 3)
+	assert.Equal(t, 2, broker.published[2].Attempts)
+
+	// published messages are never published again
+	relay.relayDue(ctx, now.Add(time.Hour))
+	assert.Len(t, broker.published, 3)
+
+	seen := map[string]bool{}
+
+	for _, msg := range broker.published {
+		assert.False(t, seen[msg.MessageID], msg.MessageID)
+		seen[msg.MessageID] = true
+
+		assert.Equal(t, outboxTopicTransfers, msg.Topic)
+		assert.Equal(t, "1", msg.Key)
+
+		event := new(Event)
+		require.Nil(t, json.Unmarshal(msg.Payload, event))
+		assert.Equal(t, EventTransferCompleted, event.Type)
+		assert.Equal(t, msg.MessageID, event.ID)
+	}
+
+	for _, transfer := range transfers {
+		assert.True(t, seen["transfer_"+strconv.Itoa(transfer.ID)], transfer.ID)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// isAccountOwner reports whether the holder of number may act on account:
+// they hold it or co-own it.
+func isAccountOwner(ctx context.Context, store OwnerRepository, account, number int64) (bool, error) {
+	if account == number {
+		return true, nil
+	}
+
+	owners, err := store.GetAccountOwners(ctx, account)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, owner := range owners {
+		if owner.OwnerNumber == number {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// needsSecondOwner reports whether a transfer of amount from acc must wait
+// for a second owner's approval: user, the business user requesting it, is
+// an initiator, or acc has co-owners and amount is above its
+// DualApprovalAmount.
+func needsSecondOwner(ctx context.Context, store OwnerRepository, acc *Account, user *BusinessUser, amount int64) (bool, error) {
+	if user != nil && user.Role == BusinessUserInitiator {
+		return true, nil
+	}
+
+	if acc.DualApprovalAmount <= 0 || amount <= acc.DualApprovalAmount {
+		return false, nil
+	}
+
+	owners, err := store.GetAccountOwners(ctx, acc.Number)
+
+	if err != nil {
+		return false, err
+	}
+
+	return len(owners) > 0, nil
+}
+
+// checkSecondOwner refuses transfers that need a second owner's approval,
+// for the ways of moving money that can't wait for one.
+func checkSecondOwner(ctx context.Context, store Storage, from, amount int64) error {
+	acc, err := store.GetAccountByNumber(ctx, int(from))
+
+	if err != nil {
+		return err
+	}
+
+	needed, err := needsSecondOwner(ctx, store, acc, nil, amount)
+
+	if err != nil {
+		return err
+	}
+
+	if needed {
+		return approvalRequiredError(from, acc.DualApprovalAmount)
+	}
+
+	return nil
+}
+
+func NewTransferApproval(transfer *Transfer, requestedBy int64) *TransferApproval {
+	return &TransferApproval{
+		FromAccount: transfer.FromAccount,
+		ToAccount:   transfer.ToAccount,
+		Amount:      transfer.Amount,
+		RequestedBy: requestedBy,
+		Status:      TransferApprovalPending,
+		CreatedAt:   time.Now().UTC(),
+
+		TransferReference: transfer.TransferReference,
+	}
+}
+
+// CheckDecision validates approving or rejecting the approval by decidedBy,
+// or by their business user decidedByUser. The owner or user who requested
+// the transfer can withdraw it by rejecting it, but not approve it.
+func (a *TransferApproval) CheckDecision(status TransferApprovalStatus, decidedBy int64, decidedByUser int) error {
+	switch {
+	case a.Status != TransferApprovalPending:
+		return conflictError("transfer approval %d is %s", a.ID, a.Status)
+	case status == TransferApprovalApproved && decidedBy == a.RequestedBy && decidedByUser == a.RequestedByUser:
+		return forbiddenError("transfer approval %d must be approved by another owner", a.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferApprovalCheckDecision(t *testing.T) {
+	approval := &TransferApproval{ID: 1, RequestedBy: 10, Status: TransferApprovalPending}
+
+	var httpErr *HTTPError
+
+	require.True(t, errors.As(approval.CheckDecision(TransferApprovalApproved, 10, 0), &httpErr))
+	assert.Equal(t, http.StatusForbidden, httpErr.Status)
+
+	assert.Nil(t, approval.CheckDecision(TransferApprovalRejected, 10, 0))
+	assert.Nil(t, approval.CheckDecision(TransferApprovalApproved, 11, 0))
+	assert.Nil(t, approval.CheckDecision(TransferApprovalApproved, 10, 3), "another user of the account can approve it")
+
+	approval.RequestedByUser = 3
+
+	require.True(t, errors.As(approval.CheckDecision(TransferApprovalApproved, 10, 3), &httpErr))
+	assert.Equal(t, http.StatusForbidden, httpErr.Status)
+	assert.Nil(t, approval.CheckDecision(TransferApprovalApproved, 10, 0), "the holder can approve their user's transfer")
+
+	approval.Status = TransferApprovalRejected
+
+	require.True(t, errors.As(approval.CheckDecision(TransferApprovalApproved, 11, 0), &httpErr))
+	assert.Equal(t, http.StatusConflict, httpErr.Status)
+}
+
+func TestAPIJointAccount(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	carol := api.createAccount("Carol", "carol-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	aliceToken := api.login(alice, "alice-pw")
+	bobToken := api.login(bob, "bob-pw")
+	carolToken := api.login(carol, "carol-pw")
+	adminToken := api.login(admin, "admin-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	balance := func() int64 {
+		acc, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+		require.Nil(t, err)
+
+		return acc.Balance
+	}
+
+	rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 100000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path, bobToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", path+"/owners", aliceToken, AccountOwnerRequest{OwnerNumber: alice.Number})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = api.do("POST", path+"/owners", aliceToken, AccountOwnerRequest{OwnerNumber: bob.Number})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/owners", aliceToken, AccountOwnerRequest{OwnerNumber: bob.Number})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// co-owners use the joint account, but can't add owners or manage the
+	// holder's login
+	rec = api.do("GET", path, bobToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/owners", bobToken, AccountOwnerRequest{OwnerNumber: carol.Number})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", path+"/totp", bobToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(bob.ID)+"/joint-accounts", bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var owned []*AccountOwner
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&owned))
+	require.Len(t, owned, 1)
+	assert.Equal(t, alice.Number, owned[0].AccountNumber)
+
+	rec = api.do("POST", "/transfer", bobToken, TransferRequest{FromAccount: int(alice.Number), ToAccount: int(carol.Number), Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, int64(99000), balance())
+
+	rec = api.do("POST", "/transfer", carolToken, TransferRequest{FromAccount: int(alice.Number), ToAccount: int(carol.Number), Amount: 1000})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("PUT", "/admin/account/"+strconv.Itoa(alice.ID)+"/limits", adminToken, AccountLimits{DualApprovalAmount: 20000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// large transfers wait for the other owner
+	rec = api.do("POST", "/transfer", bobToken, TransferRequest{FromAccount: int(alice.Number), ToAccount: int(carol.Number), Amount: 60000})
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	approval := new(TransferApproval)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(approval))
+	assert.Equal(t, TransferApprovalPending, approval.Status)
+	assert.Equal(t, bob.Number, approval.RequestedBy)
+	assert.Equal(t, int64(99000), balance())
+
+	approvalPath := path + "/approvals/" + strconv.Itoa(approval.ID)
+
+	rec = api.do("POST", approvalPath+"/approve", bobToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", approvalPath+"/approve", aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(approval))
+	assert.Equal(t, TransferApprovalApproved, approval.Status)
+	assert.NotNil(t, approval.TransferID)
+	assert.Equal(t, int64(39000), balance())
+
+	rec = api.do("POST", approvalPath+"/reject", aliceToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// the requester can withdraw a pending transfer
+	rec = api.do("POST", "/transfer", aliceToken, TransferRequest{ToAccount: int(carol.Number), Amount: 31000})
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(approval))
+
+	rec = api.do("POST", path+"/approvals/"+strconv.Itoa(approval.ID)+"/reject", aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer/authorize", aliceToken, TransferRequest{ToAccount: int(carol.Number), Amount: 31000})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeApprovalRequired))
+
+	rec = api.do("GET", path+"/approvals", bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var approvals []*TransferApproval
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&approvals))
+	require.Len(t, approvals, 2)
+	assert.Equal(t, TransferApprovalRejected, approvals[0].Status)
+
+	// once bob leaves, alice is the sole owner again
+	rec = api.do("DELETE", path+"/owners/"+strconv.FormatInt(bob.Number, 10), bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", path, bobToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/transfer", aliceToken, TransferRequest{ToAccount: int(carol.Number), Amount: 30000})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxPayeeNameLength = 200
+
+// PayeeMatch is how closely a payee name matches the holder of an account.
+type PayeeMatch string
+
+const (
+	PayeeMatchExact PayeeMatch = "match"
+	// PayeeMatchClose is a name that is likely meant for the holder: a typo,
+	// initials or the names in another order.
+	PayeeMatchClose PayeeMatch = "close_match"
+	PayeeMatchNone  PayeeMatch = "no_match"
+)
+
+// PayeeVerification is the outcome of checking a payee name before paying
+// an account.
+type PayeeVerification struct {
+	AccountNumber int64      `json:"accountNumber"`
+	Result        PayeeMatch `json:"result"`
+	// Name is the holder's name, given on a close match only so the payer
+	// can tell whether they meant them. Other results reveal nothing.
+	Name string `json:"name,omitempty"`
+}
+
+// payeeTitles are left out of names before they are compared.
+var payeeTitles = []string{"mr", "mrs", "ms", "miss", "mx", "dr", "prof"}
+
+// VerifyPayee compares the name the payer gave to the holder of acc,
+// ignoring case, punctuation and titles.
+func VerifyPayee(name string, acc *Account) *PayeeVerification {
+	verification := &PayeeVerification{AccountNumber: acc.Number, Result: PayeeMatchNone}
+
+	holder := strings.TrimSpace(acc.FirstName + " " + acc.LastName)
+	given, stored := payeeNameParts(name), payeeNameParts(holder)
+
+	switch {
+	case len(given) == 0 || len(stored) == 0:
+	case slices.Equal(given, stored):
+		verification.Result = PayeeMatchExact
+	case sameNameParts(given, stored), matchesInitials(given, stored), closeSpelling(given, stored):
+		verification.Result = PayeeMatchClose
+		verification.Name = holder
+	}
+
+	return verification
+}
+
+// payeeNameParts lowercases name and splits it into words, dropping
+// punctuation and titles.
+func payeeNameParts(name string) []string {
+	parts := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return slices.DeleteFunc(parts, func(part string) bool {
+		return slices.Contains(payeeTitles, part)
+	})
+}
+
+// sameNameParts reports whether both names have the same words, in any
+// order.
+func sameNameParts(given, stored []string) bool {
+	given, stored = slices.Clone(given), slices.Clone(stored)
+	slices.Sort(given)
+	slices.Sort(stored)
+
+	return slices.Equal(given, stored)
+}
+
+// matchesInitials reports whether given is stored with some of the names
+// before the last one shortened to their initial, as in A. Lovelace.
+func matchesInitials(given, stored []string) bool {
+	if len(given) != len(stored) || given[len(given)-1] != stored[len(stored)-1] {
+		return false
+	}
+
+	for i, part := range given[:len(given)-1] {
+		initial, _ := utf8.DecodeRuneInString(stored[i])
+
+		if part != stored[i] && part != string(initial) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// closeSpelling reports whether the names are a typo or two apart: the
+// longer the name, the more edits are allowed, up to three.
+func closeSpelling(given, stored []string) bool {
+	a, b := []rune(strings.Join(given, " ")), []rune(strings.Join(stored, " "))
+	allowed := min(max(len(b)/6, 1), 3)
+
+	return editDistance(a, b) <= allowed
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
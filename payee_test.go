@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPayee(t *testing.T) {
+	acc := &Account{Number: 42, FirstName: "Ada", LastName: "Lovelace"}
+
+	for name, want := range map[string]PayeeMatch{
+		"Ada Lovelace":      PayeeMatchExact,
+		"  ada LOVELACE ":   PayeeMatchExact,
+		"Mrs. Ada Lovelace": PayeeMatchExact,
+		"Lovelace, Ada":     PayeeMatchClose,
+		"A. Lovelace":       PayeeMatchClose,
+		"Ada Lovelase":      PayeeMatchClose,
+		"Ada Byron":         PayeeMatchNone,
+		"Charles Babbage":   PayeeMatchNone,
+		"B. Lovelace":       PayeeMatchNone,
+		"---":               PayeeMatchNone,
+	} {
+		verification := VerifyPayee(name, acc)
+		assert.Equal(t, want, verification.Result, name)
+
+		if want == PayeeMatchClose {
+			assert.Equal(t, "Ada Lovelace", verification.Name, name)
+		} else {
+			assert.Empty(t, verification.Name, "only close matches reveal the name")
+		}
+	}
+}
+
+func TestAPIVerifyPayee(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+
+	verify := func(number int64, name string) *PayeeVerification {
+		rec := api.do("GET", fmt.Sprintf("/account/verify?number=%d&name=%s", number, url.QueryEscape(name)), token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		verification := new(PayeeVerification)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(verification))
+
+		return verification
+	}
+
+	assert.Equal(t, PayeeMatchExact, verify(bob.Number, "Bob Test").Result)
+	assert.Equal(t, PayeeMatchClose, verify(bob.Number, "Rob Test").Result)
+	assert.Equal(t, PayeeMatchNone, verify(bob.Number, "Alice Test").Result)
+
+	rec := api.do("GET", fmt.Sprintf("/account/verify?number=%d&name=Bob", bob.Number), "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("GET", fmt.Sprintf("/account/verify?number=%d&name=Bob", bob.Number+1), token, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "the check digit is wrong")
+
+	rec = api.do("GET", fmt.Sprintf("/account/verify?number=%d", bob.Number), token, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorePaymentRequests(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	for _, expiresAt := range []time.Time{now.Add(-time.Minute), now.Add(time.Hour)} {
+		require.Nil(t, store.CreatePaymentRequest(ctx, &PaymentRequest{FromAccount: 42, ToAccount: 43, Amount: 100, Currency: "USD", Status: PaymentRequestPending, ExpiresAt: expiresAt, CreatedAt: now}))
+	}
+
+	pending, err := store.GetPaymentRequests(ctx, 42, true, PaymentRequestPending, now, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].ID)
+
+	expired, err := store.GetPaymentRequests(ctx, 43, false, PaymentRequestExpired, now, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, 1, expired[0].ID)
+
+	_, err = store.DecidePaymentRequest(ctx, 1, 42, PaymentRequestDeclined, now)
+	assert.ErrorContains(t, err, "is expired")
+
+	_, err = store.DecidePaymentRequest(ctx, 2, 43, PaymentRequestDeclined, now)
+	assert.True(t, isNotFound(err), "only the payer decides")
+
+	declined, err := store.DecidePaymentRequest(ctx, 2, 42, PaymentRequestDeclined, now)
+	require.Nil(t, err)
+	assert.Equal(t, PaymentRequestDeclined, declined.Status)
+
+	_, err = store.DecidePaymentRequest(ctx, 2, 42, PaymentRequestAccepted, now)
+	assert.ErrorContains(t, err, "is declined")
+}
+
+func TestAPIPaymentRequest(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+
+	_, err := api.store.Deposit(context.Background(), bob.Number, 1000, 0)
+	require.Nil(t, err)
+
+	aliceToken := api.login(alice, "alice-pw")
+	bobToken := api.login(bob, "bob-pw")
+
+	ask := func(amount int64, memo string) *PaymentRequest {
+		rec := api.do("POST", "/payment-request", aliceToken, PaymentRequestRequest{FromAccount: bob.Number, Amount: amount, TransferReference: TransferReference{Memo: memo}})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		request := new(PaymentRequest)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(request))
+
+		return request
+	}
+
+	rec := api.do("POST", "/payment-request", aliceToken, PaymentRequestRequest{FromAccount: alice.Number, Amount: 100})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "accounts can't ask themselves")
+
+	dinner := ask(300, "Dinner")
+	assert.Equal(t, PaymentRequestPending, dinner.Status)
+	assert.Equal(t, "USD", dinner.Currency)
+
+	tickets := ask(5000, "Tickets")
+
+	list := func(token string, id int, query string) []*PaymentRequest {
+		rec := api.do("GET", fmt.Sprintf("/account/%d/payment-requests%s", id, query), token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var requests []*PaymentRequest
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(&requests))
+
+		return requests
+	}
+
+	assert.Len(t, list(bobToken, bob.ID, ""), 2)
+	assert.Empty(t, list(bobToken, bob.ID, "?direction=outgoing"))
+	assert.Len(t, list(aliceToken, alice.ID, "?direction=outgoing&status=pending"), 2)
+
+	rec = api.do("POST", fmt.Sprintf("/account/%d/payment-requests/%d/accept", alice.ID, dinner.ID), aliceToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "the requester can't pay it")
+
+	rec = api.do("POST", fmt.Sprintf("/account/%d/payment-requests/%d/accept", bob.ID, dinner.ID), bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	accepted := new(PaymentRequest)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(accepted))
+	assert.Equal(t, PaymentRequestAccepted, accepted.Status)
+	require.NotNil(t, accepted.TransferID)
+
+	acc, err := api.store.GetAccountByNumber(context.Background(), int(alice.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), acc.Balance)
+
+	found, err := api.store.SearchTransactions(context.Background(), alice.Number, &TransactionSearch{Query: "dinner", Limit: 10})
+	require.Nil(t, err)
+	assert.Len(t, found, 1, "the transfer carries the request's memo")
+
+	rec = api.do("POST", fmt.Sprintf("/account/%d/payment-requests/%d/decline", bob.ID, dinner.ID), bobToken, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "requests are decided once")
+
+	rec = api.do("POST", fmt.Sprintf("/account/%d/payment-requests/%d/accept", bob.ID, tickets.ID), bobToken, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "bob can't afford it")
+
+	pending := list(aliceToken, alice.ID, "?direction=outgoing&status=pending")
+	require.Len(t, pending, 1, "a failed transfer leaves the request pending")
+	assert.Equal(t, tickets.ID, pending[0].ID)
+	assert.Nil(t, pending[0].TransferID)
+
+	require.Nil(t, api.store.UpdateAccountLimits(context.Background(), bob.ID, AccountLimits{DualApprovalAmount: 1000}))
+	require.Nil(t, api.store.AddAccountOwner(context.Background(), &AccountOwner{AccountNumber: bob.Number, OwnerNumber: alice.Number}))
+	_, err = api.store.Deposit(context.Background(), bob.Number, 10000, 0)
+	require.Nil(t, err)
+
+	rec = api.do("POST", fmt.Sprintf("/account/%d/payment-requests/%d/accept", bob.ID, tickets.ID), bobToken, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the payment needs the other owner")
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeApprovalRequired))
+	assert.Len(t, list(aliceToken, alice.ID, "?direction=outgoing&status=pending"), 1)
+
+	lunch := ask(100, "Lunch")
+
+	rec = api.do("POST", fmt.Sprintf("/account/%d/payment-requests/%d/decline", bob.ID, lunch.ID), bobToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Len(t, list(bobToken, bob.ID, "?status=declined"), 1)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// potRoundUpUnit is what outgoing transfers are rounded up to, a whole
+	// unit of two-decimal currencies.
+	potRoundUpUnit = 100
+	// potSweepInterval is how often WeeklyAmount is swept into a pot.
+	potSweepInterval = 7 * 24 * time.Hour
+
+	potSweeperInterval  = time.Minute
+	potSweeperBatchSize = 100
+)
+
+// Update applies req to the pot. Setting a weekly amount schedules the first
+// sweep a week from now; changing it keeps the schedule.
+func (p *Pot) Update(req *PotRequest, now time.Time) {
+	p.Name = req.Name
+	p.TargetAmount = req.TargetAmount
+	p.RoundUp = req.RoundUp
+	p.WeeklyAmount = req.WeeklyAmount
+
+	switch {
+	case p.WeeklyAmount == 0:
+		p.NextSweepAt = nil
+	case p.NextSweepAt == nil:
+		next := now.Add(potSweepInterval)
+		p.NextSweepAt = &next
+	}
+}
+
+func (p *Pot) Progress() *PotProgress {
+	progress := &PotProgress{
+		PotID:        p.ID,
+		Name:         p.Name,
+		Balance:      p.Balance,
+		TargetAmount: p.TargetAmount,
+		Remaining:    max(p.TargetAmount-p.Balance, 0),
+		Percent:      int(min(p.Balance*100/p.TargetAmount, 100)),
+		Reached:      p.Balance >= p.TargetAmount,
+	}
+
+	if !progress.Reached && p.WeeklyAmount > 0 && p.NextSweepAt != nil {
+		sweeps := (progress.Remaining + p.WeeklyAmount - 1) / p.WeeklyAmount
+		projected := p.NextSweepAt.Add(time.Duration(sweeps-1) * potSweepInterval)
+		progress.ProjectedAt = &projected
+	}
+
+	return progress
+}
+
+// checkPotMove validates moving amount into the pot, or out of it when
+// negative, with its account locked.
+func checkPotMove(acc *Account, pot *Pot, amount int64) error {
+	if amount == 0 {
+		return validationError("invalid amount %d", amount)
+	}
+
+	if err := acc.CheckActive(); err != nil {
+		return err
+	}
+
+	if amount > 0 && !acc.CanDebit(amount) {
+		return insufficientFundsError()
+	}
+
+	if amount < 0 && pot.Balance < -amount {
+		return validationError("pot %d only holds %d", pot.ID, pot.Balance)
+	}
+
+	return nil
+}
+
+// roundUp returns what rounds amount up to the next potRoundUpUnit.
+func roundUp(amount int64) int64 {
+	return (potRoundUpUnit - amount%potRoundUpUnit) % potRoundUpUnit
+}
+
+// PotSweeper applies the automatic rules of pots: it sweeps weekly amounts in
+// the background, and as an EventPublisher it moves the round-up of every
+// completed transfer into the sender's oldest round-up pot.
+type PotSweeper struct {
+	store Storage
+}
+
+func NewPotSweeper(store Storage) *PotSweeper {
+	return &PotSweeper{store: store}
+}
+
+func (p *PotSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(potSweeperInterval)
+	defer ticker.Stop()
+
+	for {
+		p.sweepDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepDue sweeps pots in batches until none are left due at now.
+func (p *PotSweeper) sweepDue(ctx context.Context, now time.Time) {
+	for {
+		sweeps, err := p.store.SweepPots(ctx, now, potSweeperBatchSize)
+
+		if err != nil {
+			slog.Error("sweeping pots", "error", err)
+			return
+		}
+
+		for _, sweep := range sweeps {
+			slog.Info("pot swept", "pot", sweep.PotID, "account", sweep.AccountNumber, "amount", sweep.Amount, "swept", sweep.Swept)
+		}
+
+		if len(sweeps) < potSweeperBatchSize {
+			return
+		}
+	}
+}
+
+func (p *PotSweeper) Publish(ctx context.Context, event *Event) {
+	transfer, ok := event.Data.(*Transfer)
+
+	if !ok || event.Type != EventTransferCompleted || event.AccountNumber != transfer.FromAccount {
+		return
+	}
+
+	amount := roundUp(transfer.Amount)
+
+	if amount == 0 {
+		return
+	}
+
+	pots, err := p.store.GetPots(ctx, transfer.FromAccount)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "loading pots for round-up", "error", err)
+		return
+	}
+
+	for _, pot := range pots {
+		if !pot.RoundUp {
+			continue
+		}
+
+		_, err := p.store.MovePotMoney(ctx, pot.ID, pot.AccountNumber, amount)
+
+		var httpErr *HTTPError
+
+		// an account that can't spare the round-up just skips it
+		if errors.As(err, &httpErr) && httpErr.Status != http.StatusInternalServerError {
+			slog.InfoContext(ctx, "round-up skipped", "pot", pot.ID, "amount", amount, "reason", httpErr.Message)
+		} else if err != nil {
+			slog.ErrorContext(ctx, "rounding up transfer", "pot", pot.ID, "error", err)
+		}
+
+		return
+	}
+}
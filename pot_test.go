@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundUp(t *testing.T) {
+	assert.Equal(t, int64(0), roundUp(300))
+	assert.Equal(t, int64(1), roundUp(299))
+	assert.Equal(t, int64(55), roundUp(1245))
+}
+
+func TestPotProgress(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pot := &Pot{ID: 1, Name: "Holiday", Balance: 250}
+	pot.Update(&PotRequest{Name: "Holiday", TargetAmount: 1000, WeeklyAmount: 300}, now)
+
+	progress := pot.Progress()
+	assert.Equal(t, int64(750), progress.Remaining)
+	assert.Equal(t, 25, progress.Percent)
+	assert.False(t, progress.Reached)
+	// three sweeps, the first a week from now
+	require.NotNil(t, progress.ProjectedAt)
+	assert.Equal(t, now.Add(3*potSweepInterval), *progress.ProjectedAt)
+
+	pot.Balance = 1200
+
+	progress = pot.Progress()
+	assert.Equal(t, int64(0), progress.Remaining)
+	assert.Equal(t, 100, progress.Percent)
+	assert.True(t, progress.Reached)
+	assert.Nil(t, progress.ProjectedAt)
+
+	pot.Update(&PotRequest{Name: "Holiday", TargetAmount: 1000}, now)
+	assert.Nil(t, pot.NextSweepAt)
+}
+
+func TestCannotCloseAccountWithPots(t *testing.T) {
+	acc := &Account{Status: AccountActive, PotBalance: 100}
+
+	assert.ErrorContains(t, acc.CheckStatusChange(AccountClosed), "in pots")
+	assert.ErrorContains(t, acc.CheckDelete(), "in pots")
+}
+
+func TestMemoryStorePots(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	acc := &Account{Number: 1, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	_, err := store.Deposit(ctx, acc.Number, 1000, 0)
+	require.Nil(t, err)
+
+	now := time.Now().UTC()
+	pot := &Pot{AccountNumber: acc.Number, CreatedAt: now}
+	pot.Update(&PotRequest{Name: "Car", TargetAmount: 5000, WeeklyAmount: 400}, now)
+	require.Nil(t, store.CreatePot(ctx, pot))
+
+	assert.ErrorContains(t, store.CreatePot(ctx, &Pot{AccountNumber: acc.Number, Name: "Car", TargetAmount: 1}), "already has a pot")
+
+	_, err = store.MovePotMoney(ctx, pot.ID, acc.Number, 700)
+	require.Nil(t, err)
+
+	// money in pots can't be spent
+	_, err = store.Withdraw(ctx, acc.Number, 400, 0)
+	assert.ErrorContains(t, err, "insufficient funds")
+
+	_, err = store.MovePotMoney(ctx, pot.ID, acc.Number, -800)
+	assert.ErrorContains(t, err, "only holds 700")
+
+	// the second week's sweep can't be spared and is skipped
+	sweeps, err := store.SweepPots(ctx, now.Add(potSweepInterval), 10)
+	require.Nil(t, err)
+	require.Len(t, sweeps, 1)
+	assert.False(t, sweeps[0].Swept)
+
+	_, err = store.MovePotMoney(ctx, pot.ID, acc.Number, -700)
+	require.Nil(t, err)
+
+	sweeps, err = store.SweepPots(ctx, now.Add(2*potSweepInterval), 10)
+	require.Nil(t, err)
+	require.Len(t, sweeps, 1)
+	assert.True(t, sweeps[0].Swept)
+
+	sweeps, err = store.SweepPots(ctx, now.Add(2*potSweepInterval), 10)
+	require.Nil(t, err)
+	assert.Empty(t, sweeps)
+
+	stored, err := store.GetAccountByNumber(ctx, int(acc.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(1000), stored.Balance)
+	assert.Equal(t, int64(400), stored.PotBalance)
+
+	deleted, err := store.DeletePot(ctx, pot.ID, acc.Number)
+	require.Nil(t, err)
+	assert.Equal(t, int64(400), deleted.Balance)
+
+	stored, err = store.GetAccountByNumber(ctx, int(acc.Number))
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), stored.PotBalance)
+}
+
+func TestAPIPots(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/pots"
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 10000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path, token, PotRequest{Name: "Holiday", TargetAmount: 2000, RoundUp: true})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	pot := new(Pot)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(pot))
+	potPath := path + "/" + strconv.Itoa(pot.ID)
+
+	rec = api.do("POST", path, token, PotRequest{Name: "Holiday", TargetAmount: 1})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", potPath+"/deposit", token, AmountRequest{Amount: 1500})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", potPath+"/withdraw", token, AmountRequest{Amount: 2000})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 8600})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeInsufficientFunds))
+
+	// the round-up of 8450 tops the pot up to 1550
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 8450})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", potPath+"/progress", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	progress := new(PotProgress)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(progress))
+	assert.Equal(t, int64(1550), progress.Balance)
+	assert.Equal(t, int64(450), progress.Remaining)
+	assert.Equal(t, 77, progress.Percent)
+
+	rec = api.do("PUT", potPath, token, PotRequest{Name: "Summer", TargetAmount: 1500, WeeklyAmount: 100})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(pot))
+	assert.Equal(t, int64(1550), pot.Balance)
+	assert.NotNil(t, pot.NextSweepAt)
+
+	rec = api.do("GET", path+"/progress", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var all []*PotProgress
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&all))
+	require.Len(t, all, 1)
+	assert.True(t, all[0].Reached)
+
+	rec = api.do("GET", potPath, api.login(bob, "bob-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("DELETE", potPath, token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID), token, nil)
+	assert.Contains(t, rec.Body.String(), `"balance":1550`)
+	assert.Contains(t, rec.Body.String(), `"potBalance":0`)
+
+	rec = api.do("GET", potPath, token, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const rateLimitSweepInterval = 5 * time.Minute
+
+// RateLimiter is a token bucket per key: it holds up to burst tokens, refills
+// at rate tokens per second and every request takes one.
+type RateLimiter interface {
+	// Allow takes a token for key. When the bucket is empty it returns false
+	// and how long until the next token is available.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket up to now and takes a token if there is one.
+func (b *tokenBucket) take(now time.Time, rate, burst float64) (bool, time.Duration) {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// MemoryRateLimiter keeps the buckets in process memory, so every instance of
+// the server enforces its own limit.
+type MemoryRateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimiter(rate float64, burst int) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	allowed, retryAfter := b.take(now, l.rate, l.burst)
+
+	return allowed, retryAfter, nil
+}
+
+// sweep forgets buckets that have refilled completely, they are the same as
+// new ones.
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// redisTokenBucket implements tokenBucket.take atomically in Redis, using
+// the Redis clock so all instances agree on time.
+var redisTokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`)
+
+// RedisRateLimiter shares the buckets between all instances of the server.
+type RedisRateLimiter struct {
+	client *redis.Client
+	rate   float64
+	burst  int
+}
+
+func NewRedisRateLimiter(client *redis.Client, rate float64, burst int) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		rate:   rate,
+		burst:  burst,
+	}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	res, err := redisTokenBucket.Run(ctx, l.client, []string{"ratelimit:" + key}, l.rate, l.burst).Int64Slice()
+
+	if err != nil {
+		return false, 0, err
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// rateLimitKey limits authenticated requests per account and anonymous ones
+// per client IP.
+func rateLimitKey(r *http.Request) string {
+	if number, err := getAccountNumberFromToken(r); err == nil {
+		return "account:" + strconv.FormatInt(number, 10)
+	}
+
+	return "ip:" + clientIP(r.RemoteAddr)
+}
+
+// clientIP strips the port from a remote address.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
+}
+
+// withRateLimit answers 429 with a Retry-After header once the caller's
+// bucket is empty. If the limiter fails the request is let through rather
+// than taking the API down with it.
+func withRateLimit(limiter RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := limiter.Allow(r.Context(), rateLimitKey(r))
+
+			if err != nil {
+				slog.ErrorContext(r.Context(), "rate limiter failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				writeError(w, r, tooManyRequestsError())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	limiter := NewMemoryRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		allowed, _, _ := limiter.Allow(ctx, "a")
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter, _ := limiter.Allow(ctx, "a")
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, retryAfter)
+
+	allowed, _, _ = limiter.Allow(ctx, "b")
+	assert.True(t, allowed)
+
+	now = now.Add(time.Second)
+	allowed, _, _ = limiter.Allow(ctx, "a")
+	assert.True(t, allowed)
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	limiter := NewRedisRateLimiter(redis.NewClient(&redis.Options{Addr: server.Addr()}), 1, 2)
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := limiter.Allow(ctx, "a")
+		require.Nil(t, err)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter, err := limiter.Allow(ctx, "a")
+	require.Nil(t, err)
+	assert.False(t, allowed)
+	assert.InDelta(t, time.Second, retryAfter, float64(10*time.Millisecond))
+}
+
+func TestWithRateLimit(t *testing.T) {
+	handler := withRateLimit(NewMemoryRateLimiter(1, 1))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/account", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	req.RemoteAddr = "10.0.0.2:1234"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// receiptAlgorithm is how receipts are signed.
+const receiptAlgorithm = "HMAC-SHA256"
+
+// TransferReceipt is a signed copy of a completed transfer. Whoever keeps
+// it can later have the bank check that neither the receipt nor the
+// transfer it records was changed.
+type TransferReceipt struct {
+	TransferID  int       `json:"transferId"`
+	FromAccount int64     `json:"fromAccount"`
+	ToAccount   int64     `json:"toAccount"`
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	ToAmount    int64     `json:"toAmount"`
+	ToCurrency  string    `json:"toCurrency"`
+	Rate        string    `json:"rate,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	TransferReference
+
+	IssuedAt  time.Time `json:"issuedAt"`
+	Algorithm string    `json:"algorithm"`
+	Signature string    `json:"signature"`
+}
+
+// TransferReceiptVerification is the outcome of checking a receipt.
+type TransferReceiptVerification struct {
+	// SignatureValid is whether the bank issued the receipt as it is.
+	SignatureValid bool `json:"signatureValid"`
+	// RecordMatches is whether the stored transfer still says what the
+	// receipt does.
+	RecordMatches bool `json:"recordMatches"`
+}
+
+// ReceiptSigner signs and checks transfer receipts with the receipt key.
+type ReceiptSigner struct {
+	key []byte
+}
+
+// newReceiptSigner returns nil when there is no key, receipts are disabled
+// then.
+func newReceiptSigner(key string) *ReceiptSigner {
+	if key == "" {
+		return nil
+	}
+
+	return &ReceiptSigner{key: []byte(key)}
+}
+
+// Issue returns the signed receipt of transfer at now.
+func (s *ReceiptSigner) Issue(transfer *Transfer, now time.Time) *TransferReceipt {
+	receipt := &TransferReceipt{
+		TransferID:        transfer.ID,
+		FromAccount:       transfer.FromAccount,
+		ToAccount:         transfer.ToAccount,
+		Amount:            transfer.Amount,
+		Currency:          transfer.Currency,
+		ToAmount:          transfer.ToAmount,
+		ToCurrency:        transfer.ToCurrency,
+		Rate:              transfer.Rate,
+		CreatedAt:         transfer.CreatedAt.UTC(),
+		TransferReference: transfer.TransferReference,
+		IssuedAt:          now.UTC(),
+		Algorithm:         receiptAlgorithm,
+	}
+	receipt.Signature = hex.EncodeToString(s.sign(receipt))
+
+	return receipt
+}
+
+// Verify reports whether the bank signed receipt as it is.
+func (s *ReceiptSigner) Verify(receipt *TransferReceipt) bool {
+	signature, err := hex.DecodeString(receipt.Signature)
+
+	if err != nil || receipt.Algorithm != receiptAlgorithm {
+		return false
+	}
+
+	return hmac.Equal(signature, s.sign(receipt))
+}
+
+// sign returns the HMAC of every field of receipt but the signature, one
+// per line with the strings quoted so no two receipts share a payload.
+func (s *ReceiptSigner) sign(receipt *TransferReceipt) []byte {
+	mac := hmac.New(sha256.New, s.key)
+
+	fmt.Fprintf(mac, "%d\n%d\n%d\n%d\n%q\n%d\n%q\n%q\n%s\n%q\n%q\n%q\n%s\n%q",
+		receipt.TransferID,
+		receipt.FromAccount,
+		receipt.ToAccount,
+		receipt.Amount,
+		receipt.Currency,
+		receipt.ToAmount,
+		receipt.ToCurrency,
+		receipt.Rate,
+		receipt.CreatedAt.UTC().Format(time.RFC3339Nano),
+		receipt.Reference,
+		receipt.Memo,
+		receipt.EndToEndID,
+		receipt.IssuedAt.UTC().Format(time.RFC3339Nano),
+		receipt.Algorithm,
+	)
+
+	return mac.Sum(nil)
+}
+
+// Matches reports whether receipt records transfer as it is stored.
+func (r *TransferReceipt) Matches(transfer *Transfer) bool {
+	return r.TransferID == transfer.ID &&
+		r.FromAccount == transfer.FromAccount &&
+		r.ToAccount == transfer.ToAccount &&
+		r.Amount == transfer.Amount &&
+		r.Currency == transfer.Currency &&
+		r.ToAmount == transfer.ToAmount &&
+		r.ToCurrency == transfer.ToCurrency &&
+		r.Rate == transfer.Rate &&
+		r.CreatedAt.Equal(transfer.CreatedAt) &&
+		r.TransferReference == transfer.TransferReference
+}
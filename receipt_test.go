@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testReceiptKey = "receipt-key-of-at-least-32-chars"
+
+func TestReceiptSigner(t *testing.T) {
+	signer := newReceiptSigner(testReceiptKey)
+	transfer := &Transfer{ID: 7, FromAccount: 42, ToAccount: 43, Amount: 100, Currency: "USD", ToAmount: 100, ToCurrency: "USD", CreatedAt: time.Now(), TransferReference: TransferReference{Memo: "Rent"}}
+
+	receipt := signer.Issue(transfer, time.Now())
+	assert.Equal(t, receiptAlgorithm, receipt.Algorithm)
+	assert.True(t, signer.Verify(receipt))
+	assert.True(t, receipt.Matches(transfer))
+
+	payload, err := json.Marshal(receipt)
+	require.Nil(t, err)
+
+	decoded := new(TransferReceipt)
+	require.Nil(t, json.Unmarshal(payload, decoded))
+	assert.True(t, signer.Verify(decoded), "receipts survive the round trip through JSON")
+
+	forged := *receipt
+	forged.Amount = 1
+	assert.False(t, signer.Verify(&forged))
+
+	forged = *receipt
+	forged.Memo = "Rent\"\n"
+	assert.False(t, signer.Verify(&forged))
+
+	assert.False(t, newReceiptSigner("another-receipt-key-of-32-chars!").Verify(receipt))
+	assert.Nil(t, newReceiptSigner(""))
+
+	changed := *transfer
+	changed.ToAccount = 44
+	assert.False(t, receipt.Matches(&changed))
+}
+
+func TestAPITransferReceipt(t *testing.T) {
+	cfg := testConfig()
+	cfg.ReceiptKey = testReceiptKey
+	api := newTestAPIWithConfig(t, cfg)
+
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	eve := api.createAccount("Eve", "eve-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+
+	_, err := api.store.Deposit(context.Background(), alice.Number, 1000, 0)
+	require.Nil(t, err)
+
+	aliceToken := api.login(alice, "alice-pw")
+
+	rec := api.do("POST", "/transfer", aliceToken, TransferRequest{ToAccount: int(bob.Number), Amount: 100})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	transfer := new(Transfer)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(transfer))
+
+	path := fmt.Sprintf("/transfer/%d/receipt", transfer.ID)
+
+	for _, token := range []string{api.login(bob, "bob-pw"), api.login(admin, "admin-pw")} {
+		rec = api.do("GET", path, token, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	eveToken := api.login(eve, "eve-pw")
+
+	rec = api.do("GET", path, eveToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("GET", path, aliceToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	receipt := new(TransferReceipt)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(receipt))
+	assert.Equal(t, transfer.ID, receipt.TransferID)
+	assert.NotEmpty(t, receipt.Signature)
+
+	verify := func(token string, receipt *TransferReceipt) *TransferReceiptVerification {
+		rec := api.do("POST", "/transfer/receipt/verify", token, receipt)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		verification := new(TransferReceiptVerification)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(verification))
+
+		return verification
+	}
+
+	assert.Equal(t, &TransferReceiptVerification{SignatureValid: true, RecordMatches: true}, verify(aliceToken, receipt))
+
+	forged := *receipt
+	forged.Amount = 10
+	assert.Equal(t, &TransferReceiptVerification{}, verify(aliceToken, &forged))
+
+	rec = api.do("POST", "/transfer/receipt/verify", eveToken, receipt)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "strangers learn nothing about the transfer")
+
+	api.store.transfers[0].Amount = 90
+	assert.Equal(t, &TransferReceiptVerification{SignatureValid: true}, verify(aliceToken, receipt), "the record was tampered with")
+}
+
+func TestAPITransferReceiptDisabled(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+
+	rec := api.do("GET", "/transfer/1/receipt", api.login(alice, "alice-pw"), nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "not configured")
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	reconciliationInterval     = time.Hour
+	reconciliationAlertTimeout = 10 * time.Second
+)
+
+var reconciliationDiscrepancies = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "bank_reconciliation_discrepancies",
+	Help: "Unbalanced entries, mismatched accounts and off currency totals found by the last reconciliation.",
+})
+
+// ReconciliationReport is a stored ledger integrity check: every account
+// balance recomputed from its journal lines and compared to the stored one.
+type ReconciliationReport struct {
+	ID int `json:"id"`
+	LedgerIntegrityReport
+}
+
+// Discrepancies counts what the report found off.
+func (r *ReconciliationReport) Discrepancies() int {
+	n := len(r.UnbalancedEntries) + len(r.MismatchedAccounts)
+
+	for _, total := range r.Totals {
+		if total != 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ReconciliationAlerter is told about every reconciliation that found the
+// books off.
+type ReconciliationAlerter interface {
+	Alert(ctx context.Context, report *ReconciliationReport) error
+}
+
+// NewReconciliationAlerter posts alerts to the configured URL, or logs them
+// when there is none.
+func NewReconciliationAlerter(cfg *Config) ReconciliationAlerter {
+	if cfg.ReconciliationAlertURL != "" {
+		return &WebhookReconciliationAlerter{
+			url:    cfg.ReconciliationAlertURL,
+			client: &http.Client{Timeout: reconciliationAlertTimeout},
+		}
+	}
+
+	return LogReconciliationAlerter{}
+}
+
+// LogReconciliationAlerter writes alerts to the server log.
+type LogReconciliationAlerter struct{}
+
+func (LogReconciliationAlerter) Alert(ctx context.Context, report *ReconciliationReport) error {
+	slog.ErrorContext(ctx, "reconciliation found discrepancies",
+		"report", report.ID,
+		"unbalancedEntries", len(report.UnbalancedEntries),
+		"mismatchedAccounts", len(report.MismatchedAccounts),
+	)
+
+	return nil
+}
+
+// WebhookReconciliationAlerter posts the report as JSON, e.g. to an
+// incident management or chat integration.
+type WebhookReconciliationAlerter struct {
+	url    string
+	client *http.Client
+}
+
+func (a *WebhookReconciliationAlerter) Alert(ctx context.Context, report *ReconciliationReport) error {
+	payload, err := json.Marshal(report)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.url, bytes.NewReader(payload))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("reconciliation alert got status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Reconciler checks the books once a day at the configured hour, UTC, stores
+// the report and raises an alert when anything is off. A day missed while
+// not running is reconciled once the reconciler is back.
+type Reconciler struct {
+	store   Storage
+	alerter ReconciliationAlerter
+	hour    int
+}
+
+func NewReconciler(cfg *Config, store Storage) *Reconciler {
+	return &Reconciler{store: store, alerter: NewReconciliationAlerter(cfg), hour: cfg.ReconciliationHour}
+}
+
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(reconciliationInterval)
+	defer ticker.Stop()
+
+	for {
+		r.reconcileDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileDue reconciles unless the last report was made after the most
+// recent scheduled time.
+func (r *Reconciler) reconcileDue(ctx context.Context, now time.Time) {
+	due := now.Truncate(24 * time.Hour).Add(time.Duration(r.hour) * time.Hour)
+
+	if due.After(now) {
+		due = due.AddDate(0, 0, -1)
+	}
+
+	reports, err := r.store.GetReconciliationReports(ctx, 1, 0)
+
+	if err != nil {
+		slog.Error("finding the last reconciliation", "error", err)
+		return
+	}
+
+	if len(reports) == 1 && !reports[0].CheckedAt.Before(due) {
+		return
+	}
+
+	if _, err := r.Reconcile(ctx); err != nil {
+		slog.Error("reconciling the ledger", "error", err)
+	}
+}
+
+// Reconcile checks the books now and stores the report, alerting when it
+// found discrepancies. A failed alert is logged, the report is kept.
+func (r *Reconciler) Reconcile(ctx context.Context) (*ReconciliationReport, error) {
+	integrity, err := r.store.CheckLedgerIntegrity(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{LedgerIntegrityReport: *integrity}
+
+	if err := r.store.CreateReconciliationReport(ctx, report); err != nil {
+		return nil, err
+	}
+
+	reconciliationDiscrepancies.Set(float64(report.Discrepancies()))
+
+	if report.Balanced {
+		slog.InfoContext(ctx, "ledger reconciled", "report", report.ID, "entries", report.Entries)
+		return report, nil
+	}
+
+	if err := r.alerter.Alert(ctx, report); err != nil {
+		slog.ErrorContext(ctx, "sending reconciliation alert", "error", err, "report", report.ID)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingAlerter struct {
+	reports []*ReconciliationReport
+}
+
+func (a *recordingAlerter) Alert(ctx context.Context, report *ReconciliationReport) error {
+	a.reports = append(a.reports, report)
+	return nil
+}
+
+func TestReconcilerReconcileDue(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	alerter := &recordingAlerter{}
+	reconciler := &Reconciler{store: store, alerter: alerter, hour: 2}
+
+	acc, err := NewAccount("Alice", "Test", "alice-pw")
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	_, err = store.Deposit(ctx, acc.Number, 1000, 0)
+	require.Nil(t, err)
+
+	now := time.Now().UTC()
+	reconciler.reconcileDue(ctx, now)
+
+	reports, err := store.GetReconciliationReports(ctx, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, reports, 1, "a missed day is reconciled right away")
+	assert.True(t, reports[0].Balanced)
+	assert.Empty(t, alerter.reports)
+
+	reconciler.reconcileDue(ctx, now.Add(time.Minute))
+
+	reports, err = store.GetReconciliationReports(ctx, 10, 0)
+	require.Nil(t, err)
+	assert.Len(t, reports, 1, "a day is reconciled once")
+
+	store.accounts[acc.ID].Balance += 5
+
+	reconciler.reconcileDue(ctx, now.Add(24*time.Hour))
+
+	reports, err = store.GetReconciliationReports(ctx, 10, 0)
+	require.Nil(t, err)
+	require.Len(t, reports, 2)
+	assert.False(t, reports[0].Balanced, "newest first")
+	assert.Equal(t, []LedgerMismatch{{AccountNumber: acc.Number, Balance: 1005, LedgerBalance: 1000}}, reports[0].MismatchedAccounts)
+	assert.Equal(t, 1, reports[0].Discrepancies())
+
+	require.Len(t, alerter.reports, 1)
+	assert.Equal(t, reports[0].ID, alerter.reports[0].ID)
+}
+
+func TestWebhookReconciliationAlerter(t *testing.T) {
+	received := make(chan *ReconciliationReport, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := new(ReconciliationReport)
+		require.Nil(t, json.NewDecoder(r.Body).Decode(report))
+		received <- report
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	cfg.ReconciliationAlertURL = server.URL
+
+	alerter := NewReconciliationAlerter(cfg)
+	report := &ReconciliationReport{ID: 7, LedgerIntegrityReport: LedgerIntegrityReport{MismatchedAccounts: []LedgerMismatch{{AccountNumber: 42, Balance: 5}}}}
+
+	require.Nil(t, alerter.Alert(context.Background(), report))
+	assert.Equal(t, 7, (<-received).ID)
+}
+
+func TestAPIReconciliationReports(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	adminToken := api.login(admin, "admin-pw")
+
+	rec := api.do("POST", "/admin/reconciliation/reports", api.login(alice, "alice-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/admin/reconciliation/reports", adminToken, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	report := new(ReconciliationReport)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(report))
+	assert.True(t, report.Balanced)
+
+	rec = api.do("GET", "/admin/reconciliation/reports", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var reports []*ReconciliationReport
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&reports))
+	require.Len(t, reports, 1)
+	assert.Equal(t, report.ID, reports[0].ID)
+
+	rec = api.do("GET", fmt.Sprintf("/admin/reconciliation/reports/%d", report.ID), adminToken, nil)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/reconciliation/reports/999", adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
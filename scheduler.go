@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	schedulerInterval        = 30 * time.Second
+	schedulerBatchSize       = 50
+	scheduledTransferLease   = 5 * time.Minute
+	maxScheduledTransferRuns = 5
+)
+
+// nextOccurrence returns when the n-th occurrence (0 based) of a transfer
+// starting at start runs. Monthly transfers keep the day of month of start,
+// clamped to the last day of shorter months.
+func nextOccurrence(start time.Time, recurrence Recurrence, n int) time.Time {
+	switch recurrence {
+	case RecurrenceDaily:
+		return start.AddDate(0, 0, n)
+	case RecurrenceWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case RecurrenceMonthly:
+		year, month, day := start.Date()
+		first := time.Date(year, month+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		last := first.AddDate(0, 1, -1).Day()
+
+		if day > last {
+			day = last
+		}
+
+		return first.AddDate(0, 0, day-1)
+	}
+
+	return start
+}
+
+// retryBackoff doubles the delay after every failed attempt, starting at one
+// minute.
+func retryBackoff(attempts int) time.Duration {
+	return time.Minute << (attempts - 1)
+}
+
+// Scheduler executes due scheduled transfers. Several schedulers may run
+// against the same database: due rows are leased before they are executed.
+type Scheduler struct {
+	store  Storage
+	rates  ExchangeRateProvider
+	events EventPublisher
+}
+
+func NewScheduler(store Storage, rates ExchangeRateProvider, events EventPublisher) *Scheduler {
+	return &Scheduler{
+		store:  store,
+		rates:  rates,
+		events: events,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	due, err := s.store.ClaimDueScheduledTransfers(ctx, now, now.Add(scheduledTransferLease), schedulerBatchSize)
+
+	if err != nil {
+		slog.Error("claiming scheduled transfers", "error", err)
+		return
+	}
+
+	for _, st := range due {
+		run := s.execute(ctx, st, now)
+
+		if err := s.store.RecordScheduledTransferRun(ctx, st, run); err != nil {
+			slog.Error("recording scheduled transfer run", "error", err, "scheduledTransferId", st.ID)
+		}
+	}
+}
+
+// execute runs st once and advances its state for the next run.
+func (s *Scheduler) execute(ctx context.Context, st *ScheduledTransfer, now time.Time) *ScheduledTransferRun {
+	run := &ScheduledTransferRun{
+		ScheduledTransferID: st.ID,
+		RanAt:               now,
+	}
+
+	transfer, err := newTransfer(ctx, s.store, s.rates, st.FromAccount, st.ToAccount, st.Amount)
+
+	if err == nil {
+		err = s.store.Transfer(ctx, transfer)
+	}
+
+	if err == nil {
+		publishTransferEvents(ctx, s.events, s.store, transfer)
+
+		run.TransferID = &transfer.ID
+		st.LastError = ""
+		st.advance()
+
+		return run
+	}
+
+	run.Error = err.Error()
+	st.LastError = err.Error()
+	st.Attempts++
+
+	if st.Attempts < maxScheduledTransferRuns {
+		st.NextRunAt = now.Add(retryBackoff(st.Attempts))
+		return run
+	}
+
+	slog.Warn("scheduled transfer gave up", "scheduledTransferId", st.ID, "error", err)
+
+	if st.Recurrence == RecurrenceNone {
+		st.Status = ScheduledTransferFailed
+		return run
+	}
+
+	st.advance()
+
+	return run
+}
+
+// advance moves st to its next occurrence, completing one-off transfers.
+func (st *ScheduledTransfer) advance() {
+	st.Attempts = 0
+	st.Occurrence++
+
+	if st.Recurrence == RecurrenceNone {
+		st.Status = ScheduledTransferCompleted
+		return
+	}
+
+	st.NextRunAt = nextOccurrence(st.StartAt, st.Recurrence, st.Occurrence)
+}
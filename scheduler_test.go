@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, start, nextOccurrence(start, RecurrenceNone, 3))
+	assert.Equal(t, time.Date(2024, time.February, 3, 9, 0, 0, 0, time.UTC), nextOccurrence(start, RecurrenceDaily, 3))
+	assert.Equal(t, time.Date(2024, time.February, 14, 9, 0, 0, 0, time.UTC), nextOccurrence(start, RecurrenceWeekly, 2))
+	assert.Equal(t, time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC), nextOccurrence(start, RecurrenceMonthly, 1))
+	assert.Equal(t, time.Date(2024, time.March, 31, 9, 0, 0, 0, time.UTC), nextOccurrence(start, RecurrenceMonthly, 2))
+	assert.Equal(t, time.Date(2025, time.January, 31, 9, 0, 0, 0, time.UTC), nextOccurrence(start, RecurrenceMonthly, 12))
+}
+
+func TestScheduledTransferAdvance(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	st := &ScheduledTransfer{Recurrence: RecurrenceWeekly, Status: ScheduledTransferActive, StartAt: start, NextRunAt: start, Attempts: 2}
+	st.advance()
+	assert.Equal(t, 0, st.Attempts)
+	assert.Equal(t, ScheduledTransferActive, st.Status)
+	assert.Equal(t, start.AddDate(0, 0, 7), st.NextRunAt)
+
+	st = &ScheduledTransfer{Recurrence: RecurrenceNone, Status: ScheduledTransferActive, StartAt: start, NextRunAt: start}
+	st.advance()
+	assert.Equal(t, ScheduledTransferCompleted, st.Status)
+}
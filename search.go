@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	minSearchQueryLength = 2
+	maxSearchQueryLength = 100
+
+	// searchSimilarityThreshold is the default of pg_trgm's % operator.
+	searchSimilarityThreshold = 0.3
+)
+
+// checkSearchQuery trims q and checks its length for GET /account/search.
+func checkSearchQuery(q string) (string, error) {
+	q = strings.TrimSpace(q)
+
+	if n := len([]rune(q)); n < minSearchQueryLength || n > maxSearchQueryLength {
+		return "", badRequestError("q must be between %d and %d characters", minSearchQueryLength, maxSearchQueryLength)
+	}
+
+	return q, nil
+}
+
+// likePattern matches q anywhere in a string, with LIKE wildcards in q
+// taken literally.
+func likePattern(q string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q)
+
+	return "%" + escaped + "%"
+}
+
+// accountSearchScore ranks acc against q the way the Postgres search does:
+// the best trigram similarity to the first, last or full name, plus one when
+// q appears verbatim in the full name or the account number. A zero score
+// means no match.
+func accountSearchScore(acc *Account, q string) float64 {
+	fullName := acc.FirstName + " " + acc.LastName
+	score := max(trigramSimilarity(acc.FirstName, q), trigramSimilarity(acc.LastName, q), trigramSimilarity(fullName, q))
+
+	if strings.Contains(strings.ToLower(fullName), strings.ToLower(q)) || strings.Contains(strconv.FormatInt(acc.Number, 10), q) {
+		return score + 1
+	}
+
+	if score < searchSimilarityThreshold {
+		return 0
+	}
+
+	return score
+}
+
+// trigramSimilarity mirrors pg_trgm's similarity: the share of trigrams the
+// two strings have in common, where each word is lower-cased and padded with
+// two spaces before and one after.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	common := 0
+
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+
+	return set
+}
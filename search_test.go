@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrigramSimilarity(t *testing.T) {
+	// the example of the pg_trgm documentation
+	assert.InDelta(t, 0.363636, trigramSimilarity("word", "two words"), 0.0001)
+	assert.Equal(t, 1.0, trigramSimilarity("Alice", "alice"))
+	assert.Equal(t, 0.0, trigramSimilarity("", "alice"))
+}
+
+func TestLikePattern(t *testing.T) {
+	assert.Equal(t, `%50\%\_off\\%`, likePattern(`50%_off\`))
+}
+
+func TestAPISearchAccounts(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	api.createAccount("Alicia", "alicia-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	adminToken := api.login(admin, "admin-pw")
+
+	search := func(q string) []*Account {
+		rec := api.do("GET", "/account/search?q="+q, adminToken, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		accounts := []*Account{}
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(&accounts))
+
+		return accounts
+	}
+
+	// a typo still finds the closest name first
+	accounts := search("Alise")
+	require.NotEmpty(t, accounts)
+	assert.Equal(t, alice.ID, accounts[0].ID)
+
+	accounts = search("alic")
+	require.Len(t, accounts, 2)
+
+	accounts = search(strconv.FormatInt(bob.Number, 10)[2:8])
+	require.Len(t, accounts, 1)
+	assert.Equal(t, bob.ID, accounts[0].ID)
+
+	assert.Empty(t, search("zzzz"))
+
+	rec := api.do("GET", "/account/search?q=a", adminToken, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = api.do("GET", "/account/search?q=Alice", api.login(bob, "bob-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("DELETE", "/account/"+strconv.Itoa(alice.ID), api.login(alice, "alice-pw"), nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	for _, acc := range search("Alice") {
+		assert.NotEqual(t, alice.ID, acc.ID)
+	}
+}
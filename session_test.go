@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreSessions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	phone := NewSession(42, Device{UserAgent: "phone", IP: "10.0.0.1"}, now)
+	laptop := NewSession(42, Device{UserAgent: "laptop", IP: "10.0.0.2"}, now)
+	require.Nil(t, store.CreateSession(ctx, phone))
+	require.Nil(t, store.CreateSession(ctx, laptop))
+
+	for i, session := range []*Session{phone, laptop} {
+		token := &RefreshToken{AccountNumber: 42, SessionID: session.ID, TokenHash: strconv.Itoa(i), ExpiresAt: now.Add(time.Hour)}
+		require.Nil(t, store.CreateRefreshToken(ctx, token))
+	}
+
+	next := &RefreshToken{TokenHash: "next", ExpiresAt: now.Add(time.Hour)}
+	require.Nil(t, store.RotateRefreshToken(ctx, "0", next, Device{UserAgent: "phone 2", IP: "10.0.0.3"}))
+	assert.Equal(t, phone.ID, next.SessionID, "rotated tokens stay in their session")
+
+	sessions, err := store.GetSessions(ctx, 42, now)
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "phone 2", sessions[0].UserAgent, "the most recently seen first")
+
+	revoked, err := store.RevokeSessions(ctx, 42, phone.ID, now, now.Add(time.Minute))
+	require.Nil(t, err)
+	assert.Len(t, revoked, 1)
+
+	_, err = store.RevokeSessions(ctx, 43, laptop.ID, now, now.Add(time.Minute))
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+
+	denied, err := store.SessionDenied(ctx, phone.ID, now)
+	require.Nil(t, err)
+	assert.True(t, denied)
+
+	denied, err = store.SessionDenied(ctx, phone.ID, now.Add(time.Minute))
+	require.Nil(t, err)
+	assert.False(t, denied, "tokens are denied until they expire")
+
+	err = store.RotateRefreshToken(ctx, "next", &RefreshToken{TokenHash: "again"}, Device{})
+	assert.ErrorContains(t, err, "session revoked")
+	assert.Nil(t, store.RotateRefreshToken(ctx, "1", &RefreshToken{TokenHash: "laptop", ExpiresAt: now.Add(time.Hour)}, Device{}), "other sessions are left alone")
+}
+
+func TestAPISessions(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	sessions := "/account/" + strconv.Itoa(alice.ID) + "/sessions"
+
+	login := func(userAgent string) *LoginResponse {
+		body := `{"number": ` + strconv.FormatInt(alice.Number, 10) + `, "password": "alice-pw"}`
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+
+		rec := httptest.NewRecorder()
+		api.handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		resp := new(LoginResponse)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(resp))
+
+		return resp
+	}
+
+	phone := login("phone")
+	laptop := login("laptop")
+
+	rec := api.do("GET", sessions, laptop.Token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var listed []*Session
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&listed))
+	require.Len(t, listed, 2)
+	assert.Equal(t, "laptop", listed[0].UserAgent)
+	assert.True(t, listed[0].Current)
+	assert.False(t, listed[1].Current)
+
+	rec = api.do("GET", sessions, api.login(bob, "bob-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("DELETE", sessions+"/"+strconv.Itoa(listed[1].ID), laptop.Token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", sessions, phone.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "the revoked session's access token is denied")
+
+	rec = api.do("POST", "/token/refresh", "", RefreshTokenRequest{RefreshToken: phone.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/token/refresh", "", RefreshTokenRequest{RefreshToken: laptop.RefreshToken})
+	require.Equal(t, http.StatusOK, rec.Code, "refreshing a revoked session doesn't log out the others")
+
+	refreshed := new(LoginResponse)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(refreshed))
+
+	rec = api.do("DELETE", sessions+"/"+strconv.Itoa(listed[1].ID), refreshed.Token, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("DELETE", sessions, refreshed.Token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", sessions, refreshed.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	entries, err := api.store.GetAuditLog(context.Background(), 10, 0)
+	require.Nil(t, err)
+	revocations := 0
+
+	for _, entry := range entries {
+		if entry.Action == AuditSessionsRevoked {
+			revocations++
+		}
+	}
+
+	assert.Equal(t, 2, revocations)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// legacyKeyID is the id of JWTSecret in the keyring. Tokens signed before
+// keys had ids carry no kid and are checked against it.
+const legacyKeyID = "default"
+
+// SigningKey is an HMAC key access tokens are signed and checked with,
+// named in their kid header.
+type SigningKey struct {
+	ID     string `json:"kid"`
+	Secret string `json:"secret"`
+	// Retired keys no longer check tokens, so the sessions they signed end.
+	Retired bool `json:"retired,omitempty"`
+}
+
+// KeySource loads the signing keys, oldest first. The newest key that is not
+// retired signs new tokens; the others only check the tokens they signed
+// until those expire.
+type KeySource interface {
+	SigningKeys(ctx context.Context) ([]*SigningKey, error)
+}
+
+// NewKeySource returns the configured key source.
+func NewKeySource(cfg *Config) KeySource {
+	switch cfg.JWTKeySource {
+	case "file":
+		return FileKeySource{Path: cfg.JWTKeysFile}
+	case "kms":
+		return KMSKeySource{}
+	}
+
+	keys, _ := cfg.JWTKeyList()
+
+	return StaticKeySource(keys)
+}
+
+// StaticKeySource is the keys of the configuration, JWTSecret and JWTKeys.
+type StaticKeySource []*SigningKey
+
+func (s StaticKeySource) SigningKeys(ctx context.Context) ([]*SigningKey, error) {
+	return s, nil
+}
+
+// FileKeySource reads the keys from a JSON array of {"kid", "secret",
+// "retired"} objects, so they can be rotated by rewriting the file.
+type FileKeySource struct {
+	Path string
+}
+
+func (s FileKeySource) SigningKeys(ctx context.Context) ([]*SigningKey, error) {
+	data, err := os.ReadFile(s.Path)
+
+	if err != nil {
+		return nil, fmt.Errorf("reading signing keys: %w", err)
+	}
+
+	var keys []*SigningKey
+
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("reading signing keys from %s: %w", s.Path, err)
+	}
+
+	return keys, nil
+}
+
+// KMSKeySource stands in for keys kept in a key management service. It
+// fails every load until a client is wired in.
+type KMSKeySource struct{}
+
+func (KMSKeySource) SigningKeys(ctx context.Context) ([]*SigningKey, error) {
+	return nil, errors.New("kms key source: no KMS client configured")
+}
+
+// keyring is a loaded set of signing keys.
+type keyring struct {
+	// signing is the newest key that is not retired.
+	signing *SigningKey
+	byID    map[string]*SigningKey
+}
+
+// newKeyring checks keys and indexes them by id.
+func newKeyring(keys []*SigningKey) (*keyring, error) {
+	ring := &keyring{byID: map[string]*SigningKey{}}
+
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing keys must have a kid")
+		}
+
+		if ring.byID[key.ID] != nil {
+			return nil, fmt.Errorf("signing key %s is listed twice", key.ID)
+		}
+
+		if len(key.Secret) < minJWTSecretLength {
+			return nil, fmt.Errorf("signing key %s must be at least %d characters", key.ID, minJWTSecretLength)
+		}
+
+		ring.byID[key.ID] = key
+
+		if !key.Retired {
+			ring.signing = key
+		}
+	}
+
+	if ring.signing == nil {
+		return nil, errors.New("no signing key that is not retired")
+	}
+
+	return ring, nil
+}
+
+// verifying returns the key that checks tokens signed with kid, if it is
+// not retired.
+func (r *keyring) verifying(kid string) (*SigningKey, bool) {
+	if kid == "" {
+		kid = legacyKeyID
+	}
+
+	key, ok := r.byID[kid]
+
+	if !ok || key.Retired {
+		return nil, false
+	}
+
+	return key, true
+}
+
+// parseSigningKeys parses a comma separated list of kid:secret pairs, oldest
+// first.
+func parseSigningKeys(value string) ([]*SigningKey, error) {
+	var keys []*SigningKey
+
+	for i, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		kid, secret, ok := strings.Cut(pair, ":")
+
+		// the pair is not quoted, it may be a bare secret
+		if !ok {
+			return nil, fmt.Errorf("key %d must be kid:secret", i+1)
+		}
+
+		keys = append(keys, &SigningKey{ID: strings.TrimSpace(kid), Secret: secret})
+	}
+
+	return keys, nil
+}
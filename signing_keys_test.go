@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenIssuerKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys := func(keys string) {
+		require.Nil(t, os.WriteFile(path, []byte(keys), 0o600))
+	}
+
+	writeKeys(`[{"kid": "2024-01", "secret": "first-secret-of-some-length"}]`)
+
+	cfg := testConfig()
+	cfg.JWTKeySource = "file"
+	cfg.JWTKeysFile = path
+	tokens := NewTokenIssuer(cfg)
+	acc := &Account{Number: 42, Role: RoleCustomer}
+
+	old, err := tokens.CreateAccessToken(acc, 0)
+	require.Nil(t, err)
+
+	writeKeys(`[{"kid": "2024-01", "secret": "first-secret-of-some-length"}, {"kid": "2024-02", "secret": "second-secret-of-some-length"}]`)
+	require.Nil(t, tokens.ReloadKeys(ctx))
+
+	current, err := tokens.CreateAccessToken(acc, 0)
+	require.Nil(t, err)
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(current, jwt.MapClaims{})
+	require.Nil(t, err)
+	assert.Equal(t, "2024-02", parsed.Header["kid"], "new tokens are signed with the newest key")
+
+	for _, token := range []string{old, current} {
+		number, err := tokens.AccountNumber(token)
+		require.Nil(t, err)
+		assert.Equal(t, int64(42), number)
+	}
+
+	writeKeys(`[{"kid": "2024-01", "secret": "first-secret-of-some-length", "retired": true}, {"kid": "2024-02", "secret": "second-secret-of-some-length"}]`)
+	require.Nil(t, tokens.ReloadKeys(ctx))
+
+	_, err = tokens.AccountNumber(old)
+	assert.NotNil(t, err, "retired keys no longer check tokens")
+
+	_, err = tokens.AccountNumber(current)
+	assert.Nil(t, err)
+
+	writeKeys(`[{"kid": "2024-02", "secret": "second-secret-of-some-length", "retired": true}]`)
+	assert.ErrorContains(t, tokens.ReloadKeys(ctx), "no signing key")
+
+	_, err = tokens.AccountNumber(current)
+	assert.Nil(t, err, "invalid keys don't replace the loaded ones")
+}
+
+func TestTokenIssuerLegacyTokens(t *testing.T) {
+	cfg := testConfig()
+	cfg.JWTKeys = "next:next-secret-of-some-length"
+	tokens := NewTokenIssuer(cfg)
+
+	// signed before tokens had a kid
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"accountNumber": 42}).SignedString([]byte(cfg.JWTSecret))
+	require.Nil(t, err)
+
+	number, err := tokens.AccountNumber(legacy)
+	require.Nil(t, err)
+	assert.Equal(t, int64(42), number)
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"accountNumber": 42}).SignedString([]byte("next-secret-of-some-length"))
+	require.Nil(t, err)
+
+	_, err = tokens.AccountNumber(forged)
+	assert.NotNil(t, err, "tokens without a kid are checked with JWTSecret only")
+}
+
+func TestKMSKeySource(t *testing.T) {
+	cfg := testConfig()
+	cfg.JWTKeySource = "kms"
+	tokens := NewTokenIssuer(cfg)
+
+	assert.NotNil(t, tokens.Ready())
+
+	_, err := tokens.CreateAccessToken(&Account{Number: 42}, 0)
+	assert.NotNil(t, err)
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	keys, err := parseSigningKeys(" 2024-01:first, ,2024-02:sec:ond")
+	require.Nil(t, err)
+	assert.Equal(t, []*SigningKey{{ID: "2024-01", Secret: "first"}, {ID: "2024-02", Secret: "sec:ond"}}, keys)
+
+	_, err = parseSigningKeys("2024-01:first,bare-secret")
+	assert.ErrorContains(t, err, "key 2")
+	assert.NotContains(t, err.Error(), "bare-secret")
+}
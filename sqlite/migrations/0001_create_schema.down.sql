@@ -0,0 +1,55 @@
+drop table if exists aml_note;
+drop table if exists aml_flag;
+drop table if exists api_key;
+drop table if exists term_deposit;
+drop table if exists maintenance_state;
+drop table if exists reconciliation_report;
+drop table if exists payment_request;
+drop table if exists login_throttle;
+drop table if exists contact_detail;
+drop table if exists transfer_quote;
+drop table if exists loan_installment;
+drop table if exists loan;
+drop table if exists notification_delivery;
+drop table if exists notification_preferences;
+drop table if exists admin_approval;
+drop table if exists imported_transaction;
+drop table if exists alias;
+drop table if exists external_transfer;
+drop table if exists card_authorization;
+drop table if exists card;
+drop table if exists balances_history;
+drop table if exists dispute;
+drop table if exists login_network;
+drop table if exists fraud_review;
+drop table if exists transfer_batch_item;
+drop table if exists transfer_batch;
+drop table if exists standing_order;
+drop table if exists holiday;
+drop table if exists outbox;
+drop table if exists pot;
+drop table if exists password_reset;
+drop table if exists transfer_approval;
+drop table if exists account_owner;
+drop table if exists account_kyc;
+drop table if exists account_event;
+drop table if exists external_identity;
+drop table if exists audit_log;
+drop table if exists beneficiary;
+drop table if exists hold;
+drop table if exists totp_backup_code;
+drop table if exists account_totp;
+drop table if exists webhook_delivery;
+drop table if exists webhook;
+drop table if exists scheduled_transfer_run;
+drop table if exists scheduled_transfer;
+drop table if exists idempotency_key;
+drop table if exists token_denylist;
+drop table if exists refresh_token;
+drop table if exists session;
+drop table if exists business_user;
+drop table if exists transactions;
+drop table if exists journal_line;
+drop table if exists journal_entry;
+drop table if exists transfer;
+drop table if exists account;
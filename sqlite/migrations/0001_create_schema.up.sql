@@ -0,0 +1,785 @@
+-- the schema of the Postgres migrations up to 0058_create_aml_flag, in
+-- SQLite's types: serials are autoincrement keys, arrays are stored in
+-- Postgres' text format, jsonb as text and bytea as blob. The trigram and
+-- jsonb indexes have no SQLite counterpart.
+
+create table if not exists account (
+	id integer primary key autoincrement,
+	first_name varchar(50),
+	last_name varchar(50),
+	number bigint not null constraint account_number_key unique,
+	encrypted_password varchar(100),
+	balance bigint not null default 0,
+	currency varchar(3) not null default 'USD',
+	role varchar(20) not null default 'customer',
+	overdraft_limit bigint not null default 0,
+	minimum_balance bigint not null default 0,
+	overdraft_fee bigint not null default 0,
+	created_at timestamp,
+	type varchar(20) not null default 'checking',
+	accrued_interest bigint not null default 0,
+	interest_remainder bigint not null default 0,
+	interest_accrued_through date,
+	status varchar(20) not null default 'active',
+	held_balance bigint not null default 0,
+	deleted_at timestamp,
+	kyc_status varchar(10) not null default 'pending',
+	dual_approval_amount bigint not null default 0,
+	pot_balance bigint not null default 0 constraint account_pot_balance_nonnegative check (pot_balance >= 0),
+	version integer not null default 1,
+	metadata text not null default '{}'
+);
+
+create index if not exists account_balance_idx on account (balance desc, id) where deleted_at is null;
+
+create table if not exists transfer (
+	id integer primary key autoincrement,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null default 'USD',
+	to_amount bigint,
+	to_currency varchar(3) not null default 'USD',
+	rate varchar(32),
+	created_at timestamp,
+	reference varchar(35) not null default '',
+	memo varchar(140) not null default '',
+	end_to_end_id varchar(35) not null default '',
+	status varchar(16) not null default 'completed',
+	failure_reason varchar(255) not null default '',
+	reversed_at timestamp
+);
+
+create index if not exists transfer_created_at_idx on transfer (created_at);
+create index if not exists transfer_from_account_idx on transfer (from_account, created_at);
+create index if not exists transfer_to_account_idx on transfer (to_account, created_at);
+
+create table if not exists journal_entry (
+	id integer primary key autoincrement,
+	kind varchar(20) not null,
+	created_at timestamp not null
+);
+
+create table if not exists journal_line (
+	id integer primary key autoincrement,
+	entry_id integer not null references journal_entry (id),
+	ledger varchar(20) not null,
+	account_number bigint,
+	currency varchar(3) not null,
+	amount bigint not null
+);
+
+create index if not exists journal_line_entry_id_idx on journal_line (entry_id);
+create index if not exists journal_line_account_number_idx on journal_line (account_number) where ledger = 'customer';
+
+create table if not exists transactions (
+	id integer primary key autoincrement,
+	account_number bigint not null,
+	type varchar(20) not null,
+	amount bigint not null,
+	balance bigint not null,
+	counterparty bigint,
+	created_at timestamp,
+	journal_id integer not null references journal_entry (id),
+	category varchar(30),
+	description text,
+	reference varchar(35) not null default '',
+	memo varchar(140) not null default '',
+	end_to_end_id varchar(35) not null default ''
+);
+
+create index if not exists transactions_account_number_idx on transactions (account_number, id);
+create index if not exists transactions_account_number_created_at_idx on transactions (account_number, created_at);
+create index if not exists transactions_account_number_created_at_id_idx on transactions (account_number, created_at desc, id desc);
+
+create table if not exists business_user (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	username varchar(50) not null,
+	encrypted_password varchar(100) not null,
+	role varchar(10) not null,
+	created_at timestamp not null,
+	removed_at timestamp
+);
+
+-- usernames are unique among an account's current users
+create unique index if not exists business_user_username_idx on business_user (account_number, username) where removed_at is null;
+
+create table if not exists session (
+	id integer primary key autoincrement,
+	account_number bigint not null,
+	user_agent varchar(256) not null default '',
+	ip varchar(45) not null default '',
+	created_at timestamp not null,
+	last_seen_at timestamp not null,
+	revoked_at timestamp,
+	user_id integer references business_user (id)
+);
+
+create index if not exists session_account_number_idx on session (account_number);
+
+create table if not exists refresh_token (
+	id integer primary key autoincrement,
+	account_number bigint not null,
+	token_hash varchar(64) not null unique,
+	expires_at timestamp not null,
+	revoked_at timestamp,
+	created_at timestamp,
+	session_id integer references session (id)
+);
+
+create index if not exists refresh_token_session_id_idx on refresh_token (session_id);
+
+-- the access tokens of revoked sessions, until they expire
+create table if not exists token_denylist (
+	session_id integer primary key references session (id),
+	expires_at timestamp not null
+);
+
+create table if not exists idempotency_key (
+	key varchar(255) not null,
+	scope varchar(255) not null,
+	request_hash varchar(64) not null,
+	status varchar(20) not null,
+	status_code integer,
+	response_body blob,
+	created_at timestamp,
+	primary key (key, scope)
+);
+
+create table if not exists scheduled_transfer (
+	id integer primary key autoincrement,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	recurrence varchar(20) not null,
+	status varchar(20) not null,
+	start_at timestamp not null,
+	next_run_at timestamp not null,
+	occurrence integer not null default 0,
+	attempts integer not null default 0,
+	last_error text not null default '',
+	locked_until timestamp,
+	created_at timestamp
+);
+
+create index if not exists scheduled_transfer_due_idx on scheduled_transfer (status, next_run_at);
+
+create table if not exists scheduled_transfer_run (
+	id integer primary key autoincrement,
+	scheduled_transfer_id integer not null references scheduled_transfer (id),
+	transfer_id integer,
+	error text not null default '',
+	ran_at timestamp not null
+);
+
+create table if not exists webhook (
+	id integer primary key autoincrement,
+	account_number bigint,
+	url text not null,
+	secret varchar(100) not null,
+	events text not null,
+	low_balance_threshold bigint not null default 0,
+	active boolean not null default true,
+	created_at timestamp
+);
+
+create table if not exists webhook_delivery (
+	id integer primary key autoincrement,
+	webhook_id integer not null references webhook (id),
+	event_id varchar(50) not null,
+	event_type varchar(50) not null,
+	payload blob not null,
+	status varchar(20) not null,
+	attempts integer not null default 0,
+	next_attempt_at timestamp not null,
+	response_code integer,
+	last_error text not null default '',
+	locked_until timestamp,
+	delivered_at timestamp,
+	created_at timestamp
+);
+
+create index if not exists webhook_delivery_due_idx on webhook_delivery (status, next_attempt_at);
+
+create table if not exists account_totp (
+	account_number bigint primary key,
+	secret varchar(64) not null,
+	enabled_at timestamp,
+	created_at timestamp not null
+);
+
+create table if not exists totp_backup_code (
+	id integer primary key autoincrement,
+	account_number bigint not null,
+	code_hash varchar(64) not null,
+	used_at timestamp
+);
+
+create index if not exists totp_backup_code_account_number_idx on totp_backup_code (account_number);
+
+create table if not exists hold (
+	id integer primary key autoincrement,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	to_amount bigint not null,
+	to_currency varchar(3) not null,
+	rate varchar(32) not null default '',
+	status varchar(20) not null,
+	transfer_id integer references transfer (id),
+	expires_at timestamp not null,
+	created_at timestamp not null
+);
+
+create index if not exists hold_expiry_idx on hold (status, expires_at);
+
+create table if not exists beneficiary (
+	id integer primary key autoincrement,
+	owner bigint not null,
+	name varchar(50) not null,
+	account_number bigint not null,
+	nickname varchar(50) not null default '',
+	cooling_off_until timestamp not null,
+	created_at timestamp not null,
+	unique (owner, account_number)
+);
+
+create table if not exists audit_log (
+	id integer primary key autoincrement,
+	action varchar(50) not null,
+	actor bigint,
+	account_number bigint not null,
+	ip varchar(45) not null,
+	before text,
+	after text,
+	created_at timestamp not null,
+	request_id varchar(64) not null default '',
+	token_source varchar(20) not null default ''
+);
+
+create index if not exists audit_log_action_created_at_idx on audit_log (action, created_at);
+
+create trigger if not exists audit_log_immutable_update
+before update on audit_log
+begin
+	select raise(abort, 'audit_log is append-only');
+end;
+
+create trigger if not exists audit_log_immutable_delete
+before delete on audit_log
+begin
+	select raise(abort, 'audit_log is append-only');
+end;
+
+create table if not exists external_identity (
+	issuer varchar(255) not null,
+	subject varchar(255) not null,
+	account_number bigint not null,
+	created_at timestamp not null,
+	primary key (issuer, subject),
+	unique (issuer, account_number)
+);
+
+create table if not exists account_event (
+	id integer primary key autoincrement,
+	account_number bigint not null,
+	version integer not null,
+	type varchar(30) not null,
+	data text not null,
+	created_at timestamp not null,
+	unique (account_number, version)
+);
+
+create trigger if not exists account_event_immutable_update
+before update on account_event
+begin
+	select raise(abort, 'account_event is append-only');
+end;
+
+create trigger if not exists account_event_immutable_delete
+before delete on account_event
+begin
+	select raise(abort, 'account_event is append-only');
+end;
+
+create table if not exists account_kyc (
+	account_number bigint primary key,
+	date_of_birth date not null,
+	national_id varchar(30) not null,
+	address text not null,
+	rejection_reason text,
+	submitted_at timestamp not null,
+	reviewed_at timestamp
+);
+
+create table if not exists account_owner (
+	account_number bigint not null references account (number),
+	owner_number bigint not null references account (number),
+	created_at timestamp not null,
+	primary key (account_number, owner_number)
+);
+
+create index if not exists account_owner_owner_number_idx on account_owner (owner_number);
+
+create table if not exists transfer_approval (
+	id integer primary key autoincrement,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	requested_by bigint not null,
+	decided_by bigint,
+	status varchar(10) not null,
+	transfer_id integer references transfer (id),
+	error text,
+	created_at timestamp not null,
+	decided_at timestamp,
+	reference varchar(35) not null default '',
+	memo varchar(140) not null default '',
+	end_to_end_id varchar(35) not null default '',
+	requested_by_user integer references business_user (id),
+	decided_by_user integer references business_user (id)
+);
+
+create index if not exists transfer_approval_from_account_idx on transfer_approval (from_account, id);
+
+create table if not exists password_reset (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	token_hash varchar(64) not null unique,
+	expires_at timestamp not null,
+	used_at timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists password_reset_account_number_idx on password_reset (account_number);
+
+create table if not exists pot (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	name varchar(255) not null,
+	target_amount bigint not null,
+	balance bigint not null default 0 constraint pot_balance_nonnegative check (balance >= 0),
+	round_up boolean not null default false,
+	weekly_amount bigint not null default 0,
+	next_sweep_at timestamp,
+	created_at timestamp not null,
+	unique (account_number, name)
+);
+
+create index if not exists pot_next_sweep_at_idx on pot (next_sweep_at) where next_sweep_at is not null;
+
+create table if not exists outbox (
+	id integer primary key autoincrement,
+	message_id varchar(50) not null unique,
+	topic varchar(50) not null,
+	message_key varchar(50) not null,
+	payload blob not null,
+	attempts integer not null default 0,
+	next_attempt_at timestamp not null,
+	last_error text not null default '',
+	locked_until timestamp,
+	published_at timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists outbox_unpublished_idx on outbox (id) where published_at is null;
+
+create table if not exists holiday (
+	date date primary key,
+	name varchar(100) not null
+);
+
+create table if not exists standing_order (
+	id integer primary key autoincrement,
+	from_account bigint not null references account (number),
+	to_account bigint not null,
+	amount bigint not null,
+	frequency varchar(20) not null,
+	status varchar(20) not null,
+	start_date timestamp not null,
+	next_run_at timestamp not null,
+	occurrence integer not null default 0,
+	failures integer not null default 0,
+	last_error text not null default '',
+	locked_until timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists standing_order_due_idx on standing_order (status, next_run_at);
+create index if not exists standing_order_from_account_idx on standing_order (from_account);
+
+create table if not exists transfer_batch (
+	id integer primary key autoincrement,
+	from_account bigint not null references account (number),
+	mode varchar(20) not null,
+	created_at timestamp not null
+);
+
+create table if not exists transfer_batch_item (
+	batch_id integer not null references transfer_batch (id),
+	position integer not null,
+	to_account bigint not null,
+	amount bigint not null,
+	status varchar(20) not null,
+	transfer_id integer references transfer (id),
+	error_code varchar(50) not null default '',
+	error text not null default '',
+	primary key (batch_id, position)
+);
+
+create index if not exists transfer_batch_from_account_idx on transfer_batch (from_account);
+
+create table if not exists fraud_review (
+	id integer primary key autoincrement,
+	from_account bigint not null,
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	ip varchar(45) not null default '',
+	rules text not null,
+	action varchar(10) not null,
+	transfer_id integer references transfer (id),
+	status varchar(10) not null,
+	reviewed_by bigint,
+	created_at timestamp not null,
+	reviewed_at timestamp
+);
+
+create index if not exists fraud_review_status_idx on fraud_review (status, id);
+
+create table if not exists login_network (
+	account_number bigint not null references account (number),
+	network varchar(50) not null,
+	first_seen_at timestamp not null,
+	last_seen_at timestamp not null,
+	primary key (account_number, network)
+);
+
+create table if not exists dispute (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	transaction_id integer not null references transactions (id),
+	amount bigint not null,
+	reason text not null,
+	status varchar(10) not null,
+	reversed_amount bigint not null default 0,
+	reversal_journal_id integer references journal_entry (id),
+	decided_by bigint,
+	note text not null default '',
+	created_at timestamp not null,
+	decided_at timestamp
+);
+
+-- a transaction has at most one open dispute at a time
+create unique index if not exists dispute_open_idx on dispute (transaction_id) where status = 'open';
+create index if not exists dispute_transaction_id_idx on dispute (transaction_id);
+create index if not exists dispute_account_number_idx on dispute (account_number, id);
+create index if not exists dispute_status_idx on dispute (status, id);
+
+create table if not exists balances_history (
+	account_number bigint not null references account (number),
+	day date not null,
+	balance bigint not null,
+	primary key (account_number, day)
+);
+
+create index if not exists balances_history_day_idx on balances_history (day);
+
+create table if not exists card (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	masked_pan varchar(19) not null,
+	pan_hash varchar(64) not null unique,
+	expiry_month integer not null,
+	expiry_year integer not null,
+	status varchar(10) not null,
+	created_at timestamp not null
+);
+
+create index if not exists card_account_number_idx on card (account_number, id);
+
+create table if not exists card_authorization (
+	id integer primary key autoincrement,
+	card_id integer not null references card (id),
+	account_number bigint not null references account (number),
+	amount bigint not null,
+	currency varchar(3) not null,
+	merchant varchar(100) not null default '',
+	status varchar(10) not null,
+	decline_reason varchar(20) not null default '',
+	expires_at timestamp not null,
+	created_at timestamp not null
+);
+
+create index if not exists card_authorization_expiry_idx on card_authorization (status, expires_at);
+
+create table if not exists external_transfer (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	scheme varchar(10) not null,
+	beneficiary_name varchar(50) not null,
+	destination varchar(40) not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	reference varchar(140) not null default '',
+	status varchar(10) not null,
+	return_code varchar(4) not null default '',
+	return_reason text not null default '',
+	journal_id integer references journal_entry (id),
+	return_journal_id integer references journal_entry (id),
+	created_at timestamp not null,
+	submitted_at timestamp,
+	settled_at timestamp,
+	returned_at timestamp
+);
+
+create index if not exists external_transfer_account_number_idx on external_transfer (account_number, id);
+create index if not exists external_transfer_status_idx on external_transfer (status, created_at);
+
+create table if not exists alias (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	type varchar(10) not null,
+	value varchar(254) not null,
+	code_hash varchar(64) not null,
+	code_expires_at timestamp not null,
+	attempts integer not null default 0,
+	verified_at timestamp,
+	created_at timestamp not null,
+	unique (account_number, value)
+);
+
+create unique index if not exists alias_verified_value_idx on alias (value) where verified_at is not null;
+
+create table if not exists imported_transaction (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	transaction_id integer not null references transactions (id),
+	fingerprint varchar(64) not null,
+	external_id varchar(255) not null default '',
+	posted_on date not null,
+	amount bigint not null,
+	description varchar(140) not null default '',
+	created_at timestamp not null,
+	unique (account_number, fingerprint)
+);
+
+create table if not exists admin_approval (
+	id integer primary key autoincrement,
+	action varchar(20) not null,
+	account_number bigint not null references account (number),
+	amount bigint not null default 0,
+	limits text,
+	reason text not null default '',
+	proposed_by bigint not null,
+	decided_by bigint,
+	status varchar(10) not null,
+	transaction_id integer references transactions (id),
+	error text,
+	created_at timestamp not null,
+	decided_at timestamp,
+	reason_code varchar(30) not null default ''
+);
+
+create index if not exists admin_approval_status_idx on admin_approval (status, id);
+
+create table if not exists notification_preferences (
+	account_number bigint primary key references account (number),
+	channels text not null,
+	email varchar(254) not null default '',
+	phone varchar(20) not null default '',
+	push_token varchar(4096) not null default '',
+	low_balance_threshold bigint not null default 0,
+	incoming_transfer boolean not null default false,
+	new_device_login boolean not null default false,
+	updated_at timestamp not null
+);
+
+create table if not exists notification_delivery (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	channel varchar(10) not null,
+	recipient varchar(4096) not null,
+	kind varchar(30) not null,
+	subject text not null,
+	body text not null,
+	status varchar(20) not null,
+	attempts integer not null default 0,
+	next_attempt_at timestamp not null,
+	last_error text not null default '',
+	locked_until timestamp,
+	delivered_at timestamp,
+	created_at timestamp not null
+);
+
+create index if not exists notification_delivery_due_idx on notification_delivery (status, next_attempt_at);
+create index if not exists notification_delivery_account_idx on notification_delivery (account_number, id);
+
+create table if not exists loan (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	principal bigint not null constraint loan_principal_check check (principal > 0),
+	currency varchar(3) not null,
+	annual_rate varchar(10) not null,
+	term_months integer not null,
+	monthly_payment bigint not null,
+	outstanding bigint not null,
+	arrears_amount bigint not null default 0,
+	status varchar(20) not null,
+	next_payment_at timestamp,
+	created_at timestamp not null,
+	paid_off_at timestamp
+);
+
+create index if not exists loan_account_number_idx on loan (account_number);
+create index if not exists loan_due_idx on loan (next_payment_at) where status <> 'paid_off';
+
+create table if not exists loan_installment (
+	loan_id integer not null references loan (id),
+	number integer not null,
+	due_date date not null,
+	payment bigint not null,
+	principal bigint not null,
+	interest bigint not null,
+	balance bigint not null,
+	status varchar(20) not null,
+	transaction_id integer references transactions (id),
+	paid_at timestamp,
+	primary key (loan_id, number)
+);
+
+create table if not exists transfer_quote (
+	id integer primary key autoincrement,
+	from_account bigint not null references account (number),
+	to_account bigint not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	to_amount bigint not null,
+	to_currency varchar(3) not null,
+	rate varchar(32) not null default '',
+	fee bigint not null,
+	expires_at timestamp not null,
+	created_at timestamp not null,
+	used_at timestamp
+);
+
+create table if not exists contact_detail (
+	account_number bigint not null references account (number),
+	kind varchar(10) not null,
+	value varchar(254) not null,
+	code_hash varchar(64) not null,
+	code_expires_at timestamp not null,
+	attempts integer not null default 0,
+	verified_at timestamp,
+	updated_at timestamp not null,
+	primary key (account_number, kind)
+);
+
+create table if not exists login_throttle (
+	key varchar(80) primary key,
+	failures integer not null,
+	last_failure_at timestamp not null,
+	locked_until timestamp
+);
+
+create table if not exists payment_request (
+	id integer primary key autoincrement,
+	from_account bigint not null references account (number),
+	to_account bigint not null references account (number),
+	amount bigint not null,
+	currency varchar(3) not null,
+	status varchar(10) not null,
+	transfer_id integer references transfer (id),
+	reference varchar(35) not null default '',
+	memo varchar(140) not null default '',
+	end_to_end_id varchar(35) not null default '',
+	expires_at timestamp not null,
+	created_at timestamp not null,
+	decided_at timestamp
+);
+
+create index if not exists payment_request_from_account_idx on payment_request (from_account, id);
+create index if not exists payment_request_to_account_idx on payment_request (to_account, id);
+
+create table if not exists reconciliation_report (
+	id integer primary key autoincrement,
+	balanced boolean not null,
+	entries integer not null,
+	totals text not null,
+	unbalanced_entries text not null,
+	mismatched_accounts text not null,
+	checked_at timestamp not null
+);
+
+create index if not exists reconciliation_report_checked_at_idx on reconciliation_report (checked_at);
+
+create table if not exists maintenance_state (
+	id integer primary key autoincrement,
+	mode varchar(20) not null,
+	message text not null default '',
+	retry_after integer not null,
+	updated_by bigint,
+	updated_at timestamp not null
+);
+
+create table if not exists term_deposit (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	principal bigint not null constraint term_deposit_principal_check check (principal > 0),
+	currency varchar(3) not null,
+	annual_rate varchar(10) not null,
+	term_months integer not null,
+	interest bigint not null,
+	penalty bigint not null default 0,
+	status varchar(20) not null,
+	matures_at timestamp not null,
+	created_at timestamp not null,
+	closed_at timestamp
+);
+
+create index if not exists term_deposit_account_number_idx on term_deposit (account_number);
+create index if not exists term_deposit_due_idx on term_deposit (matures_at) where status = 'active';
+
+create table if not exists api_key (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	name varchar(100) not null,
+	scopes text not null,
+	prefix varchar(20) not null,
+	key_hash varchar(64) not null unique,
+	created_at timestamp not null,
+	last_used_at timestamp,
+	revoked_at timestamp
+);
+
+create index if not exists api_key_account_number_idx on api_key (account_number);
+
+create table if not exists aml_flag (
+	id integer primary key autoincrement,
+	account_number bigint not null references account (number),
+	rule varchar(20) not null,
+	transaction_id integer not null references transactions (id),
+	transaction_ids text not null,
+	amount bigint not null,
+	currency varchar(3) not null,
+	status varchar(20) not null,
+	reviewed_by bigint,
+	created_at timestamp not null,
+	updated_at timestamp not null,
+	unique (rule, transaction_id)
+);
+
+create index if not exists aml_flag_status_idx on aml_flag (status, id);
+create index if not exists aml_flag_account_number_idx on aml_flag (account_number, created_at);
+
+create table if not exists aml_note (
+	id integer primary key autoincrement,
+	flag_id integer not null references aml_flag (id),
+	author bigint not null,
+	body text not null,
+	created_at timestamp not null
+);
+
+create index if not exists aml_note_flag_id_idx on aml_note (flag_id, id);
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	standingOrderInterval   = time.Minute
+	standingOrderBatchSize  = 50
+	standingOrderLease      = 5 * time.Minute
+	standingOrderRetryDelay = 4 * time.Hour
+)
+
+// BusinessCalendar tells business days from weekends and the holidays of the
+// bank's calendar.
+type BusinessCalendar struct {
+	holidays map[string]bool
+}
+
+func NewBusinessCalendar(holidays []*Holiday) *BusinessCalendar {
+	cal := &BusinessCalendar{holidays: map[string]bool{}}
+
+	for _, h := range holidays {
+		cal.holidays[h.Date] = true
+	}
+
+	return cal
+}
+
+func (c *BusinessCalendar) IsBusinessDay(day time.Time) bool {
+	if weekday := day.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		return false
+	}
+
+	return !c.holidays[day.Format(time.DateOnly)]
+}
+
+// onOrAfter returns the first business day from day.
+func (c *BusinessCalendar) onOrAfter(day time.Time) time.Time {
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return day
+}
+
+// onOrBefore returns the last business day up to day.
+func (c *BusinessCalendar) onOrBefore(day time.Time) time.Time {
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return day
+}
+
+// paymentDate returns the n-th (0 based) payment date of the order. Weekly
+// and monthly payments due on a weekend or holiday move to the next business
+// day; monthly ones keep the day of month of StartDate like scheduled
+// transfers do.
+func (o *StandingOrder) paymentDate(cal *BusinessCalendar, n int) time.Time {
+	switch o.Frequency {
+	case StandingOrderWeekly:
+		return cal.onOrAfter(o.StartDate.AddDate(0, 0, 7*n))
+	case StandingOrderMonthly:
+		return cal.onOrAfter(nextOccurrence(o.StartDate, RecurrenceMonthly, n))
+	}
+
+	year, month, _ := o.StartDate.Date()
+
+	return cal.onOrBefore(time.Date(year, month+time.Month(n)+1, 0, 0, 0, 0, 0, time.UTC))
+}
+
+// schedule moves the order to its first payment date on or after day.
+func (o *StandingOrder) schedule(cal *BusinessCalendar, day time.Time) {
+	for o.paymentDate(cal, o.Occurrence).Before(day) {
+		o.Occurrence++
+	}
+
+	o.NextRunAt = o.paymentDate(cal, o.Occurrence)
+}
+
+// advance moves the order to its next payment date.
+func (o *StandingOrder) advance(cal *BusinessCalendar) {
+	o.Failures = 0
+	o.Occurrence++
+	o.NextRunAt = o.paymentDate(cal, o.Occurrence)
+}
+
+// StandingOrderRunner pays due standing orders. Payments refused for lack of
+// funds are retried every standingOrderRetryDelay; after maxAttempts the
+// payment is skipped and the holder notified. Any other refusal stops the
+// order.
+type StandingOrderRunner struct {
+	store       Storage
+	rates       ExchangeRateProvider
+	events      EventPublisher
+	notifier    Notifier
+	maxAttempts int
+}
+
+func NewStandingOrderRunner(cfg *Config, store Storage, rates ExchangeRateProvider, events EventPublisher) *StandingOrderRunner {
+	return &StandingOrderRunner{
+		store:       store,
+		rates:       rates,
+		events:      events,
+		notifier:    NewNotifier(cfg),
+		maxAttempts: cfg.StandingOrderMaxAttempts,
+	}
+}
+
+func (s *StandingOrderRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(standingOrderInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *StandingOrderRunner) runDue(ctx context.Context, now time.Time) {
+	holidays, err := s.store.GetHolidays(ctx)
+
+	if err != nil {
+		slog.Error("loading holidays", "error", err)
+		return
+	}
+
+	cal := NewBusinessCalendar(holidays)
+
+	for {
+		due, err := s.store.ClaimDueStandingOrders(ctx, now, now.Add(standingOrderLease), standingOrderBatchSize)
+
+		if err != nil {
+			slog.Error("claiming standing orders", "error", err)
+			return
+		}
+
+		for _, order := range due {
+			s.execute(ctx, cal, order, now)
+
+			if err := s.store.UpdateStandingOrder(ctx, order); err != nil {
+				slog.Error("recording standing order", "error", err, "standingOrderId", order.ID)
+			}
+		}
+
+		if len(due) < standingOrderBatchSize {
+			return
+		}
+	}
+}
+
+// execute pays order once and advances it for the next run.
+func (s *StandingOrderRunner) execute(ctx context.Context, cal *BusinessCalendar, order *StandingOrder, now time.Time) {
+	// holidays added since the order was scheduled move its payment date
+	if order.Failures == 0 {
+		order.NextRunAt = order.paymentDate(cal, order.Occurrence)
+
+		if order.NextRunAt.After(now) {
+			return
+		}
+	}
+
+	transfer, err := newTransfer(ctx, s.store, s.rates, order.FromAccount, order.ToAccount, order.Amount)
+
+	if err == nil {
+		err = s.store.Transfer(ctx, transfer)
+	}
+
+	if err == nil {
+		publishTransferEvents(ctx, s.events, s.store, transfer)
+
+		order.LastError = ""
+		order.advance(cal)
+
+		return
+	}
+
+	order.LastError = err.Error()
+
+	var httpErr *HTTPError
+
+	if !errors.As(err, &httpErr) {
+		slog.Error("paying standing order", "error", err, "standingOrderId", order.ID)
+		order.NextRunAt = now.Add(standingOrderRetryDelay)
+
+		return
+	}
+
+	if httpErr.Code != ErrorCodeInsufficientFunds {
+		order.Status = StandingOrderFailed
+		s.notify(ctx, order, "Standing order stopped", fmt.Sprintf("Your standing order %d paying %d to account %d was stopped: %s.", order.ID, order.Amount, order.ToAccount, httpErr.Message))
+
+		return
+	}
+
+	order.Failures++
+
+	if order.Failures < s.maxAttempts {
+		order.NextRunAt = now.Add(standingOrderRetryDelay)
+		return
+	}
+
+	order.advance(cal)
+	s.notify(ctx, order, "Standing order payment missed", fmt.Sprintf("Your standing order %d could not pay %d to account %d after %d attempts for lack of funds. The next payment is due on %s.", order.ID, order.Amount, order.ToAccount, s.maxAttempts, order.NextRunAt.Format(time.DateOnly)))
+}
+
+func (s *StandingOrderRunner) notify(ctx context.Context, order *StandingOrder, subject, body string) {
+	err := s.notifier.Notify(ctx, &Notification{AccountNumber: order.FromAccount, Subject: subject, Body: body})
+
+	if err != nil {
+		slog.Error("notifying standing order failure", "error", err, "standingOrderId", order.ID)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestStandingOrderPaymentDate(t *testing.T) {
+	cal := NewBusinessCalendar([]*Holiday{
+		{Date: "2024-03-29", Name: "Good Friday"},
+		{Date: "2024-04-01", Name: "Easter Monday"},
+		{Date: "2024-12-25", Name: "Christmas Day"},
+	})
+
+	weekly := &StandingOrder{Frequency: StandingOrderWeekly, StartDate: date(2024, time.December, 18)}
+	assert.Equal(t, date(2024, time.December, 18), weekly.paymentDate(cal, 0))
+	assert.Equal(t, date(2024, time.December, 26), weekly.paymentDate(cal, 1))
+
+	monthly := &StandingOrder{Frequency: StandingOrderMonthly, StartDate: date(2024, time.January, 31)}
+	assert.Equal(t, date(2024, time.February, 29), monthly.paymentDate(cal, 1))
+	// Sunday the 31st, then Easter Monday
+	assert.Equal(t, date(2024, time.April, 2), monthly.paymentDate(cal, 2))
+
+	last := &StandingOrder{Frequency: StandingOrderLastBusinessDay, StartDate: date(2024, time.February, 10)}
+	assert.Equal(t, date(2024, time.February, 29), last.paymentDate(cal, 0))
+	// Sunday the 31st, then Good Friday
+	assert.Equal(t, date(2024, time.March, 28), last.paymentDate(cal, 1))
+	assert.Equal(t, date(2024, time.August, 30), last.paymentDate(cal, 6))
+}
+
+func TestStandingOrderSchedule(t *testing.T) {
+	cal := NewBusinessCalendar(nil)
+
+	// March's last business day is before the start date
+	order := &StandingOrder{Frequency: StandingOrderLastBusinessDay, StartDate: date(2024, time.March, 30)}
+	order.schedule(cal, order.StartDate)
+	assert.Equal(t, 1, order.Occurrence)
+	assert.Equal(t, date(2024, time.April, 30), order.NextRunAt)
+
+	// a Saturday start pays on Monday
+	order = &StandingOrder{Frequency: StandingOrderWeekly, StartDate: date(2024, time.January, 6)}
+	order.schedule(cal, order.StartDate)
+	assert.Equal(t, 0, order.Occurrence)
+	assert.Equal(t, date(2024, time.January, 8), order.NextRunAt)
+}
+
+func TestStandingOrderRunner(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rates, err := NewStaticRateProvider(defaultExchangeRates)
+	require.Nil(t, err)
+
+	alice := &Account{Number: 1, Currency: "USD", Status: AccountActive}
+	bob := &Account{Number: 2, Currency: "USD", Status: AccountActive}
+
+	for _, acc := range []*Account{alice, bob} {
+		require.Nil(t, store.CreateAccount(ctx, acc))
+	}
+
+	start := date(2024, time.January, 1)
+	order := &StandingOrder{FromAccount: 1, ToAccount: 2, Amount: 500, Frequency: StandingOrderWeekly, Status: StandingOrderActive, StartDate: start, NextRunAt: start}
+	require.Nil(t, store.CreateStandingOrder(ctx, order))
+
+	notifier := new(recordingNotifier)
+	runner := &StandingOrderRunner{store: store, rates: rates, events: publishers{}, notifier: notifier, maxAttempts: 2}
+
+	stored := func() *StandingOrder {
+		orders, err := store.GetStandingOrders(ctx, 1)
+		require.Nil(t, err)
+		require.Len(t, orders, 1)
+
+		return orders[0]
+	}
+
+	// lack of funds is retried, then the payment is skipped
+	runner.runDue(ctx, start)
+	assert.Equal(t, 1, stored().Failures)
+	assert.Equal(t, start.Add(standingOrderRetryDelay), stored().NextRunAt)
+	assert.Empty(t, notifier.notifications)
+
+	runner.runDue(ctx, start.Add(standingOrderRetryDelay))
+	assert.Equal(t, 0, stored().Failures)
+	assert.Equal(t, date(2024, time.January, 8), stored().NextRunAt)
+	require.Len(t, notifier.notifications, 1)
+	assert.Contains(t, notifier.notifications[0].Body, "2024-01-08")
+
+	// a holiday added since moves the payment
+	require.Nil(t, store.AddHoliday(ctx, &Holiday{Date: "2024-01-08", Name: "Closed"}))
+	_, err = store.Deposit(ctx, 1, 1000, 0)
+	require.Nil(t, err)
+
+	runner.runDue(ctx, date(2024, time.January, 8))
+	assert.Equal(t, date(2024, time.January, 9), stored().NextRunAt)
+
+	runner.runDue(ctx, date(2024, time.January, 9))
+	assert.Equal(t, 2, stored().Occurrence)
+	assert.Equal(t, date(2024, time.January, 15), stored().NextRunAt)
+
+	acc, err := store.GetAccountByNumber(ctx, 2)
+	require.Nil(t, err)
+	assert.Equal(t, int64(500), acc.Balance)
+
+	// other refusals stop the order
+	_, err = store.UpdateAccountStatus(ctx, bob.ID, AccountFrozen)
+	require.Nil(t, err)
+
+	runner.runDue(ctx, date(2024, time.January, 15))
+	assert.Equal(t, StandingOrderFailed, stored().Status)
+	assert.Len(t, notifier.notifications, 2)
+}
+
+func TestAPIStandingOrders(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/standing-orders"
+
+	rec := api.do("POST", "/admin/holidays", token, Holiday{Date: "2099-12-25", Name: "Christmas Day"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", "/admin/holidays", adminToken, Holiday{Date: "2099-12-25", Name: "Christmas Day"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin/holidays", adminToken, Holiday{Date: "2099-12-25", Name: "Christmas Day"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = api.do("POST", path, token, StandingOrderRequest{ToAccount: int(bob.Number), Amount: 500, Frequency: StandingOrderWeekly, StartDate: "2000-01-01"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// the 25th is a Friday holiday, so the first payment is on Monday
+	rec = api.do("POST", path, token, StandingOrderRequest{ToAccount: int(bob.Number), Amount: 500, Frequency: StandingOrderWeekly, StartDate: "2099-12-25"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	order := new(StandingOrder)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(order))
+	assert.Equal(t, date(2099, time.December, 28), order.NextRunAt)
+	assert.Equal(t, StandingOrderActive, order.Status)
+
+	rec = api.do("GET", path, token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var orders []*StandingOrder
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&orders))
+	require.Len(t, orders, 1)
+
+	rec = api.do("DELETE", path+"/"+strconv.Itoa(order.ID), token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("DELETE", path+"/"+strconv.Itoa(order.ID), token, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = api.do("DELETE", "/admin/holidays/2099-12-25", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/admin/holidays", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
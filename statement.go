@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const statementDateLayout = "2006-01-02"
+
+// formatAmount renders minor units as a decimal in major units, e.g. 12345
+// USD as "123.45".
+func formatAmount(amount int64, currency string) string {
+	exp := currencies[currency].Decimals
+
+	if exp == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+
+	sign := ""
+
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	unit := pow10(exp).Int64()
+
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, exp, amount%unit)
+}
+
+func counterpartyString(t *Transaction) string {
+	if t.Counterparty == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(*t.Counterparty, 10)
+}
+
+// statementPeriod returns the inclusive last day of a statement, whose To is
+// the exclusive end of the period.
+func statementPeriod(s *Statement) (string, string) {
+	return s.From.Format(statementDateLayout), s.To.Add(-time.Nanosecond).Format(statementDateLayout)
+}
+
+func writeStatementCSV(w io.Writer, s *Statement) error {
+	cw := csv.NewWriter(w)
+	from, to := statementPeriod(s)
+
+	records := [][]string{
+		{"account", strconv.FormatInt(s.AccountNumber, 10)},
+		{"currency", s.Currency},
+		{"from", from},
+		{"to", to},
+		{"opening balance", formatAmount(s.OpeningBalance, s.Currency)},
+		{"closing balance", formatAmount(s.ClosingBalance, s.Currency)},
+		{"total credits", formatAmount(s.TotalCredits, s.Currency)},
+		{"total debits", formatAmount(s.TotalDebits, s.Currency)},
+		{},
+		{"id", "date", "type", "counterparty", "amount", "balance", "description"},
+	}
+
+	for _, t := range s.Transactions {
+		records = append(records, []string{
+			strconv.Itoa(t.ID),
+			t.CreatedAt.Format(time.RFC3339),
+			string(t.Type),
+			counterpartyString(t),
+			formatAmount(t.Amount, s.Currency),
+			formatAmount(t.Balance, s.Currency),
+			t.Description,
+		})
+	}
+
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+func writeStatementPDF(w io.Writer, s *Statement) error {
+	from, to := statementPeriod(s)
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(fmt.Sprintf("Statement %d %s to %s", s.AccountNumber, from, to), true)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Account statement", "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	summary := [][2]string{
+		{"Account holder", tr(s.Name)},
+		{"Account number", strconv.FormatInt(s.AccountNumber, 10)},
+		{"Period", from + " to " + to},
+		{"Opening balance", formatAmount(s.OpeningBalance, s.Currency) + " " + s.Currency},
+		{"Total credits", formatAmount(s.TotalCredits, s.Currency) + " " + s.Currency},
+		{"Total debits", formatAmount(s.TotalDebits, s.Currency) + " " + s.Currency},
+		{"Closing balance", formatAmount(s.ClosingBalance, s.Currency) + " " + s.Currency},
+	}
+
+	for _, row := range summary {
+		pdf.CellFormat(40, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, row[1], "", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(6)
+
+	widths := []float64{40, 35, 35, 40, 40}
+	header := []string{"Date", "Type", "Counterparty", "Amount", "Balance"}
+
+	pdf.SetFont("Helvetica", "B", 10)
+
+	for i, title := range header {
+		pdf.CellFormat(widths[i], 7, title, "B", 0, "L", false, 0, "")
+	}
+
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 10)
+
+	for _, t := range s.Transactions {
+		row := []string{
+			t.CreatedAt.Format("2006-01-02 15:04"),
+			string(t.Type),
+			counterpartyString(t),
+			formatAmount(t.Amount, s.Currency),
+			formatAmount(t.Balance, s.Currency),
+		}
+
+		for i, value := range row {
+			align := "L"
+
+			if i >= 3 {
+				align = "R"
+			}
+
+			pdf.CellFormat(widths[i], 6, value, "", 0, align, false, 0, "")
+		}
+
+		pdf.Ln(-1)
+
+		// entries booked by the bank, like adjustments, say why under them
+		if t.Description != "" {
+			pdf.SetFont("Helvetica", "I", 9)
+			pdf.CellFormat(widths[0], 5, "", "", 0, "L", false, 0, "")
+			pdf.MultiCell(0, 5, tr(t.Description), "", "L", false)
+			pdf.SetFont("Helvetica", "", 10)
+		}
+	}
+
+	if len(s.Transactions) == 0 {
+		pdf.CellFormat(0, 6, "No transactions in this period.", "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// localize adds the amounts of the statement and its entries written for
+// locale.
+func (s *Statement) localize(locale *Locale) {
+	s.Display = map[string]string{
+		"openingBalance": locale.FormatAmount(s.OpeningBalance, s.Currency),
+		"closingBalance": locale.FormatAmount(s.ClosingBalance, s.Currency),
+		"totalCredits":   locale.FormatAmount(s.TotalCredits, s.Currency),
+		"totalDebits":    locale.FormatAmount(s.TotalDebits, s.Currency),
+	}
+
+	for _, t := range s.Transactions {
+		t.Display = map[string]string{
+			"amount":  locale.FormatAmount(t.Amount, s.Currency),
+			"balance": locale.FormatAmount(t.Balance, s.Currency),
+		}
+	}
+}
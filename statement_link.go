@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// statementLinkAudience is the aud claim of statement link tokens.
+	// Access tokens have none, and statement link tokens no accountNumber
+	// claim, so neither is accepted as the other.
+	statementLinkAudience = "statement"
+
+	maxStatementLinkTTL = 24 * time.Hour
+)
+
+// StatementLinkRequest asks for a link to the statement of the account for
+// the period from and to (YYYY-MM-DD, both included) in format, defaulting
+// like GET /account/{id}/statement.
+type StatementLinkRequest struct {
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// StatementLink downloads a single statement without an access token until
+// it expires, so it can be shared by email.
+type StatementLink struct {
+	// URL is relative to the API root, e.g. /v1.
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// statementScope is the statement a link token downloads: the account's
+// for the period [From, To) in Format.
+type statementScope struct {
+	AccountNumber int64
+	From          time.Time
+	To            time.Time
+	Format        string
+}
+
+// CreateStatementLink signs a link to the statement of scope, which works
+// for the statement link lifetime from now.
+func (t *TokenIssuer) CreateStatementLink(scope *statementScope, now time.Time) (*StatementLink, error) {
+	ring := t.keys.Load()
+
+	if ring == nil {
+		return nil, errors.New("no signing keys loaded")
+	}
+
+	expiresAt := now.Add(t.statementLinkTTL).Truncate(time.Second)
+	claims := jwt.MapClaims{
+		"aud":              statementLinkAudience,
+		"exp":              expiresAt.Unix(),
+		"statementAccount": scope.AccountNumber,
+		"from":             scope.From.Format(statementDateLayout),
+		"to":               scope.To.AddDate(0, 0, -1).Format(statementDateLayout),
+		"format":           scope.Format,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = ring.signing.ID
+
+	signed, err := token.SignedString([]byte(ring.signing.Secret))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &StatementLink{URL: "/statement/download?token=" + url.QueryEscape(signed), ExpiresAt: expiresAt.UTC()}, nil
+}
+
+// VerifyStatementLink validates the token of a statement link and returns
+// the statement it downloads.
+func (t *TokenIssuer) VerifyStatementLink(tokenString string) (*statementScope, error) {
+	token, err := jwt.Parse(tokenString, t.verifyingKey)
+
+	if err != nil || !token.Valid {
+		return nil, unauthorizedError("invalid or expired statement link")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	// the audience is required, access tokens would pass without it
+	if !claims.VerifyAudience(statementLinkAudience, true) {
+		return nil, unauthorizedError("invalid or expired statement link")
+	}
+
+	number, _ := claims["statementAccount"].(float64)
+	from, _ := claims["from"].(string)
+	to, _ := claims["to"].(string)
+	format, _ := claims["format"].(string)
+
+	scope := &statementScope{AccountNumber: int64(number), Format: format}
+
+	if scope.From, err = time.Parse(statementDateLayout, from); err != nil {
+		return nil, unauthorizedError("invalid or expired statement link")
+	}
+
+	if scope.To, err = time.Parse(statementDateLayout, to); err != nil {
+		return nil, unauthorizedError("invalid or expired statement link")
+	}
+
+	scope.To = scope.To.AddDate(0, 0, 1)
+
+	return scope, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementLinkToken(t *testing.T) {
+	tokens := NewTokenIssuer(testConfig())
+	now := time.Now().UTC()
+	scope := &statementScope{
+		AccountNumber: 42,
+		From:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:            time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Format:        "pdf",
+	}
+
+	link, err := tokens.CreateStatementLink(scope, now)
+	require.Nil(t, err)
+	assert.Equal(t, now.Add(15*time.Minute).Truncate(time.Second), link.ExpiresAt)
+
+	token := strings.TrimPrefix(link.URL, "/statement/download?token=")
+
+	verified, err := tokens.VerifyStatementLink(token)
+	require.Nil(t, err)
+	assert.Equal(t, scope, verified)
+
+	_, err = tokens.AccountNumber(token)
+	assert.NotNil(t, err, "a link is not an access token")
+
+	accessToken, err := tokens.CreateAccessToken(&Account{Number: 42, Role: RoleCustomer}, 0)
+	require.Nil(t, err)
+
+	_, err = tokens.VerifyStatementLink(accessToken)
+	assert.NotNil(t, err, "an access token is not a link")
+
+	expired, err := tokens.CreateStatementLink(scope, now.Add(-time.Hour))
+	require.Nil(t, err)
+
+	_, err = tokens.VerifyStatementLink(strings.TrimPrefix(expired.URL, "/statement/download?token="))
+	assert.NotNil(t, err)
+}
+
+func TestAPIStatementLink(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/statement/link"
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1234})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path, api.login(bob, "bob-pw"), StatementLinkRequest{})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", path, token, StatementLinkRequest{From: "2024-02-01", To: "2024-01-01"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = api.do("POST", path, token, StatementLinkRequest{Format: "csv"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	link := new(StatementLink)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(link))
+
+	rec = api.do("GET", link.URL, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Contains(t, rec.Body.String(), "12.34")
+
+	rec = api.do("GET", link.URL+"x", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("GET", "/statement/download?token="+token, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "access tokens don't download statements")
+
+	token = strings.TrimPrefix(link.URL, "/statement/download?token=")
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID), token, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "links don't authenticate other requests")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "123.45", formatAmount(12345, "USD"))
+	assert.Equal(t, "0.05", formatAmount(5, "EUR"))
+	assert.Equal(t, "-1.50", formatAmount(-150, "GBP"))
+	assert.Equal(t, "1500", formatAmount(1500, "JPY"))
+}
+
+func testStatement() *Statement {
+	account := &Account{Number: 42, FirstName: "Ada", LastName: "Lovelace", Currency: "USD"}
+	counterparty := int64(7)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	return NewStatement(account, from, from.AddDate(0, 1, 0), 1000, []*Transaction{
+		{ID: 1, Type: TransactionDeposit, Amount: 500, Balance: 1500, CreatedAt: from.Add(time.Hour)},
+		{ID: 2, Type: TransactionTransferOut, Amount: -200, Balance: 1300, Counterparty: &counterparty, CreatedAt: from.Add(2 * time.Hour)},
+	})
+}
+
+func TestNewStatement(t *testing.T) {
+	statement := testStatement()
+
+	assert.Equal(t, "Ada Lovelace", statement.Name)
+	assert.Equal(t, int64(1000), statement.OpeningBalance)
+	assert.Equal(t, int64(1300), statement.ClosingBalance)
+	assert.Equal(t, int64(500), statement.TotalCredits)
+	assert.Equal(t, int64(200), statement.TotalDebits)
+
+	empty := NewStatement(&Account{}, statement.From, statement.To, 1000, nil)
+
+	assert.Equal(t, int64(1000), empty.ClosingBalance)
+}
+
+func TestWriteStatementCSV(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	assert.Nil(t, writeStatementCSV(buf, testStatement()))
+
+	out := buf.String()
+
+	assert.Contains(t, out, "to,2024-03-31\n")
+	assert.Contains(t, out, "closing balance,13.00\n")
+	assert.True(t, strings.HasSuffix(out, "2,2024-03-01T02:00:00Z,transfer_out,7,-2.00,13.00,\n"))
+
+	statement := testStatement()
+	statement.Transactions = append(statement.Transactions, &Transaction{ID: 3, Type: TransactionAdjustment, Amount: 100, Balance: 1400, Description: "fee_refund: charged twice", CreatedAt: statement.From.Add(3 * time.Hour)})
+
+	buf.Reset()
+	assert.Nil(t, writeStatementCSV(buf, statement))
+	assert.True(t, strings.HasSuffix(buf.String(), "3,2024-03-01T03:00:00Z,adjustment,,1.00,14.00,fee_refund: charged twice\n"))
+	assert.Nil(t, writeStatementPDF(new(bytes.Buffer), statement))
+}
+
+func TestWriteStatementPDF(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	assert.Nil(t, writeStatementPDF(buf, testStatement()))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+}
@@ -0,0 +1,41 @@
+package main
+
+import "sort"
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+
+	defaultLargestAccounts = 10
+)
+
+func newAccountStats() *AccountStats {
+	return &AccountStats{
+		ByStatus: map[AccountStatus]int{AccountActive: 0, AccountFrozen: 0, AccountClosed: 0},
+		Balances: []*CurrencyBalance{},
+	}
+}
+
+// add counts count accounts of the status and currency holding balance in
+// total, keeping the per-currency totals in balances.
+func (a *AccountStats) add(balances map[string]*CurrencyBalance, status AccountStatus, currency string, count int, balance int64) {
+	a.TotalAccounts += count
+	a.ByStatus[status] += count
+
+	total, ok := balances[currency]
+
+	if !ok {
+		total = &CurrencyBalance{Currency: currency}
+		balances[currency] = total
+		a.Balances = append(a.Balances, total)
+	}
+
+	total.Accounts += count
+	total.Balance += balance
+}
+
+func (a *AccountStats) sortBalances() {
+	sort.Slice(a.Balances, func(i, j int) bool {
+		return a.Balances[i].Currency < a.Balances[j].Currency
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStatsPeriodFromQueryParams(t *testing.T) {
+	now := time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	from, to, err := getStatsPeriodFromQueryParams(httptest.NewRequest("GET", "/admin/stats/transfers", nil), now)
+	require.Nil(t, err)
+	assert.Equal(t, today.AddDate(0, 0, -29), from)
+	assert.Equal(t, today.AddDate(0, 0, 1), to)
+
+	from, to, err = getStatsPeriodFromQueryParams(httptest.NewRequest("GET", "/admin/stats/transfers?from=2024-01-01&to=2024-01-01", nil), now)
+	require.Nil(t, err)
+	assert.Equal(t, 24*time.Hour, to.Sub(from))
+
+	for _, query := range []string{"from=2024-01", "from=2024-02-01&to=2024-01-01", "from=2022-01-01&to=2024-01-01"} {
+		_, _, err := getStatsPeriodFromQueryParams(httptest.NewRequest("GET", "/admin/stats/transfers?"+query, nil), now)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestAPIAdminStats(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	admin := api.createAccountWithRole("Admin", "admin-pw", RoleAdmin)
+	token := api.login(alice, "alice-pw")
+	adminToken := api.login(admin, "admin-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", adminToken, AmountRequest{Amount: 5000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	for _, amount := range []int64{1000, 500} {
+		rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: amount})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: bob.Number, Password: "wrong"})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("GET", "/admin/stats", token, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("GET", "/admin/stats", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	stats := new(AccountStats)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(stats))
+	assert.Equal(t, 3, stats.TotalAccounts)
+	assert.Equal(t, 3, stats.ByStatus[AccountActive])
+	assert.Equal(t, 0, stats.ByStatus[AccountFrozen])
+	require.Len(t, stats.Balances, 1)
+	assert.Equal(t, int64(5000), stats.Balances[0].Balance)
+
+	today := time.Now().UTC().Format(time.DateOnly)
+
+	rec = api.do("GET", "/admin/stats/transfers", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var volume []*DailyTransferVolume
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&volume))
+	require.Len(t, volume, 1)
+	assert.Equal(t, &DailyTransferVolume{Date: today, Currency: stats.Balances[0].Currency, Count: 2, Amount: 1500}, volume[0])
+
+	rec = api.do("GET", "/admin/stats/transfers?from=2020-01-01&to=2020-01-31", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	rec = api.do("GET", "/admin/stats/failed-logins", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `[{"date":"`+today+`","count":1}]`, rec.Body.String())
+
+	rec = api.do("GET", "/admin/stats/largest-accounts?limit=2", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var largest []*Account
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&largest))
+	require.Len(t, largest, 2)
+	assert.Equal(t, alice.Number, largest[0].Number)
+	assert.Equal(t, bob.Number, largest[1].Number)
+
+	rec = api.do("GET", "/admin/stats/largest-accounts?currency=XXX", adminToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
@@ -1,171 +1,778 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"os"
-
-	_ "github.com/lib/pq"
+	"io/fs"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
-type Storage interface {
-	CreateAccount(*Account) error
-	DeleteAccount(id int) error
-	UpdateAccount(*Account) error
-	GetAccountById(id int) (*Account, error)
-	GetAccountByNumber(number int) (*Account, error)
-	GetAccounts() ([]*Account, error)
+type AccountRepository interface {
+	CreateAccount(context.Context, *Account) error
+	// SeedAccount creates a fixture account with its history, deposits and
+	// withdrawals booked at their own times without limits or fees. It
+	// fails with errAccountNumberTaken, creating nothing, when the number is
+	// in use.
+	SeedAccount(ctx context.Context, acc *Account, history []*Transaction) error
+	// CreateAccountWithTransfer creates an account funded by a transfer to
+	// it, whose ToAccount it sets. It creates nothing when the transfer
+	// fails, so the account never exists without its initial balance.
+	CreateAccountWithTransfer(ctx context.Context, acc *Account, transfer *Transfer) error
+	// UpdateAccountStatus freezes, unfreezes or closes an account after
+	// checking the change with Account.CheckStatusChange.
+	UpdateAccountStatus(ctx context.Context, id int, status AccountStatus) (*Account, error)
+	// DeleteAccount soft-deletes an account after checking it with
+	// Account.CheckDelete. Deleted accounts are left out of every read and
+	// cannot send or receive money until RestoreAccount.
+	DeleteAccount(ctx context.Context, id int, now time.Time) (*Account, error)
+	// CloseAccount sweeps the balance of the account to the sweepTo account,
+	// cancels its standing orders and scheduled transfers and marks it
+	// closed, after checking it with Account.CheckClose. sweepTo is 0 when
+	// none was given.
+	CloseAccount(ctx context.Context, number, sweepTo int64) (*AccountClosure, error)
+	RestoreAccount(ctx context.Context, id int) (*Account, error)
+	UpdateAccount(context.Context, *Account) error
+	// UpdateAccountMetadata merges patch into the account's metadata, see
+	// mergeMetadata, unless version is set and the account has moved on.
+	UpdateAccountMetadata(ctx context.Context, id int, patch map[string]*string, version int) (*Account, error)
+	GetAccountById(ctx context.Context, id int) (*Account, error)
+	GetAccountByNumber(ctx context.Context, number int) (*Account, error)
+	GetAccounts(context.Context, AccountFilter) ([]*Account, error)
+	// SearchAccounts matches q against names, tolerating typos, and against
+	// parts of account numbers, best match first. See accountSearchScore.
+	SearchAccounts(ctx context.Context, q string, limit, offset int) ([]*Account, error)
+	UpdateAccountLimits(ctx context.Context, id int, limits AccountLimits) error
+	// ResetPassword replaces the password and revokes the account's refresh
+	// tokens, so its sessions end once their access tokens expire.
+	ResetPassword(ctx context.Context, number int64, encryptedPassword string) error
 }
 
-type PostgresStore struct {
-	db *sql.DB
+type TransactionRepository interface {
+	// Deposit and Withdraw refuse with a conflict when version is set and
+	// the account is at another one.
+	Deposit(ctx context.Context, number, amount int64, version int) (*Transaction, error)
+	Withdraw(ctx context.Context, number, amount int64, version int) (*Transaction, error)
+	// AdjustBalance credits the account by amount, or debits it when amount
+	// is negative, after checking it with checkAdjustment. The description
+	// is shown with the entry on statements.
+	AdjustBalance(ctx context.Context, number, amount int64, description string) (*Transaction, error)
+	GetTransactions(ctx context.Context, number int64, limit, offset int) ([]*Transaction, error)
+	// GetTransactionsBefore returns up to limit entries after before in the
+	// feed, newest first by created_at then id. A nil cursor starts at the
+	// newest entry.
+	GetTransactionsBefore(ctx context.Context, number int64, before *TransactionCursor, limit int) ([]*Transaction, error)
+	SearchTransactions(ctx context.Context, number int64, search *TransactionSearch) ([]*Transaction, error)
+	// GetTransactionsBetween returns the entries created in [from, to), oldest first.
+	GetTransactionsBetween(ctx context.Context, number int64, from, to time.Time) ([]*Transaction, error)
+	// StreamTransactions calls fn with each entry created in [from, to),
+	// oldest first by created_at then id, without holding more than a batch of them in memory. An
+	// error of fn stops the stream and is returned.
+	StreamTransactions(ctx context.Context, number int64, from, to time.Time, fn func(*Transaction) error) error
+	// GetBalanceAt returns the balance after the last entry created before at.
+	GetBalanceAt(ctx context.Context, number int64, at time.Time) (int64, error)
+	// CategorizeTransaction sets the category of the account's entry id, or
+	// clears it when category is empty.
+	CategorizeTransaction(ctx context.Context, number int64, id int, category string) (*Transaction, error)
+	// GetCategoryTotals sums the entries created in [from, to) per category
+	// and month, ordered by category and month.
+	GetCategoryTotals(ctx context.Context, number int64, from, to time.Time) ([]*CategoryTotal, error)
 }
 
-func NewPostgresStore() (*PostgresStore, error) {
-	username := os.Getenv("POSTGRES_USERNAME")
-	password := os.Getenv("POSTGRES_PASSWORD")
+// EventRepository reads the account events that the account and
+// transaction methods append alongside their changes.
+type EventRepository interface {
+	// GetAccountEvents returns the account's events in version order.
+	GetAccountEvents(ctx context.Context, number int64, filter AccountEventFilter) ([]*AccountEvent, error)
+}
 
-	connStr := "user=postgres dbname=" + username + " password=" + password + " sslmode=disable"
+type InterestRepository interface {
+	GetAccountsDueForInterest(ctx context.Context, today time.Time) ([]*Account, error)
+	AccrueInterest(ctx context.Context, number int64, day time.Time, micros int64, post bool) error
+}
 
-	db, err := sql.Open("postgres", connStr)
+// BalanceHistoryRepository materializes the accounts' daily closing
+// balances, so charts and statements don't have to search the ledger.
+type BalanceHistoryRepository interface {
+	// NextClosingBalanceDay returns the first day whose closing balances
+	// have not been materialized: the day after the last one, or the day the
+	// oldest account was opened. It is zero without accounts.
+	NextClosingBalanceDay(context.Context) (time.Time, error)
+	// MaterializeClosingBalances stores the balance at the end of day of
+	// every account opened by then, skipping those already stored, and
+	// returns how many it stored.
+	MaterializeClosingBalances(ctx context.Context, day time.Time) (int, error)
+	// GetClosingBalances returns the account's closing balances of the days
+	// in [from, to) that have been materialized, oldest first.
+	GetClosingBalances(ctx context.Context, number int64, from, to time.Time) ([]*ClosingBalance, error)
+}
 
-	if err != nil {
-		return nil, err
-	}
+type LedgerRepository interface {
+	// CheckLedgerIntegrity verifies that the journal balances and matches
+	// the account balances.
+	CheckLedgerIntegrity(context.Context) (*LedgerIntegrityReport, error)
+	// OnJournalCommitted has the store call fn with every journal entry it
+	// commits, once the transaction committing it has ended. Every balance
+	// change is booked by one, so fn sees them all. It replaces the fn set
+	// before, if any, and is called before the store is used.
+	OnJournalCommitted(fn func(context.Context, *JournalEntry))
+}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
-	}
+type ReconciliationRepository interface {
+	CreateReconciliationReport(context.Context, *ReconciliationReport) error
+	// GetReconciliationReports returns the stored reports, newest first.
+	GetReconciliationReports(ctx context.Context, limit, offset int) ([]*ReconciliationReport, error)
+	GetReconciliationReport(ctx context.Context, id int) (*ReconciliationReport, error)
+}
 
-	return &PostgresStore{
-		db: db,
-	}, nil
+type TransferRepository interface {
+	Transfer(context.Context, *Transfer) error
+	GetTransfer(ctx context.Context, id int) (*Transfer, error)
+	// GetTransfers lists the transfers matching filter, newest first.
+	GetTransfers(ctx context.Context, filter *TransferFilter) ([]*Transfer, error)
+	// ReverseTransfer moves the money of the completed id transfer back and
+	// marks it reversed at now.
+	ReverseTransfer(ctx context.Context, id int, now time.Time) (*Transfer, error)
 }
 
-func (s *PostgresStore) Init() error {
-	return s.createAccountTable()
+type DatasetRepository interface {
+	// ExportDataset reads every account, account event, journal entry,
+	// transaction and transfer as of one point in time, in id order.
+	ExportDataset(ctx context.Context, now time.Time) (*Dataset, error)
+	// ImportDataset writes an archived dataset with its ids and account
+	// numbers into a fresh store: one without journal entries or transfers,
+	// whose accounts, if any, share no id or number with the archive. It
+	// fails with a conflict otherwise.
+	ImportDataset(context.Context, *Dataset) error
 }
 
-func (s *PostgresStore) createAccountTable() error {
+type TransferBatchRepository interface {
+	// TransferBatch executes the transfers of batch and records it. An atomic
+	// batch executes all of them or none, returning the refusal of the first
+	// that fails; a partial one records the refusals on their items.
+	TransferBatch(context.Context, *TransferBatch) error
+	GetTransferBatch(ctx context.Context, id int) (*TransferBatch, error)
+}
 
-	query := `create table if not exists account (
-		id serial primary key,
-		first_name varchar(50),
-		last_name varchar(50),
-		number serial,
-		encrypted_password varchar(100),
-		balance serial,
-		created_at timestamp
-	)`
+type HoldRepository interface {
+	// AuthorizeTransfer reserves hold.Amount on the source account if its
+	// available balance covers it.
+	AuthorizeTransfer(context.Context, *Hold) error
+	// CaptureHold executes the transfer of an authorized hold of
+	// fromAccount and releases the reserved amount.
+	CaptureHold(ctx context.Context, id int, fromAccount int64, now time.Time) (*Transfer, error)
+	// ExpireHolds releases up to limit authorized holds that expired at now
+	// and returns them.
+	ExpireHolds(ctx context.Context, now time.Time, limit int) ([]*Hold, error)
+}
 
-	_, err := s.db.Exec(query)
+type PaymentRequestRepository interface {
+	CreatePaymentRequest(context.Context, *PaymentRequest) error
+	// GetPaymentRequest returns request id as it stands at now.
+	GetPaymentRequest(ctx context.Context, id int, now time.Time) (*PaymentRequest, error)
+	// GetPaymentRequests lists, newest first, the requests the account was
+	// asked to pay if incoming, or the ones it made otherwise, only those
+	// with status if it isn't empty.
+	GetPaymentRequests(ctx context.Context, number int64, incoming bool, status PaymentRequestStatus, now time.Time, limit, offset int) ([]*PaymentRequest, error)
+	// DecidePaymentRequest declines a request fromAccount was asked to pay,
+	// if it is still pending at now.
+	DecidePaymentRequest(ctx context.Context, id int, fromAccount int64, status PaymentRequestStatus, now time.Time) (*PaymentRequest, error)
+	// AcceptPaymentRequest accepts a request fromAccount was asked to pay,
+	// if it is still pending at now, and makes transfer to pay it. Both
+	// happen or neither does.
+	AcceptPaymentRequest(ctx context.Context, id int, fromAccount int64, transfer *Transfer, now time.Time) (*PaymentRequest, error)
+}
 
-	return err
+type TransferQuoteRepository interface {
+	CreateTransferQuote(context.Context, *TransferQuote) error
+	// UseTransferQuote marks quote id of fromAccount as used at now, unless
+	// it was used already or is expired, and returns it.
+	UseTransferQuote(ctx context.Context, id int, fromAccount int64, now time.Time) (*TransferQuote, error)
 }
 
-func (s *PostgresStore) CreateAccount(acc *Account) error {
-	query := `
-	insert into account
-	(first_name, last_name, number, encrypted_password, balance, created_at)
-	values
-	($1, $2, $3, $4, $5, $6)`
+type CardRepository interface {
+	CreateCard(context.Context, *Card) error
+	GetCards(ctx context.Context, number int64) ([]*Card, error)
+	// UpdateCardStatus freezes or unfreezes card id of the number account.
+	UpdateCardStatus(ctx context.Context, id int, number int64, status CardStatus) (*Card, error)
+	// AuthorizeCard decides auth for the card whose number hashes to
+	// panHash and stores it; approved, its amount is held on the card's
+	// account.
+	AuthorizeCard(ctx context.Context, panHash string, auth *CardAuthorization) error
+	// ExpireCardAuthorizations releases up to limit approved authorizations
+	// that expired at now and returns them.
+	ExpireCardAuthorizations(ctx context.Context, now time.Time, limit int) ([]*CardAuthorization, error)
+}
 
-	_, err := s.db.Query(query, acc.FirstName, acc.LastName, acc.Number, acc.EncryptedPassword, acc.Balance, acc.CreatedAt)
+type ExternalTransferRepository interface {
+	// CreateExternalTransfer holds the amount of a pending transfer on its
+	// account if the available balance covers it.
+	CreateExternalTransfer(context.Context, *ExternalTransfer) error
+	// GetExternalTransfers lists the number account's transfers newest
+	// first.
+	GetExternalTransfers(ctx context.Context, number int64, limit, offset int) ([]*ExternalTransfer, error)
+	// SubmitExternalTransfers submits up to limit pending transfers, oldest
+	// first, and returns them.
+	SubmitExternalTransfers(ctx context.Context, now time.Time, limit int) ([]*ExternalTransfer, error)
+	// SettleExternalTransfers books up to limit transfers submitted at or
+	// before submittedBefore, releasing their holds, and returns them.
+	SettleExternalTransfers(ctx context.Context, submittedBefore, now time.Time, limit int) ([]*ExternalTransfer, error)
+	// ReturnExternalTransfer releases the hold of an unsettled transfer, or
+	// gives the amount of a settled one back.
+	ReturnExternalTransfer(ctx context.Context, id int, code, reason string, now time.Time) (*ExternalTransfer, error)
+}
 
-	if err != nil {
-		return err
-	}
+type ImportRepository interface {
+	// ImportTransactions marks the rows that were imported before or are
+	// already in the number account's ledger as duplicates, and books the
+	// others in order unless dryRun is set. A debit the account can't cover
+	// fails the whole import, dry run or not.
+	ImportTransactions(ctx context.Context, number int64, rows []*ImportRow, dryRun bool, now time.Time) (*ImportResult, error)
+}
 
-	return nil
+type BeneficiaryRepository interface {
+	CreateBeneficiary(context.Context, *Beneficiary) error
+	GetBeneficiaries(ctx context.Context, owner int64) ([]*Beneficiary, error)
+	GetBeneficiary(ctx context.Context, id int, owner int64) (*Beneficiary, error)
+	DeleteBeneficiary(ctx context.Context, id int, owner int64) error
 }
 
-func (s *PostgresStore) DeleteAccount(id int) error {
-	query := "delete from account where id = $1"
+type AliasRepository interface {
+	// CreateAlias adds an unverified alias to its account. Adding an alias
+	// the account has not verified yet again replaces its code; a value
+	// verified on any account is a conflict.
+	CreateAlias(context.Context, *Alias) error
+	GetAliases(ctx context.Context, number int64) ([]*Alias, error)
+	// VerifyAlias verifies the id alias of the number account with the hash
+	// of the code sent to it. Wrong codes are counted even though they
+	// return an error.
+	VerifyAlias(ctx context.Context, id int, number int64, codeHash string, now time.Time) (*Alias, error)
+	DeleteAlias(ctx context.Context, id int, number int64) error
+	// ResolveAlias returns the verified alias with the normalized value.
+	ResolveAlias(ctx context.Context, value string) (*Alias, error)
+}
 
-	_, err := s.db.Query(query, id)
+type ContactRepository interface {
+	// SetContactDetail sets the account's detail of its kind, replacing
+	// the one it had, verified or not.
+	SetContactDetail(context.Context, *ContactDetail) error
+	GetContactDetails(ctx context.Context, number int64) ([]*ContactDetail, error)
+	// VerifyContactDetail verifies the kind detail of the number account
+	// with the hash of the code sent to it. Wrong codes count as attempts.
+	VerifyContactDetail(ctx context.Context, number int64, kind ContactKind, codeHash string, now time.Time) (*ContactDetail, error)
+	DeleteContactDetail(ctx context.Context, number int64, kind ContactKind) error
+}
 
-	if err != nil {
-		return err
-	}
+type MaintenanceRepository interface {
+	// GetMaintenanceState returns the last state set, off if none was.
+	GetMaintenanceState(context.Context) (*MaintenanceState, error)
+	SetMaintenanceState(context.Context, *MaintenanceState) error
+}
 
-	return nil
+type AuditRepository interface {
+	RecordAudit(context.Context, *AuditEntry) error
+	// GetAuditLog lists entries newest first.
+	GetAuditLog(ctx context.Context, limit, offset int) ([]*AuditEntry, error)
+}
 
+type IdentityRepository interface {
+	// LinkIdentity fails with a conflict when the subject is already linked,
+	// or the account already has an identity of the same issuer.
+	LinkIdentity(context.Context, *ExternalIdentity) error
+	GetIdentity(ctx context.Context, issuer, subject string) (*ExternalIdentity, error)
 }
 
-func (s *PostgresStore) UpdateAccount(acc *Account) error {
-	query := "update account set first_name = $1, last_name = $2 where id = $3"
+type KYCRepository interface {
+	// SubmitKYC stores the account's details, replacing an earlier
+	// submission, and sets its status back to pending. Verified accounts
+	// can't resubmit.
+	SubmitKYC(context.Context, *KYC) error
+	GetKYC(ctx context.Context, number int64) (*KYC, error)
+	// GetPendingKYC lists the submissions awaiting review, oldest first.
+	GetPendingKYC(ctx context.Context, limit, offset int) ([]*KYC, error)
+	// ReviewKYC verifies or rejects a pending submission.
+	ReviewKYC(ctx context.Context, number int64, status KYCStatus, reason string, reviewedAt time.Time) (*KYC, error)
+}
 
-	_, err := s.db.Query(query, acc.FirstName, acc.LastName, acc.ID)
+type OwnerRepository interface {
+	// AddAccountOwner fails with a conflict when the owner already co-owns
+	// the account.
+	AddAccountOwner(context.Context, *AccountOwner) error
+	RemoveAccountOwner(ctx context.Context, number, owner int64) error
+	// GetAccountOwners lists the co-owners of the account.
+	GetAccountOwners(ctx context.Context, number int64) ([]*AccountOwner, error)
+	// GetOwnedAccounts lists the accounts owner co-owns.
+	GetOwnedAccounts(ctx context.Context, owner int64) ([]*AccountOwner, error)
+}
 
-	if err != nil {
-		return err
-	}
+type TransferApprovalRepository interface {
+	CreateTransferApproval(context.Context, *TransferApproval) error
+	// GetTransferApprovals lists the account's approvals, newest first.
+	GetTransferApprovals(ctx context.Context, number int64, limit, offset int) ([]*TransferApproval, error)
+	// DecideTransferApproval approves or rejects a pending approval of the
+	// account. Only the owner, or business user, who requested it can't
+	// approve it.
+	DecideTransferApproval(ctx context.Context, id int, number int64, status TransferApprovalStatus, decidedBy int64, decidedByUser int, decidedAt time.Time) (*TransferApproval, error)
+	// UpdateTransferApproval records how executing an approved transfer went.
+	UpdateTransferApproval(context.Context, *TransferApproval) error
+}
 
-	return nil
+type AdminApprovalRepository interface {
+	CreateAdminApproval(context.Context, *AdminApproval) error
+	// GetAdminApprovals lists the approvals with status, all of them if
+	// empty, oldest first.
+	GetAdminApprovals(ctx context.Context, status AdminApprovalStatus, limit, offset int) ([]*AdminApproval, error)
+	// DecideAdminApproval approves or rejects a pending approval after
+	// checking it with AdminApproval.CheckDecision.
+	DecideAdminApproval(ctx context.Context, id int, status AdminApprovalStatus, decidedBy int64, decidedAt time.Time) (*AdminApproval, error)
+	// UpdateAdminApproval records how carrying out an approved action went.
+	UpdateAdminApproval(context.Context, *AdminApproval) error
 }
 
-func (s *PostgresStore) GetAccounts() ([]*Account, error) {
+type NotificationRepository interface {
+	// GetNotificationPreferences returns the preferences of an account,
+	// which notify nothing until the holder saves some.
+	GetNotificationPreferences(ctx context.Context, number int64) (*NotificationPreferences, error)
+	SaveNotificationPreferences(context.Context, *NotificationPreferences) error
+	EnqueueNotificationDeliveries(context.Context, []*NotificationDelivery) error
+	// ClaimDueNotificationDeliveries leases up to limit pending deliveries
+	// due by now until leaseUntil, so concurrent dispatchers skip them.
+	ClaimDueNotificationDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*NotificationDelivery, error)
+	UpdateNotificationDelivery(context.Context, *NotificationDelivery) error
+	// GetNotificationDeliveries lists the deliveries of an account, newest
+	// first.
+	GetNotificationDeliveries(ctx context.Context, number int64, limit, offset int) ([]*NotificationDelivery, error)
+}
 
-	rows, err := s.db.Query("select * from account")
+type LoanRepository interface {
+	// CreateLoan originates a loan with its amortization schedule and pays
+	// the principal into its account.
+	CreateLoan(context.Context, *Loan, []*LoanInstallment) (*Transaction, error)
+	GetLoan(ctx context.Context, id int) (*Loan, error)
+	GetLoans(ctx context.Context, number int64) ([]*Loan, error)
+	GetLoanSchedule(ctx context.Context, id int) ([]*LoanInstallment, error)
+	// GetLoansDue lists up to limit loans with a collection due by now,
+	// longest due first.
+	GetLoansDue(ctx context.Context, now time.Time, limit int) ([]*Loan, error)
+	// CollectLoanRepayments debits the installments of a loan due by now
+	// from its account, oldest first. Once one can't be paid, it and the
+	// ones after it are overdue and the loan in arrears until a later
+	// collection pays them.
+	CollectLoanRepayments(ctx context.Context, id int, now time.Time) (*Loan, []*Transaction, error)
+}
 
-	if err != nil {
-		return nil, err
-	}
+type DisputeRepository interface {
+	// CreateDispute opens a dispute on a transaction of its account.
+	CreateDispute(context.Context, *Dispute) error
+	// GetDisputes lists the disputes of an account, of every account if
+	// number is 0, with status if not empty, oldest first.
+	GetDisputes(ctx context.Context, number int64, status DisputeStatus, limit, offset int) ([]*Dispute, error)
+	// DecideDispute denies an open dispute, or reverses it with a journal
+	// entry compensating the disputed one: the disputing account is
+	// credited and the cash or fees book, or the counterparty of a transfer,
+	// debited. Counterparties are debited even if inactive or left
+	// overdrawn.
+	DecideDispute(ctx context.Context, id int, decision *DisputeDecision) (*Dispute, error)
+}
+
+type FraudRepository interface {
+	CreateFraudReview(context.Context, *FraudReview) error
+	// GetFraudReviews lists the reviews with status, all of them if empty,
+	// oldest first.
+	GetFraudReviews(ctx context.Context, status FraudReviewStatus, limit, offset int) ([]*FraudReview, error)
+	// DecideFraudReview clears or confirms a pending review.
+	DecideFraudReview(ctx context.Context, id int, status FraudReviewStatus, reviewedBy int64, reviewedAt time.Time) (*FraudReview, error)
+	// RecordLoginNetwork remembers that the holder of number logged in from
+	// network.
+	RecordLoginNetwork(ctx context.Context, number int64, network string, at time.Time) error
+	GetLoginNetworks(ctx context.Context, number int64) ([]string, error)
+	// CountTransfersFrom counts the transfers the account made since.
+	CountTransfersFrom(ctx context.Context, number int64, since time.Time) (int, error)
+	// HasTransferredTo reports whether from ever paid to.
+	HasTransferredTo(ctx context.Context, from, to int64) (bool, error)
+}
 
-	accounts := []*Account{}
+type AMLRepository interface {
+	// CreateAMLFlag returns a conflict error if rule already flagged the
+	// transaction that raised flag.
+	CreateAMLFlag(context.Context, *AMLFlag) error
+	// GetAMLFlags lists the flags filter selects, oldest first, without
+	// their notes.
+	GetAMLFlags(ctx context.Context, filter *AMLFlagFilter) ([]*AMLFlag, error)
+	// GetAMLFlag returns the flag with its notes, oldest first.
+	GetAMLFlag(ctx context.Context, id int) (*AMLFlag, error)
+	// UpdateAMLFlagStatus moves the flag to status if it may move there.
+	UpdateAMLFlagStatus(ctx context.Context, id int, status AMLFlagStatus, reviewedBy int64, at time.Time) (*AMLFlag, error)
+	AddAMLNote(context.Context, *AMLNote) error
+	// GetAMLNotes returns the notes of the flags with ids, oldest first.
+	GetAMLNotes(ctx context.Context, flagIDs []int) ([]*AMLNote, error)
+}
 
-	for rows.Next() {
-		account, err := scanIntoAccount(rows)
+type ScheduledTransferRepository interface {
+	CreateScheduledTransfer(context.Context, *ScheduledTransfer) error
+	GetScheduledTransfers(ctx context.Context, accountNumber int64) ([]*ScheduledTransfer, error)
+	CancelScheduledTransfer(ctx context.Context, id int, accountNumber int64) error
+	ClaimDueScheduledTransfers(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*ScheduledTransfer, error)
+	RecordScheduledTransferRun(context.Context, *ScheduledTransfer, *ScheduledTransferRun) error
+}
 
-		if err != nil {
-			return nil, err
-		}
+type StandingOrderRepository interface {
+	CreateStandingOrder(context.Context, *StandingOrder) error
+	GetStandingOrders(ctx context.Context, accountNumber int64) ([]*StandingOrder, error)
+	CancelStandingOrder(ctx context.Context, id int, accountNumber int64) error
+	// ClaimDueStandingOrders leases up to limit active orders due at now
+	// until leaseUntil.
+	ClaimDueStandingOrders(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*StandingOrder, error)
+	// UpdateStandingOrder stores the state the runner advanced the order to
+	// and releases its lease.
+	UpdateStandingOrder(context.Context, *StandingOrder) error
+}
 
-		accounts = append(accounts, account)
-	}
+type HolidayRepository interface {
+	// AddHoliday fails with a conflict when the date already is a holiday.
+	AddHoliday(context.Context, *Holiday) error
+	// GetHolidays returns the whole calendar, by date.
+	GetHolidays(context.Context) ([]*Holiday, error)
+	DeleteHoliday(ctx context.Context, date string) error
+}
 
-	return accounts, nil
+type WebhookRepository interface {
+	CreateWebhook(context.Context, *Webhook) error
+	GetWebhooks(ctx context.Context, accountNumber *int64) ([]*Webhook, error)
+	DeleteWebhook(ctx context.Context, id int, accountNumber *int64) error
+	GetWebhookDeliveries(ctx context.Context, webhookID int, accountNumber *int64, limit, offset int) ([]*WebhookDelivery, error)
+	EnqueueWebhookDeliveries(ctx context.Context, event *Event, payload []byte, balance *int64) error
+	ClaimDueWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error)
+	UpdateWebhookDelivery(context.Context, *WebhookDelivery) error
+}
 
+type TokenRepository interface {
+	CreateRefreshToken(context.Context, *RefreshToken) error
+	// RotateRefreshToken revokes the token identified by tokenHash and
+	// persists next in its place, in the same session, which is seen from
+	// device.
+	RotateRefreshToken(ctx context.Context, tokenHash string, next *RefreshToken, device Device) error
 }
 
-func (s *PostgresStore) GetAccountById(id int) (*Account, error) {
+type SessionRepository interface {
+	CreateSession(context.Context, *Session) error
+	// GetSessions lists the sessions of an account that are neither revoked
+	// nor expired at now, the most recently seen first.
+	GetSessions(ctx context.Context, number int64, now time.Time) ([]*Session, error)
+	// RevokeSessions revokes session id of an account, or all of its
+	// sessions if id is 0, with their refresh tokens, and denies the access
+	// tokens issued to them until deniedUntil. It returns the sessions it
+	// revoked.
+	RevokeSessions(ctx context.Context, number int64, id int, now, deniedUntil time.Time) ([]*Session, error)
+	// SessionDenied reports whether the access tokens of session id are
+	// denied at now.
+	SessionDenied(ctx context.Context, id int, now time.Time) (bool, error)
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(context.Context, *APIKey) error
+	// GetAPIKeys lists the keys of an account, revoked ones included, the
+	// newest first.
+	GetAPIKeys(ctx context.Context, number int64) ([]*APIKey, error)
+	// GetAPIKeyByHash returns the key with the hash, revoked or not.
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	// TouchAPIKey records that key id was used at now.
+	TouchAPIKey(ctx context.Context, id int, now time.Time) error
+	// RevokeAPIKey revokes key id of the number account at now and returns
+	// it. Revoking a revoked key is a conflict.
+	RevokeAPIKey(ctx context.Context, id int, number int64, now time.Time) (*APIKey, error)
+}
 
-	rows, err := s.db.Query("select * from account where id = $1", id)
+type BusinessUserRepository interface {
+	// CreateBusinessUser adds a user to a business account. A username
+	// another user of the account has is a conflict.
+	CreateBusinessUser(context.Context, *BusinessUser) error
+	// GetBusinessUser returns user id, removed or not.
+	GetBusinessUser(ctx context.Context, id int) (*BusinessUser, error)
+	// GetBusinessUserByUsername returns the user of the number account with
+	// username who hasn't been removed.
+	GetBusinessUserByUsername(ctx context.Context, number int64, username string) (*BusinessUser, error)
+	// GetBusinessUsers lists the users of an account who haven't been
+	// removed, the oldest first.
+	GetBusinessUsers(ctx context.Context, number int64) ([]*BusinessUser, error)
+	// SetBusinessUserRole changes the role of user id of the number account
+	// and returns the user before and after.
+	SetBusinessUserRole(ctx context.Context, id int, number int64, role BusinessUserRole) (before, after *BusinessUser, err error)
+	// RemoveBusinessUser removes user id of the number account at now and
+	// revokes their sessions, denying their access tokens until deniedUntil.
+	RemoveBusinessUser(ctx context.Context, id int, number int64, now, deniedUntil time.Time) (*BusinessUser, error)
+}
+
+type LoginThrottleRepository interface {
+	// GetLoginThrottles returns the throttles of those keys that have one.
+	GetLoginThrottles(ctx context.Context, keys []string) ([]*LoginThrottle, error)
+	// RecordLoginFailure counts a failed login against key at now under
+	// policy, creating its throttle on the first failure.
+	RecordLoginFailure(ctx context.Context, key string, policy LoginPolicy, now time.Time) (*LoginThrottle, error)
+	// ClearLoginThrottle forgets the failures of key and returns its
+	// throttle as it was, or a not found error if it had none.
+	ClearLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error)
+}
+
+type PasswordResetRepository interface {
+	// CreatePasswordReset stores reset, invalidating the account's unused
+	// resets.
+	CreatePasswordReset(context.Context, *PasswordReset) error
+	// RedeemPasswordReset marks the reset identified by tokenHash as used and
+	// resets the password of its account like ResetPassword, returning the
+	// account number. Unknown, used and expired tokens are unauthorized.
+	RedeemPasswordReset(ctx context.Context, tokenHash, encryptedPassword string) (int64, error)
+}
+
+type TermDepositRepository interface {
+	// CreateTermDeposit debits the deposit's principal from its account.
+	CreateTermDeposit(context.Context, *TermDeposit) (*Transaction, error)
+	GetTermDeposits(ctx context.Context, number int64) ([]*TermDeposit, error)
+	GetTermDeposit(ctx context.Context, id int, number int64) (*TermDeposit, error)
+	// GetTermDepositsDue lists up to limit active deposits matured by now,
+	// the longest matured first.
+	GetTermDepositsDue(ctx context.Context, now time.Time, limit int) ([]*TermDeposit, error)
+	// CloseTermDeposit pays the deposit back into its account on now, with
+	// a penalty of penaltyDays of interest before maturity. Closing a
+	// deposit that isn't active is a conflict.
+	CloseTermDeposit(ctx context.Context, id int, number int64, now time.Time, penaltyDays int) (*TermDeposit, *Transaction, error)
+}
+
+type PotRepository interface {
+	// CreatePot fails with a conflict when the account already has a pot of
+	// the same name.
+	CreatePot(context.Context, *Pot) error
+	GetPots(ctx context.Context, number int64) ([]*Pot, error)
+	GetPot(ctx context.Context, id int, number int64) (*Pot, error)
+	// UpdatePot changes the name, target and sweep rules of the pot, not its
+	// balance.
+	UpdatePot(context.Context, *Pot) error
+	// DeletePot moves the pot's balance back to the account and deletes it.
+	DeletePot(ctx context.Context, id int, number int64) (*Pot, error)
+	// MovePotMoney moves amount from the account's available balance into
+	// the pot, or back out of the pot when amount is negative.
+	MovePotMoney(ctx context.Context, id int, number int64, amount int64) (*Pot, error)
+	// SweepPots moves the weekly amount of up to limit pots due at now into
+	// them, and schedules their next sweep a week later.
+	SweepPots(ctx context.Context, now time.Time, limit int) ([]*PotSweep, error)
+}
+
+type OutboxRepository interface {
+	// ClaimOutboxMessages leases up to limit unpublished messages due at now
+	// until leaseUntil, oldest first.
+	ClaimOutboxMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*OutboxMessage, error)
+	// UpdateOutboxMessage records a publishing attempt and releases the
+	// lease.
+	UpdateOutboxMessage(context.Context, *OutboxMessage) error
+}
+
+// StatsRepository aggregates across accounts for the admin dashboard.
+type StatsRepository interface {
+	GetAccountStats(context.Context) (*AccountStats, error)
+	// GetTransferVolume totals the transfers made in [from, to) per day and
+	// source currency.
+	GetTransferVolume(ctx context.Context, from, to time.Time) ([]*DailyTransferVolume, error)
+	// GetFailedLogins counts the failed logins audited in [from, to) per day.
+	GetFailedLogins(ctx context.Context, from, to time.Time) ([]*DailyCount, error)
+	// GetLargestAccounts returns up to limit accounts by descending balance,
+	// only those in currency unless it is empty.
+	GetLargestAccounts(ctx context.Context, currency string, limit int) ([]*Account, error)
+}
+
+type TOTPRepository interface {
+	// CreateTOTP starts an enrollment, replacing any pending one. It fails
+	// with a conflict if the account already has two-factor enabled.
+	CreateTOTP(context.Context, *TOTP) error
+	GetTOTP(ctx context.Context, number int64) (*TOTP, error)
+	// EnableTOTP enables the pending enrollment and replaces the account's
+	// backup codes.
+	EnableTOTP(ctx context.Context, number int64, backupCodeHashes []string) error
+	// UseTOTPBackupCode marks an unused backup code as used, or returns a not
+	// found error.
+	UseTOTPBackupCode(ctx context.Context, number int64, codeHash string) error
+}
+
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey inserts rec as pending. If the key already exists
+	// in the scope the stored record is returned and reserved is false.
+	ReserveIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (stored *IdempotencyRecord, reserved bool, err error)
+	CompleteIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, key, scope string) error
+}
+
+type Storage interface {
+	AccountRepository
+	TransactionRepository
+	EventRepository
+	InterestRepository
+	BalanceHistoryRepository
+	LedgerRepository
+	ReconciliationRepository
+	DatasetRepository
+	TransferRepository
+	TransferBatchRepository
+	HoldRepository
+	TransferQuoteRepository
+	PaymentRequestRepository
+	CardRepository
+	ExternalTransferRepository
+	ImportRepository
+	BeneficiaryRepository
+	AliasRepository
+	ContactRepository
+	AuditRepository
+	MaintenanceRepository
+	IdentityRepository
+	KYCRepository
+	OwnerRepository
+	TransferApprovalRepository
+	AdminApprovalRepository
+	FraudRepository
+	AMLRepository
+	DisputeRepository
+	PotRepository
+	TermDepositRepository
+	ScheduledTransferRepository
+	StandingOrderRepository
+	HolidayRepository
+	WebhookRepository
+	NotificationRepository
+	LoanRepository
+	OutboxRepository
+	StatsRepository
+	TokenRepository
+	SessionRepository
+	APIKeyRepository
+	BusinessUserRepository
+	LoginThrottleRepository
+	PasswordResetRepository
+	TOTPRepository
+	IdempotencyRepository
+
+	// Ping reports whether the store can serve requests.
+	Ping(ctx context.Context) error
+
+	// PendingMigrations returns the versions of the migrations that have not
+	// been applied yet, oldest first.
+	PendingMigrations(ctx context.Context) ([]int, error)
+}
+
+// PostgresStore runs its queries through database/sql on top of a pgx pool,
+// which bounds the number of connections and prepares every statement once
+// per connection, caching it for the hot paths.
+type PostgresStore struct {
+	db   *sql.DB
+	pool *pgxpool.Pool
+
+	// migrations holds the migrations/*.sql files of the schema
+	migrations fs.FS
+
+	// replicas serve the read-only queries that go through reader
+	replicas        []*replica
+	nextReplica     atomic.Uint64
+	stopReplicas    context.CancelFunc
+	replicasWatched chan struct{}
+
+	// journalCommitted is the fn of OnJournalCommitted
+	journalCommitted func(context.Context, *JournalEntry)
+}
+
+var _ Storage = (*PostgresStore)(nil)
+
+func NewPostgresStore(ctx context.Context, cfg *Config) (*PostgresStore, error) {
+	pool, err := newPool(ctx, cfg, cfg.DatabaseURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	replicas, err := openReplicas(ctx, cfg)
 
 	if err != nil {
+		pool.Close()
 		return nil, err
 	}
 
-	for rows.Next() {
-		return scanIntoAccount(rows)
+	s := &PostgresStore{
+		db:         stdlib.OpenDBFromPool(pool),
+		pool:       pool,
+		migrations: migrationFiles,
+		replicas:   replicas,
 	}
 
-	return nil, fmt.Errorf("account %d not found", id)
-}
+	if len(replicas) > 0 {
+		watchCtx, cancel := context.WithCancel(context.Background())
+		s.stopReplicas = cancel
+		s.replicasWatched = make(chan struct{})
+
+		go s.watchReplicas(watchCtx, cfg.ReplicaMaxLag)
+	}
 
-func (s *PostgresStore) GetAccountByNumber(number int) (*Account, error) {
+	return s, nil
+}
 
-	rows, err := s.db.Query("select * from account where number = $1", number)
+// newPool configures a pool to dsn with the pool settings of cfg.
+func newPool(ctx context.Context, cfg *Config, dsn string) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 
 	if err != nil {
 		return nil, err
 	}
 
-	for rows.Next() {
-		return scanIntoAccount(rows)
+	poolConfig.MaxConns = int32(cfg.DBMaxConns)
+	poolConfig.MinConns = int32(cfg.DBMinConns)
+	poolConfig.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolConfig.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolConfig.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+	poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+
+	if cfg.DBQueryTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.DBQueryTimeout.Milliseconds(), 10)
 	}
 
-	return nil, fmt.Errorf("account with number %d not found", number)
-}
+	if cfg.DBSlowQuery > 0 {
+		poolConfig.ConnConfig.Tracer = &slowStatementTracer{threshold: cfg.DBSlowQuery}
+	}
 
-func scanIntoAccount(rows *sql.Rows) (*Account, error) {
-	account := new(Account)
+	return pgxpool.NewWithConfig(ctx, poolConfig)
+}
 
-	err := rows.Scan(&account.ID, &account.FirstName, &account.LastName, &account.Number, &account.EncryptedPassword, &account.Balance, &account.CreatedAt)
+// withoutStatementTimeout lifts dbQueryTimeout for the rest of tx, for
+// the work that is expected to run long.
+func withoutStatementTimeout(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "set local statement_timeout = 0")
+	return err
+}
 
-	if err != nil {
-		return nil, err
+func (s *PostgresStore) Close() error {
+	if s.stopReplicas != nil {
+		s.stopReplicas()
+		<-s.replicasWatched
 	}
 
-	return account, nil
+	closeReplicas(s.replicas)
+
+	err := s.db.Close()
+	s.pool.Close()
+
+	return err
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// Init brings the schema up to date by applying any pending migrations.
+func (s *PostgresStore) Init(ctx context.Context) error {
+	return s.MigrateUp(ctx)
 }
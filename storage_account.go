@@ -0,0 +1,629 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (s *PostgresStore) CreateAccount(ctx context.Context, acc *Account) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := insertAccount(ctx, tx, acc); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SeedAccount books the history in the transaction that creates the
+// account, so a concurrent seed of the same account either finds it whole
+// or fails on its number.
+func (s *PostgresStore) SeedAccount(ctx context.Context, acc *Account, history []*Transaction) error {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	if err := insertAccount(ctx, tx, acc); err != nil {
+		return err
+	}
+
+	for _, t := range history {
+		entry, err := beginJournalEntry(ctx, tx, seedJournalKinds[t.Type], t.CreatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := applyTransaction(ctx, tx, entry, acc, t.Type, t.Amount, nil); err != nil {
+			return err
+		}
+
+		entry.post(LedgerCash, nil, acc.Currency, -t.Amount)
+
+		if err := commitJournalEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateAccountWithTransfer locks the paying account with the new one, so
+// the transfer is checked against its current balance.
+func (s *PostgresStore) CreateAccountWithTransfer(ctx context.Context, acc *Account, transfer *Transfer) error {
+	if transfer.Amount <= 0 || transfer.ToAmount <= 0 {
+		return validationError("invalid amount %d", transfer.Amount)
+	}
+
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	if err := insertAccount(ctx, tx, acc); err != nil {
+		return err
+	}
+
+	transfer.ToAccount = acc.Number
+
+	accounts, err := lockAccounts(ctx, tx, transfer.FromAccount, acc.Number)
+
+	if err != nil {
+		return err
+	}
+
+	if err := transferLocked(ctx, tx, accounts, transfer); err != nil {
+		return err
+	}
+
+	acc.Balance, acc.Version = accounts[acc.Number].Balance, accounts[acc.Number].Version
+
+	return tx.Commit()
+}
+
+func insertAccount(ctx context.Context, tx *sql.Tx, acc *Account) error {
+	query := `
+	insert into account
+	(first_name, last_name, number, encrypted_password, balance, currency, role, type, status, kyc_status, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	returning id, version`
+
+	err := tx.QueryRowContext(ctx, query, acc.FirstName, acc.LastName, acc.Number, acc.EncryptedPassword, acc.Balance, acc.Currency, acc.Role, acc.Type, acc.Status, acc.KYCStatus, acc.CreatedAt).Scan(&acc.ID, &acc.Version)
+
+	if err != nil {
+		return pgError(err)
+	}
+
+	return appendAccountEvent(ctx, tx, newAccountOpenedEvent(acc))
+}
+
+// UpdateAccountStatus locks the account so the status change is checked
+// against its current balance, which a concurrent debit could otherwise change.
+func (s *PostgresStore) UpdateAccountStatus(ctx context.Context, id int, status AccountStatus) (*Account, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	account, err := lockAccountById(ctx, tx, id, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := account.CheckStatusChange(status); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set status = $1 where id = $2", status, id); err != nil {
+		return nil, err
+	}
+
+	account.Status = status
+
+	return account, tx.Commit()
+}
+
+// DeleteAccount locks the account for the same reason as UpdateAccountStatus.
+func (s *PostgresStore) DeleteAccount(ctx context.Context, id int, now time.Time) (*Account, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	account, err := lockAccountById(ctx, tx, id, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := account.CheckDelete(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set deleted_at = $1 where id = $2", now, id); err != nil {
+		return nil, err
+	}
+
+	account.DeletedAt = &now
+
+	return account, tx.Commit()
+}
+
+// CloseAccount locks the account and the one its balance is swept to, so
+// nothing moves money in or out of it while it closes.
+func (s *PostgresStore) CloseAccount(ctx context.Context, number, sweepTo int64) (*AccountClosure, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	numbers := []int64{number}
+
+	if sweepTo != 0 && sweepTo != number {
+		numbers = append(numbers, sweepTo)
+	}
+
+	accounts, err := lockAccounts(ctx, tx, numbers...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[number]
+
+	if err := acc.CheckClose(accounts[sweepTo]); err != nil {
+		return nil, err
+	}
+
+	closure := &AccountClosure{Account: acc}
+
+	if acc.Balance > 0 {
+		closure.Sweep = newSweepTransfer(acc, sweepTo)
+
+		if err := sweepLocked(ctx, tx, accounts, closure.Sweep); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "update standing_order set status = $1 where from_account = $2 and status = $3", StandingOrderCancelled, number, StandingOrderActive)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if closure.CancelledStandingOrders, err = rowsAffected(res); err != nil {
+		return nil, err
+	}
+
+	res, err = tx.ExecContext(ctx, "update scheduled_transfer set status = $1 where from_account = $2 and status = $3", ScheduledTransferCancelled, number, ScheduledTransferActive)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if closure.CancelledScheduledTransfers, err = rowsAffected(res); err != nil {
+		return nil, err
+	}
+
+	if err := tx.QueryRowContext(ctx, "update account set status = $1, version = version + 1 where number = $2 returning version", AccountClosed, number).Scan(&acc.Version); err != nil {
+		return nil, err
+	}
+
+	acc.Status = AccountClosed
+
+	return closure, tx.Commit()
+}
+
+// sweepLocked moves the whole balance of a closing account to the other
+// locked account. Unlike transferLocked it ignores the minimum balance and
+// charges no overdraft fee, since the account is being emptied.
+func sweepLocked(ctx context.Context, tx *sql.Tx, accounts map[int64]*Account, transfer *Transfer) error {
+	from, to := transfer.FromAccount, transfer.ToAccount
+
+	entry, err := beginJournalEntry(ctx, tx, JournalTransfer, transfer.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := applyTransaction(ctx, tx, entry, accounts[from], TransactionTransferOut, -transfer.Amount, &to); err != nil {
+		return err
+	}
+
+	if _, err := applyTransaction(ctx, tx, entry, accounts[to], TransactionTransferIn, transfer.ToAmount, &from); err != nil {
+		return err
+	}
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	return insertTransfer(ctx, tx, transfer)
+}
+
+func rowsAffected(res sql.Result) (int, error) {
+	n, err := res.RowsAffected()
+
+	return int(n), err
+}
+
+func (s *PostgresStore) RestoreAccount(ctx context.Context, id int) (*Account, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	account, err := lockAccountById(ctx, tx, id, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set deleted_at = null where id = $1", id); err != nil {
+		return nil, err
+	}
+
+	account.DeletedAt = nil
+
+	return account, tx.Commit()
+}
+
+// lockAccountById locks the account for the rest of tx. It only finds
+// soft-deleted accounts when deleted is true, and only others otherwise.
+func lockAccountById(ctx context.Context, tx *sql.Tx, id int, deleted bool) (*Account, error) {
+	rows, err := tx.QueryContext(ctx, "select "+accountColumns+" from account where id = $1 and (deleted_at is not null) = $2 for update", id, deleted)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if deleted {
+			return nil, accountNotFoundError("deleted account %d not found", id)
+		}
+
+		return nil, accountNotFoundError("account %d not found", id)
+	}
+
+	return scanIntoAccount(rows)
+}
+
+// UpdateAccount renames the account. When acc.Version is set the account
+// must still be at that version; acc.Version is then the new one.
+func (s *PostgresStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	query := "update account set first_name = $1, last_name = $2 where id = $3 and deleted_at is null and ($4 = 0 or version = $4) returning version"
+
+	err := s.db.QueryRowContext(ctx, query, acc.FirstName, acc.LastName, acc.ID, acc.Version).Scan(&acc.Version)
+
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	current, err := s.GetAccountById(ctx, acc.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return current.CheckVersion(acc.Version)
+}
+
+// UpdateAccountMetadata merges patch into the metadata of the account,
+// which must still be at version unless that is 0.
+func (s *PostgresStore) UpdateAccountMetadata(ctx context.Context, id int, patch map[string]*string, version int) (*Account, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	account, err := lockAccountById(ctx, tx, id, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := account.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	metadata, err := mergeMetadata(account.Metadata, patch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(metadata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.QueryRowContext(ctx, "update account set metadata = $1 where id = $2 returning version", encoded, id).Scan(&account.Version); err != nil {
+		return nil, err
+	}
+
+	account.Metadata = metadata
+
+	return account, tx.Commit()
+}
+
+func (s *PostgresStore) ResetPassword(ctx context.Context, number int64, encryptedPassword string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := resetPassword(ctx, tx, number, encryptedPassword); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// resetPassword replaces the password and revokes the refresh tokens of the
+// account within tx.
+func resetPassword(ctx context.Context, tx *sql.Tx, number int64, encryptedPassword string) error {
+	res, err := tx.ExecContext(ctx, "update account set encrypted_password = $1 where number = $2 and deleted_at is null", encryptedPassword, number)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return accountNotFoundError("account with number %d not found", number)
+	}
+
+	_, err = tx.ExecContext(ctx, "update refresh_token set revoked_at = $1 where account_number = $2 and revoked_at is null", time.Now().UTC(), number)
+
+	return err
+}
+
+// accountSortColumns whitelists the columns GET /account can be sorted by.
+var accountSortColumns = map[string]string{
+	"created_at": "created_at",
+	"last_name":  "last_name",
+}
+
+func (s *PostgresStore) UpdateAccountLimits(ctx context.Context, id int, limits AccountLimits) error {
+	query := "update account set overdraft_limit = $1, minimum_balance = $2, overdraft_fee = $3, dual_approval_amount = $4 where id = $5 and deleted_at is null"
+
+	res, err := s.db.ExecContext(ctx, query, limits.OverdraftLimit, limits.MinimumBalance, limits.OverdraftFee, limits.DualApprovalAmount, id)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return accountNotFoundError("account %d not found", id)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) GetAccounts(ctx context.Context, filter AccountFilter) ([]*Account, error) {
+	// containment is what the account_metadata_idx index serves
+	return s.getAccounts(ctx, filter, "metadata @> $%d")
+}
+
+// getAccounts lists the accounts filter matches, with contains the format
+// of the condition that the metadata contains the parameter numbered %d.
+func (s *PostgresStore) getAccounts(ctx context.Context, filter AccountFilter, contains string) ([]*Account, error) {
+	conditions := []string{"deleted_at is null"}
+	args := []any{}
+
+	if filter.LastName != "" {
+		args = append(args, filter.LastName)
+		conditions = append(conditions, fmt.Sprintf("last_name = $%d", len(args)))
+	}
+
+	if filter.MinBalance != nil {
+		args = append(args, *filter.MinBalance)
+		conditions = append(conditions, fmt.Sprintf("balance >= $%d", len(args)))
+	}
+
+	if len(filter.Metadata) > 0 {
+		metadata, err := json.Marshal(filter.Metadata)
+
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, metadata)
+		conditions = append(conditions, fmt.Sprintf(contains, len(args)))
+	}
+
+	query := "select " + accountColumns + " from account where " + strings.Join(conditions, " and ")
+
+	orderBy, err := accountOrderBy(filter.Sort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" order by %s limit $%d offset $%d", orderBy, len(args)-1, len(args))
+
+	rows, err := s.reader().QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := []*Account{}
+
+	for rows.Next() {
+		account, err := scanIntoAccount(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (s *PostgresStore) SearchAccounts(ctx context.Context, q string, limit, offset int) ([]*Account, error) {
+	query := `select ` + accountColumns + ` from account
+		where deleted_at is null
+			and ((first_name || ' ' || last_name) ilike $1
+				or number::text like $1
+				or first_name % $2
+				or last_name % $2
+				or (first_name || ' ' || last_name) % $2)
+		order by greatest(similarity(first_name, $2), similarity(last_name, $2), similarity(first_name || ' ' || last_name, $2))
+			+ case when (first_name || ' ' || last_name) ilike $1 or number::text like $1 then 1 else 0 end desc, id
+		limit $3 offset $4`
+
+	return s.searchAccounts(ctx, query, q, limit, offset)
+}
+
+// searchAccounts runs a SearchAccounts query, whose parameters are the like
+// pattern of q, q itself, limit and offset.
+func (s *PostgresStore) searchAccounts(ctx context.Context, query, q string, limit, offset int) ([]*Account, error) {
+	rows, err := s.reader().QueryContext(ctx, query, likePattern(q), q, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := []*Account{}
+
+	for rows.Next() {
+		account, err := scanIntoAccount(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (s *PostgresStore) GetAccountById(ctx context.Context, id int) (*Account, error) {
+
+	rows, err := s.db.QueryContext(ctx, "select "+accountColumns+" from account where id = $1 and deleted_at is null", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoAccount(rows)
+	}
+
+	return nil, accountNotFoundError("account %d not found", id)
+}
+
+func (s *PostgresStore) GetAccountByNumber(ctx context.Context, number int) (*Account, error) {
+
+	rows, err := s.db.QueryContext(ctx, "select "+accountColumns+" from account where number = $1 and deleted_at is null", number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoAccount(rows)
+	}
+
+	return nil, accountNotFoundError("account with number %d not found", number)
+}
+
+func accountOrderBy(sort string) (string, error) {
+	if sort == "" {
+		return "id", nil
+	}
+
+	direction := "asc"
+
+	if strings.HasPrefix(sort, "-") {
+		sort, direction = sort[1:], "desc"
+	}
+
+	column, ok := accountSortColumns[sort]
+
+	if !ok {
+		return "", badRequestError("invalid sort %s", sort)
+	}
+
+	// id breaks ties so pages are stable
+	return column + " " + direction + ", id " + direction, nil
+}
+
+const accountColumns = "id, first_name, last_name, number, encrypted_password, balance, currency, role, created_at, overdraft_limit, minimum_balance, overdraft_fee, type, accrued_interest, interest_remainder, interest_accrued_through, status, held_balance, deleted_at, kyc_status, dual_approval_amount, pot_balance, version, metadata"
+
+func scanIntoAccount(rows *sql.Rows) (*Account, error) {
+	account := new(Account)
+	var metadata []byte
+
+	err := rows.Scan(&account.ID, &account.FirstName, &account.LastName, &account.Number, &account.EncryptedPassword, &account.Balance, &account.Currency, &account.Role, &account.CreatedAt, &account.OverdraftLimit, &account.MinimumBalance, &account.OverdraftFee, &account.Type, &account.AccruedInterest, &account.InterestRemainder, &account.InterestAccruedThrough, &account.Status, &account.HeldBalance, &account.DeletedAt, &account.KYCStatus, &account.DualApprovalAmount, &account.PotBalance, &account.Version, &metadata)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(metadata, &account.Metadata); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
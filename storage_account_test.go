@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountOrderBy(t *testing.T) {
+	orderBy, err := accountOrderBy("")
+	assert.Nil(t, err)
+	assert.Equal(t, "id", orderBy)
+
+	orderBy, err = accountOrderBy("-last_name")
+	assert.Nil(t, err)
+	assert.Equal(t, "last_name desc, id desc", orderBy)
+
+	_, err = accountOrderBy("balance; drop table account")
+	assert.NotNil(t, err)
+}
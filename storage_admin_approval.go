@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const adminApprovalColumns = "id, action, account_number, amount, limits, reason_code, reason, proposed_by, decided_by, status, transaction_id, error, created_at, decided_at"
+
+func (s *PostgresStore) CreateAdminApproval(ctx context.Context, approval *AdminApproval) error {
+	var limits []byte
+
+	if approval.Limits != nil {
+		data, err := json.Marshal(approval.Limits)
+
+		if err != nil {
+			return err
+		}
+
+		limits = data
+	}
+
+	query := `
+	insert into admin_approval
+	(action, account_number, amount, limits, reason_code, reason, proposed_by, status, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	returning id`
+
+	err := s.db.QueryRowContext(ctx, query, approval.Action, approval.AccountNumber, approval.Amount, limits, approval.ReasonCode, approval.Reason, approval.ProposedBy, approval.Status, approval.CreatedAt).Scan(&approval.ID)
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) GetAdminApprovals(ctx context.Context, status AdminApprovalStatus, limit, offset int) ([]*AdminApproval, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+adminApprovalColumns+" from admin_approval where ($1 = '' or status = $1) order by id limit $2 offset $3", status, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	approvals := []*AdminApproval{}
+
+	for rows.Next() {
+		approval, err := scanIntoAdminApproval(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		approvals = append(approvals, approval)
+	}
+
+	return approvals, rows.Err()
+}
+
+// DecideAdminApproval locks the approval so two admins deciding at once
+// can't both carry it out.
+func (s *PostgresStore) DecideAdminApproval(ctx context.Context, id int, status AdminApprovalStatus, decidedBy int64, decidedAt time.Time) (*AdminApproval, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "select "+adminApprovalColumns+" from admin_approval where id = $1 for update", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, notFoundError("admin approval %d not found", id)
+	}
+
+	approval, err := scanIntoAdminApproval(rows)
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := approval.CheckDecision(status, decidedBy); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update admin_approval set status = $1, decided_by = $2, decided_at = $3 where id = $4", status, decidedBy, decidedAt, id); err != nil {
+		return nil, err
+	}
+
+	approval.Status = status
+	approval.DecidedBy = &decidedBy
+	approval.DecidedAt = &decidedAt
+
+	return approval, tx.Commit()
+}
+
+func (s *PostgresStore) UpdateAdminApproval(ctx context.Context, approval *AdminApproval) error {
+	var errMsg *string
+
+	if approval.Error != "" {
+		errMsg = &approval.Error
+	}
+
+	_, err := s.db.ExecContext(ctx, "update admin_approval set status = $1, transaction_id = $2, error = $3 where id = $4", approval.Status, approval.TransactionID, errMsg, approval.ID)
+
+	return err
+}
+
+func scanIntoAdminApproval(rows *sql.Rows) (*AdminApproval, error) {
+	approval := new(AdminApproval)
+
+	var limits []byte
+	var errMsg sql.NullString
+
+	err := rows.Scan(&approval.ID, &approval.Action, &approval.AccountNumber, &approval.Amount, &limits, &approval.ReasonCode, &approval.Reason, &approval.ProposedBy, &approval.DecidedBy, &approval.Status, &approval.TransactionID, &errMsg, &approval.CreatedAt, &approval.DecidedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if limits != nil {
+		approval.Limits = new(AccountLimits)
+
+		if err := json.Unmarshal(limits, approval.Limits); err != nil {
+			return nil, err
+		}
+	}
+
+	approval.Error = errMsg.String
+
+	return approval, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const aliasColumns = "id, account_number, type, value, code_hash, code_expires_at, attempts, verified_at, created_at"
+
+func (s *PostgresStore) CreateAlias(ctx context.Context, alias *Alias) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var taken bool
+
+	if err := tx.QueryRowContext(ctx, "select exists (select 1 from alias where value = $1 and verified_at is not null)", alias.Value).Scan(&taken); err != nil {
+		return err
+	}
+
+	if taken {
+		return conflictError("alias %s is already in use", alias.Value)
+	}
+
+	query := `
+	insert into alias
+	(account_number, type, value, code_hash, code_expires_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6)
+	on conflict (account_number, value) do update
+	set code_hash = excluded.code_hash, code_expires_at = excluded.code_expires_at, attempts = 0
+	where alias.verified_at is null
+	returning id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, alias.AccountNumber, alias.Type, alias.Value, alias.CodeHash, alias.CodeExpiresAt, alias.CreatedAt).Scan(&alias.ID, &alias.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return conflictError("alias %s is already verified", alias.Value)
+	}
+
+	if err != nil {
+		return pgError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) GetAliases(ctx context.Context, number int64) ([]*Alias, error) {
+	return queryAliases(ctx, s.db, "where account_number = $1 order by id", number)
+}
+
+// VerifyAlias commits a wrong attempt before returning its error, so the
+// attempts can't be rolled back by guessing.
+func (s *PostgresStore) VerifyAlias(ctx context.Context, id int, number int64, codeHash string, now time.Time) (*Alias, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	aliases, err := queryAliases(ctx, tx, "where id = $1 and account_number = $2 for update", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(aliases) == 0 {
+		return nil, notFoundError("alias %d not found", id)
+	}
+
+	alias := aliases[0]
+	attempts := alias.Attempts
+
+	codeErr := alias.checkCode(codeHash, now)
+
+	if alias.Attempts != attempts {
+		if _, err := tx.ExecContext(ctx, "update alias set attempts = $1 where id = $2", alias.Attempts, id); err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	if codeErr != nil {
+		return nil, codeErr
+	}
+
+	alias.VerifiedAt = &now
+
+	_, err = tx.ExecContext(ctx, "update alias set verified_at = $1 where id = $2", now, id)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, conflictError("alias %s is already in use", alias.Value)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return alias, tx.Commit()
+}
+
+func (s *PostgresStore) DeleteAlias(ctx context.Context, id int, number int64) error {
+	res, err := s.db.ExecContext(ctx, "delete from alias where id = $1 and account_number = $2", id, number)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("alias %d not found", id)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) ResolveAlias(ctx context.Context, value string) (*Alias, error) {
+	aliases, err := queryAliases(ctx, s.db, "where value = $1 and verified_at is not null", value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(aliases) == 0 {
+		return nil, notFoundError("alias %s not found", value)
+	}
+
+	return aliases[0], nil
+}
+
+func queryAliases(ctx context.Context, db querier, where string, args ...any) ([]*Alias, error) {
+	rows, err := db.QueryContext(ctx, "select "+aliasColumns+" from alias "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	aliases := []*Alias{}
+
+	for rows.Next() {
+		a := new(Alias)
+
+		if err := rows.Scan(&a.ID, &a.AccountNumber, &a.Type, &a.Value, &a.CodeHash, &a.CodeExpiresAt, &a.Attempts, &a.VerifiedAt, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		aliases = append(aliases, a)
+	}
+
+	return aliases, rows.Err()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const amlFlagColumns = "id, account_number, rule, transaction_id, transaction_ids, amount, currency, status, reviewed_by, created_at, updated_at"
+
+func (s *PostgresStore) CreateAMLFlag(ctx context.Context, flag *AMLFlag) error {
+	query := `
+	insert into aml_flag
+	(account_number, rule, transaction_id, transaction_ids, amount, currency, status, created_at, updated_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	on conflict (rule, transaction_id) do nothing
+	returning id`
+
+	err := s.db.QueryRowContext(ctx, query, flag.AccountNumber, flag.Rule, flag.TransactionID, flag.TransactionIDs, flag.Amount, flag.Currency, flag.Status, flag.CreatedAt, flag.UpdatedAt).Scan(&flag.ID)
+
+	if err == sql.ErrNoRows {
+		return conflictError("transaction %d is already flagged by the %s rule", flag.TransactionID, flag.Rule)
+	}
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) GetAMLFlags(ctx context.Context, filter *AMLFlagFilter) ([]*AMLFlag, error) {
+	conditions := []string{}
+	args := []any{}
+
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.AccountNumber != 0 {
+		where("account_number = $%d", filter.AccountNumber)
+	}
+
+	if filter.Rule != "" {
+		where("rule = $%d", filter.Rule)
+	}
+
+	if filter.Status != "" {
+		where("status = $%d", filter.Status)
+	}
+
+	if !filter.From.IsZero() {
+		where("created_at >= $%d", filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		where("created_at < $%d", filter.To)
+	}
+
+	query := "order by id"
+
+	if len(conditions) > 0 {
+		query = "where " + strings.Join(conditions, " and ") + " " + query
+	}
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" limit $%d", len(args))
+	}
+
+	args = append(args, filter.Offset)
+	query += fmt.Sprintf(" offset $%d", len(args))
+
+	return queryAMLFlags(ctx, s.db, query, args...)
+}
+
+func (s *PostgresStore) GetAMLFlag(ctx context.Context, id int) (*AMLFlag, error) {
+	flags, err := queryAMLFlags(ctx, s.db, "where id = $1", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(flags) == 0 {
+		return nil, notFoundError("AML flag %d not found", id)
+	}
+
+	flag := flags[0]
+
+	if flag.Notes, err = s.GetAMLNotes(ctx, []int{id}); err != nil {
+		return nil, err
+	}
+
+	return flag, nil
+}
+
+// UpdateAMLFlagStatus locks the flag so two officers can't both move it.
+func (s *PostgresStore) UpdateAMLFlagStatus(ctx context.Context, id int, status AMLFlagStatus, reviewedBy int64, at time.Time) (*AMLFlag, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	flags, err := queryAMLFlags(ctx, tx, "where id = $1 for update", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(flags) == 0 {
+		return nil, notFoundError("AML flag %d not found", id)
+	}
+
+	flag := flags[0]
+
+	if err := flag.CheckTransition(status); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update aml_flag set status = $1, reviewed_by = $2, updated_at = $3 where id = $4", status, reviewedBy, at, id); err != nil {
+		return nil, err
+	}
+
+	flag.Status = status
+	flag.ReviewedBy = &reviewedBy
+	flag.UpdatedAt = at
+
+	return flag, tx.Commit()
+}
+
+func (s *PostgresStore) AddAMLNote(ctx context.Context, note *AMLNote) error {
+	query := `
+	insert into aml_note
+	(flag_id, author, body, created_at)
+	select id, $2, $3, $4 from aml_flag where id = $1
+	returning id`
+
+	err := s.db.QueryRowContext(ctx, query, note.FlagID, note.Author, note.Body, note.CreatedAt).Scan(&note.ID)
+
+	if err == sql.ErrNoRows {
+		return notFoundError("AML flag %d not found", note.FlagID)
+	}
+
+	return err
+}
+
+func (s *PostgresStore) GetAMLNotes(ctx context.Context, flagIDs []int) ([]*AMLNote, error) {
+	rows, err := s.db.QueryContext(ctx, "select id, flag_id, author, body, created_at from aml_note where flag_id = any($1) order by id", flagIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	notes := []*AMLNote{}
+
+	for rows.Next() {
+		note := new(AMLNote)
+
+		if err := rows.Scan(&note.ID, &note.FlagID, &note.Author, &note.Body, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, note)
+	}
+
+	return notes, rows.Err()
+}
+
+func queryAMLFlags(ctx context.Context, db querier, where string, args ...any) ([]*AMLFlag, error) {
+	rows, err := db.QueryContext(ctx, "select "+amlFlagColumns+" from aml_flag "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	// database/sql hands arrays over as text; the pgx type map parses them
+	typeMap := pgtype.NewMap()
+	flags := []*AMLFlag{}
+
+	for rows.Next() {
+		flag := new(AMLFlag)
+
+		if err := rows.Scan(&flag.ID, &flag.AccountNumber, &flag.Rule, &flag.TransactionID, typeMap.SQLScanner(&flag.TransactionIDs), &flag.Amount, &flag.Currency, &flag.Status, &flag.ReviewedBy, &flag.CreatedAt, &flag.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		flags = append(flags, flag)
+	}
+
+	return flags, rows.Err()
+}
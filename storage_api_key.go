@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const apiKeyColumns = "id, account_number, name, scopes, prefix, key_hash, created_at, last_used_at, revoked_at"
+
+func (s *PostgresStore) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	query := `
+	insert into api_key
+	(account_number, name, scopes, prefix, key_hash, created_at)
+	values
+	($1, $2, $3, $4, $5, $6)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, key.AccountNumber, key.Name, key.Scopes, key.Prefix, key.KeyHash, key.CreatedAt).Scan(&key.ID)
+}
+
+func (s *PostgresStore) GetAPIKeys(ctx context.Context, number int64) ([]*APIKey, error) {
+	return queryAPIKeys(ctx, s.db, "where account_number = $1 order by id desc", number)
+}
+
+func (s *PostgresStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	keys, err := queryAPIKeys(ctx, s.db, "where key_hash = $1", keyHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, notFoundError("API key not found")
+	}
+
+	return keys[0], nil
+}
+
+func (s *PostgresStore) TouchAPIKey(ctx context.Context, id int, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "update api_key set last_used_at = $2 where id = $1", id, now)
+	return err
+}
+
+func (s *PostgresStore) RevokeAPIKey(ctx context.Context, id int, number int64, now time.Time) (*APIKey, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	keys, err := queryAPIKeys(ctx, tx, "where id = $1 and account_number = $2 for update", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, notFoundError("API key %d not found", id)
+	}
+
+	key := keys[0]
+
+	if key.RevokedAt != nil {
+		return nil, conflictError("API key %d is already revoked", id)
+	}
+
+	if _, err := tx.ExecContext(ctx, "update api_key set revoked_at = $2 where id = $1", id, now); err != nil {
+		return nil, err
+	}
+
+	key.RevokedAt = &now
+
+	return key, tx.Commit()
+}
+
+func queryAPIKeys(ctx context.Context, db querier, where string, args ...any) ([]*APIKey, error) {
+	rows, err := db.QueryContext(ctx, "select "+apiKeyColumns+" from api_key "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	// database/sql hands arrays over as text; the pgx type map parses them
+	typeMap := pgtype.NewMap()
+	keys := []*APIKey{}
+
+	for rows.Next() {
+		key := new(APIKey)
+		scopes := []string{}
+
+		if err := rows.Scan(&key.ID, &key.AccountNumber, &key.Name, typeMap.SQLScanner(&scopes), &key.Prefix, &key.KeyHash, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+
+		for _, scope := range scopes {
+			key.Scopes = append(key.Scopes, APIKeyScope(scope))
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+)
+
+func (s *PostgresStore) RecordAudit(ctx context.Context, entry *AuditEntry) error {
+	query := `
+	insert into audit_log
+	(action, actor, account_number, ip, before, after, created_at, request_id, token_source)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, entry.Action, entry.Actor, entry.AccountNumber, entry.IP, []byte(entry.Before), []byte(entry.After), entry.CreatedAt, entry.RequestID, entry.TokenSource).Scan(&entry.ID)
+}
+
+func (s *PostgresStore) GetAuditLog(ctx context.Context, limit, offset int) ([]*AuditEntry, error) {
+	query := `
+	select id, action, actor, account_number, ip, before, after, created_at, request_id, token_source
+	from audit_log
+	order by id desc
+	limit $1 offset $2`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+
+	for rows.Next() {
+		entry := new(AuditEntry)
+		var before, after []byte
+
+		if err := rows.Scan(&entry.ID, &entry.Action, &entry.Actor, &entry.AccountNumber, &entry.IP, &before, &after, &entry.CreatedAt, &entry.RequestID, &entry.TokenSource); err != nil {
+			return nil, err
+		}
+
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
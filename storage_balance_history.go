@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *PostgresStore) NextClosingBalanceDay(ctx context.Context) (time.Time, error) {
+	query := "select coalesce((select max(day) + 1 from balances_history), (select min(created_at)::date from account))"
+
+	var day sql.NullTime
+
+	if err := s.db.QueryRowContext(ctx, query).Scan(&day); err != nil {
+		return time.Time{}, err
+	}
+
+	return day.Time, nil
+}
+
+// MaterializeClosingBalances takes each account's balance from its last
+// entry created before midnight, like GetBalanceAt.
+func (s *PostgresStore) MaterializeClosingBalances(ctx context.Context, day time.Time) (int, error) {
+	query := `
+	insert into balances_history (account_number, day, balance)
+	select a.number, $1::date, coalesce((
+		select t.balance
+		from transactions t
+		where t.account_number = a.number and t.created_at < $1::date + 1
+		order by t.id desc
+		limit 1
+	), 0)
+	from account a
+	where a.created_at < $1::date + 1
+	on conflict (account_number, day) do nothing`
+
+	res, err := s.db.ExecContext(ctx, query, day)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return int(n), err
+}
+
+func (s *PostgresStore) GetClosingBalances(ctx context.Context, number int64, from, to time.Time) ([]*ClosingBalance, error) {
+	query := "select account_number, to_char(day, 'YYYY-MM-DD'), balance from balances_history where account_number = $1 and day >= $2::date and day < $3::date order by day"
+
+	return s.queryClosingBalances(ctx, query, number, from, to)
+}
+
+func (s *PostgresStore) queryClosingBalances(ctx context.Context, query string, number int64, from, to time.Time) ([]*ClosingBalance, error) {
+	rows, err := s.reader().QueryContext(ctx, query, number, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	balances := []*ClosingBalance{}
+
+	for rows.Next() {
+		balance := new(ClosingBalance)
+
+		if err := rows.Scan(&balance.AccountNumber, &balance.Date, &balance.Balance); err != nil {
+			return nil, err
+		}
+
+		balances = append(balances, balance)
+	}
+
+	return balances, rows.Err()
+}
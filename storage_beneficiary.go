@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const beneficiaryColumns = "id, owner, name, account_number, nickname, cooling_off_until, created_at"
+
+func (s *PostgresStore) CreateBeneficiary(ctx context.Context, b *Beneficiary) error {
+	query := `
+	insert into beneficiary
+	(owner, name, account_number, nickname, cooling_off_until, created_at)
+	values
+	($1, $2, $3, $4, $5, $6)
+	returning id`
+
+	err := s.db.QueryRowContext(ctx, query, b.Owner, b.Name, b.AccountNumber, b.Nickname, b.CoolingOffUntil, b.CreatedAt).Scan(&b.ID)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return conflictError("account %d is already a beneficiary", b.AccountNumber)
+	}
+
+	return err
+}
+
+func (s *PostgresStore) GetBeneficiaries(ctx context.Context, owner int64) ([]*Beneficiary, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+beneficiaryColumns+" from beneficiary where owner = $1 order by id", owner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanBeneficiaries(rows)
+}
+
+func (s *PostgresStore) GetBeneficiary(ctx context.Context, id int, owner int64) (*Beneficiary, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+beneficiaryColumns+" from beneficiary where id = $1 and owner = $2", id, owner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	beneficiaries, err := scanBeneficiaries(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(beneficiaries) == 0 {
+		return nil, notFoundError("beneficiary %d not found", id)
+	}
+
+	return beneficiaries[0], nil
+}
+
+func (s *PostgresStore) DeleteBeneficiary(ctx context.Context, id int, owner int64) error {
+	res, err := s.db.ExecContext(ctx, "delete from beneficiary where id = $1 and owner = $2", id, owner)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("beneficiary %d not found", id)
+	}
+
+	return nil
+}
+
+func scanBeneficiaries(rows *sql.Rows) ([]*Beneficiary, error) {
+	defer rows.Close()
+
+	beneficiaries := []*Beneficiary{}
+
+	for rows.Next() {
+		b := new(Beneficiary)
+
+		if err := rows.Scan(&b.ID, &b.Owner, &b.Name, &b.AccountNumber, &b.Nickname, &b.CoolingOffUntil, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		beneficiaries = append(beneficiaries, b)
+	}
+
+	return beneficiaries, rows.Err()
+}
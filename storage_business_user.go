@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const businessUserColumns = "id, account_number, username, encrypted_password, role, created_at, removed_at"
+
+func (s *PostgresStore) CreateBusinessUser(ctx context.Context, user *BusinessUser) error {
+	query := `
+	insert into business_user
+	(account_number, username, encrypted_password, role, created_at)
+	values
+	($1, $2, $3, $4, $5)
+	returning id`
+
+	err := s.db.QueryRowContext(ctx, query, user.AccountNumber, user.Username, user.EncryptedPassword, user.Role, user.CreatedAt).Scan(&user.ID)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return conflictError("account with number %d already has a user %s", user.AccountNumber, user.Username)
+	}
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) GetBusinessUser(ctx context.Context, id int) (*BusinessUser, error) {
+	return s.queryBusinessUser(ctx, "where id = $1", id)
+}
+
+func (s *PostgresStore) GetBusinessUserByUsername(ctx context.Context, number int64, username string) (*BusinessUser, error) {
+	return s.queryBusinessUser(ctx, "where account_number = $1 and username = $2 and removed_at is null", number, username)
+}
+
+func (s *PostgresStore) GetBusinessUsers(ctx context.Context, number int64) ([]*BusinessUser, error) {
+	return queryBusinessUsers(ctx, s.db, "where account_number = $1 and removed_at is null order by id", number)
+}
+
+func (s *PostgresStore) SetBusinessUserRole(ctx context.Context, id int, number int64, role BusinessUserRole) (*BusinessUser, *BusinessUser, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer tx.Rollback()
+
+	users, err := queryBusinessUsers(ctx, tx, "where id = $1 and account_number = $2 and removed_at is null for update", id, number)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, nil, notFoundError("business user %d not found", id)
+	}
+
+	if _, err := tx.ExecContext(ctx, "update business_user set role = $2 where id = $1", id, role); err != nil {
+		return nil, nil, err
+	}
+
+	before := users[0]
+	after := *before
+	after.Role = role
+
+	return before, &after, tx.Commit()
+}
+
+// RemoveBusinessUser revokes the user's sessions like RevokeSessions does
+// the holder's, so their tokens stop working before they expire.
+func (s *PostgresStore) RemoveBusinessUser(ctx context.Context, id int, number int64, now, deniedUntil time.Time) (*BusinessUser, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	users, err := queryBusinessUsers(ctx, tx, "where id = $1 and account_number = $2 and removed_at is null for update", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, notFoundError("business user %d not found", id)
+	}
+
+	if _, err := tx.ExecContext(ctx, "update business_user set removed_at = $2 where id = $1", id, now); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "update session set revoked_at = $2 where user_id = $1 and revoked_at is null returning id", id, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIDs := []int{}
+
+	for rows.Next() {
+		var sessionID int
+
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if _, err := tx.ExecContext(ctx, "update refresh_token set revoked_at = $1 where session_id = $2 and revoked_at is null", now, sessionID); err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx, "insert into token_denylist (session_id, expires_at) values ($1, $2)", sessionID, deniedUntil); err != nil {
+			return nil, err
+		}
+	}
+
+	user := users[0]
+	user.RemovedAt = &now
+
+	return user, tx.Commit()
+}
+
+func (s *PostgresStore) queryBusinessUser(ctx context.Context, where string, args ...any) (*BusinessUser, error) {
+	users, err := queryBusinessUsers(ctx, s.db, where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, notFoundError("business user not found")
+	}
+
+	return users[0], nil
+}
+
+func queryBusinessUsers(ctx context.Context, db querier, where string, args ...any) ([]*BusinessUser, error) {
+	rows, err := db.QueryContext(ctx, "select "+businessUserColumns+" from business_user "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []*BusinessUser{}
+
+	for rows.Next() {
+		user := new(BusinessUser)
+
+		if err := rows.Scan(&user.ID, &user.AccountNumber, &user.Username, &user.EncryptedPassword, &user.Role, &user.CreatedAt, &user.RemovedAt); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
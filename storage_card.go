@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+const (
+	cardColumns              = "id, account_number, masked_pan, pan_hash, expiry_month, expiry_year, status, created_at"
+	cardAuthorizationColumns = "id, card_id, account_number, amount, currency, merchant, status, decline_reason, expires_at, created_at"
+)
+
+func (s *PostgresStore) CreateCard(ctx context.Context, card *Card) error {
+	query := `
+	insert into card
+	(account_number, masked_pan, pan_hash, expiry_month, expiry_year, status, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7)
+	returning id`
+
+	err := s.db.QueryRowContext(ctx, query, card.AccountNumber, card.MaskedPAN, card.PANHash, card.ExpiryMonth, card.ExpiryYear, card.Status, card.CreatedAt).Scan(&card.ID)
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) GetCards(ctx context.Context, number int64) ([]*Card, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+cardColumns+" from card where account_number = $1 order by id", number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCards(rows)
+}
+
+func (s *PostgresStore) UpdateCardStatus(ctx context.Context, id int, number int64, status CardStatus) (*Card, error) {
+	rows, err := s.db.QueryContext(ctx, "update card set status = $1 where id = $2 and account_number = $3 returning "+cardColumns, status, id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := scanCards(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cards) == 0 {
+		return nil, notFoundError("card %d not found", id)
+	}
+
+	return cards[0], nil
+}
+
+// AuthorizeCard locks the card so it can't be frozen while it is being
+// used, then the account like any other debit.
+func (s *PostgresStore) AuthorizeCard(ctx context.Context, panHash string, auth *CardAuthorization) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "select "+cardColumns+" from card where pan_hash = $1 for share", panHash)
+
+	if err != nil {
+		return err
+	}
+
+	cards, err := scanCards(rows)
+
+	if err != nil {
+		return err
+	}
+
+	if len(cards) == 0 {
+		return notFoundError("card not found")
+	}
+
+	card := cards[0]
+	accounts, err := lockAccounts(ctx, tx, card.AccountNumber)
+
+	if err != nil {
+		return err
+	}
+
+	auth.decide(card, accounts[card.AccountNumber], auth.CreatedAt)
+
+	if auth.Status == CardAuthorizationApproved {
+		if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance + $1 where number = $2", auth.Amount, auth.AccountNumber); err != nil {
+			return err
+		}
+	}
+
+	query := `
+	insert into card_authorization
+	(card_id, account_number, amount, currency, merchant, status, decline_reason, expires_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	returning id`
+
+	err = tx.QueryRowContext(ctx, query, auth.CardID, auth.AccountNumber, auth.Amount, auth.Currency, auth.Merchant, auth.Status, auth.DeclineReason, auth.ExpiresAt, auth.CreatedAt).Scan(&auth.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ExpireCardAuthorizations works like ExpireHolds.
+func (s *PostgresStore) ExpireCardAuthorizations(ctx context.Context, now time.Time, limit int) ([]*CardAuthorization, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	select ` + cardAuthorizationColumns + `
+	from card_authorization
+	where status = $1 and expires_at <= $2
+	order by expires_at
+	limit $3
+	for update skip locked`
+
+	rows, err := tx.QueryContext(ctx, query, CardAuthorizationApproved, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	auths, err := scanCardAuthorizations(rows)
+
+	if err != nil || len(auths) == 0 {
+		return auths, err
+	}
+
+	sort.Slice(auths, func(i, j int) bool {
+		return auths[i].AccountNumber < auths[j].AccountNumber
+	})
+
+	ids := make([]int64, len(auths))
+
+	for i, auth := range auths {
+		if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance - $1 where number = $2", auth.Amount, auth.AccountNumber); err != nil {
+			return nil, err
+		}
+
+		auth.Status = CardAuthorizationExpired
+		ids[i] = int64(auth.ID)
+	}
+
+	if _, err := tx.ExecContext(ctx, "update card_authorization set status = $1 where id = any($2)", CardAuthorizationExpired, ids); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return auths, nil
+}
+
+func scanCards(rows *sql.Rows) ([]*Card, error) {
+	defer rows.Close()
+
+	cards := []*Card{}
+
+	for rows.Next() {
+		card := new(Card)
+
+		err := rows.Scan(&card.ID, &card.AccountNumber, &card.MaskedPAN, &card.PANHash, &card.ExpiryMonth, &card.ExpiryYear, &card.Status, &card.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+func scanCardAuthorizations(rows *sql.Rows) ([]*CardAuthorization, error) {
+	defer rows.Close()
+
+	auths := []*CardAuthorization{}
+
+	for rows.Next() {
+		auth := new(CardAuthorization)
+
+		err := rows.Scan(&auth.ID, &auth.CardID, &auth.AccountNumber, &auth.Amount, &auth.Currency, &auth.Merchant, &auth.Status, &auth.DeclineReason, &auth.ExpiresAt, &auth.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		auths = append(auths, auth)
+	}
+
+	return auths, rows.Err()
+}
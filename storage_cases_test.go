@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The store cases run the API against a database backed store, the same
+// cases for each: SQLite in every test run, Postgres with the integration
+// tag. Each case gets a store of its own, migrated from scratch.
+
+// storeCases are the cases every SQL store passes.
+var storeCases = []struct {
+	name string
+	run  func(*testing.T, *storeAPI)
+}{
+	{"AccountTransferStatement", testStoreAccountTransferStatement},
+	{"AccountMetadata", testStoreAccountMetadata},
+	{"ConcurrentTransfers", testStoreConcurrentTransfers},
+	{"AcceptPaymentRequest", testStoreAcceptPaymentRequest},
+	{"NextClosingBalanceDay", testStoreNextClosingBalanceDay},
+	{"CategoryTotals", testStoreCategoryTotals},
+	{"TimeShapedText", testStoreTimeShapedText},
+	{"SearchAccounts", testStoreSearchAccounts},
+	{"ClosingBalances", testStoreClosingBalances},
+	{"RevokeSessions", testStoreRevokeSessions},
+	{"PendingMigrations", testStorePendingMigrations},
+}
+
+// runStoreCases runs every store case against a store newStore opens for
+// it.
+func runStoreCases(t *testing.T, newStore func(*testing.T) Storage) {
+	for _, c := range storeCases {
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, newStoreAPI(t, newStore(t)))
+		})
+	}
+}
+
+// storeAPI serves the API from the store under test. Requests go through
+// testAPI.do, the store is storage.
+type storeAPI struct {
+	*testAPI
+	storage Storage
+}
+
+func newStoreAPI(t *testing.T, store Storage) *storeAPI {
+	cfg := testConfig()
+	// the concurrency tests send many requests from one account at once
+	cfg.RateLimit = 0
+
+	rates, err := NewStaticRateProvider(defaultExchangeRates)
+	require.Nil(t, err)
+
+	bus := NewEventBus()
+	server := NewAPIServer(cfg, store, rates, publishers{NewWebhookDispatcher(store), bus, NewPotSweeper(store), NewNotificationDispatcher(store, newChannelNotifiers(cfg)), NewAMLMonitor(cfg, store)}, bus, nil)
+	handler, err := server.routes()
+	require.Nil(t, err)
+
+	return &storeAPI{testAPI: &testAPI{t: t, server: server, handler: handler}, storage: store}
+}
+
+// openAccount opens an account through the API, funded with cash an admin
+// books when deposit isn't 0.
+func (a *storeAPI) openAccount(firstName, password string, deposit int64) *Account {
+	rec := a.do("POST", "/account", "", &AccountRequest{FirstName: firstName, LastName: "Test", Password: password, Currency: "EUR", Type: AccountChecking})
+	require.Equal(a.t, http.StatusOK, rec.Code, rec.Body.String())
+
+	acc := new(Account)
+	require.Nil(a.t, json.NewDecoder(rec.Body).Decode(acc))
+
+	if deposit != 0 {
+		rec = a.do("POST", "/admin/account/"+strconv.Itoa(acc.ID)+"/deposit", a.tellerToken(), AmountRequest{Amount: deposit})
+		require.Equal(a.t, http.StatusOK, rec.Code, rec.Body.String())
+		acc.Balance = deposit
+	}
+
+	return acc
+}
+
+// tellerToken logs in an admin to book cash with, created in the store on
+// first use.
+func (a *storeAPI) tellerToken() string {
+	if a.teller == "" {
+		teller, err := NewAccount("Teller", "Test", "teller-pw")
+		require.Nil(a.t, err)
+		teller.Role = RoleAdmin
+		require.Nil(a.t, a.storage.CreateAccount(context.Background(), teller))
+		a.teller = a.login(teller, "teller-pw")
+	}
+
+	return a.teller
+}
+
+// balance reads the booked balance of the id account through the API.
+func (a *storeAPI) balance(id int, token string) int64 {
+	rec := a.do("GET", "/account/"+strconv.Itoa(id), token, nil)
+	require.Equal(a.t, http.StatusOK, rec.Code, rec.Body.String())
+
+	acc := new(Account)
+	require.Nil(a.t, json.NewDecoder(rec.Body).Decode(acc))
+
+	return acc.Balance
+}
+
+// requireLedgerBalanced checks every journal entry balances and every
+// account balance is the sum of its ledger lines.
+func (a *storeAPI) requireLedgerBalanced() {
+	report, err := a.storage.CheckLedgerIntegrity(context.Background())
+	require.Nil(a.t, err)
+	require.True(a.t, report.Balanced, "unbalanced entries %v, mismatched accounts %v", report.UnbalancedEntries, report.MismatchedAccounts)
+}
+
+func testStoreAccountTransferStatement(t *testing.T, api *storeAPI) {
+	alice := api.openAccount("Alice", "alice-pw", 10000)
+	bob := api.openAccount("Bob", "bob-pw", 0)
+	token := api.login(alice, "alice-pw")
+	bobToken := api.login(bob, "bob-pw")
+	alicePath := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", "/admin"+alicePath+"/deposit", api.tellerToken(), AmountRequest{Amount: 2500})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/admin"+alicePath+"/withdraw", api.tellerToken(), AmountRequest{Amount: 500})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 4000, TransferReference: TransferReference{Reference: "rent"}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100000})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeInsufficientFunds))
+
+	assert.Equal(t, int64(8000), api.balance(alice.ID, token))
+	assert.Equal(t, int64(4000), api.balance(bob.ID, bobToken))
+
+	rec = api.do("GET", alicePath+"/transfers", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var transfers []*Transfer
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&transfers))
+	require.Len(t, transfers, 2, "the failed transfer is recorded too")
+
+	rec = api.do("GET", alicePath+"/transfers?status=failed", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&transfers))
+	require.Len(t, transfers, 1)
+	assert.Equal(t, int64(100000), transfers[0].Amount)
+
+	rec = api.do("GET", alicePath+"/statement", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	statement := new(Statement)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(statement))
+	assert.Equal(t, int64(0), statement.OpeningBalance)
+	assert.Equal(t, int64(8000), statement.ClosingBalance)
+	assert.Equal(t, int64(12500), statement.TotalCredits)
+	assert.Equal(t, int64(4500), statement.TotalDebits)
+	assert.Len(t, statement.Transactions, 4)
+
+	rec = api.do("POST", alicePath+"/statement/link", token, StatementLinkRequest{Format: "csv"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	link := new(StatementLink)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(link))
+
+	rec = api.do("GET", link.URL, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), strconv.FormatInt(bob.Number, 10))
+
+	rec = api.do("GET", alicePath+"/transactions/export", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 4, strings.Count(rec.Body.String(), "\n"))
+
+	api.requireLedgerBalanced()
+}
+
+func testStoreAccountMetadata(t *testing.T, api *storeAPI) {
+	alice := api.openAccount("Alice", "alice-pw", 0)
+	api.openAccount("Bob", "bob-pw", 0)
+	token := api.login(alice, "alice-pw")
+	value := func(s string) *string { return &s }
+
+	rec := api.do("PATCH", "/account/"+strconv.Itoa(alice.ID), token, AccountPatchRequest{Metadata: map[string]*string{"crm_id": value("42"), "tier": value("gold")}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("PATCH", "/account/"+strconv.Itoa(alice.ID), token, AccountPatchRequest{Metadata: map[string]*string{"tier": nil}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/account?metadata[crm_id]=42", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var accounts []*Account
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&accounts))
+	require.Len(t, accounts, 1)
+	assert.Equal(t, map[string]string{"crm_id": "42"}, accounts[0].Metadata)
+
+	rec = api.do("GET", "/account?metadata[tier]=gold", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&accounts))
+	assert.Empty(t, accounts)
+}
+
+// testStoreConcurrentTransfers sends transfers both ways between two
+// accounts at once, which deadlocks Postgres unless accounts are always
+// locked in the same order and fails SQLite writers that don't wait for
+// the write lock, and checks no money was made or lost.
+func testStoreConcurrentTransfers(t *testing.T, api *storeAPI) {
+	const transfers = 20
+
+	alice := api.openAccount("Alice", "alice-pw", 1000)
+	bob := api.openAccount("Bob", "bob-pw", 1000)
+	tokens := map[int64]string{alice.Number: api.login(alice, "alice-pw"), bob.Number: api.login(bob, "bob-pw")}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2*transfers)
+
+	for i := range codes {
+		from, to := alice, bob
+
+		if i%2 == 1 {
+			from, to = bob, alice
+		}
+
+		wg.Add(1)
+
+		go func(i int, from, to *Account) {
+			defer wg.Done()
+
+			rec := api.do("POST", "/transfer", tokens[from.Number], TransferRequest{ToAccount: int(to.Number), Amount: 100})
+			codes[i] = rec.Code
+		}(i, from, to)
+	}
+
+	wg.Wait()
+
+	for i, code := range codes {
+		assert.Contains(t, []int{http.StatusOK, http.StatusUnprocessableEntity}, code, "transfer %d", i)
+	}
+
+	assert.Equal(t, int64(2000), api.balance(alice.ID, tokens[alice.Number])+api.balance(bob.ID, tokens[bob.Number]))
+
+	api.requireLedgerBalanced()
+}
+
+func testStoreAcceptPaymentRequest(t *testing.T, api *storeAPI) {
+	alice := api.openAccount("Alice", "alice-pw", 0)
+	bob := api.openAccount("Bob", "bob-pw", 1000)
+	aliceToken, bobToken := api.login(alice, "alice-pw"), api.login(bob, "bob-pw")
+
+	ask := func(amount int64) *PaymentRequest {
+		rec := api.do("POST", "/payment-request", aliceToken, PaymentRequestRequest{FromAccount: bob.Number, Amount: amount})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		request := new(PaymentRequest)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(request))
+
+		return request
+	}
+
+	accept := func(request *PaymentRequest) *httptest.ResponseRecorder {
+		return api.do("POST", "/account/"+strconv.Itoa(bob.ID)+"/payment-requests/"+strconv.Itoa(request.ID)+"/accept", bobToken, nil)
+	}
+
+	tickets := ask(5000)
+	rec := accept(tickets)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	request, err := api.storage.GetPaymentRequest(context.Background(), tickets.ID, time.Now().UTC())
+	require.Nil(t, err)
+	assert.Equal(t, PaymentRequestPending, request.Status, "the failed transfer rolls the acceptance back")
+
+	rec = accept(ask(300))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	accepted := new(PaymentRequest)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(accepted))
+	assert.Equal(t, PaymentRequestAccepted, accepted.Status)
+	require.NotNil(t, accepted.TransferID)
+	assert.Equal(t, int64(300), api.balance(alice.ID, aliceToken))
+
+	api.requireLedgerBalanced()
+}
+
+func testStoreNextClosingBalanceDay(t *testing.T, api *storeAPI) {
+	ctx := context.Background()
+
+	acc := &Account{FirstName: "Alice", LastName: "Test", Number: 100001, Currency: "EUR", Type: AccountChecking, Role: RoleCustomer, CreatedAt: time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)}
+	require.Nil(t, api.storage.CreateAccount(ctx, acc))
+
+	day, err := api.storage.NextClosingBalanceDay(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), day.UTC())
+}
+
+func testStoreCategoryTotals(t *testing.T, api *storeAPI) {
+	alice := api.openAccount("Alice", "alice-pw", 1000)
+	now := time.Now().UTC()
+
+	totals, err := api.storage.GetCategoryTotals(context.Background(), alice.Number, now.AddDate(0, -1, 0), now.Add(time.Minute))
+	require.Nil(t, err)
+	require.Len(t, totals, 1)
+	assert.Equal(t, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), totals[0].Month.UTC())
+	assert.Equal(t, int64(1000), totals[0].Credits)
+}
+
+// testStoreTimeShapedText checks text that looks like a timestamp comes
+// back as the text it was.
+func testStoreTimeShapedText(t *testing.T, api *storeAPI) {
+	alice := api.openAccount("Alice", "alice-pw", 1000)
+	bob := api.openAccount("Bob", "bob-pw", 0)
+	token := api.login(alice, "alice-pw")
+	ref := TransferReference{Reference: "2024-03-05 14:30:00.000000", Memo: "2024-03-05"}
+
+	rec := api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100, TransferReference: ref})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID)+"/transfers", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var transfers []*Transfer
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&transfers))
+	require.Len(t, transfers, 1)
+	assert.Equal(t, ref, transfers[0].TransferReference)
+}
+
+func testStoreSearchAccounts(t *testing.T, api *storeAPI) {
+	ctx := context.Background()
+
+	for i, name := range []string{"Smith", "Jones"} {
+		acc := &Account{FirstName: "Alice", LastName: name, Number: int64(100001 + i), Currency: "EUR", Type: AccountChecking, Role: RoleCustomer, CreatedAt: time.Now().UTC()}
+		require.Nil(t, api.storage.CreateAccount(ctx, acc))
+	}
+
+	accounts, err := api.storage.SearchAccounts(ctx, "Smyth", 10, 0)
+	require.Nil(t, err)
+	require.Len(t, accounts, 1, "a typo is similar enough")
+	assert.Equal(t, "Smith", accounts[0].LastName)
+
+	accounts, err = api.storage.SearchAccounts(ctx, "100002", 10, 0)
+	require.Nil(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "Jones", accounts[0].LastName)
+}
+
+func testStoreClosingBalances(t *testing.T, api *storeAPI) {
+	ctx := context.Background()
+	alice := api.openAccount("Alice", "alice-pw", 1000)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	n, err := api.storage.MaterializeClosingBalances(ctx, today)
+	require.Nil(t, err)
+	assert.Equal(t, 2, n, "alice and the teller")
+
+	n, err = api.storage.MaterializeClosingBalances(ctx, today)
+	require.Nil(t, err)
+	assert.Zero(t, n, "a day is materialized once")
+
+	balances, err := api.storage.GetClosingBalances(ctx, alice.Number, today, today.AddDate(0, 0, 1))
+	require.Nil(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, today.Format(time.DateOnly), balances[0].Date)
+	assert.Equal(t, int64(1000), balances[0].Balance)
+}
+
+func testStoreRevokeSessions(t *testing.T, api *storeAPI) {
+	alice := api.openAccount("Alice", "alice-pw", 0)
+	api.login(alice, "alice-pw")
+	api.login(alice, "alice-pw")
+	now := time.Now().UTC()
+
+	sessions, err := api.storage.RevokeSessions(context.Background(), alice.Number, 0, now, now.Add(time.Hour))
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+
+	for _, session := range sessions {
+		assert.Equal(t, alice.Number, session.AccountNumber)
+		require.NotNil(t, session.RevokedAt)
+	}
+
+	sessions, err = api.storage.RevokeSessions(context.Background(), alice.Number, 0, now, now.Add(time.Hour))
+	require.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func testStorePendingMigrations(t *testing.T, api *storeAPI) {
+	pending, err := api.storage.PendingMigrations(context.Background())
+	require.Nil(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const contactDetailColumns = "account_number, kind, value, code_hash, code_expires_at, attempts, verified_at, updated_at"
+
+func (s *PostgresStore) SetContactDetail(ctx context.Context, detail *ContactDetail) error {
+	query := `
+	insert into contact_detail
+	(account_number, kind, value, code_hash, code_expires_at, updated_at)
+	values
+	($1, $2, $3, $4, $5, $6)
+	on conflict (account_number, kind) do update
+	set value = excluded.value, code_hash = excluded.code_hash, code_expires_at = excluded.code_expires_at,
+		attempts = 0, verified_at = null, updated_at = excluded.updated_at`
+
+	_, err := s.db.ExecContext(ctx, query, detail.AccountNumber, detail.Kind, detail.Value, detail.CodeHash, detail.CodeExpiresAt, detail.UpdatedAt)
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) GetContactDetails(ctx context.Context, number int64) ([]*ContactDetail, error) {
+	return queryContactDetails(ctx, s.db, "where account_number = $1 order by kind", number)
+}
+
+// VerifyContactDetail commits a wrong attempt before returning its error,
+// so the attempts can't be rolled back by guessing.
+func (s *PostgresStore) VerifyContactDetail(ctx context.Context, number int64, kind ContactKind, codeHash string, now time.Time) (*ContactDetail, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	details, err := queryContactDetails(ctx, tx, "where account_number = $1 and kind = $2 for update", number, kind)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(details) == 0 {
+		return nil, notFoundError("no %s set", kind)
+	}
+
+	detail := details[0]
+	attempts := detail.Attempts
+
+	codeErr := detail.checkCode(codeHash, now)
+
+	if detail.Attempts != attempts {
+		if _, err := tx.ExecContext(ctx, "update contact_detail set attempts = $1 where account_number = $2 and kind = $3", detail.Attempts, number, kind); err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	if codeErr != nil {
+		return nil, codeErr
+	}
+
+	detail.VerifiedAt = &now
+
+	if _, err := tx.ExecContext(ctx, "update contact_detail set verified_at = $1 where account_number = $2 and kind = $3", now, number, kind); err != nil {
+		return nil, err
+	}
+
+	return detail, tx.Commit()
+}
+
+func (s *PostgresStore) DeleteContactDetail(ctx context.Context, number int64, kind ContactKind) error {
+	res, err := s.db.ExecContext(ctx, "delete from contact_detail where account_number = $1 and kind = $2", number, kind)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("no %s set", kind)
+	}
+
+	return nil
+}
+
+func queryContactDetails(ctx context.Context, db querier, where string, args ...any) ([]*ContactDetail, error) {
+	rows, err := db.QueryContext(ctx, "select "+contactDetailColumns+" from contact_detail "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	details := []*ContactDetail{}
+
+	for rows.Next() {
+		d := new(ContactDetail)
+
+		if err := rows.Scan(&d.AccountNumber, &d.Kind, &d.Value, &d.CodeHash, &d.CodeExpiresAt, &d.Attempts, &d.VerifiedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		details = append(details, d)
+	}
+
+	return details, rows.Err()
+}
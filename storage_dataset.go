@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// datasetSequences are the serial columns ImportDataset moves past the
+// imported ids.
+var datasetSequences = []string{"account", "journal_entry", "journal_line", "transactions", "transfer"}
+
+func (s *PostgresStore) ExportDataset(ctx context.Context, now time.Time) (*Dataset, error) {
+	// one snapshot for every table so the archive balances
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	if err := withoutStatementTimeout(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	d := &Dataset{
+		Version:        datasetVersion,
+		ExportedAt:     now,
+		Accounts:       []*ArchivedAccount{},
+		JournalEntries: []*JournalEntry{},
+		Transactions:   []*Transaction{},
+		Transfers:      []*Transfer{},
+	}
+
+	rows, err := tx.QueryContext(ctx, "select "+accountColumns+" from account order by id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		acc, err := scanIntoAccount(rows)
+
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		d.Accounts = append(d.Accounts, NewArchivedAccount(acc))
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, "select account_number, version, type, data, created_at from account_event order by id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.AccountEvents, err = scanAccountEvents(rows); err != nil {
+		return nil, err
+	}
+
+	if err := exportJournal(ctx, tx, d); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, "select "+transactionColumns+" from transactions order by id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		d.Transactions = append(d.Transactions, transaction)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	d.Transfers, err = queryTransfers(ctx, tx, "order by id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func exportJournal(ctx context.Context, tx *sql.Tx, d *Dataset) error {
+	rows, err := tx.QueryContext(ctx, "select id, kind, created_at from journal_entry order by id")
+
+	if err != nil {
+		return err
+	}
+
+	entries := map[int]*JournalEntry{}
+
+	for rows.Next() {
+		entry := &JournalEntry{Lines: []*JournalLine{}}
+
+		if err := rows.Scan(&entry.ID, &entry.Kind, &entry.CreatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+
+		entries[entry.ID] = entry
+		d.JournalEntries = append(d.JournalEntries, entry)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.QueryContext(ctx, "select id, entry_id, ledger, account_number, currency, amount from journal_line order by id")
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		line := new(JournalLine)
+
+		if err := rows.Scan(&line.ID, &line.EntryID, &line.Ledger, &line.AccountNumber, &line.Currency, &line.Amount); err != nil {
+			return err
+		}
+
+		entry := entries[line.EntryID]
+		entry.Lines = append(entry.Lines, line)
+	}
+
+	return rows.Err()
+}
+
+func (s *PostgresStore) ImportDataset(ctx context.Context, d *Dataset) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := withoutStatementTimeout(ctx, tx); err != nil {
+		return err
+	}
+
+	// keeps concurrent imports, money movements and accounts opened
+	// meanwhile out
+	if _, err := tx.ExecContext(ctx, "lock table account, journal_entry, transfer in exclusive mode"); err != nil {
+		return err
+	}
+
+	var used bool
+
+	if err := tx.QueryRowContext(ctx, "select exists (select 1 from journal_entry) or exists (select 1 from transfer)").Scan(&used); err != nil {
+		return err
+	}
+
+	if used {
+		return conflictError("the store already has journal entries, import into a fresh instance")
+	}
+
+	ids, numbers := d.accountKeys()
+
+	if err := tx.QueryRowContext(ctx, "select exists (select 1 from account where id = any($1) or number = any($2))", ids, numbers).Scan(&used); err != nil {
+		return err
+	}
+
+	if used {
+		return conflictError("the store has accounts with the ids or numbers of archived ones")
+	}
+
+	query := `
+	insert into account
+	(` + accountColumns + `)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)`
+
+	for _, a := range d.Accounts {
+		metadata, err := json.Marshal(a.Metadata)
+
+		if err != nil {
+			return err
+		}
+
+		// archives made before accounts had metadata have none
+		if a.Metadata == nil {
+			metadata = []byte("{}")
+		}
+
+		_, err = tx.ExecContext(ctx, query, a.ID, a.FirstName, a.LastName, a.Number, a.Account.EncryptedPassword, a.Balance, a.Currency, a.Role, a.CreatedAt, a.OverdraftLimit, a.MinimumBalance, a.OverdraftFee, a.Type, a.AccruedInterest, a.Account.InterestRemainder, a.Account.InterestAccruedThrough, a.Status, a.HeldBalance, a.DeletedAt, a.KYCStatus, a.DualApprovalAmount, a.PotBalance, a.Version, metadata)
+
+		if err != nil {
+			return pgError(err)
+		}
+	}
+
+	for _, e := range d.AccountEvents {
+		if err := appendAccountEvent(ctx, tx, e); err != nil {
+			return pgError(err)
+		}
+	}
+
+	for _, entry := range d.JournalEntries {
+		if _, err := tx.ExecContext(ctx, "insert into journal_entry (id, kind, created_at) values ($1, $2, $3)", entry.ID, entry.Kind, entry.CreatedAt); err != nil {
+			return pgError(err)
+		}
+
+		for _, line := range entry.Lines {
+			query := `
+			insert into journal_line
+			(id, entry_id, ledger, account_number, currency, amount)
+			values
+			($1, $2, $3, $4, $5, $6)`
+
+			if _, err := tx.ExecContext(ctx, query, line.ID, entry.ID, line.Ledger, line.AccountNumber, line.Currency, line.Amount); err != nil {
+				return pgError(err)
+			}
+		}
+	}
+
+	query = `
+	insert into transactions
+	(` + transactionColumns + `)
+	values
+	($1, $2, $3, $4, $5, $6, $7, nullif($8, ''), nullif($9, ''), $10, $11, $12, $13)`
+
+	for _, t := range d.Transactions {
+		if _, err := tx.ExecContext(ctx, query, t.ID, t.JournalID, t.AccountNumber, t.Type, t.Amount, t.Balance, t.Counterparty, t.Category, t.Description, t.Reference, t.Memo, t.EndToEndID, t.CreatedAt); err != nil {
+			return pgError(err)
+		}
+	}
+
+	// archives from before transfers had a status only hold completed ones
+	query = `
+	insert into transfer
+	(id, from_account, to_account, amount, currency, to_amount, to_currency, rate, status, failure_reason, created_at, reversed_at, reference, memo, end_to_end_id)
+	values
+	($1, $2, $3, $4, $5, $6, $7, nullif($8, ''), coalesce(nullif($9, ''), 'completed'), $10, $11, $12, $13, $14, $15)`
+
+	for _, t := range d.Transfers {
+		if _, err := tx.ExecContext(ctx, query, t.ID, t.FromAccount, t.ToAccount, t.Amount, t.Currency, t.ToAmount, t.ToCurrency, t.Rate, t.Status, t.FailureReason, t.CreatedAt, t.ReversedAt, t.Reference, t.Memo, t.EndToEndID); err != nil {
+			return pgError(err)
+		}
+	}
+
+	for _, table := range datasetSequences {
+		if _, err := tx.ExecContext(ctx, "select setval(pg_get_serial_sequence('"+table+"', 'id'), coalesce(max(id), 0) + 1, false) from "+table); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
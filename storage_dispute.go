@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const disputeColumns = "id, account_number, transaction_id, amount, reason, status, reversed_amount, reversal_journal_id, decided_by, note, created_at, decided_at"
+
+// CreateDispute locks the account so two disputes of the same transaction
+// can't both be opened.
+func (s *PostgresStore) CreateDispute(ctx context.Context, dispute *Dispute) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := lockAccounts(ctx, tx, dispute.AccountNumber); err != nil {
+		return err
+	}
+
+	transaction, err := getTransaction(ctx, tx, dispute.AccountNumber, dispute.TransactionID)
+
+	if err != nil {
+		return err
+	}
+
+	previous, err := queryDisputes(ctx, tx, "where transaction_id = $1 order by id", dispute.TransactionID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := dispute.open(transaction, previous); err != nil {
+		return err
+	}
+
+	query := `
+	insert into dispute
+	(account_number, transaction_id, amount, reason, status, created_at)
+	values
+	($1, $2, $3, $4, $5, $6)
+	returning id`
+
+	if err := tx.QueryRowContext(ctx, query, dispute.AccountNumber, dispute.TransactionID, dispute.Amount, dispute.Reason, dispute.Status, dispute.CreatedAt).Scan(&dispute.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) GetDisputes(ctx context.Context, number int64, status DisputeStatus, limit, offset int) ([]*Dispute, error) {
+	return queryDisputes(ctx, s.db, "where ($1::bigint = 0 or account_number = $1) and ($2 = '' or status = $2) order by id limit $3 offset $4", number, status, limit, offset)
+}
+
+func (s *PostgresStore) DecideDispute(ctx context.Context, id int, decision *DisputeDecision) (*Dispute, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	disputes, err := queryDisputes(ctx, tx, "where id = $1 for update", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(disputes) == 0 {
+		return nil, notFoundError("dispute %d not found", id)
+	}
+
+	dispute := disputes[0]
+	amount, err := dispute.reversal(decision)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var journalID *int
+
+	if decision.Status == DisputeReversed {
+		entry, err := reverseTransaction(ctx, tx, dispute, amount, decision.DecidedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		journalID = &entry.ID
+	}
+
+	dispute.decide(decision, amount, journalID)
+
+	query := `
+	update dispute
+	set status = $1, reversed_amount = $2, reversal_journal_id = $3, decided_by = $4, note = $5, decided_at = $6
+	where id = $7`
+
+	if _, err := tx.ExecContext(ctx, query, dispute.Status, dispute.ReversedAmount, dispute.ReversalJournalID, dispute.DecidedBy, dispute.Note, dispute.DecidedAt, id); err != nil {
+		return nil, err
+	}
+
+	return dispute, tx.Commit()
+}
+
+// reverseTransaction posts the entry giving amount of the disputed
+// transaction back, leaving the disputed entry untouched.
+func reverseTransaction(ctx context.Context, tx *sql.Tx, dispute *Dispute, amount int64, at time.Time) (*JournalEntry, error) {
+	number := dispute.AccountNumber
+
+	original, err := getTransaction(ctx, tx, number, dispute.TransactionID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := []int64{number}
+
+	if original.Type == TransactionTransferOut {
+		numbers = append(numbers, *original.Counterparty)
+	}
+
+	accounts, err := lockAccounts(ctx, tx, numbers...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[number]
+	entry, err := beginJournalEntry(ctx, tx, JournalReversal, at)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := applyTransaction(ctx, tx, entry, acc, TransactionReversal, amount, original.Counterparty); err != nil {
+		return nil, err
+	}
+
+	switch original.Type {
+	case TransactionWithdrawal:
+		entry.post(LedgerCash, nil, acc.Currency, -amount)
+	case TransactionFee:
+		entry.post(LedgerFees, nil, acc.Currency, -amount)
+	case TransactionTransferOut:
+		to := accounts[*original.Counterparty]
+
+		var credited int64
+
+		query := "select amount from transactions where journal_id = $1 and account_number = $2 and type = $3"
+
+		if err := tx.QueryRowContext(ctx, query, original.JournalID, to.Number, TransactionTransferIn).Scan(&credited); err != nil {
+			return nil, err
+		}
+
+		share := counterpartyShare(amount, -original.Amount, credited)
+
+		if _, err := applyTransaction(ctx, tx, entry, to, TransactionReversal, -share, &number); err != nil {
+			return nil, err
+		}
+
+		if acc.Currency != to.Currency {
+			entry.post(LedgerFX, nil, acc.Currency, -amount)
+			entry.post(LedgerFX, nil, to.Currency, share)
+		}
+	}
+
+	return entry, commitJournalEntry(ctx, tx, entry)
+}
+
+func getTransaction(ctx context.Context, tx *sql.Tx, number int64, id int) (*Transaction, error) {
+	rows, err := tx.QueryContext(ctx, "select "+transactionColumns+" from transactions where id = $1 and account_number = $2", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, notFoundError("transaction %d not found", id)
+	}
+
+	return scanIntoTransaction(rows)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryDisputes(ctx context.Context, db querier, where string, args ...any) ([]*Dispute, error) {
+	rows, err := db.QueryContext(ctx, "select "+disputeColumns+" from dispute "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	disputes := []*Dispute{}
+
+	for rows.Next() {
+		dispute := new(Dispute)
+
+		err := rows.Scan(&dispute.ID, &dispute.AccountNumber, &dispute.TransactionID, &dispute.Amount, &dispute.Reason, &dispute.Status, &dispute.ReversedAmount, &dispute.ReversalJournalID, &dispute.DecidedBy, &dispute.Note, &dispute.CreatedAt, &dispute.DecidedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		disputes = append(disputes, dispute)
+	}
+
+	return disputes, rows.Err()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+
+	pgNumericValueOutOfRange = "22003"
+	pgQueryCanceled          = "57014"
+)
+
+// balanceConstraints are the check constraints that keep balances from
+// going negative behind the application's back.
+var balanceConstraints = map[string]bool{
+	"pot_balance_nonnegative":         true,
+	"account_pot_balance_nonnegative": true,
+}
+
+// pgError translates the driver errors that have a domain meaning into the
+// domain errors, so they reach clients as the right status code instead of
+// a SQL error string. Other errors are returned as they are.
+func pgError(err error) error {
+	var pgErr *pgconn.PgError
+
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch {
+	case pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == "account_number_key":
+		return errAccountNumberTaken
+	case pgErr.Code == pgForeignKeyViolation && isAccountReference(pgErr.ConstraintName):
+		return accountNotFoundError("account not found")
+	case pgErr.Code == pgCheckViolation && balanceConstraints[pgErr.ConstraintName]:
+		return insufficientFundsError()
+	case pgErr.Code == pgNumericValueOutOfRange:
+		return validationError("amount out of range")
+	}
+
+	return err
+}
+
+// isStatementTimeout tells whether Postgres cancelled the statement for
+// running past statement_timeout. A cancelled context surfaces as itself.
+func isStatementTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgQueryCanceled
+}
+
+// isAccountReference tells whether constraint is the foreign key of a
+// column referencing account numbers, named as Postgres names them by
+// default.
+func isAccountReference(constraint string) bool {
+	return strings.HasSuffix(constraint, "_account_number_fkey") ||
+		strings.HasSuffix(constraint, "_owner_number_fkey") ||
+		strings.HasSuffix(constraint, "_from_account_fkey")
+}
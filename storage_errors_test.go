@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPgError(t *testing.T) {
+	err := pgError(fmt.Errorf("insert: %w", &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "account_number_key"}))
+	assert.ErrorIs(t, err, ErrDuplicateAccountNumber)
+
+	err = pgError(&pgconn.PgError{Code: pgForeignKeyViolation, ConstraintName: "card_account_number_fkey"})
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status)
+	assert.NotContains(t, err.Error(), "fkey")
+
+	err = pgError(&pgconn.PgError{Code: pgCheckViolation, ConstraintName: "pot_balance_nonnegative"})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	other := &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "pot_account_number_name_key"}
+	assert.Equal(t, error(other), pgError(other), "errors without a domain meaning are left alone")
+
+	plain := errors.New("connection refused")
+	assert.Equal(t, plain, pgError(plain))
+	assert.Nil(t, pgError(nil))
+}
+
+func TestStatementTimeoutError(t *testing.T) {
+	httpErr, ok := asHTTPError(fmt.Errorf("select: %w", &pgconn.PgError{Code: pgQueryCanceled, Message: "canceling statement due to statement timeout"}))
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusGatewayTimeout, httpErr.Status)
+	assert.Equal(t, ErrorCodeTimeout, httpErr.Code)
+}
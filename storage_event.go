@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// accountEventData is the payload stored as JSON with each event.
+type accountEventData struct {
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency,omitempty"`
+	Counterparty *int64 `json:"counterparty,omitempty"`
+}
+
+func appendAccountEvent(ctx context.Context, tx *sql.Tx, e *AccountEvent) error {
+	data, err := json.Marshal(accountEventData{Amount: e.Amount, Currency: e.Currency, Counterparty: e.Counterparty})
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+	insert into account_event
+	(account_number, version, type, data, created_at)
+	values
+	($1, $2, $3, $4, $5)`
+
+	_, err = tx.ExecContext(ctx, query, e.AccountNumber, e.Version, e.Type, data, e.CreatedAt)
+
+	return err
+}
+
+func (s *PostgresStore) GetAccountEvents(ctx context.Context, number int64, filter AccountEventFilter) ([]*AccountEvent, error) {
+	query := `
+	select account_number, version, type, data, created_at
+	from account_event
+	where account_number = $1 and version > $2`
+	args := []any{number, filter.AfterVersion}
+
+	if !filter.Until.IsZero() {
+		args = append(args, filter.Until)
+		query += fmt.Sprintf(" and created_at < $%d", len(args))
+	}
+
+	query += " order by version"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" limit $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAccountEvents(rows)
+}
+
+func scanAccountEvents(rows *sql.Rows) ([]*AccountEvent, error) {
+	defer rows.Close()
+
+	events := []*AccountEvent{}
+
+	for rows.Next() {
+		e := new(AccountEvent)
+		var data []byte
+
+		if err := rows.Scan(&e.AccountNumber, &e.Version, &e.Type, &data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		var payload accountEventData
+
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return nil, err
+		}
+
+		e.Amount, e.Currency, e.Counterparty = payload.Amount, payload.Currency, payload.Counterparty
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
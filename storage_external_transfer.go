@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+const externalTransferColumns = "id, account_number, scheme, beneficiary_name, destination, amount, currency, reference, status, return_code, return_reason, journal_id, return_journal_id, created_at, submitted_at, settled_at, returned_at"
+
+func (s *PostgresStore) CreateExternalTransfer(ctx context.Context, transfer *ExternalTransfer) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	accounts, err := lockAccounts(ctx, tx, transfer.AccountNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkExternalTransfer(accounts[transfer.AccountNumber], transfer); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance + $1 where number = $2", transfer.Amount, transfer.AccountNumber); err != nil {
+		return err
+	}
+
+	query := `
+	insert into external_transfer
+	(account_number, scheme, beneficiary_name, destination, amount, currency, reference, status, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	returning id`
+
+	err = tx.QueryRowContext(ctx, query, transfer.AccountNumber, transfer.Scheme, transfer.BeneficiaryName, transfer.Destination, transfer.Amount, transfer.Currency, transfer.Reference, transfer.Status, transfer.CreatedAt).Scan(&transfer.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) GetExternalTransfers(ctx context.Context, number int64, limit, offset int) ([]*ExternalTransfer, error) {
+	return queryExternalTransfers(ctx, s.db, "where account_number = $1 order by id desc limit $2 offset $3", number, limit, offset)
+}
+
+func (s *PostgresStore) SubmitExternalTransfers(ctx context.Context, now time.Time, limit int) ([]*ExternalTransfer, error) {
+	query := `
+	update external_transfer
+	set status = $1, submitted_at = $2
+	where id in (
+		select id from external_transfer
+		where status = $3
+		order by created_at
+		limit $4
+		for update skip locked
+	)
+	returning ` + externalTransferColumns
+
+	rows, err := s.db.QueryContext(ctx, query, ExternalTransferSubmitted, now, ExternalTransferPending, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanExternalTransfers(rows)
+}
+
+// SettleExternalTransfers skips transfers locked by a concurrent worker or
+// return, and books them in account order like ExpireHolds.
+func (s *PostgresStore) SettleExternalTransfers(ctx context.Context, submittedBefore, now time.Time, limit int) ([]*ExternalTransfer, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	transfers, err := queryExternalTransfers(ctx, tx, "where status = $1 and submitted_at <= $2 order by submitted_at limit $3 for update skip locked", ExternalTransferSubmitted, submittedBefore, limit)
+
+	if err != nil || len(transfers) == 0 {
+		return transfers, err
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].AccountNumber < transfers[j].AccountNumber
+	})
+
+	numbers := make([]int64, len(transfers))
+
+	for i, transfer := range transfers {
+		numbers[i] = transfer.AccountNumber
+	}
+
+	accounts, err := lockAccounts(ctx, tx, numbers...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transfer := range transfers {
+		acc := accounts[transfer.AccountNumber]
+
+		if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance - $1 where number = $2", transfer.Amount, acc.Number); err != nil {
+			return nil, err
+		}
+
+		entry, err := beginJournalEntry(ctx, tx, JournalExternalTransfer, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := applyTransaction(ctx, tx, entry, acc, TransactionExternalOut, -transfer.Amount, nil); err != nil {
+			return nil, err
+		}
+
+		entry.post(LedgerClearing, nil, transfer.Currency, transfer.Amount)
+
+		if err := commitJournalEntry(ctx, tx, entry); err != nil {
+			return nil, err
+		}
+
+		transfer.settle(entry.ID, now)
+
+		if _, err := tx.ExecContext(ctx, "update external_transfer set status = $1, journal_id = $2, settled_at = $3 where id = $4", transfer.Status, transfer.JournalID, now, transfer.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+// ReturnExternalTransfer locks the transfer before the account, like
+// SettleExternalTransfers, so a transfer is returned either before or after
+// it settles, never while.
+func (s *PostgresStore) ReturnExternalTransfer(ctx context.Context, id int, code, reason string, now time.Time) (*ExternalTransfer, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	transfers, err := queryExternalTransfers(ctx, tx, "where id = $1 for update", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transfers) == 0 {
+		return nil, notFoundError("external transfer %d not found", id)
+	}
+
+	transfer := transfers[0]
+
+	if err := transfer.CheckReturn(); err != nil {
+		return nil, err
+	}
+
+	accounts, err := lockAccounts(ctx, tx, transfer.AccountNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var journalID *int
+
+	if transfer.Status == ExternalTransferSettled {
+		entry, err := beginJournalEntry(ctx, tx, JournalExternalReturn, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := applyTransaction(ctx, tx, entry, accounts[transfer.AccountNumber], TransactionExternalReturn, transfer.Amount, nil); err != nil {
+			return nil, err
+		}
+
+		entry.post(LedgerClearing, nil, transfer.Currency, -transfer.Amount)
+
+		if err := commitJournalEntry(ctx, tx, entry); err != nil {
+			return nil, err
+		}
+
+		journalID = &entry.ID
+	} else if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance - $1 where number = $2", transfer.Amount, transfer.AccountNumber); err != nil {
+		return nil, err
+	}
+
+	transfer.markReturned(code, reason, journalID, now)
+
+	query := `
+	update external_transfer
+	set status = $1, return_code = $2, return_reason = $3, return_journal_id = $4, returned_at = $5
+	where id = $6`
+
+	if _, err := tx.ExecContext(ctx, query, transfer.Status, transfer.ReturnCode, transfer.ReturnReason, transfer.ReturnJournalID, transfer.ReturnedAt, id); err != nil {
+		return nil, err
+	}
+
+	return transfer, tx.Commit()
+}
+
+func queryExternalTransfers(ctx context.Context, db querier, where string, args ...any) ([]*ExternalTransfer, error) {
+	rows, err := db.QueryContext(ctx, "select "+externalTransferColumns+" from external_transfer "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanExternalTransfers(rows)
+}
+
+func scanExternalTransfers(rows *sql.Rows) ([]*ExternalTransfer, error) {
+	defer rows.Close()
+
+	transfers := []*ExternalTransfer{}
+
+	for rows.Next() {
+		t := new(ExternalTransfer)
+
+		err := rows.Scan(&t.ID, &t.AccountNumber, &t.Scheme, &t.BeneficiaryName, &t.Destination, &t.Amount, &t.Currency, &t.Reference, &t.Status, &t.ReturnCode, &t.ReturnReason, &t.JournalID, &t.ReturnJournalID, &t.CreatedAt, &t.SubmittedAt, &t.SettledAt, &t.ReturnedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const fraudReviewColumns = "id, from_account, to_account, amount, currency, ip, rules, action, transfer_id, status, reviewed_by, created_at, reviewed_at"
+
+func (s *PostgresStore) CreateFraudReview(ctx context.Context, review *FraudReview) error {
+	query := `
+	insert into fraud_review
+	(from_account, to_account, amount, currency, ip, rules, action, transfer_id, status, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, review.FromAccount, review.ToAccount, review.Amount, review.Currency, review.IP, strings.Join(review.Rules, ","), review.Action, review.TransferID, review.Status, review.CreatedAt).Scan(&review.ID)
+}
+
+func (s *PostgresStore) GetFraudReviews(ctx context.Context, status FraudReviewStatus, limit, offset int) ([]*FraudReview, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+fraudReviewColumns+" from fraud_review where ($1 = '' or status = $1) order by id limit $2 offset $3", status, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reviews := []*FraudReview{}
+
+	for rows.Next() {
+		review, err := scanIntoFraudReview(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// DecideFraudReview locks the review so two reviewers can't both decide it.
+func (s *PostgresStore) DecideFraudReview(ctx context.Context, id int, status FraudReviewStatus, reviewedBy int64, reviewedAt time.Time) (*FraudReview, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "select "+fraudReviewColumns+" from fraud_review where id = $1 for update", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, notFoundError("fraud review %d not found", id)
+	}
+
+	review, err := scanIntoFraudReview(rows)
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := review.CheckDecision(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update fraud_review set status = $1, reviewed_by = $2, reviewed_at = $3 where id = $4", status, reviewedBy, reviewedAt, id); err != nil {
+		return nil, err
+	}
+
+	review.Status = status
+	review.ReviewedBy = &reviewedBy
+	review.ReviewedAt = &reviewedAt
+
+	return review, tx.Commit()
+}
+
+func (s *PostgresStore) RecordLoginNetwork(ctx context.Context, number int64, network string, at time.Time) error {
+	query := `
+	insert into login_network
+	(account_number, network, first_seen_at, last_seen_at)
+	values
+	($1, $2, $3, $3)
+	on conflict (account_number, network) do update set last_seen_at = excluded.last_seen_at`
+
+	_, err := s.db.ExecContext(ctx, query, number, network, at)
+
+	return err
+}
+
+func (s *PostgresStore) GetLoginNetworks(ctx context.Context, number int64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "select network from login_network where account_number = $1 order by first_seen_at", number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	networks := []string{}
+
+	for rows.Next() {
+		var network string
+
+		if err := rows.Scan(&network); err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, rows.Err()
+}
+
+func (s *PostgresStore) CountTransfersFrom(ctx context.Context, number int64, since time.Time) (int, error) {
+	var count int
+
+	err := s.db.QueryRowContext(ctx, "select count(*) from transfer where from_account = $1 and created_at >= $2 and status in ($3, $4)", number, since, TransferCompleted, TransferReversed).Scan(&count)
+
+	return count, err
+}
+
+func (s *PostgresStore) HasTransferredTo(ctx context.Context, from, to int64) (bool, error) {
+	var paid bool
+
+	err := s.db.QueryRowContext(ctx, "select exists (select 1 from transfer where from_account = $1 and to_account = $2 and status in ($3, $4))", from, to, TransferCompleted, TransferReversed).Scan(&paid)
+
+	return paid, err
+}
+
+func scanIntoFraudReview(rows *sql.Rows) (*FraudReview, error) {
+	review := new(FraudReview)
+
+	var rules string
+
+	err := rows.Scan(&review.ID, &review.FromAccount, &review.ToAccount, &review.Amount, &review.Currency, &review.IP, &rules, &review.Action, &review.TransferID, &review.Status, &review.ReviewedBy, &review.CreatedAt, &review.ReviewedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	review.Rules = strings.Split(rules, ",")
+
+	return review, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+const holdColumns = "id, from_account, to_account, amount, currency, to_amount, to_currency, rate, status, transfer_id, expires_at, created_at"
+
+// AuthorizeTransfer checks the transfer as Transfer would and reserves the
+// amount on the source account instead of moving it.
+func (s *PostgresStore) AuthorizeTransfer(ctx context.Context, hold *Hold) error {
+	from, to := hold.FromAccount, hold.ToAccount
+
+	if hold.Amount <= 0 || hold.ToAmount <= 0 {
+		return validationError("invalid amount %d", hold.Amount)
+	}
+
+	if from == to {
+		return validationError("cannot transfer to the same account")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	accounts, err := lockAccounts(ctx, tx, from, to)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkHold(accounts, hold); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance + $1 where number = $2", hold.Amount, from); err != nil {
+		return err
+	}
+
+	query := `
+	insert into hold
+	(from_account, to_account, amount, currency, to_amount, to_currency, rate, status, expires_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	returning id`
+
+	err = tx.QueryRowContext(ctx, query, from, to, hold.Amount, hold.Currency, hold.ToAmount, hold.ToCurrency, hold.Rate, hold.Status, hold.ExpiresAt, hold.CreatedAt).Scan(&hold.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkHold validates a new hold against the locked accounts it moves money
+// between.
+func checkHold(accounts map[int64]*Account, hold *Hold) error {
+	fromAcc, toAcc := accounts[hold.FromAccount], accounts[hold.ToAccount]
+
+	if fromAcc.Currency != hold.Currency || toAcc.Currency != hold.ToCurrency {
+		return conflictError("transfer currency does not match account currency")
+	}
+
+	if err := fromAcc.CheckActive(); err != nil {
+		return err
+	}
+
+	if err := toAcc.CheckActive(); err != nil {
+		return err
+	}
+
+	if !fromAcc.CanDebit(hold.Amount) {
+		return insufficientFundsError()
+	}
+
+	return nil
+}
+
+// CaptureHold locks the hold before the accounts, like ExpireHolds, so a
+// hold is either captured or expired, never both.
+func (s *PostgresStore) CaptureHold(ctx context.Context, id int, fromAccount int64, now time.Time) (*Transfer, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	rows, err := tx.QueryContext(ctx, "select "+holdColumns+" from hold where id = $1 and from_account = $2 for update", id, fromAccount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	holds, err := scanHolds(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(holds) == 0 {
+		return nil, notFoundError("hold %d not found", id)
+	}
+
+	hold := holds[0]
+
+	if err := hold.CheckCapture(now); err != nil {
+		return nil, err
+	}
+
+	accounts, err := lockAccounts(ctx, tx, hold.FromAccount, hold.ToAccount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance - $1 where number = $2", hold.Amount, hold.FromAccount); err != nil {
+		return nil, err
+	}
+
+	accounts[hold.FromAccount].HeldBalance -= hold.Amount
+
+	transfer := hold.Transfer()
+
+	if err := transferLocked(ctx, tx, accounts, transfer); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update hold set status = $1, transfer_id = $2 where id = $3", HoldCaptured, transfer.ID, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// ExpireHolds skips holds locked by a concurrent capture or reaper, and
+// releases the amounts in account order so it cannot deadlock with transfers.
+func (s *PostgresStore) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]*Hold, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	select ` + holdColumns + `
+	from hold
+	where status = $1 and expires_at <= $2
+	order by expires_at
+	limit $3
+	for update skip locked`
+
+	rows, err := tx.QueryContext(ctx, query, HoldAuthorized, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	holds, err := scanHolds(rows)
+
+	if err != nil || len(holds) == 0 {
+		return holds, err
+	}
+
+	sort.Slice(holds, func(i, j int) bool {
+		return holds[i].FromAccount < holds[j].FromAccount
+	})
+
+	ids := make([]int64, len(holds))
+
+	for i, hold := range holds {
+		if _, err := tx.ExecContext(ctx, "update account set held_balance = held_balance - $1 where number = $2", hold.Amount, hold.FromAccount); err != nil {
+			return nil, err
+		}
+
+		hold.Status = HoldExpired
+		ids[i] = int64(hold.ID)
+	}
+
+	if _, err := tx.ExecContext(ctx, "update hold set status = $1 where id = any($2)", HoldExpired, ids); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+func scanHolds(rows *sql.Rows) ([]*Hold, error) {
+	defer rows.Close()
+
+	holds := []*Hold{}
+
+	for rows.Next() {
+		hold := new(Hold)
+
+		err := rows.Scan(&hold.ID, &hold.FromAccount, &hold.ToAccount, &hold.Amount, &hold.Currency, &hold.ToAmount, &hold.ToCurrency, &hold.Rate, &hold.Status, &hold.TransferID, &hold.ExpiresAt, &hold.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		holds = append(holds, hold)
+	}
+
+	return holds, rows.Err()
+}
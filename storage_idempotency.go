@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (s *PostgresStore) ReserveIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, bool, error) {
+	query := `
+	insert into idempotency_key
+	(key, scope, request_hash, status, created_at)
+	values
+	($1, $2, $3, $4, $5)
+	on conflict (key, scope) do nothing`
+
+	res, err := s.db.ExecContext(ctx, query, rec.Key, rec.Scope, rec.RequestHash, IdempotencyPending, rec.CreatedAt)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if n == 1 {
+		return rec, true, nil
+	}
+
+	stored := new(IdempotencyRecord)
+	var statusCode sql.NullInt64
+
+	query = `
+	select key, scope, request_hash, status, status_code, response_body, created_at
+	from idempotency_key
+	where key = $1 and scope = $2`
+
+	err = s.db.QueryRowContext(ctx, query, rec.Key, rec.Scope).Scan(&stored.Key, &stored.Scope, &stored.RequestHash, &stored.Status, &statusCode, &stored.ResponseBody, &stored.CreatedAt)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	stored.StatusCode = int(statusCode.Int64)
+
+	return stored, false, nil
+}
+
+func (s *PostgresStore) CompleteIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) error {
+	query := `
+	update idempotency_key
+	set status = $1, status_code = $2, response_body = $3
+	where key = $4 and scope = $5`
+
+	_, err := s.db.ExecContext(ctx, query, IdempotencyCompleted, rec.StatusCode, rec.ResponseBody, rec.Key, rec.Scope)
+
+	return err
+}
+
+func (s *PostgresStore) ReleaseIdempotencyKey(ctx context.Context, key, scope string) error {
+	_, err := s.db.ExecContext(ctx, "delete from idempotency_key where key = $1 and scope = $2 and status = $3", key, scope, IdempotencyPending)
+
+	return err
+}
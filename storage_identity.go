@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *PostgresStore) LinkIdentity(ctx context.Context, identity *ExternalIdentity) error {
+	query := `
+	insert into external_identity
+	(issuer, subject, account_number, created_at)
+	values
+	($1, $2, $3, $4)`
+
+	_, err := s.db.ExecContext(ctx, query, identity.Issuer, identity.Subject, identity.AccountNumber, identity.CreatedAt)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return conflictError("identity already linked")
+	}
+
+	return err
+}
+
+func (s *PostgresStore) GetIdentity(ctx context.Context, issuer, subject string) (*ExternalIdentity, error) {
+	identity := new(ExternalIdentity)
+
+	err := s.db.QueryRowContext(ctx, "select issuer, subject, account_number, created_at from external_identity where issuer = $1 and subject = $2", issuer, subject).
+		Scan(&identity.Issuer, &identity.Subject, &identity.AccountNumber, &identity.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, notFoundError("identity not found")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func (s *PostgresStore) ImportTransactions(ctx context.Context, number int64, rows []*ImportRow, dryRun bool, now time.Time) (*ImportResult, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[number]
+	fingerprints := make([]string, len(rows))
+
+	for i, row := range rows {
+		fingerprints[i] = row.fingerprint
+	}
+
+	imported := map[string]int{}
+
+	found, err := tx.QueryContext(ctx, "select fingerprint, transaction_id from imported_transaction where account_number = $1 and fingerprint = any($2)", number, fingerprints)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer found.Close()
+
+	for found.Next() {
+		var fingerprint string
+		var id int
+
+		if err := found.Scan(&fingerprint, &id); err != nil {
+			return nil, err
+		}
+
+		imported[fingerprint] = id
+	}
+
+	if err := found.Err(); err != nil {
+		return nil, err
+	}
+
+	from, to := importPeriod(rows)
+
+	query := `
+	select ` + transactionColumns + `
+	from transactions
+	where account_number = $1 and type <> $2 and created_at >= $3 and created_at < $4
+	order by id`
+
+	ledgerRows, err := tx.QueryContext(ctx, query, number, TransactionImport, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ledgerRows.Close()
+
+	ledger := []*Transaction{}
+
+	for ledgerRows.Next() {
+		transaction, err := scanIntoTransaction(ledgerRows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ledger = append(ledger, transaction)
+	}
+
+	if err := ledgerRows.Err(); err != nil {
+		return nil, err
+	}
+
+	markDuplicates(rows, imported, ledger)
+
+	if err := checkImport(acc, rows); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return NewImportResult(rows, dryRun), nil
+	}
+
+	for _, row := range rows {
+		if row.Status != ImportRowNew {
+			continue
+		}
+
+		entry, err := beginJournalEntry(ctx, tx, JournalImport, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transaction, err := applyTransaction(ctx, tx, entry, acc, TransactionImport, row.Amount, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry.post(LedgerImport, nil, acc.Currency, -row.Amount)
+
+		if err := commitJournalEntry(ctx, tx, entry); err != nil {
+			return nil, err
+		}
+
+		query := `
+		insert into imported_transaction
+		(account_number, transaction_id, fingerprint, external_id, posted_on, amount, description, created_at)
+		values
+		($1, $2, $3, $4, $5, $6, $7, $8)`
+
+		if _, err := tx.ExecContext(ctx, query, number, transaction.ID, row.fingerprint, row.ExternalID, row.Date, row.Amount, row.Description, now); err != nil {
+			return nil, err
+		}
+
+		row.Status = ImportRowImported
+		row.TransactionID = &transaction.ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return NewImportResult(rows, dryRun), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// GetAccountsDueForInterest returns the savings accounts with at least one
+// full day before today that has not been accrued.
+func (s *PostgresStore) GetAccountsDueForInterest(ctx context.Context, today time.Time) ([]*Account, error) {
+	query := "select " + accountColumns + " from account where type = $1 and deleted_at is null and coalesce(interest_accrued_through + 1, created_at::date) < $2::date order by id"
+
+	return s.queryAccountsDueForInterest(ctx, query, today)
+}
+
+// queryAccountsDueForInterest runs the query of GetAccountsDueForInterest,
+// which takes the savings type and today.
+func (s *PostgresStore) queryAccountsDueForInterest(ctx context.Context, query string, today time.Time) ([]*Account, error) {
+	rows, err := s.db.QueryContext(ctx, query, AccountSavings, today)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := []*Account{}
+
+	for rows.Next() {
+		account, err := scanIntoAccount(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// AccrueInterest adds micros of interest for day, unless day has already been
+// accrued. With post the accrued whole minor units are credited to the
+// account as an interest entry dated the following midnight.
+func (s *PostgresStore) AccrueInterest(ctx context.Context, number int64, day time.Time, micros int64, post bool) error {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return err
+	}
+
+	acc := accounts[number]
+
+	if acc.InterestAccruedThrough != nil && !acc.InterestAccruedThrough.Before(day) {
+		return nil
+	}
+
+	acc.Accrue(micros)
+
+	if post && acc.AccruedInterest > 0 {
+		entry, err := beginJournalEntry(ctx, tx, JournalInterest, day.AddDate(0, 0, 1))
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := applyTransaction(ctx, tx, entry, acc, TransactionInterest, acc.AccruedInterest, nil); err != nil {
+			return err
+		}
+
+		entry.post(LedgerInterest, nil, acc.Currency, -acc.AccruedInterest)
+
+		if err := commitJournalEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+
+		acc.AccruedInterest = 0
+	}
+
+	query := "update account set accrued_interest = $1, interest_remainder = $2, interest_accrued_through = $3 where number = $4"
+
+	if _, err := tx.ExecContext(ctx, query, acc.AccruedInterest, acc.InterestRemainder, day, number); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
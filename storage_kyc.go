@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const kycColumns = "k.account_number, a.kyc_status, k.date_of_birth, k.national_id, k.address, k.rejection_reason, k.submitted_at, k.reviewed_at"
+
+func (s *PostgresStore) SubmitKYC(ctx context.Context, kyc *KYC) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	accounts, err := lockAccounts(ctx, tx, kyc.AccountNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if accounts[kyc.AccountNumber].KYCStatus == KYCVerified {
+		return conflictError("account with number %d is already verified", kyc.AccountNumber)
+	}
+
+	query := `
+	insert into account_kyc
+	(account_number, date_of_birth, national_id, address, submitted_at)
+	values
+	($1, $2, $3, $4, $5)
+	on conflict (account_number) do update set
+		date_of_birth = excluded.date_of_birth,
+		national_id = excluded.national_id,
+		address = excluded.address,
+		rejection_reason = null,
+		submitted_at = excluded.submitted_at,
+		reviewed_at = null`
+
+	if _, err := tx.ExecContext(ctx, query, kyc.AccountNumber, kyc.DateOfBirth, kyc.NationalID, kyc.Address, kyc.SubmittedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set kyc_status = $1 where number = $2", KYCPending, kyc.AccountNumber); err != nil {
+		return err
+	}
+
+	kyc.Status = KYCPending
+	kyc.RejectionReason = ""
+	kyc.ReviewedAt = nil
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) GetKYC(ctx context.Context, number int64) (*KYC, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+kycColumns+" from account_kyc k join account a on a.number = k.account_number where k.account_number = $1 and a.deleted_at is null", number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, notFoundError("account with number %d has not submitted KYC details", number)
+	}
+
+	return scanIntoKYC(rows)
+}
+
+func (s *PostgresStore) GetPendingKYC(ctx context.Context, limit, offset int) ([]*KYC, error) {
+	query := `select ` + kycColumns + ` from account_kyc k join account a on a.number = k.account_number
+		where a.kyc_status = $1 and a.deleted_at is null
+		order by k.submitted_at, k.account_number
+		limit $2 offset $3`
+
+	rows, err := s.db.QueryContext(ctx, query, KYCPending, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	submissions := []*KYC{}
+
+	for rows.Next() {
+		kyc, err := scanIntoKYC(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		submissions = append(submissions, kyc)
+	}
+
+	return submissions, rows.Err()
+}
+
+// ReviewKYC locks the account so a resubmission can't slip in between
+// checking the status and changing it.
+func (s *PostgresStore) ReviewKYC(ctx context.Context, number int64, status KYCStatus, reason string, reviewedAt time.Time) (*KYC, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := lockAccounts(ctx, tx, number); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "select "+kycColumns+" from account_kyc k join account a on a.number = k.account_number where k.account_number = $1", number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, notFoundError("account with number %d has not submitted KYC details", number)
+	}
+
+	kyc, err := scanIntoKYC(rows)
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if kyc.Status != KYCPending {
+		return nil, conflictError("KYC of account with number %d is already %s", number, kyc.Status)
+	}
+
+	var rejectionReason *string
+
+	if reason != "" {
+		rejectionReason = &reason
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account_kyc set rejection_reason = $1, reviewed_at = $2 where account_number = $3", rejectionReason, reviewedAt, number); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set kyc_status = $1 where number = $2", status, number); err != nil {
+		return nil, err
+	}
+
+	kyc.Status = status
+	kyc.RejectionReason = reason
+	kyc.ReviewedAt = &reviewedAt
+
+	return kyc, tx.Commit()
+}
+
+func scanIntoKYC(rows *sql.Rows) (*KYC, error) {
+	kyc := new(KYC)
+
+	var dateOfBirth time.Time
+	var rejectionReason sql.NullString
+
+	err := rows.Scan(&kyc.AccountNumber, &kyc.Status, &dateOfBirth, &kyc.NationalID, &kyc.Address, &rejectionReason, &kyc.SubmittedAt, &kyc.ReviewedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	kyc.DateOfBirth = dateOfBirth.Format(time.DateOnly)
+	kyc.RejectionReason = rejectionReason.String
+
+	return kyc, nil
+}
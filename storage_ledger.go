@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// journalTxKey keys the journalTx of the context a journal transaction runs
+// in.
+type journalTxKey struct{}
+
+// journalTx collects the entries a transaction commits, to report them to
+// OnJournalCommitted's fn once it has ended.
+type journalTx struct {
+	entries []*JournalEntry
+}
+
+func (s *PostgresStore) OnJournalCommitted(fn func(context.Context, *JournalEntry)) {
+	s.journalCommitted = fn
+}
+
+// beginJournalTx begins a transaction that commits journal entries, and
+// returns the context to run it in. endJournalTx is deferred in place of
+// tx.Rollback.
+func (s *PostgresStore) beginJournalTx(ctx context.Context) (context.Context, *sql.Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	return context.WithValue(ctx, journalTxKey{}, &journalTx{}), tx, nil
+}
+
+// endJournalTx rolls tx back unless it was committed, then reports the
+// entries it committed. Those of a transaction that failed to commit are
+// reported too: they changed nothing, which is harmless to report.
+func (s *PostgresStore) endJournalTx(ctx context.Context, tx *sql.Tx) {
+	tx.Rollback()
+
+	if s.journalCommitted == nil {
+		return
+	}
+
+	for _, entry := range ctx.Value(journalTxKey{}).(*journalTx).entries {
+		s.journalCommitted(ctx, entry)
+	}
+}
+
+// beginJournalEntry inserts the entry up front so the account transactions
+// posted to it can reference its id.
+func beginJournalEntry(ctx context.Context, tx *sql.Tx, kind JournalKind, createdAt time.Time) (*JournalEntry, error) {
+	entry := newJournalEntry(kind, createdAt)
+
+	err := tx.QueryRowContext(ctx, "insert into journal_entry (kind, created_at) values ($1, $2) returning id", kind, createdAt).Scan(&entry.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// commitJournalEntry writes the lines of the entry, refusing to if they do
+// not balance, or if tx wasn't begun by beginJournalTx, which reports the
+// entry once tx ends.
+func commitJournalEntry(ctx context.Context, tx *sql.Tx, entry *JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	jtx, ok := ctx.Value(journalTxKey{}).(*journalTx)
+
+	if !ok {
+		return errors.New("journal entry committed outside a transaction begun by beginJournalTx")
+	}
+
+	query := `
+	insert into journal_line
+	(entry_id, ledger, account_number, currency, amount)
+	values
+	($1, $2, $3, $4, $5)
+	returning id`
+
+	for _, line := range entry.Lines {
+		line.EntryID = entry.ID
+
+		if err := tx.QueryRowContext(ctx, query, entry.ID, line.Ledger, line.AccountNumber, line.Currency, line.Amount).Scan(&line.ID); err != nil {
+			return err
+		}
+	}
+
+	jtx.entries = append(jtx.entries, entry)
+
+	return nil
+}
+
+func (s *PostgresStore) CheckLedgerIntegrity(ctx context.Context) (*LedgerIntegrityReport, error) {
+	// one snapshot for all the queries so concurrent postings can't make
+	// the books look off
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	report := &LedgerIntegrityReport{Totals: map[string]int64{}, CheckedAt: time.Now().UTC()}
+
+	if err := tx.QueryRowContext(ctx, "select count(*) from journal_entry").Scan(&report.Entries); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "select currency, sum(amount) from journal_line group by currency")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var currency string
+		var total int64
+
+		if err := rows.Scan(&currency, &total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		report.Totals[currency] = total
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, "select distinct entry_id from journal_line group by entry_id, currency having sum(amount) <> 0")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var id int
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		report.UnbalancedEntries = append(report.UnbalancedEntries, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query := `
+	select a.number, a.balance, coalesce(l.total, 0)
+	from account a
+	left join (
+		select account_number, sum(amount) as total
+		from journal_line
+		where ledger = 'customer'
+		group by account_number
+	) l on l.account_number = a.number
+	where a.balance <> coalesce(l.total, 0)`
+
+	rows, err = tx.QueryContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var mismatch LedgerMismatch
+
+		if err := rows.Scan(&mismatch.AccountNumber, &mismatch.Balance, &mismatch.LedgerBalance); err != nil {
+			return nil, err
+		}
+
+		report.MismatchedAccounts = append(report.MismatchedAccounts, mismatch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report.finish(), nil
+}
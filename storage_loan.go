@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	loanColumns            = "id, account_number, principal, currency, annual_rate, term_months, monthly_payment, outstanding, arrears_amount, status, next_payment_at, created_at, paid_off_at"
+	loanInstallmentColumns = "loan_id, number, due_date, payment, principal, interest, balance, status, transaction_id, paid_at"
+)
+
+func (s *PostgresStore) CreateLoan(ctx context.Context, loan *Loan, installments []*LoanInstallment) (*Transaction, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, loan.AccountNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[loan.AccountNumber]
+
+	if err := acc.CheckActive(); err != nil {
+		return nil, err
+	}
+
+	query := `
+	insert into loan
+	(account_number, principal, currency, annual_rate, term_months, monthly_payment, outstanding, arrears_amount, status, next_payment_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	returning id`
+
+	err = tx.QueryRowContext(ctx, query, loan.AccountNumber, loan.Principal, loan.Currency, loan.AnnualRate, loan.TermMonths, loan.MonthlyPayment, loan.Outstanding, loan.ArrearsAmount, loan.Status, loan.NextPaymentAt, loan.CreatedAt).Scan(&loan.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+	insert into loan_installment
+	(loan_id, number, due_date, payment, principal, interest, balance, status)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	for _, installment := range installments {
+		installment.LoanID = loan.ID
+
+		if _, err := tx.ExecContext(ctx, query, loan.ID, installment.Number, installment.DueDate, installment.Payment, installment.Principal, installment.Interest, installment.Balance, installment.Status); err != nil {
+			return nil, err
+		}
+	}
+
+	entry, err := beginJournalEntry(ctx, tx, JournalLoanDisbursement, loan.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := applyTransaction(ctx, tx, entry, acc, TransactionLoanDisbursement, loan.Principal, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry.post(LedgerLoans, nil, acc.Currency, -loan.Principal)
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	return transaction, tx.Commit()
+}
+
+func (s *PostgresStore) GetLoan(ctx context.Context, id int) (*Loan, error) {
+	loans, err := queryLoans(ctx, s.db, "where id = $1", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(loans) == 0 {
+		return nil, notFoundError("loan %d not found", id)
+	}
+
+	return loans[0], nil
+}
+
+func (s *PostgresStore) GetLoans(ctx context.Context, number int64) ([]*Loan, error) {
+	return queryLoans(ctx, s.db, "where account_number = $1 order by id", number)
+}
+
+func (s *PostgresStore) GetLoanSchedule(ctx context.Context, id int) ([]*LoanInstallment, error) {
+	return queryLoanInstallments(ctx, s.db, "where loan_id = $1 order by number", id)
+}
+
+func (s *PostgresStore) GetLoansDue(ctx context.Context, now time.Time, limit int) ([]*Loan, error) {
+	return queryLoans(ctx, s.db, "where status <> $1 and next_payment_at <= $2 order by next_payment_at limit $3", LoanPaidOff, now, limit)
+}
+
+// CollectLoanRepayments locks the loan, then its account, so concurrent
+// collectors don't debit an installment twice.
+func (s *PostgresStore) CollectLoanRepayments(ctx context.Context, id int, now time.Time) (*Loan, []*Transaction, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	loans, err := queryLoans(ctx, tx, "where id = $1 for update", id)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(loans) == 0 {
+		return nil, nil, notFoundError("loan %d not found", id)
+	}
+
+	loan := loans[0]
+
+	if loan.Status == LoanPaidOff {
+		return loan, []*Transaction{}, nil
+	}
+
+	accounts, err := lockAccounts(ctx, tx, loan.AccountNumber)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	acc := accounts[loan.AccountNumber]
+
+	installments, err := queryLoanInstallments(ctx, tx, "where loan_id = $1 order by number", id)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transactions := []*Transaction{}
+	collecting := true
+
+	for _, installment := range installments {
+		if installment.Status == LoanInstallmentPaid || installment.DueDate.After(now) {
+			continue
+		}
+
+		// installments are collected in order, the ones after an unpaid one wait
+		if !collecting || !canCollect(acc, installment) {
+			collecting = false
+			installment.Status = LoanInstallmentOverdue
+		} else {
+			entry, err := beginJournalEntry(ctx, tx, JournalLoanRepayment, now)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			transaction, err := applyTransaction(ctx, tx, entry, acc, TransactionLoanRepayment, -installment.Payment, nil)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			entry.post(LedgerLoans, nil, acc.Currency, installment.Principal)
+			entry.post(LedgerInterest, nil, acc.Currency, installment.Interest)
+
+			if err := commitJournalEntry(ctx, tx, entry); err != nil {
+				return nil, nil, err
+			}
+
+			installment.Status = LoanInstallmentPaid
+			installment.TransactionID = &transaction.ID
+			installment.PaidAt = &now
+			transactions = append(transactions, transaction)
+		}
+
+		if _, err := tx.ExecContext(ctx, "update loan_installment set status = $1, transaction_id = $2, paid_at = $3 where loan_id = $4 and number = $5", installment.Status, installment.TransactionID, installment.PaidAt, id, installment.Number); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	settleLoan(loan, installments, now)
+
+	query := `
+	update loan
+	set outstanding = $1, arrears_amount = $2, status = $3, next_payment_at = $4, paid_off_at = $5
+	where id = $6`
+
+	if _, err := tx.ExecContext(ctx, query, loan.Outstanding, loan.ArrearsAmount, loan.Status, loan.NextPaymentAt, loan.PaidOffAt, id); err != nil {
+		return nil, nil, err
+	}
+
+	return loan, transactions, tx.Commit()
+}
+
+func queryLoans(ctx context.Context, db querier, where string, args ...any) ([]*Loan, error) {
+	rows, err := db.QueryContext(ctx, "select "+loanColumns+" from loan "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	loans := []*Loan{}
+
+	for rows.Next() {
+		loan := new(Loan)
+
+		err := rows.Scan(&loan.ID, &loan.AccountNumber, &loan.Principal, &loan.Currency, &loan.AnnualRate, &loan.TermMonths, &loan.MonthlyPayment, &loan.Outstanding, &loan.ArrearsAmount, &loan.Status, &loan.NextPaymentAt, &loan.CreatedAt, &loan.PaidOffAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		loans = append(loans, loan)
+	}
+
+	return loans, rows.Err()
+}
+
+func queryLoanInstallments(ctx context.Context, db querier, where string, args ...any) ([]*LoanInstallment, error) {
+	rows, err := db.QueryContext(ctx, "select "+loanInstallmentColumns+" from loan_installment "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	installments := []*LoanInstallment{}
+
+	for rows.Next() {
+		installment := new(LoanInstallment)
+
+		err := rows.Scan(&installment.LoanID, &installment.Number, &installment.DueDate, &installment.Payment, &installment.Principal, &installment.Interest, &installment.Balance, &installment.Status, &installment.TransactionID, &installment.PaidAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		installments = append(installments, installment)
+	}
+
+	return installments, rows.Err()
+}
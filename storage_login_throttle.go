@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const loginThrottleColumns = "key, failures, last_failure_at, locked_until"
+
+func (s *PostgresStore) GetLoginThrottles(ctx context.Context, keys []string) ([]*LoginThrottle, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+loginThrottleColumns+" from login_throttle where key = any($1)", keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	throttles := []*LoginThrottle{}
+
+	for rows.Next() {
+		throttle := new(LoginThrottle)
+
+		if err := rows.Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil); err != nil {
+			return nil, err
+		}
+
+		throttles = append(throttles, throttle)
+	}
+
+	return throttles, rows.Err()
+}
+
+func (s *PostgresStore) RecordLoginFailure(ctx context.Context, key string, policy LoginPolicy, now time.Time) (*LoginThrottle, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "insert into login_throttle (key, failures, last_failure_at) values ($1, 0, $2) on conflict (key) do nothing", key, now); err != nil {
+		return nil, err
+	}
+
+	throttle := new(LoginThrottle)
+
+	if err := tx.QueryRowContext(ctx, "select "+loginThrottleColumns+" from login_throttle where key = $1 for update", key).Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil); err != nil {
+		return nil, err
+	}
+
+	throttle.Fail(policy, now)
+
+	query := `
+	update login_throttle
+	set failures = $2, last_failure_at = $3, locked_until = $4
+	where key = $1`
+
+	if _, err := tx.ExecContext(ctx, query, key, throttle.Failures, throttle.LastFailureAt, throttle.LockedUntil); err != nil {
+		return nil, err
+	}
+
+	return throttle, tx.Commit()
+}
+
+func (s *PostgresStore) ClearLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error) {
+	throttle := new(LoginThrottle)
+
+	err := s.db.QueryRowContext(ctx, "delete from login_throttle where key = $1 returning "+loginThrottleColumns, key).Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt, &throttle.LockedUntil)
+
+	if err == sql.ErrNoRows {
+		return nil, notFoundError("no failed logins for %s", key)
+	}
+
+	return throttle, err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+func (s *PostgresStore) GetMaintenanceState(ctx context.Context) (*MaintenanceState, error) {
+	state := new(MaintenanceState)
+
+	query := "select mode, message, retry_after, updated_by, updated_at from maintenance_state order by id desc limit 1"
+
+	err := s.db.QueryRowContext(ctx, query).Scan(&state.Mode, &state.Message, &state.RetryAfter, &state.UpdatedBy, &state.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &MaintenanceState{Mode: MaintenanceOff}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// SetMaintenanceState adds a row rather than updating one, so the table
+// keeps the history of the switches.
+func (s *PostgresStore) SetMaintenanceState(ctx context.Context, state *MaintenanceState) error {
+	query := `
+	insert into maintenance_state
+	(mode, message, retry_after, updated_by, updated_at)
+	values
+	($1, $2, $3, $4, $5)`
+
+	_, err := s.db.ExecContext(ctx, query, state.Mode, state.Message, state.RetryAfter, state.UpdatedBy, state.UpdatedAt)
+
+	return err
+}
@@ -0,0 +1,4918 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in maps guarded by a single mutex. It backs
+// -store=memory for demos and lets handler tests run without Postgres; all
+// data is lost when the process exits.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	ids           map[string]int
+	accounts      map[int]*Account
+	transactions  []*Transaction
+	events        []*AccountEvent
+	journal       []*JournalEntry
+	transfers     []*Transfer
+	batches       map[int]*TransferBatch
+	holds         map[int]*Hold
+	beneficiaries map[int]*Beneficiary
+	aliases       map[int]*Alias
+	contacts      map[contactKey]*ContactDetail
+	auditLog      []*AuditEntry
+	identities    []*ExternalIdentity
+	kyc           map[int64]*KYC
+	owners        []*AccountOwner
+	approvals     map[int]*TransferApproval
+	fraudReviews  map[int]*FraudReview
+	amlFlags      map[int]*AMLFlag
+	amlNotes      []*AMLNote
+	disputes      map[int]*Dispute
+	loginNetworks map[int64][]string
+	throttles     map[string]*LoginThrottle
+	pots          map[int]*Pot
+	refreshTokens map[string]*RefreshToken
+	resets        map[string]*PasswordReset
+	idempotency   map[[2]string]*IdempotencyRecord
+
+	// adminApprovals are the admin actions proposed for a second admin.
+	adminApprovals map[int]*AdminApproval
+
+	// notificationPreferences are by account number; deliveries are in the
+	// order they were queued.
+	notificationPreferences map[int64]*NotificationPreferences
+	notificationDeliveries  []*NotificationDelivery
+	notificationLocks       map[int]time.Time
+
+	// loanInstallments are the amortization schedules of loans, by loan id.
+	loans            map[int]*Loan
+	loanInstallments map[int][]*LoanInstallment
+	termDeposits     map[int]*TermDeposit
+
+	// closingBalances are in the order they were materialized, by day.
+	closingBalances []*ClosingBalance
+
+	// reconciliationReports are in the order they were made.
+	reconciliationReports []*ReconciliationReport
+
+	maintenance *MaintenanceState
+
+	transferQuotes  map[int]*TransferQuote
+	paymentRequests map[int]*PaymentRequest
+
+	// denylist holds until when the access tokens of revoked sessions are
+	// denied, by session id.
+	sessions map[int]*Session
+	denylist map[int]time.Time
+
+	// journalCommitted is the fn of OnJournalCommitted, called with s.mu
+	// held, which keeps readers from seeing the entry's balances before it.
+	journalCommitted func(context.Context, *JournalEntry)
+
+	apiKeys       map[int]*APIKey
+	businessUsers map[int]*BusinessUser
+
+	cards              map[int]*Card
+	cardAuthorizations map[int]*CardAuthorization
+	externalTransfers  map[int]*ExternalTransfer
+
+	// imported maps the fingerprints of each account's imported rows to
+	// the transactions they were booked as.
+	imported map[int64]map[string]int
+
+	totps       map[int64]*TOTP
+	backupCodes map[int64]map[string]bool
+
+	scheduledTransfers    map[int]*ScheduledTransfer
+	scheduledTransferRuns []*ScheduledTransferRun
+	scheduledLocks        map[int]time.Time
+
+	standingOrders     map[int]*StandingOrder
+	standingOrderLocks map[int]time.Time
+	holidays           map[string]*Holiday
+
+	webhooks          map[int]*Webhook
+	inactiveWebhooks  map[int]bool
+	webhookDeliveries []*WebhookDelivery
+	deliveryLocks     map[int]time.Time
+
+	outbox      []*OutboxMessage
+	outboxLocks map[int64]time.Time
+}
+
+var _ Storage = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		ids:                map[string]int{},
+		accounts:           map[int]*Account{},
+		holds:              map[int]*Hold{},
+		cards:              map[int]*Card{},
+		cardAuthorizations: map[int]*CardAuthorization{},
+		externalTransfers:  map[int]*ExternalTransfer{},
+		imported:           map[int64]map[string]int{},
+		batches:            map[int]*TransferBatch{},
+		beneficiaries:      map[int]*Beneficiary{},
+		aliases:            map[int]*Alias{},
+		contacts:           map[contactKey]*ContactDetail{},
+		kyc:                map[int64]*KYC{},
+		approvals:          map[int]*TransferApproval{},
+		adminApprovals:     map[int]*AdminApproval{},
+		fraudReviews:       map[int]*FraudReview{},
+		amlFlags:           map[int]*AMLFlag{},
+		disputes:           map[int]*Dispute{},
+		loginNetworks:      map[int64][]string{},
+		throttles:          map[string]*LoginThrottle{},
+		pots:               map[int]*Pot{},
+		refreshTokens:      map[string]*RefreshToken{},
+		resets:             map[string]*PasswordReset{},
+		idempotency:        map[[2]string]*IdempotencyRecord{},
+		totps:              map[int64]*TOTP{},
+		backupCodes:        map[int64]map[string]bool{},
+		scheduledTransfers: map[int]*ScheduledTransfer{},
+		scheduledLocks:     map[int]time.Time{},
+		standingOrders:     map[int]*StandingOrder{},
+		standingOrderLocks: map[int]time.Time{},
+		holidays:           map[string]*Holiday{},
+		webhooks:           map[int]*Webhook{},
+		inactiveWebhooks:   map[int]bool{},
+		deliveryLocks:      map[int]time.Time{},
+		outboxLocks:        map[int64]time.Time{},
+
+		notificationPreferences: map[int64]*NotificationPreferences{},
+		notificationLocks:       map[int]time.Time{},
+
+		transferQuotes:  map[int]*TransferQuote{},
+		paymentRequests: map[int]*PaymentRequest{},
+
+		sessions: map[int]*Session{},
+		denylist: map[int]time.Time{},
+
+		apiKeys:       map[int]*APIKey{},
+		businessUsers: map[int]*BusinessUser{},
+
+		loans:            map[int]*Loan{},
+		loanInstallments: map[int][]*LoanInstallment{},
+		termDeposits:     map[int]*TermDeposit{},
+	}
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// PendingMigrations reports none: the memory store has no schema.
+func (s *MemoryStore) PendingMigrations(ctx context.Context) ([]int, error) {
+	return []int{}, nil
+}
+
+// nextID mimics a serial column.
+func (s *MemoryStore) nextID(table string) int {
+	s.ids[table]++
+	return s.ids[table]
+}
+
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+
+	items = items[offset:]
+
+	if limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items
+}
+
+func (s *MemoryStore) CreateAccount(ctx context.Context, acc *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.createAccount(acc)
+
+	return err
+}
+
+func (s *MemoryStore) createAccount(acc *Account) (*Account, error) {
+	// deleted accounts keep their number
+	for _, existing := range s.accounts {
+		if existing.Number == acc.Number {
+			return nil, errAccountNumberTaken
+		}
+	}
+
+	acc.ID = s.nextID("account")
+	acc.Version = 1
+
+	stored := *acc
+	s.accounts[acc.ID] = &stored
+	s.events = append(s.events, newAccountOpenedEvent(acc))
+
+	return &stored, nil
+}
+
+func (s *MemoryStore) SeedAccount(ctx context.Context, acc *Account, history []*Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.createAccount(acc)
+
+	if err != nil {
+		return err
+	}
+
+	for _, t := range history {
+		entry := s.beginJournalEntry(seedJournalKinds[t.Type], t.CreatedAt)
+		s.applyTransaction(entry, stored, t.Type, t.Amount, nil)
+		entry.post(LedgerCash, nil, stored.Currency, -t.Amount)
+
+		if err := s.commitJournalEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	acc.Balance, acc.Version = stored.Balance, stored.Version
+
+	return nil
+}
+
+// CreateAccountWithTransfer removes the account again when the transfer
+// fails; transfers fail before they move any money.
+func (s *MemoryStore) CreateAccountWithTransfer(ctx context.Context, acc *Account, transfer *Transfer) error {
+	if transfer.Amount <= 0 || transfer.ToAmount <= 0 {
+		return validationError("invalid amount %d", transfer.Amount)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := s.accountByNumber(transfer.FromAccount)
+
+	if from == nil {
+		return accountNotFoundError("account with number %d not found", transfer.FromAccount)
+	}
+
+	stored, err := s.createAccount(acc)
+
+	if err != nil {
+		return err
+	}
+
+	transfer.ToAccount = stored.Number
+
+	if err := s.transfer(from, stored, transfer); err != nil {
+		delete(s.accounts, stored.ID)
+		s.events = s.events[:len(s.events)-1]
+
+		return err
+	}
+
+	acc.Balance, acc.Version = stored.Balance, stored.Version
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateAccountStatus(ctx context.Context, id int, status AccountStatus) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.accountById(id)
+
+	if stored == nil {
+		return nil, accountNotFoundError("account %d not found", id)
+	}
+
+	if err := stored.CheckStatusChange(status); err != nil {
+		return nil, err
+	}
+
+	stored.Status = status
+	stored.Version++
+
+	copied := *stored
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) DeleteAccount(ctx context.Context, id int, now time.Time) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.accountById(id)
+
+	if stored == nil {
+		return nil, accountNotFoundError("account %d not found", id)
+	}
+
+	if err := stored.CheckDelete(); err != nil {
+		return nil, err
+	}
+
+	stored.DeletedAt = &now
+	stored.Version++
+
+	copied := *stored
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) CloseAccount(ctx context.Context, number, sweepTo int64) (*AccountClosure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	var to *Account
+
+	if sweepTo != 0 {
+		if to = s.accountByNumber(sweepTo); to == nil {
+			return nil, accountNotFoundError("account with number %d not found", sweepTo)
+		}
+	}
+
+	if err := acc.CheckClose(to); err != nil {
+		return nil, err
+	}
+
+	closure := new(AccountClosure)
+
+	if acc.Balance > 0 {
+		closure.Sweep = newSweepTransfer(acc, sweepTo)
+
+		if err := s.sweep(acc, to, closure.Sweep); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, order := range s.standingOrders {
+		if order.FromAccount == number && order.Status == StandingOrderActive {
+			order.Status = StandingOrderCancelled
+			closure.CancelledStandingOrders++
+		}
+	}
+
+	for _, st := range s.scheduledTransfers {
+		if st.FromAccount == number && st.Status == ScheduledTransferActive {
+			st.Status = ScheduledTransferCancelled
+			closure.CancelledScheduledTransfers++
+		}
+	}
+
+	acc.Status = AccountClosed
+	acc.Version++
+
+	copied := *acc
+	closure.Account = &copied
+
+	return closure, nil
+}
+
+// sweep mirrors sweepLocked.
+func (s *MemoryStore) sweep(fromAcc, toAcc *Account, transfer *Transfer) error {
+	from, to := transfer.FromAccount, transfer.ToAccount
+
+	entry := s.beginJournalEntry(JournalTransfer, transfer.CreatedAt)
+	s.applyTransaction(entry, fromAcc, TransactionTransferOut, -transfer.Amount, &to)
+	s.applyTransaction(entry, toAcc, TransactionTransferIn, transfer.ToAmount, &from)
+
+	if err := s.commitJournalEntry(entry); err != nil {
+		return err
+	}
+
+	return s.insertTransfer(transfer)
+}
+
+func (s *MemoryStore) RestoreAccount(ctx context.Context, id int) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.accounts[id]
+
+	if !ok || stored.DeletedAt == nil {
+		return nil, accountNotFoundError("deleted account %d not found", id)
+	}
+
+	stored.DeletedAt = nil
+	stored.Version++
+
+	copied := *stored
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.accountById(acc.ID)
+
+	if stored == nil {
+		return nil
+	}
+
+	if err := stored.CheckVersion(acc.Version); err != nil {
+		return err
+	}
+
+	stored.FirstName = acc.FirstName
+	stored.LastName = acc.LastName
+	stored.Version++
+	acc.Version = stored.Version
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateAccountMetadata(ctx context.Context, id int, patch map[string]*string, version int) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.accountById(id)
+
+	if stored == nil {
+		return nil, accountNotFoundError("account %d not found", id)
+	}
+
+	if err := stored.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	metadata, err := mergeMetadata(stored.Metadata, patch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// replaced rather than changed in place, as copies handed out share it
+	stored.Metadata = metadata
+	stored.Version++
+
+	copied := *stored
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) ResetPassword(ctx context.Context, number int64, encryptedPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.resetPassword(number, encryptedPassword)
+}
+
+func (s *MemoryStore) resetPassword(number int64, encryptedPassword string) error {
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return accountNotFoundError("account with number %d not found", number)
+	}
+
+	acc.EncryptedPassword = encryptedPassword
+	acc.Version++
+	now := time.Now().UTC()
+
+	for _, token := range s.refreshTokens {
+		if token.AccountNumber == number && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateAccountLimits(ctx context.Context, id int, limits AccountLimits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.accountById(id)
+
+	if stored == nil {
+		return accountNotFoundError("account %d not found", id)
+	}
+
+	stored.AccountLimits = limits
+	stored.Version++
+
+	return nil
+}
+
+func (s *MemoryStore) SearchAccounts(ctx context.Context, q string, limit, offset int) ([]*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := []*Account{}
+	scores := map[int]float64{}
+
+	for _, acc := range s.accounts {
+		if acc.DeletedAt != nil {
+			continue
+		}
+
+		if score := accountSearchScore(acc, q); score > 0 {
+			copied := *acc
+			accounts = append(accounts, &copied)
+			scores[acc.ID] = score
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		a, b := accounts[i], accounts[j]
+
+		if scores[a.ID] != scores[b.ID] {
+			return scores[a.ID] > scores[b.ID]
+		}
+
+		return a.ID < b.ID
+	})
+
+	return page(accounts, limit, offset), nil
+}
+
+func (s *MemoryStore) GetAccounts(ctx context.Context, filter AccountFilter) ([]*Account, error) {
+	// validates the sort the same way the Postgres store does
+	if _, err := accountOrderBy(filter.Sort); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := []*Account{}
+
+	for _, acc := range s.accounts {
+		if acc.DeletedAt != nil {
+			continue
+		}
+
+		if filter.LastName != "" && acc.LastName != filter.LastName {
+			continue
+		}
+
+		if filter.MinBalance != nil && acc.Balance < *filter.MinBalance {
+			continue
+		}
+
+		if !matchesMetadata(acc.Metadata, filter.Metadata) {
+			continue
+		}
+
+		copied := *acc
+		accounts = append(accounts, &copied)
+	}
+
+	column, desc := strings.TrimPrefix(filter.Sort, "-"), strings.HasPrefix(filter.Sort, "-")
+
+	sort.Slice(accounts, func(i, j int) bool {
+		a, b := accounts[i], accounts[j]
+
+		if desc {
+			a, b = b, a
+		}
+
+		switch {
+		case column == "created_at" && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		case column == "last_name" && a.LastName != b.LastName:
+			return a.LastName < b.LastName
+		}
+
+		return a.ID < b.ID
+	})
+
+	return page(accounts, filter.Limit, filter.Offset), nil
+}
+
+func (s *MemoryStore) GetAccountById(ctx context.Context, id int) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountById(id)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account %d not found", id)
+	}
+
+	copied := *acc
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetAccountByNumber(ctx context.Context, number int) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(int64(number))
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	copied := *acc
+
+	return &copied, nil
+}
+
+// accountById and accountByNumber skip soft-deleted accounts.
+func (s *MemoryStore) accountById(id int) *Account {
+	if acc, ok := s.accounts[id]; ok && acc.DeletedAt == nil {
+		return acc
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) accountByNumber(number int64) *Account {
+	for _, acc := range s.accounts {
+		if acc.Number == number && acc.DeletedAt == nil {
+			return acc
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Deposit(ctx context.Context, number, amount int64, version int) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, validationError("invalid amount %d", amount)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	if err := acc.CheckActive(); err != nil {
+		return nil, err
+	}
+
+	if err := acc.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	if _, ok := addMinorUnits(acc.Balance, amount); !ok {
+		return nil, validationError("deposit of %d would take the balance of account %d out of range", amount, number)
+	}
+
+	entry := s.beginJournalEntry(JournalDeposit, time.Now().UTC())
+	transaction := s.applyTransaction(entry, acc, TransactionDeposit, amount, nil)
+	transaction.accountVersion = acc.Version
+	entry.post(LedgerCash, nil, acc.Currency, -amount)
+
+	return transaction, s.commitJournalEntry(entry)
+}
+
+func (s *MemoryStore) AdjustBalance(ctx context.Context, number, amount int64, description string) (*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	if err := checkAdjustment(acc, amount); err != nil {
+		return nil, err
+	}
+
+	entry := s.beginJournalEntry(JournalAdjustment, time.Now().UTC())
+	transaction := s.applyTransaction(entry, acc, TransactionAdjustment, amount, nil)
+	s.transactions[len(s.transactions)-1].Description = description
+	transaction.Description = description
+	transaction.accountVersion = acc.Version
+	entry.post(LedgerAdjustments, nil, acc.Currency, -amount)
+
+	return transaction, s.commitJournalEntry(entry)
+}
+
+func (s *MemoryStore) Withdraw(ctx context.Context, number, amount int64, version int) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, validationError("invalid amount %d", amount)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	if err := acc.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	entry := s.beginJournalEntry(JournalWithdrawal, time.Now().UTC())
+	transaction, err := s.debit(entry, acc, TransactionWithdrawal, amount, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.accountVersion = acc.Version
+	entry.post(LedgerCash, nil, acc.Currency, amount)
+
+	return transaction, s.commitJournalEntry(entry)
+}
+
+func (s *MemoryStore) GetTransactions(ctx context.Context, number int64, limit, offset int) ([]*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transactions := []*Transaction{}
+
+	for i := len(s.transactions) - 1; i >= 0; i-- {
+		if t := s.transactions[i]; t.AccountNumber == number {
+			copied := *t
+			transactions = append(transactions, &copied)
+		}
+	}
+
+	return page(transactions, limit, offset), nil
+}
+
+func (s *MemoryStore) GetTransactionsBefore(ctx context.Context, number int64, before *TransactionCursor, limit int) ([]*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transactions := []*Transaction{}
+
+	for _, t := range s.transactions {
+		if t.AccountNumber == number && (before == nil || before.precedes(t)) {
+			copied := *t
+			transactions = append(transactions, &copied)
+		}
+	}
+
+	sort.Slice(transactions, func(i, j int) bool {
+		return newTransactionCursor(transactions[i]).precedes(transactions[j])
+	})
+
+	return page(transactions, limit, 0), nil
+}
+
+func (s *MemoryStore) SearchTransactions(ctx context.Context, number int64, search *TransactionSearch) ([]*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transactions := []*Transaction{}
+
+	for _, t := range s.transactions {
+		if t.AccountNumber == number && t.TransferReference.Matches(search.Query) && (search.Before == nil || search.Before.precedes(t)) {
+			copied := *t
+			transactions = append(transactions, &copied)
+		}
+	}
+
+	sort.Slice(transactions, func(i, j int) bool {
+		return newTransactionCursor(transactions[i]).precedes(transactions[j])
+	})
+
+	return page(transactions, search.Limit, search.Offset), nil
+}
+
+func (s *MemoryStore) GetTransactionsBetween(ctx context.Context, number int64, from, to time.Time) ([]*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transactions := []*Transaction{}
+
+	for _, t := range s.transactions {
+		if t.AccountNumber == number && !t.CreatedAt.Before(from) && t.CreatedAt.Before(to) {
+			copied := *t
+			transactions = append(transactions, &copied)
+		}
+	}
+
+	return transactions, nil
+}
+
+// StreamTransactions copies the entries before calling fn, so a slow fn
+// doesn't hold the lock, and orders them by created_at then id, since
+// seeded and imported entries are back-dated.
+func (s *MemoryStore) StreamTransactions(ctx context.Context, number int64, from, to time.Time, fn func(*Transaction) error) error {
+	transactions, err := s.GetTransactionsBetween(ctx, number, from, to)
+
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if !transactions[i].CreatedAt.Equal(transactions[j].CreatedAt) {
+			return transactions[i].CreatedAt.Before(transactions[j].CreatedAt)
+		}
+
+		return transactions[i].ID < transactions[j].ID
+	})
+
+	for _, t := range transactions {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) CategorizeTransaction(ctx context.Context, number int64, id int, category string) (*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.transactions {
+		if t.ID == id && t.AccountNumber == number {
+			t.Category = category
+			copied := *t
+
+			return &copied, nil
+		}
+	}
+
+	return nil, notFoundError("transaction %d not found", id)
+}
+
+func (s *MemoryStore) GetCategoryTotals(ctx context.Context, number int64, from, to time.Time) ([]*CategoryTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type key struct {
+		category string
+		month    time.Time
+	}
+
+	totals := []*CategoryTotal{}
+	byKey := map[key]*CategoryTotal{}
+
+	for _, t := range s.transactions {
+		if t.AccountNumber != number || t.CreatedAt.Before(from) || !t.CreatedAt.Before(to) {
+			continue
+		}
+
+		k := key{category: t.Category, month: startOfMonth(t.CreatedAt)}
+
+		if k.category == "" {
+			k.category = uncategorizedCategory
+		}
+
+		total, ok := byKey[k]
+
+		if !ok {
+			total = &CategoryTotal{Category: k.category, Month: k.month}
+			byKey[k] = total
+			totals = append(totals, total)
+		}
+
+		if t.Amount > 0 {
+			total.Credits += t.Amount
+		} else {
+			total.Debits -= t.Amount
+		}
+
+		total.Count++
+	}
+
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Category != totals[j].Category {
+			return totals[i].Category < totals[j].Category
+		}
+
+		return totals[i].Month.Before(totals[j].Month)
+	})
+
+	return totals, nil
+}
+
+func (s *MemoryStore) GetBalanceAt(ctx context.Context, number int64, at time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.balanceAt(number, at), nil
+}
+
+func (s *MemoryStore) balanceAt(number int64, at time.Time) int64 {
+	for i := len(s.transactions) - 1; i >= 0; i-- {
+		if t := s.transactions[i]; t.AccountNumber == number && t.CreatedAt.Before(at) {
+			return t.Balance
+		}
+	}
+
+	return 0
+}
+
+func (s *MemoryStore) NextClosingBalanceDay(ctx context.Context) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := len(s.closingBalances); n > 0 {
+		last, err := time.Parse(statementDateLayout, s.closingBalances[n-1].Date)
+
+		return last.AddDate(0, 0, 1), err
+	}
+
+	var first time.Time
+
+	for _, acc := range s.accounts {
+		if !acc.CreatedAt.IsZero() && (first.IsZero() || acc.CreatedAt.Before(first)) {
+			first = acc.CreatedAt
+		}
+	}
+
+	return first.Truncate(24 * time.Hour), nil
+}
+
+func (s *MemoryStore) MaterializeClosingBalances(ctx context.Context, day time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	date, end := day.Format(statementDateLayout), day.AddDate(0, 0, 1)
+	stored := map[int64]bool{}
+
+	for _, balance := range s.closingBalances {
+		if balance.Date == date {
+			stored[balance.AccountNumber] = true
+		}
+	}
+
+	accounts := []*Account{}
+
+	for _, acc := range s.accounts {
+		if acc.CreatedAt.Before(end) && !stored[acc.Number] {
+			accounts = append(accounts, acc)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number < accounts[j].Number })
+
+	for _, acc := range accounts {
+		s.closingBalances = append(s.closingBalances, &ClosingBalance{AccountNumber: acc.Number, Date: date, Balance: s.balanceAt(acc.Number, end)})
+	}
+
+	return len(accounts), nil
+}
+
+func (s *MemoryStore) GetClosingBalances(ctx context.Context, number int64, from, to time.Time) ([]*ClosingBalance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first, end := from.Format(statementDateLayout), to.Format(statementDateLayout)
+	balances := []*ClosingBalance{}
+
+	for _, balance := range s.closingBalances {
+		if balance.AccountNumber == number && balance.Date >= first && balance.Date < end {
+			copied := *balance
+			balances = append(balances, &copied)
+		}
+	}
+
+	return balances, nil
+}
+
+// debit mirrors the Postgres debit: it enforces the account's limits and
+// charges the overdraft fee as a separate entry.
+func (s *MemoryStore) debit(entry *JournalEntry, acc *Account, kind TransactionType, amount int64, counterparty *int64) (*Transaction, error) {
+	if err := acc.CheckActive(); err != nil {
+		return nil, err
+	}
+
+	if !acc.CanDebit(amount) {
+		return nil, insufficientFundsError()
+	}
+
+	transaction := s.applyTransaction(entry, acc, kind, -amount, counterparty)
+
+	if fee := acc.OverdraftFeeFor(transaction.Balance); fee > 0 {
+		s.applyTransaction(entry, acc, TransactionFee, -fee, nil)
+		entry.post(LedgerFees, nil, acc.Currency, fee)
+	}
+
+	return transaction, nil
+}
+
+func (s *MemoryStore) applyTransaction(entry *JournalEntry, acc *Account, kind TransactionType, amount int64, counterparty *int64) *Transaction {
+	acc.Balance += amount
+	acc.Version++
+	entry.postCustomer(acc, amount)
+
+	transaction := &Transaction{
+		ID:            s.nextID("transactions"),
+		JournalID:     entry.ID,
+		AccountNumber: acc.Number,
+		Type:          kind,
+		Amount:        amount,
+		Balance:       acc.Balance,
+		Counterparty:  counterparty,
+		CreatedAt:     entry.CreatedAt,
+	}
+
+	s.transactions = append(s.transactions, transaction)
+	s.events = append(s.events, newTransactionEvent(transaction, s.lastEventVersion(acc.Number)+1))
+
+	copied := *transaction
+
+	return &copied
+}
+
+func (s *MemoryStore) lastEventVersion(number int64) int {
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].AccountNumber == number {
+			return s.events[i].Version
+		}
+	}
+
+	return 0
+}
+
+func (s *MemoryStore) GetAccountEvents(ctx context.Context, number int64, filter AccountEventFilter) ([]*AccountEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := []*AccountEvent{}
+
+	for _, e := range s.events {
+		if e.AccountNumber != number || e.Version <= filter.AfterVersion || (!filter.Until.IsZero() && !e.CreatedAt.Before(filter.Until)) {
+			continue
+		}
+
+		copied := *e
+		events = append(events, &copied)
+
+		if len(events) == filter.Limit {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+func (s *MemoryStore) beginJournalEntry(kind JournalKind, createdAt time.Time) *JournalEntry {
+	entry := newJournalEntry(kind, createdAt)
+	entry.ID = s.nextID("journal_entry")
+
+	return entry
+}
+
+func (s *MemoryStore) commitJournalEntry(entry *JournalEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	for _, line := range entry.Lines {
+		line.ID = s.nextID("journal_line")
+		line.EntryID = entry.ID
+	}
+
+	s.journal = append(s.journal, entry)
+
+	if s.journalCommitted != nil {
+		s.journalCommitted(context.Background(), entry)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) OnJournalCommitted(fn func(context.Context, *JournalEntry)) {
+	s.journalCommitted = fn
+}
+
+func (s *MemoryStore) CheckLedgerIntegrity(ctx context.Context) (*LedgerIntegrityReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &LedgerIntegrityReport{Totals: map[string]int64{}, Entries: len(s.journal), CheckedAt: time.Now().UTC()}
+	balances := map[int64]int64{}
+
+	for _, entry := range s.journal {
+		totals, err := sumLines(entry.Lines)
+
+		if err != nil && !slices.Contains(report.UnbalancedEntries, entry.ID) {
+			report.UnbalancedEntries = append(report.UnbalancedEntries, entry.ID)
+		}
+
+		for currency, total := range totals {
+			report.Totals[currency] += total
+
+			if total != 0 && !slices.Contains(report.UnbalancedEntries, entry.ID) {
+				report.UnbalancedEntries = append(report.UnbalancedEntries, entry.ID)
+			}
+		}
+
+		for _, line := range entry.Lines {
+			if line.Ledger == LedgerCustomer {
+				balances[*line.AccountNumber] += line.Amount
+			}
+		}
+	}
+
+	for _, acc := range s.accounts {
+		if acc.Balance != balances[acc.Number] {
+			report.MismatchedAccounts = append(report.MismatchedAccounts, LedgerMismatch{
+				AccountNumber: acc.Number,
+				Balance:       acc.Balance,
+				LedgerBalance: balances[acc.Number],
+			})
+		}
+	}
+
+	return report.finish(), nil
+}
+
+func (s *MemoryStore) ExportDataset(ctx context.Context, now time.Time) (*Dataset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := &Dataset{
+		Version:        datasetVersion,
+		ExportedAt:     now,
+		Accounts:       []*ArchivedAccount{},
+		AccountEvents:  []*AccountEvent{},
+		JournalEntries: []*JournalEntry{},
+		Transactions:   []*Transaction{},
+		Transfers:      []*Transfer{},
+	}
+
+	for _, acc := range s.accounts {
+		copied := *acc
+		d.Accounts = append(d.Accounts, NewArchivedAccount(&copied))
+	}
+
+	sort.Slice(d.Accounts, func(i, j int) bool { return d.Accounts[i].ID < d.Accounts[j].ID })
+
+	for _, e := range s.events {
+		copied := *e
+		d.AccountEvents = append(d.AccountEvents, &copied)
+	}
+
+	for _, entry := range s.journal {
+		d.JournalEntries = append(d.JournalEntries, copyJournalEntry(entry))
+	}
+
+	sort.Slice(d.JournalEntries, func(i, j int) bool { return d.JournalEntries[i].ID < d.JournalEntries[j].ID })
+
+	for _, t := range s.transactions {
+		copied := *t
+		d.Transactions = append(d.Transactions, &copied)
+	}
+
+	for _, t := range s.transfers {
+		copied := *t
+		d.Transfers = append(d.Transfers, &copied)
+	}
+
+	return d, nil
+}
+
+func (s *MemoryStore) ImportDataset(ctx context.Context, d *Dataset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.journal) > 0 || len(s.transfers) > 0 {
+		return conflictError("the store already has journal entries, import into a fresh instance")
+	}
+
+	for _, a := range d.Accounts {
+		for _, acc := range s.accounts {
+			if acc.ID == a.ID || acc.Number == a.Number {
+				return conflictError("the store has accounts with the ids or numbers of archived ones")
+			}
+		}
+	}
+
+	for _, a := range d.Accounts {
+		copied := *a.Account
+		s.accounts[copied.ID] = &copied
+		s.ids["account"] = max(s.ids["account"], copied.ID)
+	}
+
+	for _, e := range d.AccountEvents {
+		copied := *e
+		s.events = append(s.events, &copied)
+	}
+
+	for _, entry := range d.JournalEntries {
+		copied := copyJournalEntry(entry)
+		s.journal = append(s.journal, copied)
+		s.ids["journal_entry"] = max(s.ids["journal_entry"], copied.ID)
+
+		for _, line := range copied.Lines {
+			s.ids["journal_line"] = max(s.ids["journal_line"], line.ID)
+		}
+	}
+
+	for _, t := range d.Transactions {
+		copied := *t
+		s.transactions = append(s.transactions, &copied)
+		s.ids["transactions"] = max(s.ids["transactions"], copied.ID)
+	}
+
+	for _, t := range d.Transfers {
+		copied := *t
+
+		if copied.Status == "" {
+			copied.Status = TransferCompleted
+		}
+
+		s.transfers = append(s.transfers, &copied)
+		s.ids["transfer"] = max(s.ids["transfer"], copied.ID)
+	}
+
+	return nil
+}
+
+func copyJournalEntry(entry *JournalEntry) *JournalEntry {
+	copied := *entry
+	copied.Lines = make([]*JournalLine, len(entry.Lines))
+
+	for i, line := range entry.Lines {
+		l := *line
+		copied.Lines[i] = &l
+	}
+
+	return &copied
+}
+
+func (s *MemoryStore) GetAccountsDueForInterest(ctx context.Context, today time.Time) ([]*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := []*Account{}
+
+	for _, acc := range s.accounts {
+		next := acc.CreatedAt.Truncate(24 * time.Hour)
+
+		if acc.InterestAccruedThrough != nil {
+			next = acc.InterestAccruedThrough.AddDate(0, 0, 1)
+		}
+
+		if acc.Type == AccountSavings && acc.DeletedAt == nil && next.Before(today) {
+			copied := *acc
+			accounts = append(accounts, &copied)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ID < accounts[j].ID
+	})
+
+	return accounts, nil
+}
+
+func (s *MemoryStore) AccrueInterest(ctx context.Context, number int64, day time.Time, micros int64, post bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return accountNotFoundError("account with number %d not found", number)
+	}
+
+	if acc.InterestAccruedThrough != nil && !acc.InterestAccruedThrough.Before(day) {
+		return nil
+	}
+
+	acc.Accrue(micros)
+
+	if post && acc.AccruedInterest > 0 {
+		entry := s.beginJournalEntry(JournalInterest, day.AddDate(0, 0, 1))
+		s.applyTransaction(entry, acc, TransactionInterest, acc.AccruedInterest, nil)
+		entry.post(LedgerInterest, nil, acc.Currency, -acc.AccruedInterest)
+
+		if err := s.commitJournalEntry(entry); err != nil {
+			return err
+		}
+
+		acc.AccruedInterest = 0
+	}
+
+	acc.InterestAccruedThrough = &day
+	acc.Version++
+
+	return nil
+}
+
+func (s *MemoryStore) Transfer(ctx context.Context, transfer *Transfer) error {
+	from, to := transfer.FromAccount, transfer.ToAccount
+
+	if transfer.Amount <= 0 || transfer.ToAmount <= 0 {
+		return validationError("invalid amount %d", transfer.Amount)
+	}
+
+	if from == to {
+		return validationError("cannot transfer to the same account")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fromAcc, toAcc := s.accountByNumber(from), s.accountByNumber(to)
+	var err error
+
+	switch {
+	case fromAcc == nil:
+		err = accountNotFoundError("account with number %d not found", from)
+	case toAcc == nil:
+		err = accountNotFoundError("account with number %d not found", to)
+	default:
+		err = s.transfer(fromAcc, toAcc, transfer)
+	}
+
+	if err != nil {
+		s.insertFailedTransfer(transfer, err)
+	}
+
+	return err
+}
+
+// insertFailedTransfer mirrors a Postgres transfer recorded as pending that
+// then failed with cause.
+func (s *MemoryStore) insertFailedTransfer(transfer *Transfer, cause error) {
+	transfer.ID = s.nextID("transfer")
+	transfer.Status = TransferFailed
+	transfer.FailureReason = publicErrorMessage(cause)
+	transfer.CreatedAt = time.Now().UTC()
+
+	stored := *transfer
+	s.transfers = append(s.transfers, &stored)
+}
+
+// transfer mirrors transferLocked.
+func (s *MemoryStore) transfer(fromAcc, toAcc *Account, transfer *Transfer) error {
+	from, to := transfer.FromAccount, transfer.ToAccount
+
+	if fromAcc.Currency != transfer.Currency || toAcc.Currency != transfer.ToCurrency {
+		return conflictError("transfer currency does not match account currency")
+	}
+
+	if err := toAcc.CheckActive(); err != nil {
+		return err
+	}
+
+	transfer.CreatedAt = time.Now().UTC()
+
+	entry := s.beginJournalEntry(JournalTransfer, transfer.CreatedAt)
+
+	if _, err := s.debit(entry, fromAcc, TransactionTransferOut, transfer.Amount, &to); err != nil {
+		return err
+	}
+
+	postFXLines(entry, transfer)
+	s.applyTransaction(entry, toAcc, TransactionTransferIn, transfer.ToAmount, &from)
+	s.referenceTransferEntries(entry, transfer.TransferReference)
+
+	if err := s.commitJournalEntry(entry); err != nil {
+		return err
+	}
+
+	return s.insertTransfer(transfer)
+}
+
+// referenceTransferEntries mirrors the Postgres referenceTransferEntries.
+func (s *MemoryStore) referenceTransferEntries(entry *JournalEntry, ref TransferReference) {
+	for _, t := range s.transactions {
+		if t.JournalID == entry.ID && (t.Type == TransactionTransferOut || t.Type == TransactionTransferIn) {
+			t.TransferReference = ref
+		}
+	}
+}
+
+// insertTransfer mirrors the Postgres insertTransfer.
+func (s *MemoryStore) insertTransfer(transfer *Transfer) error {
+	transfer.ID = s.nextID("transfer")
+	transfer.Status = TransferCompleted
+
+	msg, err := newTransferOutboxMessage(transfer)
+
+	if err != nil {
+		return err
+	}
+
+	msg.ID = int64(s.nextID("outbox"))
+	s.outbox = append(s.outbox, msg)
+
+	stored := *transfer
+	s.transfers = append(s.transfers, &stored)
+
+	return nil
+}
+
+func (s *MemoryStore) GetTransfer(ctx context.Context, id int) (*Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, transfer := range s.transfers {
+		if transfer.ID == id {
+			copied := *transfer
+			return &copied, nil
+		}
+	}
+
+	return nil, notFoundError("transfer %d not found", id)
+}
+
+func (s *MemoryStore) GetTransfers(ctx context.Context, filter *TransferFilter) ([]*Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfers := []*Transfer{}
+
+	for _, transfer := range s.transfers {
+		if filter.Matches(transfer) {
+			copied := *transfer
+			transfers = append(transfers, &copied)
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		if !transfers[i].CreatedAt.Equal(transfers[j].CreatedAt) {
+			return transfers[i].CreatedAt.After(transfers[j].CreatedAt)
+		}
+
+		return transfers[i].ID > transfers[j].ID
+	})
+
+	return page(transfers, filter.Limit, filter.Offset), nil
+}
+
+func (s *MemoryStore) ReverseTransfer(ctx context.Context, id int, now time.Time) (*Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transfer *Transfer
+
+	for _, t := range s.transfers {
+		if t.ID == id {
+			transfer = t
+		}
+	}
+
+	if transfer == nil {
+		return nil, notFoundError("transfer %d not found", id)
+	}
+
+	if transfer.Status != TransferCompleted {
+		return nil, conflictError("transfer %d is %s", id, transfer.Status)
+	}
+
+	from, to := transfer.FromAccount, transfer.ToAccount
+	fromAcc, toAcc := s.accountByNumber(from), s.accountByNumber(to)
+
+	if fromAcc == nil || toAcc == nil {
+		return nil, accountNotFoundError("account not found")
+	}
+
+	if err := fromAcc.CheckActive(); err != nil {
+		return nil, err
+	}
+
+	entry := s.beginJournalEntry(JournalReversal, now)
+	s.applyTransaction(entry, toAcc, TransactionReversal, -transfer.ToAmount, &from)
+	postFXReversalLines(entry, transfer)
+	s.applyTransaction(entry, fromAcc, TransactionReversal, transfer.Amount, &to)
+
+	if err := s.commitJournalEntry(entry); err != nil {
+		return nil, err
+	}
+
+	transfer.Status = TransferReversed
+	transfer.ReversedAt = &now
+	copied := *transfer
+
+	return &copied, nil
+}
+
+// checkpoint returns a function that undoes the transfers made after it, like
+// a rolled back Postgres transaction.
+func (s *MemoryStore) checkpoint() func() {
+	accounts := map[int]Account{}
+
+	for id, acc := range s.accounts {
+		accounts[id] = *acc
+	}
+
+	transactions, events, journal, transfers, outbox := len(s.transactions), len(s.events), len(s.journal), len(s.transfers), len(s.outbox)
+
+	return func() {
+		for id, acc := range accounts {
+			*s.accounts[id] = acc
+		}
+
+		s.transactions = s.transactions[:transactions]
+		s.events = s.events[:events]
+		s.journal = s.journal[:journal]
+		s.transfers = s.transfers[:transfers]
+		s.outbox = s.outbox[:outbox]
+	}
+}
+
+func (s *MemoryStore) TransferBatch(ctx context.Context, batch *TransferBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := map[int64]*Account{}
+
+	for _, number := range append([]int64{batch.FromAccount}, batchDestinations(batch)...) {
+		if accounts[number] = s.accountByNumber(number); accounts[number] == nil {
+			return accountNotFoundError("account with number %d not found", number)
+		}
+	}
+
+	rollback := s.checkpoint()
+
+	for i, item := range batch.Items {
+		err := s.transfer(accounts[batch.FromAccount], accounts[item.ToAccount], item.transfer)
+
+		var httpErr *HTTPError
+
+		if err != nil && (batch.Mode == TransferBatchAtomic || !errors.As(err, &httpErr)) {
+			rollback()
+			return batchItemError(i, err)
+		}
+
+		if err != nil {
+			item.fail(httpErr)
+		} else {
+			item.complete()
+		}
+	}
+
+	batch.ID = s.nextID("transfer_batch")
+	batch.CreatedAt = time.Now().UTC()
+	batch.count()
+	s.batches[batch.ID] = copyTransferBatch(batch)
+
+	return nil
+}
+
+func (s *MemoryStore) GetTransferBatch(ctx context.Context, id int) (*TransferBatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, ok := s.batches[id]
+
+	if !ok {
+		return nil, notFoundError("transfer batch %d not found", id)
+	}
+
+	return copyTransferBatch(batch), nil
+}
+
+func batchDestinations(batch *TransferBatch) []int64 {
+	numbers := make([]int64, len(batch.Items))
+
+	for i, item := range batch.Items {
+		numbers[i] = item.ToAccount
+	}
+
+	return numbers
+}
+
+func copyTransferBatch(batch *TransferBatch) *TransferBatch {
+	copied := *batch
+	copied.Items = make([]*TransferBatchItem, len(batch.Items))
+
+	for i, item := range batch.Items {
+		copiedItem := *item
+		copiedItem.transfer = nil
+		copied.Items[i] = &copiedItem
+	}
+
+	return &copied
+}
+
+func (s *MemoryStore) CreateTransferQuote(ctx context.Context, quote *TransferQuote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quote.ID = s.nextID("transfer_quote")
+
+	stored := *quote
+	s.transferQuotes[quote.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) UseTransferQuote(ctx context.Context, id int, fromAccount int64, now time.Time) (*TransferQuote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quote, ok := s.transferQuotes[id]
+
+	if !ok || quote.FromAccount != fromAccount {
+		return nil, notFoundError("quote %d not found", id)
+	}
+
+	if err := quote.CheckUse(now); err != nil {
+		return nil, err
+	}
+
+	quote.UsedAt = &now
+	used := *quote
+
+	return &used, nil
+}
+
+func (s *MemoryStore) CreatePaymentRequest(ctx context.Context, request *PaymentRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request.ID = s.nextID("payment_request")
+
+	stored := *request
+	s.paymentRequests[request.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetPaymentRequest(ctx context.Context, id int, now time.Time) (*PaymentRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.paymentRequests[id]
+
+	if !ok {
+		return nil, notFoundError("payment request %d not found", id)
+	}
+
+	copied := *request
+	copied.Expire(now)
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetPaymentRequests(ctx context.Context, number int64, incoming bool, status PaymentRequestStatus, now time.Time, limit, offset int) ([]*PaymentRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := []*PaymentRequest{}
+
+	for _, request := range s.paymentRequests {
+		if (incoming && request.FromAccount != number) || (!incoming && request.ToAccount != number) {
+			continue
+		}
+
+		copied := *request
+		copied.Expire(now)
+
+		if status == "" || copied.Status == status {
+			requests = append(requests, &copied)
+		}
+	}
+
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID > requests[j].ID })
+
+	return page(requests, limit, offset), nil
+}
+
+func (s *MemoryStore) DecidePaymentRequest(ctx context.Context, id int, fromAccount int64, status PaymentRequestStatus, now time.Time) (*PaymentRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, err := s.pendingPaymentRequest(id, fromAccount, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = status
+	request.DecidedAt = &now
+	copied := *request
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) AcceptPaymentRequest(ctx context.Context, id int, fromAccount int64, transfer *Transfer, now time.Time) (*PaymentRequest, error) {
+	if transfer.Amount <= 0 || transfer.ToAmount <= 0 {
+		return nil, validationError("invalid amount %d", transfer.Amount)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, err := s.pendingPaymentRequest(id, fromAccount, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fromAcc, toAcc := s.accountByNumber(transfer.FromAccount), s.accountByNumber(transfer.ToAccount)
+
+	switch {
+	case fromAcc == nil:
+		return nil, accountNotFoundError("account with number %d not found", transfer.FromAccount)
+	case toAcc == nil:
+		return nil, accountNotFoundError("account with number %d not found", transfer.ToAccount)
+	}
+
+	if err := s.transfer(fromAcc, toAcc, transfer); err != nil {
+		return nil, err
+	}
+
+	transferID := transfer.ID
+	request.Status = PaymentRequestAccepted
+	request.TransferID = &transferID
+	request.DecidedAt = &now
+	copied := *request
+
+	return &copied, nil
+}
+
+// pendingPaymentRequest returns request id, which fromAccount was asked to
+// pay, after checking it can still be decided at now.
+func (s *MemoryStore) pendingPaymentRequest(id int, fromAccount int64, now time.Time) (*PaymentRequest, error) {
+	request, ok := s.paymentRequests[id]
+
+	if !ok || request.FromAccount != fromAccount {
+		return nil, notFoundError("payment request %d not found", id)
+	}
+
+	copied := *request
+
+	if err := copied.CheckDecision(now); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func (s *MemoryStore) AuthorizeTransfer(ctx context.Context, hold *Hold) error {
+	if hold.Amount <= 0 || hold.ToAmount <= 0 {
+		return validationError("invalid amount %d", hold.Amount)
+	}
+
+	if hold.FromAccount == hold.ToAccount {
+		return validationError("cannot transfer to the same account")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := map[int64]*Account{}
+
+	for _, number := range []int64{hold.FromAccount, hold.ToAccount} {
+		if accounts[number] = s.accountByNumber(number); accounts[number] == nil {
+			return accountNotFoundError("account with number %d not found", number)
+		}
+	}
+
+	if err := checkHold(accounts, hold); err != nil {
+		return err
+	}
+
+	accounts[hold.FromAccount].HeldBalance += hold.Amount
+	accounts[hold.FromAccount].Version++
+	hold.ID = s.nextID("hold")
+
+	stored := *hold
+	s.holds[hold.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) CaptureHold(ctx context.Context, id int, fromAccount int64, now time.Time) (*Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hold, ok := s.holds[id]
+
+	if !ok || hold.FromAccount != fromAccount {
+		return nil, notFoundError("hold %d not found", id)
+	}
+
+	if err := hold.CheckCapture(now); err != nil {
+		return nil, err
+	}
+
+	fromAcc, toAcc := s.accountByNumber(hold.FromAccount), s.accountByNumber(hold.ToAccount)
+	transfer := hold.Transfer()
+
+	// release the hold first so the debit can use the reserved amount, and
+	// restore it if the transfer fails like the Postgres rollback would
+	fromAcc.HeldBalance -= hold.Amount
+
+	if err := s.transfer(fromAcc, toAcc, transfer); err != nil {
+		fromAcc.HeldBalance += hold.Amount
+		return nil, err
+	}
+
+	fromAcc.Version++
+
+	hold.Status = HoldCaptured
+	hold.TransferID = &transfer.ID
+
+	return transfer, nil
+}
+
+func (s *MemoryStore) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]*Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := []*Hold{}
+
+	for _, hold := range s.holds {
+		if hold.Status == HoldAuthorized && !hold.ExpiresAt.After(now) {
+			expired = append(expired, hold)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].ExpiresAt.Before(expired[j].ExpiresAt)
+	})
+
+	expired = page(expired, limit, 0)
+	holds := make([]*Hold, len(expired))
+
+	for i, hold := range expired {
+		acc := s.accountByNumber(hold.FromAccount)
+		acc.HeldBalance -= hold.Amount
+		acc.Version++
+		hold.Status = HoldExpired
+
+		copied := *hold
+		holds[i] = &copied
+	}
+
+	return holds, nil
+}
+
+func (s *MemoryStore) CreateCard(ctx context.Context, card *Card) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card.ID = s.nextID("card")
+
+	stored := *card
+	s.cards[card.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetCards(ctx context.Context, number int64) ([]*Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cards := []*Card{}
+
+	for _, card := range s.cards {
+		if card.AccountNumber == number {
+			copied := *card
+			cards = append(cards, &copied)
+		}
+	}
+
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].ID < cards[j].ID
+	})
+
+	return cards, nil
+}
+
+func (s *MemoryStore) UpdateCardStatus(ctx context.Context, id int, number int64, status CardStatus) (*Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card, ok := s.cards[id]
+
+	if !ok || card.AccountNumber != number {
+		return nil, notFoundError("card %d not found", id)
+	}
+
+	card.Status = status
+
+	copied := *card
+	return &copied, nil
+}
+
+func (s *MemoryStore) AuthorizeCard(ctx context.Context, panHash string, auth *CardAuthorization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var card *Card
+
+	for _, c := range s.cards {
+		if c.PANHash == panHash {
+			card = c
+		}
+	}
+
+	if card == nil {
+		return notFoundError("card not found")
+	}
+
+	acc := s.accountByNumber(card.AccountNumber)
+
+	if acc == nil {
+		return accountNotFoundError("account with number %d not found", card.AccountNumber)
+	}
+
+	auth.decide(card, acc, auth.CreatedAt)
+
+	if auth.Status == CardAuthorizationApproved {
+		acc.HeldBalance += auth.Amount
+		acc.Version++
+	}
+
+	auth.ID = s.nextID("card_authorization")
+
+	stored := *auth
+	s.cardAuthorizations[auth.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) ExpireCardAuthorizations(ctx context.Context, now time.Time, limit int) ([]*CardAuthorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := []*CardAuthorization{}
+
+	for _, auth := range s.cardAuthorizations {
+		if auth.Status == CardAuthorizationApproved && !auth.ExpiresAt.After(now) {
+			expired = append(expired, auth)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].ExpiresAt.Before(expired[j].ExpiresAt)
+	})
+
+	expired = page(expired, limit, 0)
+	auths := make([]*CardAuthorization, len(expired))
+
+	for i, auth := range expired {
+		acc := s.accountByNumber(auth.AccountNumber)
+		acc.HeldBalance -= auth.Amount
+		acc.Version++
+		auth.Status = CardAuthorizationExpired
+
+		copied := *auth
+		auths[i] = &copied
+	}
+
+	return auths, nil
+}
+
+func (s *MemoryStore) CreateExternalTransfer(ctx context.Context, transfer *ExternalTransfer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(transfer.AccountNumber)
+
+	if acc == nil {
+		return accountNotFoundError("account with number %d not found", transfer.AccountNumber)
+	}
+
+	if err := checkExternalTransfer(acc, transfer); err != nil {
+		return err
+	}
+
+	acc.HeldBalance += transfer.Amount
+	acc.Version++
+	transfer.ID = s.nextID("external_transfer")
+
+	stored := *transfer
+	s.externalTransfers[transfer.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetExternalTransfers(ctx context.Context, number int64, limit, offset int) ([]*ExternalTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfers := []*ExternalTransfer{}
+
+	for _, t := range s.externalTransfers {
+		if t.AccountNumber == number {
+			copied := *t
+			transfers = append(transfers, &copied)
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].ID > transfers[j].ID
+	})
+
+	return page(transfers, limit, offset), nil
+}
+
+func (s *MemoryStore) SubmitExternalTransfers(ctx context.Context, now time.Time, limit int) ([]*ExternalTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := s.externalTransfersWhere(func(t *ExternalTransfer) bool {
+		return t.Status == ExternalTransferPending
+	}, limit)
+
+	for i, t := range due {
+		t.Status = ExternalTransferSubmitted
+		t.SubmittedAt = &now
+
+		copied := *t
+		due[i] = &copied
+	}
+
+	return due, nil
+}
+
+func (s *MemoryStore) SettleExternalTransfers(ctx context.Context, submittedBefore, now time.Time, limit int) ([]*ExternalTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := s.externalTransfersWhere(func(t *ExternalTransfer) bool {
+		return t.Status == ExternalTransferSubmitted && !t.SubmittedAt.After(submittedBefore)
+	}, limit)
+
+	for i, t := range due {
+		acc := s.accountByNumber(t.AccountNumber)
+		acc.HeldBalance -= t.Amount
+
+		entry := s.beginJournalEntry(JournalExternalTransfer, now)
+		s.applyTransaction(entry, acc, TransactionExternalOut, -t.Amount, nil)
+		entry.post(LedgerClearing, nil, t.Currency, t.Amount)
+
+		if err := s.commitJournalEntry(entry); err != nil {
+			return nil, err
+		}
+
+		t.settle(entry.ID, now)
+
+		copied := *t
+		due[i] = &copied
+	}
+
+	return due, nil
+}
+
+func (s *MemoryStore) ReturnExternalTransfer(ctx context.Context, id int, code, reason string, now time.Time) (*ExternalTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.externalTransfers[id]
+
+	if !ok {
+		return nil, notFoundError("external transfer %d not found", id)
+	}
+
+	if err := t.CheckReturn(); err != nil {
+		return nil, err
+	}
+
+	acc := s.accountByNumber(t.AccountNumber)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", t.AccountNumber)
+	}
+
+	var journalID *int
+
+	if t.Status == ExternalTransferSettled {
+		entry := s.beginJournalEntry(JournalExternalReturn, now)
+		s.applyTransaction(entry, acc, TransactionExternalReturn, t.Amount, nil)
+		entry.post(LedgerClearing, nil, t.Currency, -t.Amount)
+
+		if err := s.commitJournalEntry(entry); err != nil {
+			return nil, err
+		}
+
+		journalID = &entry.ID
+	} else {
+		acc.HeldBalance -= t.Amount
+		acc.Version++
+	}
+
+	t.markReturned(code, reason, journalID, now)
+
+	copied := *t
+	return &copied, nil
+}
+
+// externalTransfersWhere returns up to limit stored transfers matching
+// match, oldest first.
+func (s *MemoryStore) externalTransfersWhere(match func(*ExternalTransfer) bool, limit int) []*ExternalTransfer {
+	transfers := []*ExternalTransfer{}
+
+	for _, t := range s.externalTransfers {
+		if match(t) {
+			transfers = append(transfers, t)
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].ID < transfers[j].ID
+	})
+
+	return page(transfers, limit, 0)
+}
+
+func (s *MemoryStore) ImportTransactions(ctx context.Context, number int64, rows []*ImportRow, dryRun bool, now time.Time) (*ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	from, to := importPeriod(rows)
+	ledger := []*Transaction{}
+
+	for _, t := range s.transactions {
+		if t.AccountNumber == number && t.Type != TransactionImport && !t.CreatedAt.Before(from) && t.CreatedAt.Before(to) {
+			ledger = append(ledger, t)
+		}
+	}
+
+	markDuplicates(rows, s.imported[number], ledger)
+
+	if err := checkImport(acc, rows); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return NewImportResult(rows, dryRun), nil
+	}
+
+	if s.imported[number] == nil {
+		s.imported[number] = map[string]int{}
+	}
+
+	for _, row := range rows {
+		if row.Status != ImportRowNew {
+			continue
+		}
+
+		entry := s.beginJournalEntry(JournalImport, now)
+		transaction := s.applyTransaction(entry, acc, TransactionImport, row.Amount, nil)
+		entry.post(LedgerImport, nil, acc.Currency, -row.Amount)
+
+		if err := s.commitJournalEntry(entry); err != nil {
+			return nil, err
+		}
+
+		s.imported[number][row.fingerprint] = transaction.ID
+
+		row.Status = ImportRowImported
+		row.TransactionID = &transaction.ID
+	}
+
+	return NewImportResult(rows, dryRun), nil
+}
+
+func (s *MemoryStore) CreateBeneficiary(ctx context.Context, b *Beneficiary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.beneficiaries {
+		if existing.Owner == b.Owner && existing.AccountNumber == b.AccountNumber {
+			return conflictError("account %d is already a beneficiary", b.AccountNumber)
+		}
+	}
+
+	b.ID = s.nextID("beneficiary")
+
+	stored := *b
+	s.beneficiaries[b.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetBeneficiaries(ctx context.Context, owner int64) ([]*Beneficiary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	beneficiaries := []*Beneficiary{}
+
+	for _, b := range s.beneficiaries {
+		if b.Owner == owner {
+			copied := *b
+			beneficiaries = append(beneficiaries, &copied)
+		}
+	}
+
+	sort.Slice(beneficiaries, func(i, j int) bool {
+		return beneficiaries[i].ID < beneficiaries[j].ID
+	})
+
+	return beneficiaries, nil
+}
+
+func (s *MemoryStore) GetBeneficiary(ctx context.Context, id int, owner int64) (*Beneficiary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.beneficiaries[id]
+
+	if !ok || b.Owner != owner {
+		return nil, notFoundError("beneficiary %d not found", id)
+	}
+
+	copied := *b
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) DeleteBeneficiary(ctx context.Context, id int, owner int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.beneficiaries[id]; !ok || b.Owner != owner {
+		return notFoundError("beneficiary %d not found", id)
+	}
+
+	delete(s.beneficiaries, id)
+
+	return nil
+}
+
+func (s *MemoryStore) CreateAlias(ctx context.Context, alias *Alias) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.aliases {
+		if existing.Value != alias.Value {
+			continue
+		}
+
+		if existing.VerifiedAt != nil {
+			return conflictError("alias %s is already in use", alias.Value)
+		}
+
+		if existing.AccountNumber == alias.AccountNumber {
+			existing.CodeHash = alias.CodeHash
+			existing.CodeExpiresAt = alias.CodeExpiresAt
+			existing.Attempts = 0
+
+			alias.ID = existing.ID
+			alias.CreatedAt = existing.CreatedAt
+
+			return nil
+		}
+	}
+
+	alias.ID = s.nextID("alias")
+
+	stored := *alias
+	s.aliases[alias.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetAliases(ctx context.Context, number int64) ([]*Alias, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	aliases := []*Alias{}
+
+	for _, a := range s.aliases {
+		if a.AccountNumber == number {
+			copied := *a
+			aliases = append(aliases, &copied)
+		}
+	}
+
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].ID < aliases[j].ID
+	})
+
+	return aliases, nil
+}
+
+func (s *MemoryStore) VerifyAlias(ctx context.Context, id int, number int64, codeHash string, now time.Time) (*Alias, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alias, ok := s.aliases[id]
+
+	if !ok || alias.AccountNumber != number {
+		return nil, notFoundError("alias %d not found", id)
+	}
+
+	if err := alias.checkCode(codeHash, now); err != nil {
+		return nil, err
+	}
+
+	for _, existing := range s.aliases {
+		if existing.Value == alias.Value && existing.VerifiedAt != nil {
+			return nil, conflictError("alias %s is already in use", alias.Value)
+		}
+	}
+
+	alias.VerifiedAt = &now
+	copied := *alias
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) DeleteAlias(ctx context.Context, id int, number int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.aliases[id]; !ok || a.AccountNumber != number {
+		return notFoundError("alias %d not found", id)
+	}
+
+	delete(s.aliases, id)
+
+	return nil
+}
+
+func (s *MemoryStore) ResolveAlias(ctx context.Context, value string) (*Alias, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.aliases {
+		if a.Value == value && a.VerifiedAt != nil {
+			copied := *a
+			return &copied, nil
+		}
+	}
+
+	return nil, notFoundError("alias %s not found", value)
+}
+
+// contactKey is the kind contact detail of the number account.
+type contactKey struct {
+	number int64
+	kind   ContactKind
+}
+
+func (s *MemoryStore) SetContactDetail(ctx context.Context, detail *ContactDetail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accountByNumber(detail.AccountNumber) == nil {
+		return accountNotFoundError("account with number %d not found", detail.AccountNumber)
+	}
+
+	stored := *detail
+	s.contacts[contactKey{detail.AccountNumber, detail.Kind}] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetContactDetails(ctx context.Context, number int64) ([]*ContactDetail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	details := []*ContactDetail{}
+
+	for _, d := range s.contacts {
+		if d.AccountNumber == number {
+			copied := *d
+			details = append(details, &copied)
+		}
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].Kind < details[j].Kind
+	})
+
+	return details, nil
+}
+
+func (s *MemoryStore) VerifyContactDetail(ctx context.Context, number int64, kind ContactKind, codeHash string, now time.Time) (*ContactDetail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	detail, ok := s.contacts[contactKey{number, kind}]
+
+	if !ok {
+		return nil, notFoundError("no %s set", kind)
+	}
+
+	if err := detail.checkCode(codeHash, now); err != nil {
+		return nil, err
+	}
+
+	detail.VerifiedAt = &now
+	copied := *detail
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) DeleteContactDetail(ctx context.Context, number int64, kind ContactKind) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := contactKey{number, kind}
+
+	if _, ok := s.contacts[key]; !ok {
+		return notFoundError("no %s set", kind)
+	}
+
+	delete(s.contacts, key)
+
+	return nil
+}
+
+func (s *MemoryStore) RecordAudit(ctx context.Context, entry *AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = s.nextID("audit")
+	copied := *entry
+	s.auditLog = append(s.auditLog, &copied)
+
+	return nil
+}
+
+func (s *MemoryStore) GetAuditLog(ctx context.Context, limit, offset int) ([]*AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []*AuditEntry{}
+
+	for i := len(s.auditLog) - 1; i >= 0; i-- {
+		copied := *s.auditLog[i]
+		entries = append(entries, &copied)
+	}
+
+	return page(entries, limit, offset), nil
+}
+
+func (s *MemoryStore) LinkIdentity(ctx context.Context, identity *ExternalIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, linked := range s.identities {
+		if linked.Issuer == identity.Issuer && (linked.Subject == identity.Subject || linked.AccountNumber == identity.AccountNumber) {
+			return conflictError("identity already linked")
+		}
+	}
+
+	copied := *identity
+	s.identities = append(s.identities, &copied)
+
+	return nil
+}
+
+func (s *MemoryStore) GetIdentity(ctx context.Context, issuer, subject string) (*ExternalIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, identity := range s.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			copied := *identity
+			return &copied, nil
+		}
+	}
+
+	return nil, notFoundError("identity not found")
+}
+
+func (s *MemoryStore) SubmitKYC(ctx context.Context, kyc *KYC) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(kyc.AccountNumber)
+
+	if acc == nil {
+		return accountNotFoundError("account with number %d not found", kyc.AccountNumber)
+	}
+
+	if acc.KYCStatus == KYCVerified {
+		return conflictError("account with number %d is already verified", kyc.AccountNumber)
+	}
+
+	acc.KYCStatus = KYCPending
+	acc.Version++
+	kyc.Status = KYCPending
+	kyc.RejectionReason = ""
+	kyc.ReviewedAt = nil
+
+	copied := *kyc
+	s.kyc[kyc.AccountNumber] = &copied
+
+	return nil
+}
+
+func (s *MemoryStore) GetKYC(ctx context.Context, number int64) (*KYC, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getKYC(number)
+}
+
+func (s *MemoryStore) getKYC(number int64) (*KYC, error) {
+	acc := s.accountByNumber(number)
+	kyc, ok := s.kyc[number]
+
+	if acc == nil || !ok {
+		return nil, accountNotFoundError("account with number %d has not submitted KYC details", number)
+	}
+
+	copied := *kyc
+	copied.Status = acc.KYCStatus
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetPendingKYC(ctx context.Context, limit, offset int) ([]*KYC, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	submissions := []*KYC{}
+
+	for number := range s.kyc {
+		if kyc, err := s.getKYC(number); err == nil && kyc.Status == KYCPending {
+			submissions = append(submissions, kyc)
+		}
+	}
+
+	sort.Slice(submissions, func(i, j int) bool {
+		a, b := submissions[i], submissions[j]
+
+		if !a.SubmittedAt.Equal(b.SubmittedAt) {
+			return a.SubmittedAt.Before(b.SubmittedAt)
+		}
+
+		return a.AccountNumber < b.AccountNumber
+	})
+
+	return page(submissions, limit, offset), nil
+}
+
+func (s *MemoryStore) ReviewKYC(ctx context.Context, number int64, status KYCStatus, reason string, reviewedAt time.Time) (*KYC, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kyc, err := s.getKYC(number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if kyc.Status != KYCPending {
+		return nil, conflictError("KYC of account with number %d is already %s", number, kyc.Status)
+	}
+
+	stored := s.kyc[number]
+	stored.RejectionReason = reason
+	stored.ReviewedAt = &reviewedAt
+	acc := s.accountByNumber(number)
+	acc.KYCStatus = status
+	acc.Version++
+
+	return s.getKYC(number)
+}
+
+func (s *MemoryStore) AddAccountOwner(ctx context.Context, owner *AccountOwner) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.owners {
+		if existing.AccountNumber == owner.AccountNumber && existing.OwnerNumber == owner.OwnerNumber {
+			return conflictError("account with number %d already co-owns account with number %d", owner.OwnerNumber, owner.AccountNumber)
+		}
+	}
+
+	copied := *owner
+	s.owners = append(s.owners, &copied)
+
+	return nil
+}
+
+func (s *MemoryStore) RemoveAccountOwner(ctx context.Context, number, owner int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.owners {
+		if existing.AccountNumber == number && existing.OwnerNumber == owner {
+			s.owners = append(s.owners[:i], s.owners[i+1:]...)
+			return nil
+		}
+	}
+
+	return accountNotFoundError("account with number %d does not co-own account with number %d", owner, number)
+}
+
+func (s *MemoryStore) GetAccountOwners(ctx context.Context, number int64) ([]*AccountOwner, error) {
+	return s.accountOwners(func(owner *AccountOwner) bool { return owner.AccountNumber == number }), nil
+}
+
+func (s *MemoryStore) GetOwnedAccounts(ctx context.Context, owner int64) ([]*AccountOwner, error) {
+	return s.accountOwners(func(o *AccountOwner) bool { return o.OwnerNumber == owner }), nil
+}
+
+func (s *MemoryStore) accountOwners(match func(*AccountOwner) bool) []*AccountOwner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owners := []*AccountOwner{}
+
+	for _, owner := range s.owners {
+		acc := s.accountByNumber(owner.AccountNumber)
+
+		if acc == nil || !match(owner) {
+			continue
+		}
+
+		copied := *owner
+		copied.AccountID = acc.ID
+		owners = append(owners, &copied)
+	}
+
+	return owners
+}
+
+func (s *MemoryStore) CreateTransferApproval(ctx context.Context, approval *TransferApproval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	approval.ID = s.nextID("transfer_approval")
+
+	copied := *approval
+	s.approvals[approval.ID] = &copied
+
+	return nil
+}
+
+func (s *MemoryStore) GetTransferApprovals(ctx context.Context, number int64, limit, offset int) ([]*TransferApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	approvals := []*TransferApproval{}
+
+	for _, approval := range s.approvals {
+		if approval.FromAccount == number {
+			copied := *approval
+			approvals = append(approvals, &copied)
+		}
+	}
+
+	sort.Slice(approvals, func(i, j int) bool { return approvals[i].ID > approvals[j].ID })
+
+	return page(approvals, limit, offset), nil
+}
+
+func (s *MemoryStore) DecideTransferApproval(ctx context.Context, id int, number int64, status TransferApprovalStatus, decidedBy int64, decidedByUser int, decidedAt time.Time) (*TransferApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.approvals[id]
+
+	if !ok || stored.FromAccount != number {
+		return nil, notFoundError("transfer approval %d not found", id)
+	}
+
+	if err := stored.CheckDecision(status, decidedBy, decidedByUser); err != nil {
+		return nil, err
+	}
+
+	stored.Status = status
+	stored.DecidedBy = &decidedBy
+	stored.DecidedByUser = decidedByUser
+	stored.DecidedAt = &decidedAt
+
+	copied := *stored
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) UpdateTransferApproval(ctx context.Context, approval *TransferApproval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.approvals[approval.ID]
+
+	if !ok {
+		return notFoundError("transfer approval %d not found", approval.ID)
+	}
+
+	stored.Status = approval.Status
+	stored.TransferID = approval.TransferID
+	stored.Error = approval.Error
+
+	return nil
+}
+
+func (s *MemoryStore) CreateAdminApproval(ctx context.Context, approval *AdminApproval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accountByNumber(approval.AccountNumber) == nil {
+		return accountNotFoundError("account with number %d not found", approval.AccountNumber)
+	}
+
+	approval.ID = s.nextID("admin_approval")
+	s.adminApprovals[approval.ID] = copyAdminApproval(approval)
+
+	return nil
+}
+
+func (s *MemoryStore) GetAdminApprovals(ctx context.Context, status AdminApprovalStatus, limit, offset int) ([]*AdminApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	approvals := []*AdminApproval{}
+
+	for _, approval := range s.adminApprovals {
+		if status == "" || approval.Status == status {
+			approvals = append(approvals, copyAdminApproval(approval))
+		}
+	}
+
+	sort.Slice(approvals, func(i, j int) bool { return approvals[i].ID < approvals[j].ID })
+
+	return page(approvals, limit, offset), nil
+}
+
+func (s *MemoryStore) DecideAdminApproval(ctx context.Context, id int, status AdminApprovalStatus, decidedBy int64, decidedAt time.Time) (*AdminApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.adminApprovals[id]
+
+	if !ok {
+		return nil, notFoundError("admin approval %d not found", id)
+	}
+
+	if err := stored.CheckDecision(status, decidedBy); err != nil {
+		return nil, err
+	}
+
+	stored.Status = status
+	stored.DecidedBy = &decidedBy
+	stored.DecidedAt = &decidedAt
+
+	return copyAdminApproval(stored), nil
+}
+
+func (s *MemoryStore) UpdateAdminApproval(ctx context.Context, approval *AdminApproval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.adminApprovals[approval.ID]
+
+	if !ok {
+		return notFoundError("admin approval %d not found", approval.ID)
+	}
+
+	stored.Status = approval.Status
+	stored.TransactionID = approval.TransactionID
+	stored.Error = approval.Error
+
+	return nil
+}
+
+// copyAdminApproval copies the limits along with the approval, so callers
+// can't change the stored ones.
+func copyAdminApproval(approval *AdminApproval) *AdminApproval {
+	copied := *approval
+
+	if approval.Limits != nil {
+		limits := *approval.Limits
+		copied.Limits = &limits
+	}
+
+	return &copied
+}
+
+func (s *MemoryStore) GetNotificationPreferences(ctx context.Context, number int64) (*NotificationPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, ok := s.notificationPreferences[number]
+
+	if !ok {
+		return &NotificationPreferences{AccountNumber: number, Channels: []NotificationChannel{}}, nil
+	}
+
+	copied := *prefs
+	copied.Channels = slices.Clone(prefs.Channels)
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) SaveNotificationPreferences(ctx context.Context, prefs *NotificationPreferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accountByNumber(prefs.AccountNumber) == nil {
+		return accountNotFoundError("account %d not found", prefs.AccountNumber)
+	}
+
+	copied := *prefs
+	copied.Channels = slices.Clone(prefs.Channels)
+	s.notificationPreferences[prefs.AccountNumber] = &copied
+
+	return nil
+}
+
+func (s *MemoryStore) EnqueueNotificationDeliveries(ctx context.Context, deliveries []*NotificationDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range deliveries {
+		d.ID = s.nextID("notification_delivery")
+
+		copied := *d
+		s.notificationDeliveries = append(s.notificationDeliveries, &copied)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ClaimDueNotificationDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*NotificationDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*NotificationDelivery{}
+
+	for _, d := range s.notificationDeliveries {
+		lockedUntil, locked := s.notificationLocks[d.ID]
+
+		if d.Status == NotificationDeliveryPending && !d.NextAttemptAt.After(now) && (!locked || lockedUntil.Before(now)) {
+			due = append(due, d)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	due = page(due, limit, 0)
+	claimed := make([]*NotificationDelivery, 0, len(due))
+
+	for _, d := range due {
+		s.notificationLocks[d.ID] = leaseUntil
+
+		copied := *d
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *MemoryStore) UpdateNotificationDelivery(ctx context.Context, delivery *NotificationDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.notificationDeliveries {
+		if d.ID == delivery.ID {
+			d.Status = delivery.Status
+			d.Attempts = delivery.Attempts
+			d.NextAttemptAt = delivery.NextAttemptAt
+			d.LastError = delivery.LastError
+			d.DeliveredAt = delivery.DeliveredAt
+		}
+	}
+
+	delete(s.notificationLocks, delivery.ID)
+
+	return nil
+}
+
+func (s *MemoryStore) GetNotificationDeliveries(ctx context.Context, number int64, limit, offset int) ([]*NotificationDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := []*NotificationDelivery{}
+
+	for i := len(s.notificationDeliveries) - 1; i >= 0; i-- {
+		if d := s.notificationDeliveries[i]; d.AccountNumber == number {
+			copied := *d
+			deliveries = append(deliveries, &copied)
+		}
+	}
+
+	return page(deliveries, limit, offset), nil
+}
+
+func (s *MemoryStore) CreateLoan(ctx context.Context, loan *Loan, installments []*LoanInstallment) (*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(loan.AccountNumber)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", loan.AccountNumber)
+	}
+
+	if err := acc.CheckActive(); err != nil {
+		return nil, err
+	}
+
+	loan.ID = s.nextID("loan")
+	stored := make([]*LoanInstallment, len(installments))
+
+	for i, installment := range installments {
+		installment.LoanID = loan.ID
+
+		copied := *installment
+		stored[i] = &copied
+	}
+
+	entry := s.beginJournalEntry(JournalLoanDisbursement, loan.CreatedAt)
+	transaction := s.applyTransaction(entry, acc, TransactionLoanDisbursement, loan.Principal, nil)
+	entry.post(LedgerLoans, nil, acc.Currency, -loan.Principal)
+
+	if err := s.commitJournalEntry(entry); err != nil {
+		return nil, err
+	}
+
+	copied := *loan
+	s.loans[loan.ID] = &copied
+	s.loanInstallments[loan.ID] = stored
+
+	return transaction, nil
+}
+
+func (s *MemoryStore) GetLoan(ctx context.Context, id int) (*Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loan, ok := s.loans[id]
+
+	if !ok {
+		return nil, notFoundError("loan %d not found", id)
+	}
+
+	copied := *loan
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetLoans(ctx context.Context, number int64) ([]*Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loans := []*Loan{}
+
+	for _, loan := range s.loans {
+		if loan.AccountNumber == number {
+			copied := *loan
+			loans = append(loans, &copied)
+		}
+	}
+
+	sort.Slice(loans, func(i, j int) bool {
+		return loans[i].ID < loans[j].ID
+	})
+
+	return loans, nil
+}
+
+func (s *MemoryStore) GetLoanSchedule(ctx context.Context, id int) ([]*LoanInstallment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	installments := []*LoanInstallment{}
+
+	for _, installment := range s.loanInstallments[id] {
+		copied := *installment
+		installments = append(installments, &copied)
+	}
+
+	return installments, nil
+}
+
+func (s *MemoryStore) GetLoansDue(ctx context.Context, now time.Time, limit int) ([]*Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*Loan{}
+
+	for _, loan := range s.loans {
+		if loan.Status != LoanPaidOff && loan.NextPaymentAt != nil && !loan.NextPaymentAt.After(now) {
+			copied := *loan
+			due = append(due, &copied)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextPaymentAt.Before(*due[j].NextPaymentAt)
+	})
+
+	return page(due, limit, 0), nil
+}
+
+func (s *MemoryStore) CollectLoanRepayments(ctx context.Context, id int, now time.Time) (*Loan, []*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loan, ok := s.loans[id]
+
+	if !ok {
+		return nil, nil, notFoundError("loan %d not found", id)
+	}
+
+	transactions := []*Transaction{}
+
+	if loan.Status == LoanPaidOff {
+		copied := *loan
+		return &copied, transactions, nil
+	}
+
+	acc := s.accountByNumber(loan.AccountNumber)
+
+	if acc == nil {
+		return nil, nil, accountNotFoundError("account with number %d not found", loan.AccountNumber)
+	}
+
+	installments := s.loanInstallments[id]
+	collecting := true
+
+	for _, installment := range installments {
+		if installment.Status == LoanInstallmentPaid || installment.DueDate.After(now) {
+			continue
+		}
+
+		if !collecting || !canCollect(acc, installment) {
+			collecting = false
+			installment.Status = LoanInstallmentOverdue
+			continue
+		}
+
+		entry := s.beginJournalEntry(JournalLoanRepayment, now)
+		transaction := s.applyTransaction(entry, acc, TransactionLoanRepayment, -installment.Payment, nil)
+		entry.post(LedgerLoans, nil, acc.Currency, installment.Principal)
+		entry.post(LedgerInterest, nil, acc.Currency, installment.Interest)
+
+		if err := s.commitJournalEntry(entry); err != nil {
+			return nil, nil, err
+		}
+
+		installment.Status = LoanInstallmentPaid
+		installment.TransactionID = &transaction.ID
+		installment.PaidAt = &now
+		transactions = append(transactions, transaction)
+	}
+
+	settleLoan(loan, installments, now)
+	copied := *loan
+
+	return &copied, transactions, nil
+}
+
+func (s *MemoryStore) CreateTermDeposit(ctx context.Context, deposit *TermDeposit) (*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc := s.accountByNumber(deposit.AccountNumber)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", deposit.AccountNumber)
+	}
+
+	if err := checkTermDepositOpen(acc, deposit); err != nil {
+		return nil, err
+	}
+
+	deposit.ID = s.nextID("term_deposit")
+
+	entry := s.beginJournalEntry(JournalTermDeposit, deposit.CreatedAt)
+	transaction := s.applyTransaction(entry, acc, TransactionTermDeposit, -deposit.Principal, nil)
+	entry.post(LedgerTermDeposits, nil, acc.Currency, deposit.Principal)
+
+	if err := s.commitJournalEntry(entry); err != nil {
+		return nil, err
+	}
+
+	copied := *deposit
+	s.termDeposits[deposit.ID] = &copied
+
+	return transaction, nil
+}
+
+func (s *MemoryStore) GetTermDeposits(ctx context.Context, number int64) ([]*TermDeposit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deposits := []*TermDeposit{}
+
+	for _, deposit := range s.termDeposits {
+		if deposit.AccountNumber == number {
+			copied := *deposit
+			deposits = append(deposits, &copied)
+		}
+	}
+
+	sort.Slice(deposits, func(i, j int) bool {
+		return deposits[i].ID < deposits[j].ID
+	})
+
+	return deposits, nil
+}
+
+func (s *MemoryStore) GetTermDeposit(ctx context.Context, id int, number int64) (*TermDeposit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deposit, ok := s.termDeposits[id]
+
+	if !ok || deposit.AccountNumber != number {
+		return nil, notFoundError("term deposit %d not found", id)
+	}
+
+	copied := *deposit
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetTermDepositsDue(ctx context.Context, now time.Time, limit int) ([]*TermDeposit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*TermDeposit{}
+
+	for _, deposit := range s.termDeposits {
+		if deposit.Status == TermDepositActive && !deposit.MaturesAt.After(now) {
+			copied := *deposit
+			due = append(due, &copied)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].MaturesAt.Before(due[j].MaturesAt)
+	})
+
+	return page(due, limit, 0), nil
+}
+
+func (s *MemoryStore) CloseTermDeposit(ctx context.Context, id int, number int64, now time.Time, penaltyDays int) (*TermDeposit, *Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deposit, ok := s.termDeposits[id]
+
+	if !ok || deposit.AccountNumber != number {
+		return nil, nil, notFoundError("term deposit %d not found", id)
+	}
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	if err := checkTermDepositClose(acc, deposit, now); err != nil {
+		return nil, nil, err
+	}
+
+	closed := *deposit
+	closed.close(now, penaltyDays)
+
+	entry := s.beginJournalEntry(JournalTermDepositPayout, now)
+	transaction := s.applyTransaction(entry, acc, TransactionTermDepositPayout, closed.Payout(), nil)
+	postTermDepositPayout(entry, &closed)
+
+	if err := s.commitJournalEntry(entry); err != nil {
+		return nil, nil, err
+	}
+
+	*deposit = closed
+
+	return &closed, transaction, nil
+}
+
+func (s *MemoryStore) CreateFraudReview(ctx context.Context, review *FraudReview) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	review.ID = s.nextID("fraud_review")
+	s.fraudReviews[review.ID] = copyFraudReview(review)
+
+	return nil
+}
+
+func (s *MemoryStore) GetFraudReviews(ctx context.Context, status FraudReviewStatus, limit, offset int) ([]*FraudReview, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reviews := []*FraudReview{}
+
+	for _, review := range s.fraudReviews {
+		if status == "" || review.Status == status {
+			reviews = append(reviews, copyFraudReview(review))
+		}
+	}
+
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].ID < reviews[j].ID })
+
+	return page(reviews, limit, offset), nil
+}
+
+func (s *MemoryStore) DecideFraudReview(ctx context.Context, id int, status FraudReviewStatus, reviewedBy int64, reviewedAt time.Time) (*FraudReview, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.fraudReviews[id]
+
+	if !ok {
+		return nil, notFoundError("fraud review %d not found", id)
+	}
+
+	if err := stored.CheckDecision(); err != nil {
+		return nil, err
+	}
+
+	stored.Status = status
+	stored.ReviewedBy = &reviewedBy
+	stored.ReviewedAt = &reviewedAt
+
+	return copyFraudReview(stored), nil
+}
+
+func copyFraudReview(review *FraudReview) *FraudReview {
+	copied := *review
+	copied.Rules = append([]string{}, review.Rules...)
+
+	return &copied
+}
+
+func (s *MemoryStore) CreateAMLFlag(ctx context.Context, flag *AMLFlag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.amlFlags {
+		if stored.Rule == flag.Rule && stored.TransactionID == flag.TransactionID {
+			return conflictError("transaction %d is already flagged by the %s rule", flag.TransactionID, flag.Rule)
+		}
+	}
+
+	flag.ID = s.nextID("aml_flag")
+	s.amlFlags[flag.ID] = copyAMLFlag(flag)
+
+	return nil
+}
+
+func (s *MemoryStore) GetAMLFlags(ctx context.Context, filter *AMLFlagFilter) ([]*AMLFlag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flags := []*AMLFlag{}
+
+	for _, flag := range s.amlFlags {
+		if filter.Matches(flag) {
+			flags = append(flags, copyAMLFlag(flag))
+		}
+	}
+
+	sort.Slice(flags, func(i, j int) bool { return flags[i].ID < flags[j].ID })
+
+	limit := filter.Limit
+
+	if limit == 0 {
+		limit = len(flags)
+	}
+
+	return page(flags, limit, filter.Offset), nil
+}
+
+func (s *MemoryStore) GetAMLFlag(ctx context.Context, id int) (*AMLFlag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.amlFlags[id]
+
+	if !ok {
+		return nil, notFoundError("AML flag %d not found", id)
+	}
+
+	flag := copyAMLFlag(stored)
+	flag.Notes = s.amlNotesOf([]int{id})
+
+	return flag, nil
+}
+
+func (s *MemoryStore) UpdateAMLFlagStatus(ctx context.Context, id int, status AMLFlagStatus, reviewedBy int64, at time.Time) (*AMLFlag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.amlFlags[id]
+
+	if !ok {
+		return nil, notFoundError("AML flag %d not found", id)
+	}
+
+	if err := stored.CheckTransition(status); err != nil {
+		return nil, err
+	}
+
+	stored.Status = status
+	stored.ReviewedBy = &reviewedBy
+	stored.UpdatedAt = at
+
+	return copyAMLFlag(stored), nil
+}
+
+func (s *MemoryStore) AddAMLNote(ctx context.Context, note *AMLNote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.amlFlags[note.FlagID]; !ok {
+		return notFoundError("AML flag %d not found", note.FlagID)
+	}
+
+	note.ID = s.nextID("aml_note")
+	copied := *note
+	s.amlNotes = append(s.amlNotes, &copied)
+
+	return nil
+}
+
+func (s *MemoryStore) GetAMLNotes(ctx context.Context, flagIDs []int) ([]*AMLNote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.amlNotesOf(flagIDs), nil
+}
+
+func (s *MemoryStore) amlNotesOf(flagIDs []int) []*AMLNote {
+	notes := []*AMLNote{}
+
+	for _, note := range s.amlNotes {
+		if slices.Contains(flagIDs, note.FlagID) {
+			copied := *note
+			notes = append(notes, &copied)
+		}
+	}
+
+	return notes
+}
+
+func copyAMLFlag(flag *AMLFlag) *AMLFlag {
+	copied := *flag
+	copied.TransactionIDs = append([]int{}, flag.TransactionIDs...)
+	copied.Notes = nil
+
+	return &copied
+}
+
+func (s *MemoryStore) RecordLoginNetwork(ctx context.Context, number int64, network string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slices.Contains(s.loginNetworks[number], network) {
+		s.loginNetworks[number] = append(s.loginNetworks[number], network)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) GetLoginNetworks(ctx context.Context, number int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.loginNetworks[number]...), nil
+}
+
+func (s *MemoryStore) CountTransfersFrom(ctx context.Context, number int64, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+
+	for _, transfer := range s.transfers {
+		if transfer.FromAccount == number && !transfer.CreatedAt.Before(since) && transfer.MovedMoney() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *MemoryStore) HasTransferredTo(ctx context.Context, from, to int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, transfer := range s.transfers {
+		if transfer.FromAccount == from && transfer.ToAccount == to && transfer.MovedMoney() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *MemoryStore) CreateDispute(ctx context.Context, dispute *Dispute) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accountByNumber(dispute.AccountNumber) == nil {
+		return accountNotFoundError("account with number %d not found", dispute.AccountNumber)
+	}
+
+	transaction := s.transaction(dispute.AccountNumber, dispute.TransactionID)
+
+	if transaction == nil {
+		return notFoundError("transaction %d not found", dispute.TransactionID)
+	}
+
+	previous := []*Dispute{}
+
+	for _, d := range s.disputes {
+		if d.TransactionID == dispute.TransactionID {
+			previous = append(previous, d)
+		}
+	}
+
+	if err := dispute.open(transaction, previous); err != nil {
+		return err
+	}
+
+	dispute.ID = s.nextID("dispute")
+	copied := *dispute
+	s.disputes[dispute.ID] = &copied
+
+	return nil
+}
+
+func (s *MemoryStore) GetDisputes(ctx context.Context, number int64, status DisputeStatus, limit, offset int) ([]*Dispute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	disputes := []*Dispute{}
+
+	for _, dispute := range s.disputes {
+		if (number == 0 || dispute.AccountNumber == number) && (status == "" || dispute.Status == status) {
+			copied := *dispute
+			disputes = append(disputes, &copied)
+		}
+	}
+
+	sort.Slice(disputes, func(i, j int) bool { return disputes[i].ID < disputes[j].ID })
+
+	return page(disputes, limit, offset), nil
+}
+
+func (s *MemoryStore) DecideDispute(ctx context.Context, id int, decision *DisputeDecision) (*Dispute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.disputes[id]
+
+	if !ok {
+		return nil, notFoundError("dispute %d not found", id)
+	}
+
+	amount, err := stored.reversal(decision)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var journalID *int
+
+	if decision.Status == DisputeReversed {
+		entry, err := s.reverseTransaction(stored, amount, decision.DecidedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		journalID = &entry.ID
+	}
+
+	stored.decide(decision, amount, journalID)
+	copied := *stored
+
+	return &copied, nil
+}
+
+// reverseTransaction mirrors the Postgres reverseTransaction.
+func (s *MemoryStore) reverseTransaction(dispute *Dispute, amount int64, at time.Time) (*JournalEntry, error) {
+	number := dispute.AccountNumber
+	original := s.transaction(number, dispute.TransactionID)
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	var to *Account
+
+	if original.Type == TransactionTransferOut {
+		if to = s.accountByNumber(*original.Counterparty); to == nil {
+			return nil, accountNotFoundError("account with number %d not found", *original.Counterparty)
+		}
+	}
+
+	entry := s.beginJournalEntry(JournalReversal, at)
+	s.applyTransaction(entry, acc, TransactionReversal, amount, original.Counterparty)
+
+	switch original.Type {
+	case TransactionWithdrawal:
+		entry.post(LedgerCash, nil, acc.Currency, -amount)
+	case TransactionFee:
+		entry.post(LedgerFees, nil, acc.Currency, -amount)
+	case TransactionTransferOut:
+		var credited int64
+
+		for _, t := range s.transactions {
+			if t.JournalID == original.JournalID && t.AccountNumber == to.Number && t.Type == TransactionTransferIn {
+				credited = t.Amount
+			}
+		}
+
+		share := counterpartyShare(amount, -original.Amount, credited)
+		s.applyTransaction(entry, to, TransactionReversal, -share, &number)
+
+		if acc.Currency != to.Currency {
+			entry.post(LedgerFX, nil, acc.Currency, -amount)
+			entry.post(LedgerFX, nil, to.Currency, share)
+		}
+	}
+
+	return entry, s.commitJournalEntry(entry)
+}
+
+func (s *MemoryStore) transaction(number int64, id int) *Transaction {
+	for _, t := range s.transactions {
+		if t.ID == id && t.AccountNumber == number {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) CreatePot(ctx context.Context, pot *Pot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkPotName(pot); err != nil {
+		return err
+	}
+
+	pot.ID = s.nextID("pot")
+
+	stored := *pot
+	s.pots[pot.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) checkPotName(pot *Pot) error {
+	for _, existing := range s.pots {
+		if existing.ID != pot.ID && existing.AccountNumber == pot.AccountNumber && existing.Name == pot.Name {
+			return conflictError("account with number %d already has a pot named %s", pot.AccountNumber, pot.Name)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) GetPots(ctx context.Context, number int64) ([]*Pot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pots := []*Pot{}
+
+	for _, pot := range s.pots {
+		if pot.AccountNumber == number {
+			copied := *pot
+			pots = append(pots, &copied)
+		}
+	}
+
+	sort.Slice(pots, func(i, j int) bool {
+		return pots[i].ID < pots[j].ID
+	})
+
+	return pots, nil
+}
+
+func (s *MemoryStore) GetPot(ctx context.Context, id int, number int64) (*Pot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pot, err := s.pot(id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	copied := *pot
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) pot(id int, number int64) (*Pot, error) {
+	pot, ok := s.pots[id]
+
+	if !ok || pot.AccountNumber != number {
+		return nil, notFoundError("pot %d not found", id)
+	}
+
+	return pot, nil
+}
+
+func (s *MemoryStore) UpdatePot(ctx context.Context, pot *Pot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.pot(pot.ID, pot.AccountNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPotName(pot); err != nil {
+		return err
+	}
+
+	stored.Name = pot.Name
+	stored.TargetAmount = pot.TargetAmount
+	stored.RoundUp = pot.RoundUp
+	stored.WeeklyAmount = pot.WeeklyAmount
+	stored.NextSweepAt = pot.NextSweepAt
+
+	return nil
+}
+
+func (s *MemoryStore) DeletePot(ctx context.Context, id int, number int64) (*Pot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pot, err := s.pot(id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	acc.PotBalance -= pot.Balance
+	acc.Version++
+	delete(s.pots, id)
+
+	return pot, nil
+}
+
+func (s *MemoryStore) MovePotMoney(ctx context.Context, id int, number int64, amount int64) (*Pot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pot, err := s.pot(id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := s.accountByNumber(number)
+
+	if acc == nil {
+		return nil, accountNotFoundError("account with number %d not found", number)
+	}
+
+	if err := checkPotMove(acc, pot, amount); err != nil {
+		return nil, err
+	}
+
+	pot.Balance += amount
+	acc.PotBalance += amount
+	acc.Version++
+
+	copied := *pot
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) SweepPots(ctx context.Context, now time.Time, limit int) ([]*PotSweep, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*Pot{}
+
+	for _, pot := range s.pots {
+		if pot.NextSweepAt != nil && !pot.NextSweepAt.After(now) && s.accountByNumber(pot.AccountNumber) != nil {
+			due = append(due, pot)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextSweepAt.Before(*due[j].NextSweepAt)
+	})
+
+	sweeps := []*PotSweep{}
+
+	for _, pot := range page(due, limit, 0) {
+		acc := s.accountByNumber(pot.AccountNumber)
+		sweep := &PotSweep{PotID: pot.ID, AccountNumber: pot.AccountNumber, Amount: pot.WeeklyAmount}
+
+		if checkPotMove(acc, pot, pot.WeeklyAmount) == nil {
+			pot.Balance += pot.WeeklyAmount
+			acc.PotBalance += pot.WeeklyAmount
+			acc.Version++
+			sweep.Swept = true
+		}
+
+		next := pot.NextSweepAt.Add(potSweepInterval)
+		pot.NextSweepAt = &next
+		sweeps = append(sweeps, sweep)
+	}
+
+	return sweeps, nil
+}
+
+func (s *MemoryStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertRefreshToken(token)
+
+	return nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, tokenHash string, next *RefreshToken, device Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.refreshTokens[tokenHash]
+
+	if !ok {
+		return unauthorizedError("invalid refresh token")
+	}
+
+	session := s.sessions[current.SessionID]
+
+	if session != nil && session.RevokedAt != nil {
+		return unauthorizedError("session revoked")
+	}
+
+	now := time.Now().UTC()
+
+	if current.RevokedAt != nil {
+		for _, token := range s.refreshTokens {
+			if token.AccountNumber == current.AccountNumber && token.RevokedAt == nil {
+				token.RevokedAt = &now
+			}
+		}
+
+		return unauthorizedError("invalid refresh token")
+	}
+
+	if now.After(current.ExpiresAt) {
+		return unauthorizedError("refresh token expired")
+	}
+
+	current.RevokedAt = &now
+
+	if session != nil {
+		session.Device = device
+		session.LastSeenAt = now
+	}
+
+	next.AccountNumber = current.AccountNumber
+	next.SessionID = current.SessionID
+
+	if session != nil {
+		next.UserID = session.UserID
+	}
+
+	s.insertRefreshToken(next)
+
+	return nil
+}
+
+func (s *MemoryStore) CreateSession(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.ID = s.nextID("session")
+
+	stored := *session
+	s.sessions[session.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetSessions(ctx context.Context, number int64, now time.Time) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	live := map[int]bool{}
+
+	for _, token := range s.refreshTokens {
+		if token.RevokedAt == nil && token.ExpiresAt.After(now) {
+			live[token.SessionID] = true
+		}
+	}
+
+	sessions := []*Session{}
+
+	for _, session := range s.sessions {
+		if session.AccountNumber == number && session.RevokedAt == nil && live[session.ID] {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+
+		return sessions[i].ID > sessions[j].ID
+	})
+
+	return sessions, nil
+}
+
+func (s *MemoryStore) RevokeSessions(ctx context.Context, number int64, id int, now, deniedUntil time.Time) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revoked := []*Session{}
+
+	for _, session := range s.sessions {
+		if session.AccountNumber != number || session.RevokedAt != nil || (id != 0 && session.ID != id) {
+			continue
+		}
+
+		session.RevokedAt = &now
+		s.denylist[session.ID] = deniedUntil
+
+		for _, token := range s.refreshTokens {
+			if token.SessionID == session.ID && token.RevokedAt == nil {
+				token.RevokedAt = &now
+			}
+		}
+
+		copied := *session
+		revoked = append(revoked, &copied)
+	}
+
+	if id != 0 && len(revoked) == 0 {
+		return nil, notFoundError("session %d not found", id)
+	}
+
+	for sessionID, until := range s.denylist {
+		if !until.After(now) {
+			delete(s.denylist, sessionID)
+		}
+	}
+
+	sort.Slice(revoked, func(i, j int) bool { return revoked[i].ID < revoked[j].ID })
+
+	return revoked, nil
+}
+
+func (s *MemoryStore) SessionDenied(ctx context.Context, id int, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.denylist[id]
+
+	return ok && until.After(now), nil
+}
+
+func (s *MemoryStore) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.ID = s.nextID("api_key")
+	s.apiKeys[key.ID] = copyAPIKey(key)
+
+	return nil
+}
+
+func (s *MemoryStore) GetAPIKeys(ctx context.Context, number int64) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []*APIKey{}
+
+	for _, key := range s.apiKeys {
+		if key.AccountNumber == number {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+
+	return keys, nil
+}
+
+func (s *MemoryStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.apiKeys {
+		if key.KeyHash == keyHash {
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return nil, notFoundError("API key not found")
+}
+
+func (s *MemoryStore) TouchAPIKey(ctx context.Context, id int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.apiKeys[id]; ok {
+		key.LastUsedAt = &now
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) RevokeAPIKey(ctx context.Context, id int, number int64, now time.Time) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+
+	if !ok || key.AccountNumber != number {
+		return nil, notFoundError("API key %d not found", id)
+	}
+
+	if key.RevokedAt != nil {
+		return nil, conflictError("API key %d is already revoked", id)
+	}
+
+	key.RevokedAt = &now
+
+	return copyAPIKey(key), nil
+}
+
+func copyAPIKey(key *APIKey) *APIKey {
+	copied := *key
+	copied.Scopes = slices.Clone(key.Scopes)
+
+	return &copied
+}
+
+func (s *MemoryStore) CreateBusinessUser(ctx context.Context, user *BusinessUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.businessUsers {
+		if other.AccountNumber == user.AccountNumber && other.Username == user.Username && other.RemovedAt == nil {
+			return conflictError("account with number %d already has a user %s", user.AccountNumber, user.Username)
+		}
+	}
+
+	user.ID = s.nextID("business_user")
+
+	stored := *user
+	s.businessUsers[user.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetBusinessUser(ctx context.Context, id int) (*BusinessUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.businessUsers[id]
+
+	if !ok {
+		return nil, notFoundError("business user not found")
+	}
+
+	copied := *user
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetBusinessUserByUsername(ctx context.Context, number int64, username string) (*BusinessUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.businessUsers {
+		if user.AccountNumber == number && user.Username == username && user.RemovedAt == nil {
+			copied := *user
+			return &copied, nil
+		}
+	}
+
+	return nil, notFoundError("business user not found")
+}
+
+func (s *MemoryStore) GetBusinessUsers(ctx context.Context, number int64) ([]*BusinessUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []*BusinessUser{}
+
+	for _, user := range s.businessUsers {
+		if user.AccountNumber == number && user.RemovedAt == nil {
+			copied := *user
+			users = append(users, &copied)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return users, nil
+}
+
+func (s *MemoryStore) SetBusinessUserRole(ctx context.Context, id int, number int64, role BusinessUserRole) (*BusinessUser, *BusinessUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.businessUsers[id]
+
+	if !ok || user.AccountNumber != number || user.RemovedAt != nil {
+		return nil, nil, notFoundError("business user %d not found", id)
+	}
+
+	before := *user
+	user.Role = role
+	after := *user
+
+	return &before, &after, nil
+}
+
+func (s *MemoryStore) RemoveBusinessUser(ctx context.Context, id int, number int64, now, deniedUntil time.Time) (*BusinessUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.businessUsers[id]
+
+	if !ok || user.AccountNumber != number || user.RemovedAt != nil {
+		return nil, notFoundError("business user %d not found", id)
+	}
+
+	user.RemovedAt = &now
+
+	for _, session := range s.sessions {
+		if session.UserID != id || session.RevokedAt != nil {
+			continue
+		}
+
+		session.RevokedAt = &now
+		s.denylist[session.ID] = deniedUntil
+
+		for _, token := range s.refreshTokens {
+			if token.SessionID == session.ID && token.RevokedAt == nil {
+				token.RevokedAt = &now
+			}
+		}
+	}
+
+	copied := *user
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) GetLoginThrottles(ctx context.Context, keys []string) ([]*LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	throttles := []*LoginThrottle{}
+
+	for _, key := range keys {
+		if throttle, ok := s.throttles[key]; ok {
+			copied := *throttle
+			throttles = append(throttles, &copied)
+		}
+	}
+
+	return throttles, nil
+}
+
+func (s *MemoryStore) RecordLoginFailure(ctx context.Context, key string, policy LoginPolicy, now time.Time) (*LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	throttle, ok := s.throttles[key]
+
+	if !ok {
+		throttle = &LoginThrottle{Key: key}
+		s.throttles[key] = throttle
+	}
+
+	throttle.Fail(policy, now)
+	copied := *throttle
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) ClearLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	throttle, ok := s.throttles[key]
+
+	if !ok {
+		return nil, notFoundError("no failed logins for %s", key)
+	}
+
+	delete(s.throttles, key)
+
+	return throttle, nil
+}
+
+func (s *MemoryStore) insertRefreshToken(token *RefreshToken) {
+	token.ID = s.nextID("refresh_token")
+
+	stored := *token
+	s.refreshTokens[token.TokenHash] = &stored
+}
+
+func (s *MemoryStore) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.resets {
+		if existing.AccountNumber == reset.AccountNumber && existing.UsedAt == nil && existing.ExpiresAt.After(reset.CreatedAt) {
+			existing.ExpiresAt = reset.CreatedAt
+		}
+	}
+
+	reset.ID = s.nextID("password_reset")
+
+	stored := *reset
+	s.resets[reset.TokenHash] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) RedeemPasswordReset(ctx context.Context, tokenHash, encryptedPassword string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, ok := s.resets[tokenHash]
+
+	if !ok || reset.UsedAt != nil {
+		return 0, unauthorizedError("invalid password reset token")
+	}
+
+	now := time.Now().UTC()
+
+	if !now.Before(reset.ExpiresAt) {
+		return 0, unauthorizedError("password reset token expired")
+	}
+
+	if err := s.resetPassword(reset.AccountNumber, encryptedPassword); err != nil {
+		return 0, err
+	}
+
+	reset.UsedAt = &now
+
+	return reset.AccountNumber, nil
+}
+
+func (s *MemoryStore) CreateTOTP(ctx context.Context, t *TOTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.totps[t.AccountNumber]; ok && current.EnabledAt != nil {
+		return conflictError("two-factor authentication is already enabled")
+	}
+
+	stored := *t
+	s.totps[t.AccountNumber] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetTOTP(ctx context.Context, number int64) (*TOTP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[number]
+
+	if !ok {
+		return nil, notFoundError("two-factor authentication is not set up")
+	}
+
+	copied := *t
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) EnableTOTP(ctx context.Context, number int64, backupCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[number]
+
+	if !ok || t.EnabledAt != nil {
+		return conflictError("no pending two-factor enrollment")
+	}
+
+	now := time.Now().UTC()
+	t.EnabledAt = &now
+
+	// the value records whether the code is still unused
+	codes := map[string]bool{}
+
+	for _, hash := range backupCodeHashes {
+		codes[hash] = true
+	}
+
+	s.backupCodes[number] = codes
+
+	return nil
+}
+
+func (s *MemoryStore) UseTOTPBackupCode(ctx context.Context, number int64, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.backupCodes[number][codeHash] {
+		return notFoundError("backup code not found")
+	}
+
+	s.backupCodes[number][codeHash] = false
+
+	return nil
+}
+
+func (s *MemoryStore) ReserveIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{rec.Key, rec.Scope}
+
+	if stored, ok := s.idempotency[key]; ok {
+		copied := *stored
+		return &copied, false, nil
+	}
+
+	stored := *rec
+	stored.Status = IdempotencyPending
+	s.idempotency[key] = &stored
+
+	return rec, true, nil
+}
+
+func (s *MemoryStore) CompleteIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.idempotency[[2]string{rec.Key, rec.Scope}]; ok {
+		stored.Status = IdempotencyCompleted
+		stored.StatusCode = rec.StatusCode
+		stored.ResponseBody = rec.ResponseBody
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ReleaseIdempotencyKey(ctx context.Context, key, scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.idempotency[[2]string{key, scope}]; ok && stored.Status == IdempotencyPending {
+		delete(s.idempotency, [2]string{key, scope})
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) CreateScheduledTransfer(ctx context.Context, st *ScheduledTransfer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st.ID = s.nextID("scheduled_transfer")
+
+	stored := *st
+	s.scheduledTransfers[st.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetScheduledTransfers(ctx context.Context, accountNumber int64) ([]*ScheduledTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfers := []*ScheduledTransfer{}
+
+	for _, st := range s.scheduledTransfers {
+		if st.FromAccount == accountNumber {
+			copied := *st
+			transfers = append(transfers, &copied)
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].ID > transfers[j].ID
+	})
+
+	return transfers, nil
+}
+
+func (s *MemoryStore) CancelScheduledTransfer(ctx context.Context, id int, accountNumber int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.scheduledTransfers[id]
+
+	if !ok || st.FromAccount != accountNumber || st.Status != ScheduledTransferActive {
+		return notFoundError("active scheduled transfer %d not found", id)
+	}
+
+	st.Status = ScheduledTransferCancelled
+
+	return nil
+}
+
+func (s *MemoryStore) ClaimDueScheduledTransfers(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*ScheduledTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*ScheduledTransfer{}
+
+	for _, st := range s.scheduledTransfers {
+		lockedUntil, locked := s.scheduledLocks[st.ID]
+
+		if st.Status == ScheduledTransferActive && !st.NextRunAt.After(now) && (!locked || lockedUntil.Before(now)) {
+			due = append(due, st)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+
+	due = page(due, limit, 0)
+	claimed := make([]*ScheduledTransfer, 0, len(due))
+
+	for _, st := range due {
+		s.scheduledLocks[st.ID] = leaseUntil
+
+		copied := *st
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *MemoryStore) RecordScheduledTransferRun(ctx context.Context, st *ScheduledTransfer, run *ScheduledTransferRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.scheduledTransfers[st.ID]; ok {
+		stored.Status = st.Status
+		stored.NextRunAt = st.NextRunAt
+		stored.Occurrence = st.Occurrence
+		stored.Attempts = st.Attempts
+		stored.LastError = st.LastError
+	}
+
+	delete(s.scheduledLocks, st.ID)
+
+	run.ID = s.nextID("scheduled_transfer_run")
+
+	stored := *run
+	s.scheduledTransferRuns = append(s.scheduledTransferRuns, &stored)
+
+	return nil
+}
+
+func (s *MemoryStore) CreateStandingOrder(ctx context.Context, order *StandingOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order.ID = s.nextID("standing_order")
+
+	stored := *order
+	s.standingOrders[order.ID] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetStandingOrders(ctx context.Context, accountNumber int64) ([]*StandingOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := []*StandingOrder{}
+
+	for _, order := range s.standingOrders {
+		if order.FromAccount == accountNumber {
+			copied := *order
+			orders = append(orders, &copied)
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID > orders[j].ID
+	})
+
+	return orders, nil
+}
+
+func (s *MemoryStore) CancelStandingOrder(ctx context.Context, id int, accountNumber int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.standingOrders[id]
+
+	if !ok || order.FromAccount != accountNumber || order.Status != StandingOrderActive {
+		return notFoundError("active standing order %d not found", id)
+	}
+
+	order.Status = StandingOrderCancelled
+
+	return nil
+}
+
+func (s *MemoryStore) ClaimDueStandingOrders(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*StandingOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*StandingOrder{}
+
+	for _, order := range s.standingOrders {
+		lockedUntil, locked := s.standingOrderLocks[order.ID]
+
+		if order.Status == StandingOrderActive && !order.NextRunAt.After(now) && (!locked || lockedUntil.Before(now)) {
+			due = append(due, order)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+
+	due = page(due, limit, 0)
+	claimed := make([]*StandingOrder, 0, len(due))
+
+	for _, order := range due {
+		s.standingOrderLocks[order.ID] = leaseUntil
+
+		copied := *order
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *MemoryStore) UpdateStandingOrder(ctx context.Context, order *StandingOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.standingOrders[order.ID]; ok {
+		if stored.Status == StandingOrderActive {
+			stored.Status = order.Status
+		}
+
+		stored.NextRunAt = order.NextRunAt
+		stored.Occurrence = order.Occurrence
+		stored.Failures = order.Failures
+		stored.LastError = order.LastError
+	}
+
+	delete(s.standingOrderLocks, order.ID)
+
+	return nil
+}
+
+func (s *MemoryStore) AddHoliday(ctx context.Context, holiday *Holiday) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.holidays[holiday.Date]; ok {
+		return conflictError("%s already is a holiday", holiday.Date)
+	}
+
+	stored := *holiday
+	s.holidays[holiday.Date] = &stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetHolidays(ctx context.Context) ([]*Holiday, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holidays := []*Holiday{}
+
+	for _, holiday := range s.holidays {
+		copied := *holiday
+		holidays = append(holidays, &copied)
+	}
+
+	sort.Slice(holidays, func(i, j int) bool {
+		return holidays[i].Date < holidays[j].Date
+	})
+
+	return holidays, nil
+}
+
+func (s *MemoryStore) DeleteHoliday(ctx context.Context, date string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.holidays[date]; !ok {
+		return notFoundError("holiday %s not found", date)
+	}
+
+	delete(s.holidays, date)
+
+	return nil
+}
+
+func (s *MemoryStore) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook.ID = s.nextID("webhook")
+
+	stored := *webhook
+	s.webhooks[webhook.ID] = &stored
+
+	return nil
+}
+
+func sameOwner(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
+
+func (s *MemoryStore) GetWebhooks(ctx context.Context, accountNumber *int64) ([]*Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks := []*Webhook{}
+
+	for id, webhook := range s.webhooks {
+		if !s.inactiveWebhooks[id] && sameOwner(webhook.AccountNumber, accountNumber) {
+			copied := *webhook
+			copied.Secret = ""
+			webhooks = append(webhooks, &copied)
+		}
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].ID < webhooks[j].ID
+	})
+
+	return webhooks, nil
+}
+
+func (s *MemoryStore) DeleteWebhook(ctx context.Context, id int, accountNumber *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+
+	if !ok || s.inactiveWebhooks[id] || !sameOwner(webhook.AccountNumber, accountNumber) {
+		return notFoundError("webhook %d not found", id)
+	}
+
+	s.inactiveWebhooks[id] = true
+
+	return nil
+}
+
+func (s *MemoryStore) GetWebhookDeliveries(ctx context.Context, webhookID int, accountNumber *int64, limit, offset int) ([]*WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := []*WebhookDelivery{}
+	webhook, ok := s.webhooks[webhookID]
+
+	if !ok || !sameOwner(webhook.AccountNumber, accountNumber) {
+		return deliveries, nil
+	}
+
+	for i := len(s.webhookDeliveries) - 1; i >= 0; i-- {
+		if d := s.webhookDeliveries[i]; d.WebhookID == webhookID {
+			copied := *d
+			copied.Payload, copied.URL, copied.Secret = nil, "", ""
+			deliveries = append(deliveries, &copied)
+		}
+	}
+
+	return page(deliveries, limit, offset), nil
+}
+
+func (s *MemoryStore) EnqueueWebhookDeliveries(ctx context.Context, event *Event, payload []byte, balance *int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, 0, len(s.webhooks))
+
+	for id := range s.webhooks {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		webhook := s.webhooks[id]
+
+		if s.inactiveWebhooks[id] || !slices.Contains(webhook.Events, event.Type) {
+			continue
+		}
+
+		if webhook.AccountNumber != nil && *webhook.AccountNumber != event.AccountNumber {
+			continue
+		}
+
+		if balance != nil && *balance >= webhook.LowBalanceThreshold {
+			continue
+		}
+
+		s.webhookDeliveries = append(s.webhookDeliveries, &WebhookDelivery{
+			ID:            s.nextID("webhook_delivery"),
+			WebhookID:     id,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: event.CreatedAt,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ClaimDueWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*WebhookDelivery{}
+
+	for _, d := range s.webhookDeliveries {
+		lockedUntil, locked := s.deliveryLocks[d.ID]
+
+		if d.Status == WebhookDeliveryPending && !s.inactiveWebhooks[d.WebhookID] && !d.NextAttemptAt.After(now) && (!locked || lockedUntil.Before(now)) {
+			due = append(due, d)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	due = page(due, limit, 0)
+	claimed := make([]*WebhookDelivery, 0, len(due))
+
+	for _, d := range due {
+		s.deliveryLocks[d.ID] = leaseUntil
+
+		copied := *d
+		copied.URL = s.webhooks[d.WebhookID].URL
+		copied.Secret = s.webhooks[d.WebhookID].Secret
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *MemoryStore) UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.webhookDeliveries {
+		if d.ID == delivery.ID {
+			d.Status = delivery.Status
+			d.Attempts = delivery.Attempts
+			d.NextAttemptAt = delivery.NextAttemptAt
+			d.ResponseCode = delivery.ResponseCode
+			d.LastError = delivery.LastError
+			d.DeliveredAt = delivery.DeliveredAt
+		}
+	}
+
+	delete(s.deliveryLocks, delivery.ID)
+
+	return nil
+}
+
+func (s *MemoryStore) GetAccountStats(ctx context.Context) (*AccountStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := newAccountStats()
+	balances := map[string]*CurrencyBalance{}
+
+	for _, acc := range s.accounts {
+		if acc.DeletedAt == nil {
+			stats.add(balances, acc.Status, acc.Currency, 1, acc.Balance)
+		}
+	}
+
+	stats.sortBalances()
+
+	return stats, nil
+}
+
+func (s *MemoryStore) GetTransferVolume(ctx context.Context, from, to time.Time) ([]*DailyTransferVolume, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	volume := []*DailyTransferVolume{}
+	days := map[[2]string]*DailyTransferVolume{}
+
+	for _, transfer := range s.transfers {
+		if transfer.CreatedAt.Before(from) || !transfer.CreatedAt.Before(to) || !transfer.MovedMoney() {
+			continue
+		}
+
+		key := [2]string{transfer.CreatedAt.Format(time.DateOnly), transfer.Currency}
+		day, ok := days[key]
+
+		if !ok {
+			day = &DailyTransferVolume{Date: key[0], Currency: key[1]}
+			days[key] = day
+			volume = append(volume, day)
+		}
+
+		day.Count++
+		day.Amount += transfer.Amount
+	}
+
+	sort.Slice(volume, func(i, j int) bool {
+		if volume[i].Date != volume[j].Date {
+			return volume[i].Date < volume[j].Date
+		}
+
+		return volume[i].Currency < volume[j].Currency
+	})
+
+	return volume, nil
+}
+
+func (s *MemoryStore) GetFailedLogins(ctx context.Context, from, to time.Time) ([]*DailyCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := []*DailyCount{}
+	days := map[string]*DailyCount{}
+
+	for _, entry := range s.auditLog {
+		if entry.Action != AuditLoginFailed || entry.CreatedAt.Before(from) || !entry.CreatedAt.Before(to) {
+			continue
+		}
+
+		date := entry.CreatedAt.Format(time.DateOnly)
+		day, ok := days[date]
+
+		if !ok {
+			day = &DailyCount{Date: date}
+			days[date] = day
+			counts = append(counts, day)
+		}
+
+		day.Count++
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Date < counts[j].Date
+	})
+
+	return counts, nil
+}
+
+func (s *MemoryStore) GetLargestAccounts(ctx context.Context, currency string, limit int) ([]*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := []*Account{}
+
+	for _, acc := range s.accounts {
+		if acc.DeletedAt == nil && (currency == "" || acc.Currency == currency) {
+			copied := *acc
+			accounts = append(accounts, &copied)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Balance != accounts[j].Balance {
+			return accounts[i].Balance > accounts[j].Balance
+		}
+
+		return accounts[i].ID < accounts[j].ID
+	})
+
+	return page(accounts, limit, 0), nil
+}
+
+func (s *MemoryStore) ClaimOutboxMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*OutboxMessage{}
+
+	for _, msg := range s.outbox {
+		lockedUntil, locked := s.outboxLocks[msg.ID]
+
+		if msg.PublishedAt == nil && !msg.NextAttemptAt.After(now) && (!locked || lockedUntil.Before(now)) {
+			due = append(due, msg)
+		}
+	}
+
+	due = page(due, limit, 0)
+	claimed := make([]*OutboxMessage, 0, len(due))
+
+	for _, msg := range due {
+		s.outboxLocks[msg.ID] = leaseUntil
+
+		copied := *msg
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *MemoryStore) UpdateOutboxMessage(ctx context.Context, msg *OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.outbox {
+		if stored.ID == msg.ID {
+			stored.Attempts = msg.Attempts
+			stored.NextAttemptAt = msg.NextAttemptAt
+			stored.LastError = msg.LastError
+			stored.PublishedAt = msg.PublishedAt
+		}
+	}
+
+	delete(s.outboxLocks, msg.ID)
+
+	return nil
+}
+
+func (s *MemoryStore) CreateReconciliationReport(ctx context.Context, report *ReconciliationReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report.ID = s.nextID("reconciliation_report")
+	s.reconciliationReports = append(s.reconciliationReports, copyReconciliationReport(report))
+
+	return nil
+}
+
+func (s *MemoryStore) GetReconciliationReports(ctx context.Context, limit, offset int) ([]*ReconciliationReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reports := []*ReconciliationReport{}
+
+	for i := len(s.reconciliationReports) - 1; i >= 0; i-- {
+		reports = append(reports, copyReconciliationReport(s.reconciliationReports[i]))
+	}
+
+	return page(reports, limit, offset), nil
+}
+
+func (s *MemoryStore) GetReconciliationReport(ctx context.Context, id int) (*ReconciliationReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, report := range s.reconciliationReports {
+		if report.ID == id {
+			return copyReconciliationReport(report), nil
+		}
+	}
+
+	return nil, notFoundError("reconciliation report %d not found", id)
+}
+
+func copyReconciliationReport(report *ReconciliationReport) *ReconciliationReport {
+	copied := *report
+	copied.Totals = maps.Clone(report.Totals)
+	copied.UnbalancedEntries = slices.Clone(report.UnbalancedEntries)
+	copied.MismatchedAccounts = slices.Clone(report.MismatchedAccounts)
+
+	return &copied
+}
+
+func (s *MemoryStore) GetMaintenanceState(ctx context.Context) (*MaintenanceState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maintenance == nil {
+		return &MaintenanceState{Mode: MaintenanceOff}, nil
+	}
+
+	copied := *s.maintenance
+
+	return &copied, nil
+}
+
+func (s *MemoryStore) SetMaintenanceState(ctx context.Context, state *MaintenanceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *state
+	s.maintenance = &copied
+
+	return nil
+}
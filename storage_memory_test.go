@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreTransferChargesOverdraftFee(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	from := &Account{Number: 1, Currency: "USD", Status: AccountActive, AccountLimits: AccountLimits{OverdraftLimit: 500, OverdraftFee: 25}}
+	to := &Account{Number: 2, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, from))
+	require.Nil(t, store.CreateAccount(ctx, to))
+
+	_, err := store.Deposit(ctx, 1, 100, 0)
+	require.Nil(t, err)
+
+	transfer := &Transfer{FromAccount: 1, ToAccount: 2, Amount: 300, Currency: "USD", ToAmount: 300, ToCurrency: "USD"}
+	assert.Nil(t, store.Transfer(ctx, transfer))
+	assert.NotZero(t, transfer.ID)
+
+	acc, _ := store.GetAccountByNumber(ctx, 1)
+	assert.Equal(t, int64(-225), acc.Balance)
+
+	transactions, _ := store.GetTransactions(ctx, 1, 10, 0)
+	require.Len(t, transactions, 3)
+	assert.Equal(t, TransactionFee, transactions[0].Type)
+	assert.Equal(t, TransactionTransferOut, transactions[1].Type)
+
+	transfer = &Transfer{FromAccount: 1, ToAccount: 2, Amount: 300, Currency: "USD", ToAmount: 300, ToCurrency: "USD"}
+	assert.Equal(t, insufficientFundsError(), store.Transfer(ctx, transfer))
+}
+
+func TestMemoryStoreRotateRefreshTokenReuse(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	expires := time.Now().Add(time.Hour)
+
+	require.Nil(t, store.CreateRefreshToken(ctx, &RefreshToken{AccountNumber: 1, TokenHash: "a", ExpiresAt: expires}))
+	assert.Nil(t, store.RotateRefreshToken(ctx, "a", &RefreshToken{TokenHash: "b", ExpiresAt: expires}, Device{}))
+
+	assert.NotNil(t, store.RotateRefreshToken(ctx, "a", &RefreshToken{TokenHash: "c", ExpiresAt: expires}, Device{}))
+	assert.NotNil(t, store.RotateRefreshToken(ctx, "b", &RefreshToken{TokenHash: "d", ExpiresAt: expires}, Device{}))
+}
+
+func TestMemoryStoreGetAccounts(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for i, name := range []string{"b", "c", "a"} {
+		require.Nil(t, store.CreateAccount(ctx, &Account{Number: int64(i + 1), LastName: name}))
+	}
+
+	accounts, err := store.GetAccounts(ctx, AccountFilter{Limit: 2, Sort: "-last_name"})
+	assert.Nil(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "c", accounts[0].LastName)
+	assert.Equal(t, "b", accounts[1].LastName)
+
+	_, err = store.GetAccounts(ctx, AccountFilter{Limit: 2, Sort: "balance"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// migrationLockID keys the advisory lock that keeps two processes from
+// migrating the same database at once.
+const migrationLockID = 72_617_001
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction.
+func (s *PostgresStore) MigrateUp(ctx context.Context) error {
+	migrations, err := loadMigrations(s.migrations)
+
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+
+			slog.Info("applying migration", "version", m.Version, "name", m.Name)
+
+			err := runMigration(ctx, conn, m.Up, "insert into schema_migrations (version, name, applied_at) values ($1, $2, $3)", m.Version, m.Name, time.Now().UTC())
+
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first.
+func (s *PostgresStore) MigrateDown(ctx context.Context, steps int) error {
+	migrations, err := loadMigrations(s.migrations)
+
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+
+			if !applied[m.Version] {
+				continue
+			}
+
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted", m.Version, m.Name)
+			}
+
+			slog.Info("reverting migration", "version", m.Version, "name", m.Name)
+
+			if err := runMigration(ctx, conn, m.Down, "delete from schema_migrations where version = $1", m.Version); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// PendingMigrations doesn't take the migration lock: readiness probes call it
+// and must not wait for a migration running elsewhere.
+func (s *PostgresStore) PendingMigrations(ctx context.Context) ([]int, error) {
+	return s.pendingMigrations(ctx, "select to_regclass('schema_migrations') is not null")
+}
+
+// pendingMigrations returns the migrations not applied yet, all of them when
+// the exists query finds no schema_migrations table.
+func (s *PostgresStore) pendingMigrations(ctx context.Context, exists string) ([]int, error) {
+	migrations, err := loadMigrations(s.migrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.db.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	var found bool
+
+	if err := conn.QueryRowContext(ctx, exists).Scan(&found); err != nil {
+		return nil, err
+	}
+
+	applied := map[int]bool{}
+
+	if found {
+		if applied, err = appliedMigrations(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := []int{}
+
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m.Version)
+		}
+	}
+
+	return pending, nil
+}
+
+func (s *PostgresStore) withMigrationLock(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := s.db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	// waiting for another process's migrations and running them can both
+	// take longer than dbQueryTimeout
+	if _, err := conn.ExecContext(ctx, "set statement_timeout = 0"); err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(context.Background(), "reset statement_timeout")
+
+	if _, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", migrationLockID); err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", migrationLockID)
+
+	query := `create table if not exists schema_migrations (
+		version bigint primary key,
+		name varchar(255) not null,
+		applied_at timestamp not null
+	)`
+
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, "select version from schema_migrations")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]bool{}
+
+	for rows.Next() {
+		var version int
+
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// runMigration executes a migration script and records it in
+// schema_migrations in the same transaction.
+func runMigration(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const notificationDeliveryColumns = "id, account_number, channel, recipient, kind, subject, body, status, attempts, next_attempt_at, last_error, created_at, delivered_at"
+
+func (s *PostgresStore) GetNotificationPreferences(ctx context.Context, number int64) (*NotificationPreferences, error) {
+	query := `
+	select channels, email, phone, push_token, low_balance_threshold, incoming_transfer, new_device_login, updated_at
+	from notification_preferences
+	where account_number = $1`
+
+	// database/sql hands arrays over as text; the pgx type map parses them
+	typeMap := pgtype.NewMap()
+	prefs := &NotificationPreferences{AccountNumber: number, Channels: []NotificationChannel{}}
+	channels := []string{}
+
+	err := s.db.QueryRowContext(ctx, query, number).Scan(typeMap.SQLScanner(&channels), &prefs.Email, &prefs.Phone, &prefs.PushToken, &prefs.LowBalanceThreshold, &prefs.IncomingTransfer, &prefs.NewDeviceLogin, &prefs.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return prefs, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channel := range channels {
+		prefs.Channels = append(prefs.Channels, NotificationChannel(channel))
+	}
+
+	return prefs, nil
+}
+
+func (s *PostgresStore) SaveNotificationPreferences(ctx context.Context, prefs *NotificationPreferences) error {
+	query := `
+	insert into notification_preferences
+	(account_number, channels, email, phone, push_token, low_balance_threshold, incoming_transfer, new_device_login, updated_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	on conflict (account_number) do update set
+	channels = excluded.channels, email = excluded.email, phone = excluded.phone, push_token = excluded.push_token,
+	low_balance_threshold = excluded.low_balance_threshold, incoming_transfer = excluded.incoming_transfer,
+	new_device_login = excluded.new_device_login, updated_at = excluded.updated_at`
+
+	_, err := s.db.ExecContext(ctx, query, prefs.AccountNumber, prefs.Channels, prefs.Email, prefs.Phone, prefs.PushToken, prefs.LowBalanceThreshold, prefs.IncomingTransfer, prefs.NewDeviceLogin, prefs.UpdatedAt)
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) EnqueueNotificationDeliveries(ctx context.Context, deliveries []*NotificationDelivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	insert into notification_delivery
+	(account_number, channel, recipient, kind, subject, body, status, attempts, next_attempt_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, 0, $8, $9)
+	returning id`
+
+	for _, d := range deliveries {
+		if err := tx.QueryRowContext(ctx, query, d.AccountNumber, d.Channel, d.To, d.Kind, d.Subject, d.Body, d.Status, d.NextAttemptAt, d.CreatedAt).Scan(&d.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) ClaimDueNotificationDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*NotificationDelivery, error) {
+	query := `
+	update notification_delivery
+	set locked_until = $1
+	where id in (
+		select id from notification_delivery
+		where status = $2 and next_attempt_at <= $3 and (locked_until is null or locked_until < $3)
+		order by next_attempt_at
+		limit $4
+		for update skip locked
+	)
+	returning ` + notificationDeliveryColumns
+
+	return s.queryNotificationDeliveries(ctx, query, leaseUntil, NotificationDeliveryPending, now, limit)
+}
+
+func (s *PostgresStore) UpdateNotificationDelivery(ctx context.Context, delivery *NotificationDelivery) error {
+	query := `
+	update notification_delivery
+	set status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, delivered_at = $5, locked_until = null
+	where id = $6`
+
+	_, err := s.db.ExecContext(ctx, query, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt, delivery.ID)
+
+	return err
+}
+
+func (s *PostgresStore) GetNotificationDeliveries(ctx context.Context, number int64, limit, offset int) ([]*NotificationDelivery, error) {
+	query := `
+	select ` + notificationDeliveryColumns + `
+	from notification_delivery
+	where account_number = $1
+	order by id desc
+	limit $2 offset $3`
+
+	return s.queryNotificationDeliveries(ctx, query, number, limit, offset)
+}
+
+func (s *PostgresStore) queryNotificationDeliveries(ctx context.Context, query string, args ...any) ([]*NotificationDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*NotificationDelivery{}
+
+	for rows.Next() {
+		d := new(NotificationDelivery)
+
+		err := rows.Scan(&d.ID, &d.AccountNumber, &d.Channel, &d.To, &d.Kind, &d.Subject, &d.Body, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
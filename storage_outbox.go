@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func insertOutboxMessage(ctx context.Context, tx *sql.Tx, msg *OutboxMessage) error {
+	query := `
+	insert into outbox
+	(message_id, topic, message_key, payload, next_attempt_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6)
+	returning id`
+
+	return tx.QueryRowContext(ctx, query, msg.MessageID, msg.Topic, msg.Key, msg.Payload, msg.NextAttemptAt, msg.CreatedAt).Scan(&msg.ID)
+}
+
+// ClaimOutboxMessages leases the messages like ClaimDueWebhookDeliveries, so
+// concurrent relays don't publish the same attempt twice.
+func (s *PostgresStore) ClaimOutboxMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*OutboxMessage, error) {
+	query := `
+	with claimed as (
+		update outbox
+		set locked_until = $1
+		where id in (
+			select id from outbox
+			where published_at is null and next_attempt_at <= $2 and (locked_until is null or locked_until < $2)
+			order by id
+			limit $3
+			for update skip locked
+		)
+		returning id, message_id, topic, message_key, payload, attempts, next_attempt_at, last_error, published_at, created_at
+	)
+	select * from claimed order by id`
+
+	rows, err := s.db.QueryContext(ctx, query, leaseUntil, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOutboxMessages(rows)
+}
+
+// scanOutboxMessages reads the claimed messages of rows and closes it.
+func scanOutboxMessages(rows *sql.Rows) ([]*OutboxMessage, error) {
+	defer rows.Close()
+
+	messages := []*OutboxMessage{}
+
+	for rows.Next() {
+		msg := new(OutboxMessage)
+
+		err := rows.Scan(&msg.ID, &msg.MessageID, &msg.Topic, &msg.Key, &msg.Payload, &msg.Attempts, &msg.NextAttemptAt, &msg.LastError, &msg.PublishedAt, &msg.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+func (s *PostgresStore) UpdateOutboxMessage(ctx context.Context, msg *OutboxMessage) error {
+	query := `
+	update outbox
+	set attempts = $1, next_attempt_at = $2, last_error = $3, published_at = $4, locked_until = null
+	where id = $5`
+
+	_, err := s.db.ExecContext(ctx, query, msg.Attempts, msg.NextAttemptAt, msg.LastError, msg.PublishedAt, msg.ID)
+
+	return err
+}
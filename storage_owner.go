@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const accountOwnerColumns = "a.id, o.account_number, o.owner_number, o.created_at"
+
+func (s *PostgresStore) AddAccountOwner(ctx context.Context, owner *AccountOwner) error {
+	query := `
+	insert into account_owner
+	(account_number, owner_number, created_at)
+	values
+	($1, $2, $3)`
+
+	_, err := s.db.ExecContext(ctx, query, owner.AccountNumber, owner.OwnerNumber, owner.CreatedAt)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return conflictError("account with number %d already co-owns account with number %d", owner.OwnerNumber, owner.AccountNumber)
+	}
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) RemoveAccountOwner(ctx context.Context, number, owner int64) error {
+	res, err := s.db.ExecContext(ctx, "delete from account_owner where account_number = $1 and owner_number = $2", number, owner)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("account with number %d does not co-own account with number %d", owner, number)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) GetAccountOwners(ctx context.Context, number int64) ([]*AccountOwner, error) {
+	return s.queryAccountOwners(ctx, "o.account_number = $1", number)
+}
+
+func (s *PostgresStore) GetOwnedAccounts(ctx context.Context, owner int64) ([]*AccountOwner, error) {
+	return s.queryAccountOwners(ctx, "o.owner_number = $1", owner)
+}
+
+func (s *PostgresStore) queryAccountOwners(ctx context.Context, condition string, number int64) ([]*AccountOwner, error) {
+	query := "select " + accountOwnerColumns + " from account_owner o join account a on a.number = o.account_number where " + condition + " and a.deleted_at is null order by o.created_at, o.account_number, o.owner_number"
+
+	rows, err := s.db.QueryContext(ctx, query, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	owners := []*AccountOwner{}
+
+	for rows.Next() {
+		owner := new(AccountOwner)
+
+		if err := rows.Scan(&owner.AccountID, &owner.AccountNumber, &owner.OwnerNumber, &owner.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		owners = append(owners, owner)
+	}
+
+	return owners, rows.Err()
+}
+
+const transferApprovalColumns = "id, from_account, to_account, amount, requested_by, coalesce(requested_by_user, 0), decided_by, coalesce(decided_by_user, 0), status, transfer_id, error, created_at, decided_at, reference, memo, end_to_end_id"
+
+func (s *PostgresStore) CreateTransferApproval(ctx context.Context, approval *TransferApproval) error {
+	query := `
+	insert into transfer_approval
+	(from_account, to_account, amount, requested_by, requested_by_user, status, created_at, reference, memo, end_to_end_id)
+	values
+	($1, $2, $3, $4, nullif($5, 0), $6, $7, $8, $9, $10)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, approval.FromAccount, approval.ToAccount, approval.Amount, approval.RequestedBy, approval.RequestedByUser, approval.Status, approval.CreatedAt, approval.Reference, approval.Memo, approval.EndToEndID).Scan(&approval.ID)
+}
+
+func (s *PostgresStore) GetTransferApprovals(ctx context.Context, number int64, limit, offset int) ([]*TransferApproval, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+transferApprovalColumns+" from transfer_approval where from_account = $1 order by id desc limit $2 offset $3", number, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	approvals := []*TransferApproval{}
+
+	for rows.Next() {
+		approval, err := scanIntoTransferApproval(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		approvals = append(approvals, approval)
+	}
+
+	return approvals, rows.Err()
+}
+
+// DecideTransferApproval locks the approval so two owners deciding at once
+// can't both execute it.
+func (s *PostgresStore) DecideTransferApproval(ctx context.Context, id int, number int64, status TransferApprovalStatus, decidedBy int64, decidedByUser int, decidedAt time.Time) (*TransferApproval, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "select "+transferApprovalColumns+" from transfer_approval where id = $1 and from_account = $2 for update", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, notFoundError("transfer approval %d not found", id)
+	}
+
+	approval, err := scanIntoTransferApproval(rows)
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := approval.CheckDecision(status, decidedBy, decidedByUser); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update transfer_approval set status = $1, decided_by = $2, decided_by_user = nullif($3, 0), decided_at = $4 where id = $5", status, decidedBy, decidedByUser, decidedAt, id); err != nil {
+		return nil, err
+	}
+
+	approval.Status = status
+	approval.DecidedBy = &decidedBy
+	approval.DecidedByUser = decidedByUser
+	approval.DecidedAt = &decidedAt
+
+	return approval, tx.Commit()
+}
+
+func (s *PostgresStore) UpdateTransferApproval(ctx context.Context, approval *TransferApproval) error {
+	var errMsg *string
+
+	if approval.Error != "" {
+		errMsg = &approval.Error
+	}
+
+	_, err := s.db.ExecContext(ctx, "update transfer_approval set status = $1, transfer_id = $2, error = $3 where id = $4", approval.Status, approval.TransferID, errMsg, approval.ID)
+
+	return err
+}
+
+func scanIntoTransferApproval(rows *sql.Rows) (*TransferApproval, error) {
+	approval := new(TransferApproval)
+
+	var errMsg sql.NullString
+
+	err := rows.Scan(&approval.ID, &approval.FromAccount, &approval.ToAccount, &approval.Amount, &approval.RequestedBy, &approval.RequestedByUser, &approval.DecidedBy, &approval.DecidedByUser, &approval.Status, &approval.TransferID, &errMsg, &approval.CreatedAt, &approval.DecidedAt, &approval.Reference, &approval.Memo, &approval.EndToEndID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	approval.Error = errMsg.String
+
+	return approval, nil
+}
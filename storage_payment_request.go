@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const paymentRequestColumns = "id, from_account, to_account, amount, currency, status, transfer_id, reference, memo, end_to_end_id, expires_at, created_at, decided_at"
+
+func (s *PostgresStore) CreatePaymentRequest(ctx context.Context, request *PaymentRequest) error {
+	query := `
+	insert into payment_request
+	(from_account, to_account, amount, currency, status, reference, memo, end_to_end_id, expires_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, request.FromAccount, request.ToAccount, request.Amount, request.Currency, request.Status, request.Reference, request.Memo, request.EndToEndID, request.ExpiresAt, request.CreatedAt).Scan(&request.ID)
+}
+
+func (s *PostgresStore) GetPaymentRequest(ctx context.Context, id int, now time.Time) (*PaymentRequest, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+paymentRequestColumns+" from payment_request where id = $1", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := scanPaymentRequests(rows, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, notFoundError("payment request %d not found", id)
+	}
+
+	return requests[0], nil
+}
+
+func (s *PostgresStore) GetPaymentRequests(ctx context.Context, number int64, incoming bool, status PaymentRequestStatus, now time.Time, limit, offset int) ([]*PaymentRequest, error) {
+	column := "to_account"
+
+	if incoming {
+		column = "from_account"
+	}
+
+	// expired requests are stored as pending
+	query := `
+	select ` + paymentRequestColumns + `
+	from payment_request
+	where ` + column + ` = $1
+	and (
+		$2 = ''
+		or ($2 = 'pending' and status = 'pending' and expires_at > $3)
+		or ($2 = 'expired' and status = 'pending' and expires_at <= $3)
+		or status = $2
+	)
+	order by id desc
+	limit $4 offset $5`
+
+	rows, err := s.db.QueryContext(ctx, query, number, status, now, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPaymentRequests(rows, now)
+}
+
+func (s *PostgresStore) DecidePaymentRequest(ctx context.Context, id int, fromAccount int64, status PaymentRequestStatus, now time.Time) (*PaymentRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	request, err := lockPaymentRequest(ctx, tx, id, fromAccount, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decidePaymentRequest(ctx, tx, request, status, nil, now); err != nil {
+		return nil, err
+	}
+
+	return request, tx.Commit()
+}
+
+// AcceptPaymentRequest makes the transfer in the transaction that accepts
+// the request, so a transfer that fails leaves the request pending.
+func (s *PostgresStore) AcceptPaymentRequest(ctx context.Context, id int, fromAccount int64, transfer *Transfer, now time.Time) (*PaymentRequest, error) {
+	if transfer.Amount <= 0 || transfer.ToAmount <= 0 {
+		return nil, validationError("invalid amount %d", transfer.Amount)
+	}
+
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	request, err := lockPaymentRequest(ctx, tx, id, fromAccount, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := lockAccounts(ctx, tx, transfer.FromAccount, transfer.ToAccount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transferLocked(ctx, tx, accounts, transfer); err != nil {
+		return nil, err
+	}
+
+	if err := decidePaymentRequest(ctx, tx, request, PaymentRequestAccepted, &transfer.ID, now); err != nil {
+		return nil, err
+	}
+
+	return request, tx.Commit()
+}
+
+// lockPaymentRequest locks request id, which fromAccount was asked to pay,
+// after checking it can still be decided at now.
+func lockPaymentRequest(ctx context.Context, tx *sql.Tx, id int, fromAccount int64, now time.Time) (*PaymentRequest, error) {
+	rows, err := tx.QueryContext(ctx, "select "+paymentRequestColumns+" from payment_request where id = $1 and from_account = $2 for update", id, fromAccount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := scanPaymentRequests(rows, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, notFoundError("payment request %d not found", id)
+	}
+
+	if err := requests[0].CheckDecision(now); err != nil {
+		return nil, err
+	}
+
+	return requests[0], nil
+}
+
+func decidePaymentRequest(ctx context.Context, tx *sql.Tx, request *PaymentRequest, status PaymentRequestStatus, transferID *int, now time.Time) error {
+	if _, err := tx.ExecContext(ctx, "update payment_request set status = $1, transfer_id = $2, decided_at = $3 where id = $4", status, transferID, now, request.ID); err != nil {
+		return err
+	}
+
+	request.Status = status
+	request.TransferID = transferID
+	request.DecidedAt = &now
+
+	return nil
+}
+
+func scanPaymentRequests(rows *sql.Rows, now time.Time) ([]*PaymentRequest, error) {
+	defer rows.Close()
+
+	requests := []*PaymentRequest{}
+
+	for rows.Next() {
+		p := new(PaymentRequest)
+
+		if err := rows.Scan(&p.ID, &p.FromAccount, &p.ToAccount, &p.Amount, &p.Currency, &p.Status, &p.TransferID, &p.Reference, &p.Memo, &p.EndToEndID, &p.ExpiresAt, &p.CreatedAt, &p.DecidedAt); err != nil {
+			return nil, err
+		}
+
+		p.Expire(now)
+		requests = append(requests, p)
+	}
+
+	return requests, rows.Err()
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const potColumns = "id, account_number, name, target_amount, balance, round_up, weekly_amount, next_sweep_at, created_at"
+
+func (s *PostgresStore) CreatePot(ctx context.Context, pot *Pot) error {
+	query := `
+	insert into pot
+	(account_number, name, target_amount, round_up, weekly_amount, next_sweep_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7)
+	returning id`
+
+	err := s.db.QueryRowContext(ctx, query, pot.AccountNumber, pot.Name, pot.TargetAmount, pot.RoundUp, pot.WeeklyAmount, pot.NextSweepAt, pot.CreatedAt).Scan(&pot.ID)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return conflictError("account with number %d already has a pot named %s", pot.AccountNumber, pot.Name)
+	}
+
+	return pgError(err)
+}
+
+func (s *PostgresStore) GetPots(ctx context.Context, number int64) ([]*Pot, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+potColumns+" from pot where account_number = $1 order by id", number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPots(rows)
+}
+
+func (s *PostgresStore) GetPot(ctx context.Context, id int, number int64) (*Pot, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+potColumns+" from pot where id = $1 and account_number = $2", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pots, err := scanPots(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pots) == 0 {
+		return nil, notFoundError("pot %d not found", id)
+	}
+
+	return pots[0], nil
+}
+
+func (s *PostgresStore) UpdatePot(ctx context.Context, pot *Pot) error {
+	query := "update pot set name = $1, target_amount = $2, round_up = $3, weekly_amount = $4, next_sweep_at = $5 where id = $6 and account_number = $7"
+
+	res, err := s.db.ExecContext(ctx, query, pot.Name, pot.TargetAmount, pot.RoundUp, pot.WeeklyAmount, pot.NextSweepAt, pot.ID, pot.AccountNumber)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return conflictError("account with number %d already has a pot named %s", pot.AccountNumber, pot.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("pot %d not found", pot.ID)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) DeletePot(ctx context.Context, id int, number int64) (*Pot, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	pot, err := lockPot(ctx, tx, id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := lockAccounts(ctx, tx, number); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from pot where id = $1", id); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set pot_balance = pot_balance - $1 where number = $2", pot.Balance, number); err != nil {
+		return nil, pgError(err)
+	}
+
+	return pot, tx.Commit()
+}
+
+// MovePotMoney locks the pot before its account, like SweepPots, so the two
+// can't deadlock.
+func (s *PostgresStore) MovePotMoney(ctx context.Context, id int, number int64, amount int64) (*Pot, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	pot, err := lockPot(ctx, tx, id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPotMove(accounts[number], pot, amount); err != nil {
+		return nil, err
+	}
+
+	if err := movePotMoney(ctx, tx, pot, amount); err != nil {
+		return nil, err
+	}
+
+	return pot, tx.Commit()
+}
+
+// SweepPots skips pots locked by a concurrent sweeper. Each pot is swept in
+// its own transaction, so one account's sweep doesn't hold the others'
+// accounts locked.
+func (s *PostgresStore) SweepPots(ctx context.Context, now time.Time, limit int) ([]*PotSweep, error) {
+	sweeps := []*PotSweep{}
+
+	for len(sweeps) < limit {
+		sweep, err := s.sweepPot(ctx, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if sweep == nil {
+			break
+		}
+
+		sweeps = append(sweeps, sweep)
+	}
+
+	return sweeps, nil
+}
+
+// sweepPot sweeps the pot due the longest, or returns nil when none is due.
+func (s *PostgresStore) sweepPot(ctx context.Context, now time.Time) (*PotSweep, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// pots of deleted accounts wait for them to be restored
+	query := `
+	select p.id, p.account_number, p.name, p.target_amount, p.balance, p.round_up, p.weekly_amount, p.next_sweep_at, p.created_at
+	from pot p join account a on a.number = p.account_number
+	where p.next_sweep_at <= $1 and a.deleted_at is null
+	order by p.next_sweep_at
+	limit 1
+	for update of p skip locked`
+
+	rows, err := tx.QueryContext(ctx, query, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pots, err := scanPots(rows)
+
+	if err != nil || len(pots) == 0 {
+		return nil, err
+	}
+
+	pot := pots[0]
+	number := pot.AccountNumber
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sweep := &PotSweep{PotID: pot.ID, AccountNumber: number, Amount: pot.WeeklyAmount}
+
+	if checkPotMove(accounts[number], pot, pot.WeeklyAmount) == nil {
+		if err := movePotMoney(ctx, tx, pot, pot.WeeklyAmount); err != nil {
+			return nil, err
+		}
+
+		sweep.Swept = true
+	}
+
+	if _, err := tx.ExecContext(ctx, "update pot set next_sweep_at = $1 where id = $2", pot.NextSweepAt.Add(potSweepInterval), pot.ID); err != nil {
+		return nil, err
+	}
+
+	return sweep, tx.Commit()
+}
+
+func lockPot(ctx context.Context, tx *sql.Tx, id int, number int64) (*Pot, error) {
+	rows, err := tx.QueryContext(ctx, "select "+potColumns+" from pot where id = $1 and account_number = $2 for update", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pots, err := scanPots(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pots) == 0 {
+		return nil, notFoundError("pot %d not found", id)
+	}
+
+	return pots[0], nil
+}
+
+// movePotMoney moves amount between the locked pot and its account's
+// available balance.
+func movePotMoney(ctx context.Context, tx *sql.Tx, pot *Pot, amount int64) error {
+	if _, err := tx.ExecContext(ctx, "update pot set balance = balance + $1 where id = $2", amount, pot.ID); err != nil {
+		return pgError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "update account set pot_balance = pot_balance + $1 where number = $2", amount, pot.AccountNumber); err != nil {
+		return pgError(err)
+	}
+
+	pot.Balance += amount
+
+	return nil
+}
+
+func scanPots(rows *sql.Rows) ([]*Pot, error) {
+	defer rows.Close()
+
+	pots := []*Pot{}
+
+	for rows.Next() {
+		pot := new(Pot)
+
+		err := rows.Scan(&pot.ID, &pot.AccountNumber, &pot.Name, &pot.TargetAmount, &pot.Balance, &pot.RoundUp, &pot.WeeklyAmount, &pot.NextSweepAt, &pot.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pots = append(pots, pot)
+	}
+
+	return pots, rows.Err()
+}
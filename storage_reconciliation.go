@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+const reconciliationReportColumns = "id, balanced, entries, totals, unbalanced_entries, mismatched_accounts, checked_at"
+
+func (s *PostgresStore) CreateReconciliationReport(ctx context.Context, report *ReconciliationReport) error {
+	totals, err := json.Marshal(report.Totals)
+
+	if err != nil {
+		return err
+	}
+
+	unbalanced, err := json.Marshal(report.UnbalancedEntries)
+
+	if err != nil {
+		return err
+	}
+
+	mismatched, err := json.Marshal(report.MismatchedAccounts)
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+	insert into reconciliation_report
+	(balanced, entries, totals, unbalanced_entries, mismatched_accounts, checked_at)
+	values
+	($1, $2, $3, $4, $5, $6)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, report.Balanced, report.Entries, totals, unbalanced, mismatched, report.CheckedAt).Scan(&report.ID)
+}
+
+func (s *PostgresStore) GetReconciliationReports(ctx context.Context, limit, offset int) ([]*ReconciliationReport, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+reconciliationReportColumns+" from reconciliation_report order by checked_at desc, id desc limit $1 offset $2", limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reports := []*ReconciliationReport{}
+
+	for rows.Next() {
+		report, err := scanIntoReconciliationReport(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+func (s *PostgresStore) GetReconciliationReport(ctx context.Context, id int) (*ReconciliationReport, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+reconciliationReportColumns+" from reconciliation_report where id = $1", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanIntoReconciliationReport(rows)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, notFoundError("reconciliation report %d not found", id)
+}
+
+func scanIntoReconciliationReport(rows *sql.Rows) (*ReconciliationReport, error) {
+	report := new(ReconciliationReport)
+
+	var totals, unbalanced, mismatched []byte
+
+	if err := rows.Scan(&report.ID, &report.Balanced, &report.Entries, &totals, &unbalanced, &mismatched, &report.CheckedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(totals, &report.Totals); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(unbalanced, &report.UnbalancedEntries); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(mismatched, &report.MismatchedAccounts); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
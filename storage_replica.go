@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const replicaCheckInterval = 5 * time.Second
+
+// replicaLagQuery returns how far behind the primary a replica is, in
+// seconds. A replica that has replayed all it received is caught up even if
+// the primary has been idle since its last transaction.
+const replicaLagQuery = `
+select case
+	when pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() then 0
+	else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0)
+end`
+
+// replica is a read replica of the primary database.
+type replica struct {
+	db   *sql.DB
+	pool *pgxpool.Pool
+	// stale is set while the replica is unreachable or lags further behind
+	// than allowed; reads skip it until it catches up.
+	stale atomic.Bool
+}
+
+// openReplicas opens a pool to each replica. They start out stale, until
+// the first lag check finds them caught up.
+func openReplicas(ctx context.Context, cfg *Config) ([]*replica, error) {
+	replicas := []*replica{}
+
+	for _, dsn := range cfg.ReplicaURLs() {
+		pool, err := newPool(ctx, cfg, dsn)
+
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, err
+		}
+
+		r := &replica{db: stdlib.OpenDBFromPool(pool), pool: pool}
+		r.stale.Store(true)
+		replicas = append(replicas, r)
+	}
+
+	return replicas, nil
+}
+
+func closeReplicas(replicas []*replica) {
+	for _, r := range replicas {
+		r.db.Close()
+		r.pool.Close()
+	}
+}
+
+// reader returns where read-only queries that can do with slightly stale
+// data run: the next replica that is caught up, or the primary when there
+// is none.
+func (s *PostgresStore) reader() *sql.DB {
+	for range s.replicas {
+		r := s.replicas[s.nextReplica.Add(1)%uint64(len(s.replicas))]
+
+		if !r.stale.Load() {
+			return r.db
+		}
+	}
+
+	return s.db
+}
+
+// watchReplicas checks the lag of every replica every replicaCheckInterval
+// until ctx is done.
+func (s *PostgresStore) watchReplicas(ctx context.Context, maxLag time.Duration) {
+	defer close(s.replicasWatched)
+
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for i, r := range s.replicas {
+			checkReplica(ctx, i, r, maxLag)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkReplica(ctx context.Context, i int, r *replica, maxLag time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckInterval)
+	defer cancel()
+
+	var seconds float64
+	err := r.pool.QueryRow(ctx, replicaLagQuery).Scan(&seconds)
+	lag := time.Duration(seconds * float64(time.Second))
+
+	if err != nil && ctx.Err() != nil {
+		// shutting down
+		return
+	}
+
+	stale := err != nil || (maxLag > 0 && lag > maxLag)
+
+	if r.stale.Swap(stale) == stale {
+		return
+	}
+
+	if stale {
+		slog.Warn("read replica taken out of rotation", "replica", i, "lag", lag, "error", err)
+	} else {
+		slog.Info("read replica in rotation", "replica", i, "lag", lag)
+	}
+}
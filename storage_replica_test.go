@@ -0,0 +1,29 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresStoreReader(t *testing.T) {
+	primary, first, second := new(sql.DB), new(sql.DB), new(sql.DB)
+	s := &PostgresStore{db: primary}
+
+	assert.Same(t, primary, s.reader(), "without replicas everything is read from the primary")
+
+	s.replicas = []*replica{{db: first}, {db: second}}
+
+	assert.Same(t, second, s.reader())
+	assert.Same(t, first, s.reader(), "reads take turns between the replicas")
+
+	s.replicas[0].stale.Store(true)
+
+	assert.Same(t, second, s.reader())
+	assert.Same(t, second, s.reader(), "stale replicas are skipped")
+
+	s.replicas[1].stale.Store(true)
+
+	assert.Same(t, primary, s.reader(), "with every replica stale reads go to the primary")
+}
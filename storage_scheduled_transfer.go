@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const scheduledTransferColumns = "id, from_account, to_account, amount, recurrence, status, start_at, next_run_at, occurrence, attempts, last_error, created_at"
+
+func (s *PostgresStore) CreateScheduledTransfer(ctx context.Context, st *ScheduledTransfer) error {
+	query := `
+	insert into scheduled_transfer
+	(from_account, to_account, amount, recurrence, status, start_at, next_run_at, occurrence, attempts, last_error, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, st.FromAccount, st.ToAccount, st.Amount, st.Recurrence, st.Status, st.StartAt, st.NextRunAt, st.Occurrence, st.Attempts, st.LastError, st.CreatedAt).Scan(&st.ID)
+}
+
+func (s *PostgresStore) GetScheduledTransfers(ctx context.Context, accountNumber int64) ([]*ScheduledTransfer, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+scheduledTransferColumns+" from scheduled_transfer where from_account = $1 order by id desc", accountNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanScheduledTransfers(rows)
+}
+
+func (s *PostgresStore) CancelScheduledTransfer(ctx context.Context, id int, accountNumber int64) error {
+	query := "update scheduled_transfer set status = $1 where id = $2 and from_account = $3 and status = $4"
+
+	res, err := s.db.ExecContext(ctx, query, ScheduledTransferCancelled, id, accountNumber, ScheduledTransferActive)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("active scheduled transfer %d not found", id)
+	}
+
+	return nil
+}
+
+// ClaimDueScheduledTransfers leases up to limit active transfers due at now
+// until leaseUntil, so concurrent schedulers never execute the same run.
+func (s *PostgresStore) ClaimDueScheduledTransfers(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*ScheduledTransfer, error) {
+	query := `
+	update scheduled_transfer
+	set locked_until = $1
+	where id in (
+		select id from scheduled_transfer
+		where status = $2 and next_run_at <= $3 and (locked_until is null or locked_until < $3)
+		order by next_run_at
+		limit $4
+		for update skip locked
+	)
+	returning ` + scheduledTransferColumns
+
+	rows, err := s.db.QueryContext(ctx, query, leaseUntil, ScheduledTransferActive, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanScheduledTransfers(rows)
+}
+
+// RecordScheduledTransferRun stores the outcome of a run together with the
+// state the scheduler advanced st to, and releases the lease.
+func (s *PostgresStore) RecordScheduledTransferRun(ctx context.Context, st *ScheduledTransfer, run *ScheduledTransferRun) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	update scheduled_transfer
+	set status = $1, next_run_at = $2, occurrence = $3, attempts = $4, last_error = $5, locked_until = null
+	where id = $6`
+
+	if _, err := tx.ExecContext(ctx, query, st.Status, st.NextRunAt, st.Occurrence, st.Attempts, st.LastError, st.ID); err != nil {
+		return err
+	}
+
+	query = `
+	insert into scheduled_transfer_run
+	(scheduled_transfer_id, transfer_id, error, ran_at)
+	values
+	($1, $2, $3, $4)
+	returning id`
+
+	if err := tx.QueryRowContext(ctx, query, run.ScheduledTransferID, run.TransferID, run.Error, run.RanAt).Scan(&run.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanScheduledTransfers(rows *sql.Rows) ([]*ScheduledTransfer, error) {
+	defer rows.Close()
+
+	transfers := []*ScheduledTransfer{}
+
+	for rows.Next() {
+		st := new(ScheduledTransfer)
+
+		err := rows.Scan(&st.ID, &st.FromAccount, &st.ToAccount, &st.Amount, &st.Recurrence, &st.Status, &st.StartAt, &st.NextRunAt, &st.Occurrence, &st.Attempts, &st.LastError, &st.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, st)
+	}
+
+	return transfers, rows.Err()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const sessionColumns = "id, account_number, coalesce(user_id, 0), user_agent, ip, created_at, last_seen_at, revoked_at"
+
+func (s *PostgresStore) CreateSession(ctx context.Context, session *Session) error {
+	query := `
+	insert into session
+	(account_number, user_id, user_agent, ip, created_at, last_seen_at)
+	values
+	($1, nullif($2, 0), $3, $4, $5, $6)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, session.AccountNumber, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt).Scan(&session.ID)
+}
+
+func (s *PostgresStore) GetSessions(ctx context.Context, number int64, now time.Time) ([]*Session, error) {
+	query := `
+	select ` + sessionColumns + `
+	from session s
+	where s.account_number = $1
+	and s.revoked_at is null
+	and exists (
+		select 1 from refresh_token t
+		where t.session_id = s.id and t.revoked_at is null and t.expires_at > $2
+	)
+	order by s.last_seen_at desc, s.id desc`
+
+	rows, err := s.db.QueryContext(ctx, query, number, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSessions(rows)
+}
+
+func (s *PostgresStore) RevokeSessions(ctx context.Context, number int64, id int, now, deniedUntil time.Time) ([]*Session, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	update session
+	set revoked_at = $3
+	where account_number = $1
+	and ($2 = 0 or id = $2)
+	and revoked_at is null
+	returning ` + sessionColumns
+
+	rows, err := tx.QueryContext(ctx, query, number, id, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := scanSessions(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if id != 0 && len(sessions) == 0 {
+		return nil, notFoundError("session %d not found", id)
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from token_denylist where expires_at <= $1", now); err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		if _, err := tx.ExecContext(ctx, "update refresh_token set revoked_at = $1 where session_id = $2 and revoked_at is null", now, session.ID); err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx, "insert into token_denylist (session_id, expires_at) values ($1, $2)", session.ID, deniedUntil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessions, tx.Commit()
+}
+
+func (s *PostgresStore) SessionDenied(ctx context.Context, id int, now time.Time) (bool, error) {
+	var denied bool
+
+	err := s.db.QueryRowContext(ctx, "select exists (select 1 from token_denylist where session_id = $1 and expires_at > $2)", id, now).Scan(&denied)
+
+	return denied, err
+}
+
+func scanSessions(rows *sql.Rows) ([]*Session, error) {
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		session := new(Session)
+
+		if err := rows.Scan(&session.ID, &session.AccountNumber, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.RevokedAt); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"slices"
+	"strconv"
+	"time"
+)
+
+//go:embed sqlite/migrations/*.sql
+var sqliteFiles embed.FS
+
+// sqliteOptions are the DSN options of every connection: foreign keys are
+// enforced like in Postgres, and transactions take the write lock when they
+// begin, waiting for the transaction holding it, so they never deadlock
+// upgrading a read lock.
+const sqliteOptions = "_pragma=foreign_keys(1)&_pragma=journal_mode(wal)&_pragma=busy_timeout(10000)&_txlock=immediate"
+
+// SQLiteStore keeps the bank in a SQLite database file, for local and
+// embedded deployments without a Postgres server. It runs the queries of
+// PostgresStore through sqliteDriver, which rewrites their syntax for
+// SQLite, and has queries of its own where the dialects differ beyond it:
+// date arithmetic, trigram search, jsonb containment and the order of
+// updated rows. Writes are serialized, one transaction at a time.
+type SQLiteStore struct {
+	*PostgresStore
+}
+
+var _ Storage = (*SQLiteStore)(nil)
+
+func NewSQLiteStore(ctx context.Context, cfg *Config) (*SQLiteStore, error) {
+	db, err := sql.Open(sqliteDriverName, "file:"+cfg.SQLitePath+"?"+sqliteOptions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.DBMaxConns)
+	db.SetConnMaxIdleTime(cfg.DBMaxConnIdleTime)
+	db.SetConnMaxLifetime(cfg.DBMaxConnLifetime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	migrations, err := fs.Sub(sqliteFiles, "sqlite")
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{&PostgresStore{db: db, migrations: migrations}}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStore) NextClosingBalanceDay(ctx context.Context) (time.Time, error) {
+	query := "select coalesce((select pg_date(max(day), 1) from balances_history), (select pg_date(min(created_at)) from account))"
+
+	var day time.Time
+
+	if err := s.db.QueryRowContext(ctx, query).Scan(sqliteTimeColumn{&day}); err != nil {
+		return time.Time{}, err
+	}
+
+	return day, nil
+}
+
+func (s *SQLiteStore) MaterializeClosingBalances(ctx context.Context, day time.Time) (int, error) {
+	query := `
+	insert into balances_history (account_number, day, balance)
+	select a.number, pg_date($1), coalesce((
+		select t.balance
+		from transactions t
+		where t.account_number = a.number and t.created_at < pg_date($1, 1)
+		order by t.id desc
+		limit 1
+	), 0)
+	from account a
+	where a.created_at < pg_date($1, 1)
+	on conflict (account_number, day) do nothing`
+
+	res, err := s.db.ExecContext(ctx, query, day)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return int(n), err
+}
+
+func (s *SQLiteStore) GetClosingBalances(ctx context.Context, number int64, from, to time.Time) ([]*ClosingBalance, error) {
+	query := "select account_number, to_char(day, 'YYYY-MM-DD'), balance from balances_history where account_number = $1 and day >= pg_date($2) and day < pg_date($3) order by day"
+
+	return s.queryClosingBalances(ctx, query, number, from, to)
+}
+
+func (s *SQLiteStore) GetCategoryTotals(ctx context.Context, number int64, from, to time.Time) ([]*CategoryTotal, error) {
+	query := `
+	select coalesce(category, $4), date_trunc('month', created_at) as month,
+		coalesce(sum(amount) filter (where amount > 0), 0),
+		coalesce(-sum(amount) filter (where amount < 0), 0),
+		count(*)
+	from transactions
+	where account_number = $1 and created_at >= $2 and created_at < $3
+	group by 1, 2
+	order by 1, 2`
+
+	rows, err := s.db.QueryContext(ctx, query, number, from, to, uncategorizedCategory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	totals := []*CategoryTotal{}
+
+	for rows.Next() {
+		total := new(CategoryTotal)
+
+		if err := rows.Scan(&total.Category, sqliteTimeColumn{&total.Month}, &total.Credits, &total.Debits, &total.Count); err != nil {
+			return nil, err
+		}
+
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
+func (s *SQLiteStore) GetAccounts(ctx context.Context, filter AccountFilter) ([]*Account, error) {
+	return s.getAccounts(ctx, filter, "jsonb_contains(metadata, $%d)")
+}
+
+// SearchAccounts matches names as similar when pg_trgm's % operator would.
+func (s *SQLiteStore) SearchAccounts(ctx context.Context, q string, limit, offset int) ([]*Account, error) {
+	threshold := strconv.FormatFloat(searchSimilarityThreshold, 'f', -1, 64)
+	query := `select ` + accountColumns + ` from account
+		where deleted_at is null
+			and ((first_name || ' ' || last_name) like $1
+				or cast(number as text) like $1
+				or similarity(first_name, $2) >= ` + threshold + `
+				or similarity(last_name, $2) >= ` + threshold + `
+				or similarity(first_name || ' ' || last_name, $2) >= ` + threshold + `)
+		order by max(similarity(first_name, $2), similarity(last_name, $2), similarity(first_name || ' ' || last_name, $2))
+			+ case when (first_name || ' ' || last_name) like $1 or cast(number as text) like $1 then 1 else 0 end desc, id
+		limit $3 offset $4`
+
+	return s.searchAccounts(ctx, query, q, limit, offset)
+}
+
+func (s *SQLiteStore) PendingMigrations(ctx context.Context) ([]int, error) {
+	return s.pendingMigrations(ctx, "select exists (select 1 from sqlite_master where name = 'schema_migrations')")
+}
+
+func (s *SQLiteStore) GetAccountsDueForInterest(ctx context.Context, today time.Time) ([]*Account, error) {
+	query := "select " + accountColumns + " from account where type = $1 and deleted_at is null and coalesce(pg_date(interest_accrued_through, 1), pg_date(created_at)) < pg_date($2) order by id"
+
+	return s.queryAccountsDueForInterest(ctx, query, today)
+}
+
+// ClaimOutboxMessages sorts the claimed messages itself, as SQLite returns
+// updated rows in no particular order.
+func (s *SQLiteStore) ClaimOutboxMessages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*OutboxMessage, error) {
+	query := `
+	update outbox
+	set locked_until = $1
+	where id in (
+		select id from outbox
+		where published_at is null and next_attempt_at <= $2 and (locked_until is null or locked_until < $2)
+		order by id
+		limit $3
+	)
+	returning id, message_id, topic, message_key, payload, attempts, next_attempt_at, last_error, published_at, created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, leaseUntil, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := scanOutboxMessages(rows)
+
+	slices.SortFunc(messages, func(a, b *OutboxMessage) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return messages, err
+}
+
+func (s *SQLiteStore) ClaimDueWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error) {
+	query := `
+	update webhook_delivery
+	set locked_until = $1
+	where id in (
+		select d.id from webhook_delivery d
+		join webhook w on w.id = d.webhook_id
+		where w.active and d.status = $2 and d.next_attempt_at <= $3 and (d.locked_until is null or d.locked_until < $3)
+		order by d.next_attempt_at
+		limit $4
+	)
+	returning id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, coalesce(response_code, 0), last_error, created_at, delivered_at,
+		(select url from webhook w where w.id = webhook_delivery.webhook_id), (select secret from webhook w where w.id = webhook_delivery.webhook_id)`
+
+	rows, err := s.db.QueryContext(ctx, query, leaseUntil, WebhookDeliveryPending, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanClaimedWebhookDeliveries(rows)
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteDriverName is the database/sql driver of the SQLite store: the
+// modernc driver behind a translation of the Postgres store's queries.
+const sqliteDriverName = "go-bank-sqlite"
+
+// sqliteTimeLayout is how times are stored. It has the microseconds of a
+// Postgres timestamp and a fixed width, so times compare as strings.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000"
+
+func init() {
+	db, err := sql.Open("sqlite", "")
+
+	if err != nil {
+		panic(err)
+	}
+
+	sql.Register(sqliteDriverName, sqliteDriver{db.Driver()})
+
+	sqlite.MustRegisterDeterministicScalarFunction("pg_date", -1, sqlitePgDate)
+	sqlite.MustRegisterDeterministicScalarFunction("to_char", 2, sqliteToChar)
+	sqlite.MustRegisterDeterministicScalarFunction("date_trunc", 2, sqliteDateTrunc)
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2, sqliteSimilarity)
+	sqlite.MustRegisterDeterministicScalarFunction("jsonb_contains", 2, sqliteJSONBContains)
+	sqlite.MustRegisterDeterministicScalarFunction("pg_array_json", 1, sqlitePgArrayJSON)
+}
+
+// sqliteRewrites turn the Postgres syntax of the store's queries into
+// SQLite's, in order. SQLite takes a write lock on the whole database for
+// each transaction, so the row locks are dropped, and so are the casts
+// SQLite has no use for. They only rewrite keywords and parameters: where
+// the dialects differ in expressions, SQLiteStore has queries of its own.
+var sqliteRewrites = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`\s+for (update|share)( of \w+)?( skip locked)?`), ""},
+	{regexp.MustCompile(`::(bigint|int|integer|text|timestamp)\b`), ""},
+	// Postgres escapes like patterns with a backslash by default, and
+	// SQLite's like ignores the case of ASCII letters like ilike
+	{regexp.MustCompile(`\bi?like (\$\d+)`), `like $1 escape '\'`},
+	{regexp.MustCompile(`([\w.]+|\$\d+) = any\(([\w.]+|\$\d+)\)`), "$1 in (select value from json_each(pg_array_json($2)))"},
+	// SQLite only takes an offset after a limit
+	{regexp.MustCompile(`\boffset\b`), "limit -1 offset"},
+	{regexp.MustCompile(`\blimit (\$\d+|\d+) limit -1 offset\b`), "limit $1 offset"},
+	// stands in for the account_version_bump trigger, as SQLite triggers
+	// can't change the row being updated
+	{regexp.MustCompile(`^\s*update account set `), "update account set version = version + 1, "},
+}
+
+// sqliteNoops are the statements with nothing to do in SQLite: session
+// settings, table and advisory locks, and resetting serials, which SQLite
+// keeps past the largest id by itself.
+var sqliteNoops = regexp.MustCompile(`^\s*(set |reset |lock table |select pg_advisory_(un)?lock\(|select setval\()`)
+
+var sqliteQueries sync.Map
+
+// translateQuery returns query in SQLite's syntax, "" when SQLite has
+// nothing to execute.
+func translateQuery(query string) string {
+	if translated, ok := sqliteQueries.Load(query); ok {
+		return translated.(string)
+	}
+
+	translated := ""
+
+	if !sqliteNoops.MatchString(query) {
+		translated = query
+
+		for _, rewrite := range sqliteRewrites {
+			translated = rewrite.pattern.ReplaceAllString(translated, rewrite.replace)
+		}
+	}
+
+	sqliteQueries.Store(query, translated)
+
+	return translated
+}
+
+// sqliteDriver wraps the driver modernc registers as "sqlite", the one
+// holding the functions registered in init.
+type sqliteDriver struct {
+	driver.Driver
+}
+
+func (d sqliteDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteConn{conn.(sqliteDriverConn)}, nil
+}
+
+// sqliteDriverConn is what the modernc connections implement.
+type sqliteDriverConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.ConnPrepareContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// sqliteConn translates the queries, arguments, results and errors of a
+// modernc connection.
+type sqliteConn struct {
+	sqliteDriverConn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.sqliteDriverConn.PrepareContext(ctx, translateQuery(query))
+
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return &sqliteStmt{stmt}, nil
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query = translateQuery(query)
+
+	if query == "" {
+		return driver.RowsAffected(0), nil
+	}
+
+	res, err := c.sqliteDriverConn.ExecContext(ctx, query, args)
+
+	return res, sqliteError(err)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.sqliteDriverConn.QueryContext(ctx, translateQuery(query), args)
+
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return &sqliteRows{rows}, nil
+}
+
+// CheckNamedValue binds arguments the way pgx does: slices as arrays, in
+// Postgres' text format, and times without their zone.
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if v := reflect.ValueOf(nv.Value); v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			nv.Value = nil
+			return nil
+		}
+
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			nv.Value = pgArrayText(v)
+			return nil
+		}
+	}
+
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+
+	if err != nil {
+		return err
+	}
+
+	if t, ok := value.(time.Time); ok {
+		value = t.Format(sqliteTimeLayout)
+	}
+
+	nv.Value = value
+
+	return nil
+}
+
+type sqliteStmt struct {
+	driver.Stmt
+}
+
+func (s *sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	res, err := s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+
+	return res, sqliteError(err)
+}
+
+func (s *sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+
+	return &sqliteRows{rows}, nil
+}
+
+// sqliteRows returns the errors of reading rows like Postgres'. Times are
+// left to the columns: modernc returns those declared as timestamps as
+// times, the queries scan computed ones with sqliteTimeColumn.
+type sqliteRows struct {
+	driver.Rows
+}
+
+func (r *sqliteRows) Next(dest []driver.Value) error {
+	return sqliteError(r.Rows.Next(dest))
+}
+
+var (
+	sqliteUniqueColumns = regexp.MustCompile(`UNIQUE constraint failed: ([\w.]+(?:, [\w.]+)*)`)
+	sqliteCheckName     = regexp.MustCompile(`CHECK constraint failed: (\w+)`)
+)
+
+// sqliteError returns constraint violations as the Postgres errors pgError
+// and the store's queries look for, named as Postgres names them. SQLite
+// doesn't say which foreign key a row violates; the ones the store can
+// violate reference account numbers, the others rows it has just read.
+func sqliteError(err error) error {
+	var sqliteErr *sqlite.Error
+
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	pgErr := &pgconn.PgError{Severity: "ERROR", Message: sqliteErr.Error()}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		pgErr.Code = pgUniqueViolation
+
+		if m := sqliteUniqueColumns.FindStringSubmatch(sqliteErr.Error()); m != nil {
+			columns := strings.Split(m[1], ", ")
+			pgErr.TableName, _, _ = strings.Cut(columns[0], ".")
+
+			for i, column := range columns {
+				_, columns[i], _ = strings.Cut(column, ".")
+			}
+
+			pgErr.ConstraintName = pgErr.TableName + "_" + strings.Join(columns, "_") + "_key"
+		}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		pgErr.Code = pgForeignKeyViolation
+		pgErr.ConstraintName = "sqlite_account_number_fkey"
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		pgErr.Code = pgCheckViolation
+
+		if m := sqliteCheckName.FindStringSubmatch(sqliteErr.Error()); m != nil {
+			pgErr.ConstraintName = m[1]
+		}
+	default:
+		return err
+	}
+
+	return pgErr
+}
+
+var pgArrayEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// pgArrayText formats the slice v as a Postgres array, which is what
+// pgtype's scanners parse.
+func pgArrayText(v reflect.Value) string {
+	var b strings.Builder
+
+	b.WriteByte('{')
+
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		switch elem := v.Index(i); elem.Kind() {
+		case reflect.String:
+			b.WriteString(`"` + pgArrayEscaper.Replace(elem.String()) + `"`)
+		default:
+			fmt.Fprint(&b, elem.Interface())
+		}
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// sqlitePgArrayJSON converts a Postgres array to JSON for json_each, which
+// = any(...) is rewritten to use.
+func sqlitePgArrayJSON(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	text, ok := sqliteText(args[0])
+
+	if !ok {
+		return nil, nil
+	}
+
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "{"), "}")
+	elems := []any{}
+
+	for i := 0; i < len(text); i++ {
+		var elem strings.Builder
+
+		if text[i] != '"' {
+			for ; i < len(text) && text[i] != ','; i++ {
+				elem.WriteByte(text[i])
+			}
+
+			if n, err := strconv.ParseInt(elem.String(), 10, 64); err == nil {
+				elems = append(elems, n)
+			} else {
+				elems = append(elems, elem.String())
+			}
+
+			continue
+		}
+
+		for i++; i < len(text) && text[i] != '"'; i++ {
+			if text[i] == '\\' {
+				i++
+			}
+
+			if i < len(text) {
+				elem.WriteByte(text[i])
+			}
+		}
+
+		elems = append(elems, elem.String())
+		i++
+	}
+
+	encoded, err := json.Marshal(elems)
+
+	return string(encoded), err
+}
+
+// sqlitePgDate truncates a time to its day, days later when a second
+// argument is given, like a Postgres date and date + n.
+func sqlitePgDate(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	t, ok := sqliteTime(args[0])
+
+	if !ok {
+		return nil, nil
+	}
+
+	days := int64(0)
+
+	if len(args) > 1 {
+		days, _ = args[1].(int64)
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day()+int(days), 0, 0, 0, 0, time.UTC).Format(sqliteTimeLayout), nil
+}
+
+// sqliteToChar formats a time with the YYYY-MM-DD pattern of the store's
+// queries, the only one it knows.
+func sqliteToChar(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	t, ok := sqliteTime(args[0])
+
+	if !ok {
+		return nil, nil
+	}
+
+	if args[1] != "YYYY-MM-DD" {
+		return nil, fmt.Errorf("to_char: unsupported pattern %v", args[1])
+	}
+
+	return t.Format(time.DateOnly), nil
+}
+
+// sqliteDateTrunc truncates a time to the start of its month, the only
+// field of the store's queries.
+func sqliteDateTrunc(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	t, ok := sqliteTime(args[1])
+
+	if !ok {
+		return nil, nil
+	}
+
+	if args[0] != "month" {
+		return nil, fmt.Errorf("date_trunc: unsupported field %v", args[0])
+	}
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Format(sqliteTimeLayout), nil
+}
+
+func sqliteSimilarity(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	a, _ := sqliteText(args[0])
+	b, _ := sqliteText(args[1])
+
+	return trigramSimilarity(a, b), nil
+}
+
+// sqliteJSONBContains is the jsonb containment operator @>.
+func sqliteJSONBContains(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var doc, sub any
+
+	for i, target := range []*any{&doc, &sub} {
+		text, ok := sqliteText(args[i])
+
+		if !ok {
+			return nil, nil
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(target); err != nil {
+			return nil, err
+		}
+	}
+
+	return jsonContains(doc, sub), nil
+}
+
+// jsonContains follows Postgres: objects contain the pairs of the objects
+// they contain, arrays contain each element of the arrays they contain, and
+// scalars contain equal scalars.
+func jsonContains(doc, sub any) bool {
+	switch sub := sub.(type) {
+	case map[string]any:
+		obj, ok := doc.(map[string]any)
+
+		if !ok {
+			return false
+		}
+
+		for key, value := range sub {
+			if v, ok := obj[key]; !ok || !jsonContains(v, value) {
+				return false
+			}
+		}
+
+		return true
+	case []any:
+		arr, ok := doc.([]any)
+
+		if !ok {
+			return false
+		}
+
+		for _, value := range sub {
+			found := false
+
+			for _, v := range arr {
+				if jsonContains(v, value) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return doc == sub
+}
+
+func sqliteText(value driver.Value) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case []byte:
+		return string(value), true
+	}
+
+	return "", false
+}
+
+func sqliteTime(value driver.Value) (time.Time, bool) {
+	text, ok := sqliteText(value)
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(sqliteTimeLayout, text)
+
+	if err != nil {
+		// dates bound as strings, like the holidays, are kept as they are
+		t, err = time.Parse(time.DateOnly, text)
+	}
+
+	return t, err == nil
+}
+
+// sqliteTimeColumn scans a time computed by an expression, which SQLite
+// returns as text, into Time. NULL scans as the zero time.
+type sqliteTimeColumn struct {
+	Time *time.Time
+}
+
+func (c sqliteTimeColumn) Scan(value any) error {
+	if value == nil {
+		*c.Time = time.Time{}
+		return nil
+	}
+
+	t, ok := sqliteTime(value)
+
+	if !ok {
+		return fmt.Errorf("sqlite: %v is not a time", value)
+	}
+
+	*c.Time = t
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	ctx := context.Background()
+
+	cfg := testConfig()
+	cfg.Store = "sqlite"
+	cfg.SQLitePath = filepath.Join(t.TempDir(), "bank.db")
+
+	store, err := NewSQLiteStore(ctx, cfg)
+	require.Nil(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	require.Nil(t, store.Init(ctx))
+
+	return store
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteStore(t)
+
+	migrations, err := loadMigrations(store.migrations)
+	require.Nil(t, err)
+	require.NotEmpty(t, migrations)
+
+	require.Nil(t, store.MigrateDown(ctx, len(migrations)), "every down migration reverts its up")
+	require.Nil(t, store.MigrateUp(ctx), "and the schema can be built again after")
+	require.Nil(t, store.MigrateUp(ctx), "applying no pending migration is a no-op")
+}
+
+// TestSQLiteSchema checks the SQLite schema has the tables and columns the
+// Postgres migrations build.
+func TestSQLiteSchema(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	require.Nil(t, err)
+
+	want := map[string][]string{}
+
+	for _, m := range migrations {
+		for table, columns := range migrationColumns(m.Up) {
+			want[table] = append(want[table], columns...)
+		}
+	}
+
+	// the first migrations add columns if not exists, for databases that
+	// predate them
+	for table, columns := range want {
+		slices.Sort(columns)
+		want[table] = slices.Compact(columns)
+	}
+
+	store := newTestSQLiteStore(t)
+	rows, err := store.db.QueryContext(context.Background(), `
+	select m.name, c.name
+	from sqlite_master m, pragma_table_info(m.name) c
+	where m.type = 'table' and m.name not like 'sqlite_%' and m.name <> 'schema_migrations'
+	order by m.name, c.name`)
+	require.Nil(t, err)
+	defer rows.Close()
+
+	got := map[string][]string{}
+
+	for rows.Next() {
+		var table, column string
+		require.Nil(t, rows.Scan(&table, &column))
+		got[table] = append(got[table], column)
+	}
+
+	require.Nil(t, rows.Err())
+	assert.Equal(t, want, got)
+}
+
+var (
+	migrationComment     = regexp.MustCompile(`--[^\n]*`)
+	migrationCreateTable = regexp.MustCompile(`(?i)\bcreate table (?:if not exists )?(\w+)\s*\(`)
+	migrationAddColumn   = regexp.MustCompile(`(?i)\balter table (\w+) add column (?:if not exists )?(\w+)`)
+)
+
+// tableConstraints are the words starting the items of a create table that
+// aren't columns.
+var tableConstraints = []string{"constraint", "primary", "unique", "foreign", "check", "exclude"}
+
+// migrationColumns returns the columns a migration script creates tables
+// with or adds to them.
+func migrationColumns(script string) map[string][]string {
+	script = migrationComment.ReplaceAllString(script, "")
+	columns := map[string][]string{}
+
+	for _, match := range migrationCreateTable.FindAllStringSubmatchIndex(script, -1) {
+		table := script[match[2]:match[3]]
+		depth, start := 0, match[1]
+
+		for i := start; i < len(script); i++ {
+			// the items end at the commas and the parenthesis outside any
+			// type or expression
+			switch script[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				if depth > 0 {
+					depth--
+					continue
+				}
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+
+			if fields := strings.Fields(script[start:i]); len(fields) > 0 && !slices.Contains(tableConstraints, strings.ToLower(fields[0])) {
+				columns[table] = append(columns[table], fields[0])
+			}
+
+			if script[i] == ')' {
+				break
+			}
+
+			start = i + 1
+		}
+	}
+
+	for _, match := range migrationAddColumn.FindAllStringSubmatch(script, -1) {
+		columns[match[1]] = append(columns[match[1]], match[2])
+	}
+
+	return columns
+}
+
+func TestSQLiteTranslateQuery(t *testing.T) {
+	cases := map[string]string{
+		"select id from account where id = $1 for update":        "select id from account where id = $1",
+		"select count(*)::bigint from account":                   "select count(*) from account",
+		"select id from account where first_name ilike $1":       `select id from account where first_name like $1 escape '\'`,
+		"update account set balance = $1 where id = $2":          "update account set version = version + 1, balance = $1 where id = $2",
+		"select id from aml_flag order by id offset $1":          "select id from aml_flag order by id limit -1 offset $1",
+		"select id from aml_flag order by id limit $1 offset $2": "select id from aml_flag order by id limit $1 offset $2",
+		"select id from account where number = any($1)":          "select id from account where number in (select value from json_each(pg_array_json($1)))",
+	}
+
+	for query, want := range cases {
+		assert.Equal(t, want, translateQuery(query), query)
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+	runStoreCases(t, func(t *testing.T) Storage { return newTestSQLiteStore(t) })
+}
+
+func TestSQLiteClaimOutboxMessages(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteStore(t)
+	now := time.Now().UTC()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	require.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, insertOutboxMessage(ctx, tx, &OutboxMessage{MessageID: "m" + strconv.Itoa(i), Topic: outboxTopicTransfers, Payload: []byte("{}"), NextAttemptAt: now}))
+	}
+
+	require.Nil(t, tx.Commit())
+
+	messages, err := store.ClaimOutboxMessages(ctx, now, now.Add(time.Minute), 2)
+	require.Nil(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "m0", messages[0].MessageID)
+	assert.Equal(t, "m1", messages[1].MessageID)
+
+	messages, err = store.ClaimOutboxMessages(ctx, now, now.Add(time.Minute), 2)
+	require.Nil(t, err)
+	require.Len(t, messages, 1, "the leased messages aren't claimed twice")
+	assert.Equal(t, "m2", messages[0].MessageID)
+}
+
+// TestSQLiteStreamTransactions checks fn runs after the rows of its batch
+// are closed: with one connection, a query of its own would wait forever
+// otherwise.
+func TestSQLiteStreamTransactions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := newTestSQLiteStore(t)
+	store.db.SetMaxOpenConns(1)
+
+	acc := &Account{FirstName: "Alice", LastName: "Test", Number: 100001, Currency: "EUR", Type: AccountChecking, Status: AccountActive, Role: RoleCustomer, CreatedAt: time.Now().UTC()}
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	for i := 0; i < 3; i++ {
+		_, err := store.Deposit(ctx, acc.Number, 100, 0)
+		require.Nil(t, err)
+	}
+
+	balances := []int64{}
+	err := store.StreamTransactions(ctx, acc.Number, acc.CreatedAt.Add(-time.Minute), time.Now().UTC().Add(time.Minute), func(transaction *Transaction) error {
+		current, err := store.GetAccountByNumber(ctx, int(acc.Number))
+
+		if err != nil {
+			return err
+		}
+
+		balances = append(balances, current.Balance-transaction.Balance)
+
+		return nil
+	})
+
+	require.Nil(t, err)
+	assert.Equal(t, []int64{200, 100, 0}, balances)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const standingOrderColumns = "id, from_account, to_account, amount, frequency, status, start_date, next_run_at, occurrence, failures, last_error, created_at"
+
+func (s *PostgresStore) CreateStandingOrder(ctx context.Context, order *StandingOrder) error {
+	query := `
+	insert into standing_order
+	(from_account, to_account, amount, frequency, status, start_date, next_run_at, occurrence, failures, last_error, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, order.FromAccount, order.ToAccount, order.Amount, order.Frequency, order.Status, order.StartDate, order.NextRunAt, order.Occurrence, order.Failures, order.LastError, order.CreatedAt).Scan(&order.ID)
+}
+
+func (s *PostgresStore) GetStandingOrders(ctx context.Context, accountNumber int64) ([]*StandingOrder, error) {
+	rows, err := s.db.QueryContext(ctx, "select "+standingOrderColumns+" from standing_order where from_account = $1 order by id desc", accountNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanStandingOrders(rows)
+}
+
+func (s *PostgresStore) CancelStandingOrder(ctx context.Context, id int, accountNumber int64) error {
+	query := "update standing_order set status = $1 where id = $2 and from_account = $3 and status = $4"
+
+	res, err := s.db.ExecContext(ctx, query, StandingOrderCancelled, id, accountNumber, StandingOrderActive)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("active standing order %d not found", id)
+	}
+
+	return nil
+}
+
+// ClaimDueStandingOrders leases the orders like ClaimDueScheduledTransfers,
+// so concurrent runners never pay the same order twice.
+func (s *PostgresStore) ClaimDueStandingOrders(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*StandingOrder, error) {
+	query := `
+	update standing_order
+	set locked_until = $1
+	where id in (
+		select id from standing_order
+		where status = $2 and next_run_at <= $3 and (locked_until is null or locked_until < $3)
+		order by next_run_at
+		limit $4
+		for update skip locked
+	)
+	returning ` + standingOrderColumns
+
+	rows, err := s.db.QueryContext(ctx, query, leaseUntil, StandingOrderActive, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanStandingOrders(rows)
+}
+
+// UpdateStandingOrder leaves orders cancelled while they were leased
+// cancelled.
+func (s *PostgresStore) UpdateStandingOrder(ctx context.Context, order *StandingOrder) error {
+	query := `
+	update standing_order
+	set status = case when status = $1 then $2 else status end,
+		next_run_at = $3, occurrence = $4, failures = $5, last_error = $6, locked_until = null
+	where id = $7`
+
+	_, err := s.db.ExecContext(ctx, query, StandingOrderActive, order.Status, order.NextRunAt, order.Occurrence, order.Failures, order.LastError, order.ID)
+
+	return err
+}
+
+func scanStandingOrders(rows *sql.Rows) ([]*StandingOrder, error) {
+	defer rows.Close()
+
+	orders := []*StandingOrder{}
+
+	for rows.Next() {
+		order := new(StandingOrder)
+
+		err := rows.Scan(&order.ID, &order.FromAccount, &order.ToAccount, &order.Amount, &order.Frequency, &order.Status, &order.StartDate, &order.NextRunAt, &order.Occurrence, &order.Failures, &order.LastError, &order.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+func (s *PostgresStore) AddHoliday(ctx context.Context, holiday *Holiday) error {
+	_, err := s.db.ExecContext(ctx, "insert into holiday (date, name) values ($1, $2)", holiday.Date, holiday.Name)
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return conflictError("%s already is a holiday", holiday.Date)
+	}
+
+	return err
+}
+
+func (s *PostgresStore) GetHolidays(ctx context.Context) ([]*Holiday, error) {
+	rows, err := s.db.QueryContext(ctx, "select to_char(date, 'YYYY-MM-DD'), name from holiday order by date")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	holidays := []*Holiday{}
+
+	for rows.Next() {
+		holiday := new(Holiday)
+
+		if err := rows.Scan(&holiday.Date, &holiday.Name); err != nil {
+			return nil, err
+		}
+
+		holidays = append(holidays, holiday)
+	}
+
+	return holidays, rows.Err()
+}
+
+func (s *PostgresStore) DeleteHoliday(ctx context.Context, date string) error {
+	res, err := s.db.ExecContext(ctx, "delete from holiday where date = $1", date)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("holiday %s not found", date)
+	}
+
+	return nil
+}
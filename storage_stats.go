@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func (s *PostgresStore) GetAccountStats(ctx context.Context) (*AccountStats, error) {
+	query := `
+	select status, currency, count(*), coalesce(sum(balance), 0)
+	from account
+	where deleted_at is null
+	group by status, currency`
+
+	rows, err := s.reader().QueryContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	stats := newAccountStats()
+	balances := map[string]*CurrencyBalance{}
+
+	for rows.Next() {
+		var (
+			status   AccountStatus
+			currency string
+			count    int
+			balance  int64
+		)
+
+		if err := rows.Scan(&status, &currency, &count, &balance); err != nil {
+			return nil, err
+		}
+
+		stats.add(balances, status, currency, count, balance)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats.sortBalances()
+
+	return stats, nil
+}
+
+func (s *PostgresStore) GetTransferVolume(ctx context.Context, from, to time.Time) ([]*DailyTransferVolume, error) {
+	query := `
+	select to_char(created_at, 'YYYY-MM-DD'), currency, count(*), sum(amount)
+	from transfer
+	where created_at >= $1 and created_at < $2 and status in ($3, $4)
+	group by 1, 2
+	order by 1, 2`
+
+	rows, err := s.reader().QueryContext(ctx, query, from, to, TransferCompleted, TransferReversed)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	volume := []*DailyTransferVolume{}
+
+	for rows.Next() {
+		day := new(DailyTransferVolume)
+
+		if err := rows.Scan(&day.Date, &day.Currency, &day.Count, &day.Amount); err != nil {
+			return nil, err
+		}
+
+		volume = append(volume, day)
+	}
+
+	return volume, rows.Err()
+}
+
+func (s *PostgresStore) GetFailedLogins(ctx context.Context, from, to time.Time) ([]*DailyCount, error) {
+	query := `
+	select to_char(created_at, 'YYYY-MM-DD'), count(*)
+	from audit_log
+	where action = $1 and created_at >= $2 and created_at < $3
+	group by 1
+	order by 1`
+
+	rows, err := s.reader().QueryContext(ctx, query, AuditLoginFailed, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := []*DailyCount{}
+
+	for rows.Next() {
+		day := new(DailyCount)
+
+		if err := rows.Scan(&day.Date, &day.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, day)
+	}
+
+	return counts, rows.Err()
+}
+
+func (s *PostgresStore) GetLargestAccounts(ctx context.Context, currency string, limit int) ([]*Account, error) {
+	query := `select ` + accountColumns + ` from account
+	where deleted_at is null and ($1 = '' or currency = $1)
+	order by balance desc, id
+	limit $2`
+
+	rows, err := s.reader().QueryContext(ctx, query, currency, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := []*Account{}
+
+	for rows.Next() {
+		account, err := scanIntoAccount(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const termDepositColumns = "id, account_number, principal, currency, annual_rate, term_months, interest, penalty, status, matures_at, created_at, closed_at"
+
+func (s *PostgresStore) CreateTermDeposit(ctx context.Context, deposit *TermDeposit) (*Transaction, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, deposit.AccountNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[deposit.AccountNumber]
+
+	if err := checkTermDepositOpen(acc, deposit); err != nil {
+		return nil, err
+	}
+
+	query := `
+	insert into term_deposit
+	(account_number, principal, currency, annual_rate, term_months, interest, penalty, status, matures_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	returning id`
+
+	err = tx.QueryRowContext(ctx, query, deposit.AccountNumber, deposit.Principal, deposit.Currency, deposit.AnnualRate, deposit.TermMonths, deposit.Interest, deposit.Penalty, deposit.Status, deposit.MaturesAt, deposit.CreatedAt).Scan(&deposit.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := beginJournalEntry(ctx, tx, JournalTermDeposit, deposit.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := applyTransaction(ctx, tx, entry, acc, TransactionTermDeposit, -deposit.Principal, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry.post(LedgerTermDeposits, nil, acc.Currency, deposit.Principal)
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	return transaction, tx.Commit()
+}
+
+func (s *PostgresStore) GetTermDeposits(ctx context.Context, number int64) ([]*TermDeposit, error) {
+	return queryTermDeposits(ctx, s.db, "where account_number = $1 order by id", number)
+}
+
+func (s *PostgresStore) GetTermDeposit(ctx context.Context, id int, number int64) (*TermDeposit, error) {
+	deposits, err := queryTermDeposits(ctx, s.db, "where id = $1 and account_number = $2", id, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deposits) == 0 {
+		return nil, notFoundError("term deposit %d not found", id)
+	}
+
+	return deposits[0], nil
+}
+
+func (s *PostgresStore) GetTermDepositsDue(ctx context.Context, now time.Time, limit int) ([]*TermDeposit, error) {
+	return queryTermDeposits(ctx, s.db, "where status = $1 and matures_at <= $2 order by matures_at limit $3", TermDepositActive, now, limit)
+}
+
+// CloseTermDeposit locks the deposit, then its account, so the maturity
+// worker and an early withdrawal don't both pay it out.
+func (s *PostgresStore) CloseTermDeposit(ctx context.Context, id int, number int64, now time.Time, penaltyDays int) (*TermDeposit, *Transaction, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	deposits, err := queryTermDeposits(ctx, tx, "where id = $1 and account_number = $2 for update", id, number)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(deposits) == 0 {
+		return nil, nil, notFoundError("term deposit %d not found", id)
+	}
+
+	deposit := deposits[0]
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	acc := accounts[number]
+
+	if err := checkTermDepositClose(acc, deposit, now); err != nil {
+		return nil, nil, err
+	}
+
+	deposit.close(now, penaltyDays)
+
+	entry, err := beginJournalEntry(ctx, tx, JournalTermDepositPayout, now)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transaction, err := applyTransaction(ctx, tx, entry, acc, TransactionTermDepositPayout, deposit.Payout(), nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	postTermDepositPayout(entry, deposit)
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return nil, nil, err
+	}
+
+	query := "update term_deposit set interest = $1, penalty = $2, status = $3, closed_at = $4 where id = $5"
+
+	if _, err := tx.ExecContext(ctx, query, deposit.Interest, deposit.Penalty, deposit.Status, deposit.ClosedAt, id); err != nil {
+		return nil, nil, err
+	}
+
+	return deposit, transaction, tx.Commit()
+}
+
+// postTermDepositPayout balances the customer line of a payout: the
+// principal leaves the term deposits book, the interest the interest book,
+// and the penalty goes to the fees book.
+func postTermDepositPayout(entry *JournalEntry, deposit *TermDeposit) {
+	entry.post(LedgerTermDeposits, nil, deposit.Currency, -deposit.Principal)
+	entry.post(LedgerInterest, nil, deposit.Currency, -deposit.Interest)
+
+	if deposit.Penalty > 0 {
+		entry.post(LedgerFees, nil, deposit.Currency, deposit.Penalty)
+	}
+}
+
+func queryTermDeposits(ctx context.Context, db querier, where string, args ...any) ([]*TermDeposit, error) {
+	rows, err := db.QueryContext(ctx, "select "+termDepositColumns+" from term_deposit "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTermDeposits(rows)
+}
+
+func scanTermDeposits(rows *sql.Rows) ([]*TermDeposit, error) {
+	defer rows.Close()
+
+	deposits := []*TermDeposit{}
+
+	for rows.Next() {
+		deposit := new(TermDeposit)
+
+		err := rows.Scan(&deposit.ID, &deposit.AccountNumber, &deposit.Principal, &deposit.Currency, &deposit.AnnualRate, &deposit.TermMonths, &deposit.Interest, &deposit.Penalty, &deposit.Status, &deposit.MaturesAt, &deposit.CreatedAt, &deposit.ClosedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deposits = append(deposits, deposit)
+	}
+
+	return deposits, rows.Err()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *PostgresStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	return insertRefreshToken(ctx, s.db.QueryRowContext, token)
+}
+
+// RotateRefreshToken revokes the token identified by tokenHash and persists
+// next in its place for the same account. Presenting an already revoked token
+// is treated as token theft and revokes every live token of the account,
+// unless its session was revoked.
+func (s *PostgresStore) RotateRefreshToken(ctx context.Context, tokenHash string, next *RefreshToken, device Device) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	current := new(RefreshToken)
+	var sessionRevokedAt *time.Time
+
+	query := `
+	select t.id, t.account_number, coalesce(t.session_id, 0), t.expires_at, t.revoked_at, s.revoked_at, coalesce(s.user_id, 0)
+	from refresh_token t
+	left join session s on s.id = t.session_id
+	where t.token_hash = $1
+	for update of t`
+
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&current.ID, &current.AccountNumber, &current.SessionID, &current.ExpiresAt, &current.RevokedAt, &sessionRevokedAt, &next.UserID)
+
+	if err == sql.ErrNoRows {
+		return unauthorizedError("invalid refresh token")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if sessionRevokedAt != nil {
+		return unauthorizedError("session revoked")
+	}
+
+	now := time.Now().UTC()
+
+	if current.RevokedAt != nil {
+		if _, err := tx.ExecContext(ctx, "update refresh_token set revoked_at = $1 where account_number = $2 and revoked_at is null", now, current.AccountNumber); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		return unauthorizedError("invalid refresh token")
+	}
+
+	if now.After(current.ExpiresAt) {
+		return unauthorizedError("refresh token expired")
+	}
+
+	if _, err := tx.ExecContext(ctx, "update refresh_token set revoked_at = $1 where id = $2", now, current.ID); err != nil {
+		return err
+	}
+
+	if current.SessionID != 0 {
+		if _, err := tx.ExecContext(ctx, "update session set last_seen_at = $1, user_agent = $2, ip = $3 where id = $4", now, device.UserAgent, device.IP, current.SessionID); err != nil {
+			return err
+		}
+	}
+
+	next.AccountNumber = current.AccountNumber
+	next.SessionID = current.SessionID
+
+	if err := insertRefreshToken(ctx, tx.QueryRowContext, next); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "update password_reset set expires_at = $1 where account_number = $2 and used_at is null and expires_at > $1", reset.CreatedAt, reset.AccountNumber); err != nil {
+		return err
+	}
+
+	query := `
+	insert into password_reset
+	(account_number, token_hash, expires_at, created_at)
+	values
+	($1, $2, $3, $4)
+	returning id`
+
+	if err := tx.QueryRowContext(ctx, query, reset.AccountNumber, reset.TokenHash, reset.ExpiresAt, reset.CreatedAt).Scan(&reset.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) RedeemPasswordReset(ctx context.Context, tokenHash, encryptedPassword string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	reset := new(PasswordReset)
+
+	query := `
+	select id, account_number, expires_at, used_at
+	from password_reset
+	where token_hash = $1
+	for update`
+
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&reset.ID, &reset.AccountNumber, &reset.ExpiresAt, &reset.UsedAt)
+
+	if err == sql.ErrNoRows {
+		return 0, unauthorizedError("invalid password reset token")
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+
+	if reset.UsedAt != nil {
+		return 0, unauthorizedError("invalid password reset token")
+	}
+
+	if !now.Before(reset.ExpiresAt) {
+		return 0, unauthorizedError("password reset token expired")
+	}
+
+	if _, err := tx.ExecContext(ctx, "update password_reset set used_at = $1 where id = $2", now, reset.ID); err != nil {
+		return 0, err
+	}
+
+	if err := resetPassword(ctx, tx, reset.AccountNumber, encryptedPassword); err != nil {
+		return 0, err
+	}
+
+	return reset.AccountNumber, tx.Commit()
+}
+
+func insertRefreshToken(ctx context.Context, queryRow func(context.Context, string, ...any) *sql.Row, token *RefreshToken) error {
+	query := `
+	insert into refresh_token
+	(account_number, session_id, token_hash, expires_at, created_at)
+	values
+	($1, nullif($2, 0), $3, $4, $5)
+	returning id`
+
+	return queryRow(ctx, query, token.AccountNumber, token.SessionID, token.TokenHash, token.ExpiresAt, token.CreatedAt).Scan(&token.ID)
+}
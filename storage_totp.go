@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *PostgresStore) CreateTOTP(ctx context.Context, t *TOTP) error {
+	query := `
+	insert into account_totp
+	(account_number, secret, created_at)
+	values
+	($1, $2, $3)
+	on conflict (account_number) do update
+	set secret = excluded.secret, created_at = excluded.created_at
+	where account_totp.enabled_at is null`
+
+	res, err := s.db.ExecContext(ctx, query, t.AccountNumber, t.Secret, t.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return conflictError("two-factor authentication is already enabled")
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) GetTOTP(ctx context.Context, number int64) (*TOTP, error) {
+	t := new(TOTP)
+
+	query := "select account_number, secret, enabled_at, created_at from account_totp where account_number = $1"
+
+	err := s.db.QueryRowContext(ctx, query, number).Scan(&t.AccountNumber, &t.Secret, &t.EnabledAt, &t.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, notFoundError("two-factor authentication is not set up")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (s *PostgresStore) EnableTOTP(ctx context.Context, number int64, backupCodeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "update account_totp set enabled_at = $1 where account_number = $2 and enabled_at is null", time.Now().UTC(), number)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return conflictError("no pending two-factor enrollment")
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from totp_backup_code where account_number = $1", number); err != nil {
+		return err
+	}
+
+	for _, hash := range backupCodeHashes {
+		if _, err := tx.ExecContext(ctx, "insert into totp_backup_code (account_number, code_hash) values ($1, $2)", number, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) UseTOTPBackupCode(ctx context.Context, number int64, codeHash string) error {
+	query := `
+	update totp_backup_code
+	set used_at = $1
+	where account_number = $2 and code_hash = $3 and used_at is null`
+
+	res, err := s.db.ExecContext(ctx, query, time.Now().UTC(), number, codeHash)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("backup code not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// slowStatementTracer logs the SQL statements that take longer than
+// threshold. Arguments are logged by type only, since they hold account
+// numbers, names and password hashes.
+type slowStatementTracer struct {
+	threshold time.Duration
+}
+
+var _ pgx.QueryTracer = (*slowStatementTracer)(nil)
+
+type tracedStatementKey struct{}
+
+type tracedStatement struct {
+	sql   string
+	args  []any
+	start time.Time
+}
+
+func (t *slowStatementTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, tracedStatementKey{}, &tracedStatement{sql: data.SQL, args: data.Args, start: time.Now()})
+}
+
+func (t *slowStatementTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	statement, ok := ctx.Value(tracedStatementKey{}).(*tracedStatement)
+
+	if !ok {
+		return
+	}
+
+	took := time.Since(statement.start)
+
+	if took < t.threshold {
+		return
+	}
+
+	storeSlowStatementsTotal.Inc()
+	slog.WarnContext(ctx, "slow statement", "sql", statement.sql, "args", redactArgs(statement.args), "latency", took, "error", data.Err)
+}
+
+// redactArgs replaces statement arguments with their types.
+func redactArgs(args []any) []string {
+	redacted := make([]string, len(args))
+
+	for i, arg := range args {
+		if arg == nil {
+			redacted[i] = "null"
+			continue
+		}
+
+		redacted[i] = fmt.Sprintf("%T", arg)
+	}
+
+	return redacted
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowStatementTracer(t *testing.T) {
+	tracer := &slowStatementTracer{threshold: time.Hour}
+	slow := testutil.ToFloat64(storeSlowStatementsTotal)
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "select 1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	assert.Equal(t, slow, testutil.ToFloat64(storeSlowStatementsTotal))
+
+	tracer.threshold = time.Nanosecond
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "select 1"})
+	time.Sleep(time.Millisecond)
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	assert.Equal(t, slow+1, testutil.ToFloat64(storeSlowStatementsTotal))
+}
+
+func TestRedactArgs(t *testing.T) {
+	redacted := redactArgs([]any{int64(42), "Ada", nil, []byte("$2a$10$hash")})
+	assert.Equal(t, []string{"int64", "string", "null", "[]uint8"}, redacted)
+}
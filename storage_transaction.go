@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *PostgresStore) Deposit(ctx context.Context, number, amount int64, version int) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, validationError("invalid amount %d", amount)
+	}
+
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[number]
+
+	if err := acc.CheckActive(); err != nil {
+		return nil, err
+	}
+
+	if err := acc.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	entry, err := beginJournalEntry(ctx, tx, JournalDeposit, time.Now().UTC())
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := applyTransaction(ctx, tx, entry, acc, TransactionDeposit, amount, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.accountVersion = acc.Version
+	entry.post(LedgerCash, nil, acc.Currency, -amount)
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (s *PostgresStore) Withdraw(ctx context.Context, number, amount int64, version int) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, validationError("invalid amount %d", amount)
+	}
+
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[number]
+
+	if err := acc.CheckVersion(version); err != nil {
+		return nil, err
+	}
+
+	entry, err := beginJournalEntry(ctx, tx, JournalWithdrawal, time.Now().UTC())
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := debit(ctx, tx, entry, acc, TransactionWithdrawal, amount, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.accountVersion = acc.Version
+	entry.post(LedgerCash, nil, acc.Currency, amount)
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (s *PostgresStore) AdjustBalance(ctx context.Context, number, amount int64, description string) (*Transaction, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	acc := accounts[number]
+
+	if err := checkAdjustment(acc, amount); err != nil {
+		return nil, err
+	}
+
+	entry, err := beginJournalEntry(ctx, tx, JournalAdjustment, time.Now().UTC())
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := applyTransaction(ctx, tx, entry, acc, TransactionAdjustment, amount, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update transactions set description = $1 where id = $2", description, transaction.ID); err != nil {
+		return nil, err
+	}
+
+	transaction.Description = description
+	transaction.accountVersion = acc.Version
+	entry.post(LedgerAdjustments, nil, acc.Currency, -amount)
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (s *PostgresStore) GetTransactions(ctx context.Context, number int64, limit, offset int) ([]*Transaction, error) {
+	query := `
+	select ` + transactionColumns + `
+	from transactions
+	where account_number = $1
+	order by id desc
+	limit $2 offset $3`
+
+	rows, err := s.reader().QueryContext(ctx, query, number, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	transactions := []*Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+func (s *PostgresStore) GetTransactionsBefore(ctx context.Context, number int64, before *TransactionCursor, limit int) ([]*Transaction, error) {
+	args := []any{number, limit}
+	where := ""
+
+	if before != nil {
+		args = append(args, before.CreatedAt, before.ID)
+		where = "and (created_at, id) < ($3, $4)"
+	}
+
+	query := `
+	select ` + transactionColumns + `
+	from transactions
+	where account_number = $1 ` + where + `
+	order by created_at desc, id desc
+	limit $2`
+
+	rows, err := s.reader().QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	transactions := []*Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+func (s *PostgresStore) SearchTransactions(ctx context.Context, number int64, search *TransactionSearch) ([]*Transaction, error) {
+	args := []any{number, likePattern(search.Query), search.Limit, search.Offset}
+	where := ""
+
+	if search.Before != nil {
+		args = append(args, search.Before.CreatedAt, search.Before.ID)
+		where = "and (created_at, id) < ($5, $6)"
+	}
+
+	query := `
+	select ` + transactionColumns + `
+	from transactions
+	where account_number = $1
+	and (reference ilike $2 or memo ilike $2 or end_to_end_id ilike $2) ` + where + `
+	order by created_at desc, id desc
+	limit $3 offset $4`
+
+	rows, err := s.reader().QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	transactions := []*Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+func (s *PostgresStore) GetTransactionsBetween(ctx context.Context, number int64, from, to time.Time) ([]*Transaction, error) {
+	query := `
+	select ` + transactionColumns + `
+	from transactions
+	where account_number = $1 and created_at >= $2 and created_at < $3
+	order by id`
+
+	rows, err := s.reader().QueryContext(ctx, query, number, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	transactions := []*Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+// transactionStreamBatch is how many entries StreamTransactions reads at a
+// time.
+const transactionStreamBatch = 500
+
+// StreamTransactions reads the entries in batches after the created_at and
+// id of the last one it read, rather than with one query whose rows pile up
+// in the driver while a slow client reads them. Seeded and imported entries
+// are back-dated, so ids don't follow created_at and both make the cursor,
+// as in GetTransactionsBefore. Each batch is a short statement of its own,
+// within dbQueryTimeout however long the stream takes: it is read and its
+// rows closed before fn sees any of it, so a slow fn holds neither a
+// connection nor a transaction open.
+func (s *PostgresStore) StreamTransactions(ctx context.Context, number int64, from, to time.Time, fn func(*Transaction) error) error {
+	query := `
+	select ` + transactionColumns + `
+	from transactions
+	where account_number = $1 and created_at < $2 and (created_at, id) > ($3, $4)
+	order by created_at, id
+	limit $5`
+
+	// ids start at 1, so the first batch starts at the first entry created
+	// at from
+	after := &TransactionCursor{CreatedAt: from}
+
+	for {
+		rows, err := s.reader().QueryContext(ctx, query, number, to, after.CreatedAt, after.ID, transactionStreamBatch)
+
+		if err != nil {
+			return err
+		}
+
+		batch, err := readTransactionRows(rows)
+
+		if err != nil {
+			return err
+		}
+
+		for _, transaction := range batch {
+			if err := fn(transaction); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < transactionStreamBatch {
+			return nil
+		}
+
+		after = newTransactionCursor(batch[len(batch)-1])
+	}
+}
+
+// readTransactionRows reads every entry of rows and closes them.
+func readTransactionRows(rows *sql.Rows) ([]*Transaction, error) {
+	defer rows.Close()
+
+	transactions := make([]*Transaction, 0, transactionStreamBatch)
+
+	for rows.Next() {
+		transaction, err := scanIntoTransaction(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+func (s *PostgresStore) CategorizeTransaction(ctx context.Context, number int64, id int, category string) (*Transaction, error) {
+	query := `
+	update transactions
+	set category = nullif($3, '')
+	where id = $1 and account_number = $2
+	returning ` + transactionColumns
+
+	rows, err := s.db.QueryContext(ctx, query, id, number, category)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		return scanIntoTransaction(rows)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, notFoundError("transaction %d not found", id)
+}
+
+func (s *PostgresStore) GetCategoryTotals(ctx context.Context, number int64, from, to time.Time) ([]*CategoryTotal, error) {
+	query := `
+	select coalesce(category, $4), date_trunc('month', created_at) as month,
+		coalesce(sum(amount) filter (where amount > 0), 0),
+		coalesce(-sum(amount) filter (where amount < 0), 0),
+		count(*)
+	from transactions
+	where account_number = $1 and created_at >= $2 and created_at < $3
+	group by 1, 2
+	order by 1, 2`
+
+	rows, err := s.reader().QueryContext(ctx, query, number, from, to, uncategorizedCategory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	totals := []*CategoryTotal{}
+
+	for rows.Next() {
+		total := new(CategoryTotal)
+
+		if err := rows.Scan(&total.Category, &total.Month, &total.Credits, &total.Debits, &total.Count); err != nil {
+			return nil, err
+		}
+
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
+func (s *PostgresStore) GetBalanceAt(ctx context.Context, number int64, at time.Time) (int64, error) {
+	query := `
+	select balance
+	from transactions
+	where account_number = $1 and created_at < $2
+	order by id desc
+	limit 1`
+
+	var balance int64
+
+	err := s.db.QueryRowContext(ctx, query, number, at).Scan(&balance)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return balance, err
+}
+
+// lockAccounts takes row locks on the given accounts, ordered by number, and
+// returns them keyed by number.
+func lockAccounts(ctx context.Context, tx *sql.Tx, numbers ...int64) (map[int64]*Account, error) {
+	rows, err := tx.QueryContext(ctx, "select "+accountColumns+" from account where number = any($1) and deleted_at is null order by number for update", numbers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := map[int64]*Account{}
+
+	for rows.Next() {
+		account, err := scanIntoAccount(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		accounts[account.Number] = account
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, number := range numbers {
+		if _, ok := accounts[number]; !ok {
+			return nil, accountNotFoundError("account with number %d not found", number)
+		}
+	}
+
+	return accounts, nil
+}
+
+// debit takes amount from a locked active account, enforcing its limits, and
+// charges the overdraft fee when the debit leaves it below its minimum
+// balance. The caller posts the other side of the debit to entry; the fee is
+// posted to the fees book here.
+func debit(ctx context.Context, tx *sql.Tx, entry *JournalEntry, acc *Account, kind TransactionType, amount int64, counterparty *int64) (*Transaction, error) {
+	if err := acc.CheckActive(); err != nil {
+		return nil, err
+	}
+
+	if !acc.CanDebit(amount) {
+		return nil, insufficientFundsError()
+	}
+
+	transaction, err := applyTransaction(ctx, tx, entry, acc, kind, -amount, counterparty)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if fee := acc.OverdraftFeeFor(transaction.Balance); fee > 0 {
+		if _, err := applyTransaction(ctx, tx, entry, acc, TransactionFee, -fee, nil); err != nil {
+			return nil, err
+		}
+
+		entry.post(LedgerFees, nil, acc.Currency, fee)
+	}
+
+	return transaction, nil
+}
+
+// applyTransaction moves the balance of a locked account by amount, posts the
+// customer line to entry and records the account's ledger entry.
+func applyTransaction(ctx context.Context, tx *sql.Tx, entry *JournalEntry, acc *Account, kind TransactionType, amount int64, counterparty *int64) (*Transaction, error) {
+	transaction := &Transaction{
+		JournalID:     entry.ID,
+		AccountNumber: acc.Number,
+		Type:          kind,
+		Amount:        amount,
+		Counterparty:  counterparty,
+		CreatedAt:     entry.CreatedAt,
+	}
+
+	if err := tx.QueryRowContext(ctx, "update account set balance = balance + $1 where number = $2 returning balance, version", amount, acc.Number).Scan(&transaction.Balance, &acc.Version); err != nil {
+		return nil, err
+	}
+
+	acc.Balance = transaction.Balance
+	entry.postCustomer(acc, amount)
+
+	query := `
+	insert into transactions
+	(journal_id, account_number, type, amount, balance, counterparty, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7)
+	returning id`
+
+	if err := tx.QueryRowContext(ctx, query, entry.ID, acc.Number, kind, amount, transaction.Balance, counterparty, transaction.CreatedAt).Scan(&transaction.ID); err != nil {
+		return nil, err
+	}
+
+	var version int
+
+	// the account is locked, so no one else appends to its events meanwhile
+	if err := tx.QueryRowContext(ctx, "select coalesce(max(version), 0) + 1 from account_event where account_number = $1", acc.Number).Scan(&version); err != nil {
+		return nil, err
+	}
+
+	if err := appendAccountEvent(ctx, tx, newTransactionEvent(transaction, version)); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+const transactionColumns = "id, journal_id, account_number, type, amount, balance, counterparty, category, description, reference, memo, end_to_end_id, created_at"
+
+func scanIntoTransaction(rows *sql.Rows) (*Transaction, error) {
+	transaction := new(Transaction)
+
+	var category, description sql.NullString
+
+	err := rows.Scan(&transaction.ID, &transaction.JournalID, &transaction.AccountNumber, &transaction.Type, &transaction.Amount, &transaction.Balance, &transaction.Counterparty, &category, &description, &transaction.Reference, &transaction.Memo, &transaction.EndToEndID, &transaction.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.Category = category.String
+	transaction.Description = description.String
+
+	return transaction, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// Transfer debits transfer.Amount from the source account and credits
+// transfer.ToAmount to the destination account in one database transaction.
+// The currencies on the transfer must match the accounts' currencies. The
+// transfer is recorded as pending first, so one that fails is kept as
+// failed.
+func (s *PostgresStore) Transfer(ctx context.Context, transfer *Transfer) error {
+	from, to := transfer.FromAccount, transfer.ToAccount
+
+	if transfer.Amount <= 0 || transfer.ToAmount <= 0 {
+		return validationError("invalid amount %d", transfer.Amount)
+	}
+
+	if from == to {
+		return validationError("cannot transfer to the same account")
+	}
+
+	if err := s.insertPendingTransfer(ctx, transfer); err != nil {
+		return err
+	}
+
+	if err := s.executeTransfer(ctx, transfer); err != nil {
+		s.failTransfer(ctx, transfer, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) executeTransfer(ctx context.Context, transfer *Transfer) error {
+	from, to := transfer.FromAccount, transfer.ToAccount
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	// lock both rows in a stable order so concurrent transfers between the
+	// same pair of accounts cannot deadlock
+	accounts, err := lockAccounts(ctx, tx, from, to)
+
+	if err != nil {
+		return err
+	}
+
+	if err := transferLocked(ctx, tx, accounts, transfer); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertPendingTransfer records transfer before its money is moved.
+func (s *PostgresStore) insertPendingTransfer(ctx context.Context, transfer *Transfer) error {
+	transfer.Status = TransferPending
+	transfer.CreatedAt = time.Now().UTC()
+
+	query := `
+	insert into transfer
+	(from_account, to_account, amount, currency, to_amount, to_currency, rate, status, created_at, reference, memo, end_to_end_id)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, transfer.FromAccount, transfer.ToAccount, transfer.Amount, transfer.Currency, transfer.ToAmount, transfer.ToCurrency, transfer.Rate, transfer.Status, transfer.CreatedAt, transfer.Reference, transfer.Memo, transfer.EndToEndID).Scan(&transfer.ID)
+}
+
+// failTransfer marks the pending transfer failed with cause. It is recorded
+// even when the request was cancelled, since that may be why it failed.
+func (s *PostgresStore) failTransfer(ctx context.Context, transfer *Transfer, cause error) {
+	transfer.Status = TransferFailed
+	transfer.FailureReason = publicErrorMessage(cause)
+
+	_, err := s.db.ExecContext(context.WithoutCancel(ctx), "update transfer set status = $1, failure_reason = $2 where id = $3", transfer.Status, transfer.FailureReason, transfer.ID)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "recording failed transfer", "error", err, "transferId", transfer.ID)
+	}
+}
+
+func (s *PostgresStore) GetTransfer(ctx context.Context, id int) (*Transfer, error) {
+	transfers, err := queryTransfers(ctx, s.db, "where id = $1", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transfers) == 0 {
+		return nil, notFoundError("transfer %d not found", id)
+	}
+
+	return transfers[0], nil
+}
+
+func (s *PostgresStore) GetTransfers(ctx context.Context, filter *TransferFilter) ([]*Transfer, error) {
+	where := `
+	where ($1::bigint = 0 or from_account = $1 or to_account = $1)
+	and ($2 = '' or status = $2)
+	and ($3::timestamp is null or created_at >= $3)
+	and ($4::timestamp is null or created_at < $4)
+	order by created_at desc, id desc
+	limit $5 offset $6`
+
+	from := sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()}
+	to := sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()}
+
+	return queryTransfers(ctx, s.reader(), where, filter.AccountNumber, filter.Status, from, to, filter.Limit, filter.Offset)
+}
+
+// ReverseTransfer moves the money of the completed id transfer back at its
+// original amounts, overdrawing the destination account if need be, as a
+// disputed transfer is.
+func (s *PostgresStore) ReverseTransfer(ctx context.Context, id int, now time.Time) (*Transfer, error) {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	transfers, err := queryTransfers(ctx, tx, "where id = $1 for update", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(transfers) == 0 {
+		return nil, notFoundError("transfer %d not found", id)
+	}
+
+	transfer := transfers[0]
+
+	if transfer.Status != TransferCompleted {
+		return nil, conflictError("transfer %d is %s", id, transfer.Status)
+	}
+
+	from, to := transfer.FromAccount, transfer.ToAccount
+	accounts, err := lockAccounts(ctx, tx, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := accounts[from].CheckActive(); err != nil {
+		return nil, err
+	}
+
+	entry, err := beginJournalEntry(ctx, tx, JournalReversal, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := applyTransaction(ctx, tx, entry, accounts[to], TransactionReversal, -transfer.ToAmount, &from); err != nil {
+		return nil, err
+	}
+
+	postFXReversalLines(entry, transfer)
+
+	if _, err := applyTransaction(ctx, tx, entry, accounts[from], TransactionReversal, transfer.Amount, &to); err != nil {
+		return nil, err
+	}
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+
+	transfer.Status = TransferReversed
+	transfer.ReversedAt = &now
+
+	if _, err := tx.ExecContext(ctx, "update transfer set status = $1, reversed_at = $2 where id = $3", transfer.Status, transfer.ReversedAt, id); err != nil {
+		return nil, err
+	}
+
+	return transfer, tx.Commit()
+}
+
+const transferColumns = "id, from_account, to_account, amount, currency, coalesce(to_amount, amount), to_currency, coalesce(rate, ''), status, failure_reason, created_at, reversed_at, reference, memo, end_to_end_id"
+
+func queryTransfers(ctx context.Context, db querier, where string, args ...any) ([]*Transfer, error) {
+	rows, err := db.QueryContext(ctx, "select "+transferColumns+" from transfer "+where, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	transfers := []*Transfer{}
+
+	for rows.Next() {
+		t := new(Transfer)
+
+		err := rows.Scan(&t.ID, &t.FromAccount, &t.ToAccount, &t.Amount, &t.Currency, &t.ToAmount, &t.ToCurrency, &t.Rate, &t.Status, &t.FailureReason, &t.CreatedAt, &t.ReversedAt, &t.Reference, &t.Memo, &t.EndToEndID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
+
+// transferLocked moves the money of transfer between the locked accounts and
+// records it, inside the caller's transaction.
+func transferLocked(ctx context.Context, tx *sql.Tx, accounts map[int64]*Account, transfer *Transfer) error {
+	from, to := transfer.FromAccount, transfer.ToAccount
+
+	if accounts[from].Currency != transfer.Currency || accounts[to].Currency != transfer.ToCurrency {
+		return conflictError("transfer currency does not match account currency")
+	}
+
+	if err := accounts[to].CheckActive(); err != nil {
+		return err
+	}
+
+	transfer.CreatedAt = time.Now().UTC()
+
+	entry, err := beginJournalEntry(ctx, tx, JournalTransfer, transfer.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := debit(ctx, tx, entry, accounts[from], TransactionTransferOut, transfer.Amount, &to); err != nil {
+		return err
+	}
+
+	postFXLines(entry, transfer)
+
+	if _, err := applyTransaction(ctx, tx, entry, accounts[to], TransactionTransferIn, transfer.ToAmount, &from); err != nil {
+		return err
+	}
+
+	if err := referenceTransferEntries(ctx, tx, entry, transfer.TransferReference); err != nil {
+		return err
+	}
+
+	if err := commitJournalEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	return insertTransfer(ctx, tx, transfer)
+}
+
+// referenceTransferEntries copies the reference of a transfer to its entries
+// in both accounts, leaving out the fees it was charged.
+func referenceTransferEntries(ctx context.Context, tx *sql.Tx, entry *JournalEntry, ref TransferReference) error {
+	if ref.IsZero() {
+		return nil
+	}
+
+	query := `
+	update transactions
+	set reference = $1, memo = $2, end_to_end_id = $3
+	where journal_id = $4 and type in ($5, $6)`
+
+	_, err := tx.ExecContext(ctx, query, ref.Reference, ref.Memo, ref.EndToEndID, entry.ID, TransactionTransferOut, TransactionTransferIn)
+
+	return err
+}
+
+// insertTransfer records a transfer whose money has been moved, completing
+// it if it was recorded as pending, and queues its outbox message.
+func insertTransfer(ctx context.Context, tx *sql.Tx, transfer *Transfer) error {
+	transfer.Status = TransferCompleted
+
+	if transfer.ID != 0 {
+		if _, err := tx.ExecContext(ctx, "update transfer set status = $1, created_at = $2 where id = $3", transfer.Status, transfer.CreatedAt, transfer.ID); err != nil {
+			return err
+		}
+	} else {
+		query := `
+		insert into transfer
+		(from_account, to_account, amount, currency, to_amount, to_currency, rate, status, created_at, reference, memo, end_to_end_id)
+		values
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		returning id`
+
+		if err := tx.QueryRowContext(ctx, query, transfer.FromAccount, transfer.ToAccount, transfer.Amount, transfer.Currency, transfer.ToAmount, transfer.ToCurrency, transfer.Rate, transfer.Status, transfer.CreatedAt, transfer.Reference, transfer.Memo, transfer.EndToEndID).Scan(&transfer.ID); err != nil {
+			return err
+		}
+	}
+
+	msg, err := newTransferOutboxMessage(transfer)
+
+	if err != nil {
+		return err
+	}
+
+	return insertOutboxMessage(ctx, tx, msg)
+}
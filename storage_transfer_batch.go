@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TransferBatch executes the batch in one database transaction holding the
+// locks of every account involved. Each transfer of a partial batch runs in
+// a savepoint, so a refused one is rolled back alone and recorded as failed;
+// an atomic batch is rolled back as a whole by the first refusal.
+func (s *PostgresStore) TransferBatch(ctx context.Context, batch *TransferBatch) error {
+	ctx, tx, err := s.beginJournalTx(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.endJournalTx(ctx, tx)
+
+	accounts, err := lockAccounts(ctx, tx, append([]int64{batch.FromAccount}, batchDestinations(batch)...)...)
+
+	if err != nil {
+		return err
+	}
+
+	batch.CreatedAt = time.Now().UTC()
+
+	query := "insert into transfer_batch (from_account, mode, created_at) values ($1, $2, $3) returning id"
+
+	if err := tx.QueryRowContext(ctx, query, batch.FromAccount, batch.Mode, batch.CreatedAt).Scan(&batch.ID); err != nil {
+		return err
+	}
+
+	for i, item := range batch.Items {
+		if err := transferBatchItemLocked(ctx, tx, accounts, batch.Mode, item); err != nil {
+			return batchItemError(i, err)
+		}
+
+		query := `
+		insert into transfer_batch_item
+		(batch_id, position, to_account, amount, status, transfer_id, error_code, error)
+		values
+		($1, $2, $3, $4, $5, $6, $7, $8)`
+
+		if _, err := tx.ExecContext(ctx, query, batch.ID, i, item.ToAccount, item.Amount, item.Status, item.TransferID, item.ErrorCode, item.Error); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	batch.count()
+
+	return nil
+}
+
+// transferBatchItemLocked executes one transfer of a batch. Refusals fail
+// the item of a partial batch and are returned for an atomic one.
+func transferBatchItemLocked(ctx context.Context, tx *sql.Tx, accounts map[int64]*Account, mode TransferBatchMode, item *TransferBatchItem) error {
+	if mode == TransferBatchAtomic {
+		if err := transferLocked(ctx, tx, accounts, item.transfer); err != nil {
+			return err
+		}
+
+		item.complete()
+
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "savepoint transfer_batch_item"); err != nil {
+		return err
+	}
+
+	// transferLocked refuses a transfer before moving any money, so the
+	// locked accounts need no rolling back with the savepoint
+	err := transferLocked(ctx, tx, accounts, item.transfer)
+
+	var httpErr *HTTPError
+
+	if err != nil && !errors.As(err, &httpErr) {
+		return err
+	}
+
+	if err != nil {
+		if _, err := tx.ExecContext(ctx, "rollback to savepoint transfer_batch_item"); err != nil {
+			return err
+		}
+
+		item.fail(httpErr)
+
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "release savepoint transfer_batch_item"); err != nil {
+		return err
+	}
+
+	item.complete()
+
+	return nil
+}
+
+func (s *PostgresStore) GetTransferBatch(ctx context.Context, id int) (*TransferBatch, error) {
+	batch := new(TransferBatch)
+
+	query := "select id, from_account, mode, created_at from transfer_batch where id = $1"
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&batch.ID, &batch.FromAccount, &batch.Mode, &batch.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, notFoundError("transfer batch %d not found", id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "select to_account, amount, status, transfer_id, error_code, error from transfer_batch_item where batch_id = $1 order by position", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := new(TransferBatchItem)
+
+		var transferID sql.NullInt64
+
+		if err := rows.Scan(&item.ToAccount, &item.Amount, &item.Status, &transferID, &item.ErrorCode, &item.Error); err != nil {
+			return nil, err
+		}
+
+		if transferID.Valid {
+			id := int(transferID.Int64)
+			item.TransferID = &id
+		}
+
+		batch.Items = append(batch.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	batch.count()
+
+	return batch, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const transferQuoteColumns = "id, from_account, to_account, amount, currency, to_amount, to_currency, rate, fee, expires_at, created_at, used_at"
+
+func (s *PostgresStore) CreateTransferQuote(ctx context.Context, quote *TransferQuote) error {
+	query := `
+	insert into transfer_quote
+	(from_account, to_account, amount, currency, to_amount, to_currency, rate, fee, expires_at, created_at)
+	values
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, quote.FromAccount, quote.ToAccount, quote.Amount, quote.Currency, quote.ToAmount, quote.ToCurrency, quote.Rate, quote.Fee, quote.ExpiresAt, quote.CreatedAt).Scan(&quote.ID)
+}
+
+func (s *PostgresStore) UseTransferQuote(ctx context.Context, id int, fromAccount int64, now time.Time) (*TransferQuote, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "select "+transferQuoteColumns+" from transfer_quote where id = $1 and from_account = $2 for update", id, fromAccount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := scanTransferQuotes(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(quotes) == 0 {
+		return nil, notFoundError("quote %d not found", id)
+	}
+
+	quote := quotes[0]
+
+	if err := quote.CheckUse(now); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "update transfer_quote set used_at = $1 where id = $2", now, id); err != nil {
+		return nil, err
+	}
+
+	quote.UsedAt = &now
+
+	return quote, tx.Commit()
+}
+
+func scanTransferQuotes(rows *sql.Rows) ([]*TransferQuote, error) {
+	defer rows.Close()
+
+	quotes := []*TransferQuote{}
+
+	for rows.Next() {
+		q := new(TransferQuote)
+
+		if err := rows.Scan(&q.ID, &q.FromAccount, &q.ToAccount, &q.Amount, &q.Currency, &q.ToAmount, &q.ToCurrency, &q.Rate, &q.Fee, &q.ExpiresAt, &q.CreatedAt, &q.UsedAt); err != nil {
+			return nil, err
+		}
+
+		q.TotalDebit = q.Amount + q.Fee
+		quotes = append(quotes, q)
+	}
+
+	return quotes, rows.Err()
+}
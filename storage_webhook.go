@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (s *PostgresStore) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	query := `
+	insert into webhook
+	(account_number, url, secret, events, low_balance_threshold, active, created_at)
+	values
+	($1, $2, $3, $4, $5, true, $6)
+	returning id`
+
+	return s.db.QueryRowContext(ctx, query, webhook.AccountNumber, webhook.URL, webhook.Secret, webhook.Events, webhook.LowBalanceThreshold, webhook.CreatedAt).Scan(&webhook.ID)
+}
+
+// GetWebhooks lists the active webhooks of an account, or the admin webhooks
+// when accountNumber is nil.
+func (s *PostgresStore) GetWebhooks(ctx context.Context, accountNumber *int64) ([]*Webhook, error) {
+	query := `
+	select id, account_number, url, events, low_balance_threshold, created_at
+	from webhook
+	where active and account_number is not distinct from $1
+	order by id`
+
+	rows, err := s.db.QueryContext(ctx, query, accountNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	// database/sql hands arrays over as text; the pgx type map parses them
+	typeMap := pgtype.NewMap()
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		webhook := new(Webhook)
+		events := []string{}
+
+		if err := rows.Scan(&webhook.ID, &webhook.AccountNumber, &webhook.URL, typeMap.SQLScanner(&events), &webhook.LowBalanceThreshold, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		for _, event := range events {
+			webhook.Events = append(webhook.Events, EventType(event))
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook deactivates the webhook; its delivery log is kept.
+func (s *PostgresStore) DeleteWebhook(ctx context.Context, id int, accountNumber *int64) error {
+	res, err := s.db.ExecContext(ctx, "update webhook set active = false where id = $1 and active and account_number is not distinct from $2", id, accountNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return notFoundError("webhook %d not found", id)
+	}
+
+	return nil
+}
+
+// EnqueueWebhookDeliveries queues event for the account's webhooks and the
+// admin webhooks subscribed to it. balance, when set, is compared with each
+// webhook's low balance threshold.
+func (s *PostgresStore) EnqueueWebhookDeliveries(ctx context.Context, event *Event, payload []byte, balance *int64) error {
+	query := `
+	insert into webhook_delivery
+	(webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+	select id, $1, $2, $3, $4, 0, $5, $5
+	from webhook
+	where active
+	and (account_number = $6 or account_number is null)
+	and $2 = any(events)
+	and ($7::bigint is null or $7::bigint < low_balance_threshold)`
+
+	_, err := s.db.ExecContext(ctx, query, event.ID, event.Type, payload, WebhookDeliveryPending, event.CreatedAt, event.AccountNumber, balance)
+
+	return err
+}
+
+// ClaimDueWebhookDeliveries leases up to limit pending deliveries until
+// leaseUntil so concurrent dispatchers never send the same attempt twice.
+func (s *PostgresStore) ClaimDueWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error) {
+	query := `
+	with claimed as (
+		update webhook_delivery
+		set locked_until = $1
+		where id in (
+			select d.id from webhook_delivery d
+			join webhook w on w.id = d.webhook_id
+			where w.active and d.status = $2 and d.next_attempt_at <= $3 and (d.locked_until is null or d.locked_until < $3)
+			order by d.next_attempt_at
+			limit $4
+			for update of d skip locked
+		)
+		returning *
+	)
+	select c.id, c.webhook_id, c.event_id, c.event_type, c.payload, c.status, c.attempts, c.next_attempt_at, coalesce(c.response_code, 0), c.last_error, c.created_at, c.delivered_at, w.url, w.secret
+	from claimed c
+	join webhook w on w.id = c.webhook_id`
+
+	rows, err := s.db.QueryContext(ctx, query, leaseUntil, WebhookDeliveryPending, now, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanClaimedWebhookDeliveries(rows)
+}
+
+// scanClaimedWebhookDeliveries reads the claimed deliveries of rows, with
+// their webhook's URL and secret, and closes it.
+func scanClaimedWebhookDeliveries(rows *sql.Rows) ([]*WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		delivery := new(WebhookDelivery)
+
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.ResponseCode, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt, &delivery.URL, &delivery.Secret)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (s *PostgresStore) UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	query := `
+	update webhook_delivery
+	set status = $1, attempts = $2, next_attempt_at = $3, response_code = $4, last_error = $5, delivered_at = $6, locked_until = null
+	where id = $7`
+
+	_, err := s.db.ExecContext(ctx, query, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.ResponseCode, delivery.LastError, delivery.DeliveredAt, delivery.ID)
+
+	return err
+}
+
+func (s *PostgresStore) GetWebhookDeliveries(ctx context.Context, webhookID int, accountNumber *int64, limit, offset int) ([]*WebhookDelivery, error) {
+	query := `
+	select d.id, d.webhook_id, d.event_id, d.event_type, d.status, d.attempts, d.next_attempt_at, coalesce(d.response_code, 0), d.last_error, d.created_at, d.delivered_at
+	from webhook_delivery d
+	join webhook w on w.id = d.webhook_id
+	where d.webhook_id = $1 and w.account_number is not distinct from $2
+	order by d.id desc
+	limit $3 offset $4`
+
+	rows, err := s.db.QueryContext(ctx, query, webhookID, accountNumber, limit, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		delivery := new(WebhookDelivery)
+
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.ResponseCode, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	streamBufferSize = 16
+	// streamHeartbeat keeps idle streams from being cut by proxies.
+	streamHeartbeat = 15 * time.Second
+)
+
+// EventBus hands published events to the clients streaming the account's
+// updates. It only reaches the clients connected to this process.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan *Event]bool
+	closed      bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[int64]map[chan *Event]bool{}}
+}
+
+// Subscribe returns the channel the account's events arrive on and a func
+// to stop them. The channel is closed when the bus is.
+func (b *EventBus) Subscribe(number int64) (<-chan *Event, func()) {
+	ch := make(chan *Event, streamBufferSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if b.subscribers[number] == nil {
+		b.subscribers[number] = map[chan *Event]bool{}
+	}
+
+	b.subscribers[number][ch] = true
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if !b.subscribers[number][ch] {
+			return
+		}
+
+		delete(b.subscribers[number], ch)
+
+		if len(b.subscribers[number]) == 0 {
+			delete(b.subscribers, number)
+		}
+
+		close(ch)
+	}
+}
+
+// Publish never blocks: a client too slow to keep up misses events, and the
+// balance sent with its next event makes up for them.
+func (b *EventBus) Publish(ctx context.Context, event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.AccountNumber] {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("dropping event for slow stream", "event", event.Type, "accountNumber", event.AccountNumber)
+		}
+	}
+}
+
+// Close ends every stream so the server can shut down.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+	}
+
+	b.subscribers = map[int64]map[chan *Event]bool{}
+	b.closed = true
+}
+
+// publishers publishes every event to each of its publishers in turn.
+type publishers []EventPublisher
+
+func (p publishers) Publish(ctx context.Context, event *Event) {
+	stampEvent(ctx, event)
+
+	for _, publisher := range p {
+		publisher.Publish(ctx, event)
+	}
+}
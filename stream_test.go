@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	ch, unsubscribe := bus.Subscribe(1)
+
+	bus.Publish(context.Background(), &Event{Type: EventTransferCompleted, AccountNumber: 2})
+	bus.Publish(context.Background(), &Event{Type: EventTransferCompleted, AccountNumber: 1})
+
+	event := <-ch
+	assert.Equal(t, int64(1), event.AccountNumber)
+
+	// a slow subscriber misses events instead of blocking the publisher
+	for i := 0; i < streamBufferSize+1; i++ {
+		bus.Publish(context.Background(), &Event{Type: EventTransactionCreated, AccountNumber: 1})
+	}
+
+	assert.Len(t, ch, streamBufferSize)
+
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(context.Background(), &Event{Type: EventTransactionCreated, AccountNumber: 1})
+
+	closed, _ := bus.Subscribe(1)
+	bus.Close()
+
+	_, ok := <-closed
+	assert.False(t, ok)
+
+	afterClose, _ := bus.Subscribe(1)
+	_, ok = <-afterClose
+	assert.False(t, ok)
+}
+
+func TestPublishersStampEventsOnce(t *testing.T) {
+	a, b := NewEventBus(), NewEventBus()
+	chA, _ := a.Subscribe(1)
+	chB, _ := b.Subscribe(1)
+
+	publishers{a, b}.Publish(context.Background(), &Event{Type: EventAccountCreated, AccountNumber: 1})
+
+	eventA, eventB := <-chA, <-chB
+	assert.NotEmpty(t, eventA.ID)
+	assert.Equal(t, eventA.ID, eventB.ID)
+}
+
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+func readSSEEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	var event sseEvent
+
+	for {
+		line, err := r.ReadString('\n')
+		require.Nil(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && event.Event != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestAPIStream(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+
+	server := httptest.NewServer(api.handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/account/"+strconv.Itoa(alice.ID)+"/stream", nil)
+	require.Nil(t, err)
+	req.Header.Set("x-jwt-token", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body := bufio.NewReader(resp.Body)
+
+	event := readSSEEvent(t, body)
+	assert.Equal(t, "balance", event.Event)
+	assert.JSONEq(t, `{"balance":0,"currency":"USD"}`, event.Data)
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	event = readSSEEvent(t, body)
+	assert.Equal(t, string(EventTransactionCreated), event.Event)
+	assert.NotEmpty(t, event.ID)
+
+	published := new(Event)
+	require.Nil(t, json.Unmarshal([]byte(event.Data), published))
+	assert.Equal(t, event.ID, published.ID)
+	assert.Equal(t, alice.Number, published.AccountNumber)
+
+	event = readSSEEvent(t, body)
+	assert.Equal(t, "balance", event.Event)
+	assert.JSONEq(t, `{"balance":1000,"currency":"USD"}`, event.Data)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 400})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	event = readSSEEvent(t, body)
+	assert.Equal(t, string(EventTransferCompleted), event.Event)
+
+	event = readSSEEvent(t, body)
+	assert.Equal(t, "balance", event.Event)
+	assert.JSONEq(t, `{"balance":600,"currency":"USD"}`, event.Data)
+
+	// other accounts' streams are off limits
+	req, err = http.NewRequestWithContext(ctx, "GET", server.URL+"/account/"+strconv.Itoa(bob.ID)+"/stream", nil)
+	require.Nil(t, err)
+	req.Header.Set("x-jwt-token", token)
+
+	forbidden, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	forbidden.Body.Close()
+
+	assert.Equal(t, http.StatusForbidden, forbidden.StatusCode)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	termDepositInterval  = time.Hour
+	termDepositBatchSize = 50
+
+	maxTermDepositMonths = 120
+
+	defaultTermDepositRates       = "3=0.03,6=0.035,12=0.04"
+	defaultTermDepositPenaltyDays = 90
+)
+
+// TermDepositProduct is a term deposits can be opened for and the fixed
+// annual rate they earn.
+type TermDepositProduct struct {
+	TermMonths int    `json:"termMonths"`
+	AnnualRate string `json:"annualRate"`
+}
+
+// parseTermDepositProducts parses a comma separated list of
+// termMonths=rate pairs, e.g. 6=0.035, sorted by term.
+func parseTermDepositProducts(s string) ([]*TermDepositProduct, error) {
+	products := []*TermDepositProduct{}
+	terms := map[int]bool{}
+
+	for _, item := range splitList(s) {
+		term, rate, ok := strings.Cut(item, "=")
+		months, err := strconv.Atoi(strings.TrimSpace(term))
+		rate = strings.TrimSpace(rate)
+
+		if !ok || err != nil || months < 1 || months > maxTermDepositMonths {
+			return nil, fmt.Errorf("%q is not termMonths=rate with a term of 1 to %d months", item, maxTermDepositMonths)
+		}
+
+		if apr, ok := new(big.Rat).SetString(rate); !loanRatePattern.MatchString(rate) || !ok || apr.Cmp(big.NewRat(1, 1)) > 0 {
+			return nil, fmt.Errorf("%q is not a rate between 0 and 1", rate)
+		}
+
+		if terms[months] {
+			return nil, fmt.Errorf("term %d is offered twice", months)
+		}
+
+		terms[months] = true
+		products = append(products, &TermDepositProduct{TermMonths: months, AnnualRate: rate})
+	}
+
+	sort.Slice(products, func(i, j int) bool { return products[i].TermMonths < products[j].TermMonths })
+
+	return products, nil
+}
+
+// NewTermDeposit locks req.Amount of acc for the product's term from now.
+// Interest is the simple interest earned over the whole term.
+func NewTermDeposit(req *TermDepositRequest, product *TermDepositProduct, acc *Account, now time.Time) *TermDeposit {
+	rate, _ := new(big.Rat).SetString(product.AnnualRate)
+	interest := new(big.Rat).Mul(big.NewRat(req.Amount, 1), rate)
+	interest.Mul(interest, big.NewRat(int64(product.TermMonths), 12))
+
+	return &TermDeposit{
+		AccountNumber: acc.Number,
+		Principal:     req.Amount,
+		Currency:      acc.Currency,
+		AnnualRate:    product.AnnualRate,
+		TermMonths:    product.TermMonths,
+		Interest:      floorRat(interest),
+		Status:        TermDepositActive,
+		MaturesAt:     now.AddDate(0, product.TermMonths, 0),
+		CreatedAt:     now,
+	}
+}
+
+// close settles the deposit on now. From maturity on it pays the whole
+// interest; before, the interest accrued by then less penaltyDays of
+// interest, which may eat into the principal.
+func (d *TermDeposit) close(now time.Time, penaltyDays int) {
+	d.ClosedAt = &now
+
+	if !now.Before(d.MaturesAt) {
+		d.Status = TermDepositMatured
+		return
+	}
+
+	rate, _ := new(big.Rat).SetString(d.AnnualRate)
+	daily := new(big.Rat).Mul(big.NewRat(d.Principal, 1), rate)
+	daily.Quo(daily, big.NewRat(365, 1))
+	days := int64(now.Sub(d.CreatedAt) / (24 * time.Hour))
+
+	d.Status = TermDepositWithdrawn
+	d.Interest = min(floorRat(new(big.Rat).Mul(daily, big.NewRat(days, 1))), d.Interest)
+	d.Penalty = min(ceilRat(new(big.Rat).Mul(daily, big.NewRat(int64(penaltyDays), 1))), d.Principal+d.Interest)
+}
+
+// Payout is what closing the deposit pays back into its account.
+func (d *TermDeposit) Payout() int64 {
+	return d.Principal + d.Interest - d.Penalty
+}
+
+// checkTermDepositOpen checks acc can spare the deposit's principal.
+func checkTermDepositOpen(acc *Account, deposit *TermDeposit) error {
+	if err := acc.CheckActive(); err != nil {
+		return err
+	}
+
+	if !acc.CanDebit(deposit.Principal) {
+		return insufficientFundsError()
+	}
+
+	return nil
+}
+
+// checkTermDepositClose refuses to close a deposit twice, and early
+// withdrawals into an account that isn't active.
+func checkTermDepositClose(acc *Account, deposit *TermDeposit, now time.Time) error {
+	if deposit.Status != TermDepositActive {
+		return conflictError("term deposit %d is %s", deposit.ID, deposit.Status)
+	}
+
+	if now.Before(deposit.MaturesAt) {
+		return acc.CheckActive()
+	}
+
+	return nil
+}
+
+// floorRat rounds a non-negative v down to an integer.
+func floorRat(v *big.Rat) int64 {
+	return new(big.Int).Quo(v.Num(), v.Denom()).Int64()
+}
+
+// TermDepositMaturer pays matured term deposits back into their accounts
+// with their interest.
+type TermDepositMaturer struct {
+	store  Storage
+	events EventPublisher
+}
+
+func NewTermDepositMaturer(store Storage, events EventPublisher) *TermDepositMaturer {
+	return &TermDepositMaturer{store: store, events: events}
+}
+
+func (m *TermDepositMaturer) Run(ctx context.Context) {
+	ticker := time.NewTicker(termDepositInterval)
+	defer ticker.Stop()
+
+	for {
+		m.matureDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *TermDepositMaturer) matureDue(ctx context.Context, now time.Time) {
+	deposits, err := m.store.GetTermDepositsDue(ctx, now, termDepositBatchSize)
+
+	if err != nil {
+		slog.Error("loading matured term deposits", "error", err)
+		return
+	}
+
+	for _, due := range deposits {
+		deposit, transaction, err := m.store.CloseTermDeposit(ctx, due.ID, due.AccountNumber, now, 0)
+
+		if err != nil {
+			slog.Error("paying out term deposit", "error", err, "termDepositId", due.ID)
+			continue
+		}
+
+		m.events.Publish(ctx, &Event{Type: EventTransactionCreated, AccountNumber: deposit.AccountNumber, Data: transaction})
+		publishBalanceEvent(ctx, m.events, deposit.AccountNumber, transaction.Balance, deposit.Currency)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTermDepositProducts(t *testing.T) {
+	products, err := parseTermDepositProducts("12=0.04, 3=0.03,6=0.035")
+	require.Nil(t, err)
+	require.Len(t, products, 3)
+	assert.Equal(t, &TermDepositProduct{TermMonths: 3, AnnualRate: "0.03"}, products[0])
+	assert.Equal(t, 12, products[2].TermMonths)
+
+	products, err = parseTermDepositProducts("")
+	require.Nil(t, err)
+	assert.Empty(t, products, "no term deposits are offered")
+
+	for _, rates := range []string{"12", "0=0.04", "121=0.04", "12=4%", "12=1.5", "12=0.04,12=0.05"} {
+		_, err := parseTermDepositProducts(rates)
+		assert.NotNil(t, err, rates)
+	}
+}
+
+func TestTermDepositClose(t *testing.T) {
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	acc := &Account{Number: 42, Currency: "USD"}
+	product := &TermDepositProduct{TermMonths: 12, AnnualRate: "0.04"}
+
+	deposit := NewTermDeposit(&TermDepositRequest{Amount: 100000, TermMonths: 12}, product, acc, now)
+	assert.Equal(t, int64(4000), deposit.Interest)
+	assert.Equal(t, now.AddDate(1, 0, 0), deposit.MaturesAt)
+
+	matured := *deposit
+	matured.close(deposit.MaturesAt, 90)
+	assert.Equal(t, TermDepositMatured, matured.Status)
+	assert.Equal(t, int64(104000), matured.Payout())
+
+	early := *deposit
+	early.close(now.AddDate(0, 0, 100), 90)
+	assert.Equal(t, TermDepositWithdrawn, early.Status)
+	assert.Equal(t, int64(1095), early.Interest, "100 days of interest")
+	assert.Equal(t, int64(987), early.Penalty, "90 days of interest, rounded up")
+	assert.Equal(t, int64(100108), early.Payout())
+
+	early = *deposit
+	early.close(now.AddDate(0, 0, 10), 90)
+	assert.Less(t, early.Payout(), deposit.Principal, "the penalty eats into the principal")
+}
+
+func TestMemoryStoreTermDeposits(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+
+	acc := &Account{Number: 42, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	_, err := store.Deposit(ctx, 42, 60000, 0)
+	require.Nil(t, err)
+
+	product := &TermDepositProduct{TermMonths: 3, AnnualRate: "0.03"}
+
+	_, err = store.CreateTermDeposit(ctx, NewTermDeposit(&TermDepositRequest{Amount: 70000, TermMonths: 3}, product, acc, now))
+	assert.ErrorContains(t, err, "insufficient")
+
+	deposit := NewTermDeposit(&TermDepositRequest{Amount: 50000, TermMonths: 3}, product, acc, now)
+	transaction, err := store.CreateTermDeposit(ctx, deposit)
+	require.Nil(t, err)
+	assert.Equal(t, TransactionTermDeposit, transaction.Type)
+	assert.Equal(t, int64(10000), transaction.Balance)
+
+	due, err := store.GetTermDepositsDue(ctx, now.AddDate(0, 2, 0), 10)
+	require.Nil(t, err)
+	assert.Empty(t, due)
+
+	maturity := now.AddDate(0, 3, 0)
+	NewTermDepositMaturer(store, NewEventBus()).matureDue(ctx, maturity)
+
+	paid, err := store.GetTermDeposit(ctx, deposit.ID, 42)
+	require.Nil(t, err)
+	assert.Equal(t, TermDepositMatured, paid.Status)
+	assert.Equal(t, maturity, *paid.ClosedAt)
+
+	updated, err := store.GetAccountByNumber(ctx, 42)
+	require.Nil(t, err)
+	assert.Equal(t, int64(60375), updated.Balance, "the principal and 375 of interest")
+
+	_, _, err = store.CloseTermDeposit(ctx, deposit.ID, 42, maturity, 0)
+	assert.ErrorContains(t, err, "is matured", "deposits are paid out once")
+
+	due, err = store.GetTermDepositsDue(ctx, maturity, 10)
+	require.Nil(t, err)
+	assert.Empty(t, due)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestAPITermDeposits(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID) + "/term-deposits"
+
+	rec := api.do("GET", "/term-deposits/products", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var products []*TermDepositProduct
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&products))
+	assert.Len(t, products, 3)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 10000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path, token, TermDepositRequest{Amount: 5000, TermMonths: 7})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "no product for 7 months")
+
+	rec = api.do("POST", path, token, TermDepositRequest{Amount: 20000, TermMonths: 6})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeInsufficientFunds))
+
+	rec = api.do("POST", path, token, TermDepositRequest{Amount: 8000, TermMonths: 12})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	deposit := new(TermDeposit)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(deposit))
+	assert.Equal(t, "0.04", deposit.AnnualRate)
+	assert.Equal(t, int64(320), deposit.Interest)
+	depositPath := path + "/" + strconv.Itoa(deposit.ID)
+
+	rec = api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/withdraw", api.tellerToken(), AmountRequest{Amount: 5000})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "the deposit can't be spent")
+
+	rec = api.do("GET", path, token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var deposits []*TermDeposit
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&deposits))
+	require.Len(t, deposits, 1)
+	assert.Equal(t, TermDepositActive, deposits[0].Status)
+
+	rec = api.do("GET", depositPath, api.login(bob, "bob-pw"), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = api.do("POST", depositPath+"/withdraw", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(deposit))
+	assert.Equal(t, TermDepositWithdrawn, deposit.Status)
+	assert.Zero(t, deposit.Interest)
+	assert.Equal(t, int64(79), deposit.Penalty, "90 days of interest on 8000 at 4%")
+
+	rec = api.do("GET", "/account/"+strconv.Itoa(alice.ID), token, nil)
+	assert.Contains(t, rec.Body.String(), `"balance":9921`)
+
+	rec = api.do("POST", depositPath+"/withdraw", token, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
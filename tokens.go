@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	accessTokenKey contextKey = "accessToken"
+
+	// keyReloadInterval is how often keys are reloaded from a file or a KMS.
+	keyReloadInterval = time.Minute
+)
+
+// TokenIssuer signs and checks access tokens and sets the lifetime of
+// refresh tokens, as configured at startup.
+type TokenIssuer struct {
+	keySource KeySource
+	// keys is nil until they first load.
+	keys             atomic.Pointer[keyring]
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	statementLinkTTL time.Duration
+}
+
+func NewTokenIssuer(cfg *Config) *TokenIssuer {
+	t := &TokenIssuer{
+		keySource:        NewKeySource(cfg),
+		accessTokenTTL:   cfg.AccessTokenTTL,
+		refreshTokenTTL:  cfg.RefreshTokenTTL,
+		statementLinkTTL: cfg.StatementLinkTTL,
+	}
+
+	if err := t.ReloadKeys(context.Background()); err != nil {
+		slog.Error("failed to load signing keys", "error", err)
+	}
+
+	return t
+}
+
+// ReloadKeys loads the signing keys again. If they can't be loaded, or are
+// invalid, the keys loaded before stay in use.
+func (t *TokenIssuer) ReloadKeys(ctx context.Context) error {
+	keys, err := t.keySource.SigningKeys(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	ring, err := newKeyring(keys)
+
+	if err != nil {
+		return err
+	}
+
+	if old := t.keys.Swap(ring); old == nil || old.signing.ID != ring.signing.ID {
+		slog.InfoContext(ctx, "signing access tokens", "kid", ring.signing.ID, "keys", len(ring.byID))
+	}
+
+	return nil
+}
+
+// Run reloads keys from a file or a KMS every keyReloadInterval until ctx is
+// cancelled, so rotating them needs no restart.
+func (t *TokenIssuer) Run(ctx context.Context) {
+	if _, static := t.keySource.(StaticKeySource); static {
+		return
+	}
+
+	ticker := time.NewTicker(keyReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.ReloadKeys(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to reload signing keys", "error", err)
+			}
+		}
+	}
+}
+
+// Ready reports whether signing keys are loaded.
+func (t *TokenIssuer) Ready() error {
+	if t.keys.Load() == nil {
+		return errors.New("no signing keys loaded")
+	}
+
+	return nil
+}
+
+// CreateAccessToken signs a token for account with the newest key, named
+// in the kid header. Tokens issued to a session carry its id in the sid
+// claim, so they can be denied when it is revoked.
+func (t *TokenIssuer) CreateAccessToken(account *Account, sessionID int) (string, error) {
+	return t.createAccessToken(account, nil, sessionID)
+}
+
+// createAccessToken is CreateAccessToken for a business user of account
+// unless user is nil. Tokens of users carry their id in the uid claim.
+func (t *TokenIssuer) createAccessToken(account *Account, user *BusinessUser, sessionID int) (string, error) {
+	ring := t.keys.Load()
+
+	if ring == nil {
+		return "", errors.New("no signing keys loaded")
+	}
+
+	claims := jwt.MapClaims{
+		"exp":           time.Now().Add(t.accessTokenTTL).Unix(),
+		"accountNumber": account.Number,
+		"role":          account.Role,
+	}
+
+	if sessionID != 0 {
+		claims["sid"] = sessionID
+	}
+
+	if user != nil {
+		claims["role"] = RoleCustomer
+		claims["uid"] = user.ID
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = ring.signing.ID
+
+	return token.SignedString([]byte(ring.signing.Secret))
+}
+
+func (t *TokenIssuer) NewRefreshToken(accountNumber int64) (*RefreshToken, string, error) {
+	return NewRefreshToken(accountNumber, t.refreshTokenTTL)
+}
+
+// AccountNumber validates an access token against the key named in its
+// kid header, which must not be retired, and returns the account it was
+// issued to.
+func (t *TokenIssuer) AccountNumber(tokenString string) (int64, error) {
+	token, err := t.Verify(tokenString)
+	return token.number, err
+}
+
+// Verify is AccountNumber that also returns the session the token was
+// issued to, 0 for tokens issued before sessions were tracked, and the
+// business user, 0 for the account's holder.
+func (t *TokenIssuer) Verify(tokenString string) (accessToken, error) {
+	token, err := jwt.Parse(tokenString, t.verifyingKey)
+
+	if err != nil || !token.Valid {
+		return accessToken{number: -1}, unauthorizedError("permission denied")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	number, ok := claims["accountNumber"].(float64)
+
+	if !ok {
+		return accessToken{number: -1}, unauthorizedError("permission denied")
+	}
+
+	sessionID, _ := claims["sid"].(float64)
+	userID, _ := claims["uid"].(float64)
+
+	return accessToken{number: int64(number), sessionID: int(sessionID), userID: int(userID)}, nil
+}
+
+// verifyingKey is the jwt.Keyfunc of the tokens the issuer signed.
+func (t *TokenIssuer) verifyingKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+
+	ring := t.keys.Load()
+
+	if ring == nil {
+		return nil, errors.New("no signing keys loaded")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ring.verifying(kid)
+
+	if !ok {
+		return nil, fmt.Errorf("unknown or retired signing key %q", kid)
+	}
+
+	return []byte(key.Secret), nil
+}
+
+// checkAccessToken verifies an access token and denies it if its session
+// has been revoked.
+func checkAccessToken(ctx context.Context, tokens *TokenIssuer, sessions SessionRepository, tokenString string) (accessToken, error) {
+	token, err := tokens.Verify(tokenString)
+
+	if err != nil || token.sessionID == 0 {
+		return token, err
+	}
+
+	denied, err := sessions.SessionDenied(ctx, token.sessionID, time.Now().UTC())
+
+	if err != nil {
+		return accessToken{number: -1}, err
+	}
+
+	if denied {
+		return accessToken{number: -1}, unauthorizedError("session revoked")
+	}
+
+	return token, nil
+}
+
+// TokenSource is where a request carried its access token.
+type TokenSource string
+
+const (
+	TokenSourceBearer TokenSource = "bearer"
+	// TokenSourceHeader is the x-jwt-token header, which clients used
+	// before Authorization was accepted.
+	TokenSourceHeader TokenSource = "x-jwt-token"
+)
+
+// bearerToken returns the token of an Authorization value with the Bearer
+// scheme, whose name is case insensitive.
+func bearerToken(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+// accessTokenFromHeader reads the access token from the Authorization
+// header, falling back to x-jwt-token. Authorization values of other
+// schemes, e.g. a gateway's Basic credentials, are ignored.
+func accessTokenFromHeader(header http.Header) (string, TokenSource) {
+	if token, ok := bearerToken(header.Get("Authorization")); ok {
+		return token, TokenSourceBearer
+	}
+
+	if token := header.Get("x-jwt-token"); token != "" {
+		return token, TokenSourceHeader
+	}
+
+	return "", ""
+}
+
+type accessToken struct {
+	number    int64
+	sessionID int
+	// userID is the business user the token was issued to, and user that
+	// user as loaded by withBusinessUser.
+	userID int
+	user   *BusinessUser
+	source TokenSource
+	err    error
+}
+
+// withAccessToken checks the access token once per request, so the
+// handlers and the other middlewares can ask for the caller with
+// getAccountNumberFromToken. Tokens of revoked sessions are denied here.
+func withAccessToken(tokens *TokenIssuer, sessions SessionRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, source := accessTokenFromHeader(r.Header)
+			token, err := checkAccessToken(r.Context(), tokens, sessions, tokenString)
+			token.source, token.err = source, err
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accessTokenKey, token)))
+		})
+	}
+}
+
+// getAccountNumberFromToken returns the account of the request's access
+// token, as checked by withAccessToken.
+func getAccountNumberFromToken(r *http.Request) (int64, error) {
+	token, ok := r.Context().Value(accessTokenKey).(accessToken)
+
+	if !ok {
+		return -1, unauthorizedError("permission denied")
+	}
+
+	return token.number, token.err
+}
+
+// getTokenSource returns where the request carried a valid access token,
+// empty when it had none.
+func getTokenSource(r *http.Request) TokenSource {
+	token, _ := r.Context().Value(accessTokenKey).(accessToken)
+
+	if token.err != nil {
+		return ""
+	}
+
+	return token.source
+}
+
+// getSessionIDFromToken returns the session of the request's access token,
+// 0 if it has none.
+func getSessionIDFromToken(r *http.Request) int {
+	token, _ := r.Context().Value(accessTokenKey).(accessToken)
+	return token.sessionID
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base32"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpIssuer          = "go-bank"
+	totpBackupCodeCount = 10
+)
+
+// totpValidateOpts accepts the codes of the previous and next 30 second
+// window, so a little clock drift doesn't lock the user out.
+var totpValidateOpts = totp.ValidateOpts{
+	Period:    30,
+	Skew:      1,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// newTOTP generates a secret for the account and the enrollment returned to
+// the client to set up its authenticator app.
+func newTOTP(account *Account) (*TOTP, *TOTPEnrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: strconv.FormatInt(account.Number, 10),
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := &TOTP{
+		AccountNumber: account.Number,
+		Secret:        key.Secret(),
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	return t, &TOTPEnrollment{Secret: key.Secret(), URI: key.URL()}, nil
+}
+
+func validTOTPCode(secret, code string, at time.Time) bool {
+	ok, err := totp.ValidateCustom(code, secret, at, totpValidateOpts)
+	return err == nil && ok
+}
+
+// newBackupCodes returns totpBackupCodeCount single-use codes and their
+// hashes.
+func newBackupCodes() ([]string, []string, error) {
+	codes := make([]string, totpBackupCodeCount)
+	hashes := make([]string, totpBackupCodeCount)
+
+	for i := range codes {
+		buf := make([]byte, 5)
+
+		if _, err := crand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		codes[i] = base32.StdEncoding.EncodeToString(buf)
+		hashes[i] = hashToken(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+// checkSecondFactor verifies code when the account has two-factor
+// authentication enabled. code may be a one-time code from the authenticator
+// app or one of the backup codes, which is used up.
+func checkSecondFactor(ctx context.Context, store TOTPRepository, number int64, code string) error {
+	t, err := store.GetTOTP(ctx, number)
+
+	if isNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if t.EnabledAt == nil {
+		return nil
+	}
+
+	if code == "" {
+		return totpRequiredError()
+	}
+
+	if validTOTPCode(t.Secret, code, time.Now()) {
+		return nil
+	}
+
+	err = store.UseTOTPBackupCode(ctx, number, hashToken(strings.ToUpper(strings.TrimSpace(code))))
+
+	if isNotFound(err) {
+		return unauthorizedError("invalid two-factor code")
+	}
+
+	return err
+}
+
+// checkTransferStepUp asks for a second factor on transfers above threshold.
+// A threshold of 0 disables step-up authentication.
+func checkTransferStepUp(ctx context.Context, store TOTPRepository, threshold, number, amount int64, code string) error {
+	if threshold <= 0 || amount <= threshold {
+		return nil
+	}
+
+	return checkSecondFactor(ctx, store, number, code)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPEnrollmentAndLogin(t *testing.T) {
+	api := newTestAPIWithStepUp(t, 500)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	rec := api.do("POST", path+"/totp", token, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	enrollment := new(TOTPEnrollment)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(enrollment))
+	assert.Contains(t, enrollment.URI, "otpauth://totp/")
+
+	// not enabled until verified
+	api.login(alice, "alice-pw")
+
+	rec = api.do("POST", path+"/totp/verify", token, TOTPVerifyRequest{Code: "000000"})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+	require.Nil(t, err)
+
+	rec = api.do("POST", path+"/totp/verify", token, TOTPVerifyRequest{Code: code})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	backup := new(TOTPBackupCodes)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(backup))
+	assert.Len(t, backup.BackupCodes, totpBackupCodeCount)
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "alice-pw"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), string(ErrorCodeTOTPRequired))
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "alice-pw", TOTPCode: code})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "alice-pw", TOTPCode: backup.BackupCodes[0]})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/login", "", LoginRequest{Number: alice.Number, Password: "alice-pw", TOTPCode: backup.BackupCodes[0]})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "backup codes are single use")
+
+	rec = api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: 2000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 500})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 501})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 501, TOTPCode: backup.BackupCodes[1]})
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = api.do("POST", path+"/totp", token, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
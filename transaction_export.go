@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// TransactionExportFormat is how GET /account/{id}/transactions/export
+// writes the ledger.
+type TransactionExportFormat string
+
+const (
+	// TransactionExportNDJSON writes one JSON entry per line.
+	TransactionExportNDJSON TransactionExportFormat = "ndjson"
+	// TransactionExportCSV writes a header row, then one row per entry.
+	TransactionExportCSV TransactionExportFormat = "csv"
+)
+
+var transactionExportContentTypes = map[TransactionExportFormat]string{
+	TransactionExportNDJSON: "application/x-ndjson",
+	TransactionExportCSV:    "text/csv",
+}
+
+var transactionExportHeader = []string{"id", "date", "type", "counterparty", "amount", "balance", "currency", "category", "description", "reference", "memo", "endToEndId"}
+
+// transactionExportWriter writes entries one at a time as they are read,
+// so an export never holds more than the writer's buffer.
+type transactionExportWriter struct {
+	format   TransactionExportFormat
+	currency string
+	json     *json.Encoder
+	csv      *csv.Writer
+}
+
+// newTransactionExportWriter writes the entries of an account in currency to
+// w, starting with the CSV header.
+func newTransactionExportWriter(w io.Writer, format TransactionExportFormat, currency string) (*transactionExportWriter, error) {
+	ew := &transactionExportWriter{format: format, currency: currency}
+
+	if format == TransactionExportNDJSON {
+		ew.json = json.NewEncoder(w)
+		return ew, nil
+	}
+
+	ew.csv = csv.NewWriter(w)
+
+	return ew, ew.csv.Write(transactionExportHeader)
+}
+
+func (ew *transactionExportWriter) Write(t *Transaction) error {
+	if ew.format == TransactionExportNDJSON {
+		return ew.json.Encode(t)
+	}
+
+	return ew.csv.Write([]string{
+		strconv.Itoa(t.ID),
+		t.CreatedAt.Format(time.RFC3339),
+		string(t.Type),
+		counterpartyString(t),
+		formatAmount(t.Amount, ew.currency),
+		formatAmount(t.Balance, ew.currency),
+		ew.currency,
+		t.Category,
+		t.Description,
+		t.Reference,
+		t.Memo,
+		t.EndToEndID,
+	})
+}
+
+// Flush writes out what the CSV writer buffered.
+func (ew *transactionExportWriter) Flush() error {
+	if ew.csv == nil {
+		return nil
+	}
+
+	ew.csv.Flush()
+
+	return ew.csv.Error()
+}
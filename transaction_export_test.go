@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreStreamTransactions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	acc, err := NewAccount("Alice", "Test", "alice-pw")
+	require.Nil(t, err)
+	require.Nil(t, store.CreateAccount(ctx, acc))
+
+	for _, amount := range []int64{100, 200, 300} {
+		_, err := store.Deposit(ctx, acc.Number, amount, 0)
+		require.Nil(t, err)
+	}
+
+	var amounts []int64
+	stop := errors.New("stop")
+
+	err = store.StreamTransactions(ctx, acc.Number, time.Time{}, time.Now().Add(time.Second), func(t *Transaction) error {
+		amounts = append(amounts, t.Amount)
+
+		if len(amounts) == 2 {
+			return stop
+		}
+
+		return nil
+	})
+
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []int64{100, 200}, amounts, "oldest first, until fn fails")
+
+	seeded, err := NewAccount("Bob", "Test", "bob-pw")
+	require.Nil(t, err)
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []*Transaction{
+		{Type: TransactionDeposit, Amount: 300, CreatedAt: at.Add(2 * time.Hour)},
+		{Type: TransactionDeposit, Amount: 100, CreatedAt: at},
+		{Type: TransactionDeposit, Amount: 200, CreatedAt: at.Add(time.Hour)},
+	}
+	require.Nil(t, store.SeedAccount(ctx, seeded, history))
+
+	amounts = nil
+
+	err = store.StreamTransactions(ctx, seeded.Number, at, at.AddDate(0, 0, 1), func(t *Transaction) error {
+		amounts = append(amounts, t.Amount)
+		return nil
+	})
+
+	require.Nil(t, err)
+	assert.Equal(t, []int64{100, 200, 300}, amounts, "back-dated entries come in the order they were created at")
+}
+
+func TestExportTransactions(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	for _, amount := range []int64{1000, 2500} {
+		rec := api.do("POST", "/admin"+path+"/deposit", api.tellerToken(), AmountRequest{Amount: amount})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	rec := api.do("GET", path+"/transactions/export", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	var transactions []*Transaction
+	scanner := bufio.NewScanner(rec.Body)
+
+	for scanner.Scan() {
+		transaction := new(Transaction)
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), transaction))
+		transactions = append(transactions, transaction)
+	}
+
+	require.Len(t, transactions, 2)
+	assert.Equal(t, int64(1000), transactions[0].Amount)
+	assert.Equal(t, int64(3500), transactions[1].Balance)
+
+	rec = api.do("GET", path+"/transactions/export?format=csv", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	require.Nil(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, transactionExportHeader, records[0])
+	assert.Equal(t, "25.00", records[2][4])
+
+	rec = api.do("GET", path+"/transactions/export?to=2000-01-01", token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Empty(t, rec.Body.String())
+
+	rec = api.do("GET", path+"/transactions/export?format=xml", token, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxTransferBatchSize bounds the transfers of one batch, which the store
+// executes while holding the locks of every account involved.
+const maxTransferBatchSize = 500
+
+func (item *TransferBatchItemRequest) transferRequest() *TransferRequest {
+	return &TransferRequest{ToAccount: item.ToAccount, BeneficiaryID: item.BeneficiaryID, Amount: item.Amount}
+}
+
+// total returns the sum of the batch's amounts, which the step-up and second
+// owner thresholds apply to so that splitting a payout does not avoid them.
+func (req *TransferBatchRequest) total() int64 {
+	var total int64
+
+	for _, item := range req.Transfers {
+		total += item.Amount
+	}
+
+	return total
+}
+
+func (item *TransferBatchItem) complete() {
+	item.Status = TransferBatchItemCompleted
+	item.TransferID = &item.transfer.ID
+}
+
+func (item *TransferBatchItem) fail(err *HTTPError) {
+	item.Status = TransferBatchItemFailed
+	item.ErrorCode = err.Code
+	item.Error = err.Message
+}
+
+// count tallies the completed and failed items once the batch has run.
+func (b *TransferBatch) count() {
+	b.Completed, b.Failed = 0, 0
+
+	for _, item := range b.Items {
+		if item.Status == TransferBatchItemCompleted {
+			b.Completed++
+		} else {
+			b.Failed++
+		}
+	}
+}
+
+// batchItemError points an error refusing the n-th transfer of a batch at
+// it. Internal errors are returned untouched.
+func batchItemError(n int, err error) error {
+	var httpErr *HTTPError
+
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+
+	field := fmt.Sprintf("transfers[%d]", n)
+	wrapped := newHTTPError(httpErr.Status, httpErr.Code, "%s: %s", field, httpErr.Message)
+	wrapped.Details = []FieldError{{Field: field, Message: httpErr.Message}}
+
+	return wrapped
+}
+
+// newTransferBatch checks every transfer of req, requested from ip, like
+// POST /transfer does before any is executed, and builds the batch the store
+// executes. A batch with any refused transfer is rejected as a whole, listing
+// each refusal.
+func (s *APIServer) newTransferBatch(ctx context.Context, from int64, req *TransferBatchRequest, ip string) (*TransferBatch, error) {
+	batch := &TransferBatch{FromAccount: from, Mode: req.Mode}
+
+	if batch.Mode == "" {
+		batch.Mode = TransferBatchAtomic
+	}
+
+	errs := FieldErrors{}
+
+	for i, itemReq := range req.Transfers {
+		transfer, review, err := s.checkTransferBatchItem(ctx, from, itemReq.transferRequest(), ip)
+
+		var httpErr *HTTPError
+
+		if errors.As(err, &httpErr) {
+			errs.Add(fmt.Sprintf("transfers[%d]", i), "%s", httpErr.Message)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		batch.Items = append(batch.Items, &TransferBatchItem{ToAccount: transfer.ToAccount, Amount: transfer.Amount, transfer: transfer, review: review})
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// checkTransferBatchItem returns the transfer of one item of a batch, and
+// its fraud review if the fraud rules flagged it.
+func (s *APIServer) checkTransferBatchItem(ctx context.Context, from int64, req *TransferRequest, ip string) (*Transfer, *FraudReview, error) {
+	if req.ToAccount != 0 {
+		if err := s.accountNumbers.Check("toAccount", int64(req.ToAccount)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := resolveBeneficiary(ctx, s.store, from, req); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkBeneficiaryCoolingOff(ctx, s.store, s.coolingOffAmount, from, int64(req.ToAccount), req.Amount, time.Now().UTC()); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkKYCTransferLimit(ctx, s.store, s.kycTransferLimit, from, req.Amount); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkVerifiedEmail(ctx, s.store, s.verifiedEmailAmount, from, req.Amount); err != nil {
+		return nil, nil, err
+	}
+
+	transfer, err := newTransfer(ctx, s.store, s.rates, from, int64(req.ToAccount), req.Amount)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	review, err := s.fraud.Screen(ctx, &FraudCheck{Transfer: transfer, IP: ip, At: time.Now().UTC()})
+
+	return transfer, review, err
+}
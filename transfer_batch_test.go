@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferBatchRequestValidate(t *testing.T) {
+	req := &TransferBatchRequest{Mode: "some", Transfers: []*TransferBatchItemRequest{{ToAccount: 1, Amount: 10}, {ToAccount: 2, Amount: 0}}}
+
+	err := req.ValidateFrom(1)
+	require.NotNil(t, err)
+
+	httpErr := err.(*HTTPError)
+	fields := []string{}
+
+	for _, fe := range httpErr.Details {
+		fields = append(fields, fe.Field)
+	}
+
+	assert.ElementsMatch(t, []string{"mode", "transfers[0].toAccount", "transfers[1].amount"}, fields)
+
+	assert.NotNil(t, (&TransferBatchRequest{}).ValidateFrom(1))
+	assert.NotNil(t, (&TransferBatchRequest{Transfers: make([]*TransferBatchItemRequest, maxTransferBatchSize+1)}).ValidateFrom(1))
+}
+
+func TestMemoryStoreTransferBatchRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for _, number := range []int64{1, 2, 3} {
+		require.Nil(t, store.CreateAccount(ctx, &Account{Number: number, Currency: "USD", Status: AccountActive}))
+	}
+
+	_, err := store.Deposit(ctx, 1, 1000, 0)
+	require.Nil(t, err)
+
+	transactions := len(store.transactions)
+
+	batch := &TransferBatch{FromAccount: 1, Mode: TransferBatchAtomic}
+
+	for _, item := range []struct{ to, amount int64 }{{2, 600}, {3, 600}} {
+		transfer := &Transfer{FromAccount: 1, ToAccount: item.to, Amount: item.amount, Currency: "USD", ToAmount: item.amount, ToCurrency: "USD"}
+		batch.Items = append(batch.Items, &TransferBatchItem{ToAccount: item.to, Amount: item.amount, transfer: transfer})
+	}
+
+	err = store.TransferBatch(ctx, batch)
+	require.NotNil(t, err)
+	assert.Equal(t, ErrorCodeInsufficientFunds, err.(*HTTPError).Code)
+	assert.Contains(t, err.Error(), "transfers[1]")
+
+	for number, balance := range map[int64]int64{1: 1000, 2: 0, 3: 0} {
+		acc, err := store.GetAccountByNumber(ctx, int(number))
+		require.Nil(t, err)
+		assert.Equal(t, balance, acc.Balance, number)
+	}
+
+	assert.Len(t, store.transactions, transactions)
+	assert.Empty(t, store.transfers)
+	assert.Empty(t, store.outbox)
+
+	report, err := store.CheckLedgerIntegrity(ctx)
+	require.Nil(t, err)
+	assert.Empty(t, report.UnbalancedEntries)
+}
+
+func TestAPITransferBatch(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+	carol := api.createAccount("Carol", "carol-pw")
+	token := api.login(alice, "alice-pw")
+
+	rec := api.do("POST", "/admin/account/"+strconv.Itoa(alice.ID)+"/deposit", api.tellerToken(), AmountRequest{Amount: 1000})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	balance := func(acc *Account) int64 {
+		stored, err := api.store.GetAccountByNumber(context.Background(), int(acc.Number))
+		require.Nil(t, err)
+
+		return stored.Balance
+	}
+
+	payout := func(mode TransferBatchMode, amounts ...int64) *TransferBatchRequest {
+		req := &TransferBatchRequest{Mode: mode}
+
+		for i, amount := range amounts {
+			to := bob.Number
+
+			if i%2 == 1 {
+				to = carol.Number
+			}
+
+			req.Transfers = append(req.Transfers, &TransferBatchItemRequest{ToAccount: int(to), Amount: amount})
+		}
+
+		return req
+	}
+
+	// unknown payees are refused before anything is executed
+	req := payout(TransferBatchAtomic, 100)
+	req.Transfers = append(req.Transfers, &TransferBatchItemRequest{ToAccount: 999999, Amount: 100})
+
+	rec = api.do("POST", "/transfer/batch", token, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"field":"transfers[1]"`)
+	assert.Equal(t, int64(1000), balance(alice))
+
+	// an atomic batch the account can't cover executes nothing
+	rec = api.do("POST", "/transfer/batch", token, payout(TransferBatchAtomic, 400, 400, 400))
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"code":"insufficient_funds"`)
+	assert.Contains(t, rec.Body.String(), "transfers[2]")
+	assert.Equal(t, int64(1000), balance(alice))
+
+	rec = api.do("POST", "/transfer/batch", token, payout("", 300, 200))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	batch := new(TransferBatch)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(batch))
+	assert.Equal(t, TransferBatchAtomic, batch.Mode)
+	assert.Equal(t, 2, batch.Completed)
+	assert.Equal(t, int64(500), balance(alice))
+	assert.Equal(t, int64(300), balance(bob))
+	assert.Equal(t, int64(200), balance(carol))
+
+	// a partial batch executes what it can
+	rec = api.do("POST", "/transfer/batch", token, payout(TransferBatchPartial, 300, 300, 100))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	batch = new(TransferBatch)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(batch))
+	assert.Equal(t, 2, batch.Completed)
+	assert.Equal(t, 1, batch.Failed)
+	require.Len(t, batch.Items, 3)
+	assert.Equal(t, TransferBatchItemCompleted, batch.Items[0].Status)
+	assert.NotNil(t, batch.Items[0].TransferID)
+	assert.Equal(t, TransferBatchItemFailed, batch.Items[1].Status)
+	assert.Equal(t, ErrorCodeInsufficientFunds, batch.Items[1].ErrorCode)
+	assert.Nil(t, batch.Items[1].TransferID)
+	assert.Equal(t, TransferBatchItemCompleted, batch.Items[2].Status)
+	assert.Equal(t, int64(100), balance(alice))
+
+	path := "/transfer/batch/" + strconv.Itoa(batch.ID)
+
+	rec = api.do("GET", path, token, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	stored := new(TransferBatch)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(stored))
+	assert.Equal(t, batch, stored)
+
+	rec = api.do("GET", path, api.login(bob, "bob-pw"), nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
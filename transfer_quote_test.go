@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreTransferQuotes(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+
+	acc := &Account{Number: 42, Currency: "USD", Balance: 100}
+	acc.MinimumBalance, acc.OverdraftFee = 50, 5
+	quote := NewTransferQuote(&Transfer{FromAccount: 42, ToAccount: 43, Amount: 80, Currency: "USD", ToAmount: 80, ToCurrency: "USD"}, acc, time.Minute, now)
+	assert.Equal(t, int64(5), quote.Fee, "the transfer leaves the account below its minimum balance")
+	assert.Equal(t, int64(85), quote.TotalDebit)
+	require.Nil(t, store.CreateTransferQuote(ctx, quote))
+
+	_, err := store.UseTransferQuote(ctx, quote.ID, 43, now)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Status, "quotes are used from their own account")
+
+	_, err = store.UseTransferQuote(ctx, quote.ID, 42, now.Add(time.Minute))
+	assert.ErrorContains(t, err, "expired")
+
+	used, err := store.UseTransferQuote(ctx, quote.ID, 42, now)
+	require.Nil(t, err)
+	assert.NotNil(t, used.UsedAt)
+
+	_, err = store.UseTransferQuote(ctx, quote.ID, 42, now)
+	assert.ErrorContains(t, err, "has been used")
+}
+
+func TestAPITransferQuote(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	alice, err := NewAccount("Alice", "Test", "alice-pw")
+	require.Nil(t, err)
+	alice.OverdraftLimit, alice.OverdraftFee = 500, 25
+	require.Nil(t, api.store.CreateAccount(ctx, alice))
+
+	bob, err := NewAccount("Bob", "Test", "bob-pw")
+	require.Nil(t, err)
+	bob.Currency = "EUR"
+	require.Nil(t, api.store.CreateAccount(ctx, bob))
+
+	_, err = api.store.Deposit(ctx, alice.Number, 1000, 0)
+	require.Nil(t, err)
+
+	token := api.login(alice, "alice-pw")
+
+	newQuote := func(amount int64) *TransferQuote {
+		rec := api.do("POST", "/transfer/quote", token, TransferRequest{ToAccount: int(bob.Number), Amount: amount})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		quote := new(TransferQuote)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(quote))
+
+		return quote
+	}
+
+	quote := newQuote(1200)
+	assert.Equal(t, int64(25), quote.Fee, "the transfer would overdraw the account")
+	assert.Equal(t, int64(1225), quote.TotalDebit)
+	assert.Equal(t, "EUR", quote.ToCurrency)
+	assert.NotEmpty(t, quote.Rate)
+
+	rec := api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 1100, QuoteID: quote.ID})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "the transfer must be the quoted one")
+
+	quote = newQuote(300)
+	assert.Zero(t, quote.Fee)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 300, QuoteID: quote.ID})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	transfer := new(Transfer)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(transfer))
+	assert.Equal(t, quote.Rate, transfer.Rate)
+	assert.Equal(t, quote.ToAmount, transfer.ToAmount)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 300, QuoteID: quote.ID})
+	assert.Equal(t, http.StatusConflict, rec.Code, "quotes are used once")
+
+	quote = newQuote(600)
+	assert.Zero(t, quote.Fee)
+
+	_, err = api.store.Withdraw(ctx, alice.Number, 200, 0)
+	require.Nil(t, err)
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 600, QuoteID: quote.ID})
+	assert.Equal(t, http.StatusConflict, rec.Code, "the transfer would now be charged a fee")
+	assert.Contains(t, rec.Body.String(), "the fee of quote")
+
+	rec = api.do("POST", "/transfer/quote", token, TransferRequest{ToAccount: int(bob.Number), Amount: 2000})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "quotes need the funds")
+
+	rec = api.do("POST", "/transfer/authorize", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100, QuoteID: quote.ID})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreSearchTransactions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	alice := &Account{Number: 42, Currency: "USD", Status: AccountActive}
+	bob := &Account{Number: 43, Currency: "USD", Status: AccountActive}
+	require.Nil(t, store.CreateAccount(ctx, alice))
+	require.Nil(t, store.CreateAccount(ctx, bob))
+
+	_, err := store.Deposit(ctx, 42, 1000, 0)
+	require.Nil(t, err)
+
+	ref := TransferReference{Reference: "INV-2024-17", Memo: "March rent", EndToEndID: "e2e-1"}
+	require.Nil(t, store.Transfer(ctx, &Transfer{FromAccount: 42, ToAccount: 43, Amount: 100, Currency: "USD", ToAmount: 100, ToCurrency: "USD", TransferReference: ref}))
+	require.Nil(t, store.Transfer(ctx, &Transfer{FromAccount: 42, ToAccount: 43, Amount: 50, Currency: "USD", ToAmount: 50, ToCurrency: "USD"}))
+
+	for _, number := range []int64{42, 43} {
+		found, err := store.SearchTransactions(ctx, number, &TransactionSearch{Query: "inv-2024", Limit: 10})
+		require.Nil(t, err)
+		require.Len(t, found, 1, "both sides of the transfer carry its reference")
+		assert.Equal(t, ref, found[0].TransferReference)
+	}
+
+	found, err := store.SearchTransactions(ctx, 42, &TransactionSearch{Query: "RENT", Limit: 10})
+	require.Nil(t, err)
+	assert.Len(t, found, 1, "the memo is searched too, ignoring case")
+
+	found, err = store.SearchTransactions(ctx, 42, &TransactionSearch{Query: "e2e", Before: newTransactionCursor(found[0]), Limit: 10})
+	require.Nil(t, err)
+	assert.Empty(t, found)
+}
+
+func TestAPITransferReference(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	bob := api.createAccount("Bob", "bob-pw")
+
+	_, err := api.store.Deposit(context.Background(), alice.Number, 1000, 0)
+	require.Nil(t, err)
+
+	token := api.login(alice, "alice-pw")
+
+	rec := api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100, TransferReference: TransferReference{Reference: strings.Repeat("x", 36)}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "the schema limits the length")
+
+	rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100, TransferReference: TransferReference{Memo: "tab\tbed"}})
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "memo must not contain control characters")
+
+	for i, memo := range []string{"Dinner at Luigi's", "Concert tickets"} {
+		rec = api.do("POST", "/transfer", token, TransferRequest{ToAccount: int(bob.Number), Amount: 100, TransferReference: TransferReference{Reference: fmt.Sprintf("REF-%d", i), Memo: memo, EndToEndID: "e2e-7"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	transfer := new(Transfer)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(transfer))
+	assert.Equal(t, "REF-1", transfer.Reference)
+
+	search := func(version, q string) []*Transaction {
+		rec := api.do("GET", fmt.Sprintf("%s/account/%d/transactions?q=%s", version, alice.ID, url.QueryEscape(q)), token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		if version == "/v1" {
+			var transactions []*Transaction
+			require.Nil(t, json.NewDecoder(rec.Body).Decode(&transactions))
+
+			return transactions
+		}
+
+		page := new(TransactionPage)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(page))
+
+		return page.Transactions
+	}
+
+	found := search("/v1", "luigi's")
+	require.Len(t, found, 1)
+	assert.Equal(t, "REF-0", found[0].Reference)
+	assert.Equal(t, TransactionTransferOut, found[0].Type)
+
+	assert.Len(t, search("/v2", "E2E-7"), 2)
+	assert.Empty(t, search("/v1", "100%"), "q is matched literally")
+}
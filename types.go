@@ -1,59 +1,2303 @@
 package main
 
 import (
-	"math/rand"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type LoginResponse struct {
-	Number int64  `json:"number"`
-	Token  string `json:"token"`
+	Number       int64  `json:"number"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+	// UserID is the business user logged in, if it wasn't the holder.
+	UserID int `json:"userId,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshToken is the persisted side of a refresh token; only the SHA-256 of
+// the token handed to the client is stored.
+type RefreshToken struct {
+	ID            int       `json:"id"`
+	AccountNumber int64     `json:"accountNumber"`
+	SessionID     int       `json:"sessionId,omitempty"`
+	TokenHash     string    `json:"-"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	// UserID is the business user of the token's session, read from the
+	// session when the token is rotated.
+	UserID    int        `json:"-"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// maxUserAgentLength is how much of a user agent a session keeps.
+const maxUserAgentLength = 256
+
+// Device is where a session is used from, as of its last login or refresh.
+type Device struct {
+	UserAgent string `json:"userAgent"`
+	IP        string `json:"ip"`
+}
+
+func NewDevice(userAgent, ip string) Device {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	return Device{UserAgent: userAgent, IP: ip}
+}
+
+// Session is a login of an account on a device. It lasts as long as the
+// refresh tokens rotated from the login, unless it is revoked first.
+type Session struct {
+	ID            int   `json:"id"`
+	AccountNumber int64 `json:"accountNumber"`
+	// UserID is the business user logged in, 0 for the holder.
+	UserID int `json:"userId,omitempty"`
+	Device
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	// Current marks the session of the token the sessions are listed with.
+	Current bool `json:"current,omitempty"`
+}
+
+func NewSession(accountNumber int64, device Device, now time.Time) *Session {
+	return &Session{
+		AccountNumber: accountNumber,
+		Device:        device,
+		CreatedAt:     now,
+		LastSeenAt:    now,
+	}
+}
+
+func NewRefreshToken(accountNumber int64, ttl time.Duration) (*RefreshToken, string, error) {
+	token, err := randomToken()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+
+	return &RefreshToken{
+		AccountNumber: accountNumber,
+		TokenHash:     hashToken(token),
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+	}, token, nil
+}
+
+// PasswordForgotRequest asks for a reset token to be sent to the holder of
+// the account.
+type PasswordForgotRequest struct {
+	Number int64 `json:"number"`
+}
+
+type PasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// PasswordReset is a single-use token for setting a new password without
+// the current one; like refresh tokens, only its SHA-256 is stored.
+type PasswordReset struct {
+	ID            int
+	AccountNumber int64
+	TokenHash     string
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
+func NewPasswordReset(accountNumber int64, ttl time.Duration) (*PasswordReset, string, error) {
+	token, err := randomToken()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+
+	return &PasswordReset{
+		AccountNumber: accountNumber,
+		TokenHash:     hashToken(token),
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+	}, token, nil
+}
+
+// randomToken returns 256 random bits, URL-safe base64 encoded.
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+
+	if _, err := crand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 type LoginRequest struct {
 	Number   int64  `json:"number"`
 	Password string `json:"password"`
+	// TOTPCode is a one-time or backup code, required once two-factor
+	// authentication is enabled.
+	TOTPCode string `json:"totpCode,omitempty"`
+	// Username logs in a user of the Number business account instead of
+	// its holder.
+	Username string `json:"username,omitempty"`
+}
+
+// OIDCLoginRequest logs in with an ID token of the configured OpenID Connect
+// provider instead of a password.
+type OIDCLoginRequest struct {
+	IDToken  string `json:"idToken"`
+	TOTPCode string `json:"totpCode,omitempty"`
+}
+
+type LinkIdentityRequest struct {
+	IDToken string `json:"idToken"`
+}
+
+// ExternalIdentity links the subject of an OpenID Connect provider to an
+// account, which the subject can then log in to.
+type ExternalIdentity struct {
+	Issuer        string    `json:"issuer"`
+	Subject       string    `json:"subject"`
+	AccountNumber int64     `json:"accountNumber"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// TOTP is an account's time-based one-time password enrollment. It only
+// protects the account once EnabledAt is set, i.e. after the first code has
+// been verified.
+type TOTP struct {
+	AccountNumber int64
+	Secret        string
+	EnabledAt     *time.Time
+	CreatedAt     time.Time
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// provisioning URI, rendered as a QR code by
+	// authenticator apps.
+	URI string `json:"uri"`
+}
+
+type TOTPVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+// TOTPBackupCodes are returned once, when two-factor authentication is
+// enabled; only their hashes are stored.
+type TOTPBackupCodes struct {
+	BackupCodes []string `json:"backupCodes"`
+}
+
+type TransferRequest struct {
+	// FromAccount lets joint owners send from an account they co-own. It
+	// defaults to the token's account and only POST /transfer accepts
+	// another one.
+	FromAccount int `json:"fromAccount,omitempty"`
+	ToAccount   int `json:"toAccount"`
+	// BeneficiaryID pays a saved beneficiary instead of ToAccount.
+	BeneficiaryID int `json:"beneficiaryId,omitempty"`
+	// ToAlias pays the account a verified email or phone alias points to
+	// instead of ToAccount.
+	ToAlias string `json:"toAlias,omitempty"`
+	Amount  int64  `json:"amount"`
+	// TOTPCode is required for amounts above the step-up threshold when the
+	// account has two-factor authentication enabled.
+	TOTPCode string `json:"totpCode,omitempty"`
+	// QuoteID makes the transfer on the terms of a quote of POST
+	// /transfer/quote. Only POST /transfer accepts it.
+	QuoteID int `json:"quoteId,omitempty"`
+
+	TransferReference
+}
+
+// TransferReference is what the payer tells about a transfer. It is kept on
+// the transfer and on the ledger entries of both accounts, where GET
+// /account/{id}/transactions?q= searches it.
+type TransferReference struct {
+	// Reference is meant for the payee, e.g. an invoice number.
+	Reference string `json:"reference,omitempty"`
+	Memo      string `json:"memo,omitempty"`
+	// EndToEndID is the payer's own id of the transfer, passed on unchanged.
+	EndToEndID string `json:"endToEndId,omitempty"`
+}
+
+func (ref TransferReference) IsZero() bool {
+	return ref == TransferReference{}
+}
+
+// Matches reports whether q appears in any of the fields, ignoring case.
+func (ref TransferReference) Matches(q string) bool {
+	q = strings.ToLower(q)
+
+	for _, field := range []string{ref.Reference, ref.Memo, ref.EndToEndID} {
+		if strings.Contains(strings.ToLower(field), q) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TransferStatus is where a transfer is in its lifecycle: pending while
+// its money is being moved, then completed or failed. A completed transfer
+// an admin moved back is reversed.
+type TransferStatus string
+
+const (
+	TransferPending   TransferStatus = "pending"
+	TransferCompleted TransferStatus = "completed"
+	TransferFailed    TransferStatus = "failed"
+	TransferReversed  TransferStatus = "reversed"
+)
+
+// Transfer amounts are in minor units. Amount is debited in Currency and
+// ToAmount credited in ToCurrency; Rate is only set for cross-currency
+// transfers.
+type Transfer struct {
+	ID          int            `json:"id"`
+	FromAccount int64          `json:"fromAccount"`
+	ToAccount   int64          `json:"toAccount"`
+	Amount      int64          `json:"amount"`
+	Currency    string         `json:"currency"`
+	ToAmount    int64          `json:"toAmount"`
+	ToCurrency  string         `json:"toCurrency"`
+	Rate        string         `json:"rate,omitempty"`
+	Status      TransferStatus `json:"status"`
+	// FailureReason is the error a failed transfer was refused with.
+	FailureReason string     `json:"failureReason,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ReversedAt    *time.Time `json:"reversedAt,omitempty"`
+
+	TransferReference
+}
+
+// MovedMoney reports whether the transfer's money changed hands, even if
+// it was moved back since.
+func (t *Transfer) MovedMoney() bool {
+	return t.Status == TransferCompleted || t.Status == TransferReversed
+}
+
+// TransferFilter selects the transfers to list. AccountNumber matches
+// either side, and From and To bound CreatedAt to [From, To); zero values
+// match everything.
+type TransferFilter struct {
+	AccountNumber int64
+	Status        TransferStatus
+	From          time.Time
+	To            time.Time
+	Limit         int
+	Offset        int
+}
+
+// Matches reports whether t is selected by the filter, ignoring paging.
+func (f *TransferFilter) Matches(t *Transfer) bool {
+	switch {
+	case f.AccountNumber != 0 && t.FromAccount != f.AccountNumber && t.ToAccount != f.AccountNumber:
+		return false
+	case f.Status != "" && t.Status != f.Status:
+		return false
+	case !f.From.IsZero() && t.CreatedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !t.CreatedAt.Before(f.To):
+		return false
+	}
+
+	return true
+}
+
+type TransferBatchMode string
+
+const (
+	// TransferBatchAtomic executes every transfer of a batch or none.
+	TransferBatchAtomic TransferBatchMode = "atomic"
+	// TransferBatchPartial executes the transfers that can be and records
+	// why the others failed.
+	TransferBatchPartial TransferBatchMode = "partial"
+)
+
+type TransferBatchRequest struct {
+	// FromAccount lets joint owners pay out of an account they co-own.
+	FromAccount int `json:"fromAccount,omitempty"`
+	// Mode defaults to atomic.
+	Mode      TransferBatchMode           `json:"mode,omitempty"`
+	Transfers []*TransferBatchItemRequest `json:"transfers"`
+	// TOTPCode is required when the batch total is above the step-up
+	// threshold.
+	TOTPCode string `json:"totpCode,omitempty"`
+}
+
+type TransferBatchItemRequest struct {
+	ToAccount     int   `json:"toAccount"`
+	BeneficiaryID int   `json:"beneficiaryId,omitempty"`
+	Amount        int64 `json:"amount"`
+}
+
+type TransferBatchItemStatus string
+
+const (
+	TransferBatchItemCompleted TransferBatchItemStatus = "completed"
+	TransferBatchItemFailed    TransferBatchItemStatus = "failed"
+)
+
+// TransferBatchItem is the outcome of one transfer of a batch, in the order
+// of the request. TransferID is set once completed, ErrorCode and Error once
+// failed.
+type TransferBatchItem struct {
+	ToAccount  int64                   `json:"toAccount"`
+	Amount     int64                   `json:"amount"`
+	Status     TransferBatchItemStatus `json:"status"`
+	TransferID *int                    `json:"transferId,omitempty"`
+	ErrorCode  ErrorCode               `json:"errorCode,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+
+	// transfer is what the store executes for the item, review is set if
+	// the fraud rules flagged it.
+	transfer *Transfer
+	review   *FraudReview
+}
+
+type TransferBatch struct {
+	ID          int                  `json:"id"`
+	FromAccount int64                `json:"fromAccount"`
+	Mode        TransferBatchMode    `json:"mode"`
+	Completed   int                  `json:"completed"`
+	Failed      int                  `json:"failed"`
+	Items       []*TransferBatchItem `json:"items"`
+	CreatedAt   time.Time            `json:"createdAt"`
+}
+
+type BeneficiaryRequest struct {
+	Name          string `json:"name"`
+	AccountNumber int64  `json:"accountNumber"`
+	Nickname      string `json:"nickname,omitempty"`
+}
+
+// Beneficiary is a payee saved by the Owner account. Transfers above the
+// cooling-off amount to its account are refused until CoolingOffUntil, so a
+// hijacked session can't add a payee and empty the account right away.
+type Beneficiary struct {
+	ID              int       `json:"id"`
+	Owner           int64     `json:"owner"`
+	Name            string    `json:"name"`
+	AccountNumber   int64     `json:"accountNumber"`
+	Nickname        string    `json:"nickname,omitempty"`
+	CoolingOffUntil time.Time `json:"coolingOffUntil"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+type AliasType string
+
+const (
+	AliasEmail AliasType = "email"
+	AliasPhone AliasType = "phone"
+)
+
+type AliasRequest struct {
+	Value string `json:"value"`
+}
+
+type AliasVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+// Alias is an email address or phone number other customers can send money
+// to instead of the account number. It only resolves once VerifiedAt is set,
+// by confirming the code sent to it, and a value can only be verified on one
+// account at a time.
+type Alias struct {
+	ID            int        `json:"id"`
+	AccountNumber int64      `json:"accountNumber"`
+	Type          AliasType  `json:"type"`
+	Value         string     `json:"value"`
+	VerifiedAt    *time.Time `json:"verifiedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+
+	CodeHash      string    `json:"-"`
+	CodeExpiresAt time.Time `json:"-"`
+	Attempts      int       `json:"-"`
+}
+
+type ContactKind string
+
+const (
+	ContactEmail   ContactKind = "email"
+	ContactPhone   ContactKind = "phone"
+	ContactAddress ContactKind = "address"
+)
+
+// ContactRequest sets the contact details it has; the others are left as
+// they are.
+type ContactRequest struct {
+	Email   string `json:"email,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+type ContactVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+// ContactDetail is an email address, phone number or postal address of an
+// account's holder. Setting a new value sends it a code, and it is verified
+// once the code is sent back.
+type ContactDetail struct {
+	AccountNumber int64       `json:"accountNumber"`
+	Kind          ContactKind `json:"kind"`
+	Value         string      `json:"value"`
+	VerifiedAt    *time.Time  `json:"verifiedAt,omitempty"`
+	UpdatedAt     time.Time   `json:"updatedAt"`
+
+	CodeHash      string    `json:"-"`
+	CodeExpiresAt time.Time `json:"-"`
+	Attempts      int       `json:"-"`
+}
+
+// Contact is the contact details of an account, those not set left out.
+type Contact struct {
+	Email   *ContactDetail `json:"email,omitempty"`
+	Phone   *ContactDetail `json:"phone,omitempty"`
+	Address *ContactDetail `json:"address,omitempty"`
+}
+
+// AliasResolution is what a sender sees of the account behind an alias
+// before paying it.
+type AliasResolution struct {
+	Type          AliasType `json:"type"`
+	Value         string    `json:"value"`
+	AccountNumber int64     `json:"accountNumber"`
+	Name          string    `json:"name"`
+	Currency      string    `json:"currency"`
+}
+
+type AuditAction string
+
+const (
+	AuditAccountCreated       AuditAction = "account.created"
+	AuditAccountDeleted       AuditAction = "account.deleted"
+	AuditAccountRestored      AuditAction = "account.restored"
+	AuditAccountFrozen        AuditAction = "account.frozen"
+	AuditAccountUnfrozen      AuditAction = "account.unfrozen"
+	AuditAccountClosed        AuditAction = "account.closed"
+	AuditAccountLimitsChanged AuditAction = "account.limits_changed"
+	AuditPasswordReset        AuditAction = "account.password_reset"
+	AuditIdentityLinked       AuditAction = "account.identity_linked"
+	AuditOwnerAdded           AuditAction = "account.owner_added"
+	AuditOwnerRemoved         AuditAction = "account.owner_removed"
+	AuditUserAdded            AuditAction = "account.user_added"
+	AuditUserRoleChanged      AuditAction = "account.user_role_changed"
+	AuditUserRemoved          AuditAction = "account.user_removed"
+	AuditKYCSubmitted         AuditAction = "kyc.submitted"
+	AuditKYCVerified          AuditAction = "kyc.verified"
+	AuditKYCRejected          AuditAction = "kyc.rejected"
+	AuditFraudCleared         AuditAction = "fraud.cleared"
+	AuditFraudConfirmed       AuditAction = "fraud.confirmed"
+	AuditDisputeReversed      AuditAction = "dispute.reversed"
+	AuditDisputeDenied        AuditAction = "dispute.denied"
+	AuditTransferReversed     AuditAction = "transfer.reversed"
+	AuditExternalReturned     AuditAction = "external_transfer.returned"
+	AuditAliasVerified        AuditAction = "account.alias_verified"
+	AuditLoginFailed          AuditAction = "login.failed"
+
+	AuditBalanceAdjusted       AuditAction = "account.balance_adjusted"
+	AuditCashDeposited         AuditAction = "account.cash_deposited"
+	AuditCashWithdrawn         AuditAction = "account.cash_withdrawn"
+	AuditAdminApprovalProposed AuditAction = "admin_approval.proposed"
+	AuditAdminApprovalApproved AuditAction = "admin_approval.approved"
+	AuditAdminApprovalRejected AuditAction = "admin_approval.rejected"
+	AuditLoanOriginated        AuditAction = "loan.originated"
+	AuditSessionsRevoked       AuditAction = "account.sessions_revoked"
+	AuditContactChanged        AuditAction = "account.contact_changed"
+	AuditContactVerified       AuditAction = "account.contact_verified"
+	AuditLoginLocked           AuditAction = "login.locked"
+	AuditLoginUnlocked         AuditAction = "login.unlocked"
+	AuditAPIKeyCreated         AuditAction = "account.api_key_created"
+	AuditAPIKeyRevoked         AuditAction = "account.api_key_revoked"
+	AuditAMLInvestigating      AuditAction = "aml_flag.investigating"
+	AuditAMLReported           AuditAction = "aml_flag.reported"
+	AuditAMLDismissed          AuditAction = "aml_flag.dismissed"
+	// AuditMaintenanceChanged is recorded on the account of the admin who
+	// switched the maintenance mode.
+	AuditMaintenanceChanged AuditAction = "maintenance.changed"
+)
+
+// AuditEntry records an administrative or security-sensitive action on
+// AccountNumber. Entries are never updated or deleted.
+type AuditEntry struct {
+	ID     int         `json:"id"`
+	Action AuditAction `json:"action"`
+	// Actor is the account whose token performed the action, nil when the
+	// caller was not logged in.
+	Actor         *int64 `json:"actor"`
+	AccountNumber int64  `json:"accountNumber"`
+	IP            string `json:"ip"`
+	// Before and After are JSON snapshots of what the action changed.
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	// RequestID is the request that performed the action, empty for the CLI.
+	RequestID string `json:"requestId,omitempty"`
+	// TokenSource is where the actor's access token was sent, empty when
+	// there was none.
+	TokenSource TokenSource `json:"tokenSource,omitempty"`
+}
+
+type HoldStatus string
+
+const (
+	HoldAuthorized HoldStatus = "authorized"
+	HoldCaptured   HoldStatus = "captured"
+	HoldExpired    HoldStatus = "expired"
+)
+
+// AccountOwner gives the holder of OwnerNumber the same access to the
+// account as its own holder, who is not listed as an owner.
+type AccountOwner struct {
+	AccountID     int       `json:"accountId"`
+	AccountNumber int64     `json:"accountNumber"`
+	OwnerNumber   int64     `json:"ownerNumber"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type AccountOwnerRequest struct {
+	OwnerNumber int64 `json:"ownerNumber"`
+}
+
+type TransferApprovalStatus string
+
+const (
+	TransferApprovalPending  TransferApprovalStatus = "pending"
+	TransferApprovalApproved TransferApprovalStatus = "approved"
+	TransferApprovalRejected TransferApprovalStatus = "rejected"
+	// TransferApprovalFailed approvals were approved but the transfer could
+	// not be executed, e.g. for lack of funds.
+	TransferApprovalFailed TransferApprovalStatus = "failed"
+)
+
+// TransferApproval is a transfer from a joint account above its
+// DualApprovalAmount, waiting for an owner other than the one who requested
+// it. The transfer is only executed once approved.
+type TransferApproval struct {
+	ID          int    `json:"id"`
+	FromAccount int64  `json:"fromAccount"`
+	ToAccount   int64  `json:"toAccount"`
+	Amount      int64  `json:"amount"`
+	RequestedBy int64  `json:"requestedBy"`
+	DecidedBy   *int64 `json:"decidedBy,omitempty"`
+	// RequestedByUser and DecidedByUser are the business users who
+	// requested and decided the approval, 0 for the account's owners.
+	RequestedByUser int                    `json:"requestedByUser,omitempty"`
+	DecidedByUser   int                    `json:"decidedByUser,omitempty"`
+	Status          TransferApprovalStatus `json:"status"`
+	TransferID      *int                   `json:"transferId,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+	CreatedAt       time.Time              `json:"createdAt"`
+	DecidedAt       *time.Time             `json:"decidedAt,omitempty"`
+
+	TransferReference
+}
+
+// AdminAction names the sensitive admin actions that need a second admin's
+// approval.
+type AdminAction string
+
+const (
+	AdminAdjustBalance AdminAction = "adjust_balance"
+	AdminUpdateLimits  AdminAction = "update_limits"
+	AdminUnfreeze      AdminAction = "unfreeze"
+)
+
+type AdminApprovalStatus string
+
+const (
+	AdminApprovalPending  AdminApprovalStatus = "pending"
+	AdminApprovalApproved AdminApprovalStatus = "approved"
+	AdminApprovalRejected AdminApprovalStatus = "rejected"
+	// AdminApprovalFailed approvals were approved but the action could not
+	// be carried out, e.g. because the account changed meanwhile.
+	AdminApprovalFailed AdminApprovalStatus = "failed"
+)
+
+// AdminApproval is a sensitive admin action on an account proposed by one
+// admin and carried out once another approves it. Amount is the signed
+// amount of a balance adjustment and Limits the limits an update sets.
+type AdminApproval struct {
+	ID            int                 `json:"id"`
+	Action        AdminAction         `json:"action"`
+	AccountNumber int64               `json:"accountNumber"`
+	Amount        int64               `json:"amount,omitempty"`
+	Limits        *AccountLimits      `json:"limits,omitempty"`
+	ReasonCode    AdjustmentReason    `json:"reasonCode,omitempty"`
+	Reason        string              `json:"reason,omitempty"`
+	ProposedBy    int64               `json:"proposedBy"`
+	DecidedBy     *int64              `json:"decidedBy,omitempty"`
+	Status        AdminApprovalStatus `json:"status"`
+	TransactionID *int                `json:"transactionId,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	DecidedAt     *time.Time          `json:"decidedAt,omitempty"`
+}
+
+// AdjustmentReason says why a balance was corrected by hand.
+type AdjustmentReason string
+
+const (
+	AdjustmentFeeRefund       AdjustmentReason = "fee_refund"
+	AdjustmentErrorCorrection AdjustmentReason = "error_correction"
+	AdjustmentGoodwill        AdjustmentReason = "goodwill"
+	AdjustmentOther           AdjustmentReason = "other"
+)
+
+// CloseAccountRequest closes an account, sweeping what is left of its
+// balance to the SweepTo account.
+type CloseAccountRequest struct {
+	SweepTo int64 `json:"sweepTo,omitempty"`
+	// TOTPCode is required when the balance swept is above the step-up
+	// threshold, as for a transfer.
+	TOTPCode string `json:"totpCode,omitempty"`
+}
+
+// AccountClosure is what closing an account did: the transfer that swept
+// its balance, how many of its standing orders and scheduled transfers were
+// cancelled, and the final statement, from the start of the month until it
+// closed.
+type AccountClosure struct {
+	Account                     *Account   `json:"account"`
+	Sweep                       *Transfer  `json:"sweep,omitempty"`
+	CancelledStandingOrders     int        `json:"cancelledStandingOrders"`
+	CancelledScheduledTransfers int        `json:"cancelledScheduledTransfers"`
+	Statement                   *Statement `json:"statement,omitempty"`
+}
+
+// newSweepTransfer is the transfer of the whole balance of acc to the sweepTo
+// account, in the same currency, when acc closes.
+func newSweepTransfer(acc *Account, sweepTo int64) *Transfer {
+	return &Transfer{
+		FromAccount: acc.Number,
+		ToAccount:   sweepTo,
+		Amount:      acc.Balance,
+		Currency:    acc.Currency,
+		ToAmount:    acc.Balance,
+		ToCurrency:  acc.Currency,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// BalanceAdjustmentRequest proposes crediting, or with a negative Amount
+// debiting, an account by hand. Reason is a free-text note shown with the
+// reason code on the holder's statement.
+type BalanceAdjustmentRequest struct {
+	Amount     int64            `json:"amount"`
+	ReasonCode AdjustmentReason `json:"reasonCode"`
+	Reason     string           `json:"reason"`
+}
+
+type DisputeStatus string
+
+const (
+	DisputeOpen     DisputeStatus = "open"
+	DisputeReversed DisputeStatus = "reversed"
+	DisputeDenied   DisputeStatus = "denied"
+)
+
+// Dispute is a holder's claim that a debit on their account was wrong.
+// Amount is the part of the debit disputed. Reversed disputes record the
+// amount given back, at most Amount, and the journal entry that did.
+type Dispute struct {
+	ID                int           `json:"id"`
+	AccountNumber     int64         `json:"accountNumber"`
+	TransactionID     int           `json:"transactionId"`
+	Amount            int64         `json:"amount"`
+	Reason            string        `json:"reason"`
+	Status            DisputeStatus `json:"status"`
+	ReversedAmount    int64         `json:"reversedAmount"`
+	ReversalJournalID *int          `json:"reversalJournalId,omitempty"`
+	DecidedBy         *int64        `json:"decidedBy,omitempty"`
+	Note              string        `json:"note,omitempty"`
+	CreatedAt         time.Time     `json:"createdAt"`
+	DecidedAt         *time.Time    `json:"decidedAt,omitempty"`
+}
+
+// DisputeRequest disputes Amount of a transaction, all of what is left to
+// dispute if 0.
+type DisputeRequest struct {
+	TransactionID int    `json:"transactionId"`
+	Amount        int64  `json:"amount"`
+	Reason        string `json:"reason"`
+}
+
+// DisputeDecisionRequest reverses Amount of a dispute, all of it if 0, or
+// denies it; denials must give the holder a note.
+type DisputeDecisionRequest struct {
+	Amount int64  `json:"amount"`
+	Note   string `json:"note"`
+}
+
+// DisputeDecision is how an admin resolved a dispute.
+type DisputeDecision struct {
+	Status    DisputeStatus
+	Amount    int64
+	Note      string
+	DecidedBy int64
+	DecidedAt time.Time
+}
+
+// FraudAction is what happens to a transfer a fraud rule matches: allowed
+// transfers are only logged, flagged ones are executed and queued for staff
+// to review, and blocked ones are refused and queued.
+type FraudAction string
+
+const (
+	FraudAllow FraudAction = "allow"
+	FraudFlag  FraudAction = "flag"
+	FraudBlock FraudAction = "block"
+)
+
+type FraudReviewStatus string
+
+const (
+	FraudReviewPending FraudReviewStatus = "pending"
+	// FraudReviewCleared reviews found the transfer legitimate.
+	FraudReviewCleared FraudReviewStatus = "cleared"
+	// FraudReviewConfirmed reviews found the transfer fraudulent.
+	FraudReviewConfirmed FraudReviewStatus = "confirmed"
+)
+
+// FraudReview is a transfer the fraud rules flagged or blocked, waiting in
+// the review queue. Rules names the rules it matched; TransferID is set once
+// a flagged transfer is executed.
+type FraudReview struct {
+	ID          int               `json:"id"`
+	FromAccount int64             `json:"fromAccount"`
+	ToAccount   int64             `json:"toAccount"`
+	Amount      int64             `json:"amount"`
+	Currency    string            `json:"currency"`
+	IP          string            `json:"ip,omitempty"`
+	Rules       []string          `json:"rules"`
+	Action      FraudAction       `json:"action"`
+	TransferID  *int              `json:"transferId,omitempty"`
+	Status      FraudReviewStatus `json:"status"`
+	ReviewedBy  *int64            `json:"reviewedBy,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	ReviewedAt  *time.Time        `json:"reviewedAt,omitempty"`
+}
+
+type LoanStatus string
+
+const (
+	LoanActive LoanStatus = "active"
+	// LoanInArrears loans have installments that were due but could not be
+	// collected.
+	LoanInArrears LoanStatus = "in_arrears"
+	LoanPaidOff   LoanStatus = "paid_off"
+)
+
+// Loan is money lent to the holder of AccountNumber: Principal is paid into
+// the account when the loan is originated and repaid from it, with interest
+// at AnnualRate (a decimal, e.g. "0.0599"), in TermMonths monthly
+// installments of MonthlyPayment. Outstanding is the principal left to
+// repay and ArrearsAmount the installments due but not collected yet.
+// NextPaymentAt is when the next collection is attempted.
+type Loan struct {
+	ID             int        `json:"id"`
+	AccountNumber  int64      `json:"accountNumber"`
+	Principal      int64      `json:"principal"`
+	Currency       string     `json:"currency"`
+	AnnualRate     string     `json:"annualRate"`
+	TermMonths     int        `json:"termMonths"`
+	MonthlyPayment int64      `json:"monthlyPayment"`
+	Outstanding    int64      `json:"outstanding"`
+	ArrearsAmount  int64      `json:"arrearsAmount"`
+	Status         LoanStatus `json:"status"`
+	NextPaymentAt  *time.Time `json:"nextPaymentAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	PaidOffAt      *time.Time `json:"paidOffAt,omitempty"`
+}
+
+type LoanRequest struct {
+	AccountNumber int64  `json:"accountNumber"`
+	Principal     int64  `json:"principal"`
+	AnnualRate    string `json:"annualRate"`
+	TermMonths    int    `json:"termMonths"`
+}
+
+type LoanInstallmentStatus string
+
+const (
+	LoanInstallmentScheduled LoanInstallmentStatus = "scheduled"
+	LoanInstallmentPaid      LoanInstallmentStatus = "paid"
+	// LoanInstallmentOverdue installments could not be collected when due;
+	// collecting them is retried until they are paid.
+	LoanInstallmentOverdue LoanInstallmentStatus = "overdue"
+)
+
+// LoanInstallment is one month of a loan's amortization schedule. Payment
+// is Principal plus Interest, and Balance the principal left once it is
+// paid.
+type LoanInstallment struct {
+	LoanID        int                   `json:"loanId"`
+	Number        int                   `json:"number"`
+	DueDate       time.Time             `json:"dueDate"`
+	Payment       int64                 `json:"payment"`
+	Principal     int64                 `json:"principal"`
+	Interest      int64                 `json:"interest"`
+	Balance       int64                 `json:"balance"`
+	Status        LoanInstallmentStatus `json:"status"`
+	TransactionID *int                  `json:"transactionId,omitempty"`
+	PaidAt        *time.Time            `json:"paidAt,omitempty"`
+}
+
+type TermDepositStatus string
+
+const (
+	TermDepositActive TermDepositStatus = "active"
+	// TermDepositMatured deposits were paid back with their interest at
+	// maturity, TermDepositWithdrawn ones early with a penalty.
+	TermDepositMatured   TermDepositStatus = "matured"
+	TermDepositWithdrawn TermDepositStatus = "withdrawn"
+)
+
+// TermDeposit is Principal taken out of the holder's account for TermMonths
+// at a fixed AnnualRate (a decimal, e.g. "0.04"). It is paid back with
+// Interest at MaturesAt, or earlier on request less Penalty; Interest is
+// then what had accrued by the withdrawal.
+type TermDeposit struct {
+	ID            int               `json:"id"`
+	AccountNumber int64             `json:"accountNumber"`
+	Principal     int64             `json:"principal"`
+	Currency      string            `json:"currency"`
+	AnnualRate    string            `json:"annualRate"`
+	TermMonths    int               `json:"termMonths"`
+	Interest      int64             `json:"interest"`
+	Penalty       int64             `json:"penalty"`
+	Status        TermDepositStatus `json:"status"`
+	MaturesAt     time.Time         `json:"maturesAt"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	ClosedAt      *time.Time        `json:"closedAt,omitempty"`
+}
+
+type TermDepositRequest struct {
+	Amount     int64 `json:"amount"`
+	TermMonths int   `json:"termMonths"`
+}
+
+// Pot is a named savings goal under an account. Its Balance stays in the
+// account's Balance, counted in PotBalance, but can't be spent until it is
+// moved back. RoundUp pots collect the round-up of every outgoing transfer,
+// and WeeklyAmount is swept in every week from NextSweepAt.
+type Pot struct {
+	ID            int        `json:"id"`
+	AccountNumber int64      `json:"accountNumber"`
+	Name          string     `json:"name"`
+	TargetAmount  int64      `json:"targetAmount"`
+	Balance       int64      `json:"balance"`
+	RoundUp       bool       `json:"roundUp"`
+	WeeklyAmount  int64      `json:"weeklyAmount"`
+	NextSweepAt   *time.Time `json:"nextSweepAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type PotRequest struct {
+	Name         string `json:"name"`
+	TargetAmount int64  `json:"targetAmount"`
+	RoundUp      bool   `json:"roundUp"`
+	WeeklyAmount int64  `json:"weeklyAmount"`
+}
+
+// PotProgress reports how far a pot is from its target. ProjectedAt is when
+// the weekly sweeps alone would reach it.
+type PotProgress struct {
+	PotID        int        `json:"potId"`
+	Name         string     `json:"name"`
+	Balance      int64      `json:"balance"`
+	TargetAmount int64      `json:"targetAmount"`
+	Remaining    int64      `json:"remaining"`
+	Percent      int        `json:"percent"`
+	Reached      bool       `json:"reached"`
+	ProjectedAt  *time.Time `json:"projectedAt,omitempty"`
+}
+
+// PotSweep is the outcome of a weekly sweep; Swept is false when the account
+// could not spare the amount, and the sweep waits for the next week.
+type PotSweep struct {
+	PotID         int
+	AccountNumber int64
+	Amount        int64
+	Swept         bool
+}
+
+// Hold reserves the amount of a transfer on the source account, counted in
+// its HeldBalance, until it is captured, which executes the transfer at the
+// quoted amounts, or expires.
+type Hold struct {
+	ID          int        `json:"id"`
+	FromAccount int64      `json:"fromAccount"`
+	ToAccount   int64      `json:"toAccount"`
+	Amount      int64      `json:"amount"`
+	Currency    string     `json:"currency"`
+	ToAmount    int64      `json:"toAmount"`
+	ToCurrency  string     `json:"toCurrency"`
+	Rate        string     `json:"rate,omitempty"`
+	Status      HoldStatus `json:"status"`
+	TransferID  *int       `json:"transferId,omitempty"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func NewHold(transfer *Transfer, ttl time.Duration) *Hold {
+	now := time.Now().UTC()
+
+	return &Hold{
+		FromAccount: transfer.FromAccount,
+		ToAccount:   transfer.ToAccount,
+		Amount:      transfer.Amount,
+		Currency:    transfer.Currency,
+		ToAmount:    transfer.ToAmount,
+		ToCurrency:  transfer.ToCurrency,
+		Rate:        transfer.Rate,
+		Status:      HoldAuthorized,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+}
+
+// Transfer returns the transfer executed when the hold is captured.
+func (h *Hold) Transfer() *Transfer {
+	return &Transfer{
+		FromAccount: h.FromAccount,
+		ToAccount:   h.ToAccount,
+		Amount:      h.Amount,
+		Currency:    h.Currency,
+		ToAmount:    h.ToAmount,
+		ToCurrency:  h.ToCurrency,
+		Rate:        h.Rate,
+	}
+}
+
+// CheckCapture returns an error unless the hold can still be captured at now.
+func (h *Hold) CheckCapture(now time.Time) error {
+	if h.Status != HoldAuthorized {
+		return conflictError("hold %d is %s", h.ID, h.Status)
+	}
+
+	if !now.Before(h.ExpiresAt) {
+		return conflictError("hold %d is expired", h.ID)
+	}
+
+	return nil
+}
+
+// TransferQuote prices a transfer before it is made: the overdraft fee it
+// would be charged, the exchange rate and the amount credited. A transfer
+// sent with its id until ExpiresAt is made on those terms, or not at all.
+type TransferQuote struct {
+	ID          int       `json:"id"`
+	FromAccount int64     `json:"fromAccount"`
+	ToAccount   int64     `json:"toAccount"`
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	ToAmount    int64     `json:"toAmount"`
+	ToCurrency  string    `json:"toCurrency"`
+	Rate        string    `json:"rate,omitempty"`
+	Fee         int64     `json:"fee"`
+	TotalDebit  int64     `json:"totalDebit"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// UsedAt is set once a transfer has been sent with the quote.
+	UsedAt *time.Time `json:"usedAt,omitempty"`
+}
+
+// NewTransferQuote quotes transfer from acc, its source account, as it
+// stands at now.
+func NewTransferQuote(transfer *Transfer, acc *Account, ttl time.Duration, now time.Time) *TransferQuote {
+	fee := acc.OverdraftFeeFor(acc.Balance - transfer.Amount)
+
+	return &TransferQuote{
+		FromAccount: transfer.FromAccount,
+		ToAccount:   transfer.ToAccount,
+		Amount:      transfer.Amount,
+		Currency:    transfer.Currency,
+		ToAmount:    transfer.ToAmount,
+		ToCurrency:  transfer.ToCurrency,
+		Rate:        transfer.Rate,
+		Fee:         fee,
+		TotalDebit:  transfer.Amount + fee,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+}
+
+// Transfer returns the transfer made on the quoted terms.
+func (q *TransferQuote) Transfer() *Transfer {
+	return &Transfer{
+		FromAccount: q.FromAccount,
+		ToAccount:   q.ToAccount,
+		Amount:      q.Amount,
+		Currency:    q.Currency,
+		ToAmount:    q.ToAmount,
+		ToCurrency:  q.ToCurrency,
+		Rate:        q.Rate,
+	}
+}
+
+// CheckUse returns an error unless the quote can still be used at now.
+func (q *TransferQuote) CheckUse(now time.Time) error {
+	if q.UsedAt != nil {
+		return conflictError("quote %d has been used", q.ID)
+	}
+
+	if !now.Before(q.ExpiresAt) {
+		return conflictError("quote %d is expired, ask for a new one", q.ID)
+	}
+
+	return nil
+}
+
+// CheckTerms returns an error unless a transfer of amount to toAccount from
+// acc is still made on the quoted terms.
+func (q *TransferQuote) CheckTerms(toAccount, amount int64, acc *Account) error {
+	if toAccount != q.ToAccount || amount != q.Amount {
+		return validationError("quote %d is for %d to account %d", q.ID, q.Amount, q.ToAccount)
+	}
+
+	if fee := acc.OverdraftFeeFor(acc.Balance - amount); fee != q.Fee {
+		return conflictError("the fee of quote %d is now %d, ask for a new one", q.ID, fee)
+	}
+
+	return nil
+}
+
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestPending  PaymentRequestStatus = "pending"
+	PaymentRequestAccepted PaymentRequestStatus = "accepted"
+	PaymentRequestDeclined PaymentRequestStatus = "declined"
+	// PaymentRequestExpired requests were left pending past their
+	// ExpiresAt. The status is never stored, pending requests are reported
+	// expired once their time is up.
+	PaymentRequestExpired PaymentRequestStatus = "expired"
+)
+
+// PaymentRequest is ToAccount asking FromAccount for Amount, in the
+// currency of FromAccount as with transfers. Accepting it before ExpiresAt
+// makes the transfer, with the request's reference.
+type PaymentRequest struct {
+	ID          int                  `json:"id"`
+	FromAccount int64                `json:"fromAccount"`
+	ToAccount   int64                `json:"toAccount"`
+	Amount      int64                `json:"amount"`
+	Currency    string               `json:"currency"`
+	Status      PaymentRequestStatus `json:"status"`
+	TransferID  *int                 `json:"transferId,omitempty"`
+	ExpiresAt   time.Time            `json:"expiresAt"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	DecidedAt   *time.Time           `json:"decidedAt,omitempty"`
+
+	TransferReference
+}
+
+// PaymentRequestRequest asks FromAccount, the payer, for Amount on behalf
+// of the token's account.
+type PaymentRequestRequest struct {
+	FromAccount int64 `json:"fromAccount"`
+	Amount      int64 `json:"amount"`
+
+	TransferReference
+}
+
+// PaymentRequestAcceptRequest carries the two-factor code accepting a
+// request above the step-up threshold needs.
+type PaymentRequestAcceptRequest struct {
+	TOTPCode string `json:"totpCode,omitempty"`
+}
+
+// Expire reports a pending request whose time is up at now as expired.
+func (p *PaymentRequest) Expire(now time.Time) {
+	if p.Status == PaymentRequestPending && !now.Before(p.ExpiresAt) {
+		p.Status = PaymentRequestExpired
+	}
+}
+
+// CheckDecision returns an error unless the request can still be accepted
+// or declined at now.
+func (p *PaymentRequest) CheckDecision(now time.Time) error {
+	p.Expire(now)
+
+	if p.Status != PaymentRequestPending {
+		return conflictError("payment request %d is %s", p.ID, p.Status)
+	}
+
+	return nil
+}
+
+type CardStatus string
+
+const (
+	CardActive CardStatus = "active"
+	CardFrozen CardStatus = "frozen"
+)
+
+// Card is a debit card drawing on an account. Like tokens, only the SHA-256
+// of its number is stored; the full number is shown once, when the card is
+// issued.
+type Card struct {
+	ID            int        `json:"id"`
+	AccountNumber int64      `json:"accountNumber"`
+	MaskedPAN     string     `json:"maskedPan"`
+	PANHash       string     `json:"-"`
+	ExpiryMonth   int        `json:"expiryMonth"`
+	ExpiryYear    int        `json:"expiryYear"`
+	Status        CardStatus `json:"status"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// IssuedCard is a new card with its full number.
+type IssuedCard struct {
+	*Card
+	PAN string `json:"pan"`
 }
 
-type TransferRequest struct {
-	ToAccount int `json:"toAccount"`
-	Amount    int `json:"amount"`
+type CardAuthorizationStatus string
+
+const (
+	CardAuthorizationApproved CardAuthorizationStatus = "approved"
+	CardAuthorizationDeclined CardAuthorizationStatus = "declined"
+	CardAuthorizationExpired  CardAuthorizationStatus = "expired"
+)
+
+type CardDeclineReason string
+
+const (
+	CardDeclineFrozen            CardDeclineReason = "card_frozen"
+	CardDeclineExpired           CardDeclineReason = "card_expired"
+	CardDeclineAccountInactive   CardDeclineReason = "account_inactive"
+	CardDeclineCurrency          CardDeclineReason = "currency_mismatch"
+	CardDeclineInsufficientFunds CardDeclineReason = "insufficient_funds"
+)
+
+// CardAuthorizationRequest is a card processor asking to take Amount, in
+// minor units of Currency, from the account of the card numbered PAN.
+type CardAuthorizationRequest struct {
+	PAN      string `json:"pan"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Merchant string `json:"merchant"`
+}
+
+// CardAuthorization is the answer to a CardAuthorizationRequest. Approved
+// authorizations hold the amount on the account, counted in its
+// HeldBalance, until they expire; declined ones hold nothing and say why.
+type CardAuthorization struct {
+	ID            int                     `json:"id"`
+	CardID        int                     `json:"cardId"`
+	AccountNumber int64                   `json:"accountNumber"`
+	Amount        int64                   `json:"amount"`
+	Currency      string                  `json:"currency"`
+	Merchant      string                  `json:"merchant"`
+	Status        CardAuthorizationStatus `json:"status"`
+	DeclineReason CardDeclineReason       `json:"declineReason,omitempty"`
+	ExpiresAt     time.Time               `json:"expiresAt"`
+	CreatedAt     time.Time               `json:"createdAt"`
+}
+
+type ExternalTransferScheme string
+
+const (
+	SchemeACH  ExternalTransferScheme = "ach"
+	SchemeSEPA ExternalTransferScheme = "sepa"
+)
+
+type ExternalTransferStatus string
+
+const (
+	ExternalTransferPending   ExternalTransferStatus = "pending"
+	ExternalTransferSubmitted ExternalTransferStatus = "submitted"
+	ExternalTransferSettled   ExternalTransferStatus = "settled"
+	ExternalTransferReturned  ExternalTransferStatus = "returned"
+)
+
+// ExternalTransferRequest pays Amount to an account at another bank.
+// Destination is routing/account number for ACH and an IBAN for SEPA.
+type ExternalTransferRequest struct {
+	Scheme          ExternalTransferScheme `json:"scheme"`
+	BeneficiaryName string                 `json:"beneficiaryName"`
+	Destination     string                 `json:"destination"`
+	Amount          int64                  `json:"amount"`
+	Reference       string                 `json:"reference"`
+}
+
+// ExternalTransfer is a payment to another bank through a clearing network.
+// Until it settles the amount is only held on the account; settling books
+// it. Transfers can be returned by the network before or after settling.
+type ExternalTransfer struct {
+	ID              int                    `json:"id"`
+	AccountNumber   int64                  `json:"accountNumber"`
+	Scheme          ExternalTransferScheme `json:"scheme"`
+	BeneficiaryName string                 `json:"beneficiaryName"`
+	Destination     string                 `json:"destination"`
+	Amount          int64                  `json:"amount"`
+	Currency        string                 `json:"currency"`
+	Reference       string                 `json:"reference,omitempty"`
+	Status          ExternalTransferStatus `json:"status"`
+	// ReturnCode is the network's reason code, e.g. R01 or AC04.
+	ReturnCode      string     `json:"returnCode,omitempty"`
+	ReturnReason    string     `json:"returnReason,omitempty"`
+	JournalID       *int       `json:"journalId,omitempty"`
+	ReturnJournalID *int       `json:"returnJournalId,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	SubmittedAt     *time.Time `json:"submittedAt,omitempty"`
+	SettledAt       *time.Time `json:"settledAt,omitempty"`
+	ReturnedAt      *time.Time `json:"returnedAt,omitempty"`
+}
+
+type ExternalTransferReturnRequest struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+type Recurrence string
+
+const (
+	RecurrenceNone    Recurrence = "none"
+	RecurrenceDaily   Recurrence = "daily"
+	RecurrenceWeekly  Recurrence = "weekly"
+	RecurrenceMonthly Recurrence = "monthly"
+)
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferActive    ScheduledTransferStatus = "active"
+	ScheduledTransferCompleted ScheduledTransferStatus = "completed"
+	ScheduledTransferFailed    ScheduledTransferStatus = "failed"
+	ScheduledTransferCancelled ScheduledTransferStatus = "cancelled"
+)
+
+type ScheduleTransferRequest struct {
+	ToAccount     int        `json:"toAccount"`
+	BeneficiaryID int        `json:"beneficiaryId,omitempty"`
+	Amount        int64      `json:"amount"`
+	ExecuteAt     time.Time  `json:"executeAt"`
+	Recurrence    Recurrence `json:"recurrence"`
+	TOTPCode      string     `json:"totpCode,omitempty"`
+}
+
+// ScheduledTransfer is a transfer executed by the scheduler at NextRunAt.
+// Recurring transfers are anchored to StartAt, Occurrence counts the runs
+// already completed, and Attempts counts failures of the current run.
+type ScheduledTransfer struct {
+	ID          int                     `json:"id"`
+	FromAccount int64                   `json:"fromAccount"`
+	ToAccount   int64                   `json:"toAccount"`
+	Amount      int64                   `json:"amount"`
+	Recurrence  Recurrence              `json:"recurrence"`
+	Status      ScheduledTransferStatus `json:"status"`
+	StartAt     time.Time               `json:"startAt"`
+	NextRunAt   time.Time               `json:"nextRunAt"`
+	Occurrence  int                     `json:"occurrence"`
+	Attempts    int                     `json:"attempts"`
+	LastError   string                  `json:"lastError,omitempty"`
+	CreatedAt   time.Time               `json:"createdAt"`
+}
+
+// ScheduledTransferRun records the outcome of one execution attempt.
+type ScheduledTransferRun struct {
+	ID                  int       `json:"id"`
+	ScheduledTransferID int       `json:"scheduledTransferId"`
+	TransferID          *int      `json:"transferId,omitempty"`
+	Error               string    `json:"error,omitempty"`
+	RanAt               time.Time `json:"ranAt"`
+}
+
+type StandingOrderFrequency string
+
+const (
+	StandingOrderWeekly  StandingOrderFrequency = "weekly"
+	StandingOrderMonthly StandingOrderFrequency = "monthly"
+	// StandingOrderLastBusinessDay pays on the last business day of every
+	// month.
+	StandingOrderLastBusinessDay StandingOrderFrequency = "last_business_day"
+)
+
+type StandingOrderStatus string
+
+const (
+	StandingOrderActive    StandingOrderStatus = "active"
+	StandingOrderCancelled StandingOrderStatus = "cancelled"
+	StandingOrderFailed    StandingOrderStatus = "failed"
+)
+
+type StandingOrderRequest struct {
+	ToAccount     int                    `json:"toAccount"`
+	BeneficiaryID int                    `json:"beneficiaryId,omitempty"`
+	Amount        int64                  `json:"amount"`
+	Frequency     StandingOrderFrequency `json:"frequency"`
+	// StartDate is the first day the order may pay on, as YYYY-MM-DD.
+	StartDate string `json:"startDate"`
+	TOTPCode  string `json:"totpCode,omitempty"`
+}
+
+// StandingOrder pays Amount on business days following Frequency from
+// StartDate until it is cancelled. NextRunAt is the start of the next
+// payment date, or of a retry. Occurrence counts the payment dates already
+// passed and Failures the attempts of the current one refused for lack of
+// funds.
+type StandingOrder struct {
+	ID          int                    `json:"id"`
+	FromAccount int64                  `json:"fromAccount"`
+	ToAccount   int64                  `json:"toAccount"`
+	Amount      int64                  `json:"amount"`
+	Frequency   StandingOrderFrequency `json:"frequency"`
+	Status      StandingOrderStatus    `json:"status"`
+	StartDate   time.Time              `json:"startDate"`
+	NextRunAt   time.Time              `json:"nextRunAt"`
+	Occurrence  int                    `json:"occurrence"`
+	Failures    int                    `json:"failures"`
+	LastError   string                 `json:"lastError,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt"`
+}
+
+// Holiday is a day of the bank's calendar, as YYYY-MM-DD, on which standing
+// orders don't pay.
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+type EventType string
+
+const (
+	EventAccountCreated    EventType = "account.created"
+	EventTransferCompleted EventType = "transfer.completed"
+	EventBalanceLow        EventType = "balance.low"
+	// EventTransferReversed goes to both accounts of a transfer an admin
+	// moved back.
+	EventTransferReversed EventType = "transfer.reversed"
+	// EventTransactionCreated is a deposit or withdrawal.
+	EventTransactionCreated EventType = "transaction.created"
+	// EventLoginNewDevice is a login from a network the account has not
+	// logged in from before.
+	EventLoginNewDevice EventType = "login.new_device"
+	// EventLoginLocked is an account locked out by too many failed logins.
+	EventLoginLocked EventType = "login.locked"
+	// EventPaymentRequestCreated goes to the account asked to pay,
+	// EventPaymentRequestAccepted and EventPaymentRequestDeclined to the
+	// one that asked.
+	EventPaymentRequestCreated  EventType = "payment_request.created"
+	EventPaymentRequestAccepted EventType = "payment_request.accepted"
+	EventPaymentRequestDeclined EventType = "payment_request.declined"
+)
+
+// Event is something that happened to an account. It is delivered to the
+// webhooks subscribed to its type and to the account's streams.
+type Event struct {
+	ID            string    `json:"id"`
+	Type          EventType `json:"type"`
+	AccountNumber int64     `json:"accountNumber"`
+	Data          any       `json:"data"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// RequestID is the request that caused the event, empty for events of
+	// background jobs.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+type WebhookRequest struct {
+	URL                 string      `json:"url"`
+	Events              []EventType `json:"events"`
+	LowBalanceThreshold int64       `json:"lowBalanceThreshold"`
+}
+
+// Webhook is an endpoint registered for an account's events, or for the
+// events of every account when AccountNumber is nil (admin webhooks).
+// Deliveries are signed with Secret, which is only returned on creation.
+type Webhook struct {
+	ID                  int         `json:"id"`
+	AccountNumber       *int64      `json:"accountNumber,omitempty"`
+	URL                 string      `json:"url"`
+	Secret              string      `json:"secret,omitempty"`
+	Events              []EventType `json:"events"`
+	LowBalanceThreshold int64       `json:"lowBalanceThreshold"`
+	CreatedAt           time.Time   `json:"createdAt"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+type WebhookDelivery struct {
+	ID            int                   `json:"id"`
+	WebhookID     int                   `json:"webhookId"`
+	EventID       string                `json:"eventId"`
+	EventType     EventType             `json:"eventType"`
+	Payload       []byte                `json:"-"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt time.Time             `json:"nextAttemptAt"`
+	ResponseCode  int                   `json:"responseCode,omitempty"`
+	LastError     string                `json:"lastError,omitempty"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	DeliveredAt   *time.Time            `json:"deliveredAt,omitempty"`
+	URL           string                `json:"-"`
+	Secret        string                `json:"-"`
+}
+
+type NotificationChannel string
+
+const (
+	NotificationEmail NotificationChannel = "email"
+	NotificationSMS   NotificationChannel = "sms"
+	NotificationPush  NotificationChannel = "push"
+)
+
+// NotificationKind is what a notification tells the holder about.
+type NotificationKind string
+
+const (
+	NotificationLowBalance       NotificationKind = "low_balance"
+	NotificationIncomingTransfer NotificationKind = "incoming_transfer"
+	NotificationNewDeviceLogin   NotificationKind = "new_device_login"
+	NotificationLoginLocked      NotificationKind = "login_locked"
+	NotificationPaymentRequest   NotificationKind = "payment_request"
+	// NotificationPaymentDeclined tells the requester a payment request
+	// was declined; accepted ones are notified as incoming transfers.
+	NotificationPaymentDeclined NotificationKind = "payment_request_declined"
+)
+
+// NotificationPreferences are what the holder of an account wants to be
+// told about and on which channels. Every channel needs its destination:
+// Email, Phone or PushToken. A LowBalanceThreshold of 0 turns low balance
+// notifications off.
+type NotificationPreferences struct {
+	AccountNumber       int64                 `json:"accountNumber"`
+	Channels            []NotificationChannel `json:"channels"`
+	Email               string                `json:"email,omitempty"`
+	Phone               string                `json:"phone,omitempty"`
+	PushToken           string                `json:"pushToken,omitempty"`
+	LowBalanceThreshold int64                 `json:"lowBalanceThreshold"`
+	IncomingTransfer    bool                  `json:"incomingTransfer"`
+	NewDeviceLogin      bool                  `json:"newDeviceLogin"`
+	UpdatedAt           *time.Time            `json:"updatedAt,omitempty"`
+}
+
+type NotificationPreferencesRequest struct {
+	Channels            []NotificationChannel `json:"channels,omitempty"`
+	Email               string                `json:"email"`
+	Phone               string                `json:"phone"`
+	PushToken           string                `json:"pushToken"`
+	LowBalanceThreshold int64                 `json:"lowBalanceThreshold"`
+	IncomingTransfer    bool                  `json:"incomingTransfer"`
+	NewDeviceLogin      bool                  `json:"newDeviceLogin"`
+}
+
+type NotificationDeliveryStatus string
+
+const (
+	NotificationDeliveryPending   NotificationDeliveryStatus = "pending"
+	NotificationDeliveryDelivered NotificationDeliveryStatus = "delivered"
+	NotificationDeliveryFailed    NotificationDeliveryStatus = "failed"
+)
+
+// NotificationDelivery is a notification queued for one channel, sent in
+// the background by the NotificationDispatcher and retried until it is
+// delivered or has failed too often.
+type NotificationDelivery struct {
+	ID            int                        `json:"id"`
+	AccountNumber int64                      `json:"accountNumber"`
+	Channel       NotificationChannel        `json:"channel"`
+	To            string                     `json:"to"`
+	Kind          NotificationKind           `json:"kind"`
+	Subject       string                     `json:"subject"`
+	Body          string                     `json:"body"`
+	Status        NotificationDeliveryStatus `json:"status"`
+	Attempts      int                        `json:"attempts"`
+	NextAttemptAt time.Time                  `json:"nextAttemptAt"`
+	LastError     string                     `json:"lastError,omitempty"`
+	CreatedAt     time.Time                  `json:"createdAt"`
+	DeliveredAt   *time.Time                 `json:"deliveredAt,omitempty"`
+}
+
+// OutboxMessage is an event written in the transaction that caused it, for
+// the OutboxRelay to publish to the message broker.
+type OutboxMessage struct {
+	ID            int64
+	MessageID     string
+	Topic         string
+	Key           string
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	PublishedAt   *time.Time
+	CreatedAt     time.Time
+}
+
+type TransactionType string
+
+const (
+	TransactionTransferIn  TransactionType = "transfer_in"
+	TransactionTransferOut TransactionType = "transfer_out"
+	TransactionDeposit     TransactionType = "deposit"
+	TransactionWithdrawal  TransactionType = "withdrawal"
+	TransactionFee         TransactionType = "fee"
+	TransactionInterest    TransactionType = "interest"
+	// TransactionReversal entries compensate a disputed entry, in part or in
+	// full, on both the disputing account and the counterparty, or move a
+	// reversed transfer back.
+	TransactionReversal TransactionType = "reversal"
+	// TransactionExternalOut books a settled external transfer, and
+	// TransactionExternalReturn gives back one returned after settling.
+	TransactionExternalOut    TransactionType = "external_out"
+	TransactionExternalReturn TransactionType = "external_return"
+	// TransactionImport books an external transaction imported from a CSV
+	// or OFX file.
+	TransactionImport TransactionType = "import"
+	// TransactionAdjustment corrects a balance by hand, after a second
+	// admin approved it.
+	TransactionAdjustment TransactionType = "adjustment"
+	// TransactionLoanDisbursement pays a loan's principal into its account
+	// and TransactionLoanRepayment collects one of its installments.
+	TransactionLoanDisbursement TransactionType = "loan_disbursement"
+	TransactionLoanRepayment    TransactionType = "loan_repayment"
+	// TransactionTermDeposit takes a term deposit's principal out of its
+	// account and TransactionTermDepositPayout pays it back.
+	TransactionTermDeposit       TransactionType = "term_deposit"
+	TransactionTermDepositPayout TransactionType = "term_deposit_payout"
+)
+
+// Transaction is an account's view of its line in a journal entry. Amount is
+// signed: credits are positive and debits negative, and Balance is the
+// account balance after the entry was applied.
+type Transaction struct {
+	ID            int             `json:"id"`
+	JournalID     int             `json:"journalId"`
+	AccountNumber int64           `json:"accountNumber"`
+	Type          TransactionType `json:"type"`
+	Amount        int64           `json:"amount"`
+	Balance       int64           `json:"balance"`
+	Counterparty  *int64          `json:"counterparty,omitempty"`
+	// Category is set by the account holder, e.g. rent or groceries.
+	Category string `json:"category,omitempty"`
+	// Description explains entries booked by the bank, e.g. the reason of
+	// an adjustment.
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	// TransferReference is that of the transfer of transfer entries.
+	TransferReference
+
+	// Display has the amount and balance written for the locale a
+	// statement was requested in.
+	Display map[string]string `json:"display,omitempty"`
+
+	// accountVersion is the version of the account once the transaction
+	// was written, only known to the caller that wrote it.
+	accountVersion int
+}
+
+type ImportFormat string
+
+const (
+	ImportCSV ImportFormat = "csv"
+	ImportOFX ImportFormat = "ofx"
+)
+
+// CSVMapping names the columns of an imported CSV file, matched against its
+// header without regard to case. Description and ID are optional; DateFormat
+// is a Go time layout.
+type CSVMapping struct {
+	Date        string
+	Amount      string
+	Description string
+	ID          string
+	DateFormat  string
+	Delimiter   rune
+}
+
+type ImportRowStatus string
+
+const (
+	// ImportRowNew rows would be imported by a dry run.
+	ImportRowNew       ImportRowStatus = "new"
+	ImportRowImported  ImportRowStatus = "imported"
+	ImportRowDuplicate ImportRowStatus = "duplicate"
+)
+
+// ImportRow is one external transaction of an imported file. TransactionID
+// is the ledger entry it was booked as, or the one it duplicates.
+type ImportRow struct {
+	ExternalID    string          `json:"externalId,omitempty"`
+	Date          string          `json:"date"`
+	Amount        int64           `json:"amount"`
+	Description   string          `json:"description,omitempty"`
+	Status        ImportRowStatus `json:"status"`
+	TransactionID *int            `json:"transactionId,omitempty"`
+
+	// fingerprint identifies the row across imports of the same file.
+	fingerprint string
+}
+
+type ImportResult struct {
+	DryRun     bool         `json:"dryRun"`
+	Imported   int          `json:"imported"`
+	Duplicates int          `json:"duplicates"`
+	Rows       []*ImportRow `json:"rows"`
+}
+
+// TransactionSearch selects the entries of an account whose reference, memo
+// or end-to-end ID contains Query, ignoring case, in feed order, newest
+// first. /v2 pages them with Before, /v1 with Offset.
+type TransactionSearch struct {
+	Query  string
+	Before *TransactionCursor
+	Limit  int
+	Offset int
+}
+
+// TransactionPage is a page of the /v2 transactions feed. NextCursor fetches
+// the next page and is only set while HasMore is.
+type TransactionPage struct {
+	Transactions []*Transaction `json:"transactions"`
+	NextCursor   string         `json:"nextCursor,omitempty"`
+	HasMore      bool           `json:"hasMore"`
+}
+
+type AccountEventType string
+
+const (
+	AccountOpened    AccountEventType = "AccountOpened"
+	MoneyDeposited   AccountEventType = "MoneyDeposited"
+	MoneyWithdrawn   AccountEventType = "MoneyWithdrawn"
+	TransferSent     AccountEventType = "TransferSent"
+	TransferReceived AccountEventType = "TransferReceived"
+	FeeCharged       AccountEventType = "FeeCharged"
+	InterestPaid     AccountEventType = "InterestPaid"
+	MoneyReversed    AccountEventType = "MoneyReversed"
+
+	ExternalPaymentSent     AccountEventType = "ExternalPaymentSent"
+	ExternalPaymentReturned AccountEventType = "ExternalPaymentReturned"
+	TransactionImported     AccountEventType = "TransactionImported"
+	BalanceAdjusted         AccountEventType = "BalanceAdjusted"
+	LoanDisbursed           AccountEventType = "LoanDisbursed"
+	LoanRepaid              AccountEventType = "LoanRepaid"
+	TermDepositOpened       AccountEventType = "TermDepositOpened"
+	TermDepositPaidOut      AccountEventType = "TermDepositPaidOut"
+)
+
+// AccountEvent is a fact about an account, appended in the same database
+// transaction as the change it records and never updated. An account's
+// events in Version order are its full history.
+type AccountEvent struct {
+	AccountNumber int64            `json:"accountNumber"`
+	Version       int              `json:"version"`
+	Type          AccountEventType `json:"type"`
+	// Amount is the opening balance of AccountOpened, and what every other
+	// event added to the balance, negative for debits.
+	Amount int64 `json:"amount"`
+	// Currency is only set on AccountOpened.
+	Currency     string    `json:"currency,omitempty"`
+	Counterparty *int64    `json:"counterparty,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// AccountEventFilter selects the events after AfterVersion created before
+// Until (unbounded if zero), at most Limit of them (all if 0).
+type AccountEventFilter struct {
+	AfterVersion int
+	Until        time.Time
+	Limit        int
+}
+
+// AccountAggregate is an account's state rebuilt by replaying its events.
+type AccountAggregate struct {
+	AccountNumber int64     `json:"accountNumber"`
+	Currency      string    `json:"currency"`
+	Balance       int64     `json:"balance"`
+	Version       int       `json:"version"`
+	OpenedAt      time.Time `json:"openedAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// ProjectionReport compares the balances rebuilt from the events with the
+// stored ones.
+type ProjectionReport struct {
+	Consistent bool                  `json:"consistent"`
+	Accounts   int                   `json:"accounts"`
+	Mismatches []*ProjectionMismatch `json:"mismatches"`
+}
+
+type ProjectionMismatch struct {
+	AccountNumber int64 `json:"accountNumber"`
+	Balance       int64 `json:"balance"`
+	Replayed      int64 `json:"replayed"`
+}
+
+type CategoryRequest struct {
+	Category string `json:"category"`
+}
+
+// CategoryTotal sums an account's entries of one category in the month
+// starting at Month. Uncategorized entries are totalled under
+// uncategorizedCategory.
+type CategoryTotal struct {
+	Category string
+	Month    time.Time
+	Credits  int64
+	Debits   int64
+	Count    int
+}
+
+// AccountStats summarises the open accounts for the admin dashboard.
+type AccountStats struct {
+	TotalAccounts int                   `json:"totalAccounts"`
+	ByStatus      map[AccountStatus]int `json:"byStatus"`
+	// Balances totals the balances held per currency.
+	Balances []*CurrencyBalance `json:"balances"`
+}
+
+type CurrencyBalance struct {
+	Currency string `json:"currency"`
+	Accounts int    `json:"accounts"`
+	Balance  int64  `json:"balance"`
+}
+
+// DailyTransferVolume totals the transfers sent in Currency on Date.
+type DailyTransferVolume struct {
+	Date     string `json:"date"`
+	Currency string `json:"currency"`
+	Count    int    `json:"count"`
+	Amount   int64  `json:"amount"`
+}
+
+// ClosingBalance is an account's balance at the end of Date, in UTC.
+type ClosingBalance struct {
+	AccountNumber int64  `json:"accountNumber"`
+	Date          string `json:"date"`
+	Balance       int64  `json:"balance"`
+}
+
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type Trend string
+
+const (
+	TrendUp   Trend = "up"
+	TrendDown Trend = "down"
+	TrendFlat Trend = "flat"
+)
+
+// SpendingAnalytics breaks an account's entries down by category and month
+// for the months From through To.
+type SpendingAnalytics struct {
+	AccountNumber int64                `json:"accountNumber"`
+	Currency      string               `json:"currency"`
+	From          string               `json:"from"`
+	To            string               `json:"to"`
+	Categories    []*CategoryAnalytics `json:"categories"`
+}
+
+type CategoryAnalytics struct {
+	Category     string          `json:"category"`
+	TotalCredits int64           `json:"totalCredits"`
+	TotalDebits  int64           `json:"totalDebits"`
+	Months       []*MonthlyTotal `json:"months"`
+	// Change is the amount moved in the last month minus the amount moved
+	// in the month before it, and Trend its direction.
+	Change int64 `json:"change"`
+	Trend  Trend `json:"trend"`
+	// Display has the amounts written for the requested locale.
+	Display map[string]string `json:"display,omitempty"`
+}
+
+type MonthlyTotal struct {
+	Month   string `json:"month"`
+	Credits int64  `json:"credits"`
+	Debits  int64  `json:"debits"`
+	Count   int    `json:"count"`
+	// Display has the amounts written for the requested locale, see
+	// requestLocale.
+	Display map[string]string `json:"display,omitempty"`
+}
+
+// Statement summarises an account's ledger for the period [From, To).
+type Statement struct {
+	AccountNumber  int64          `json:"accountNumber"`
+	Name           string         `json:"name"`
+	Currency       string         `json:"currency"`
+	From           time.Time      `json:"from"`
+	To             time.Time      `json:"to"`
+	OpeningBalance int64          `json:"openingBalance"`
+	ClosingBalance int64          `json:"closingBalance"`
+	TotalCredits   int64          `json:"totalCredits"`
+	TotalDebits    int64          `json:"totalDebits"`
+	Transactions   []*Transaction `json:"transactions"`
+	// Display has the amounts written for the requested locale, by the
+	// name of their field.
+	Display map[string]string `json:"display,omitempty"`
+}
+
+// NewStatement totals the period's entries. The closing balance is the
+// balance after the last entry, or the opening balance if there are none.
+func NewStatement(account *Account, from, to time.Time, opening int64, transactions []*Transaction) *Statement {
+	statement := &Statement{
+		AccountNumber:  account.Number,
+		Name:           account.FirstName + " " + account.LastName,
+		Currency:       account.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+		Transactions:   transactions,
+	}
+
+	for _, t := range transactions {
+		if t.Amount >= 0 {
+			statement.TotalCredits += t.Amount
+		} else {
+			statement.TotalDebits -= t.Amount
+		}
+
+		statement.ClosingBalance = t.Balance
+	}
+
+	return statement
+}
+
+type AmountRequest struct {
+	Amount int64 `json:"amount"`
+	// Version is the account version a deposit or withdrawal is based on.
+	// The If-Match header can give it instead.
+	Version int `json:"version,omitempty"`
+}
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyPending   IdempotencyStatus = "pending"
+	IdempotencyCompleted IdempotencyStatus = "completed"
+)
+
+// IdempotencyRecord stores the outcome of a request made with an
+// Idempotency-Key header so retries can be answered without re-executing it.
+type IdempotencyRecord struct {
+	Key          string
+	Scope        string
+	RequestHash  string
+	Status       IdempotencyStatus
+	StatusCode   int
+	ResponseBody []byte
+	CreatedAt    time.Time
 }
 
 type AccountRequest struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Password  string `json:"password"`
+	FirstName string      `json:"firstName"`
+	LastName  string      `json:"lastName"`
+	Password  string      `json:"password"`
+	Currency  string      `json:"currency"`
+	Type      AccountType `json:"type"`
+	// InitialDeposit funds a new account as it is created.
+	InitialDeposit *InitialDeposit `json:"initialDeposit,omitempty"`
+	// Version is the account version PUT /account/{id} is based on. The
+	// If-Match header can give it instead.
+	Version int `json:"version,omitempty"`
+}
+
+// AccountPatchRequest changes the metadata of an account as a JSON merge
+// patch: null removes a key, any other value sets it, and keys left out
+// are kept.
+type AccountPatchRequest struct {
+	Metadata map[string]*string `json:"metadata"`
+	// Version is the account version the patch is based on. The If-Match
+	// header can give it instead.
+	Version int `json:"version,omitempty"`
+}
+
+type InitialDepositSource string
+
+// InitialDepositTransfer deposits are transferred from FromAccount, which
+// the requester must own. It is the only source: cash is booked by admins
+// once the account is open.
+const InitialDepositTransfer InitialDepositSource = "transfer"
+
+// InitialDeposit transfers go through the checks of POST /transfer, so
+// TOTPCode is needed above the step-up amount.
+type InitialDeposit struct {
+	Source      InitialDepositSource `json:"source"`
+	Amount      int64                `json:"amount"`
+	FromAccount int64                `json:"fromAccount,omitempty"`
+	TOTPCode    string               `json:"totpCode,omitempty"`
 }
 
+// AccountFilter narrows and orders GET /account. Sort is a column name,
+// optionally prefixed with "-" for descending order.
+type AccountFilter struct {
+	Limit      int
+	Offset     int
+	Sort       string
+	LastName   string
+	MinBalance *int64
+	// Metadata keeps the accounts with every one of these metadata values.
+	Metadata map[string]string
+}
+
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleAdmin    Role = "admin"
+	// RoleCompliance is held by compliance officers, who review AML flags.
+	RoleCompliance Role = "compliance"
+)
+
+type AccountStatus string
+
+const (
+	AccountActive AccountStatus = "active"
+	AccountFrozen AccountStatus = "frozen"
+	AccountClosed AccountStatus = "closed"
+)
+
+type AccountType string
+
+const (
+	AccountChecking AccountType = "checking"
+	AccountSavings  AccountType = "savings"
+	// AccountBusiness accounts have users with their own logins, who view,
+	// initiate or approve as their role allows.
+	AccountBusiness AccountType = "business"
+)
+
+// AccountLimits controls how far an account may be debited. The balance may
+// not drop below MinimumBalance - OverdraftLimit, and every debit that leaves
+// the balance below MinimumBalance is charged OverdraftFee.
+//
+// Transfers above DualApprovalAmount from an account with co-owners need a
+// second owner's approval, 0 disables it.
+type AccountLimits struct {
+	OverdraftLimit     int64 `json:"overdraftLimit"`
+	MinimumBalance     int64 `json:"minimumBalance"`
+	OverdraftFee       int64 `json:"overdraftFee"`
+	DualApprovalAmount int64 `json:"dualApprovalAmount"`
+}
+
+// Account is an account and its holder. Balance is the booked balance, the
+// sum of its ledger entries; HeldBalance and PotBalance are the parts of it
+// that holds, card authorizations, pending external transfers and pots have
+// set aside. What is left, AvailableBalance, is what debits are checked
+// against.
 type Account struct {
-	ID                int       `json:"id"`
-	FirstName         string    `json:"firstName"`
-	LastName          string    `json:"lastName"`
-	Number            int64     `json:"number"`
-	EncryptedPassword string    `json:"-"`
-	Balance           int64     `json:"balance"`
-	CreatedAt         time.Time `json:"createdAt"`
+	ID                int           `json:"id"`
+	FirstName         string        `json:"firstName"`
+	LastName          string        `json:"lastName"`
+	Number            int64         `json:"number"`
+	EncryptedPassword string        `json:"-"`
+	Balance           int64         `json:"balance"`
+	HeldBalance       int64         `json:"heldBalance"`
+	PotBalance        int64         `json:"potBalance"`
+	Currency          string        `json:"currency"`
+	Role              Role          `json:"role"`
+	Type              AccountType   `json:"type"`
+	Status            AccountStatus `json:"status"`
+	KYCStatus         KYCStatus     `json:"kycStatus"`
+	CreatedAt         time.Time     `json:"createdAt"`
+	// DeletedAt is set on soft-deleted accounts, which the store hides
+	// until an admin restores them.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Version goes up with every change to the account. Clients send the
+	// version they read to update the account or its balance, and are
+	// refused if it has changed since.
+	Version int `json:"version"`
+	// Metadata is free-form key-value data integrators attach to the
+	// account, such as a CRM id or segment, set with PATCH /account/{id}.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	AccountLimits
+	AccountInterest
+}
+
+type KYCStatus string
+
+const (
+	// KYCPending accounts have not submitted their details yet, or are
+	// waiting for an admin to review them.
+	KYCPending  KYCStatus = "pending"
+	KYCVerified KYCStatus = "verified"
+	KYCRejected KYCStatus = "rejected"
+)
+
+// KYCRequest submits the details an admin checks to verify the account
+// holder's identity. DateOfBirth is YYYY-MM-DD.
+type KYCRequest struct {
+	DateOfBirth string `json:"dateOfBirth"`
+	NationalID  string `json:"nationalId"`
+	Address     string `json:"address"`
+}
+
+type KYCRejectRequest struct {
+	Reason string `json:"reason"`
+}
+
+// KYC is the identity verification of an account. Status is the account's
+// KYCStatus.
+type KYC struct {
+	AccountNumber   int64      `json:"accountNumber"`
+	Status          KYCStatus  `json:"status"`
+	DateOfBirth     string     `json:"dateOfBirth"`
+	NationalID      string     `json:"nationalId"`
+	Address         string     `json:"address"`
+	RejectionReason string     `json:"rejectionReason,omitempty"`
+	SubmittedAt     time.Time  `json:"submittedAt"`
+	ReviewedAt      *time.Time `json:"reviewedAt,omitempty"`
+}
+
+// AccountInterest tracks the interest a savings account has earned since it
+// was last posted to the ledger. AccruedInterest is in minor units; the
+// fraction of a minor unit left over is kept in InterestRemainder, in
+// millionths, so small daily amounts are not rounded away.
+type AccountInterest struct {
+	AccruedInterest        int64      `json:"accruedInterest"`
+	InterestRemainder      int64      `json:"-"`
+	InterestAccruedThrough *time.Time `json:"-"`
+}
+
+// CheckActive returns an error unless money can move in or out of the
+// account, i.e. it is neither frozen nor closed.
+func (acc *Account) CheckActive() error {
+	if acc.Status != AccountActive {
+		return accountInactiveError(acc.Number, acc.Status)
+	}
+
+	return nil
+}
+
+// CheckVersion refuses a change based on another version of the account
+// than the stored one. Version 0 skips the check.
+func (acc *Account) CheckVersion(version int) error {
+	if version != 0 && version != acc.Version {
+		return versionConflictError(acc.Number, version, acc.Version)
+	}
+
+	return nil
+}
+
+// CheckStatusChange validates moving the account to status. Frozen accounts
+// can only be unfrozen or closed, closing is final and requires a zero
+// balance so no money is left behind.
+func (acc *Account) CheckStatusChange(status AccountStatus) error {
+	switch {
+	case acc.Status == AccountClosed:
+		return conflictError("account %d is closed", acc.ID)
+	case status == AccountClosed && acc.Balance != 0:
+		return conflictError("account %d has a balance of %d, empty it before closing", acc.ID, acc.Balance)
+	case status == AccountClosed && acc.HeldBalance != 0:
+		return conflictError("account %d has %d on hold, capture or let the holds expire before closing", acc.ID, acc.HeldBalance)
+	case status == AccountClosed && acc.PotBalance != 0:
+		return conflictError("account %d has %d in pots, empty them before closing", acc.ID, acc.PotBalance)
+	}
+
+	return nil
+}
+
+// CheckClose validates closing the account at its holder's request. Unlike
+// CheckStatusChange it allows a balance, which is swept to sweepTo: an
+// active account in the same currency. Overdrawn accounts must be repaid
+// first.
+func (acc *Account) CheckClose(sweepTo *Account) error {
+	if acc.Balance < 0 {
+		return validationError("account %d is overdrawn by %d, repay it before closing", acc.ID, -acc.Balance)
+	}
+
+	if acc.Balance == 0 {
+		return acc.CheckStatusChange(AccountClosed)
+	}
+
+	switch {
+	case sweepTo == nil:
+		return conflictError("account %d has a balance of %d, give an account to sweep it to before closing", acc.ID, acc.Balance)
+	case sweepTo.Number == acc.Number:
+		return validationError("cannot sweep account %d into itself", acc.Number)
+	case sweepTo.Currency != acc.Currency:
+		return conflictError("account %d is in %s, the balance can only be swept to an account in %s", sweepTo.Number, sweepTo.Currency, acc.Currency)
+	case acc.HeldBalance != 0:
+		return conflictError("account %d has %d on hold, capture or let the holds expire before closing", acc.ID, acc.HeldBalance)
+	case acc.PotBalance != 0:
+		return conflictError("account %d has %d in pots, empty them before closing", acc.ID, acc.PotBalance)
+	}
+
+	if err := acc.CheckActive(); err != nil {
+		return err
+	}
+
+	return sweepTo.CheckActive()
+}
+
+// CheckDelete validates soft-deleting the account. Like closing, it requires
+// that no money is left behind.
+func (acc *Account) CheckDelete() error {
+	switch {
+	case acc.Balance != 0:
+		return conflictError("account %d has a balance of %d, empty it before deleting", acc.ID, acc.Balance)
+	case acc.HeldBalance != 0:
+		return conflictError("account %d has %d on hold, capture or let the holds expire before deleting", acc.ID, acc.HeldBalance)
+	case acc.PotBalance != 0:
+		return conflictError("account %d has %d in pots, empty them before deleting", acc.ID, acc.PotBalance)
+	}
+
+	return nil
+}
+
+// CanDebit reports whether amount can be taken from the account's available
+// balance, i.e. not counting held funds or money in pots, without breaching
+// its minimum balance and overdraft limit.
+func (acc *Account) CanDebit(amount int64) bool {
+	available, ok := acc.available()
+
+	if !ok {
+		return false
+	}
+
+	after, ok := subMinorUnits(available, amount)
+
+	if !ok {
+		return false
+	}
+
+	floor, ok := subMinorUnits(acc.MinimumBalance, acc.OverdraftLimit)
+
+	return ok && after >= floor
+}
+
+// AvailableBalance is the booked balance less what is held or in pots.
+func (acc *Account) AvailableBalance() int64 {
+	available, _ := acc.available()
+
+	return available
+}
+
+// MarshalJSON adds the available balance to the fields of the account.
+func (acc Account) MarshalJSON() ([]byte, error) {
+	// account without its methods, so encoding it doesn't recurse
+	type account Account
+
+	return json.Marshal(struct {
+		account
+		AvailableBalance int64 `json:"availableBalance"`
+	}{account(acc), acc.AvailableBalance()})
+}
+
+// available is the balance not held or in pots, and false if it overflows
+// int64, leaving nothing available.
+func (acc *Account) available() (int64, bool) {
+	available, ok := subMinorUnits(acc.Balance, acc.HeldBalance)
+
+	if ok {
+		available, ok = subMinorUnits(available, acc.PotBalance)
+	}
+
+	if !ok {
+		return math.MinInt64, false
+	}
+
+	return available, true
+}
+
+// addMinorUnits returns a+b and false if the sum overflows int64.
+func addMinorUnits(a, b int64) (int64, bool) {
+	sum := a + b
+
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+
+	return sum, true
+}
+
+// subMinorUnits returns a-b and false if the difference overflows int64.
+func subMinorUnits(a, b int64) (int64, bool) {
+	if b == math.MinInt64 {
+		return 0, false
+	}
+
+	return addMinorUnits(a, -b)
+}
+
+// OverdraftFeeFor returns the fee owed for a debit that left the account at
+// balance.
+func (acc *Account) OverdraftFeeFor(balance int64) int64 {
+	if balance < acc.MinimumBalance {
+		return acc.OverdraftFee
+	}
+
+	return 0
 }
 
 func (acc *Account) ValidPassword(password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(acc.EncryptedPassword), []byte(password)) == nil
 }
 
-func NewAccount(firstName, lastName, password string) (*Account, error) {
+func (acc *Account) SetPassword(password string) error {
 	encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
 	if err != nil {
+		return err
+	}
+
+	acc.EncryptedPassword = string(encryptedPassword)
+
+	return nil
+}
+
+func NewAccount(firstName, lastName, password string) (*Account, error) {
+	acc := newAccount(firstName, lastName)
+
+	if err := acc.SetPassword(password); err != nil {
 		return nil, err
 	}
 
+	return acc, nil
+}
+
+// newAccount is NewAccount without a password, for callers that hash it
+// themselves.
+func newAccount(firstName, lastName string) *Account {
 	return &Account{
-		FirstName:         firstName,
-		LastName:          lastName,
-		EncryptedPassword: string(encryptedPassword),
-		Number:            int64(rand.Intn(100000)),
-		CreatedAt:         time.Now().UTC(),
-	}, nil
+		FirstName: firstName,
+		LastName:  lastName,
+		Number:    defaultAccountNumbers.Generate(),
+		Currency:  defaultCurrency,
+		Role:      RoleCustomer,
+		Type:      AccountChecking,
+		Status:    AccountActive,
+		KYCStatus: KYCPending,
+		CreatedAt: time.Now().UTC(),
+	}
 }
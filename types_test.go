@@ -1,9 +1,13 @@
 package main
 
 import (
+	"encoding/json"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewAccount(t *testing.T) {
@@ -11,3 +15,84 @@ func TestNewAccount(t *testing.T) {
 
 	assert.Nil(t, err)
 }
+
+func TestNewRefreshToken(t *testing.T) {
+	refreshToken, token, err := NewRefreshToken(42, time.Hour)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, hashToken(token), refreshToken.TokenHash)
+	assert.NotEqual(t, token, refreshToken.TokenHash)
+	assert.True(t, refreshToken.ExpiresAt.After(refreshToken.CreatedAt))
+}
+
+func TestAccountCanDebit(t *testing.T) {
+	acc := &Account{Balance: 100}
+
+	assert.True(t, acc.CanDebit(100))
+	assert.False(t, acc.CanDebit(101))
+
+	acc.AccountLimits = AccountLimits{OverdraftLimit: 50, MinimumBalance: 20, OverdraftFee: 5}
+
+	assert.True(t, acc.CanDebit(130))
+	assert.False(t, acc.CanDebit(131))
+	assert.Equal(t, int64(0), acc.OverdraftFeeFor(20))
+	assert.Equal(t, int64(5), acc.OverdraftFeeFor(19))
+}
+
+func TestAccountCanDebitOverflow(t *testing.T) {
+	acc := &Account{Currency: "USD", Balance: 100}
+	acc.MinimumBalance, acc.OverdraftLimit = -10, math.MaxInt64
+	assert.False(t, acc.CanDebit(math.MaxInt64), "the floor overflows")
+
+	acc = &Account{Currency: "USD", Balance: math.MinInt64 + 10}
+	assert.False(t, acc.CanDebit(100), "the balance after the debit overflows")
+
+	acc = &Account{Currency: "USD", Balance: 100}
+	acc.MinimumBalance = 10
+	assert.True(t, acc.CanDebit(90))
+	assert.False(t, acc.CanDebit(91))
+
+	_, ok := addMinorUnits(math.MaxInt64, 1)
+	assert.False(t, ok)
+	_, ok = subMinorUnits(0, math.MinInt64)
+	assert.False(t, ok)
+}
+
+func TestAccountAvailableBalance(t *testing.T) {
+	acc := &Account{Number: 42, Balance: 100, HeldBalance: 30, PotBalance: 20, Currency: "USD", EncryptedPassword: "hash"}
+
+	assert.Equal(t, int64(50), acc.AvailableBalance())
+	assert.True(t, acc.CanDebit(50))
+	assert.False(t, acc.CanDebit(51), "held and pot money can't be spent")
+
+	data, err := json.Marshal(acc)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"balance":100,"heldBalance":30,"potBalance":20`)
+	assert.Contains(t, string(data), `"availableBalance":50`)
+	assert.NotContains(t, string(data), "hash")
+
+	data, err = json.Marshal(NewArchivedAccount(acc))
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"encryptedPassword":"hash"`, "archives keep their own fields")
+	assert.Contains(t, string(data), `"number":42`)
+}
+
+func TestAccountStatusChanges(t *testing.T) {
+	acc := &Account{Status: AccountActive}
+	assert.Nil(t, acc.CheckActive())
+	assert.Nil(t, acc.CheckStatusChange(AccountFrozen))
+
+	acc.Status = AccountFrozen
+	assert.NotNil(t, acc.CheckActive())
+	assert.Nil(t, acc.CheckStatusChange(AccountActive))
+
+	acc.Balance = 10
+	assert.NotNil(t, acc.CheckStatusChange(AccountClosed))
+
+	acc.Balance = 0
+	assert.Nil(t, acc.CheckStatusChange(AccountClosed))
+
+	acc.Status = AccountClosed
+	assert.NotNil(t, acc.CheckStatusChange(AccountActive))
+}
@@ -0,0 +1,719 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxNameLength     = 50
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+	maxCategoryLength = 30
+	maxUsernameLength = 50
+	// transfer references and end-to-end ids fit the 35 characters of SEPA
+	// and SWIFT
+	maxTransferReferenceLength = 35
+	maxMemoLength              = 140
+)
+
+// loanRatePattern is an annual rate as a decimal fraction, e.g. 0.0599.
+var loanRatePattern = regexp.MustCompile(`^[01](\.[0-9]{1,6})?$`)
+
+var categoryPattern = regexp.MustCompile(fmt.Sprintf(`^[a-z][a-z0-9_-]{0,%d}$`, maxCategoryLength-1))
+
+// Validator is implemented by request payloads that check their own fields.
+// decodeJSON calls Validate on every payload it decodes.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes why a single field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors collects the problems found in a request.
+type FieldErrors []FieldError
+
+func (e *FieldErrors) Add(field, format string, a ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+// Err returns nil when no problem was found, or a 422 validation error that
+// lists every field error in its details.
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(e))
+
+	for i, fe := range e {
+		messages[i] = fe.Field + " " + fe.Message
+	}
+
+	err := newHTTPError(http.StatusUnprocessableEntity, ErrorCodeValidation, "invalid request: %s", strings.Join(messages, "; "))
+	err.Details = e
+
+	return err
+}
+
+func (e *FieldErrors) requireName(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		e.Add(field, "is required")
+	} else if utf8.RuneCountInString(value) > maxNameLength {
+		e.Add(field, "must be at most %d characters", maxNameLength)
+	}
+}
+
+func (e *FieldErrors) requirePositive(field string, value int64) {
+	if value <= 0 {
+		e.Add(field, "must be greater than 0")
+	}
+}
+
+func (req *AccountRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requireName("firstName", req.FirstName)
+	errs.requireName("lastName", req.LastName)
+
+	if len(req.Password) > maxPasswordLength {
+		errs.Add("password", "must be at most %d bytes", maxPasswordLength)
+	}
+
+	if deposit := req.InitialDeposit; deposit != nil {
+		errs.requirePositive("initialDeposit.amount", deposit.Amount)
+
+		if deposit.Source != InitialDepositTransfer {
+			errs.Add("initialDeposit.source", "must be transfer")
+		}
+
+		errs.requirePositive("initialDeposit.fromAccount", deposit.FromAccount)
+	}
+
+	return errs.Err()
+}
+
+func (req *AccountPatchRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if len(req.Metadata) == 0 {
+		errs.Add("metadata", "is required")
+	} else if len(req.Metadata) > maxMetadataKeys {
+		errs.Add("metadata", "must have at most %d keys", maxMetadataKeys)
+	}
+
+	keys := make([]string, 0, len(req.Metadata))
+
+	for key := range req.Metadata {
+		keys = append(keys, key)
+	}
+
+	// sorted so the errors come in the same order every time
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if value := req.Metadata[key]; !metadataKeyPattern.MatchString(key) {
+			errs.Add("metadata."+key, "must be a key of at most %d letters, digits, _, . or -", maxMetadataKeyLength)
+		} else if value != nil {
+			errs.checkText("metadata."+key, *value, maxMetadataValueLength)
+		}
+	}
+
+	return errs.Err()
+}
+
+func (req *PasswordForgotRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requirePositive("number", req.Number)
+
+	return errs.Err()
+}
+
+func (req *PasswordResetRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.Token == "" {
+		errs.Add("token", "is required")
+	}
+
+	if req.Password == "" {
+		errs.Add("password", "is required")
+	} else if len(req.Password) > maxPasswordLength {
+		errs.Add("password", "must be at most %d bytes", maxPasswordLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *BusinessUserRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.Username == "" {
+		errs.Add("username", "is required")
+	} else if utf8.RuneCountInString(req.Username) > maxUsernameLength || strings.IndexFunc(req.Username, unicode.IsSpace) >= 0 || strings.IndexFunc(req.Username, unicode.IsControl) >= 0 {
+		errs.Add("username", "must be at most %d characters without spaces", maxUsernameLength)
+	}
+
+	if req.Password == "" {
+		errs.Add("password", "is required")
+	} else if len(req.Password) > maxPasswordLength {
+		errs.Add("password", "must be at most %d bytes", maxPasswordLength)
+	}
+
+	errs.checkBusinessUserRole(req.Role)
+
+	return errs.Err()
+}
+
+func (req *BusinessUserRoleRequest) Validate() error {
+	errs := FieldErrors{}
+	errs.checkBusinessUserRole(req.Role)
+
+	return errs.Err()
+}
+
+func (e *FieldErrors) checkBusinessUserRole(role BusinessUserRole) {
+	if _, ok := businessUserRoutes[role]; !ok {
+		e.Add("role", "must be viewer, initiator or approver")
+	}
+}
+
+func (req *PotRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requireName("name", req.Name)
+	errs.requirePositive("targetAmount", req.TargetAmount)
+
+	if req.WeeklyAmount < 0 {
+		errs.Add("weeklyAmount", "must not be negative")
+	}
+
+	return errs.Err()
+}
+
+// Validate checks the request's shape; whether its term is offered is up to
+// the configured products.
+func (req *TermDepositRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requirePositive("amount", req.Amount)
+
+	if req.TermMonths < 1 || req.TermMonths > maxTermDepositMonths {
+		errs.Add("termMonths", "must be between 1 and %d", maxTermDepositMonths)
+	}
+
+	return errs.Err()
+}
+
+func (req *StandingOrderRequest) Validate() error {
+	errs := FieldErrors{}
+
+	switch {
+	case req.BeneficiaryID == 0:
+		errs.requirePositive("toAccount", int64(req.ToAccount))
+	case req.BeneficiaryID < 0:
+		errs.requirePositive("beneficiaryId", int64(req.BeneficiaryID))
+	case req.ToAccount != 0:
+		errs.Add("toAccount", "must not be set together with beneficiaryId")
+	}
+
+	errs.requirePositive("amount", req.Amount)
+
+	switch req.Frequency {
+	case StandingOrderWeekly, StandingOrderMonthly, StandingOrderLastBusinessDay:
+	default:
+		errs.Add("frequency", "must be weekly, monthly or last_business_day")
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	if start, err := time.Parse(time.DateOnly, req.StartDate); err != nil {
+		errs.Add("startDate", "must be a date as YYYY-MM-DD")
+	} else if start.Before(today) {
+		errs.Add("startDate", "must not be in the past")
+	}
+
+	return errs.Err()
+}
+
+func (h *Holiday) Validate() error {
+	errs := FieldErrors{}
+
+	if _, err := time.Parse(time.DateOnly, h.Date); err != nil {
+		errs.Add("date", "must be a date as YYYY-MM-DD")
+	}
+
+	errs.requireName("name", h.Name)
+
+	return errs.Err()
+}
+
+func (req *TransferRequest) Validate() error {
+	errs := FieldErrors{}
+
+	switch {
+	case req.ToAlias != "":
+		if _, _, ok := parseAlias(req.ToAlias); !ok {
+			errs.Add("toAlias", "must be an email address or a phone number with its country code")
+		}
+
+		if req.ToAccount != 0 || req.BeneficiaryID != 0 {
+			errs.Add("toAlias", "must not be set together with toAccount or beneficiaryId")
+		}
+	case req.BeneficiaryID == 0:
+		errs.requirePositive("toAccount", int64(req.ToAccount))
+	case req.BeneficiaryID < 0:
+		errs.requirePositive("beneficiaryId", int64(req.BeneficiaryID))
+	case req.ToAccount != 0:
+		errs.Add("toAccount", "must not be set together with beneficiaryId")
+	}
+
+	errs.requirePositive("amount", req.Amount)
+	req.TransferReference.validate(&errs)
+
+	return errs.Err()
+}
+
+func (ref *TransferReference) validate(errs *FieldErrors) {
+	errs.checkText("reference", ref.Reference, maxTransferReferenceLength)
+	errs.checkText("memo", ref.Memo, maxMemoLength)
+	errs.checkText("endToEndId", ref.EndToEndID, maxTransferReferenceLength)
+}
+
+// checkText rejects optional free text longer than max characters or with
+// control characters, which would break statements.
+func (e *FieldErrors) checkText(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		e.Add(field, "must be at most %d characters", max)
+	} else if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		e.Add(field, "must not contain control characters")
+	}
+}
+
+func (req *PaymentRequestRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requirePositive("fromAccount", req.FromAccount)
+	errs.requirePositive("amount", req.Amount)
+	req.TransferReference.validate(&errs)
+
+	return errs.Err()
+}
+
+// ValidateFrom also rejects transfers from an account to itself.
+func (req *TransferRequest) ValidateFrom(from int64) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	if int64(req.ToAccount) == from {
+		errs := FieldErrors{}
+		errs.Add("toAccount", "must not be the sending account")
+		return errs.Err()
+	}
+
+	return nil
+}
+
+// ValidateFrom checks the batch and each of its transfers, reporting the
+// problems of the n-th transfer as transfers[n].field.
+func (req *TransferBatchRequest) ValidateFrom(from int64) error {
+	errs := FieldErrors{}
+
+	switch req.Mode {
+	case "", TransferBatchAtomic, TransferBatchPartial:
+	default:
+		errs.Add("mode", "must be atomic or partial")
+	}
+
+	if len(req.Transfers) == 0 {
+		errs.Add("transfers", "is required")
+	} else if len(req.Transfers) > maxTransferBatchSize {
+		errs.Add("transfers", "must have at most %d transfers", maxTransferBatchSize)
+	}
+
+	for i, item := range req.Transfers {
+		if item == nil {
+			errs.Add(fmt.Sprintf("transfers[%d]", i), "is required")
+			continue
+		}
+
+		err := item.transferRequest().ValidateFrom(from)
+
+		var httpErr *HTTPError
+
+		if errors.As(err, &httpErr) {
+			for _, fe := range httpErr.Details {
+				errs.Add(fmt.Sprintf("transfers[%d].%s", i, fe.Field), "%s", fe.Message)
+			}
+		}
+	}
+
+	return errs.Err()
+}
+
+func (req *AliasRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if _, _, ok := parseAlias(req.Value); !ok {
+		errs.Add("value", "must be an email address or a phone number with its country code")
+	}
+
+	return errs.Err()
+}
+
+func (req *ContactRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.Email == "" && req.Phone == "" && strings.TrimSpace(req.Address) == "" {
+		errs.Add("email", "one of email, phone or address is required")
+	}
+
+	if req.Email != "" {
+		if aliasType, _, ok := parseAlias(req.Email); !ok || aliasType != AliasEmail {
+			errs.Add("email", "must be an email address")
+		}
+	}
+
+	if req.Phone != "" {
+		if aliasType, _, ok := parseAlias(req.Phone); !ok || aliasType != AliasPhone {
+			errs.Add("phone", "must be a phone number with its country code")
+		}
+	}
+
+	if utf8.RuneCountInString(req.Address) > maxAddressLength {
+		errs.Add("address", "must be at most %d characters", maxAddressLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *ContactVerifyRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if strings.TrimSpace(req.Code) == "" {
+		errs.Add("code", "is required")
+	}
+
+	return errs.Err()
+}
+
+func (req *LoanRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requirePositive("accountNumber", req.AccountNumber)
+	errs.requirePositive("principal", req.Principal)
+
+	if rate, ok := new(big.Rat).SetString(req.AnnualRate); !loanRatePattern.MatchString(req.AnnualRate) || !ok || rate.Cmp(big.NewRat(1, 1)) > 0 {
+		errs.Add("annualRate", "must be a decimal rate between 0 and 1 with at most 6 decimals, e.g. 0.0599")
+	}
+
+	if req.TermMonths < 1 || req.TermMonths > maxLoanTermMonths {
+		errs.Add("termMonths", "must be between 1 and %d", maxLoanTermMonths)
+	}
+
+	return errs.Err()
+}
+
+func (req *NotificationPreferencesRequest) Validate() error {
+	errs := FieldErrors{}
+	channels := map[NotificationChannel]bool{}
+
+	for i, channel := range req.Channels {
+		field := fmt.Sprintf("channels[%d]", i)
+
+		switch channel {
+		case NotificationEmail, NotificationSMS, NotificationPush:
+		default:
+			errs.Add(field, "must be email, sms or push")
+			continue
+		}
+
+		if channels[channel] {
+			errs.Add(field, "is listed twice")
+		}
+
+		channels[channel] = true
+	}
+
+	if req.Email != "" || channels[NotificationEmail] {
+		if aliasType, _, ok := parseAlias(req.Email); !ok || aliasType != AliasEmail {
+			errs.Add("email", "must be an email address")
+		}
+	}
+
+	if req.Phone != "" || channels[NotificationSMS] {
+		if aliasType, _, ok := parseAlias(req.Phone); !ok || aliasType != AliasPhone {
+			errs.Add("phone", "must be a phone number with its country code")
+		}
+	}
+
+	switch {
+	case channels[NotificationPush] && strings.TrimSpace(req.PushToken) == "":
+		errs.Add("pushToken", "is required for push notifications")
+	case len(req.PushToken) > maxPushTokenLength:
+		errs.Add("pushToken", "must be at most %d characters", maxPushTokenLength)
+	}
+
+	if req.LowBalanceThreshold < 0 {
+		errs.Add("lowBalanceThreshold", "must not be negative")
+	}
+
+	return errs.Err()
+}
+
+func (req *AliasVerifyRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if strings.TrimSpace(req.Code) == "" {
+		errs.Add("code", "is required")
+	}
+
+	return errs.Err()
+}
+
+func (req *BeneficiaryRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requireName("name", req.Name)
+	errs.requirePositive("accountNumber", req.AccountNumber)
+
+	if utf8.RuneCountInString(req.Nickname) > maxNameLength {
+		errs.Add("nickname", "must be at most %d characters", maxNameLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *CategoryRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.Category != "" && !categoryPattern.MatchString(req.Category) {
+		errs.Add("category", "must be at most %d lowercase letters, digits, - or _, starting with a letter", maxCategoryLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *KYCRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if birth, err := time.Parse(time.DateOnly, req.DateOfBirth); err != nil {
+		errs.Add("dateOfBirth", "must be a date as YYYY-MM-DD")
+	} else if ageOn(birth, time.Now().UTC()) < minKYCAge {
+		errs.Add("dateOfBirth", "account holders must be at least %d years old", minKYCAge)
+	}
+
+	if !nationalIDPattern.MatchString(req.NationalID) {
+		errs.Add("nationalId", "must be 5 to 30 letters, digits or -")
+	}
+
+	if strings.TrimSpace(req.Address) == "" {
+		errs.Add("address", "is required")
+	} else if utf8.RuneCountInString(req.Address) > maxAddressLength {
+		errs.Add("address", "must be at most %d characters", maxAddressLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *KYCRejectRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		errs.Add("reason", "is required")
+	} else if utf8.RuneCountInString(req.Reason) > maxAddressLength {
+		errs.Add("reason", "must be at most %d characters", maxAddressLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *CloseAccountRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.SweepTo < 0 {
+		errs.Add("sweepTo", "must be an account number")
+	}
+
+	return errs.Err()
+}
+
+func (req *BalanceAdjustmentRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.Amount == 0 {
+		errs.Add("amount", "must not be zero")
+	}
+
+	switch req.ReasonCode {
+	case AdjustmentFeeRefund, AdjustmentErrorCorrection, AdjustmentGoodwill, AdjustmentOther:
+	case "":
+		errs.Add("reasonCode", "is required")
+	default:
+		errs.Add("reasonCode", "must be one of fee_refund, error_correction, goodwill or other")
+	}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		errs.Add("reason", "is required")
+	} else if utf8.RuneCountInString(req.Reason) > maxAdjustmentReasonLength {
+		errs.Add("reason", "must be at most %d characters", maxAdjustmentReasonLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *DisputeRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.TransactionID <= 0 {
+		errs.Add("transactionId", "is required")
+	}
+
+	if req.Amount < 0 {
+		errs.Add("amount", "must not be negative")
+	}
+
+	if strings.TrimSpace(req.Reason) == "" {
+		errs.Add("reason", "is required")
+	} else if utf8.RuneCountInString(req.Reason) > maxDisputeTextLength {
+		errs.Add("reason", "must be at most %d characters", maxDisputeTextLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *DisputeDecisionRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.Amount < 0 {
+		errs.Add("amount", "must not be negative")
+	}
+
+	if utf8.RuneCountInString(req.Note) > maxDisputeTextLength {
+		errs.Add("note", "must be at most %d characters", maxDisputeTextLength)
+	}
+
+	return errs.Err()
+}
+
+var panPattern = regexp.MustCompile(fmt.Sprintf(`^[0-9]{%d}$`, cardNumberLength))
+
+func (req *CardAuthorizationRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if !panPattern.MatchString(req.PAN) {
+		errs.Add("pan", "must be %d digits", cardNumberLength)
+	}
+
+	errs.requirePositive("amount", req.Amount)
+
+	if !validCurrency(req.Currency) {
+		errs.Add("currency", "is not supported")
+	}
+
+	if utf8.RuneCountInString(req.Merchant) > maxMerchantLength {
+		errs.Add("merchant", "must be at most %d characters", maxMerchantLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *ExternalTransferRequest) Validate() error {
+	errs := FieldErrors{}
+	destination := normalizeDestination(req.Destination)
+
+	switch req.Scheme {
+	case SchemeACH:
+		if !achDestinationPattern.MatchString(destination) {
+			errs.Add("destination", "must be a 9-digit routing number and an account number, as routing/account")
+		}
+	case SchemeSEPA:
+		if !validIBAN(destination) {
+			errs.Add("destination", "must be a valid IBAN")
+		}
+	default:
+		errs.Add("scheme", "must be ach or sepa")
+	}
+
+	errs.requireName("beneficiaryName", req.BeneficiaryName)
+	errs.requirePositive("amount", req.Amount)
+
+	if utf8.RuneCountInString(req.Reference) > maxReferenceLength {
+		errs.Add("reference", "must be at most %d characters", maxReferenceLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *ExternalTransferReturnRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if !returnCodePattern.MatchString(req.Code) {
+		errs.Add("code", "must be a network return code, e.g. R01 or AC04")
+	}
+
+	if utf8.RuneCountInString(req.Reason) > maxDisputeTextLength {
+		errs.Add("reason", "must be at most %d characters", maxDisputeTextLength)
+	}
+
+	return errs.Err()
+}
+
+func (req *OIDCLoginRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.IDToken == "" {
+		errs.Add("idToken", "is required")
+	}
+
+	return errs.Err()
+}
+
+func (req *LinkIdentityRequest) Validate() error {
+	errs := FieldErrors{}
+
+	if req.IDToken == "" {
+		errs.Add("idToken", "is required")
+	}
+
+	return errs.Err()
+}
+
+func (req *AmountRequest) Validate() error {
+	errs := FieldErrors{}
+
+	errs.requirePositive("amount", req.Amount)
+
+	return errs.Err()
+}
+
+func (req *MaintenanceRequest) Validate() error {
+	errs := FieldErrors{}
+
+	switch req.Mode {
+	case MaintenanceOff, MaintenanceReadOnly, MaintenanceOn:
+	default:
+		errs.Add("mode", "must be off, read_only or maintenance")
+	}
+
+	errs.checkText("message", req.Message, maxMaintenanceMessageLength)
+
+	if req.RetryAfter < 0 || time.Duration(req.RetryAfter)*time.Second > maxMaintenanceRetry {
+		errs.Add("retryAfter", "must be between 0 and %d seconds", int(maxMaintenanceRetry.Seconds()))
+	}
+
+	return errs.Err()
+}
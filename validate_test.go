@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountRequestValidate(t *testing.T) {
+	assert.Nil(t, (&AccountRequest{FirstName: "Ada", LastName: "Lovelace"}).Validate())
+
+	err := (&AccountRequest{FirstName: " ", LastName: strings.Repeat("a", 51), Password: strings.Repeat("p", 73)}).Validate()
+
+	var httpErr *HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, httpErr.Status)
+	assert.Equal(t, []FieldError{
+		{Field: "firstName", Message: "is required"},
+		{Field: "lastName", Message: "must be at most 50 characters"},
+		{Field: "password", Message: "must be at most 72 bytes"},
+	}, httpErr.Details)
+}
+
+func TestTransferRequestValidateFrom(t *testing.T) {
+	assert.Nil(t, (&TransferRequest{ToAccount: 2, Amount: 100}).ValidateFrom(1))
+
+	var httpErr *HTTPError
+
+	require.True(t, errors.As((&TransferRequest{ToAccount: 1, Amount: 100}).ValidateFrom(1), &httpErr))
+	assert.Equal(t, []FieldError{{Field: "toAccount", Message: "must not be the sending account"}}, httpErr.Details)
+
+	require.True(t, errors.As((&TransferRequest{ToAccount: 2, Amount: -5}).ValidateFrom(1), &httpErr))
+	assert.Equal(t, []FieldError{{Field: "amount", Message: "must be greater than 0"}}, httpErr.Details)
+}
+
+func TestTransferRequestValidateBeneficiary(t *testing.T) {
+	assert.Nil(t, (&TransferRequest{BeneficiaryID: 3, Amount: 100}).Validate())
+
+	var httpErr *HTTPError
+
+	require.True(t, errors.As((&TransferRequest{ToAccount: 2, BeneficiaryID: 3, Amount: 100}).Validate(), &httpErr))
+	assert.Equal(t, []FieldError{{Field: "toAccount", Message: "must not be set together with beneficiaryId"}}, httpErr.Details)
+
+	require.True(t, errors.As((&TransferRequest{Amount: 100}).Validate(), &httpErr))
+	assert.Equal(t, []FieldError{{Field: "toAccount", Message: "must be greater than 0"}}, httpErr.Details)
+}
+
+func TestAccountRequestValidateInitialDeposit(t *testing.T) {
+	req := &AccountRequest{FirstName: "Ada", LastName: "Lovelace", Password: "pw", Currency: "USD"}
+
+	req.InitialDeposit = &InitialDeposit{Source: InitialDepositTransfer, Amount: 100, FromAccount: 7}
+	assert.Nil(t, req.Validate())
+
+	var httpErr *HTTPError
+
+	req.InitialDeposit = &InitialDeposit{Source: "cash", Amount: 100}
+	require.True(t, errors.As(req.Validate(), &httpErr))
+	assert.Equal(t, []FieldError{
+		{Field: "initialDeposit.source", Message: "must be transfer"},
+		{Field: "initialDeposit.fromAccount", Message: "must be greater than 0"},
+	}, httpErr.Details, "anyone can open an account, so it can't be funded with cash")
+
+	req.InitialDeposit = &InitialDeposit{Source: InitialDepositTransfer}
+	require.True(t, errors.As(req.Validate(), &httpErr))
+	assert.Len(t, httpErr.Details, 2, "the amount and the account to transfer from")
+}
+
+func TestAccountPatchRequestValidate(t *testing.T) {
+	value := func(s string) *string { return &s }
+
+	req := &AccountPatchRequest{Metadata: map[string]*string{"crm_id": value("42"), "segment.tier": nil}}
+	assert.Nil(t, req.Validate())
+
+	var httpErr *HTTPError
+
+	req = &AccountPatchRequest{Metadata: map[string]*string{"z key": value("v"), "memo": value("a\nb")}}
+	require.True(t, errors.As(req.Validate(), &httpErr))
+	assert.Equal(t, []FieldError{
+		{Field: "metadata.memo", Message: "must not contain control characters"},
+		{Field: "metadata.z key", Message: "must be a key of at most 40 letters, digits, _, . or -"},
+	}, httpErr.Details)
+
+	req = &AccountPatchRequest{Metadata: map[string]*string{"note": value(strings.Repeat("x", maxMetadataValueLength+1))}}
+	assert.NotNil(t, req.Validate())
+
+	req = &AccountPatchRequest{}
+	assert.NotNil(t, req.Validate(), "an empty patch")
+}
+
+func TestAPIRejectsInvalidAccountRequest(t *testing.T) {
+	api := newTestAPI(t)
+
+	rec := api.do("POST", "/account", "", map[string]string{"firstName": "", "lastName": "Lovelace"})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"details":[{"field":"firstName","message":"is required"}]`)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// APIVersion is a major version of the JSON API. Each version is served under
+// its own path prefix; breaking changes to response shapes ship in a new
+// version while the older ones keep theirs through the adapters below.
+type APIVersion int
+
+const (
+	APIVersion1 APIVersion = 1
+	APIVersion2 APIVersion = 2
+)
+
+var apiVersions = []APIVersion{APIVersion1, APIVersion2}
+
+func (v APIVersion) prefix() string {
+	if v == APIVersion1 {
+		return "/v1"
+	}
+
+	return "/v2"
+}
+
+// requestAPIVersion returns the version a request was made to. Unversioned
+// paths are deprecated aliases of /v1.
+func requestAPIVersion(r *http.Request) APIVersion {
+	for _, v := range apiVersions {
+		if path := r.URL.Path; path == v.prefix() || strings.HasPrefix(path, v.prefix()+"/") {
+			return v
+		}
+	}
+
+	return APIVersion1
+}
+
+// APIErrorV2 is the error envelope of /v2: the error is nested under "error"
+// and its message is called "message".
+type APIErrorV2 struct {
+	Error APIErrorBody `json:"error"`
+}
+
+type APIErrorBody struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"requestId,omitempty"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+// errorResponse adapts an error to the envelope of the request's version.
+func errorResponse(r *http.Request, httpErr *HTTPError, requestID string) any {
+	if requestAPIVersion(r) == APIVersion1 {
+		return APIError{
+			Code:      httpErr.Code,
+			Error:     httpErr.Message,
+			RequestID: requestID,
+			Details:   httpErr.Details,
+		}
+	}
+
+	return APIErrorV2{Error: APIErrorBody{
+		Code:      httpErr.Code,
+		Message:   httpErr.Message,
+		RequestID: requestID,
+		Details:   httpErr.Details,
+	}}
+}
+
+// withDeprecatedAlias marks responses to unversioned paths as deprecated and
+// points clients at the /v1 path that replaces them.
+func withDeprecatedAlias(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", "<"+APIVersion1.prefix()+r.URL.Path+`>; rel="successor-version"`)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// versionRouters returns a subrouter of router for each API version, followed
+// by one serving the unversioned aliases of /v1.
+func versionRouters(router *mux.Router) []*mux.Router {
+	routers := []*mux.Router{}
+
+	for _, v := range apiVersions {
+		routers = append(routers, router.PathPrefix(v.prefix()).Subrouter())
+	}
+
+	aliases := router.NewRoute().Subrouter()
+	aliases.Use(withDeprecatedAlias)
+
+	return append(routers, aliases)
+}
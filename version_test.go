@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestAPIVersion(t *testing.T) {
+	for path, version := range map[string]APIVersion{
+		"/v1/account": APIVersion1,
+		"/v2/account": APIVersion2,
+		"/v2":         APIVersion2,
+		"/account":    APIVersion1,
+		"/v2account":  APIVersion1,
+	} {
+		assert.Equal(t, version, requestAPIVersion(httptest.NewRequest("GET", path, nil)), path)
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createAccount("Alice", "alice-pw")
+	token := api.login(alice, "alice-pw")
+	path := "/account/" + strconv.Itoa(alice.ID)
+
+	for _, prefix := range []string{"/v1", "/v2", ""} {
+		rec := api.do("GET", prefix+path, token, nil)
+		require.Equal(t, http.StatusOK, rec.Code, prefix)
+
+		acc := new(Account)
+		require.Nil(t, json.NewDecoder(rec.Body).Decode(acc))
+		assert.Equal(t, alice.Number, acc.Number)
+	}
+
+	rec := api.do("GET", path, token, nil)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1`+path+`>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	rec = api.do("GET", "/v1"+path, token, nil)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+
+	// /v1 keeps the flat error envelope, /v2 nests it
+	rec = api.do("GET", "/v1/account/999999", token, nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	v1 := new(APIError)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(v1))
+	assert.Equal(t, ErrorCodeForbidden, v1.Code)
+	assert.NotEmpty(t, v1.Error)
+
+	rec = api.do("GET", "/v2/account/999999", token, nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	v2 := new(APIErrorV2)
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(v2))
+	assert.Equal(t, ErrorCodeForbidden, v2.Error.Code)
+	assert.NotEmpty(t, v2.Error.Message)
+	assert.NotEmpty(t, v2.Error.RequestID)
+
+	// request validation and unknown routes answer in the version's envelope
+	rec = api.do("POST", "/v2/transfer", token, map[string]any{"toAccount": "one"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error":{"code":"bad_request"`)
+
+	rec = api.do("GET", "/v2/nowhere", token, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error":{"code":"not_found"`)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	webhookInterval    = 10 * time.Second
+	webhookBatchSize   = 50
+	webhookLease       = 2 * time.Minute
+	webhookTimeout     = 10 * time.Second
+	maxWebhookAttempts = 8
+
+	defaultLowBalanceThreshold = 10000
+)
+
+// EventPublisher is told about everything that happens to accounts.
+type EventPublisher interface {
+	Publish(ctx context.Context, event *Event)
+}
+
+// BalanceData is the payload of balance.low events.
+type BalanceData struct {
+	Balance  int64  `json:"balance"`
+	Currency string `json:"currency"`
+}
+
+// WebhookDispatcher queues a delivery for every webhook subscribed to a
+// published event and sends them in the background, retrying failures with
+// exponential backoff.
+type WebhookDispatcher struct {
+	store  Storage
+	client *http.Client
+}
+
+func NewWebhookDispatcher(store Storage) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		store:  store,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (d *WebhookDispatcher) Publish(ctx context.Context, event *Event) {
+	stampEvent(ctx, event)
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		slog.Error("encoding event", "error", err, "event", event.Type)
+		return
+	}
+
+	var balance *int64
+
+	if data, ok := event.Data.(BalanceData); ok {
+		balance = &data.Balance
+	}
+
+	if err := d.store.EnqueueWebhookDeliveries(ctx, event, payload, balance); err != nil {
+		slog.Error("queueing webhook deliveries", "error", err, "event", event.Type)
+	}
+}
+
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliverDue(ctx context.Context, now time.Time) {
+	due, err := d.store.ClaimDueWebhookDeliveries(ctx, now, now.Add(webhookLease), webhookBatchSize)
+
+	if err != nil {
+		slog.Error("claiming webhook deliveries", "error", err)
+		return
+	}
+
+	for _, delivery := range due {
+		d.deliver(ctx, delivery, now)
+
+		if err := d.store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			slog.Error("recording webhook delivery", "error", err, "deliveryId", delivery.ID)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+
+	code, err := d.post(ctx, delivery, now)
+	delivery.ResponseCode = code
+
+	if err == nil {
+		delivery.Status = WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+
+	if delivery.Attempts >= maxWebhookAttempts {
+		delivery.Status = WebhookDeliveryFailed
+		return
+	}
+
+	delivery.NextAttemptAt = now.Add(retryBackoff(delivery.Attempts))
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, delivery *WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", delivery.URL, bytes.NewReader(delivery.Payload))
+
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", string(delivery.EventType))
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Webhook-Signature", signWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// signWebhookPayload returns the X-Webhook-Signature header value. Receivers
+// recompute the HMAC-SHA256 of "<t>.<body>" with their secret and compare it
+// with v1, rejecting old timestamps to prevent replays.
+func signWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// publishTransferEvents tells both sides of a completed transfer about it and
+// lets the sender's webhooks know when the transfer left their balance low.
+func publishTransferEvents(ctx context.Context, events EventPublisher, accounts AccountRepository, transfer *Transfer) {
+	events.Publish(ctx, &Event{Type: EventTransferCompleted, AccountNumber: transfer.FromAccount, Data: transfer})
+	events.Publish(ctx, &Event{Type: EventTransferCompleted, AccountNumber: transfer.ToAccount, Data: transfer})
+
+	account, err := accounts.GetAccountByNumber(ctx, int(transfer.FromAccount))
+
+	if err != nil {
+		slog.Error("loading account for balance event", "error", err)
+		return
+	}
+
+	publishBalanceEvent(ctx, events, account.Number, account.Balance, account.Currency)
+}
+
+// publishTransferReversedEvents tells both accounts of transfer it was
+// moved back, and checks the balance of the one it was taken from again.
+func publishTransferReversedEvents(ctx context.Context, events EventPublisher, accounts AccountRepository, transfer *Transfer) {
+	events.Publish(ctx, &Event{Type: EventTransferReversed, AccountNumber: transfer.FromAccount, Data: transfer})
+	events.Publish(ctx, &Event{Type: EventTransferReversed, AccountNumber: transfer.ToAccount, Data: transfer})
+
+	account, err := accounts.GetAccountByNumber(ctx, int(transfer.ToAccount))
+
+	if err != nil {
+		slog.Error("loading account for balance event", "error", err)
+		return
+	}
+
+	publishBalanceEvent(ctx, events, account.Number, account.Balance, account.Currency)
+}
+
+// stampEvent gives a new event its ID, time and the request ID of ctx, once
+// however many publishers it goes to.
+func stampEvent(ctx context.Context, event *Event) {
+	if event.ID != "" {
+		return
+	}
+
+	event.ID = "evt_" + newRequestID()
+	event.CreatedAt = time.Now().UTC()
+	event.RequestID = requestIDFromContext(ctx)
+}
+
+func publishBalanceEvent(ctx context.Context, events EventPublisher, number, balance int64, currency string) {
+	events.Publish(ctx, &Event{
+		Type:          EventBalanceLow,
+		AccountNumber: number,
+		Data:          BalanceData{Balance: balance, Currency: currency},
+	})
+}
+
+func newWebhookSecret() (string, error) {
+	token, err := randomToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + token, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"type":"account.created"}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(payload)))
+
+	assert.Equal(t, "t=1700000000,v1="+hex.EncodeToString(mac.Sum(nil)), signWebhookPayload("whsec_test", 1700000000, payload))
+	assert.NotEqual(t, signWebhookPayload("whsec_test", 1700000000, payload), signWebhookPayload("whsec_other", 1700000000, payload))
+	assert.NotEqual(t, signWebhookPayload("whsec_test", 1700000000, payload), signWebhookPayload("whsec_test", 1700000001, payload))
+}
+
+func TestWebhookDispatcherDeliverRetries(t *testing.T) {
+	now := time.Now().UTC()
+	delivery := &WebhookDelivery{Status: WebhookDeliveryPending, URL: "http://127.0.0.1:0", Attempts: 0}
+
+	NewWebhookDispatcher(nil).deliver(context.Background(), delivery, now)
+
+	assert.Equal(t, WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, now.Add(time.Minute), delivery.NextAttemptAt)
+	assert.NotEmpty(t, delivery.LastError)
+
+	delivery.Attempts = maxWebhookAttempts - 1
+	NewWebhookDispatcher(nil).deliver(context.Background(), delivery, now)
+
+	assert.Equal(t, WebhookDeliveryFailed, delivery.Status)
+}